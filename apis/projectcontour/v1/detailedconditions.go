@@ -156,6 +156,10 @@ const (
 	// an HTTPProxy path prefix replacement issue.
 	ConditionTypePrefixReplaceError = "PrefixReplaceError"
 
+	// ConditionTypeQuotaError describes an error condition with an
+	// HTTPProxy resource that exceeds the quota of its namespace.
+	ConditionTypeQuotaError = "QuotaError"
+
 	// ConditionTypeRootNamespaceError describes an error condition
 	// with an HTTPProxy resource created in non-root namespace.
 	ConditionTypeRootNamespaceError = "RootNamespaceError"
@@ -201,6 +201,18 @@ func (status *HTTPProxyStatus) GetConditionFor(condType string) *DetailedConditi
 	return nil
 }
 
+// GetConditionFor returns the a pointer to the condition for a given type,
+// or nil if there are none currently present.
+func (status *TLSCertificateDelegationStatus) GetConditionFor(condType string) *DetailedCondition {
+	for i, cond := range status.Conditions {
+		if cond.Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
 // LongMessageLength specifies the maximum size any message field should be.
 // This is enforced on the apiserver side by CRD validation requirements.
 const LongMessageLength = 32760
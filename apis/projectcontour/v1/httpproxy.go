@@ -61,15 +61,59 @@ type Include struct {
 }
 
 // MatchCondition are a general holder for matching rules for HTTPProxies.
-// One of Prefix or Header must be provided.
+// One of Prefix, NotPrefix, Header or Geo must be provided.
 type MatchCondition struct {
 	// Prefix defines a prefix match for a request.
 	// +optional
 	Prefix string `json:"prefix,omitempty"`
 
+	// NotPrefix excludes the requests that a prefix condition of the
+	// same value would match. It is appended to the prefix that the
+	// conditions match, so that a prefix of "/api" and a NotPrefix of
+	// "/admin" exclude the requests for "/api/admin".
+	// +optional
+	NotPrefix string `json:"notprefix,omitempty"`
+
 	// Header specifies the header condition to match.
 	// +optional
 	Header *HeaderMatchCondition `json:"header,omitempty"`
+
+	// Geo specifies the condition to match on the country or
+	// autonomous system of the client address. It requires that
+	// Contour is configured with GeoIP databases.
+	// +optional
+	Geo *GeoMatchCondition `json:"geo,omitempty"`
+}
+
+// GeoMatchCondition specifies how to conditionally match against the
+// country or autonomous system of the client address, as looked up in
+// the GeoIP databases of Contour. Only one of the fields should be
+// provided.
+type GeoMatchCondition struct {
+	// Countries are ISO 3166-1 alpha-2 country codes, e.g. "DE".
+	// The condition is true when the client address is in any
+	// of the countries.
+	// +optional
+	Countries []string `json:"countries,omitempty"`
+
+	// NotCountries are ISO 3166-1 alpha-2 country codes. The
+	// condition is true when the client address is in a country
+	// that is not listed. Note that the condition is not true
+	// if the country of the client address is not known.
+	// +optional
+	NotCountries []string `json:"notcountries,omitempty"`
+
+	// ASNs are autonomous system numbers. The condition is true
+	// when the client address is in any of the autonomous systems.
+	// +optional
+	ASNs []uint32 `json:"asns,omitempty"`
+
+	// NotASNs are autonomous system numbers. The condition is true
+	// when the client address is in an autonomous system that is
+	// not listed. Note that the condition is not true if the
+	// autonomous system of the client address is not known.
+	// +optional
+	NotASNs []uint32 `json:"notasns,omitempty"`
 }
 
 // HeaderMatchCondition specifies how to conditionally match against HTTP
@@ -198,6 +242,15 @@ type VirtualHost struct {
 	// all leaves of the DAG rooted at this object relate to the fqdn.
 	Fqdn string `json:"fqdn"`
 
+	// AdditionalFqdns are further fully qualified domain names on
+	// which the routes, TLS and policies of this virtual host are
+	// served. Each name must be unique across all HTTPProxies.
+	// If TLS is enabled, the certificate must also be valid for
+	// these names.
+	//
+	// +optional
+	AdditionalFqdns []string `json:"additionalFqdns,omitempty"`
+
 	// If present the fields describes TLS properties of the virtual
 	// host. The SNI names that will be matched on are described in fqdn,
 	// the tls.secretName secret must contain a certificate that itself
@@ -221,6 +274,187 @@ type VirtualHost struct {
 	// The policy for rate limiting on the virtual host.
 	// +optional
 	RateLimitPolicy *RateLimitPolicy `json:"rateLimitPolicy,omitempty"`
+	// The policy for trusting and updating the X-Forwarded-For header
+	// on the virtual host. Only virtual hosts that terminate TLS have a
+	// dedicated connection manager, so the policy is ignored otherwise.
+	// +optional
+	XffPolicy *XffPolicy `json:"xffPolicy,omitempty"`
+	// The access logging policy for the routes of the virtual host.
+	// Routes may override it with their own policy.
+	// +optional
+	AccessLogPolicy *AccessLogPolicy `json:"accessLogPolicy,omitempty"`
+	// StatsName enables Envoy request statistics for the virtual host,
+	// reported under the virtual cluster of this name. Requests to
+	// routes with their own StatsName are reported under the route's
+	// virtual cluster instead.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_-]+$`
+	// +kubebuilder:validation:MaxLength=60
+	StatsName string `json:"statsName,omitempty"`
+	// The policy for the x-request-id header of requests to the
+	// virtual host.
+	// +optional
+	RequestIDPolicy *RequestIDPolicy `json:"requestIDPolicy,omitempty"`
+	// Listeners are the names of the HTTP and HTTPS listeners, declared
+	// in the Contour configuration file, that serve the virtual host.
+	// The default listeners are named ingress_http and ingress_https.
+	// If not specified, the virtual host is served on the default
+	// listeners.
+	// +optional
+	Listeners []string `json:"listeners,omitempty"`
+	// Lua is a Lua script that Envoy runs on the requests to the
+	// virtual host, and their responses, unless a route has its own.
+	// It is only permitted in the namespaces that are allowed to run
+	// Lua scripts in the Contour configuration file.
+	// +optional
+	Lua *LuaPolicy `json:"lua,omitempty"`
+	// WasmModules are the Wasm modules that Envoy runs, in order, as
+	// HTTP filters on the requests to the virtual host. They require
+	// that the virtual host terminates TLS, since only then does it
+	// have its own HTTP connection manager.
+	// +optional
+	WasmModules []WasmModuleReference `json:"wasmModules,omitempty"`
+	// The policy for rejecting requests to the virtual host while its
+	// services fail, so that overloaded services shed load at Envoy
+	// rather than time out. It requires that the virtual host
+	// terminates TLS, since only then does it have its own HTTP
+	// connection manager.
+	// +optional
+	AdmissionControlPolicy *AdmissionControlPolicy `json:"admissionControlPolicy,omitempty"`
+	// The policy for denying or tagging the requests to the virtual
+	// host by their User-Agent header, such as the requests of
+	// scrapers and bots.
+	// +optional
+	UserAgentPolicy *UserAgentPolicy `json:"userAgentPolicy,omitempty"`
+}
+
+// AdmissionControlPolicy defines how Envoy rejects requests to a virtual
+// host while its services fail. Once the success rate of the requests in
+// the sampling window drops below the threshold, Envoy rejects a share
+// of new requests with a 503 response, which grows as the success rate
+// drops further. Responses with a 5xx status code, and gRPC responses
+// with a status that indicates a server error, are failures.
+type AdmissionControlPolicy struct {
+	// SuccessRateThreshold is the percentage of successful requests
+	// below which requests are rejected. If not specified, it is 95.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	SuccessRateThreshold uint32 `json:"successRateThreshold,omitempty"`
+
+	// Aggression defines how quickly the share of rejected requests
+	// grows as the success rate drops. With 1, the share grows in
+	// proportion to the drop, and higher values reject more requests
+	// at the same success rate. It is a decimal number that is at
+	// least 1, such as "1.5". If not specified, it is 1.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Aggression string `json:"aggression,omitempty"`
+
+	// SamplingWindowSeconds is the length of the sliding window over
+	// which the success rate is measured. If not specified, it is 30.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SamplingWindowSeconds int64 `json:"samplingWindowSeconds,omitempty"`
+
+	// MinRequestsPerSecond is the average number of requests per second
+	// in the sampling window below which no requests are rejected,
+	// whatever the success rate. If not specified, it is 0.
+	// +optional
+	MinRequestsPerSecond uint32 `json:"minRequestsPerSecond,omitempty"`
+
+	// MaxRejectionPercentage is the largest percentage of requests that
+	// are rejected, however low the success rate. If not specified,
+	// it is 80.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MaxRejectionPercentage uint32 `json:"maxRejectionPercentage,omitempty"`
+}
+
+// UserAgentPolicy defines how Envoy handles the requests to a virtual
+// host whose User-Agent header matches any of its patterns. Requests
+// without a User-Agent header never match.
+type UserAgentPolicy struct {
+	// Patterns are RE2 regular expressions, such as "(?i)bot", that
+	// are searched for in the User-Agent header of requests. A request
+	// matches the policy if its header contains a match of any of them.
+	// +kubebuilder:validation:MinItems=1
+	Patterns []string `json:"patterns"`
+
+	// Action is what happens to requests that match the policy. Deny,
+	// the default, responds to them with a 403 (Forbidden) response.
+	// Header sets Header on them, and routes them as usual.
+	// +optional
+	// +kubebuilder:validation:Enum=Deny;Header
+	Action string `json:"action,omitempty"`
+
+	// Header is the request header that the Header action sets on the
+	// requests that match the policy. It is removed from the requests
+	// that don't match, so that clients can't set it.
+	// +optional
+	Header *HeaderValue `json:"header,omitempty"`
+}
+
+// WasmModuleReference names a WasmModule resource.
+type WasmModuleReference struct {
+	// Namespace of the WasmModule. If not specified, the namespace
+	// of the HTTPProxy is used.
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the WasmModule.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// RequestIDPolicy defines how the x-request-id header of requests to a
+// virtual host is set.
+type RequestIDPolicy struct {
+	// Policy defines whether the x-request-id header of requests from
+	// external clients is preserved, or replaced with a generated ID.
+	// Only virtual hosts that terminate TLS have a dedicated connection
+	// manager, so the policy is ignored otherwise. If not specified,
+	// the value configured for Contour is used.
+	// +optional
+	// +kubebuilder:validation:Enum=Preserve;Generate
+	Policy string `json:"policy,omitempty"`
+	// Header is the name of a request header whose value, if present,
+	// is used as the x-request-id of the request. This allows request
+	// IDs assigned by a CDN in front of Envoy to be used as the request
+	// ID. If not specified, the header configured for Contour is used.
+	// +optional
+	Header string `json:"header,omitempty"`
+}
+
+// AccessLogPolicy defines whether and how often requests are written
+// to the Envoy access log.
+type AccessLogPolicy struct {
+	// Disabled turns off access logging of requests.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// SamplingPercentage is the percentage of requests that are
+	// logged. If not supplied, all requests are logged.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SamplingPercentage *uint32 `json:"samplingPercentage,omitempty"`
+}
+
+// XffPolicy defines how the X-Forwarded-For header is trusted and
+// updated for requests to a virtual host.
+type XffPolicy struct {
+	// NumTrustedHops is the number of additional ingress proxy hops
+	// from the right side of the X-Forwarded-For header to trust when
+	// determining the client address. If not specified, the value
+	// configured for Contour is used.
+	// +optional
+	NumTrustedHops *uint32 `json:"numTrustedHops,omitempty"`
+	// SkipAppend disables appending the client address to the
+	// X-Forwarded-For header.
+	// +optional
+	SkipAppend bool `json:"skipAppend,omitempty"`
 }
 
 // TLS describes tls properties. The SNI names that will be matched on
@@ -303,10 +537,23 @@ type Route struct {
 	// route invalid.
 	// +optional
 	Conditions []MatchCondition `json:"conditions,omitempty"`
+	// Priority orders the route among the routes of the virtual host
+	// that have the same path condition. Routes with a higher priority
+	// are matched first, and routes with the same priority are matched
+	// in order of their header conditions. If not specified, it is 0.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
 	// Services are the services to proxy traffic.
-	// +kubebuilder:validation:MinItems=1
-	// +kubebuilder:validation:Required
-	Services []Service `json:"services"`
+	// Services must be set unless DynamicForwardProxy is true.
+	// +optional
+	Services []Service `json:"services,omitempty"`
+	// DynamicForwardProxy proxies requests to the host named by their
+	// Host header, which may be rewritten with RequestHeadersPolicy,
+	// rather than to Services. The host is resolved with DNS by Envoy.
+	// This is only permitted if enableDynamicForwardProxy is set in the
+	// Contour configuration file.
+	// +optional
+	DynamicForwardProxy bool `json:"dynamicForwardProxy,omitempty"`
 	// Enables websocket support for the route.
 	// +optional
 	EnableWebsockets bool `json:"enableWebsockets,omitempty"`
@@ -325,6 +572,23 @@ type Route struct {
 	// The retry policy for this route.
 	// +optional
 	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+	// The hedge policy for this route.
+	// +optional
+	HedgePolicy *HedgePolicy `json:"hedgePolicy,omitempty"`
+	// The access logging policy for this route. Overrides the
+	// policy of the virtual host.
+	// +optional
+	AccessLogPolicy *AccessLogPolicy `json:"accessLogPolicy,omitempty"`
+	// The tracing policy for this route. Overrides the global
+	// tracing configuration of Contour for requests to the route.
+	// +optional
+	TracingPolicy *TracingPolicy `json:"tracingPolicy,omitempty"`
+	// StatsName enables Envoy request statistics for the route,
+	// reported under the virtual cluster of this name.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_-]+$`
+	// +kubebuilder:validation:MaxLength=60
+	StatsName string `json:"statsName,omitempty"`
 	// The health check policy for this route.
 	// +optional
 	HealthCheckPolicy *HTTPHealthCheckPolicy `json:"healthCheckPolicy,omitempty"`
@@ -343,9 +607,84 @@ type Route struct {
 	// Rewriting the 'Host' header is not supported.
 	// +optional
 	ResponseHeadersPolicy *HeadersPolicy `json:"responseHeadersPolicy,omitempty"`
+	// The policies for rewriting the attributes of the cookies that
+	// are set by responses. Each policy must name a different cookie.
+	// +optional
+	CookieRewritePolicies []CookieRewritePolicy `json:"cookieRewritePolicies,omitempty"`
+	// Lua is a Lua script that Envoy runs on the requests that match
+	// this route, and their responses, in place of the script of the
+	// virtual host. It is only permitted in the namespaces that are
+	// allowed to run Lua scripts in the Contour configuration file.
+	// +optional
+	Lua *LuaPolicy `json:"lua,omitempty"`
+	// The policy for caching the responses of the route in Envoy.
+	// If not specified, responses are not cached.
+	// +optional
+	CachePolicy *CachePolicy `json:"cachePolicy,omitempty"`
+	// The policy for buffering the bodies of requests to the route
+	// in Envoy. If not specified, requests are not buffered.
+	// +optional
+	BufferPolicy *BufferPolicy `json:"bufferPolicy,omitempty"`
+	// The policy for capturing requests to the route, and their
+	// responses, while debugging. Tap policies must be permitted
+	// by the Contour configuration.
+	// +optional
+	TapPolicy *TapPolicy `json:"tapPolicy,omitempty"`
+	// The RBAC policy of the route, which allows or denies requests
+	// by who sends them and what they request. If not specified,
+	// all requests are allowed.
+	// +optional
+	RBACPolicy *RBACPolicy `json:"rbacPolicy,omitempty"`
 	// The policy for rate limiting on the route.
 	// +optional
 	RateLimitPolicy *RateLimitPolicy `json:"rateLimitPolicy,omitempty"`
+	// The policy for proxying oversized requests to a dedicated service.
+	// Requests are oversized by the size of their body, not their headers.
+	// +optional
+	OverflowPolicy *OverflowPolicy `json:"overflowPolicy,omitempty"`
+	// GRPC matches the route to gRPC requests for a service and,
+	// optionally, a method, rather than to a path prefix. The route
+	// cannot also have a prefix condition, either directly or
+	// inherited from an include.
+	// +optional
+	GRPC *GRPCRoute `json:"grpc,omitempty"`
+}
+
+// GRPCRoute matches gRPC requests by service and method.
+type GRPCRoute struct {
+	// Service is the fully qualified name of the gRPC service,
+	// for example "helloworld.Greeter".
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_.]*$`
+	Service string `json:"service"`
+	// Method is the name of the gRPC method. If not supplied,
+	// all methods of the service are matched.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	Method string `json:"method,omitempty"`
+	// MaxTimeout enables the deadline that clients send in the
+	// grpc-timeout request header, limited to this value. Use
+	// "infinity" to apply client deadlines without limit. If not
+	// supplied, the grpc-timeout header is ignored.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	MaxTimeout string `json:"maxTimeout,omitempty"`
+}
+
+// OverflowPolicy defines a policy for proxying requests that exceed a
+// size threshold to a dedicated service, so that heavy requests do not
+// compete with the route's latency-sensitive services.
+// Only the size of the request body is a threshold. The size of the
+// request headers is not supported, since Envoy cannot route on it.
+type OverflowPolicy struct {
+	// MaxRequestBodyBytes is the largest request body, as declared by
+	// the request's Content-Length header, that is proxied to the route's
+	// services. Requests declaring a larger body are proxied to the
+	// overflow service instead.
+	// +kubebuilder:validation:Minimum=0
+	MaxRequestBodyBytes int64 `json:"maxRequestBodyBytes"`
+	// Service is the service that oversized requests are proxied to.
+	// +kubebuilder:validation:Required
+	Service Service `json:"service"`
 }
 
 // RateLimitPolicy defines rate limiting parameters.
@@ -519,6 +858,45 @@ type TCPProxy struct {
 	// The health check policy for this tcp proxy
 	// +optional
 	HealthCheckPolicy *TCPHealthCheckPolicy `json:"healthCheckPolicy,omitempty"`
+	// Port selects an additional TCP listener, declared in the Contour
+	// configuration file, that forwards connections to the services
+	// without TLS. It cannot be combined with Spec.VirtualHost.TLS.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port,omitempty"`
+	// The timeout policy for connections through this tcp proxy.
+	// +optional
+	TimeoutPolicy *TCPProxyTimeoutPolicy `json:"timeoutPolicy,omitempty"`
+	// MaxConnectAttempts is the maximum number of unsuccessful
+	// connection attempts to the upstream services that are made
+	// before the downstream connection is closed.
+	// If not supplied, Envoy's default value of 1 applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxConnectAttempts uint32 `json:"maxConnectAttempts,omitempty"`
+	// DisableAccessLog turns off access logging of connections
+	// through this tcp proxy.
+	// +optional
+	DisableAccessLog bool `json:"disableAccessLog,omitempty"`
+}
+
+// TCPProxyTimeoutPolicy defines the timeouts applied to connections
+// through a TCPProxy.
+type TCPProxyTimeoutPolicy struct {
+	// Timeout after which, if no data has been sent or received in
+	// either direction, the downstream and upstream connections are
+	// closed. If not supplied, a default of 9001s applies.
+	// Use "infinity" to never close idle connections.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+|infinity|infinite)$`
+	Idle string `json:"idle,omitempty"`
+
+	// Timeout for establishing a connection to an upstream service.
+	// If not supplied, a default of 250ms applies.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+)$`
+	Connect string `json:"connect,omitempty"`
 }
 
 // TCPProxyInclude describes a target HTTPProxy document which contains the TCPProxy details.
@@ -557,6 +935,10 @@ type Service struct {
 	UpstreamValidation *UpstreamValidation `json:"validation,omitempty"`
 	// If Mirror is true the Service will receive a read only mirror of the traffic for this route.
 	Mirror bool `json:"mirror,omitempty"`
+	// MirrorPolicy defines how the requests mirrored to the Service are
+	// changed. It may only be set when Mirror is true.
+	// +optional
+	MirrorPolicy *MirrorPolicy `json:"mirrorPolicy,omitempty"`
 	// The policy for managing request headers during proxying.
 	// Rewriting the 'Host' header is not supported.
 	// +optional
@@ -565,12 +947,100 @@ type Service struct {
 	// Rewriting the 'Host' header is not supported.
 	// +optional
 	ResponseHeadersPolicy *HeadersPolicy `json:"responseHeadersPolicy,omitempty"`
+	// ProxyProtocol is the version of the PROXY protocol header to send
+	// to the Service, so that it can learn the original client address.
+	// Values may be v1, v2. If omitted, no PROXY protocol header is sent.
+	// +kubebuilder:validation:Enum=v1;v2
+	// +optional
+	ProxyProtocol string `json:"proxyProtocol,omitempty"`
+	// PodSelector restricts traffic to the endpoints of a headless Service
+	// whose pods have all of the given labels. It may only be used with
+	// headless Services, and allows routing to an individual shard of a
+	// StatefulSet, for example.
+	// +optional
+	PodSelector map[string]string `json:"podSelector,omitempty"`
+	// Endpoints is an explicit list of addresses to proxy traffic to, for
+	// backends that run outside the cluster. If set, Name does not refer
+	// to a Kubernetes Service and only identifies the backend.
+	// +optional
+	Endpoints []StaticEndpoint `json:"endpoints,omitempty"`
+	// HTTP2 tunes the HTTP/2 connections to the Service. It may only
+	// be used when the protocol is h2 or h2c.
+	// +optional
+	HTTP2 *HTTP2Settings `json:"http2,omitempty"`
+	// Failover marks the Service as a warm standby for the other
+	// services of the route. It only receives traffic when all the
+	// endpoints of the other services are unhealthy, and it uses
+	// their connection settings. It may only be set on route services.
+	// +optional
+	Failover bool `json:"failover,omitempty"`
+}
+
+// MirrorPolicy defines how the requests mirrored to a Service are changed.
+type MirrorPolicy struct {
+	// RequestHeaders are set on each mirrored request, so that the mirror
+	// Service can tell mirrored requests apart from live traffic, for
+	// example with an "x-shadow: true" header.
+	// Rewriting the 'Host' header is not supported.
+	// +kubebuilder:validation:MinItems=1
+	RequestHeaders []HeaderValue `json:"requestHeaders"`
+}
+
+// HTTP2Settings defines the HTTP/2 protocol settings used for
+// connections to an upstream Service.
+type HTTP2Settings struct {
+	// MaxConcurrentStreams is the maximum number of concurrent streams
+	// on a single upstream connection.
+	// If not supplied, Envoy's default value of 2147483647 applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=2147483647
+	MaxConcurrentStreams uint32 `json:"maxConcurrentStreams,omitempty"`
+	// InitialStreamWindowSize is the initial flow-control window size,
+	// in bytes, of each stream.
+	// If not supplied, Envoy's default value of 268435456 applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=65535
+	// +kubebuilder:validation:Maximum=2147483647
+	InitialStreamWindowSize uint32 `json:"initialStreamWindowSize,omitempty"`
+	// InitialConnectionWindowSize is the initial flow-control window
+	// size, in bytes, of each connection.
+	// If not supplied, Envoy's default value of 268435456 applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=65535
+	// +kubebuilder:validation:Maximum=2147483647
+	InitialConnectionWindowSize uint32 `json:"initialConnectionWindowSize,omitempty"`
+	// KeepaliveInterval is the interval between HTTP/2 PING frames
+	// sent on idle upstream connections. Keepalive pings are only
+	// sent if both KeepaliveInterval and KeepaliveTimeout are set.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+)$`
+	KeepaliveInterval string `json:"keepaliveInterval,omitempty"`
+	// KeepaliveTimeout is how long to wait for a response to a
+	// keepalive ping before the connection is closed.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+)$`
+	KeepaliveTimeout string `json:"keepaliveTimeout,omitempty"`
+}
+
+// StaticEndpoint is an address of a backend that is not
+// discovered from a Kubernetes Service.
+type StaticEndpoint struct {
+	// Address is the IP address of the endpoint.
+	Address string `json:"address"`
+	// Port of the endpoint. If omitted, the port of the service is used.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port,omitempty"`
 }
 
 // HTTPHealthCheckPolicy defines health checks on the upstream service.
 type HTTPHealthCheckPolicy struct {
-	// HTTP endpoint used to perform health checks on upstream service
-	Path string `json:"path"`
+	// HTTP endpoint used to perform health checks on upstream service.
+	// Required unless GRPC is set.
+	// +optional
+	Path string `json:"path,omitempty"`
 	// The value of the host header in the HTTP health check request.
 	// If left empty (default value), the name "contour-envoy-healthcheck"
 	// will be used.
@@ -589,6 +1059,52 @@ type HTTPHealthCheckPolicy struct {
 	// +optional
 	// +kubebuilder:validation:Minimum=0
 	HealthyThresholdCount int64 `json:"healthyThresholdCount"`
+	// GRPC checks the health of the upstream service with the gRPC
+	// health checking protocol, grpc.health.v1, rather than with
+	// an HTTP request to Path. The service must use the h2 or h2c protocol.
+	// +optional
+	GRPC *GRPCHealthCheckPolicy `json:"grpc,omitempty"`
+	// ExpectedStatuses are the ranges of HTTP status codes that mark a
+	// host healthy. If not supplied, only 200 marks a host healthy.
+	// +optional
+	ExpectedStatuses []HTTPStatusRange `json:"expectedStatuses,omitempty"`
+	// Port is the port of the upstream endpoints that health checks are
+	// sent to, for backends that report their health on another port,
+	// such as that of a sidecar. If not supplied, the service port is used.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port,omitempty"`
+	// RequestHeaders are added to the HTTP health check request.
+	// The Host header cannot be set here; use Host instead.
+	// +optional
+	RequestHeaders []HeaderValue `json:"requestHeaders,omitempty"`
+}
+
+// HTTPStatusRange is an inclusive range of HTTP status codes.
+type HTTPStatusRange struct {
+	// Start is the first status code of the range.
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	Start int64 `json:"start"`
+	// End is the last status code of the range.
+	// If not supplied, the range only contains Start.
+	// +optional
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	End int64 `json:"end,omitempty"`
+}
+
+// GRPCHealthCheckPolicy defines gRPC health checks on the upstream service.
+type GRPCHealthCheckPolicy struct {
+	// ServiceName is the name of the service whose health is checked.
+	// If left empty, the health of the whole server is checked.
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+	// Authority is the value of the :authority header in the health
+	// check request. If left empty, the name of the Envoy cluster is used.
+	// +optional
+	Authority string `json:"authority,omitempty"`
 }
 
 // TCPHealthCheckPolicy defines health checks on the upstream service.
@@ -605,6 +1121,15 @@ type TCPHealthCheckPolicy struct {
 	// The number of healthy health checks required before a host is marked healthy
 	// +optional
 	HealthyThresholdCount uint32 `json:"healthyThresholdCount"`
+	// Send is the hex encoded payload sent to the host after the
+	// connection is established. If not supplied, no data is sent.
+	// +optional
+	Send string `json:"send,omitempty"`
+	// Receive is the list of hex encoded payloads which, in order,
+	// the host must respond with for the check to pass. If not
+	// supplied, a successful connection is enough.
+	// +optional
+	Receive []string `json:"receive,omitempty"`
 }
 
 // TimeoutPolicy configures timeouts that are used for handling network requests.
@@ -676,6 +1201,52 @@ type RetryPolicy struct {
 	RetriableStatusCodes []uint32 `json:"retriableStatusCodes,omitempty"`
 }
 
+// HedgePolicy defines the attributes associated with request hedging.
+// Hedging sends additional requests upstream before earlier ones have
+// completed, and uses the first response that arrives.
+type HedgePolicy struct {
+	// InitialRequests is the number of requests sent upstream
+	// at once. If not supplied, a single request is sent.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	InitialRequests uint32 `json:"initialRequests,omitempty"`
+	// HedgeOnPerTryTimeout sends a new request when the per-try
+	// timeout of the retry policy elapses, without cancelling the
+	// outstanding one. It requires a retry policy with a perTryTimeout.
+	// +optional
+	HedgeOnPerTryTimeout bool `json:"hedgeOnPerTryTimeout,omitempty"`
+}
+
+// TracingPolicy defines how requests to a route are traced. It only
+// has effect when tracing is configured for Contour.
+type TracingPolicy struct {
+	// SamplingPercentage is the percentage of requests to the route
+	// that are traced. If not supplied, the global sampling
+	// percentage applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SamplingPercentage *uint32 `json:"samplingPercentage,omitempty"`
+	// CustomTags are tags added to the spans of requests to the route.
+	// +optional
+	CustomTags []CustomTag `json:"customTags,omitempty"`
+}
+
+// CustomTag defines a tag added to spans, with either a literal value
+// or the value of a request header.
+type CustomTag struct {
+	// TagName is the name of the tag.
+	// +kubebuilder:validation:MinLength=1
+	TagName string `json:"tagName"`
+	// Literal is the value of the tag.
+	// +optional
+	Literal string `json:"literal,omitempty"`
+	// RequestHeaderName is the name of the request header whose value
+	// is the value of the tag.
+	// +optional
+	RequestHeaderName string `json:"requestHeaderName,omitempty"`
+}
+
 // ReplacePrefix describes a path prefix replacement.
 type ReplacePrefix struct {
 	// Prefix specifies the URL path prefix to be replaced.
@@ -737,8 +1308,16 @@ type RequestHashPolicy struct {
 	// HeaderHashOptions should be set when request header hash based load
 	// balancing is desired. It must be the only hash option field set,
 	// otherwise this request hash policy object will be ignored.
-	// +kubebuilder:validation:Required
+	// +optional
 	HeaderHashOptions *HeaderHashOptions `json:"headerHashOptions,omitempty"`
+
+	// HashSourceIP should be set to true when request source IP hash based
+	// load balancing is desired. It must be the only hash option field set,
+	// otherwise this request hash policy object will be ignored. Since the
+	// source IP is always present, it can be used as the last hash policy to
+	// fall back on when the request attributes of the others are not present.
+	// +optional
+	HashSourceIP bool `json:"hashSourceIP,omitempty"`
 }
 
 // LoadBalancerPolicy defines the load balancing policy.
@@ -759,6 +1338,217 @@ type LoadBalancerPolicy struct {
 	RequestHashPolicies []RequestHashPolicy `json:"requestHashPolicies,omitempty"`
 }
 
+// BufferPolicy defines how the bodies of requests to a route are buffered
+// by Envoy. A buffered request is only proxied once its whole body has
+// been received, which protects services from slow clients and enforces
+// a limit on the size of request bodies.
+type BufferPolicy struct {
+	// MaxRequestBytes is the size, in bytes, of the largest request body
+	// that is buffered.
+	// +kubebuilder:validation:Minimum=1
+	MaxRequestBytes uint32 `json:"maxRequestBytes"`
+
+	// OnOverflow defines what happens to requests whose body is larger
+	// than MaxRequestBytes. Reject, the default, fails them with a 413
+	// (Payload Too Large) response. Passthrough proxies the requests
+	// that declare a larger body in their Content-Length header without
+	// buffering them. Larger requests without a Content-Length header
+	// are rejected either way.
+	// +optional
+	// +kubebuilder:validation:Enum=Reject;Passthrough
+	OnOverflow string `json:"onOverflow,omitempty"`
+}
+
+// CachePolicy defines how the responses of a route are cached by Envoy.
+// Responses are only cached if their Cache-Control or Expires headers
+// allow it, and requests that have an Authorization header or are
+// conditional are not served from the cache.
+type CachePolicy struct {
+	// Methods are the methods of the requests that are served from the
+	// cache, either GET or HEAD. If not specified, only GET requests
+	// are served from the cache.
+	// +optional
+	Methods []CacheMethod `json:"methods,omitempty"`
+
+	// MaxObjectSize is the size, in bytes, of the largest response body
+	// that is cached. Responses without a Content-Length header are not
+	// cached when it is set. If not specified, responses of any size
+	// are cached.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxObjectSize uint32 `json:"maxObjectSize,omitempty"`
+
+	// KeyHeaders are the names of the request headers whose values are
+	// part of the cache key, so that requests with different values
+	// are served different responses. They are added to the Vary
+	// header of the responses.
+	// +optional
+	KeyHeaders []string `json:"keyHeaders,omitempty"`
+}
+
+// CacheMethod is the method of a request that may be served from the cache.
+// +kubebuilder:validation:Enum=GET;HEAD
+type CacheMethod string
+
+// TapPolicy defines how Envoy captures requests to a route, and their
+// responses, in full. Captured requests are written to files in the
+// Envoy pod, or streamed to an ExtensionService over gRPC.
+type TapPolicy struct {
+	// ExpiresAt is the time at which the route stops capturing requests.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+
+	// MaxRequests is the number of requests that are captured.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10000
+	// +kubebuilder:default=10
+	MaxRequests uint32 `json:"maxRequests,omitempty"`
+
+	// Headers are conditions on the request headers that select the
+	// requests that are captured. All of them must match. If not
+	// specified, every request to the route is captured.
+	// +optional
+	Headers []HeaderMatchCondition `json:"headers,omitempty"`
+
+	// Sink is where captured requests are sent. File, the default,
+	// writes each of them to a file in the directory set by the
+	// Contour configuration. GRPC streams them to the ExtensionService
+	// named by ExtensionServiceRef.
+	// +optional
+	// +kubebuilder:validation:Enum=File;GRPC
+	Sink string `json:"sink,omitempty"`
+
+	// ExtensionServiceRef names the ExtensionService that captured
+	// requests are streamed to when Sink is GRPC.
+	// +optional
+	ExtensionServiceRef *ExtensionServiceReference `json:"extensionRef,omitempty"`
+}
+
+// RBACPolicy defines which requests to a route are allowed by Envoy,
+// without an external authorization service. Denied requests fail
+// with a 403 (Forbidden) response.
+type RBACPolicy struct {
+	// Action is what happens to requests that match a rule. Allow, the
+	// default, allows them and denies all other requests. Deny denies
+	// them and allows all other requests.
+	// +optional
+	// +kubebuilder:validation:Enum=Allow;Deny
+	Action string `json:"action,omitempty"`
+
+	// Rules are the rules that requests are matched against. A request
+	// matches the policy if it matches any of them.
+	// +kubebuilder:validation:MinItems=1
+	Rules []RBACRule `json:"rules"`
+}
+
+// RBACRule matches the requests that match one of its principals and
+// one of its permissions.
+type RBACRule struct {
+	// Principals match who sends a request. If not specified, requests
+	// from any client match.
+	// +optional
+	Principals []RBACPrincipal `json:"principals,omitempty"`
+
+	// Permissions match what a request asks for. If not specified,
+	// requests for any method and path match.
+	// +optional
+	Permissions []RBACPermission `json:"permissions,omitempty"`
+}
+
+// RBACPrincipal matches the client of a request. Exactly one field
+// must be set.
+type RBACPrincipal struct {
+	// SubjectAltName matches clients whose certificate has this URI or
+	// DNS subject alternative name. It requires that the virtual host
+	// validates client certificates.
+	// +optional
+	SubjectAltName string `json:"subjectAltName,omitempty"`
+
+	// SourceCIDR matches clients whose address is in this CIDR range,
+	// such as "10.0.0.0/8". The address is taken from the
+	// X-Forwarded-For header when Envoy is configured to trust it.
+	// +optional
+	SourceCIDR string `json:"sourceCIDR,omitempty"`
+
+	// Header matches requests whose header matches this condition,
+	// such as a header that holds a claim of an authenticated user.
+	// +optional
+	Header *HeaderMatchCondition `json:"header,omitempty"`
+}
+
+// RBACPermission matches the requests for any of its methods whose
+// path starts with its prefix.
+type RBACPermission struct {
+	// Methods are the methods of the requests that match. If not
+	// specified, requests for any method match.
+	// +optional
+	Methods []string `json:"methods,omitempty"`
+
+	// PathPrefix is the prefix of the paths of the requests that
+	// match. If not specified, requests for any path match.
+	// +optional
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
+// CookieRewritePolicy defines how the attributes of a cookie that is set
+// by a Set-Cookie response header are rewritten. Attributes that are not
+// specified are left as they are.
+type CookieRewritePolicy struct {
+	// Name is the name of the cookie whose attributes are rewritten.
+	// If empty, the policy applies to every cookie that is not named
+	// by another policy of the route.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Domain replaces the Domain attribute of the cookie. If set to
+	// the empty string, the Domain attribute is removed, so that the
+	// cookie is only sent to the host that set it.
+	// +optional
+	Domain *string `json:"domain,omitempty"`
+
+	// Secure adds the Secure attribute to the cookie if true, or
+	// removes it if false.
+	// +optional
+	Secure *bool `json:"secure,omitempty"`
+
+	// HTTPOnly adds the HttpOnly attribute to the cookie if true, or
+	// removes it if false.
+	// +optional
+	HTTPOnly *bool `json:"httpOnly,omitempty"`
+
+	// SameSite replaces the SameSite attribute of the cookie. Values
+	// may be Strict, Lax or None. Browsers reject cookies with a
+	// SameSite attribute of None that are not also Secure.
+	// +kubebuilder:validation:Enum=Strict;Lax;None
+	// +optional
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+// LuaPolicy defines the source code of a Lua script, which defines
+// the envoy_on_request and envoy_on_response functions of the Envoy
+// Lua filter. Exactly one of InlineCode or ConfigMapRef must be set.
+type LuaPolicy struct {
+	// InlineCode is the source code of the Lua script.
+	// +optional
+	InlineCode string `json:"inlineCode,omitempty"`
+
+	// ConfigMapRef refers to the key of a ConfigMap, in the namespace
+	// of the HTTPProxy, whose value is the source code of the Lua script.
+	// +optional
+	ConfigMapRef *ConfigMapKeyReference `json:"configMapRef,omitempty"`
+}
+
+// ConfigMapKeyReference refers to a key of a ConfigMap.
+type ConfigMapKeyReference struct {
+	// Name is the name of the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key of the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+}
+
 // HeadersPolicy defines how headers are managed during forwarding.
 // The `Host` header is treated specially and if set in a HTTP response
 // will be used as the SNI server name when forwarding over TLS. It is an
@@ -771,6 +1561,12 @@ type HeadersPolicy struct {
 	// Remove specifies a list of HTTP header names to remove.
 	// +optional
 	Remove []string `json:"remove,omitempty"`
+	// RemoveMatching specifies a list of matches of HTTP header names to remove.
+	// Headers are removed before the headers in Set are set, so a header that is
+	// set is never removed. RemoveMatching is only supported on the headers
+	// policies of routes.
+	// +optional
+	RemoveMatching []HeaderNameMatch `json:"removeMatching,omitempty"`
 }
 
 // HeaderValue represents a header name/value pair
@@ -783,6 +1579,25 @@ type HeaderValue struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinLength=1
 	Value string `json:"value"`
+	// Append, if true, appends the value to any existing values of the
+	// header, rather than overwriting them. It is only supported in
+	// headers policies.
+	// +optional
+	Append bool `json:"append,omitempty"`
+}
+
+// HeaderNameMatch matches HTTP header names by either a prefix
+// or a regular expression. Exactly one of them must be set.
+type HeaderNameMatch struct {
+	// Prefix matches the header names that start with the prefix,
+	// ignoring case.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// Regex matches the header names that contain a match of the
+	// regular expression, ignoring case. The regular expression may
+	// not contain groups, alternations or repetition counts.
+	// +optional
+	Regex string `json:"regex,omitempty"`
 }
 
 // UpstreamValidation defines how to verify the backend service's certificate
@@ -813,24 +1628,61 @@ type DownstreamValidation struct {
 	// presented to the external authorization server.
 	// +optional
 	SkipClientCertValidation bool `json:"skipClientCertValidation"`
+
+	// CertificateHeaders sets request headers to fields of the client
+	// certificate, so that upstream services can make authorization
+	// decisions based on them. The headers are removed from requests
+	// that don't present a certificate.
+	// +optional
+	CertificateHeaders []ClientCertificateHeader `json:"certificateHeaders,omitempty"`
+}
+
+// ClientCertificateHeader sets a request header to a field of the
+// client certificate.
+type ClientCertificateHeader struct {
+	// Name is the name of the request header.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Field is the field of the client certificate that the header is set to.
+	// SubjectAltName is the URI subject alternative names, separated by commas.
+	// Subject and Issuer are the distinguished names of the subject and issuer,
+	// in RFC 2253 format. Serial is the serial number, and SHA256 is the hex
+	// encoded SHA-256 fingerprint of the certificate.
+	// +kubebuilder:validation:Enum=SubjectAltName;Subject;Issuer;Serial;SHA256
+	Field string `json:"field"`
 }
 
 // HTTPProxyStatus reports the current state of the HTTPProxy.
 type HTTPProxyStatus struct {
+	// CurrentStatus summarizes the Valid condition as one of `valid`,
+	// `invalid`, `orphaned` or `partiallyvalid`.
+	//
+	// Deprecated: use Conditions instead.
 	// +optional
 	CurrentStatus string `json:"currentStatus,omitempty"`
+	// Description is the message of the Valid condition.
+	//
+	// Deprecated: use Conditions instead.
 	// +optional
 	Description string `json:"description,omitempty"`
 	// +optional
 	// LoadBalancer contains the current status of the load balancer.
 	LoadBalancer corev1.LoadBalancerStatus `json:"loadBalancer,omitempty"`
 	// +optional
+	// VirtualHost lists the external addresses at which the virtual host
+	// of a root HTTPProxy is served, so that DNS records can be published
+	// for it. It is unset for HTTPProxies that do not define a virtual host.
+	VirtualHost *VirtualHostStatus `json:"virtualhost,omitempty"`
+	// +optional
 	// Conditions contains information about the current status of the HTTPProxy,
 	// in an upstream-friendly container.
 	//
-	// Contour will update a single condition, `Valid`, that is in normal-true polarity.
+	// Contour will update the `Valid` condition, that is in normal-true polarity.
 	// That is, when `currentStatus` is `valid`, the `Valid` condition will be `status: true`,
-	// and vice versa.
+	// and vice versa. Contour will also update the `Orphaned` condition, which is true
+	// when the HTTPProxy is neither a root nor included by another HTTPProxy, and
+	// for an HTTPProxy that terminates TLS, the `TLSReady` condition.
 	//
 	// Contour will leave untouched any other Conditions set in this block,
 	// in case some other controller wants to add a Condition.
@@ -842,6 +1694,37 @@ type HTTPProxyStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []DetailedCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// +optional
+	// Includes reports, for each include of the HTTPProxy, whether it is followed.
+	Includes []IncludeStatus `json:"includes,omitempty"`
+}
+
+// VirtualHostStatus reports the external addresses serving a virtual host.
+type VirtualHostStatus struct {
+	// Fqdn is the fully qualified domain name of the virtual host.
+	Fqdn string `json:"fqdn"`
+	// Addresses lists the IP addresses and hostnames of the load balancer
+	// in front of Envoy, taken from the status of the Envoy Service or
+	// from the configured ingress status address.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// IncludeStatus reports the state of an include of an HTTPProxy.
+type IncludeStatus struct {
+	// Name of the included HTTPProxy.
+	Name string `json:"name"`
+	// Namespace of the included HTTPProxy.
+	Namespace string `json:"namespace"`
+	// Status is `True` when the include is followed and the included
+	// HTTPProxy is valid, and `False` otherwise.
+	Status ConditionStatus `json:"status"`
+	// Reason is a one-word CamelCase reason the include is not valid.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message explaining why the include is not valid.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +genclient
@@ -41,12 +41,12 @@ type CertificateDelegation struct {
 // to be presented to the user.
 type TLSCertificateDelegationStatus struct {
 	// +optional
-	// Conditions contains information about the current status of the HTTPProxy,
-	// in an upstream-friendly container.
+	// Conditions contains information about the current status of the
+	// TLSCertificateDelegation, in an upstream-friendly container.
 	//
 	// Contour will update a single condition, `Valid`, that is in normal-true polarity.
-	// That is, when `currentStatus` is `valid`, the `Valid` condition will be `status: true`,
-	// and vice versa.
+	// The `Valid` condition is `status: false` when a delegated secret is missing
+	// or is not a valid TLS secret.
 	//
 	// Contour will leave untouched any other Conditions set in this block,
 	// in case some other controller wants to add a Condition.
@@ -58,6 +58,33 @@ type TLSCertificateDelegationStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []DetailedCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// +optional
+	// Delegations reports, for each delegation of the spec, whether the
+	// delegated secret is in use and by which objects.
+	Delegations []CertificateDelegationStatus `json:"delegations,omitempty"`
+}
+
+// CertificateDelegationStatus reports the state of a CertificateDelegation.
+type CertificateDelegationStatus struct {
+	// SecretName is the name of the delegated secret.
+	SecretName string `json:"secretName"`
+	// InUse is true when at least one object references the secret
+	// through this delegation.
+	InUse bool `json:"inUse"`
+	// Consumers lists the objects that reference the secret through
+	// this delegation.
+	// +optional
+	Consumers []DelegationConsumer `json:"consumers,omitempty"`
+}
+
+// DelegationConsumer identifies an object that references a delegated secret.
+type DelegationConsumer struct {
+	// Kind of the object, `HTTPProxy` or `Ingress`.
+	Kind string `json:"kind"`
+	// Namespace of the object.
+	Namespace string `json:"namespace"`
+	// Name of the object.
+	Name string `json:"name"`
 }
 
 // +genclient
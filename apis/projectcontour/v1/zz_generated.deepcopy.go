@@ -24,6 +24,41 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLogPolicy) DeepCopyInto(out *AccessLogPolicy) {
+	*out = *in
+	if in.SamplingPercentage != nil {
+		in, out := &in.SamplingPercentage, &out.SamplingPercentage
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLogPolicy.
+func (in *AccessLogPolicy) DeepCopy() *AccessLogPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLogPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionControlPolicy) DeepCopyInto(out *AdmissionControlPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionControlPolicy.
+func (in *AdmissionControlPolicy) DeepCopy() *AdmissionControlPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionControlPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthorizationPolicy) DeepCopyInto(out *AuthorizationPolicy) {
 	*out = *in
@@ -67,6 +102,21 @@ func (in *AuthorizationServer) DeepCopy() *AuthorizationServer {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BufferPolicy) DeepCopyInto(out *BufferPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BufferPolicy.
+func (in *BufferPolicy) DeepCopy() *BufferPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BufferPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CORSPolicy) DeepCopyInto(out *CORSPolicy) {
 	*out = *in
@@ -102,6 +152,31 @@ func (in *CORSPolicy) DeepCopy() *CORSPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CachePolicy) DeepCopyInto(out *CachePolicy) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]CacheMethod, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeyHeaders != nil {
+		in, out := &in.KeyHeaders, &out.KeyHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CachePolicy.
+func (in *CachePolicy) DeepCopy() *CachePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CachePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertificateDelegation) DeepCopyInto(out *CertificateDelegation) {
 	*out = *in
@@ -122,6 +197,116 @@ func (in *CertificateDelegation) DeepCopy() *CertificateDelegation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateDelegationStatus) DeepCopyInto(out *CertificateDelegationStatus) {
+	*out = *in
+	if in.Consumers != nil {
+		in, out := &in.Consumers, &out.Consumers
+		*out = make([]DelegationConsumer, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateDelegationStatus.
+func (in *CertificateDelegationStatus) DeepCopy() *CertificateDelegationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateDelegationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientCertificateHeader) DeepCopyInto(out *ClientCertificateHeader) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientCertificateHeader.
+func (in *ClientCertificateHeader) DeepCopy() *ClientCertificateHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientCertificateHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CookieRewritePolicy) DeepCopyInto(out *CookieRewritePolicy) {
+	*out = *in
+	if in.Domain != nil {
+		in, out := &in.Domain, &out.Domain
+		*out = new(string)
+		**out = **in
+	}
+	if in.Secure != nil {
+		in, out := &in.Secure, &out.Secure
+		*out = new(bool)
+		**out = **in
+	}
+	if in.HTTPOnly != nil {
+		in, out := &in.HTTPOnly, &out.HTTPOnly
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CookieRewritePolicy.
+func (in *CookieRewritePolicy) DeepCopy() *CookieRewritePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CookieRewritePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomTag) DeepCopyInto(out *CustomTag) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomTag.
+func (in *CustomTag) DeepCopy() *CustomTag {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomTag)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DelegationConsumer) DeepCopyInto(out *DelegationConsumer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DelegationConsumer.
+func (in *DelegationConsumer) DeepCopy() *DelegationConsumer {
+	if in == nil {
+		return nil
+	}
+	out := new(DelegationConsumer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DetailedCondition) DeepCopyInto(out *DetailedCondition) {
 	*out = *in
@@ -151,6 +336,11 @@ func (in *DetailedCondition) DeepCopy() *DetailedCondition {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DownstreamValidation) DeepCopyInto(out *DownstreamValidation) {
 	*out = *in
+	if in.CertificateHeaders != nil {
+		in, out := &in.CertificateHeaders, &out.CertificateHeaders
+		*out = make([]ClientCertificateHeader, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DownstreamValidation.
@@ -178,6 +368,36 @@ func (in *ExtensionServiceReference) DeepCopy() *ExtensionServiceReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GRPCHealthCheckPolicy) DeepCopyInto(out *GRPCHealthCheckPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GRPCHealthCheckPolicy.
+func (in *GRPCHealthCheckPolicy) DeepCopy() *GRPCHealthCheckPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(GRPCHealthCheckPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GRPCRoute) DeepCopyInto(out *GRPCRoute) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GRPCRoute.
+func (in *GRPCRoute) DeepCopy() *GRPCRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(GRPCRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GenericKeyDescriptor) DeepCopyInto(out *GenericKeyDescriptor) {
 	*out = *in
@@ -193,6 +413,41 @@ func (in *GenericKeyDescriptor) DeepCopy() *GenericKeyDescriptor {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GeoMatchCondition) DeepCopyInto(out *GeoMatchCondition) {
+	*out = *in
+	if in.Countries != nil {
+		in, out := &in.Countries, &out.Countries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotCountries != nil {
+		in, out := &in.NotCountries, &out.NotCountries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ASNs != nil {
+		in, out := &in.ASNs, &out.ASNs
+		*out = make([]uint32, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotASNs != nil {
+		in, out := &in.NotASNs, &out.NotASNs
+		*out = make([]uint32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeoMatchCondition.
+func (in *GeoMatchCondition) DeepCopy() *GeoMatchCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(GeoMatchCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GlobalRateLimitPolicy) DeepCopyInto(out *GlobalRateLimitPolicy) {
 	*out = *in
@@ -215,9 +470,39 @@ func (in *GlobalRateLimitPolicy) DeepCopy() *GlobalRateLimitPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTP2Settings) DeepCopyInto(out *HTTP2Settings) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTP2Settings.
+func (in *HTTP2Settings) DeepCopy() *HTTP2Settings {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTP2Settings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HTTPHealthCheckPolicy) DeepCopyInto(out *HTTPHealthCheckPolicy) {
 	*out = *in
+	if in.GRPC != nil {
+		in, out := &in.GRPC, &out.GRPC
+		*out = new(GRPCHealthCheckPolicy)
+		**out = **in
+	}
+	if in.ExpectedStatuses != nil {
+		in, out := &in.ExpectedStatuses, &out.ExpectedStatuses
+		*out = make([]HTTPStatusRange, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequestHeaders != nil {
+		in, out := &in.RequestHeaders, &out.RequestHeaders
+		*out = make([]HeaderValue, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPHealthCheckPolicy.
@@ -332,6 +617,11 @@ func (in *HTTPProxySpec) DeepCopy() *HTTPProxySpec {
 func (in *HTTPProxyStatus) DeepCopyInto(out *HTTPProxyStatus) {
 	*out = *in
 	in.LoadBalancer.DeepCopyInto(&out.LoadBalancer)
+	if in.VirtualHost != nil {
+		in, out := &in.VirtualHost, &out.VirtualHost
+		*out = new(VirtualHostStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]DetailedCondition, len(*in))
@@ -339,6 +629,11 @@ func (in *HTTPProxyStatus) DeepCopyInto(out *HTTPProxyStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Includes != nil {
+		in, out := &in.Includes, &out.Includes
+		*out = make([]IncludeStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPProxyStatus.
@@ -351,6 +646,21 @@ func (in *HTTPProxyStatus) DeepCopy() *HTTPProxyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPStatusRange) DeepCopyInto(out *HTTPStatusRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPStatusRange.
+func (in *HTTPStatusRange) DeepCopy() *HTTPStatusRange {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPStatusRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HeaderHashOptions) DeepCopyInto(out *HeaderHashOptions) {
 	*out = *in
@@ -381,6 +691,21 @@ func (in *HeaderMatchCondition) DeepCopy() *HeaderMatchCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeaderNameMatch) DeepCopyInto(out *HeaderNameMatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeaderNameMatch.
+func (in *HeaderNameMatch) DeepCopy() *HeaderNameMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(HeaderNameMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HeaderValue) DeepCopyInto(out *HeaderValue) {
 	*out = *in
@@ -409,6 +734,11 @@ func (in *HeadersPolicy) DeepCopyInto(out *HeadersPolicy) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RemoveMatching != nil {
+		in, out := &in.RemoveMatching, &out.RemoveMatching
+		*out = make([]HeaderNameMatch, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeadersPolicy.
@@ -416,77 +746,250 @@ func (in *HeadersPolicy) DeepCopy() *HeadersPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(HeadersPolicy)
+	out := new(HeadersPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HedgePolicy) DeepCopyInto(out *HedgePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HedgePolicy.
+func (in *HedgePolicy) DeepCopy() *HedgePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HedgePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Include) DeepCopyInto(out *Include) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]MatchCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Include.
+func (in *Include) DeepCopy() *Include {
+	if in == nil {
+		return nil
+	}
+	out := new(Include)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IncludeStatus) DeepCopyInto(out *IncludeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IncludeStatus.
+func (in *IncludeStatus) DeepCopy() *IncludeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IncludeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerPolicy) DeepCopyInto(out *LoadBalancerPolicy) {
+	*out = *in
+	if in.RequestHashPolicies != nil {
+		in, out := &in.RequestHashPolicies, &out.RequestHashPolicies
+		*out = make([]RequestHashPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerPolicy.
+func (in *LoadBalancerPolicy) DeepCopy() *LoadBalancerPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalRateLimitPolicy) DeepCopyInto(out *LocalRateLimitPolicy) {
+	*out = *in
+	if in.ResponseHeadersToAdd != nil {
+		in, out := &in.ResponseHeadersToAdd, &out.ResponseHeadersToAdd
+		*out = make([]HeaderValue, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalRateLimitPolicy.
+func (in *LocalRateLimitPolicy) DeepCopy() *LocalRateLimitPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalRateLimitPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LuaPolicy) DeepCopyInto(out *LuaPolicy) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LuaPolicy.
+func (in *LuaPolicy) DeepCopy() *LuaPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LuaPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatchCondition) DeepCopyInto(out *MatchCondition) {
+	*out = *in
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = new(HeaderMatchCondition)
+		**out = **in
+	}
+	if in.Geo != nil {
+		in, out := &in.Geo, &out.Geo
+		*out = new(GeoMatchCondition)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchCondition.
+func (in *MatchCondition) DeepCopy() *MatchCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(MatchCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MirrorPolicy) DeepCopyInto(out *MirrorPolicy) {
+	*out = *in
+	if in.RequestHeaders != nil {
+		in, out := &in.RequestHeaders, &out.RequestHeaders
+		*out = make([]HeaderValue, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MirrorPolicy.
+func (in *MirrorPolicy) DeepCopy() *MirrorPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MirrorPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OverflowPolicy) DeepCopyInto(out *OverflowPolicy) {
+	*out = *in
+	in.Service.DeepCopyInto(&out.Service)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OverflowPolicy.
+func (in *OverflowPolicy) DeepCopy() *OverflowPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(OverflowPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Include) DeepCopyInto(out *Include) {
+func (in *PathRewritePolicy) DeepCopyInto(out *PathRewritePolicy) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]MatchCondition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.ReplacePrefix != nil {
+		in, out := &in.ReplacePrefix, &out.ReplacePrefix
+		*out = make([]ReplacePrefix, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Include.
-func (in *Include) DeepCopy() *Include {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PathRewritePolicy.
+func (in *PathRewritePolicy) DeepCopy() *PathRewritePolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(Include)
+	out := new(PathRewritePolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadBalancerPolicy) DeepCopyInto(out *LoadBalancerPolicy) {
+func (in *RBACPermission) DeepCopyInto(out *RBACPermission) {
 	*out = *in
-	if in.RequestHashPolicies != nil {
-		in, out := &in.RequestHashPolicies, &out.RequestHashPolicies
-		*out = make([]RequestHashPolicy, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerPolicy.
-func (in *LoadBalancerPolicy) DeepCopy() *LoadBalancerPolicy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RBACPermission.
+func (in *RBACPermission) DeepCopy() *RBACPermission {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadBalancerPolicy)
+	out := new(RBACPermission)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LocalRateLimitPolicy) DeepCopyInto(out *LocalRateLimitPolicy) {
+func (in *RBACPolicy) DeepCopyInto(out *RBACPolicy) {
 	*out = *in
-	if in.ResponseHeadersToAdd != nil {
-		in, out := &in.ResponseHeadersToAdd, &out.ResponseHeadersToAdd
-		*out = make([]HeaderValue, len(*in))
-		copy(*out, *in)
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]RBACRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalRateLimitPolicy.
-func (in *LocalRateLimitPolicy) DeepCopy() *LocalRateLimitPolicy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RBACPolicy.
+func (in *RBACPolicy) DeepCopy() *RBACPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(LocalRateLimitPolicy)
+	out := new(RBACPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MatchCondition) DeepCopyInto(out *MatchCondition) {
+func (in *RBACPrincipal) DeepCopyInto(out *RBACPrincipal) {
 	*out = *in
 	if in.Header != nil {
 		in, out := &in.Header, &out.Header
@@ -495,32 +998,41 @@ func (in *MatchCondition) DeepCopyInto(out *MatchCondition) {
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchCondition.
-func (in *MatchCondition) DeepCopy() *MatchCondition {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RBACPrincipal.
+func (in *RBACPrincipal) DeepCopy() *RBACPrincipal {
 	if in == nil {
 		return nil
 	}
-	out := new(MatchCondition)
+	out := new(RBACPrincipal)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PathRewritePolicy) DeepCopyInto(out *PathRewritePolicy) {
+func (in *RBACRule) DeepCopyInto(out *RBACRule) {
 	*out = *in
-	if in.ReplacePrefix != nil {
-		in, out := &in.ReplacePrefix, &out.ReplacePrefix
-		*out = make([]ReplacePrefix, len(*in))
-		copy(*out, *in)
+	if in.Principals != nil {
+		in, out := &in.Principals, &out.Principals
+		*out = make([]RBACPrincipal, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Permissions != nil {
+		in, out := &in.Permissions, &out.Permissions
+		*out = make([]RBACPermission, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PathRewritePolicy.
-func (in *PathRewritePolicy) DeepCopy() *PathRewritePolicy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RBACRule.
+func (in *RBACRule) DeepCopy() *RBACRule {
 	if in == nil {
 		return nil
 	}
-	out := new(PathRewritePolicy)
+	out := new(RBACRule)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -692,6 +1204,21 @@ func (in *RequestHeaderValueMatchDescriptor) DeepCopy() *RequestHeaderValueMatch
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestIDPolicy) DeepCopyInto(out *RequestIDPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestIDPolicy.
+func (in *RequestIDPolicy) DeepCopy() *RequestIDPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestIDPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
 	*out = *in
@@ -749,10 +1276,25 @@ func (in *Route) DeepCopyInto(out *Route) {
 		*out = new(RetryPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HedgePolicy != nil {
+		in, out := &in.HedgePolicy, &out.HedgePolicy
+		*out = new(HedgePolicy)
+		**out = **in
+	}
+	if in.AccessLogPolicy != nil {
+		in, out := &in.AccessLogPolicy, &out.AccessLogPolicy
+		*out = new(AccessLogPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TracingPolicy != nil {
+		in, out := &in.TracingPolicy, &out.TracingPolicy
+		*out = new(TracingPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.HealthCheckPolicy != nil {
 		in, out := &in.HealthCheckPolicy, &out.HealthCheckPolicy
 		*out = new(HTTPHealthCheckPolicy)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.LoadBalancerPolicy != nil {
 		in, out := &in.LoadBalancerPolicy, &out.LoadBalancerPolicy
@@ -774,11 +1316,53 @@ func (in *Route) DeepCopyInto(out *Route) {
 		*out = new(HeadersPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CookieRewritePolicies != nil {
+		in, out := &in.CookieRewritePolicies, &out.CookieRewritePolicies
+		*out = make([]CookieRewritePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Lua != nil {
+		in, out := &in.Lua, &out.Lua
+		*out = new(LuaPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CachePolicy != nil {
+		in, out := &in.CachePolicy, &out.CachePolicy
+		*out = new(CachePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BufferPolicy != nil {
+		in, out := &in.BufferPolicy, &out.BufferPolicy
+		*out = new(BufferPolicy)
+		**out = **in
+	}
+	if in.TapPolicy != nil {
+		in, out := &in.TapPolicy, &out.TapPolicy
+		*out = new(TapPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RBACPolicy != nil {
+		in, out := &in.RBACPolicy, &out.RBACPolicy
+		*out = new(RBACPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.RateLimitPolicy != nil {
 		in, out := &in.RateLimitPolicy, &out.RateLimitPolicy
 		*out = new(RateLimitPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.OverflowPolicy != nil {
+		in, out := &in.OverflowPolicy, &out.OverflowPolicy
+		*out = new(OverflowPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GRPC != nil {
+		in, out := &in.GRPC, &out.GRPC
+		*out = new(GRPCRoute)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
@@ -804,6 +1388,11 @@ func (in *Service) DeepCopyInto(out *Service) {
 		*out = new(UpstreamValidation)
 		**out = **in
 	}
+	if in.MirrorPolicy != nil {
+		in, out := &in.MirrorPolicy, &out.MirrorPolicy
+		*out = new(MirrorPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.RequestHeadersPolicy != nil {
 		in, out := &in.RequestHeadersPolicy, &out.RequestHeadersPolicy
 		*out = new(HeadersPolicy)
@@ -814,6 +1403,23 @@ func (in *Service) DeepCopyInto(out *Service) {
 		*out = new(HeadersPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]StaticEndpoint, len(*in))
+		copy(*out, *in)
+	}
+	if in.HTTP2 != nil {
+		in, out := &in.HTTP2, &out.HTTP2
+		*out = new(HTTP2Settings)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Service.
@@ -826,6 +1432,21 @@ func (in *Service) DeepCopy() *Service {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StaticEndpoint) DeepCopyInto(out *StaticEndpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticEndpoint.
+func (in *StaticEndpoint) DeepCopy() *StaticEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(StaticEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SubCondition) DeepCopyInto(out *SubCondition) {
 	*out = *in
@@ -844,6 +1465,11 @@ func (in *SubCondition) DeepCopy() *SubCondition {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TCPHealthCheckPolicy) DeepCopyInto(out *TCPHealthCheckPolicy) {
 	*out = *in
+	if in.Receive != nil {
+		in, out := &in.Receive, &out.Receive
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TCPHealthCheckPolicy.
@@ -884,6 +1510,11 @@ func (in *TCPProxy) DeepCopyInto(out *TCPProxy) {
 	if in.HealthCheckPolicy != nil {
 		in, out := &in.HealthCheckPolicy, &out.HealthCheckPolicy
 		*out = new(TCPHealthCheckPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TimeoutPolicy != nil {
+		in, out := &in.TimeoutPolicy, &out.TimeoutPolicy
+		*out = new(TCPProxyTimeoutPolicy)
 		**out = **in
 	}
 }
@@ -913,13 +1544,28 @@ func (in *TCPProxyInclude) DeepCopy() *TCPProxyInclude {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TCPProxyTimeoutPolicy) DeepCopyInto(out *TCPProxyTimeoutPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TCPProxyTimeoutPolicy.
+func (in *TCPProxyTimeoutPolicy) DeepCopy() *TCPProxyTimeoutPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TCPProxyTimeoutPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLS) DeepCopyInto(out *TLS) {
 	*out = *in
 	if in.ClientValidation != nil {
 		in, out := &in.ClientValidation, &out.ClientValidation
 		*out = new(DownstreamValidation)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -1024,6 +1670,13 @@ func (in *TLSCertificateDelegationStatus) DeepCopyInto(out *TLSCertificateDelega
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Delegations != nil {
+		in, out := &in.Delegations, &out.Delegations
+		*out = make([]CertificateDelegationStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSCertificateDelegationStatus.
@@ -1036,6 +1689,32 @@ func (in *TLSCertificateDelegationStatus) DeepCopy() *TLSCertificateDelegationSt
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TapPolicy) DeepCopyInto(out *TapPolicy) {
+	*out = *in
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]HeaderMatchCondition, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExtensionServiceRef != nil {
+		in, out := &in.ExtensionServiceRef, &out.ExtensionServiceRef
+		*out = new(ExtensionServiceReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TapPolicy.
+func (in *TapPolicy) DeepCopy() *TapPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TapPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TimeoutPolicy) DeepCopyInto(out *TimeoutPolicy) {
 	*out = *in
@@ -1051,6 +1730,31 @@ func (in *TimeoutPolicy) DeepCopy() *TimeoutPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TracingPolicy) DeepCopyInto(out *TracingPolicy) {
+	*out = *in
+	if in.SamplingPercentage != nil {
+		in, out := &in.SamplingPercentage, &out.SamplingPercentage
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.CustomTags != nil {
+		in, out := &in.CustomTags, &out.CustomTags
+		*out = make([]CustomTag, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TracingPolicy.
+func (in *TracingPolicy) DeepCopy() *TracingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TracingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpstreamValidation) DeepCopyInto(out *UpstreamValidation) {
 	*out = *in
@@ -1066,9 +1770,39 @@ func (in *UpstreamValidation) DeepCopy() *UpstreamValidation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserAgentPolicy) DeepCopyInto(out *UserAgentPolicy) {
+	*out = *in
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = new(HeaderValue)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserAgentPolicy.
+func (in *UserAgentPolicy) DeepCopy() *UserAgentPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(UserAgentPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualHost) DeepCopyInto(out *VirtualHost) {
 	*out = *in
+	if in.AdditionalFqdns != nil {
+		in, out := &in.AdditionalFqdns, &out.AdditionalFqdns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.TLS != nil {
 		in, out := &in.TLS, &out.TLS
 		*out = new(TLS)
@@ -1089,6 +1823,46 @@ func (in *VirtualHost) DeepCopyInto(out *VirtualHost) {
 		*out = new(RateLimitPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.XffPolicy != nil {
+		in, out := &in.XffPolicy, &out.XffPolicy
+		*out = new(XffPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AccessLogPolicy != nil {
+		in, out := &in.AccessLogPolicy, &out.AccessLogPolicy
+		*out = new(AccessLogPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequestIDPolicy != nil {
+		in, out := &in.RequestIDPolicy, &out.RequestIDPolicy
+		*out = new(RequestIDPolicy)
+		**out = **in
+	}
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Lua != nil {
+		in, out := &in.Lua, &out.Lua
+		*out = new(LuaPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WasmModules != nil {
+		in, out := &in.WasmModules, &out.WasmModules
+		*out = make([]WasmModuleReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdmissionControlPolicy != nil {
+		in, out := &in.AdmissionControlPolicy, &out.AdmissionControlPolicy
+		*out = new(AdmissionControlPolicy)
+		**out = **in
+	}
+	if in.UserAgentPolicy != nil {
+		in, out := &in.UserAgentPolicy, &out.UserAgentPolicy
+		*out = new(UserAgentPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualHost.
@@ -1100,3 +1874,58 @@ func (in *VirtualHost) DeepCopy() *VirtualHost {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualHostStatus) DeepCopyInto(out *VirtualHostStatus) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualHostStatus.
+func (in *VirtualHostStatus) DeepCopy() *VirtualHostStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualHostStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WasmModuleReference) DeepCopyInto(out *WasmModuleReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WasmModuleReference.
+func (in *WasmModuleReference) DeepCopy() *WasmModuleReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WasmModuleReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *XffPolicy) DeepCopyInto(out *XffPolicy) {
+	*out = *in
+	if in.NumTrustedHops != nil {
+		in, out := &in.NumTrustedHops, &out.NumTrustedHops
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new XffPolicy.
+func (in *XffPolicy) DeepCopy() *XffPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(XffPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
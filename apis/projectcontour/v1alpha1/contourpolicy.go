@@ -0,0 +1,113 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UpstreamProtocol is a protocol that routes may use to
+// connect to their services.
+// +kubebuilder:validation:Enum=http;h2;h2c;tls
+type UpstreamProtocol string
+
+const (
+	// UpstreamProtocolHTTP is plaintext HTTP/1.1, which is used
+	// when neither the route nor the service sets a protocol.
+	UpstreamProtocolHTTP UpstreamProtocol = "http"
+
+	// UpstreamProtocolH2 is HTTP/2 over TLS.
+	UpstreamProtocolH2 UpstreamProtocol = "h2"
+
+	// UpstreamProtocolH2C is plaintext HTTP/2.
+	UpstreamProtocolH2C UpstreamProtocol = "h2c"
+
+	// UpstreamProtocolTLS is HTTP/1.1 over TLS.
+	UpstreamProtocolTLS UpstreamProtocol = "tls"
+)
+
+// ContourPolicySpec defines the defaults and limits of the
+// HTTPProxies in the namespace of a ContourPolicy.
+type ContourPolicySpec struct {
+	// TimeoutPolicy is the timeout policy of the routes
+	// that do not set their own.
+	//
+	// +optional
+	TimeoutPolicy *contour_api_v1.TimeoutPolicy `json:"timeoutPolicy,omitempty"`
+
+	// RetryPolicy is the retry policy of the routes
+	// that do not set their own.
+	//
+	// +optional
+	RetryPolicy *contour_api_v1.RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// MaxResponseTimeout is the longest response timeout that
+	// routes may use. Routes with a longer or disabled response
+	// timeout are not valid. If not set, the response timeout
+	// is not limited.
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(((\d*(\.\d*)?h)|(\d*(\.\d*)?m)|(\d*(\.\d*)?s)|(\d*(\.\d*)?ms)|(\d*(\.\d*)?us)|(\d*(\.\d*)?µs)|(\d*(\.\d*)?ns))+)$`
+	MaxResponseTimeout string `json:"maxResponseTimeout,omitempty"`
+
+	// MaxRetries is the largest number of retries that routes
+	// may use. Routes with more retries are not valid. If not
+	// set, the number of retries is not limited.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxRetries uint32 `json:"maxRetries,omitempty"`
+
+	// AllowedProtocols are the protocols that routes may use to
+	// connect to their services. Routes whose services use any
+	// other protocol are not valid. If empty, every protocol is
+	// allowed.
+	//
+	// +optional
+	AllowedProtocols []UpstreamProtocol `json:"allowedProtocols,omitempty"`
+
+	// DisablePermitInsecure, if true, ignores the permitInsecure
+	// field of the routes in the namespace, so that their plaintext
+	// requests are redirected to HTTPS.
+	//
+	// +optional
+	DisablePermitInsecure bool `json:"disablePermitInsecure,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=contourpolicy;contourpolicies
+
+// ContourPolicy is the schema for the Contour namespace policy API.
+// A ContourPolicy sets the defaults and limits that the HTTPProxies
+// in its namespace are evaluated with, before their own route
+// settings are applied. A namespace should have at most one
+// ContourPolicy; if it has more, the first by name is used.
+type ContourPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ContourPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ContourPolicyList contains a list of ContourPolicy resources.
+type ContourPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ContourPolicy `json:"items"`
+}
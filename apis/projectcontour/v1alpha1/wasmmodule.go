@@ -0,0 +1,107 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WasmFailurePolicy defines what happens to requests when a Wasm
+// module fails.
+// +kubebuilder:validation:Enum=Fail;Ignore
+type WasmFailurePolicy string
+
+const (
+	// WasmFailurePolicyFail fails requests with a 503 response
+	// when the module can't be loaded or fails.
+	WasmFailurePolicyFail WasmFailurePolicy = "Fail"
+
+	// WasmFailurePolicyIgnore passes requests on without
+	// running the module when it can't be loaded or fails.
+	WasmFailurePolicyIgnore WasmFailurePolicy = "Ignore"
+)
+
+// WasmModuleSpec defines the source and configuration of a Wasm module.
+type WasmModuleSpec struct {
+	// Image is the reference of the OCI image of the module, such as
+	// ghcr.io/example/filter:v1 or ghcr.io/example/filter@sha256:...,
+	// which Contour pulls without credentials. The image is either a
+	// Wasm artifact with a single Wasm layer, or a container image
+	// with a single layer that holds the module as plugin.wasm.
+	// Exactly one of Image or ConfigMapRef must be set.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image,omitempty"`
+
+	// ConfigMapRef refers to the key of a ConfigMap, in the namespace
+	// of the WasmModule, whose binary data is the module. Exactly one
+	// of Image or ConfigMapRef must be set.
+	//
+	// +optional
+	ConfigMapRef *contour_api_v1.ConfigMapKeyReference `json:"configMapRef,omitempty"`
+
+	// SHA256 is the hex encoded SHA-256 digest of the module. If set,
+	// a module with a different digest is not loaded.
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-f0-9]{64}$`
+	SHA256 string `json:"sha256,omitempty"`
+
+	// RootID is the root ID of the module, which selects the root
+	// context of modules that implement more than one filter.
+	//
+	// +optional
+	RootID string `json:"rootID,omitempty"`
+
+	// Configuration is passed as is to the module when it starts,
+	// for example as a JSON document.
+	//
+	// +optional
+	Configuration string `json:"configuration,omitempty"`
+
+	// FailurePolicy defines what happens to requests when the module
+	// can't be loaded or fails, either Fail or Ignore. If not
+	// specified, requests fail.
+	//
+	// +optional
+	FailurePolicy WasmFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=wasmmodule;wasmmodules
+
+// WasmModule is the schema for the Contour Wasm module API.
+// A WasmModule defines a Wasm module that Envoy runs as an HTTP
+// filter on the requests to the virtual hosts that refer to it,
+// so that custom filters can be rolled out without rebuilding
+// Envoy.
+type WasmModule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WasmModuleSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// WasmModuleList contains a list of WasmModule resources.
+type WasmModuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WasmModule `json:"items"`
+}
@@ -28,13 +28,26 @@ func registerBootstrap(app *kingpin.Application) (*kingpin.CmdClause, *envoy.Boo
 	bootstrap.Flag("resources-dir", "Directory where configuration files will be written to.").StringVar(&config.ResourcesDir)
 	bootstrap.Flag("admin-address", "Envoy admin interface address.").StringVar(&config.AdminAddress)
 	bootstrap.Flag("admin-port", "Envoy admin interface port.").IntVar(&config.AdminPort)
+	bootstrap.Flag("admin-socket", "Unix domain socket of the Envoy admin interface, used instead of the admin address and port.").StringVar(&config.AdminSocket)
+	bootstrap.Flag("overload-max-heap", "Heap size in bytes at which Envoy shrinks its heap and then stops accepting requests. If 0, the heap size is not limited.").Uint64Var(&config.OverloadMaxHeapSizeBytes)
+	bootstrap.Flag("overload-max-downstream-connections", "Maximum number of downstream connections Envoy accepts across all listeners. If 0, the number of connections is not limited.").Uint64Var(&config.OverloadMaxDownstreamConnections)
 	bootstrap.Flag("xds-address", "xDS gRPC API address.").StringVar(&config.XDSAddress)
 	bootstrap.Flag("xds-port", "xDS gRPC API port.").IntVar(&config.XDSGRPCPort)
 	bootstrap.Flag("envoy-cafile", "CA Filename for Envoy secure xDS gRPC communication.").Envar("ENVOY_CAFILE").StringVar(&config.GrpcCABundle)
 	bootstrap.Flag("envoy-cert-file", "Client certificate filename for Envoy secure xDS gRPC communication.").Envar("ENVOY_CERT_FILE").StringVar(&config.GrpcClientCert)
 	bootstrap.Flag("envoy-key-file", "Client key filename for Envoy secure xDS gRPC communication.").Envar("ENVOY_KEY_FILE").StringVar(&config.GrpcClientKey)
 	bootstrap.Flag("namespace", "The namespace the Envoy container will run in.").Envar("CONTOUR_NAMESPACE").Default("projectcontour").StringVar(&config.Namespace)
+	bootstrap.Flag("xds-socket", "Unix domain socket of the xDS gRPC API, used instead of the xDS address and port.").StringVar(&config.XDSSocket)
+	bootstrap.Flag("xds-delta", "Request listeners and clusters with the incremental (delta) xDS protocol.").BoolVar(&config.XDSDelta)
+	bootstrap.Flag("runtime-discovery", "Fetch a runtime layer from Contour with the runtime discovery service (RTDS).").BoolVar(&config.RuntimeDiscovery)
 	bootstrap.Flag("xds-resource-version", "The versions of the xDS resources to request from Contour.").Default("v3").StringVar((*string)(&config.XDSResourceVersion))
 	bootstrap.Flag("dns-lookup-family", "Defines what DNS Resolution Policy to use for Envoy -> Contour cluster name lookup. Either v4, v6 or auto.").StringVar(&config.DNSLookupFamily)
+	bootstrap.Flag("spiffe-workload-api-socket", "Unix domain socket of the SPIFFE Workload API that serves upstream TLS identities to Envoy.").StringVar(&config.SPIFFEWorkloadAPISocket)
+	bootstrap.Flag("stats-sink", "Stats sink that Envoy pushes metrics to. Either statsd or dogstatsd.").EnumVar(&config.StatsSink, "statsd", "dogstatsd")
+	bootstrap.Flag("stats-sink-address", "IP address of the stats sink.").Envar("STATS_SINK_ADDRESS").StringVar(&config.StatsSinkAddress)
+	bootstrap.Flag("stats-sink-port", "UDP port of the stats sink.").IntVar(&config.StatsSinkPort)
+	bootstrap.Flag("stats-sink-prefix", "Prefix of the metric names sent to the stats sink.").StringVar(&config.StatsSinkPrefix)
+	bootstrap.Flag("stats-tag", "Tag to extract from Envoy metric names, as name=regex. May be repeated.").StringMapVar(&config.StatsTags)
+	bootstrap.Flag("stats-fixed-tag", "Tag to add to every Envoy metric, as name=value. May be repeated.").StringMapVar(&config.StatsFixedTags)
 	return bootstrap, &config
 }
@@ -15,9 +15,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/projectcontour/contour/internal/certgen"
 	"github.com/projectcontour/contour/internal/k8s"
@@ -44,12 +46,36 @@ func registerCertGen(app *kingpin.Application) (*kingpin.CmdClause, *certgenConf
 	certgenApp.Flag("certificate-lifetime", "Generated certificate lifetime (in days).").Default(strconv.Itoa(certs.DefaultCertificateLifetime)).UintVar(&certgenConfig.Lifetime)
 	certgenApp.Flag("overwrite", "Overwrite existing files or Secrets.").BoolVar(&certgenConfig.Overwrite)
 	certgenApp.Flag("secrets-format", "Specify how to format the generated Kubernetes Secrets.").Default("legacy").StringVar(&certgenConfig.Format)
+	certgenApp.Flag("key-type", "Type of the generated private keys.").Default(string(certs.RSAKeyType)).EnumVar(&certgenConfig.KeyType, string(certs.RSAKeyType), string(certs.ECDSAKeyType))
+	certgenApp.Flag("key-size", "Size of RSA keys in bits, or ECDSA curve size (256, 384 or 521). Defaults to 2048 for RSA and 256 for ECDSA keys.").IntVar(&certgenConfig.KeySize)
+	certgenApp.Flag("dns-name", "Kubernetes cluster DNS domain, used in the certificates' Subject Alt Names.").Default(certs.DefaultDNSName).StringVar(&certgenConfig.DNSName)
+	certgenApp.Flag("contour-san", "Additional Subject Alt Name of the Contour certificate (repeatable).").StringsVar(&certgenConfig.ContourSANs)
+	certgenApp.Flag("envoy-san", "Additional Subject Alt Name of the Envoy certificate (repeatable).").StringsVar(&certgenConfig.EnvoySANs)
+	certgenApp.Flag("renew", "Only generate new certs if the existing ones are missing or expire within --renew-before days.").BoolVar(&certgenConfig.Renew)
+	certgenApp.Flag("renew-before", "Number of days before expiry to renew certs in --renew mode.").Default("30").UintVar(&certgenConfig.RenewBefore)
+	certgenApp.Flag("signer", "How certs are signed: by a self-signed CA, by the Kubernetes certificates API, or by an external CA from CSR files written with --pem.").Default(signerSelf).EnumVar(&certgenConfig.Signer, signerSelf, signerKubernetes, signerExternal)
+	certgenApp.Flag("signer-name", "Kubernetes signer name to request certs from with --signer=kubernetes.").StringVar(&certgenConfig.SignerName)
+	certgenApp.Flag("ca-file", "CA bundle of the Kubernetes signer, required with --signer=kubernetes.").ExistingFileVar(&certgenConfig.CAFile)
+	certgenApp.Flag("approve", "Approve the CertificateSigningRequests with --signer=kubernetes.").BoolVar(&certgenConfig.Approve)
+	certgenApp.Flag("signing-timeout", "How long to wait for each CertificateSigningRequest to be signed with --signer=kubernetes.").Default("5m").DurationVar(&certgenConfig.SigningTimeout)
 
 	certgenApp.Arg("outputdir", "Directory to write output files into (default \"certs\").").Default("certs").StringVar(&certgenConfig.OutputDir)
 
 	return certgenApp, &certgenConfig
 }
 
+const (
+	// signerSelf signs certs with a newly generated CA.
+	signerSelf = "self"
+
+	// signerKubernetes signs certs with the Kubernetes certificates API.
+	signerKubernetes = "kubernetes"
+
+	// signerExternal writes certificate signing requests for an
+	// external CA to sign.
+	signerExternal = "external"
+)
+
 // certgenConfig holds the configuration for the certificate generation process.
 type certgenConfig struct {
 
@@ -82,6 +108,47 @@ type certgenConfig struct {
 
 	// Format specifies how to format the Kubernetes Secrets (must be "legacy" or "compat").
 	Format string
+
+	// KeyType is the type of the private keys, "rsa" or "ecdsa".
+	KeyType string
+
+	// KeySize is the size of RSA keys in bits, or the size of the
+	// curve of ECDSA keys. Zero selects the default for KeyType.
+	KeySize int
+
+	// DNSName is the Kubernetes cluster DNS domain.
+	DNSName string
+
+	// ContourSANs and EnvoySANs are the additional Subject Alt
+	// Names of the Contour and Envoy certificates.
+	ContourSANs []string
+	EnvoySANs   []string
+
+	// Renew means that certs are only generated if the existing ones
+	// are missing or expire within RenewBefore days, in which case
+	// they are overwritten.
+	Renew bool
+
+	// RenewBefore is the number of days before expiry that certs are
+	// renewed in Renew mode.
+	RenewBefore uint
+
+	// Signer is how the certs are signed: "self", "kubernetes"
+	// or "external".
+	Signer string
+
+	// SignerName is the Kubernetes signer that signs the certs,
+	// and CAFile the path of its CA bundle.
+	SignerName string
+	CAFile     string
+
+	// Approve means that certgen approves its own
+	// Kubernetes CertificateSigningRequests.
+	Approve bool
+
+	// SigningTimeout is how long to wait for a Kubernetes
+	// CertificateSigningRequest to be signed.
+	SigningTimeout time.Duration
 }
 
 // OutputCerts outputs the certs in certs as directed by config.
@@ -126,21 +193,128 @@ func OutputCerts(config *certgenConfig, kubeclient *kubernetes.Clientset, certs
 	return nil
 }
 
-func doCertgen(config *certgenConfig, log logrus.FieldLogger) {
-	generatedCerts, err := certs.GenerateCerts(
-		&certs.Configuration{
-			Lifetime:  config.Lifetime,
-			Namespace: config.Namespace,
-		})
+// renewalRequired returns true, and the reason, if the certs that
+// were output as directed by config need to be renewed.
+func renewalRequired(config *certgenConfig, kubeclient *kubernetes.Clientset, now time.Time) (bool, string, error) {
+	var existing *certs.Certificates
+	var err error
+
+	switch {
+	case config.OutputKube:
+		existing, err = certgen.ReadSecretsKube(kubeclient, config.Namespace)
+	case config.OutputPEM:
+		existing, err = certgen.ReadCertsPEM(config.OutputDir)
+	default:
+		return false, "", fmt.Errorf("--renew requires --kube or --pem")
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("failed to read existing certificates: %w", err)
+	}
+
+	renew, reason := certgen.RenewalRequired(existing, now.Add(24*time.Hour*time.Duration(config.RenewBefore)))
+	return renew, reason, nil
+}
+
+// outputCertificateRequests writes the certificate signing requests
+// and private keys for an external CA to sign to the output directory.
+func outputCertificateRequests(config *certgenConfig, certsConfig *certs.Configuration) error {
+	if !config.OutputPEM || config.OutputYAML || config.OutputKube {
+		return fmt.Errorf("--signer=%s requires --pem, and can not be used with --yaml or --kube", signerExternal)
+	}
+
+	requests, err := certs.GenerateCertificateRequests(certsConfig)
 	if err != nil {
-		log.WithError(err).Fatal("failed to generate certificates")
+		return err
+	}
+
+	force := certgen.NoOverwrite
+	if config.Overwrite {
+		force = certgen.Overwrite
 	}
 
+	fmt.Printf("Writing certificate signing requests to PEM files in %s/\n", config.OutputDir)
+	if err := certgen.WriteCertificateRequestsPEM(config.OutputDir, requests, force); err != nil {
+		return fmt.Errorf("failed to write certificate signing requests to %q: %w", config.OutputDir, err)
+	}
+	return nil
+}
+
+// signCertsKube generates certs signed by the Kubernetes
+// certificates API signer in config.
+func signCertsKube(config *certgenConfig, kubeclient *kubernetes.Clientset, certsConfig *certs.Configuration) (*certs.Certificates, error) {
+	if config.SignerName == "" || config.CAFile == "" {
+		return nil, fmt.Errorf("--signer=%s requires --signer-name and --ca-file", signerKubernetes)
+	}
+
+	caCert, err := ioutil.ReadFile(config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := certs.GenerateCertificateRequests(certsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &certgen.KubeSigner{
+		Client:     kubeclient,
+		SignerName: config.SignerName,
+		Approve:    config.Approve,
+		Timeout:    config.SigningTimeout,
+	}
+	return certgen.SignCertificateRequests(signer, config.Namespace, caCert, requests)
+}
+
+func doCertgen(config *certgenConfig, log logrus.FieldLogger) {
 	clients, err := k8s.NewClients(config.KubeConfig, config.InCluster)
 	if err != nil {
 		log.WithError(err).Fatalf("failed to create Kubernetes client")
 	}
 
+	if config.Renew {
+		renew, reason, err := renewalRequired(config, clients.ClientSet(), time.Now())
+		if err != nil {
+			log.WithError(err).Fatal("failed to check certificates for renewal")
+		}
+		if !renew {
+			log.Info("certificates do not need to be renewed")
+			return
+		}
+		log.WithField("reason", reason).Info("renewing certificates")
+
+		// The certificates are replaced together.
+		config.Overwrite = true
+	}
+
+	certsConfig := &certs.Configuration{
+		Lifetime:    config.Lifetime,
+		Namespace:   config.Namespace,
+		DNSName:     config.DNSName,
+		KeyType:     certs.KeyType(config.KeyType),
+		KeySize:     config.KeySize,
+		ContourSANs: config.ContourSANs,
+		EnvoySANs:   config.EnvoySANs,
+	}
+
+	var generatedCerts *certs.Certificates
+	switch config.Signer {
+	case signerExternal:
+		if err := outputCertificateRequests(config, certsConfig); err != nil {
+			log.WithError(err).Fatal("failed to output certificate signing requests")
+		}
+		return
+	case signerKubernetes:
+		generatedCerts, err = signCertsKube(config, clients.ClientSet(), certsConfig)
+		if err != nil {
+			log.WithError(err).Fatal("failed to sign certificates")
+		}
+	default:
+		generatedCerts, err = certs.GenerateCerts(certsConfig)
+		if err != nil {
+			log.WithError(err).Fatal("failed to generate certificates")
+		}
+	}
+
 	if oerr := OutputCerts(config, clients.ClientSet(), generatedCerts); oerr != nil {
 		log.WithError(oerr).Fatalf("failed output certificates")
 	}
@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/projectcontour/contour/internal/certgen"
 	"github.com/projectcontour/contour/internal/dag"
@@ -176,3 +177,65 @@ func TestOutputFileMode(t *testing.T) {
 		})
 	}
 }
+
+func TestRenewalRequired(t *testing.T) {
+	outputDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outputDir)
+
+	cc := &certgenConfig{
+		OutputDir:   outputDir,
+		OutputPEM:   true,
+		RenewBefore: 30,
+	}
+
+	renew, reason, err := renewalRequired(cc, nil, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, renew)
+	assert.Equal(t, "CA certificate does not exist", reason)
+
+	generatedCerts, err := certs.GenerateCerts(
+		&certs.Configuration{
+			Lifetime: 90,
+			KeyType:  certs.ECDSAKeyType,
+		})
+	assert.NoError(t, err)
+	assert.NoError(t, OutputCerts(cc, nil, generatedCerts))
+
+	renew, _, err = renewalRequired(cc, nil, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, renew)
+
+	// Certificates are renewed once they expire within 30 days.
+	renew, reason, err = renewalRequired(cc, nil, time.Now().Add(61*24*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, renew)
+	assert.Contains(t, reason, "CA certificate expires at ")
+
+	_, _, err = renewalRequired(&certgenConfig{OutputYAML: true}, nil, time.Now())
+	assert.Error(t, err)
+}
+
+func TestOutputCertificateRequests(t *testing.T) {
+	outputDir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(outputDir)
+
+	cc := &certgenConfig{
+		OutputDir: outputDir,
+		OutputPEM: true,
+	}
+	assert.NoError(t, outputCertificateRequests(cc, &certs.Configuration{}))
+
+	for _, name := range []string{"contourcert.csr", "contourkey.pem", "envoycert.csr", "envoykey.pem"} {
+		info, err := os.Stat(filepath.Join(outputDir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode(), "incorrect mode for file "+name)
+	}
+
+	// The requests are not overwritten unless asked to.
+	assert.Error(t, outputCertificateRequests(cc, &certs.Configuration{}))
+
+	cc.OutputKube = true
+	assert.Error(t, outputCertificateRequests(cc, &certs.Configuration{}))
+}
@@ -17,17 +17,23 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"sort"
 
 	envoy_service_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	envoy_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	envoy_service_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
 	envoy_service_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
 	envoy_service_route_v3 "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	envoy_cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/testing/protocmp"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -37,6 +43,10 @@ type Client struct {
 	CAFile      string
 	ClientCert  string
 	ClientKey   string
+
+	// Watch, if true, prints the changes between successive
+	// responses rather than each full response.
+	Watch bool
 }
 
 func (c *Client) dial() *grpc.ClientConn {
@@ -117,11 +127,12 @@ type stream interface {
 	Recv() (*envoy_discovery_v3.DiscoveryResponse, error)
 }
 
-func watchstream(st stream, typeURL string, resources []string) {
+func watchstream(st stream, typeURL string, resources []string, watch bool) {
 	m := proto.TextMarshaler{
 		Compact:   false,
 		ExpandAny: true,
 	}
+	diff := newResourceDiff(resources)
 	for {
 		req := &envoy_discovery_v3.DiscoveryRequest{
 			TypeUrl:       typeURL,
@@ -131,7 +142,122 @@ func watchstream(st stream, typeURL string, resources []string) {
 		kingpin.FatalIfError(err, "failed to send Discover Request")
 		resp, err := st.Recv()
 		kingpin.FatalIfError(err, "failed to receive response for Discover Request")
-		err = m.Marshal(os.Stdout, resp)
+		if watch {
+			err = diff.write(os.Stdout, resp)
+			kingpin.FatalIfError(err, "failed to write changes to Discovery Response")
+			continue
+		}
+		err = m.Marshal(os.Stdout, diff.filter(resp))
 		kingpin.FatalIfError(err, "failed to marshal Discovery Response")
 	}
 }
+
+// resourceDiff holds the resources of the last response received on
+// a stream, so that the changes in the next response can be printed.
+type resourceDiff struct {
+	// names holds the names of the resources to print.
+	// If empty, every resource is printed.
+	names map[string]bool
+
+	resources map[string]proto.Message
+}
+
+func newResourceDiff(names []string) *resourceDiff {
+	d := &resourceDiff{
+		names:     map[string]bool{},
+		resources: map[string]proto.Message{},
+	}
+	for _, name := range names {
+		d.names[name] = true
+	}
+	return d
+}
+
+// filter returns resp without the resources that are not selected by
+// name. Contour sends every resource to streams that do not subscribe
+// to names, and to CDS and LDS streams.
+func (d *resourceDiff) filter(resp *envoy_discovery_v3.DiscoveryResponse) *envoy_discovery_v3.DiscoveryResponse {
+	if len(d.names) == 0 {
+		return resp
+	}
+
+	filtered := proto.Clone(resp).(*envoy_discovery_v3.DiscoveryResponse)
+	filtered.Resources = nil
+	for _, a := range resp.Resources {
+		res, err := a.UnmarshalNew()
+		if err != nil || d.names[envoy_cache_v3.GetResourceName(proto.MessageV1(res))] {
+			filtered.Resources = append(filtered.Resources, a)
+		}
+	}
+	return filtered
+}
+
+// write writes the resources of resp that were added, removed or
+// changed since the last response, with the differences of those that
+// changed, and remembers the resources of resp.
+func (d *resourceDiff) write(w io.Writer, resp *envoy_discovery_v3.DiscoveryResponse) error {
+	current := map[string]proto.Message{}
+	for _, a := range resp.Resources {
+		res, err := a.UnmarshalNew()
+		if err != nil {
+			return err
+		}
+		msg := proto.MessageV1(res)
+		name := envoy_cache_v3.GetResourceName(msg)
+		if len(d.names) > 0 && !d.names[name] {
+			continue
+		}
+		current[name] = msg
+	}
+
+	var names []string
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range d.resources {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	m := proto.TextMarshaler{
+		Compact:   false,
+		ExpandAny: true,
+	}
+
+	if len(names) > 0 {
+		if _, err := fmt.Fprintf(w, "# version %s\n", resp.VersionInfo); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		old, ok := d.resources[name]
+		res, found := current[name]
+		switch {
+		case !ok:
+			if _, err := fmt.Fprintf(w, "+ %s\n", name); err != nil {
+				return err
+			}
+			if err := m.Marshal(w, res); err != nil {
+				return err
+			}
+		case !found:
+			if _, err := fmt.Fprintf(w, "- %s\n", name); err != nil {
+				return err
+			}
+		default:
+			diff := cmp.Diff(old, res, protocmp.Transform())
+			if diff == "" {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "~ %s\n%s", name, diff); err != nil {
+				return err
+			}
+		}
+	}
+
+	d.resources = current
+	return nil
+}
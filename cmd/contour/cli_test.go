@@ -0,0 +1,77 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceDiff(t *testing.T) {
+	response := func(version string, clusters ...*envoy_cluster_v3.Cluster) *envoy_discovery_v3.DiscoveryResponse {
+		resp := &envoy_discovery_v3.DiscoveryResponse{VersionInfo: version}
+		for _, c := range clusters {
+			resp.Resources = append(resp.Resources, protobuf.MustMarshalAny(c))
+		}
+		return resp
+	}
+
+	kuard := &envoy_cluster_v3.Cluster{Name: "default/kuard/80", AltStatName: "default_kuard_80"}
+	echo := &envoy_cluster_v3.Cluster{Name: "default/echo/80"}
+
+	d := newResourceDiff(nil)
+
+	var out bytes.Buffer
+	require.NoError(t, d.write(&out, response("1", kuard, echo)))
+	assert.Contains(t, out.String(), "# version 1\n")
+	assert.Contains(t, out.String(), "+ default/echo/80\n")
+	assert.Contains(t, out.String(), "+ default/kuard/80\n")
+
+	out.Reset()
+	changed := &envoy_cluster_v3.Cluster{Name: "default/kuard/80", AltStatName: "default_kuard_8080"}
+	require.NoError(t, d.write(&out, response("2", changed)))
+	assert.Contains(t, out.String(), "~ default/kuard/80\n")
+	assert.Contains(t, out.String(), "default_kuard_8080")
+	assert.Contains(t, out.String(), "- default/echo/80\n")
+
+	// Responses that change nothing print nothing.
+	out.Reset()
+	require.NoError(t, d.write(&out, response("3", changed)))
+	assert.Empty(t, out.String())
+}
+
+func TestResourceDiffFilter(t *testing.T) {
+	resp := &envoy_discovery_v3.DiscoveryResponse{
+		Resources: []*any.Any{
+			protobuf.MustMarshalAny(&envoy_cluster_v3.Cluster{Name: "default/kuard/80"}),
+			protobuf.MustMarshalAny(&envoy_cluster_v3.Cluster{Name: "default/echo/80"}),
+		},
+	}
+
+	d := newResourceDiff([]string{"default/echo/80"})
+	assert.Len(t, d.filter(resp).Resources, 1)
+	assert.Len(t, resp.Resources, 2)
+
+	var out bytes.Buffer
+	require.NoError(t, d.write(&out, resp))
+	assert.NotContains(t, out.String(), "kuard")
+	assert.Contains(t, out.String(), "+ default/echo/80\n")
+}
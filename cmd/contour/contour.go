@@ -41,12 +41,19 @@ func main() {
 
 	certgenApp, certgenConfig := registerCertGen(app)
 
+	convert, convertCtx := registerConvert(app)
+
+	lint, lintCtx := registerLint(app)
+
+	dagCmd, dagCtx := registerDAG(app)
+
 	cli := app.Command("cli", "A CLI client for the Contour Kubernetes ingress controller.")
 	var client Client
 	cli.Flag("contour", "Contour host:port.").Default("127.0.0.1:8001").StringVar(&client.ContourAddr)
 	cli.Flag("cafile", "CA bundle file for connecting to a TLS-secured Contour.").Envar("CLI_CAFILE").StringVar(&client.CAFile)
 	cli.Flag("cert-file", "Client certificate file for connecting to a TLS-secured Contour.").Envar("CLI_CERT_FILE").StringVar(&client.ClientCert)
 	cli.Flag("key-file", "Client key file for connecting to a TLS-secured Contour.").Envar("CLI_KEY_FILE").StringVar(&client.ClientKey)
+	cli.Flag("watch", "Print the resources added, removed and changed by each response instead of the full response.").BoolVar(&client.Watch)
 
 	var resources []string
 	cds := cli.Command("cds", "Watch services.")
@@ -60,6 +67,8 @@ func main() {
 	sds := cli.Command("sds", "Watch secrets.")
 	sds.Arg("resources", "SDS resource filter").StringsVar(&resources)
 
+	troubleshoot, troubleshootCtx := registerTroubleshoot(cli)
+
 	serve, serveCtx := registerServe(app)
 	version := app.Command("version", "Build information for Contour.")
 
@@ -79,21 +88,37 @@ func main() {
 		}
 	case certgenApp.FullCommand():
 		doCertgen(certgenConfig, log)
+	case convert.FullCommand():
+		if err := doConvert(convertCtx, os.Stdin, os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to convert Ingress resources")
+		}
+	case lint.FullCommand():
+		if err := doLint(lintCtx, os.Stdin, os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to validate HTTPProxy resources")
+		}
+	case dagCmd.FullCommand():
+		if err := doDAG(dagCtx, os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to print DAG")
+		}
 	case cds.FullCommand():
 		stream := client.ClusterStream()
-		watchstream(stream, resource_v3.ClusterType, resources)
+		watchstream(stream, resource_v3.ClusterType, resources, client.Watch)
 	case eds.FullCommand():
 		stream := client.EndpointStream()
-		watchstream(stream, resource_v3.EndpointType, resources)
+		watchstream(stream, resource_v3.EndpointType, resources, client.Watch)
 	case lds.FullCommand():
 		stream := client.ListenerStream()
-		watchstream(stream, resource_v3.ListenerType, resources)
+		watchstream(stream, resource_v3.ListenerType, resources, client.Watch)
 	case rds.FullCommand():
 		stream := client.RouteStream()
-		watchstream(stream, resource_v3.RouteType, resources)
+		watchstream(stream, resource_v3.RouteType, resources, client.Watch)
+	case troubleshoot.FullCommand():
+		if err := doTroubleshoot(troubleshootCtx, &client, os.Stdout); err != nil {
+			log.WithError(err).Fatal("failed to troubleshoot virtual host")
+		}
 	case sds.FullCommand():
 		stream := client.RouteStream()
-		watchstream(stream, resource_v3.SecretType, resources)
+		watchstream(stream, resource_v3.SecretType, resources, client.Watch)
 	case serve.FullCommand():
 		// Parse args a second time so cli flags are applied
 		// on top of any values sourced from -c's config file.
@@ -0,0 +1,149 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/projectcontour/contour/internal/ingressconvert"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	networking_v1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// registerConvert registers the convert subcommand and flags
+// with the Application provided.
+func registerConvert(app *kingpin.Application) (*kingpin.CmdClause, *convertContext) {
+	var ctx convertContext
+	convert := app.Command("convert", "Convert Ingress resources to equivalent HTTPProxy resources.")
+	convert.Arg("files", "YAML or JSON files containing Ingress resources (default stdin).").ExistingFilesVar(&ctx.Files)
+
+	return convert, &ctx
+}
+
+// convertContext holds the configuration for the convert subcommand.
+type convertContext struct {
+	// Files are the files to read Ingress resources from.
+	// If empty, resources are read from stdin.
+	Files []string
+}
+
+// doConvert reads Ingress resources from the configured files and
+// writes the equivalent HTTPProxy resources to out. Any part of an
+// Ingress that could not be converted exactly is written to out as
+// a YAML comment.
+func doConvert(ctx *convertContext, in io.Reader, out io.Writer) error {
+	var ingresses []*networking_v1.Ingress
+
+	if len(ctx.Files) == 0 {
+		ings, err := readIngresses(in)
+		if err != nil {
+			return err
+		}
+		ingresses = ings
+	}
+
+	for _, file := range ctx.Files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		ings, err := readIngresses(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", file, err)
+		}
+		ingresses = append(ingresses, ings...)
+	}
+
+	for _, ing := range ingresses {
+		proxies, warnings := ingressconvert.IngressToHTTPProxies(ing)
+
+		for _, w := range warnings {
+			if _, err := fmt.Fprintf(out, "# WARNING: %s\n", w); err != nil {
+				return err
+			}
+		}
+
+		for _, proxy := range proxies {
+			obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(proxy)
+			if err != nil {
+				return err
+			}
+
+			// Drop the fields that are only set by the API server.
+			unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+			unstructured.RemoveNestedField(obj, "status")
+
+			data, err := yaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(out, "---\n%s", data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readIngresses decodes the Ingress resources in the supplied stream of
+// YAML or JSON documents. Documents of other kinds are skipped, and the
+// items of List documents are decoded individually. Only
+// networking.k8s.io/v1 Ingresses are supported.
+func readIngresses(r io.Reader) ([]*networking_v1.Ingress, error) {
+	var ingresses []*networking_v1.Ingress
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var obj unstructured.Unstructured
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return ingresses, nil
+			}
+			return nil, err
+		}
+
+		objs := []unstructured.Unstructured{obj}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, err
+			}
+			objs = list.Items
+		}
+
+		for _, o := range objs {
+			if o.GetKind() != "Ingress" {
+				continue
+			}
+			if o.GetAPIVersion() != networking_v1.SchemeGroupVersion.String() {
+				return nil, fmt.Errorf("Ingress %s/%s has unsupported API version %q", o.GetNamespace(), o.GetName(), o.GetAPIVersion())
+			}
+
+			ing := &networking_v1.Ingress{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, ing); err != nil {
+				return nil, err
+			}
+			ingresses = append(ingresses, ing)
+		}
+	}
+}
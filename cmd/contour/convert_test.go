@@ -0,0 +1,77 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoConvert(t *testing.T) {
+	in := `apiVersion: v1
+kind: Service
+metadata:
+  name: kuard
+  namespace: default
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: kuard
+  namespace: default
+spec:
+  defaultBackend:
+    service:
+      name: kuard
+      port:
+        number: 80
+  rules:
+  - host: kuard.example.com
+    http:
+      paths:
+      - path: /
+        pathType: ImplementationSpecific
+        backend:
+          service:
+            name: kuard
+            port:
+              number: 80
+`
+
+	var out bytes.Buffer
+	require.NoError(t, doConvert(&convertContext{}, strings.NewReader(in), &out))
+
+	got := out.String()
+	assert.True(t, strings.HasPrefix(got, "# WARNING: default/kuard: spec.defaultBackend was not converted; HTTPProxy requires a virtual host fqdn\n---\n"))
+	assert.Contains(t, got, "kind: HTTPProxy\n")
+	assert.Contains(t, got, "    fqdn: kuard.example.com\n")
+	assert.NotContains(t, got, "status:")
+	assert.NotContains(t, got, "creationTimestamp:")
+}
+
+func TestDoConvertUnsupportedVersion(t *testing.T) {
+	in := `apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: kuard
+  namespace: default
+`
+
+	var out bytes.Buffer
+	assert.Error(t, doConvert(&convertContext{}, strings.NewReader(in), &out))
+}
@@ -0,0 +1,343 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// registerDAG registers the dag subcommand and flags
+// with the Application provided.
+func registerDAG(app *kingpin.Application) (*kingpin.CmdClause, *dagContext) {
+	var ctx dagContext
+	dag := app.Command("dag", "Print the DAG of a running Contour.")
+	dag.Flag("contour", "Contour debug host:port.").Default("127.0.0.1:6060").StringVar(&ctx.Addr)
+	dag.Flag("output", "Output format.").Short('o').Default("table").EnumVar(&ctx.Format, "table", "json", "dot")
+	dag.Flag("fqdn", "Only print the virtual hosts with this fqdn, and the objects they refer to.").StringVar(&ctx.FQDN)
+	dag.Flag("namespace", "Only print the virtual hosts of HTTPProxies in this namespace, and the objects they refer to.").Short('n').StringVar(&ctx.Namespace)
+
+	return dag, &ctx
+}
+
+// dagContext holds the configuration for the dag subcommand.
+type dagContext struct {
+	// Addr is the address of the Contour debug service.
+	Addr string
+
+	// Format is the output format: table, json or dot.
+	Format string
+
+	// FQDN and Namespace, if set, select the parts
+	// of the DAG that are printed.
+	FQDN      string
+	Namespace string
+}
+
+// dagGraph is the JSON representation of the DAG
+// that is served by the /debug/dag endpoint.
+type dagGraph struct {
+	Vertices []dagVertex `json:"vertices"`
+	Edges    []dagEdge   `json:"edges"`
+	Status   []dagStatus `json:"status"`
+}
+
+type dagVertex struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type dagEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type dagStatus struct {
+	Kind        string         `json:"kind"`
+	Namespace   string         `json:"namespace"`
+	Name        string         `json:"name"`
+	Generation  int64          `json:"generation"`
+	VirtualHost string         `json:"virtualhost,omitempty"`
+	Conditions  []dagCondition `json:"conditions"`
+}
+
+// dagCondition holds the fields common to the
+// conditions of each kind of object.
+type dagCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+
+	// Errors holds the errors of HTTPProxy conditions.
+	Errors []dagCondition `json:"errors,omitempty"`
+}
+
+// isVirtualHost returns true if v is a virtual host of any kind.
+func (v *dagVertex) isVirtualHost() bool {
+	switch v.Kind {
+	case "VirtualHost", "SecureVirtualHost", "TCPVirtualHost":
+		return true
+	default:
+		return false
+	}
+}
+
+// doDAG fetches the DAG from the Contour debug service
+// and writes it to out in the configured format.
+func doDAG(ctx *dagContext, out io.Writer) error {
+	dag, err := fetchDAG(ctx.Addr)
+	if err != nil {
+		return err
+	}
+
+	g := filterDAG(dag, ctx.FQDN, ctx.Namespace)
+
+	switch ctx.Format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	case "dot":
+		return writeDAGDot(&g, out)
+	default:
+		return writeDAGTable(&g, out)
+	}
+}
+
+// fetchDAG fetches the DAG from the Contour debug service at addr.
+func fetchDAG(addr string) (*dagGraph, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	resp, err := client.Get(fmt.Sprintf("http://%s/debug/dag?format=json", addr))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from %s: %s", addr, resp.Status)
+	}
+
+	var g dagGraph
+	if err := json.NewDecoder(resp.Body).Decode(&g); err != nil {
+		return nil, fmt.Errorf("failed to decode DAG: %w", err)
+	}
+
+	return &g, nil
+}
+
+// filterDAG returns the part of g that is reachable from the virtual
+// hosts with the fqdn, and of the HTTPProxies in the namespace, along
+// with the listeners of those virtual hosts and the status of those
+// HTTPProxies. An empty fqdn or namespace selects every virtual host.
+func filterDAG(g *dagGraph, fqdn, namespace string) dagGraph {
+	if fqdn == "" && namespace == "" {
+		return *g
+	}
+
+	// fqdns holds the virtual hosts of the HTTPProxies
+	// in the namespace.
+	fqdns := map[string]bool{}
+	for _, s := range g.Status {
+		if s.Kind == "HTTPProxy" && s.Namespace == namespace && s.VirtualHost != "" {
+			fqdns[s.VirtualHost] = true
+		}
+	}
+
+	selected := func(vhost string) bool {
+		if fqdn != "" && vhost != fqdn {
+			return false
+		}
+		if namespace != "" && !fqdns[vhost] {
+			return false
+		}
+		return true
+	}
+
+	children := map[string][]string{}
+	parents := map[string][]string{}
+	for _, e := range g.Edges {
+		children[e.From] = append(children[e.From], e.To)
+		parents[e.To] = append(parents[e.To], e.From)
+	}
+
+	keep := map[string]bool{}
+	var visit func(id string)
+	visit = func(id string) {
+		if keep[id] {
+			return
+		}
+		keep[id] = true
+		for _, child := range children[id] {
+			visit(child)
+		}
+	}
+
+	for _, v := range g.Vertices {
+		if v.isVirtualHost() && selected(v.Name) {
+			visit(v.ID)
+
+			// Keep the listeners of the virtual host,
+			// but not their other virtual hosts.
+			for _, parent := range parents[v.ID] {
+				keep[parent] = true
+			}
+		}
+	}
+
+	out := dagGraph{
+		Vertices: []dagVertex{},
+		Edges:    []dagEdge{},
+		Status:   []dagStatus{},
+	}
+	for _, v := range g.Vertices {
+		if keep[v.ID] {
+			out.Vertices = append(out.Vertices, v)
+		}
+	}
+	for _, e := range g.Edges {
+		if keep[e.From] && keep[e.To] {
+			out.Edges = append(out.Edges, e)
+		}
+	}
+	for _, s := range g.Status {
+		if namespace != "" && s.Namespace != namespace {
+			continue
+		}
+		if fqdn != "" && s.VirtualHost != fqdn {
+			continue
+		}
+		out.Status = append(out.Status, s)
+	}
+
+	return out
+}
+
+// writeDAGTable writes the routes of each virtual host in g, followed
+// by the status of each object, as tables.
+func writeDAGTable(g *dagGraph, out io.Writer) error {
+	vertices := map[string]*dagVertex{}
+	for i := range g.Vertices {
+		vertices[g.Vertices[i].ID] = &g.Vertices[i]
+	}
+
+	children := map[string][]*dagVertex{}
+	for _, e := range g.Edges {
+		children[e.From] = append(children[e.From], vertices[e.To])
+	}
+
+	var vhosts []*dagVertex
+	for i := range g.Vertices {
+		if g.Vertices[i].isVirtualHost() {
+			vhosts = append(vhosts, &g.Vertices[i])
+		}
+	}
+	sort.SliceStable(vhosts, func(i, j int) bool {
+		if vhosts[i].Name != vhosts[j].Name {
+			return vhosts[i].Name < vhosts[j].Name
+		}
+		return vhosts[i].Kind < vhosts[j].Kind
+	})
+
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "VIRTUAL HOST\tKIND\tROUTE\tSERVICE\tWEIGHT")
+	for _, vh := range vhosts {
+		var rows [][]string
+		for _, route := range children[vh.ID] {
+			switch route.Kind {
+			case "Route", "TCPProxy":
+			default:
+				continue
+			}
+			name := route.Name
+			if headers := route.Attributes["headers"]; headers != "" {
+				name += " " + headers
+			}
+			for _, cluster := range children[route.ID] {
+				if cluster.Kind != "Cluster" {
+					continue
+				}
+				for _, svc := range children[cluster.ID] {
+					if svc.Kind != "Service" {
+						continue
+					}
+					rows = append(rows, []string{
+						vh.Name,
+						vh.Kind,
+						name,
+						svc.Name + ":" + svc.Attributes["port"],
+						cluster.Attributes["weight"],
+					})
+				}
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return strings.Join(rows[i], "\t") < strings.Join(rows[j], "\t")
+		})
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tVIRTUAL HOST\tVALID\tREASON")
+	for _, s := range g.Status {
+		valid, reason := "", ""
+		for _, c := range s.Conditions {
+			switch c.Type {
+			case "Valid", "Admitted", "Accepted":
+				valid, reason = c.Status, c.Reason
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Kind, s.Namespace, s.Name, s.VirtualHost, valid, reason)
+	}
+
+	return tw.Flush()
+}
+
+// writeDAGDot writes g in DOT format.
+func writeDAGDot(g *dagGraph, out io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph DAG {\nrankdir=\"LR\"\n")
+	for _, v := range g.Vertices {
+		label := strings.ToLower(v.Kind)
+		if v.Name != "" {
+			label += "|" + v.Name
+		}
+		if port := v.Attributes["port"]; port != "" {
+			label += ":" + port
+		}
+		fmt.Fprintf(&b, "%q [shape=record, label=%q]\n", v.ID, "{"+label+"}")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "%q -> %q\n", e.From, e.To)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
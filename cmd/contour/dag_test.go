@@ -0,0 +1,98 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDAG = `{
+  "vertices": [
+    {"id": "0", "kind": "Listener", "name": "0.0.0.0:8080"},
+    {"id": "1", "kind": "VirtualHost", "name": "kuard.example.com"},
+    {"id": "2", "kind": "Route", "name": "prefix: /", "attributes": {"path": "prefix: /"}},
+    {"id": "3", "kind": "Cluster", "name": "default/kuard/80/da39a3ee5e", "attributes": {"weight": "0"}},
+    {"id": "4", "kind": "Service", "name": "default/kuard", "attributes": {"port": "80"}},
+    {"id": "5", "kind": "VirtualHost", "name": "echo.example.com"},
+    {"id": "6", "kind": "Route", "name": "prefix: /", "attributes": {"path": "prefix: /"}},
+    {"id": "7", "kind": "Cluster", "name": "echo/echo/80/da39a3ee5e", "attributes": {"weight": "0"}},
+    {"id": "8", "kind": "Service", "name": "echo/echo", "attributes": {"port": "80"}}
+  ],
+  "edges": [
+    {"from": "0", "to": "1"},
+    {"from": "1", "to": "2"},
+    {"from": "2", "to": "3"},
+    {"from": "3", "to": "4"},
+    {"from": "0", "to": "5"},
+    {"from": "5", "to": "6"},
+    {"from": "6", "to": "7"},
+    {"from": "7", "to": "8"}
+  ],
+  "status": [
+    {"kind": "HTTPProxy", "namespace": "default", "name": "kuard", "generation": 1, "virtualhost": "kuard.example.com",
+     "conditions": [{"type": "Valid", "status": "True", "reason": "Valid", "message": "Valid HTTPProxy"}]},
+    {"kind": "HTTPProxy", "namespace": "echo", "name": "echo", "generation": 1, "virtualhost": "echo.example.com",
+     "conditions": [{"type": "Valid", "status": "False", "reason": "ErrorPresent", "message": "At least one error present"}]}
+  ]
+}`
+
+func TestDoDAG(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/debug/dag", r.URL.Path)
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+		_, _ = w.Write([]byte(testDAG))
+	}))
+	defer srv.Close()
+
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	var out bytes.Buffer
+	require.NoError(t, doDAG(&dagContext{Addr: addr, Format: "table"}, &out))
+	assert.Equal(t, `VIRTUAL HOST       KIND         ROUTE      SERVICE           WEIGHT
+echo.example.com   VirtualHost  prefix: /  echo/echo:80      0
+kuard.example.com  VirtualHost  prefix: /  default/kuard:80  0
+
+KIND       NAMESPACE  NAME   VIRTUAL HOST       VALID  REASON
+HTTPProxy  default    kuard  kuard.example.com  True   Valid
+HTTPProxy  echo       echo   echo.example.com   False  ErrorPresent
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, doDAG(&dagContext{Addr: addr, Format: "dot", Namespace: "echo"}, &out))
+	got := out.String()
+	assert.Contains(t, got, `"5" [shape=record, label="{virtualhost|echo.example.com}"]`)
+	assert.Contains(t, got, `"0" -> "5"`)
+	assert.NotContains(t, got, "kuard")
+	assert.NotContains(t, got, `"0" -> "1"`)
+}
+
+func TestFilterDAG(t *testing.T) {
+	var out bytes.Buffer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testDAG))
+	}))
+	defer srv.Close()
+
+	require.NoError(t, doDAG(&dagContext{Addr: strings.TrimPrefix(srv.URL, "http://"), Format: "json", FQDN: "kuard.example.com"}, &out))
+	got := out.String()
+	assert.Contains(t, got, `"name": "default/kuard"`)
+	assert.NotContains(t, got, "echo")
+}
@@ -20,6 +20,7 @@ import (
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/shard"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
@@ -45,13 +46,21 @@ import (
 // 5. If the worker is stopped, the informer continues but no further
 //    status updates are made.
 type loadBalancerStatusWriter struct {
-	log              logrus.FieldLogger
-	clients          *k8s.Clients
-	isLeader         chan struct{}
-	lbStatus         chan v1.LoadBalancerStatus
-	statusUpdater    k8s.StatusUpdater
-	ingressClassName string
-	Converter        k8s.Converter
+	log               logrus.FieldLogger
+	clients           *k8s.Clients
+	isLeader          chan struct{}
+	lbStatus          chan v1.LoadBalancerStatus
+	statusUpdater     k8s.StatusUpdater
+	ingressClassNames []string
+	Converter         k8s.Converter
+
+	// publishHostnames enables publishing the hostnames of each
+	// object on the external-dns hostname annotation.
+	publishHostnames bool
+
+	// shard, if set, restricts the updated objects to those
+	// of the shard.
+	shard *shard.Selector
 }
 
 func (isw *loadBalancerStatusWriter) Start(stop <-chan struct{}) error {
@@ -69,15 +78,20 @@ func (isw *loadBalancerStatusWriter) Start(stop <-chan struct{}) error {
 		Logger: func() logrus.FieldLogger {
 			// Configure the StatusAddressUpdater logger.
 			log := isw.log.WithField("context", "StatusAddressUpdater")
-			if isw.ingressClassName != "" {
-				return log.WithField("target-ingress-class", isw.ingressClassName)
+			if len(isw.ingressClassNames) > 0 {
+				return log.WithField("target-ingress-classes", isw.ingressClassNames)
 			}
 
 			return log
 		}(),
-		IngressClassName: isw.ingressClassName,
-		StatusUpdater:    isw.statusUpdater,
-		Converter:        isw.Converter,
+		IngressClassNames: isw.ingressClassNames,
+		StatusUpdater:     isw.statusUpdater,
+		Converter:         isw.Converter,
+		Shard:             isw.shard,
+	}
+
+	if isw.publishHostnames {
+		u.AnnotationPatcher = isw.clients
 	}
 
 	// Create informers for the types that need load balancer
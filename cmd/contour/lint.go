@@ -0,0 +1,426 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/status"
+	"github.com/projectcontour/contour/pkg/certs"
+	"github.com/sirupsen/logrus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// registerLint registers the lint subcommand and flags
+// with the Application provided.
+func registerLint(app *kingpin.Application) (*kingpin.CmdClause, *lintContext) {
+	var ctx lintContext
+	lint := app.Command("lint", "Validate HTTPProxy resources before they are applied.")
+	lint.Arg("files", "YAML or JSON files containing HTTPProxy resources and the resources they refer to (default stdin).").ExistingFilesVar(&ctx.Files)
+	lint.Flag("cluster", "Look up the Services and Secrets that are not in the files in the cluster.").BoolVar(&ctx.Cluster)
+	lint.Flag("incluster", "Use in cluster configuration.").BoolVar(&ctx.InCluster)
+	lint.Flag("kubeconfig", "Path to kubeconfig (if not in running inside a cluster).").PlaceHolder("/path/to/file").Default(os.Getenv("KUBECONFIG")).StringVar(&ctx.Kubeconfig)
+
+	return lint, &ctx
+}
+
+// lintContext holds the configuration for the lint subcommand.
+type lintContext struct {
+	// Files are the files to read resources from.
+	// If empty, resources are read from stdin.
+	Files []string
+
+	// Cluster enables looking up the Services and Secrets
+	// that are not in the files in the cluster.
+	Cluster bool
+
+	// InCluster and Kubeconfig configure the
+	// client used to look up resources.
+	InCluster  bool
+	Kubeconfig string
+}
+
+// lintLookup finds the Services and Secrets that
+// HTTPProxies refer to but are not in the files.
+type lintLookup interface {
+	// Service returns the named Service, or nil if it does not exist.
+	Service(name types.NamespacedName) (*v1.Service, error)
+
+	// Secret returns the named Secret, or nil if it does not exist.
+	Secret(name types.NamespacedName) (*v1.Secret, error)
+}
+
+// clusterLookup is a lintLookup that gets resources from the cluster.
+type clusterLookup struct {
+	clients *k8s.Clients
+}
+
+func (c *clusterLookup) Service(name types.NamespacedName) (*v1.Service, error) {
+	svc, err := c.clients.ClientSet().CoreV1().Services(name.Namespace).Get(context.TODO(), name.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return svc, err
+}
+
+func (c *clusterLookup) Secret(name types.NamespacedName) (*v1.Secret, error) {
+	secret, err := c.clients.ClientSet().CoreV1().Secrets(name.Namespace).Get(context.TODO(), name.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return secret, err
+}
+
+// placeholderLookup is a lintLookup that assumes that every Service
+// and Secret exists, so that the HTTPProxies can be validated without
+// access to the cluster.
+type placeholderLookup struct {
+	// ports holds the ports of each Service referred to.
+	ports map[types.NamespacedName][]int
+
+	// certs holds the keypair of the placeholder Secrets,
+	// which is generated when it is first needed.
+	certs *certs.Certificates
+}
+
+func (p *placeholderLookup) Service(name types.NamespacedName) (*v1.Service, error) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+	}
+	for _, port := range p.ports[name] {
+		svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{
+			Name:     fmt.Sprintf("port-%d", port),
+			Protocol: v1.ProtocolTCP,
+			Port:     int32(port),
+		})
+	}
+	return svc, nil
+}
+
+func (p *placeholderLookup) Secret(name types.NamespacedName) (*v1.Secret, error) {
+	if p.certs == nil {
+		c, err := certs.GenerateCerts(nil)
+		if err != nil {
+			return nil, err
+		}
+		p.certs = c
+	}
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:        p.certs.EnvoyCertificate,
+			v1.TLSPrivateKeyKey:  p.certs.EnvoyPrivateKey,
+			dag.CACertificateKey: p.certs.CACertificate,
+		},
+	}, nil
+}
+
+// doLint reads resources from the configured files, validates the
+// HTTPProxies among them as Contour would, and writes the status of
+// each HTTPProxy to out. It returns an error if any HTTPProxy is
+// invalid or partially valid.
+func doLint(ctx *lintContext, in io.Reader, out io.Writer) error {
+	converter, err := k8s.NewUnstructuredConverter()
+	if err != nil {
+		return err
+	}
+
+	var objs []interface{}
+	if len(ctx.Files) == 0 {
+		objs, err = readObjects(in, converter)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range ctx.Files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		o, err := readObjects(f, converter)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", file, err)
+		}
+		objs = append(objs, o...)
+	}
+
+	var lookup lintLookup
+	if ctx.Cluster {
+		clients, err := k8s.NewClients(ctx.Kubeconfig, ctx.InCluster)
+		if err != nil {
+			return fmt.Errorf("failed to create Kubernetes clients: %w", err)
+		}
+		lookup = &clusterLookup{clients: clients}
+	}
+
+	return lintObjects(objs, lookup, out)
+}
+
+// lintObjects validates the HTTPProxies in objs, looking up the Services and
+// Secrets they refer to that are not in objs with lookup, or assuming
+// they exist if lookup is nil.
+func lintObjects(objs []interface{}, lookup lintLookup, out io.Writer) error {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: log,
+		},
+		Processors: []dag.Processor{
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{},
+		},
+	}
+
+	proxies := map[types.NamespacedName]*contour_api_v1.HTTPProxy{}
+	services := map[types.NamespacedName]bool{}
+	secrets := map[types.NamespacedName]bool{}
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *contour_api_v1.HTTPProxy:
+			proxies[k8s.NamespacedNameOf(o)] = o
+		case *v1.Service:
+			services[k8s.NamespacedNameOf(o)] = true
+		case *v1.Secret:
+			secrets[k8s.NamespacedNameOf(o)] = true
+		}
+		builder.Source.Insert(obj)
+	}
+
+	refs := proxyReferences(proxies)
+	if lookup == nil {
+		lookup = &placeholderLookup{ports: refs.services}
+	}
+
+	for _, name := range sortedNames(refs.services) {
+		if services[name] {
+			continue
+		}
+		svc, err := lookup.Service(name)
+		if err != nil {
+			return fmt.Errorf("failed to look up Service %s: %w", name, err)
+		}
+		if svc != nil {
+			builder.Source.Insert(svc)
+		}
+	}
+
+	for _, name := range sortedNames(refs.secrets) {
+		if secrets[name] {
+			continue
+		}
+		secret, err := lookup.Secret(name)
+		if err != nil {
+			return fmt.Errorf("failed to look up Secret %s: %w", name, err)
+		}
+		if secret != nil {
+			builder.Source.Insert(secret)
+		}
+	}
+
+	updates := builder.Build().StatusCache.GetProxyUpdates()
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Fullname.String() < updates[j].Fullname.String()
+	})
+
+	invalid := 0
+	for _, pu := range updates {
+		proxy, ok := proxies[pu.Fullname]
+		if !ok {
+			continue
+		}
+		updated := pu.Mutate(proxy).(*contour_api_v1.HTTPProxy)
+
+		// Orphaned HTTPProxies may be included by a root
+		// HTTPProxy that is not in the files.
+		switch status.ProxyStatus(updated.Status.CurrentStatus) {
+		case status.ProxyStatusValid, status.ProxyStatusOrphaned:
+		default:
+			invalid++
+		}
+
+		if _, err := fmt.Fprintf(out, "%s: %s: %s\n", pu.Fullname, updated.Status.CurrentStatus, updated.Status.Description); err != nil {
+			return err
+		}
+
+		cond := pu.ConditionFor(status.ValidCondition)
+		for _, e := range cond.Errors {
+			if _, err := fmt.Fprintf(out, "  error: %s: %s\n", e.Reason, e.Message); err != nil {
+				return err
+			}
+		}
+		for _, w := range cond.Warnings {
+			if _, err := fmt.Fprintf(out, "  warning: %s: %s\n", w.Reason, w.Message); err != nil {
+				return err
+			}
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d HTTPProxies are not valid", invalid, len(proxies))
+	}
+
+	return nil
+}
+
+// lintReferences holds the Services, with their ports, and the
+// Secrets that HTTPProxies refer to.
+type lintReferences struct {
+	services map[types.NamespacedName][]int
+	secrets  map[types.NamespacedName][]int
+}
+
+// proxyReferences returns the Services and Secrets that proxies refer to.
+func proxyReferences(proxies map[types.NamespacedName]*contour_api_v1.HTTPProxy) lintReferences {
+	refs := lintReferences{
+		services: map[types.NamespacedName][]int{},
+		secrets:  map[types.NamespacedName][]int{},
+	}
+
+	addService := func(namespace string, s contour_api_v1.Service) {
+		// Services that set their own endpoints
+		// are not looked up.
+		if len(s.Endpoints) > 0 || len(s.PodSelector) > 0 {
+			return
+		}
+		name := types.NamespacedName{Namespace: namespace, Name: s.Name}
+		refs.services[name] = append(refs.services[name], s.Port)
+
+		if s.UpstreamValidation != nil {
+			refs.secrets[k8s.NamespacedNameFrom(s.UpstreamValidation.CACertificate, k8s.DefaultNamespace(namespace))] = nil
+		}
+	}
+
+	for _, proxy := range proxies {
+		ns := proxy.Namespace
+
+		if vh := proxy.Spec.VirtualHost; vh != nil && vh.TLS != nil {
+			if vh.TLS.SecretName != "" {
+				refs.secrets[k8s.NamespacedNameFrom(vh.TLS.SecretName, k8s.DefaultNamespace(ns))] = nil
+			}
+			if cv := vh.TLS.ClientValidation; cv != nil && cv.CACertificate != "" {
+				refs.secrets[k8s.NamespacedNameFrom(cv.CACertificate, k8s.DefaultNamespace(ns))] = nil
+			}
+		}
+
+		for _, route := range proxy.Spec.Routes {
+			for _, s := range route.Services {
+				addService(ns, s)
+			}
+		}
+
+		if tcp := proxy.Spec.TCPProxy; tcp != nil {
+			for _, s := range tcp.Services {
+				addService(ns, s)
+			}
+		}
+	}
+
+	return refs
+}
+
+// sortedNames returns the keys of names in order.
+func sortedNames(names map[types.NamespacedName][]int) []types.NamespacedName {
+	var sorted []types.NamespacedName
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].String() < sorted[j].String()
+	})
+	return sorted
+}
+
+// readObjects decodes the resources in the supplied stream of YAML or
+// JSON documents into their typed objects. Documents of kinds that
+// Contour does not know are skipped, and the items of List documents
+// are decoded individually.
+func readObjects(r io.Reader, converter *k8s.UnstructuredConverter) ([]interface{}, error) {
+	var objs []interface{}
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var obj unstructured.Unstructured
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, err
+		}
+
+		// Empty documents decode to nil.
+		if obj.Object == nil {
+			continue
+		}
+
+		items := []unstructured.Unstructured{obj}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, err
+			}
+			items = list.Items
+		}
+
+		for i := range items {
+			o, err := converter.FromUnstructured(&items[i])
+			if err != nil {
+				if runtime.IsNotRegisteredError(err) {
+					continue
+				}
+				return nil, err
+			}
+
+			// Secrets in files commonly set stringData,
+			// which the API server merges into data.
+			if secret, ok := o.(*v1.Secret); ok {
+				for k, v := range secret.StringData {
+					if secret.Data == nil {
+						secret.Data = map[string][]byte{}
+					}
+					secret.Data[k] = []byte(v)
+				}
+			}
+
+			objs = append(objs, o)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const lintProxies = `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: valid
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: valid.example.com
+    tls:
+      secretName: valid
+  routes:
+  - services:
+    - name: kuard
+      port: 80
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: invalid
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: invalid.example.com
+  routes:
+  - conditions:
+    - prefix: foo
+    services:
+    - name: kuard
+      port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+  namespace: default
+`
+
+func TestDoLint(t *testing.T) {
+	var out bytes.Buffer
+	err := doLint(&lintContext{}, strings.NewReader(lintProxies), &out)
+	assert.EqualError(t, err, "1 of 2 HTTPProxies are not valid")
+
+	got := out.String()
+	assert.True(t, strings.HasPrefix(got, "default/invalid: invalid: "))
+	assert.Contains(t, got, "  error: PathMatchConditionsNotValid: ")
+	assert.Contains(t, got, "default/valid: valid: Valid HTTPProxy\n")
+}
+
+// missingLookup is a lintLookup that finds nothing.
+type missingLookup struct{}
+
+func (missingLookup) Service(types.NamespacedName) (*v1.Service, error) { return nil, nil }
+func (missingLookup) Secret(types.NamespacedName) (*v1.Secret, error)   { return nil, nil }
+
+func TestLintObjectsMissingReferences(t *testing.T) {
+	converter, err := k8s.NewUnstructuredConverter()
+	require.NoError(t, err)
+
+	objs, err := readObjects(strings.NewReader(lintProxies), converter)
+	require.NoError(t, err)
+	require.Len(t, objs, 2)
+
+	var out bytes.Buffer
+	assert.EqualError(t, lintObjects(objs, missingLookup{}, &out), "2 of 2 HTTPProxies are not valid")
+	assert.Contains(t, out.String(), "default/valid: invalid: ")
+}
@@ -0,0 +1,227 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/timeout"
+	xdscache_v3 "github.com/projectcontour/contour/internal/xdscache/v3"
+	"github.com/projectcontour/contour/pkg/config"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// configReloader polls the Contour configuration file and applies the
+// changes to the settings that can be changed without a restart: the
+// access log format, the listener timeouts, the fallback certificate and
+// the maximum removal percent.
+// The changes are applied on the event handler goroutine, and the next
+// DAG rebuild sends them to Envoy over the existing xDS streams.
+//
+// A setting is only applied when its value in the file changes, so a
+// command-line flag keeps overriding the file until then. Changes to
+// other settings are logged, and take effect when Contour restarts.
+type configReloader struct {
+	log      logrus.FieldLogger
+	path     string
+	interval time.Duration
+
+	// contents holds the contents of the configuration file when
+	// it was last read, and loaded the parameters last applied.
+	contents []byte
+	loaded   *config.Parameters
+
+	eventHandler  *contour.EventHandler
+	listenerCache *xdscache_v3.ListenerCache
+	removalGuard  *contour.RemovalGuard
+}
+
+// newConfigReloader returns a configReloader for the configuration
+// file at path, whose current contents are taken to be applied.
+func newConfigReloader(path string, interval time.Duration, eventHandler *contour.EventHandler, listenerCache *xdscache_v3.ListenerCache, removalGuard *contour.RemovalGuard, log logrus.FieldLogger) (*configReloader, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := parseConfigContents(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return &configReloader{
+		log:           log,
+		path:          path,
+		interval:      interval,
+		contents:      contents,
+		loaded:        params,
+		eventHandler:  eventHandler,
+		listenerCache: listenerCache,
+		removalGuard:  removalGuard,
+	}, nil
+}
+
+// parseConfigContents parses and validates the contents of a
+// configuration file.
+func parseConfigContents(contents []byte) (*config.Parameters, error) {
+	params, err := config.Parse(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Contour configuration: %w", err)
+	}
+
+	return params, nil
+}
+
+func (r *configReloader) Start(stop <-chan struct{}) error {
+	r.log.WithField("interval", r.interval).Info("watching configuration file for changes")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			r.reload()
+		}
+	}
+}
+
+// reload applies the changes made to the configuration file since
+// it was last read.
+func (r *configReloader) reload() {
+	// A ConfigMap volume is updated by replacing a symlink,
+	// so the file is read again rather than watched.
+	contents, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		r.log.WithError(err).Error("failed to read configuration file")
+		return
+	}
+
+	if bytes.Equal(contents, r.contents) {
+		return
+	}
+	r.contents = contents
+
+	params, err := parseConfigContents(contents)
+	if err != nil {
+		r.log.WithError(err).Error("ignoring changed configuration file")
+		return
+	}
+
+	old := r.loaded
+	r.loaded = params
+
+	if restartRequired(old, params) {
+		r.log.Warn("configuration file changes other than the access log format, timeouts, fallback certificate and max removal percent take effect when Contour restarts")
+	}
+
+	r.log.Info("applying configuration file changes")
+	r.eventHandler.Reconfigure(func() {
+		applyConfig(old, params, &r.listenerCache.Config, &r.eventHandler.Builder, r.removalGuard)
+	})
+}
+
+// applyConfig applies the reloadable settings that differ between
+// old and next to the listener configuration, the DAG builder and
+// the removal guard.
+func applyConfig(old, next *config.Parameters, lc *xdscache_v3.ListenerConfig, builder *dag.Builder, guard *contour.RemovalGuard) {
+	if !reflect.DeepEqual(accessLogSettings(old), accessLogSettings(next)) {
+		lc.AccessLogType = next.AccessLogFormat
+		lc.AccessLogFields = next.AccessLogFields
+		lc.AccessLogFormatString = next.AccessLogFormatString
+		lc.AccessLogFormatterExtensions = next.AccessLogFormatterExtensions()
+		lc.TCPAccessLogFields = next.TCPAccessLogFields
+		lc.TCPAccessLogFormatString = next.TCPAccessLogFormatString
+	}
+
+	// The timeouts were validated, so they parse.
+	if old.Timeouts != next.Timeouts {
+		lc.RequestTimeout, _ = timeout.Parse(next.Timeouts.RequestTimeout)
+		lc.ConnectionIdleTimeout, _ = timeout.Parse(next.Timeouts.ConnectionIdleTimeout)
+		lc.StreamIdleTimeout, _ = timeout.Parse(next.Timeouts.StreamIdleTimeout)
+		lc.DelayedCloseTimeout, _ = timeout.Parse(next.Timeouts.DelayedCloseTimeout)
+		lc.MaxConnectionDuration, _ = timeout.Parse(next.Timeouts.MaxConnectionDuration)
+		lc.ConnectionShutdownGracePeriod, _ = timeout.Parse(next.Timeouts.ConnectionShutdownGracePeriod)
+	}
+
+	if old.TLS.FallbackCertificate != next.TLS.FallbackCertificate {
+		oldCert := namespacedNameOf(old.TLS.FallbackCertificate)
+		newCert := namespacedNameOf(next.TLS.FallbackCertificate)
+
+		for _, p := range builder.Processors {
+			if hp, ok := p.(*dag.HTTPProxyProcessor); ok {
+				hp.FallbackCertificate = newCert
+			}
+		}
+
+		var refs []*types.NamespacedName
+		for _, ref := range builder.Source.ConfiguredSecretRefs {
+			if oldCert == nil || *ref != *oldCert {
+				refs = append(refs, ref)
+			}
+		}
+		if newCert != nil {
+			refs = append(refs, newCert)
+		}
+		builder.Source.ConfiguredSecretRefs = refs
+	}
+
+	// Raising the limit, or setting it to 0, lets a blocked
+	// removal through on the rebuild that follows.
+	if old.MaxRemovalPercent != next.MaxRemovalPercent {
+		guard.MaxRemovalPercent = next.MaxRemovalPercent
+	}
+}
+
+// accessLogSettings returns the access log settings of params.
+func accessLogSettings(params *config.Parameters) []interface{} {
+	return []interface{}{
+		params.AccessLogFormat,
+		params.AccessLogFields,
+		params.AccessLogFormatString,
+		params.TCPAccessLogFields,
+		params.TCPAccessLogFormatString,
+	}
+}
+
+// restartRequired returns true if old and next differ in any
+// setting that is not reloaded.
+func restartRequired(old, next *config.Parameters) bool {
+	reset := func(params config.Parameters) config.Parameters {
+		params.AccessLogFormat = ""
+		params.AccessLogFields = nil
+		params.AccessLogFormatString = ""
+		params.TCPAccessLogFields = nil
+		params.TCPAccessLogFormatString = ""
+		params.Timeouts = config.TimeoutParameters{}
+		params.TLS.FallbackCertificate = config.NamespacedName{}
+		params.MaxRemovalPercent = 0
+		return params
+	}
+
+	return !reflect.DeepEqual(reset(*old), reset(*next))
+}
@@ -0,0 +1,90 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/timeout"
+	xdscache_v3 "github.com/projectcontour/contour/internal/xdscache/v3"
+	"github.com/projectcontour/contour/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestApplyConfig(t *testing.T) {
+	old := config.Defaults()
+	old.TLS.FallbackCertificate = config.NamespacedName{Namespace: "projectcontour", Name: "fallback"}
+
+	next := config.Defaults()
+	next.AccessLogFormat = config.JSONAccessLog
+	next.Timeouts.RequestTimeout = "30s"
+	next.TLS.FallbackCertificate = config.NamespacedName{Namespace: "projectcontour", Name: "fallback-2"}
+
+	clientCert := &types.NamespacedName{Namespace: "projectcontour", Name: "client"}
+	proxies := &dag.HTTPProxyProcessor{
+		FallbackCertificate: &types.NamespacedName{Namespace: "projectcontour", Name: "fallback"},
+	}
+	builder := &dag.Builder{
+		Source: dag.KubernetesCache{
+			ConfiguredSecretRefs: []*types.NamespacedName{
+				clientCert,
+				{Namespace: "projectcontour", Name: "fallback"},
+			},
+		},
+		Processors: []dag.Processor{proxies, &dag.ListenerProcessor{}},
+	}
+
+	lc := &xdscache_v3.ListenerConfig{
+		AccessLogType:  config.EnvoyAccessLog,
+		RequestTimeout: timeout.DefaultSetting(),
+	}
+
+	guard := &contour.RemovalGuard{MaxRemovalPercent: old.MaxRemovalPercent}
+	next.MaxRemovalPercent = 50
+
+	applyConfig(&old, &next, lc, builder, guard)
+
+	assert.Equal(t, config.JSONAccessLog, lc.AccessLogType)
+	assert.Equal(t, timeout.DurationSetting(30*time.Second), lc.RequestTimeout)
+
+	fallback := &types.NamespacedName{Namespace: "projectcontour", Name: "fallback-2"}
+	assert.Equal(t, fallback, proxies.FallbackCertificate)
+	assert.Equal(t, []*types.NamespacedName{clientCert, fallback}, builder.Source.ConfiguredSecretRefs)
+	assert.Equal(t, 50, guard.MaxRemovalPercent)
+
+	// Settings that have not changed in the file keep the
+	// values they were overridden with by command-line flags.
+	lc.AccessLogType = config.EnvoyAccessLog
+	applyConfig(&next, &next, lc, builder, guard)
+	assert.Equal(t, config.EnvoyAccessLog, lc.AccessLogType)
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := config.Defaults()
+
+	reloadable := config.Defaults()
+	reloadable.AccessLogFormatString = "%START_TIME%\n"
+	reloadable.Timeouts.StreamIdleTimeout = "5m"
+	reloadable.TLS.FallbackCertificate = config.NamespacedName{Namespace: "projectcontour", Name: "fallback"}
+	reloadable.MaxRemovalPercent = 20
+	assert.False(t, restartRequired(&old, &reloadable))
+
+	other := config.Defaults()
+	other.DisablePermitInsecure = true
+	assert.True(t, restartRequired(&old, &other))
+}
@@ -21,7 +21,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -34,11 +34,14 @@ import (
 	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/debug"
+	"github.com/projectcontour/contour/internal/geoip"
 	"github.com/projectcontour/contour/internal/health"
 	"github.com/projectcontour/contour/internal/httpsvc"
 	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/projectcontour/contour/internal/shard"
 	"github.com/projectcontour/contour/internal/timeout"
+	"github.com/projectcontour/contour/internal/wasm"
 	"github.com/projectcontour/contour/internal/workgroup"
 	"github.com/projectcontour/contour/internal/xds"
 	contour_xds_v3 "github.com/projectcontour/contour/internal/xds/v3"
@@ -52,11 +55,16 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 	controller_config "sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
@@ -66,6 +74,9 @@ import (
 // Add RBAC policy to support leader election.
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;get;update
 
+// Add RBAC policy to support recording configuration change events.
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
 // Add RBAC policy to support getting CRDs.
 // +kubebuilder:rbac:groups="apiextensions.k8s.io",resources=customresourcedefinitions,verbs=list
 
@@ -110,17 +121,21 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 
 		parsed = true
 		ctx.Config = *params
+		ctx.configPath = configFile
 
 		return nil
 	}
 
 	serve.Flag("config-path", "Path to base configuration.").Short('c').PlaceHolder("/path/to/file").Action(parseConfig).ExistingFileVar(&configFile)
+	serve.Flag("config-reload-interval", "How often to check the configuration file for changes to apply without restarting. Disabled if 0.").PlaceHolder("<duration>").DurationVar(&ctx.configReloadInterval)
 
 	serve.Flag("incluster", "Use in cluster configuration.").BoolVar(&ctx.Config.InCluster)
 	serve.Flag("kubeconfig", "Path to kubeconfig (if not in running inside a cluster).").PlaceHolder("/path/to/file").StringVar(&ctx.Config.Kubeconfig)
 
 	serve.Flag("xds-address", "xDS gRPC API address.").PlaceHolder("<ipaddr>").StringVar(&ctx.xdsAddr)
-	serve.Flag("xds-port", "xDS gRPC API port.").PlaceHolder("<port>").IntVar(&ctx.xdsPort)
+	serve.Flag("xds-port", "xDS gRPC API port. Set to 0 to only serve the additional xDS addresses.").PlaceHolder("<port>").IntVar(&ctx.xdsPort)
+	serve.Flag("xds-bind-address", "Additional address the xDS gRPC API will bind to. May be repeated.").PlaceHolder("<ipaddr:port>").StringsVar(&ctx.xdsBindAddresses)
+	serve.Flag("xds-socket", "Unix domain socket the xDS gRPC API will also listen on, served without TLS.").PlaceHolder("/path/to/socket").StringVar(&ctx.xdsSocket)
 
 	serve.Flag("stats-address", "Envoy /stats interface address.").PlaceHolder("<ipaddr>").StringVar(&ctx.statsAddr)
 	serve.Flag("stats-port", "Envoy /stats interface port.").PlaceHolder("<port>").IntVar(&ctx.statsPort)
@@ -132,6 +147,7 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 	serve.Flag("http-port", "Port the metrics HTTP endpoint will bind to.").PlaceHolder("<port>").IntVar(&ctx.metricsPort)
 	serve.Flag("health-address", "Address the health HTTP endpoint will bind to.").PlaceHolder("<ipaddr>").StringVar(&ctx.healthAddr)
 	serve.Flag("health-port", "Port the health HTTP endpoint will bind to.").PlaceHolder("<port>").IntVar(&ctx.healthPort)
+	serve.Flag("readiness-staleness-threshold", "How long the xDS snapshot may lag behind observed Kubernetes resources before /readyz fails.").PlaceHolder("<duration>").DurationVar(&ctx.readinessStalenessThreshold)
 
 	serve.Flag("contour-cafile", "CA bundle file name for serving gRPC with TLS.").Envar("CONTOUR_CAFILE").StringVar(&ctx.caFile)
 	serve.Flag("contour-cert-file", "Contour certificate file name for serving gRPC over TLS.").PlaceHolder("/path/to/file").Envar("CONTOUR_CERT_FILE").StringVar(&ctx.contourCert)
@@ -139,7 +155,7 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 	serve.Flag("insecure", "Allow serving without TLS secured gRPC.").BoolVar(&ctx.PermitInsecureGRPC)
 	serve.Flag("root-namespaces", "Restrict contour to searching these namespaces for root ingress routes.").PlaceHolder("<ns,ns>").StringVar(&ctx.rootNamespaces)
 
-	serve.Flag("ingress-class-name", "Contour IngressClass name.").PlaceHolder("<name>").StringVar(&ctx.ingressClassName)
+	serve.Flag("ingress-class-name", "Contour IngressClass name (comma-separated list allowed).").PlaceHolder("<name>").StringVar(&ctx.ingressClassName)
 	serve.Flag("ingress-status-address", "Address to set in Ingress object status.").PlaceHolder("<address>").StringVar(&ctx.Config.IngressStatusAddress)
 	serve.Flag("envoy-http-access-log", "Envoy HTTP access log.").PlaceHolder("/path/to/file").StringVar(&ctx.httpAccessLog)
 	serve.Flag("envoy-https-access-log", "Envoy HTTPS access log.").PlaceHolder("/path/to/file").StringVar(&ctx.httpsAccessLog)
@@ -230,6 +246,19 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		}
 	}
 
+	// List and watch only the HTTPProxies, Services and Secrets
+	// that are in scope. The watch filters stay as a fallback.
+	if watch := ctx.Config.Watch; watchScoped(watch) {
+		// The label selector is checked when the configuration is validated.
+		selector, _ := labels.Parse(watch.LabelSelector)
+		resources := append([]schema.GroupVersionResource{contour_api_v1.HTTPProxyGVR}, k8s.ServicesResources()...)
+		resources = append(resources, k8s.SecretsResources()...)
+
+		if err := clients.ScopeInformers(watchNamespaces(watch, fallbackCert, clientCert), watch.ExcludeNamespaces, selector, resources...); err != nil {
+			return fmt.Errorf("failed to scope informers: %w", err)
+		}
+	}
+
 	// Set up Prometheus registry and register base metrics.
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
@@ -277,28 +306,33 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		ctx.Config.Listener.ConnectionBalancer = ""
 	}
 
+	// Look up the country and autonomous system of client
+	// addresses for Envoy, if GeoIP databases are configured.
+	geoIPServer, err := newGeoIPServer(ctx.Config.GeoIP, log.WithField("context", "geoip"))
+	if err != nil {
+		return err
+	}
+
 	listenerConfig := xdscache_v3.ListenerConfig{
 		UseProxyProto: ctx.useProxyProto,
-		HTTPListeners: map[string]xdscache_v3.Listener{
-			"ingress_http": {
-				Name:    "ingress_http",
-				Address: ctx.httpAddr,
-				Port:    ctx.httpPort,
-			},
-		},
-		HTTPSListeners: map[string]xdscache_v3.Listener{
-			"ingress_https": {
-				Name:    "ingress_https",
-				Address: ctx.httpsAddr,
-				Port:    ctx.httpsPort,
-			},
-		},
+		HTTPListeners: httpListeners(xdscache_v3.Listener{
+			Name:    "ingress_http",
+			Address: ctx.httpAddr,
+			Port:    ctx.httpPort,
+		}, ctx.Config.Listener.HTTPListeners),
+		HTTPSListeners: httpListeners(xdscache_v3.Listener{
+			Name:    "ingress_https",
+			Address: ctx.httpsAddr,
+			Port:    ctx.httpsPort,
+		}, ctx.Config.Listener.HTTPSListeners),
 		HTTPAccessLog:                 ctx.httpAccessLog,
 		HTTPSAccessLog:                ctx.httpsAccessLog,
 		AccessLogType:                 ctx.Config.AccessLogFormat,
 		AccessLogFields:               ctx.Config.AccessLogFields,
 		AccessLogFormatString:         ctx.Config.AccessLogFormatString,
 		AccessLogFormatterExtensions:  ctx.Config.AccessLogFormatterExtensions(),
+		TCPAccessLogFields:            ctx.Config.TCPAccessLogFields,
+		TCPAccessLogFormatString:      ctx.Config.TCPAccessLogFormatString,
 		MinimumTLSVersion:             annotation.MinTLSVersion(ctx.Config.TLS.MinimumProtocolVersion, "1.2"),
 		CipherSuites:                  config.SanitizeCipherSuites(ctx.Config.TLS.CipherSuites),
 		RequestTimeout:                requestTimeout,
@@ -310,7 +344,21 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		DefaultHTTPVersions:           parseDefaultHTTPVersions(ctx.Config.DefaultHTTPVersions),
 		AllowChunkedLength:            !ctx.Config.DisableAllowChunkedLength,
 		XffNumTrustedHops:             ctx.Config.Network.XffNumTrustedHops,
+		SkipXffAppend:                 ctx.Config.Network.SkipXffAppend,
+		ReplaceExternalRequestID:      ctx.Config.Network.RequestID.Policy == config.GenerateRequestIDPolicy,
 		ConnectionBalancer:            ctx.Config.Listener.ConnectionBalancer,
+		ReusePort:                     ctx.Config.Listener.ReusePort,
+		TCPFastOpenQueueLength:        ctx.Config.Listener.TCPFastOpenQueueLength,
+		Backlog:                       ctx.Config.Listener.Backlog,
+		ListenerOverrides:             listenerOverrides(ctx.Config.Listener.Overrides),
+		AcceptHTTP10:                  ctx.Config.Listener.AcceptHTTP10,
+		DefaultHostForHTTP10:          ctx.Config.Listener.DefaultHostForHTTP10,
+		TCPListeners:                  tcpListeners(ctx.Config.Listener.TCPListeners),
+		MirrorPort:                    ctx.Config.Listener.MirrorPort,
+		TracingConfig:                 tracingConfig(ctx.Config.Tracing),
+		XDSDelta:                      ctx.Config.Server.XDSDelta,
+		VHDS:                          ctx.Config.Server.VHDS,
+		GeoIP:                         geoIPServer != nil,
 	}
 
 	if ctx.Config.RateLimitService.ExtensionService != "" {
@@ -349,20 +397,52 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	// Endpoints updates are handled directly by the EndpointsTranslator
 	// due to their high update rate and their orthogonal nature.
 	endpointHandler := xdscache_v3.NewEndpointsTranslator(log.WithField("context", "endpointstranslator"))
+	if zar := ctx.Config.Cluster.ZoneAwareRouting; zar != nil && zar.Enabled {
+		endpointHandler.SetOverprovisioningFactor(zar.OverprovisioningFactor)
+	}
+
+	// virtualHostCache holds the virtual hosts that Envoy fetches
+	// on demand, if VHDS is enabled.
+	var virtualHostCache *xdscache_v3.VirtualHostCache
+	if ctx.Config.Server.VHDS {
+		virtualHostCache = &xdscache_v3.VirtualHostCache{}
+	}
+
+	listenerCache := xdscache_v3.NewListenerCache(listenerConfig, ctx.statsAddr, ctx.statsPort)
 
 	resources := []xdscache.ResourceCache{
-		xdscache_v3.NewListenerCache(listenerConfig, ctx.statsAddr, ctx.statsPort),
+		listenerCache,
 		&xdscache_v3.SecretCache{},
-		&xdscache_v3.RouteCache{},
-		&xdscache_v3.ClusterCache{},
+		&xdscache_v3.RouteCache{
+			RequestIDHeader: ctx.Config.Network.RequestID.Header,
+			VirtualHosts:    virtualHostCache,
+		},
+		&xdscache_v3.ClusterCache{
+			TCPKeepalive:     tcpKeepalive(ctx.Config.Cluster.TCPKeepalive),
+			ZoneAwareRouting: zoneAwareRouting(ctx.Config.Cluster.ZoneAwareRouting),
+			XDSDelta:         ctx.Config.Server.XDSDelta,
+			MirrorPort:       ctx.Config.Listener.MirrorPort,
+		},
 		endpointHandler,
+		xdscache_v3.NewRuntimeCache(ctx.Config.Runtime),
 	}
 
 	// snapshotHandler is used to produce new snapshots when the internal state changes for any xDS resource.
 	snapshotHandler := xdscache.NewSnapshotHandler(resources, log.WithField("context", "snapshotHandler"))
-
-	// register observer for endpoints updates.
-	endpointHandler.Observer = contour.ComposeObservers(snapshotHandler)
+	snapshotHandler.Metrics = contourMetrics
+
+	// freshness tracks whether observed Kubernetes resources have
+	// been written to the xDS snapshot, for the readiness endpoint.
+	freshness := &health.Freshness{}
+	snapshotHandler.Freshness = freshness
+
+	// register observer for endpoints updates, coalescing
+	// bursts of updates into a single snapshot.
+	endpointHandler.Observer = &contour.HoldoffObserver{
+		Next:            contour.ComposeObservers(snapshotHandler),
+		HoldoffDelay:    ctx.Config.Holdoff.Delay,
+		HoldoffMaxDelay: ctx.Config.Holdoff.MaxDelay,
+	}
 
 	// Log that we're using the fallback certificate if configured.
 	if fallbackCert != nil {
@@ -372,15 +452,34 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		log.WithField("context", "envoy-client-certificate").Infof("enabled client certificate with secret: %q", clientCert)
 	}
 
+	// Pull the Wasm modules that are published as OCI images
+	// in the background.
+	wasmFetcher := &wasm.Fetcher{
+		RetryInterval: time.Minute,
+		FieldLogger:   log.WithField("context", "wasm"),
+	}
+
+	// The shard selector is checked when the configuration is validated.
+	shardSelector, err := shard.New(ctx.Config.Shard.Namespaces, ctx.Config.Shard.Selector)
+	if err != nil {
+		return err
+	}
+
 	// Build the core Kubernetes event handler.
 	eventHandler := &contour.EventHandler{
-		HoldoffDelay:    100 * time.Millisecond,
-		HoldoffMaxDelay: 500 * time.Millisecond,
+		HoldoffDelay:    ctx.Config.Holdoff.Delay,
+		HoldoffMaxDelay: ctx.Config.Holdoff.MaxDelay,
 		Observer:        dag.ComposeObservers(append(xdscache.ObserversOf(resources), snapshotHandler)...),
-		Builder:         getDAGBuilder(ctx, clients, clientCert, fallbackCert, log),
+		Builder:         getDAGBuilder(ctx, clients, clientCert, fallbackCert, shardSelector, wasmFetcher, log),
+		Metrics:         contourMetrics,
+		Freshness:       freshness,
 		FieldLogger:     log.WithField("context", "contourEventHandler"),
 	}
 
+	// Rebuild the DAG once an image has been pulled, so that
+	// the virtual hosts that use the module pick it up.
+	wasmFetcher.OnFetch = eventHandler.UpdateNow
+
 	// Wrap eventHandler in a converter for objects from the dynamic client.
 	// and an EventRecorder which tracks API server events.
 	dynamicHandler := k8s.DynamicClientHandler{
@@ -392,14 +491,21 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		Logger:    log.WithField("context", "dynamicHandler"),
 	}
 
-	// Inform on DefaultResources.
+	// Inform on DefaultResources, filtering HTTPProxies and
+	// Services by the configured watch parameters.
 	for _, r := range k8s.DefaultResources() {
 		inf, err := clients.InformerForResource(r)
 		if err != nil {
 			log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
 		}
 
-		inf.AddEventHandler(&dynamicHandler)
+		var handler cache.ResourceEventHandler = &dynamicHandler
+		switch r {
+		case contour_api_v1.HTTPProxyGVR, corev1.SchemeGroupVersion.WithResource("services"):
+			handler = watchFilter(ctx.Config.Watch, fallbackCert, clientCert, handler)
+		}
+
+		inf.AddEventHandler(handler)
 	}
 
 	for _, r := range k8s.IngressV1Resources() {
@@ -408,6 +514,43 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		}
 	}
 
+	// Only inform on Knative Ingresses if Knative Serving is installed.
+	if clients.ResourcesExist(k8s.KnativeIngressResources()...) {
+		for _, r := range k8s.KnativeIngressResources() {
+			if err := informOnResource(clients, r, &dynamicHandler); err != nil {
+				log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+			}
+		}
+	}
+
+	// Only inform on ServiceImports if the Multi-Cluster Services API is installed.
+	serviceImportsExist := clients.ResourcesExist(k8s.ServiceImportResources()...)
+	if serviceImportsExist {
+		for _, r := range k8s.ServiceImportResources() {
+			if err := informOnResource(clients, r, &dynamicHandler); err != nil {
+				log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+			}
+		}
+	}
+
+	// Only inform on ContourPolicies if their CRD is installed, so that
+	// Contour can be upgraded before the CRD is applied.
+	if clients.ResourcesExist(k8s.ContourPolicyResources()...) {
+		for _, r := range k8s.ContourPolicyResources() {
+			if err := informOnResource(clients, r, &dynamicHandler); err != nil {
+				log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+			}
+		}
+	}
+
+	// Inform on the resources read by registered DAG processors.
+	// Their objects are not converted, so bypass the converter.
+	for _, r := range dag.RegisteredResources() {
+		if err := informOnResource(clients, r, dynamicHandler.Next); err != nil {
+			log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+		}
+	}
+
 	// Set up workgroup runner and register informers.
 	var g workgroup.Group
 
@@ -450,6 +593,11 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 				log.WithError(err).Fatal("failed to create tlsroute-controller")
 			}
 
+			// Create and register the NewTCPRouteController controller with the manager.
+			if _, err := controller.NewTCPRouteController(mgr, &dynamicHandler, log.WithField("context", "tcproute-controller")); err != nil {
+				log.WithError(err).Fatal("failed to create tcproute-controller")
+			}
+
 			// Inform on Namespaces.
 			if err := informOnResource(clients, k8s.NamespacesResource(), &dynamicHandler); err != nil {
 				log.WithError(err).WithField("resource", k8s.NamespacesResource()).Fatal("failed to create informer")
@@ -464,6 +612,38 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		}
 	}
 
+	// Inform on Namespaces to match their labels against the permitInsecure
+	// selector, unless they are already informed on for the Gateway API.
+	if ctx.Config.PermitInsecure.Selector != "" && ctx.Config.GatewayConfig == nil {
+		if err := informOnResource(clients, k8s.NamespacesResource(), &dynamicHandler); err != nil {
+			log.WithError(err).WithField("resource", k8s.NamespacesResource()).Fatal("failed to create informer")
+		}
+	}
+
+	// Only inform on WasmModules if their CRD is installed, so that
+	// Contour can be upgraded before the CRD is applied.
+	wasmModulesExist := clients.ResourcesExist(k8s.WasmModuleResources()...)
+	if wasmModulesExist {
+		for _, r := range k8s.WasmModuleResources() {
+			if err := informOnResource(clients, r, &dynamicHandler); err != nil {
+				log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+			}
+		}
+	}
+
+	// Inform on ConfigMaps that may hold Lua scripts or Wasm modules.
+	// WasmModules may be in any namespace, otherwise only the namespaces
+	// that are permitted to use Lua scripts are informed on.
+	if wasmModulesExist || len(ctx.Config.Lua.Namespaces) > 0 {
+		var handler cache.ResourceEventHandler = &dynamicHandler
+		if !wasmModulesExist {
+			handler = k8s.NewNamespaceFilter(ctx.Config.Lua.Namespaces, &dynamicHandler)
+		}
+		if err := informOnResource(clients, k8s.ConfigMapsResource(), handler); err != nil {
+			log.WithError(err).WithField("resource", k8s.ConfigMapsResource()).Fatal("failed to create informer")
+		}
+	}
+
 	// Inform on secrets, filtering by root namespaces.
 	for _, r := range k8s.SecretsResources() {
 		var handler cache.ResourceEventHandler = &dynamicHandler
@@ -473,6 +653,22 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			handler = k8s.NewNamespaceFilter(informerNamespaces, &dynamicHandler)
 		}
 
+		handler = watchFilter(ctx.Config.Watch, fallbackCert, clientCert, handler)
+
+		// The fallback and client certificates are read whatever
+		// their labels, so they have informers of their own.
+		if certs := certificateNames(fallbackCert, clientCert); ctx.Config.Watch.LabelSelector != "" && len(certs) > 0 {
+			handler = k8s.NewIgnoreFilter(certs, handler)
+
+			for _, cert := range certs {
+				inf, err := clients.InformerForObject(r, cert)
+				if err != nil {
+					log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+				}
+				inf.AddEventHandler(&dynamicHandler)
+			}
+		}
+
 		if err := informOnResource(clients, r, handler); err != nil {
 			log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
 		}
@@ -492,6 +688,52 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		}
 	}
 
+	// Inform on pods, so that the endpoints of headless services
+	// can be selected by pod labels.
+	for _, r := range k8s.PodResources() {
+		if err := informOnResource(clients, r, &k8s.DynamicClientHandler{
+			Next: &contour.EventRecorder{
+				Next:    endpointHandler,
+				Counter: contourMetrics.EventHandlerOperations,
+			},
+			Converter: converter,
+			Logger:    log.WithField("context", "endpointstranslator"),
+		}); err != nil {
+			log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+		}
+	}
+
+	// Inform on nodes, so that endpoints can be
+	// assigned the locality of the node they run on.
+	for _, r := range k8s.NodeResources() {
+		if err := informOnResource(clients, r, &k8s.DynamicClientHandler{
+			Next: &contour.EventRecorder{
+				Next:    endpointHandler,
+				Counter: contourMetrics.EventHandlerOperations,
+			},
+			Converter: converter,
+			Logger:    log.WithField("context", "endpointstranslator"),
+		}); err != nil {
+			log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+		}
+	}
+
+	// Inform on the EndpointSlices of imported services.
+	if serviceImportsExist {
+		for _, r := range k8s.EndpointSliceResources() {
+			if err := informOnResource(clients, r, &k8s.DynamicClientHandler{
+				Next: &contour.EventRecorder{
+					Next:    endpointHandler,
+					Counter: contourMetrics.EventHandlerOperations,
+				},
+				Converter: converter,
+				Logger:    log.WithField("context", "endpointstranslator"),
+			}); err != nil {
+				log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+			}
+		}
+	}
+
 	// Register a task to start all the informers.
 	g.AddContext(func(taskCtx context.Context) error {
 		log := log.WithField("context", "informers")
@@ -510,6 +752,26 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	// Register our event handler with the workgroup.
 	g.Add(eventHandler.Start())
 
+	// The removal guard is added to the observer stack once
+	// leader election is set up. Its limit can be changed
+	// by the configuration reloader.
+	removalGuard := &contour.RemovalGuard{
+		MaxRemovalPercent: ctx.Config.MaxRemovalPercent,
+		Metrics:           contourMetrics,
+		Freshness:         freshness,
+		FieldLogger:       log.WithField("context", "removalGuard"),
+	}
+
+	// Apply changes to the configuration file without restarting,
+	// if enabled.
+	if ctx.configPath != "" && ctx.configReloadInterval > 0 {
+		reloader, err := newConfigReloader(ctx.configPath, ctx.configReloadInterval, eventHandler, listenerCache, removalGuard, log.WithField("context", "config-reloader"))
+		if err != nil {
+			return fmt.Errorf("error reading configuration file: %w", err)
+		}
+		g.Add(reloader.Start)
+	}
+
 	// Create metrics service and register with workgroup.
 	metricsvc := httpsvc.Service{
 		Addr:        ctx.metricsAddr,
@@ -524,6 +786,7 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		h := health.Handler(clients.ClientSet())
 		metricsvc.ServeMux.Handle("/health", h)
 		metricsvc.ServeMux.Handle("/healthz", h)
+		metricsvc.ServeMux.Handle("/readyz", health.ReadinessHandler(freshness, ctx.readinessStalenessThreshold))
 	}
 
 	g.Add(metricsvc.Start)
@@ -539,6 +802,7 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		h := health.Handler(clients.ClientSet())
 		healthsvc.ServeMux.Handle("/health", h)
 		healthsvc.ServeMux.Handle("/healthz", h)
+		healthsvc.ServeMux.Handle("/readyz", health.ReadinessHandler(freshness, ctx.readinessStalenessThreshold))
 
 		g.Add(healthsvc.Start)
 	}
@@ -562,18 +826,35 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	}
 
 	// Once we have the leadership detection channel, we can
-	// push DAG rebuild metrics onto the observer stack.
-	eventHandler.Observer = &contour.RebuildMetricsObserver{
-		Metrics:      contourMetrics,
-		IsLeader:     eventHandler.IsLeader,
-		NextObserver: eventHandler.Observer,
+	// push DAG rebuild metrics onto the observer stack. DAG
+	// rebuilds that remove too much are stopped before they
+	// are counted or reach the xDS caches, and the changes
+	// made by the rest are audited.
+	removalGuard.NextObserver = &contour.RebuildMetricsObserver{
+		Metrics:  contourMetrics,
+		IsLeader: eventHandler.IsLeader,
+		NextObserver: &contour.AuditObserver{
+			FieldLogger:  log.WithField("context", "audit"),
+			Recorder:     auditRecorder(ctx, clients),
+			IsLeader:     eventHandler.IsLeader,
+			NextObserver: eventHandler.Observer,
+		},
 	}
+	eventHandler.Observer = removalGuard
 
 	sh := k8s.StatusUpdateHandler{
 		Log:           log.WithField("context", "StatusUpdateHandler"),
 		Clients:       clients,
 		LeaderElected: eventHandler.IsLeader,
 		Converter:     converter,
+		Metrics:       contourMetrics,
+	}
+	if qps := ctx.Config.StatusUpdates.QPS; qps > 0 {
+		burst := ctx.Config.StatusUpdates.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		sh.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
 	}
 	g.Add(sh.Start)
 
@@ -583,13 +864,15 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 
 	// Set up ingress load balancer status writer.
 	lbsw := loadBalancerStatusWriter{
-		log:              log.WithField("context", "loadBalancerStatusWriter"),
-		clients:          clients,
-		isLeader:         eventHandler.IsLeader,
-		lbStatus:         make(chan corev1.LoadBalancerStatus, 1),
-		ingressClassName: ctx.ingressClassName,
-		statusUpdater:    sh.Writer(),
-		Converter:        converter,
+		log:               log.WithField("context", "loadBalancerStatusWriter"),
+		clients:           clients,
+		isLeader:          eventHandler.IsLeader,
+		lbStatus:          make(chan corev1.LoadBalancerStatus, 1),
+		ingressClassNames: ctx.ingressClassNames(),
+		statusUpdater:     sh.Writer(),
+		Converter:         converter,
+		publishHostnames:  ctx.Config.PublishHostnameAnnotation,
+		shard:             shardSelector,
 	}
 	g.Add(lbsw.Start)
 
@@ -611,13 +894,20 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		for _, r := range k8s.ServicesResources() {
 			var handler cache.ResourceEventHandler = &dynamicServiceHandler
 
-			if ctx.Config.EnvoyServiceNamespace != "" {
-				handler = k8s.NewNamespaceFilter([]string{ctx.Config.EnvoyServiceNamespace}, handler)
+			if ctx.Config.EnvoyServiceNamespace == "" {
+				if err := informOnResource(clients, r, handler); err != nil {
+					log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
+				}
+				continue
 			}
 
-			if err := informOnResource(clients, r, handler); err != nil {
+			// The Envoy service is watched even if it is
+			// outside the configured watch scope.
+			inf, err := clients.NamespacedInformerForResource(r, ctx.Config.EnvoyServiceNamespace)
+			if err != nil {
 				log.WithError(err).WithField("resource", r).Fatal("failed to create informer")
 			}
+			inf.AddEventHandler(k8s.NewNamespaceFilter([]string{ctx.Config.EnvoyServiceNamespace}, handler))
 		}
 
 		log.WithField("envoy-service-name", ctx.Config.EnvoyServiceName).
@@ -636,25 +926,68 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 
 		grpcServer := xds.NewServer(registry, ctx.grpcOptions(log)...)
 
+		// Envoy reaches the GeoIP server through its
+		// xDS cluster, so it shares the xDS server.
+		if geoIPServer != nil {
+			geoIPServer.Register(grpcServer)
+		}
+
+		// authorizer restricts the Envoy nodes that may stream
+		// configuration, if any are configured.
+		var authorizer contour_xds_v3.NodeAuthorizer
+		if len(ctx.Config.Server.Nodes) > 0 {
+			authorizer = nodeAllowlist(ctx.Config.Server.Nodes)
+		}
+
 		switch ctx.Config.Server.XDSServerType {
 		case config.EnvoyServerType:
 			v3cache := contour_xds_v3.NewSnapshotCache(false, log)
 			snapshotHandler.AddSnapshotter(v3cache)
-			contour_xds_v3.RegisterServer(envoy_server_v3.NewServer(taskCtx, v3cache, contour_xds_v3.NewRequestLoggingCallbacks(log)), grpcServer)
+			callbacks := contour_xds_v3.NewRequestLoggingCallbacks(log)
+			if authorizer != nil {
+				callbacks = contour_xds_v3.NewAuthorizingCallbacks(log, authorizer)
+			}
+			contour_xds_v3.RegisterServer(envoy_server_v3.NewServer(taskCtx, v3cache, callbacks), grpcServer)
 		case config.ContourServerType:
-			contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, xdscache.ResourcesOf(resources)...), grpcServer)
+			xdsResources := xdscache.ResourcesOf(resources)
+			if virtualHostCache != nil {
+				xdsResources = append(xdsResources, virtualHostCache)
+			}
+			contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, authorizer, xdsResources...), grpcServer)
 		default:
 			// This can't happen due to config validation.
 			log.Fatalf("invalid xDS server type %q", ctx.Config.Server.XDSServerType)
 		}
 
-		addr := net.JoinHostPort(ctx.xdsAddr, strconv.Itoa(ctx.xdsPort))
-		l, err := net.Listen("tcp", addr)
-		if err != nil {
-			return err
+		addrs := ctx.xdsAddresses()
+		if len(addrs) == 0 && ctx.xdsSocket == "" {
+			return errors.New("no xDS address or socket to listen on")
 		}
 
-		log = log.WithField("address", addr)
+		var listeners []net.Listener
+		for _, addr := range addrs {
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			listeners = append(listeners, l)
+		}
+
+		if ctx.xdsSocket != "" {
+			// Remove the socket left behind by a previous run,
+			// otherwise the socket cannot be bound.
+			if err := os.Remove(ctx.xdsSocket); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			l, err := net.Listen("unix", ctx.xdsSocket)
+			if err != nil {
+				return err
+			}
+			listeners = append(listeners, l)
+			log = log.WithField("socket", ctx.xdsSocket)
+		}
+
+		log = log.WithField("address", strings.Join(addrs, ","))
 		if ctx.PermitInsecureGRPC {
 			log = log.WithField("insecure", true)
 		}
@@ -672,7 +1005,14 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			grpcServer.Stop()
 		}()
 
-		return grpcServer.Serve(l)
+		errs := make(chan error, len(listeners))
+		for _, l := range listeners {
+			go func(l net.Listener) {
+				errs <- grpcServer.Serve(l)
+			}(l)
+		}
+
+		return <-errs
 	})
 
 	// Set up SIGTERM handler for graceful shutdown.
@@ -692,7 +1032,7 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	return g.Run(context.Background())
 }
 
-func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallbackCert *types.NamespacedName, log logrus.FieldLogger) dag.Builder {
+func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallbackCert *types.NamespacedName, shardSelector *shard.Selector, wasmFetcher dag.WasmImageFetcher, log logrus.FieldLogger) dag.Builder {
 	var requestHeadersPolicy dag.HeadersPolicy
 	if ctx.Config.Policy.RequestHeadersPolicy.Set != nil {
 		requestHeadersPolicy.Set = make(map[string]string)
@@ -717,6 +1057,21 @@ func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallback
 		responseHeadersPolicy.Remove = append(responseHeadersPolicy.Remove, ctx.Config.Policy.ResponseHeadersPolicy.Remove...)
 	}
 
+	var spiffe *dag.SPIFFEIdentity
+	if ctx.Config.TLS.SPIFFE.ID != "" || ctx.Config.TLS.SPIFFE.TrustDomain != "" {
+		spiffe = &dag.SPIFFEIdentity{
+			ID:          ctx.Config.TLS.SPIFFE.ID,
+			TrustDomain: ctx.Config.TLS.SPIFFE.TrustDomain,
+		}
+	}
+
+	// The permitInsecure selector is checked when the configuration
+	// is validated, so the error is ignored.
+	var permitInsecureSelector labels.Selector
+	if ctx.Config.PermitInsecure.Selector != "" {
+		permitInsecureSelector, _ = labels.Parse(ctx.Config.PermitInsecure.Selector)
+	}
+
 	log.Debugf("EnableExternalNameService is set to %t", ctx.Config.EnableExternalNameService)
 	// Get the appropriate DAG processors.
 	dagProcessors := []dag.Processor{
@@ -724,6 +1079,7 @@ func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallback
 			EnableExternalNameService: ctx.Config.EnableExternalNameService,
 			FieldLogger:               log.WithField("context", "IngressProcessor"),
 			ClientCertificate:         clientCert,
+			SPIFFE:                    spiffe,
 		},
 		&dag.ExtensionServiceProcessor{
 			// Note that ExtensionService does not support ExternalName, if it does get added,
@@ -733,12 +1089,36 @@ func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallback
 		},
 		&dag.HTTPProxyProcessor{
 			EnableExternalNameService: ctx.Config.EnableExternalNameService,
+			EnableDynamicForwardProxy: ctx.Config.EnableDynamicForwardProxy,
+			EnableGeoIPCountry:        ctx.Config.GeoIP.CountryDatabase != "",
+			EnableGeoIPASN:            ctx.Config.GeoIP.ASNDatabase != "",
 			DisablePermitInsecure:     ctx.Config.DisablePermitInsecure,
+			PermitInsecureNamespaces:  ctx.Config.PermitInsecure.Namespaces,
+			PermitInsecureSelector:    permitInsecureSelector,
+			LuaNamespaces:             ctx.Config.Lua.Namespaces,
+			TapNamespaces:             ctx.Config.Tap.Namespaces,
+			TapFileDirectory:          ctx.Config.Tap.FileDirectory,
+			WasmImageFetcher:          wasmFetcher,
 			FallbackCertificate:       fallbackCert,
+			DefaultHTTPProxy:          namespacedNameOf(ctx.Config.DefaultHTTPProxy),
 			DNSLookupFamily:           ctx.Config.Cluster.DNSLookupFamily,
 			ClientCertificate:         clientCert,
+			SPIFFE:                    spiffe,
 			RequestHeadersPolicy:      &requestHeadersPolicy,
 			ResponseHeadersPolicy:     &responseHeadersPolicy,
+			DefaultRetryPolicy:        defaultRetryPolicy(ctx.Config.Policy.RetryPolicy),
+			DefaultTimeoutPolicy:      defaultTimeoutPolicy(ctx.Config.Policy.TimeoutPolicy),
+			TCPListeners:              tcpListenerPorts(ctx.Config.Listener.TCPListeners),
+			HTTPListeners:             httpListenerNames(ctx.Config.Listener.HTTPListeners),
+			HTTPSListeners:            httpListenerNames(ctx.Config.Listener.HTTPSListeners),
+			Workers:                   ctx.Config.HTTPProxyWorkers,
+			MaxIncludeDepth:           ctx.Config.MaxIncludeDepth,
+			DefaultNamespaceQuota:     namespaceQuota(ctx.Config.Quotas.Default),
+			NamespaceQuotas:           namespaceQuotas(ctx.Config.Quotas.Namespaces),
+			PartialValidity:           ctx.Config.HTTPProxyPartialValidity,
+		},
+		&dag.TLSCertificateDelegationProcessor{
+			FieldLogger: log.WithField("context", "TLSCertificateDelegationProcessor"),
 		},
 	}
 
@@ -746,12 +1126,25 @@ func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallback
 		dagProcessors = append(dagProcessors, &dag.GatewayAPIProcessor{
 			EnableExternalNameService: ctx.Config.EnableExternalNameService,
 			FieldLogger:               log.WithField("context", "GatewayAPIProcessor"),
+			TCPListeners:              tcpListenerPorts(ctx.Config.Listener.TCPListeners),
+		})
+	}
+
+	if clients.ResourcesExist(k8s.KnativeIngressResources()...) {
+		dagProcessors = append(dagProcessors, &dag.KnativeIngressProcessor{
+			EnableExternalNameService: ctx.Config.EnableExternalNameService,
+			FieldLogger:               log.WithField("context", "KnativeIngressProcessor"),
+			ClientCertificate:         clientCert,
+			SPIFFE:                    spiffe,
+			LoadBalancerDomain:        fmt.Sprintf("%s.%s.svc.cluster.local", ctx.Config.EnvoyServiceName, ctx.Config.EnvoyServiceNamespace),
 		})
 	}
 
 	// The listener processor has to go last since it looks at
 	// the output of the other processors.
-	dagProcessors = append(dagProcessors, &dag.ListenerProcessor{})
+	dagProcessors = append(dagProcessors, &dag.ListenerProcessor{
+		InsecureListener: ctx.Config.Listener.InsecureListener,
+	})
 
 	var configuredSecretRefs []*types.NamespacedName
 	if fallbackCert != nil {
@@ -764,13 +1157,18 @@ func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallback
 	builder := dag.Builder{
 		Source: dag.KubernetesCache{
 			RootNamespaces:       ctx.proxyRootNamespaces(),
-			IngressClassName:     ctx.ingressClassName,
+			IngressClassNames:    ctx.ingressClassNames(),
 			ConfiguredSecretRefs: configuredSecretRefs,
+			Shard:                shardSelector,
 			FieldLogger:          log.WithField("context", "KubernetesCache"),
 		},
 		Processors: dagProcessors,
 	}
 
+	for _, p := range dag.RegisteredProcessors() {
+		builder.RegisterProcessor(p)
+	}
+
 	if ctx.Config.GatewayConfig != nil {
 
 		// Log warning that the Name/Namespace fields in the configuration file are deprecated.
@@ -790,6 +1188,46 @@ func getDAGBuilder(ctx *serveContext, clients *k8s.Clients, clientCert, fallback
 	return builder
 }
 
+// auditRecorder returns an EventRecorder for the configuration
+// change audit trail, or nil if audit events are not enabled.
+func auditRecorder(ctx *serveContext, clients *k8s.Clients) record.EventRecorder {
+	if !ctx.Config.AuditEvents {
+		return nil
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: clients.ClientSet().CoreV1().Events(""),
+	})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "contour"})
+}
+
+// newGeoIPServer returns the server that looks up client addresses in
+// the configured GeoIP databases, or nil if none are configured.
+func newGeoIPServer(p config.GeoIPParameters, log logrus.FieldLogger) (*geoip.Server, error) {
+	if p.CountryDatabase == "" && p.ASNDatabase == "" {
+		return nil, nil
+	}
+
+	s := &geoip.Server{FieldLogger: log}
+
+	var err error
+	if p.CountryDatabase != "" {
+		if s.Country, err = geoip.Open(p.CountryDatabase); err != nil {
+			return nil, fmt.Errorf("error opening GeoIP country database: %w", err)
+		}
+		log.WithField("database", s.Country.Type).Infof("looking up countries in %s", p.CountryDatabase)
+	}
+	if p.ASNDatabase != "" {
+		if s.ASN, err = geoip.Open(p.ASNDatabase); err != nil {
+			return nil, fmt.Errorf("error opening GeoIP ASN database: %w", err)
+		}
+		log.WithField("database", s.ASN.Type).Infof("looking up autonomous systems in %s", p.ASNDatabase)
+	}
+
+	return s, nil
+}
+
 func contains(namespaces []string, ns string) bool {
 	for _, namespace := range namespaces {
 		if ns == namespace {
@@ -799,6 +1237,53 @@ func contains(namespaces []string, ns string) bool {
 	return false
 }
 
+// watchFilter wraps next in a filter for the namespaces and labels
+// configured by the watch parameters, if any are configured. The
+// namespaces of the fallback and client certificates are always
+// watched.
+func watchFilter(watch config.WatchParameters, fallbackCert, clientCert *types.NamespacedName, next cache.ResourceEventHandler) cache.ResourceEventHandler {
+	if !watchScoped(watch) {
+		return next
+	}
+
+	// The label selector is checked when the configuration is validated.
+	selector, _ := labels.Parse(watch.LabelSelector)
+	return k8s.NewWatchFilter(watchNamespaces(watch, fallbackCert, clientCert), watch.ExcludeNamespaces, selector, next)
+}
+
+// certificateNames returns the names of the given certificates
+// that are configured.
+func certificateNames(certs ...*types.NamespacedName) []types.NamespacedName {
+	var names []types.NamespacedName
+	for _, cert := range certs {
+		if cert != nil {
+			names = append(names, *cert)
+		}
+	}
+	return names
+}
+
+// watchScoped returns true if the watch parameters restrict the
+// objects that are watched.
+func watchScoped(watch config.WatchParameters) bool {
+	return len(watch.Namespaces) > 0 || len(watch.ExcludeNamespaces) > 0 || watch.LabelSelector != ""
+}
+
+// watchNamespaces returns the namespaces configured by the watch
+// parameters, and the namespaces of the fallback and client
+// certificates, or nil if all namespaces are watched.
+func watchNamespaces(watch config.WatchParameters, fallbackCert, clientCert *types.NamespacedName) []string {
+	namespaces := append([]string(nil), watch.Namespaces...)
+	if len(namespaces) > 0 {
+		for _, cert := range []*types.NamespacedName{fallbackCert, clientCert} {
+			if cert != nil && !contains(namespaces, cert.Namespace) {
+				namespaces = append(namespaces, cert.Namespace)
+			}
+		}
+	}
+	return namespaces
+}
+
 func informOnResource(clients *k8s.Clients, gvr schema.GroupVersionResource, handler cache.ResourceEventHandler) error {
 	inf, err := clients.InformerForResource(gvr)
 	if err != nil {
@@ -37,7 +37,7 @@ func TestGetDAGBuilder(t *testing.T) {
 	}
 
 	t.Run("all default options", func(t *testing.T) {
-		got := getDAGBuilder(newServeContext(), nil, nil, nil, logrus.StandardLogger())
+		got := getDAGBuilder(newServeContext(), nil, nil, nil, nil, nil, logrus.StandardLogger())
 		commonAssertions(t, &got)
 		assert.Empty(t, got.Source.ConfiguredSecretRefs)
 	})
@@ -45,7 +45,7 @@ func TestGetDAGBuilder(t *testing.T) {
 	t.Run("client cert specified", func(t *testing.T) {
 		clientCert := &types.NamespacedName{Namespace: "client-ns", Name: "client-name"}
 
-		got := getDAGBuilder(newServeContext(), nil, clientCert, nil, logrus.StandardLogger())
+		got := getDAGBuilder(newServeContext(), nil, clientCert, nil, nil, nil, logrus.StandardLogger())
 		commonAssertions(t, &got)
 		assert.ElementsMatch(t, got.Source.ConfiguredSecretRefs, []*types.NamespacedName{clientCert})
 	})
@@ -53,7 +53,7 @@ func TestGetDAGBuilder(t *testing.T) {
 	t.Run("fallback cert specified", func(t *testing.T) {
 		fallbackCert := &types.NamespacedName{Namespace: "fallback-ns", Name: "fallback-name"}
 
-		got := getDAGBuilder(newServeContext(), nil, nil, fallbackCert, logrus.StandardLogger())
+		got := getDAGBuilder(newServeContext(), nil, nil, fallbackCert, nil, nil, logrus.StandardLogger())
 		commonAssertions(t, &got)
 		assert.ElementsMatch(t, got.Source.ConfiguredSecretRefs, []*types.NamespacedName{fallbackCert})
 	})
@@ -62,7 +62,7 @@ func TestGetDAGBuilder(t *testing.T) {
 		clientCert := &types.NamespacedName{Namespace: "client-ns", Name: "client-name"}
 		fallbackCert := &types.NamespacedName{Namespace: "fallback-ns", Name: "fallback-name"}
 
-		got := getDAGBuilder(newServeContext(), nil, clientCert, fallbackCert, logrus.StandardLogger())
+		got := getDAGBuilder(newServeContext(), nil, clientCert, fallbackCert, nil, nil, logrus.StandardLogger())
 
 		commonAssertions(t, &got)
 		assert.ElementsMatch(t, got.Source.ConfiguredSecretRefs, []*types.NamespacedName{clientCert, fallbackCert})
@@ -81,7 +81,7 @@ func TestGetDAGBuilder(t *testing.T) {
 		}
 		ctx.Config.Policy.ResponseHeadersPolicy.Remove = []string{"res-remove-key-1", "res-remove-key-2"}
 
-		got := getDAGBuilder(ctx, nil, nil, nil, logrus.StandardLogger())
+		got := getDAGBuilder(ctx, nil, nil, nil, nil, nil, logrus.StandardLogger())
 		commonAssertions(t, &got)
 
 		httpProxyProcessor := mustGetHTTPProxyProcessor(t, &got)
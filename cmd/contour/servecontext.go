@@ -20,15 +20,23 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/k8s"
+	contour_xds_v3 "github.com/projectcontour/contour/internal/xds/v3"
 	xdscache_v3 "github.com/projectcontour/contour/internal/xdscache/v3"
 	"github.com/projectcontour/contour/pkg/config"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/local"
 	"google.golang.org/grpc/keepalive"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -36,6 +44,14 @@ import (
 type serveContext struct {
 	Config config.Parameters
 
+	// configPath is the path of the configuration file, if any.
+	configPath string
+
+	// configReloadInterval is how often the configuration file is
+	// checked for changes to apply without restarting. If zero,
+	// changes take effect when Contour restarts.
+	configReloadInterval time.Duration
+
 	ServerConfig
 
 	// Enable Kubernetes client-go debugging.
@@ -53,6 +69,11 @@ type serveContext struct {
 	healthAddr string
 	healthPort int
 
+	// readinessStalenessThreshold is how long the xDS snapshot may
+	// lag behind observed Kubernetes resources before Contour
+	// reports itself as not ready.
+	readinessStalenessThreshold time.Duration
+
 	// httpproxy root namespaces
 	rootNamespaces string
 
@@ -87,23 +108,24 @@ type serveContext struct {
 func newServeContext() *serveContext {
 	// Set defaults for parameters which are then overridden via flags, ENV, or ConfigFile
 	return &serveContext{
-		Config:                config.Defaults(),
-		statsAddr:             "0.0.0.0",
-		statsPort:             8002,
-		debugAddr:             "127.0.0.1",
-		debugPort:             6060,
-		healthAddr:            "0.0.0.0",
-		healthPort:            8000,
-		metricsAddr:           "0.0.0.0",
-		metricsPort:           8000,
-		httpAccessLog:         xdscache_v3.DEFAULT_HTTP_ACCESS_LOG,
-		httpsAccessLog:        xdscache_v3.DEFAULT_HTTPS_ACCESS_LOG,
-		httpAddr:              "0.0.0.0",
-		httpsAddr:             "0.0.0.0",
-		httpPort:              8080,
-		httpsPort:             8443,
-		PermitInsecureGRPC:    false,
-		DisableLeaderElection: false,
+		Config:                      config.Defaults(),
+		statsAddr:                   "0.0.0.0",
+		statsPort:                   8002,
+		debugAddr:                   "127.0.0.1",
+		debugPort:                   6060,
+		healthAddr:                  "0.0.0.0",
+		healthPort:                  8000,
+		readinessStalenessThreshold: 30 * time.Second,
+		metricsAddr:                 "0.0.0.0",
+		metricsPort:                 8000,
+		httpAccessLog:               xdscache_v3.DEFAULT_HTTP_ACCESS_LOG,
+		httpsAccessLog:              xdscache_v3.DEFAULT_HTTPS_ACCESS_LOG,
+		httpAddr:                    "0.0.0.0",
+		httpsAddr:                   "0.0.0.0",
+		httpPort:                    8080,
+		httpsPort:                   8443,
+		PermitInsecureGRPC:          false,
+		DisableLeaderElection:       false,
 		ServerConfig: ServerConfig{
 			xdsAddr: "127.0.0.1",
 			xdsPort: 8001,
@@ -116,11 +138,54 @@ type ServerConfig struct {
 	xdsAddr                         string
 	xdsPort                         int
 	caFile, contourCert, contourKey string
+
+	// xdsBindAddresses are the additional host:port addresses
+	// the xds service listens on.
+	xdsBindAddresses []string
+
+	// xdsSocket is the path of the Unix domain socket the xds
+	// service listens on, if not empty.
+	xdsSocket string
+}
+
+// xdsAddresses returns the TCP addresses the xDS server listens on.
+// Setting the xDS port to zero disables the default address, for
+// example so that xDS is only served over the Unix domain socket.
+func (ctx *serveContext) xdsAddresses() []string {
+	var addrs []string
+	if ctx.xdsPort != 0 {
+		addrs = append(addrs, net.JoinHostPort(ctx.xdsAddr, strconv.Itoa(ctx.xdsPort)))
+	}
+	return append(addrs, ctx.xdsBindAddresses...)
+}
+
+// socketCredentials are the transport credentials of an xDS server
+// that also listens on a Unix domain socket. Connections over the
+// socket, which only processes with access to the socket file can
+// make, use local credentials rather than TLS.
+type socketCredentials struct {
+	credentials.TransportCredentials
+	local credentials.TransportCredentials
+}
+
+func (c *socketCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if conn.LocalAddr().Network() == "unix" {
+		return c.local.ServerHandshake(conn)
+	}
+	return c.TransportCredentials.ServerHandshake(conn)
+}
+
+func (c *socketCredentials) Clone() credentials.TransportCredentials {
+	return &socketCredentials{
+		TransportCredentials: c.TransportCredentials.Clone(),
+		local:                c.local.Clone(),
+	}
 }
 
 // grpcOptions returns a slice of grpc.ServerOptions.
 // if ctx.PermitInsecureGRPC is false, the option set will
-// include TLS configuration.
+// include TLS configuration, unless xDS is only served over
+// the Unix domain socket.
 func (ctx *serveContext) grpcOptions(log logrus.FieldLogger) []grpc.ServerOption {
 	opts := []grpc.ServerOption{
 		// By default the Go grpc library defaults to a value of ~100 streams per
@@ -142,9 +207,15 @@ func (ctx *serveContext) grpcOptions(log logrus.FieldLogger) []grpc.ServerOption
 			Timeout: 20 * time.Second,
 		}),
 	}
-	if !ctx.PermitInsecureGRPC {
+	if !ctx.PermitInsecureGRPC && len(ctx.xdsAddresses()) > 0 {
 		tlsconfig := ctx.tlsconfig(log)
 		creds := credentials.NewTLS(tlsconfig)
+		if ctx.xdsSocket != "" {
+			creds = &socketCredentials{
+				TransportCredentials: creds,
+				local:                local.NewCredentials(),
+			}
+		}
 		opts = append(opts, grpc.Creds(creds))
 	}
 	return opts
@@ -225,6 +296,19 @@ func (ctx *serveContext) proxyRootNamespaces() []string {
 	return ns
 }
 
+// ingressClassNames returns the ingress class names configured with the
+// comma-separated --ingress-class-name flag.
+func (ctx *serveContext) ingressClassNames() []string {
+	if strings.TrimSpace(ctx.ingressClassName) == "" {
+		return nil
+	}
+	var names []string
+	for _, s := range strings.Split(ctx.ingressClassName, ",") {
+		names = append(names, strings.TrimSpace(s))
+	}
+	return names
+}
+
 // parseDefaultHTTPVersions parses a list of supported HTTP versions
 //  (of the form "HTTP/xx") into a slice of unique version constants.
 func parseDefaultHTTPVersions(versions []config.HTTPVersionType) []envoy_v3.HTTPVersionType {
@@ -248,6 +332,162 @@ func parseDefaultHTTPVersions(versions []config.HTTPVersionType) []envoy_v3.HTTP
 	return parsed
 }
 
+// nodeAllowlist returns the authorization policies of the configured
+// Envoy nodes.
+func nodeAllowlist(nodes []config.XDSNodeParameters) contour_xds_v3.NodeAllowlist {
+	allowlist := make(contour_xds_v3.NodeAllowlist, 0, len(nodes))
+	for _, n := range nodes {
+		allowlist = append(allowlist, contour_xds_v3.NodePolicy{
+			ID:               n.ID,
+			SubjectAltNames:  n.SubjectAltNames,
+			SecretNamespaces: n.SecretNamespaces,
+		})
+	}
+	return allowlist
+}
+
+// tcpListeners returns the configured plain TCP listeners keyed by name.
+func tcpListeners(listeners []config.TCPListener) map[string]xdscache_v3.Listener {
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	parsed := make(map[string]xdscache_v3.Listener, len(listeners))
+	for _, l := range listeners {
+		address := l.Address
+		if address == "" {
+			address = xdscache_v3.DEFAULT_HTTP_LISTENER_ADDRESS
+		}
+		parsed[l.Name] = xdscache_v3.Listener{
+			Name:    l.Name,
+			Address: address,
+			Port:    l.Port,
+		}
+	}
+	return parsed
+}
+
+// httpListeners returns the configured additional HTTP or HTTPS
+// listeners, added to the default listener, keyed by name.
+func httpListeners(defaultListener xdscache_v3.Listener, listeners []config.HTTPListener) map[string]xdscache_v3.Listener {
+	parsed := map[string]xdscache_v3.Listener{
+		defaultListener.Name: defaultListener,
+	}
+	for _, l := range listeners {
+		address := l.Address
+		if address == "" {
+			address = xdscache_v3.DEFAULT_HTTP_LISTENER_ADDRESS
+		}
+		parsed[l.Name] = xdscache_v3.Listener{
+			Name:    l.Name,
+			Address: address,
+			Port:    l.Port,
+		}
+	}
+	return parsed
+}
+
+// httpListenerNames returns the names of the configured additional
+// HTTP or HTTPS listeners.
+func httpListenerNames(listeners []config.HTTPListener) map[string]bool {
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	names := make(map[string]bool, len(listeners))
+	for _, l := range listeners {
+		names[l.Name] = true
+	}
+	return names
+}
+
+// listenerOverrides returns the configured listener overrides keyed
+// by listener name.
+func listenerOverrides(overrides map[string]config.ListenerOverrides) map[string]xdscache_v3.ListenerOverrides {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	parsed := make(map[string]xdscache_v3.ListenerOverrides, len(overrides))
+	for name, o := range overrides {
+		parsed[name] = xdscache_v3.ListenerOverrides{
+			ConnectionBalancer:     o.ConnectionBalancer,
+			ReusePort:              o.ReusePort,
+			TCPFastOpenQueueLength: o.TCPFastOpenQueueLength,
+			Backlog:                o.Backlog,
+		}
+	}
+	return parsed
+}
+
+// tcpListenerPorts returns the names of the configured plain TCP
+// listeners keyed by port.
+func tcpListenerPorts(listeners []config.TCPListener) map[int]string {
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	ports := make(map[int]string, len(listeners))
+	for _, l := range listeners {
+		ports[l.Port] = l.Name
+	}
+	return ports
+}
+
+// defaultRetryPolicy returns the HTTPProxy retry policy
+// for the configured default retry policy.
+func defaultRetryPolicy(rp *config.RetryPolicy) *contour_api_v1.RetryPolicy {
+	if rp == nil {
+		return nil
+	}
+
+	policy := &contour_api_v1.RetryPolicy{
+		NumRetries:           int64(rp.NumRetries),
+		PerTryTimeout:        rp.PerTryTimeout,
+		RetriableStatusCodes: rp.RetriableStatusCodes,
+	}
+	for _, cond := range rp.RetryOn {
+		policy.RetryOn = append(policy.RetryOn, contour_api_v1.RetryOn(cond))
+	}
+	return policy
+}
+
+// defaultTimeoutPolicy returns the HTTPProxy timeout policy
+// for the configured default timeout policy.
+func defaultTimeoutPolicy(tp *config.TimeoutPolicy) *contour_api_v1.TimeoutPolicy {
+	if tp == nil {
+		return nil
+	}
+
+	return &contour_api_v1.TimeoutPolicy{
+		Response: tp.Response,
+		Idle:     tp.Idle,
+	}
+}
+
+// namespaceQuota returns the DAG namespace quota for the configured limits.
+func namespaceQuota(limits config.QuotaLimits) dag.NamespaceQuota {
+	return dag.NamespaceQuota{
+		VirtualHosts: limits.VirtualHosts,
+		Routes:       limits.Routes,
+		Includes:     limits.Includes,
+	}
+}
+
+// namespaceQuotas returns the DAG namespace quotas
+// for the configured limits of each namespace.
+func namespaceQuotas(namespaces map[string]config.QuotaLimits) map[string]dag.NamespaceQuota {
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	quotas := make(map[string]dag.NamespaceQuota, len(namespaces))
+	for ns, limits := range namespaces {
+		quotas[ns] = namespaceQuota(limits)
+	}
+	return quotas
+}
+
 func namespacedNameOf(n config.NamespacedName) *types.NamespacedName {
 	if len(strings.TrimSpace(n.Name)) == 0 && len(strings.TrimSpace(n.Namespace)) == 0 {
 		return nil
@@ -258,3 +498,59 @@ func namespacedNameOf(n config.NamespacedName) *types.NamespacedName {
 		Name:      n.Name,
 	}
 }
+
+// tcpKeepalive converts the TCP keepalive parameters of the config
+// file to their DAG representation.
+func tcpKeepalive(p *config.TCPKeepaliveParameters) *dag.TCPKeepalive {
+	if p == nil {
+		return nil
+	}
+
+	return &dag.TCPKeepalive{
+		Probes:   p.Probes,
+		Time:     p.Time,
+		Interval: p.Interval,
+	}
+}
+
+// zoneAwareRouting converts the zone-aware routing parameters of the
+// config file to their Envoy representation. The result is nil unless
+// zone-aware routing is enabled.
+func zoneAwareRouting(p *config.ZoneAwareRoutingParameters) *envoy_cluster_v3.Cluster_CommonLbConfig_ZoneAwareLbConfig {
+	if p == nil || !p.Enabled {
+		return nil
+	}
+
+	return envoy_v3.ZoneAwareLBConfig(p.MinClusterSize)
+}
+
+// tracingConfig converts the tracing parameters of the config file to
+// their Envoy representation. The result is nil unless tracing is
+// configured.
+func tracingConfig(t *config.TracingConfig) *envoy_v3.TracingConfig {
+	if t == nil {
+		return nil
+	}
+
+	provider := envoy_v3.ZipkinTracing
+	if t.Provider == config.OpenCensusTracingProvider {
+		provider = envoy_v3.OpenCensusTracing
+	}
+
+	var customTags []*envoy_v3.CustomTag
+	for _, tag := range t.CustomTags {
+		customTags = append(customTags, &envoy_v3.CustomTag{
+			TagName:           tag.TagName,
+			Literal:           tag.Literal,
+			RequestHeaderName: tag.RequestHeaderName,
+		})
+	}
+
+	return &envoy_v3.TracingConfig{
+		Provider:           provider,
+		ExtensionService:   k8s.NamespacedNameFrom(t.ExtensionService),
+		CollectorEndpoint:  t.CollectorEndpoint,
+		SamplingPercentage: t.SamplingPercentage,
+		CustomTags:         customTags,
+	}
+}
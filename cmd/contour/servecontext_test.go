@@ -26,8 +26,10 @@ import (
 	"testing"
 	"time"
 
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/fixture"
+	xdscache_v3 "github.com/projectcontour/contour/internal/xdscache/v3"
 	"github.com/projectcontour/contour/pkg/config"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
@@ -80,6 +82,41 @@ func TestServeContextProxyRootNamespaces(t *testing.T) {
 	}
 }
 
+func TestServeContextIngressClassNames(t *testing.T) {
+	tests := map[string]struct {
+		ctx  serveContext
+		want []string
+	}{
+		"empty": {
+			ctx: serveContext{
+				ingressClassName: "",
+			},
+			want: nil,
+		},
+		"one value": {
+			ctx: serveContext{
+				ingressClassName: "contour",
+			},
+			want: []string{"contour"},
+		},
+		"multiple": {
+			ctx: serveContext{
+				ingressClassName: "contour, nginx ,legacy",
+			},
+			want: []string{"contour", "nginx", "legacy"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tc.ctx.ingressClassNames()
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
 func TestServeContextTLSParams(t *testing.T) {
 	tests := map[string]struct {
 		ctx         serveContext
@@ -120,6 +157,21 @@ func TestServeContextTLSParams(t *testing.T) {
 	}
 }
 
+func TestServeContextXDSAddresses(t *testing.T) {
+	ctx := newServeContext()
+	assert.Equal(t, []string{"127.0.0.1:8001"}, ctx.xdsAddresses())
+
+	ctx.xdsBindAddresses = []string{"10.0.0.1:8001", "[::1]:8001"}
+	assert.Equal(t, []string{"127.0.0.1:8001", "10.0.0.1:8001", "[::1]:8001"}, ctx.xdsAddresses())
+
+	// A zero port only serves the additional addresses.
+	ctx.xdsPort = 0
+	assert.Equal(t, []string{"10.0.0.1:8001", "[::1]:8001"}, ctx.xdsAddresses())
+
+	ctx.xdsBindAddresses = nil
+	assert.Empty(t, ctx.xdsAddresses())
+}
+
 // Testdata for this test case can be re-generated by running:
 // make gencerts
 // cp certs/*.pem cmd/contour/testdata/X/
@@ -364,3 +416,89 @@ func TestParseHTTPVersions(t *testing.T) {
 		})
 	}
 }
+
+func TestTCPListeners(t *testing.T) {
+	listeners := []config.TCPListener{
+		{Name: "redis", Port: 6379},
+		{Name: "postgres", Address: "127.0.0.1", Port: 5432},
+	}
+
+	assert.Nil(t, tcpListeners(nil))
+	assert.Equal(t, map[string]xdscache_v3.Listener{
+		"redis": {
+			Name:    "redis",
+			Address: "0.0.0.0",
+			Port:    6379,
+		},
+		"postgres": {
+			Name:    "postgres",
+			Address: "127.0.0.1",
+			Port:    5432,
+		},
+	}, tcpListeners(listeners))
+
+	assert.Nil(t, tcpListenerPorts(nil))
+	assert.Equal(t, map[int]string{
+		6379: "redis",
+		5432: "postgres",
+	}, tcpListenerPorts(listeners))
+}
+
+func TestDefaultPolicies(t *testing.T) {
+	assert.Nil(t, defaultRetryPolicy(nil))
+	assert.Equal(t, &contour_api_v1.RetryPolicy{
+		NumRetries:           3,
+		PerTryTimeout:        "250ms",
+		RetryOn:              []contour_api_v1.RetryOn{"gateway-error", "retriable-status-codes"},
+		RetriableStatusCodes: []uint32{503},
+	}, defaultRetryPolicy(&config.RetryPolicy{
+		NumRetries:           3,
+		PerTryTimeout:        "250ms",
+		RetryOn:              []string{"gateway-error", "retriable-status-codes"},
+		RetriableStatusCodes: []uint32{503},
+	}))
+
+	assert.Nil(t, defaultTimeoutPolicy(nil))
+	assert.Equal(t, &contour_api_v1.TimeoutPolicy{
+		Response: "30s",
+		Idle:     "infinity",
+	}, defaultTimeoutPolicy(&config.TimeoutPolicy{
+		Response: "30s",
+		Idle:     "infinity",
+	}))
+}
+
+func TestHTTPListeners(t *testing.T) {
+	defaultListener := xdscache_v3.Listener{
+		Name:    "ingress_https",
+		Address: "0.0.0.0",
+		Port:    8443,
+	}
+	listeners := []config.HTTPListener{
+		{Name: "legacy_https", Port: 8444},
+		{Name: "internal_https", Address: "127.0.0.1", Port: 9443},
+	}
+
+	assert.Equal(t, map[string]xdscache_v3.Listener{
+		"ingress_https": defaultListener,
+	}, httpListeners(defaultListener, nil))
+	assert.Equal(t, map[string]xdscache_v3.Listener{
+		"ingress_https": defaultListener,
+		"legacy_https": {
+			Name:    "legacy_https",
+			Address: "0.0.0.0",
+			Port:    8444,
+		},
+		"internal_https": {
+			Name:    "internal_https",
+			Address: "127.0.0.1",
+			Port:    9443,
+		},
+	}, httpListeners(defaultListener, listeners))
+
+	assert.Nil(t, httpListenerNames(nil))
+	assert.Equal(t, map[string]bool{
+		"legacy_https":   true,
+		"internal_https": true,
+	}, httpListenerNames(listeners))
+}
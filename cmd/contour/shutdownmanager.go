@@ -14,14 +14,20 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
+	"github.com/projectcontour/contour/internal/metrics"
 	xdscache_v3 "github.com/projectcontour/contour/internal/xdscache/v3"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/expfmt"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -41,6 +47,10 @@ const shutdownReadyFile = "/ok"
 // shutdownReadyFile is the default polling interval for the file used in the /shutdown endpoint.
 const shutdownReadyCheckInterval = time.Second * 1
 
+// drainStatusFile is the default file path used to publish the progress of the drain
+// from the shutdown command to the shutdown-manager's /metrics endpoint.
+const drainStatusFile = "/drain-status"
+
 func prometheusLabels() []string {
 	return []string{xdscache_v3.ENVOY_HTTP_LISTENER, xdscache_v3.ENVOY_HTTPS_LISTENER}
 }
@@ -52,6 +62,8 @@ type shutdownmanagerContext struct {
 	shutdownReadyFile string
 	// shutdownReadyCheckInterval is the polling interval for the file used in the /shutdown endpoint
 	shutdownReadyCheckInterval time.Duration
+	// drainStatusFile is the file path the drain progress is read from for the /metrics endpoint
+	drainStatusFile string
 
 	logrus.FieldLogger
 }
@@ -70,18 +82,56 @@ type shutdownContext struct {
 	// that can be open when polling for active connections in Envoy
 	minOpenConnections int
 
+	// maxDrainDuration defines the maximum time to wait for connections to drain,
+	// after which the drain is abandoned. Zero means no limit.
+	maxDrainDuration time.Duration
+
+	// drainListeners defines the listeners that are drained, in order. Each listener
+	// must drain to minOpenConnections before the next one is waited for. If empty,
+	// the open connections of the HTTP and HTTPS listeners are waited for together.
+	drainListeners []string
+
 	// adminPort defines the port for our envoy pod, being configurable through --admin-port flag
 	adminPort int
 
+	// shutdownReadyFile is the file that is written once connections have drained
+	shutdownReadyFile string
+
+	// drainStatusFile is the file the drain progress is written to
+	drainStatusFile string
+
 	logrus.FieldLogger
 }
 
+// drainStatus is the progress of a drain, which is written to the drain status
+// file by the shutdown command, and published as metrics by the shutdown-manager.
+type drainStatus struct {
+	// Started is the time Envoy was told to start draining connections.
+	Started time.Time `json:"started"`
+
+	// Listener is the listener that is currently being drained, if the
+	// listeners are drained in sequence.
+	Listener string `json:"listener,omitempty"`
+
+	// OpenConnections is the number of open connections of each listener
+	// when Envoy was last polled.
+	OpenConnections map[string]int `json:"openConnections"`
+
+	// Complete is true once the drain has finished or been abandoned.
+	Complete bool `json:"complete"`
+
+	// TimedOut is true if the drain was abandoned because it took
+	// longer than the max drain duration.
+	TimedOut bool `json:"timedOut"`
+}
+
 func newShutdownManagerContext() *shutdownmanagerContext {
 	// Set defaults for parameters which are then overridden via flags, ENV, or ConfigFile
 	return &shutdownmanagerContext{
 		httpServePort:              8090,
 		shutdownReadyFile:          shutdownReadyFile,
 		shutdownReadyCheckInterval: shutdownReadyCheckInterval,
+		drainStatusFile:            drainStatusFile,
 	}
 }
 
@@ -91,7 +141,10 @@ func newShutdownContext() *shutdownContext {
 		checkDelay:         60 * time.Second,
 		drainDelay:         0,
 		minOpenConnections: 0,
+		maxDrainDuration:   0,
 		adminPort:          9001,
+		shutdownReadyFile:  shutdownReadyFile,
+		drainStatusFile:    drainStatusFile,
 	}
 }
 
@@ -137,7 +190,9 @@ func (s *shutdownmanagerContext) shutdownReadyHandler(w http.ResponseWriter, r *
 }
 
 // shutdownHandler is called from a pod preStop hook, where it will block pod shutdown
-// until envoy is able to drain connections to below the min-open threshold.
+// until envoy is able to drain connections to below the min-open threshold, or the
+// max drain duration has passed. If drain listeners are configured, each listener
+// is waited for in turn.
 func (s *shutdownContext) shutdownHandler() {
 	s.WithField("context", "shutdownHandler").Infof("waiting %s before draining connections", s.drainDelay)
 	time.Sleep(s.drainDelay)
@@ -166,33 +221,152 @@ func (s *shutdownContext) shutdownHandler() {
 		s.WithField("context", "shutdownHandler").Errorf("error sending envoy healthcheck fail after 4 attempts: %v", err)
 	}
 
+	status := &drainStatus{
+		Started:         time.Now(),
+		OpenConnections: map[string]int{},
+	}
+	s.writeDrainStatus(status)
+
+	var deadline time.Time
+	if s.maxDrainDuration > 0 {
+		deadline = status.Started.Add(s.maxDrainDuration)
+	}
+
 	s.WithField("context", "shutdownHandler").Infof("waiting %s before polling for draining connections", s.checkDelay)
-	time.Sleep(s.checkDelay)
+	time.Sleep(untilDeadline(s.checkDelay, deadline))
+
+	for _, listeners := range s.drainSequence() {
+		if len(s.drainListeners) > 0 {
+			status.Listener = listeners[0]
+		}
+		if !s.waitForDrain(listeners, status, deadline) {
+			s.WithField("context", "shutdownHandler").
+				WithField("max_drain_duration", s.maxDrainDuration).
+				Warn("connections did not drain within the max drain duration, shutting down")
+			status.TimedOut = true
+			break
+		}
+	}
+
+	status.Listener = ""
+	status.Complete = true
+	s.writeDrainStatus(status)
+
+	file, err := os.Create(s.shutdownReadyFile)
+	if err != nil {
+		s.Error(err)
+		return
+	}
+	defer file.Close()
+}
+
+// drainSequence returns the groups of listeners that are waited for in turn.
+func (s *shutdownContext) drainSequence() [][]string {
+	if len(s.drainListeners) == 0 {
+		return [][]string{prometheusLabels()}
+	}
+
+	var sequence [][]string
+	for _, listener := range s.drainListeners {
+		sequence = append(sequence, []string{listener})
+	}
+	return sequence
+}
+
+// waitForDrain polls Envoy until the listeners have no more than the min number
+// of open connections, recording the open connections in status. It returns
+// false if the deadline passes first.
+func (s *shutdownContext) waitForDrain(listeners []string, status *drainStatus, deadline time.Time) bool {
+	l := s.WithField("context", "shutdownHandler")
+	if status.Listener != "" {
+		l = l.WithField("listener", status.Listener)
+	}
 
 	for {
-		openConnections, err := getOpenConnections(s.adminPort)
+		connections, err := getListenerConnections(s.adminPort)
 		if err != nil {
 			s.Error(err)
 		} else {
+			for _, listener := range s.polledListeners() {
+				status.OpenConnections[listener] = connections[listener]
+			}
+
+			openConnections := 0
+			for _, listener := range listeners {
+				openConnections += connections[listener]
+			}
+
 			if openConnections <= s.minOpenConnections {
-				s.WithField("context", "shutdownHandler").
-					WithField("open_connections", openConnections).
+				l.WithField("open_connections", openConnections).
 					WithField("min_connections", s.minOpenConnections).
-					Info("min number of open connections found, shutting down")
-				file, err := os.Create(shutdownReadyFile)
-				if err != nil {
-					s.Error(err)
-				}
-				defer file.Close()
-				return
+					Info("min number of open connections found")
+				s.writeDrainStatus(status)
+				return true
 			}
-			s.WithField("context", "shutdownHandler").
-				WithField("open_connections", openConnections).
+			l.WithField("open_connections", openConnections).
 				WithField("min_connections", s.minOpenConnections).
 				Info("polled open connections")
 		}
-		time.Sleep(s.checkInterval)
+		s.writeDrainStatus(status)
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(untilDeadline(s.checkInterval, deadline))
+	}
+}
+
+// polledListeners returns the listeners whose open connections are recorded.
+func (s *shutdownContext) polledListeners() []string {
+	if len(s.drainListeners) == 0 {
+		return prometheusLabels()
 	}
+	return s.drainListeners
+}
+
+// writeDrainStatus writes status to the drain status file. The file
+// is replaced atomically so that it is never read partially written.
+func (s *shutdownContext) writeDrainStatus(status *drainStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		s.Error(err)
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.drainStatusFile), filepath.Base(s.drainStatusFile)+"-*")
+	if err != nil {
+		s.WithField("context", "shutdownHandler").Errorf("error writing drain status: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		s.WithField("context", "shutdownHandler").Errorf("error writing drain status: %v", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		s.WithField("context", "shutdownHandler").Errorf("error writing drain status: %v", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), s.drainStatusFile); err != nil {
+		s.WithField("context", "shutdownHandler").Errorf("error writing drain status: %v", err)
+	}
+}
+
+// untilDeadline returns d, or the time left until deadline if that is shorter.
+// A zero deadline means there is no deadline.
+func untilDeadline(d time.Duration, deadline time.Time) time.Duration {
+	if deadline.IsZero() {
+		return d
+	}
+	if left := time.Until(deadline); left < d {
+		if left < 0 {
+			return 0
+		}
+		return left
+	}
+	return d
 }
 
 // shutdownEnvoy sends a POST request to /healthcheck/fail to tell Envoy to start draining connections
@@ -211,55 +385,167 @@ func shutdownEnvoy(adminPort int) error {
 	return nil
 }
 
-// getOpenConnections parses a http request to a prometheus endpoint returning the sum of values found
-func getOpenConnections(adminPort int) (int, error) {
+// getListenerConnections parses a http request to a prometheus endpoint returning the
+// open connections of each listener
+func getListenerConnections(adminPort int) (map[string]int, error) {
 	prometheusURL := fmt.Sprintf(prometheusURLFormat, adminPort)
 	// Make request to Envoy Prometheus endpoint
 	/* #nosec */
 	resp, err := http.Get(prometheusURL)
 	if err != nil {
-		return -1, fmt.Errorf("creating metrics GET request failed: %s", err)
+		return nil, fmt.Errorf("creating metrics GET request failed: %s", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return -1, fmt.Errorf("GET for %q returned HTTP status %s", prometheusURL, resp.Status)
+		return nil, fmt.Errorf("GET for %q returned HTTP status %s", prometheusURL, resp.Status)
 	}
 
 	// Parse Prometheus listener stats for open connections
-	return parseOpenConnections(resp.Body)
+	return parseListenerConnections(resp.Body)
 }
 
 // parseOpenConnections returns the sum of open connections from a Prometheus HTTP request
 func parseOpenConnections(stats io.Reader) (int, error) {
-	var parser expfmt.TextParser
+	connections, err := parseListenerConnections(stats)
+	if err != nil {
+		return -1, err
+	}
+
 	openConnections := 0
+	for _, listener := range prometheusLabels() {
+		openConnections += connections[listener]
+	}
+	return openConnections, nil
+}
+
+// parseListenerConnections returns the open connections of each listener from a Prometheus HTTP request
+func parseListenerConnections(stats io.Reader) (map[string]int, error) {
+	var parser expfmt.TextParser
 
 	if stats == nil {
-		return -1, fmt.Errorf("stats input was nil")
+		return nil, fmt.Errorf("stats input was nil")
 	}
 
 	// Parse Prometheus http response
 	metricFamilies, err := parser.TextToMetricFamilies(stats)
 	if err != nil {
-		return -1, fmt.Errorf("parsing Prometheus text format failed: %v", err)
+		return nil, fmt.Errorf("parsing Prometheus text format failed: %v", err)
 	}
 
 	// Validate stat exists in output
 	if _, ok := metricFamilies[prometheusStat]; !ok {
-		return -1, fmt.Errorf("error finding Prometheus stat %q in the request result", prometheusStat)
+		return nil, fmt.Errorf("error finding Prometheus stat %q in the request result", prometheusStat)
 	}
 
 	// Look up open connections value
-	for _, metrics := range metricFamilies[prometheusStat].Metric {
-		for _, labels := range metrics.Label {
-			for _, item := range prometheusLabels() {
-				if item == labels.GetValue() {
-					openConnections += int(metrics.Gauge.GetValue())
-				}
+	connections := map[string]int{}
+	for _, metric := range metricFamilies[prometheusStat].Metric {
+		for _, labels := range metric.Label {
+			if labels.GetName() == "envoy_http_conn_manager_prefix" {
+				connections[labels.GetValue()] += int(metric.Gauge.GetValue())
 			}
 		}
 	}
-	return openConnections, nil
+	return connections, nil
+}
+
+// readDrainStatus reads the drain status file. It returns nil if no drain has started.
+func readDrainStatus(path string) (*drainStatus, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var status drainStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("parsing drain status %s failed: %v", path, err)
+	}
+	return &status, nil
+}
+
+var (
+	drainOpenConnectionsDesc = prometheus.NewDesc(
+		"contour_envoy_drain_open_connections",
+		"Open connections of each listener when Envoy was last polled while draining.",
+		[]string{"listener"}, nil)
+	drainListenerDesc = prometheus.NewDesc(
+		"contour_envoy_drain_listener_active",
+		"Whether the listener is the one currently being drained.",
+		[]string{"listener"}, nil)
+	drainElapsedDesc = prometheus.NewDesc(
+		"contour_envoy_drain_elapsed_seconds",
+		"Time since Envoy was told to start draining connections.",
+		nil, nil)
+	drainCompleteDesc = prometheus.NewDesc(
+		"contour_envoy_drain_complete",
+		"Whether the drain has finished.",
+		nil, nil)
+	drainTimedOutDesc = prometheus.NewDesc(
+		"contour_envoy_drain_timed_out",
+		"Whether the drain was abandoned after the max drain duration.",
+		nil, nil)
+)
+
+// drainCollector is a prometheus.Collector which publishes the drain status file.
+type drainCollector struct {
+	statusFile string
+	now        func() time.Time
+
+	logrus.FieldLogger
+}
+
+// Describe implements prometheus.Collector.
+func (c *drainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- drainOpenConnectionsDesc
+	ch <- drainListenerDesc
+	ch <- drainElapsedDesc
+	ch <- drainCompleteDesc
+	ch <- drainTimedOutDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *drainCollector) Collect(ch chan<- prometheus.Metric) {
+	status, err := readDrainStatus(c.statusFile)
+	if err != nil {
+		c.WithField("context", "drainCollector").Error(err)
+		return
+	}
+	if status == nil {
+		// No drain has started.
+		return
+	}
+
+	listeners := make([]string, 0, len(status.OpenConnections))
+	for listener := range status.OpenConnections {
+		listeners = append(listeners, listener)
+	}
+	sort.Strings(listeners)
+
+	for _, listener := range listeners {
+		ch <- prometheus.MustNewConstMetric(drainOpenConnectionsDesc, prometheus.GaugeValue,
+			float64(status.OpenConnections[listener]), listener)
+
+		active := 0.0
+		if listener == status.Listener {
+			active = 1
+		}
+		ch <- prometheus.MustNewConstMetric(drainListenerDesc, prometheus.GaugeValue, active, listener)
+	}
+
+	ch <- prometheus.MustNewConstMetric(drainElapsedDesc, prometheus.GaugeValue,
+		c.now().Sub(status.Started).Seconds())
+	ch <- prometheus.MustNewConstMetric(drainCompleteDesc, prometheus.GaugeValue, boolToFloat(status.Complete))
+	ch <- prometheus.MustNewConstMetric(drainTimedOutDesc, prometheus.GaugeValue, boolToFloat(status.TimedOut))
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 func doShutdownManager(config *shutdownmanagerContext) {
@@ -267,8 +553,16 @@ func doShutdownManager(config *shutdownmanagerContext) {
 	config.Info("started envoy shutdown manager")
 	defer config.Info("stopped")
 
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&drainCollector{
+		statusFile:  config.drainStatusFile,
+		now:         time.Now,
+		FieldLogger: config.FieldLogger,
+	})
+
 	http.HandleFunc("/healthz", config.healthzHandler)
 	http.HandleFunc("/shutdown", config.shutdownReadyHandler)
+	http.Handle("/metrics", metrics.Handler(registry))
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", config.httpServePort), nil))
 }
 
@@ -279,6 +573,7 @@ func registerShutdownManager(cmd *kingpin.CmdClause, log logrus.FieldLogger) (*k
 
 	shutdownmgr := cmd.Command("shutdown-manager", "Start envoy shutdown-manager.")
 	shutdownmgr.Flag("serve-port", "Port to serve the http server on.").IntVar(&ctx.httpServePort)
+	shutdownmgr.Flag("drain-status-file", "File the drain progress is read from to serve /metrics.").StringVar(&ctx.drainStatusFile)
 
 	return shutdownmgr, ctx
 }
@@ -294,6 +589,9 @@ func registerShutdown(cmd *kingpin.CmdClause, log logrus.FieldLogger) (*kingpin.
 	shutdown.Flag("check-delay", "Time to wait before polling Envoy for open connections.").Default("60s").DurationVar(&ctx.checkDelay)
 	shutdown.Flag("drain-delay", "Time to wait before draining Envoy connections.").Default("0s").DurationVar(&ctx.drainDelay)
 	shutdown.Flag("min-open-connections", "Min number of open connections when polling Envoy.").IntVar(&ctx.minOpenConnections)
+	shutdown.Flag("max-drain-duration", "Max time to wait for connections to drain, after which Envoy is shut down regardless. Zero means no limit.").Default("0s").DurationVar(&ctx.maxDrainDuration)
+	shutdown.Flag("drain-listener", "Listener to wait for open connections to drain on, in order. May be repeated. Defaults to waiting for the HTTP and HTTPS listeners together.").StringsVar(&ctx.drainListeners)
+	shutdown.Flag("drain-status-file", "File the drain progress is written to.").StringVar(&ctx.drainStatusFile)
 
 	return shutdown, ctx
 }
@@ -18,15 +18,20 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/projectcontour/contour/internal/fixture"
 )
@@ -198,6 +203,197 @@ func TestParseOpenConnections(t *testing.T) {
 	})
 }
 
+func TestParseListenerConnections(t *testing.T) {
+	got, err := parseListenerConnections(strings.NewReader(VALIDBOTH))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"admin":         0,
+		"ingress_http":  4,
+		"ingress_https": 4,
+	}, got)
+
+	_, err = parseListenerConnections(strings.NewReader(MISSING_STATS))
+	assert.Error(t, err)
+}
+
+// fakeEnvoyAdmin serves the Envoy admin endpoints used by the shutdown command.
+// Each poll of the stats endpoint decrements the open connections of the
+// listener being drained, if any, by one.
+type fakeEnvoyAdmin struct {
+	mu          sync.Mutex
+	connections map[string]int
+	draining    string
+	healthFail  bool
+}
+
+func (f *fakeEnvoyAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/healthcheck/fail":
+		f.healthFail = true
+	case "/stats/prometheus":
+		fmt.Fprintln(w, "# TYPE envoy_http_downstream_cx_active gauge")
+		for listener, n := range f.connections {
+			fmt.Fprintf(w, "envoy_http_downstream_cx_active{envoy_http_conn_manager_prefix=%q} %d\n", listener, n)
+		}
+		if f.connections[f.draining] > 0 {
+			f.connections[f.draining]--
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newShutdownTestContext(t *testing.T, admin *fakeEnvoyAdmin) *shutdownContext {
+	t.Helper()
+
+	srv := httptest.NewServer(admin)
+	t.Cleanup(srv.Close)
+
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+
+	tmpdir, err := ioutil.TempDir("", "shutdownmanager_test-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmpdir) })
+
+	ctx := newShutdownContext()
+	ctx.FieldLogger = fixture.NewTestLogger(t)
+	ctx.adminPort, err = strconv.Atoi(port)
+	require.NoError(t, err)
+	ctx.checkDelay = 0
+	ctx.checkInterval = time.Millisecond
+	ctx.shutdownReadyFile = path.Join(tmpdir, "ok")
+	ctx.drainStatusFile = path.Join(tmpdir, "drain-status")
+	return ctx
+}
+
+func TestShutdownManager_ShutdownHandler(t *testing.T) {
+	admin := &fakeEnvoyAdmin{
+		connections: map[string]int{
+			"ingress_http":  2,
+			"ingress_https": 0,
+		},
+		draining: "ingress_http",
+	}
+	ctx := newShutdownTestContext(t, admin)
+
+	ctx.shutdownHandler()
+
+	assert.True(t, admin.healthFail)
+	assert.FileExists(t, ctx.shutdownReadyFile)
+
+	status, err := readDrainStatus(ctx.drainStatusFile)
+	require.NoError(t, err)
+	assert.True(t, status.Complete)
+	assert.False(t, status.TimedOut)
+	assert.Equal(t, map[string]int{"ingress_http": 0, "ingress_https": 0}, status.OpenConnections)
+}
+
+func TestShutdownManager_ShutdownHandlerDrainListeners(t *testing.T) {
+	admin := &fakeEnvoyAdmin{
+		connections: map[string]int{
+			"ingress_http":  3,
+			"ingress_https": 1,
+		},
+		draining: "ingress_http",
+	}
+	ctx := newShutdownTestContext(t, admin)
+	ctx.drainListeners = []string{"ingress_http", "ingress_https"}
+	ctx.minOpenConnections = 1
+
+	ctx.shutdownHandler()
+
+	assert.FileExists(t, ctx.shutdownReadyFile)
+
+	// ingress_https already has the min number of open connections
+	// once ingress_http has drained.
+	status, err := readDrainStatus(ctx.drainStatusFile)
+	require.NoError(t, err)
+	assert.True(t, status.Complete)
+	assert.False(t, status.TimedOut)
+	assert.Equal(t, "", status.Listener)
+	assert.Equal(t, map[string]int{"ingress_http": 0, "ingress_https": 1}, status.OpenConnections)
+}
+
+func TestShutdownManager_ShutdownHandlerMaxDrainDuration(t *testing.T) {
+	admin := &fakeEnvoyAdmin{
+		connections: map[string]int{
+			"ingress_http":  1,
+			"ingress_https": 5,
+		},
+	}
+	ctx := newShutdownTestContext(t, admin)
+	ctx.checkDelay = time.Minute
+	ctx.maxDrainDuration = 50 * time.Millisecond
+
+	start := time.Now()
+	ctx.shutdownHandler()
+
+	assert.Less(t, int64(time.Since(start)), int64(time.Minute))
+	assert.FileExists(t, ctx.shutdownReadyFile)
+
+	status, err := readDrainStatus(ctx.drainStatusFile)
+	require.NoError(t, err)
+	assert.True(t, status.Complete)
+	assert.True(t, status.TimedOut)
+	assert.Equal(t, map[string]int{"ingress_http": 1, "ingress_https": 5}, status.OpenConnections)
+}
+
+func TestDrainCollector(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "shutdownmanager_test-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	started := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	c := &drainCollector{
+		statusFile:  path.Join(tmpdir, "drain-status"),
+		now:         func() time.Time { return started.Add(90 * time.Second) },
+		FieldLogger: fixture.NewTestLogger(t),
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	gather := func() map[string][]float64 {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+
+		got := map[string][]float64{}
+		for _, f := range families {
+			for _, m := range f.Metric {
+				got[f.GetName()] = append(got[f.GetName()], m.GetGauge().GetValue())
+			}
+		}
+		return got
+	}
+
+	// No drain has started.
+	assert.Empty(t, gather())
+
+	ctx := &shutdownContext{
+		drainStatusFile: c.statusFile,
+		FieldLogger:     fixture.NewTestLogger(t),
+	}
+	ctx.writeDrainStatus(&drainStatus{
+		Started:  started,
+		Listener: "ingress_https",
+		OpenConnections: map[string]int{
+			"ingress_http":  0,
+			"ingress_https": 7,
+		},
+	})
+
+	assert.Equal(t, map[string][]float64{
+		"contour_envoy_drain_open_connections": {0, 7},
+		"contour_envoy_drain_listener_active":  {0, 1},
+		"contour_envoy_drain_elapsed_seconds":  {90},
+		"contour_envoy_drain_complete":         {0},
+		"contour_envoy_drain_timed_out":        {0},
+	}, gather())
+}
+
 // nolint:revive
 const (
 	VALIDHTTP = `envoy_cluster_circuit_breakers_default_cx_pool_open{envoy_cluster_name="projectcontour_service-stats_9001"} 0
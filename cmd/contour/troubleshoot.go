@@ -0,0 +1,605 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource_v3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	xdscache_v3 "github.com/projectcontour/contour/internal/xdscache/v3"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// registerTroubleshoot registers the troubleshoot subcommand
+// and flags with the cli command provided.
+func registerTroubleshoot(cli *kingpin.CmdClause) (*kingpin.CmdClause, *troubleshootContext) {
+	var ctx troubleshootContext
+	troubleshoot := cli.Command("troubleshoot", "Diagnose how requests for a virtual host are routed.")
+	troubleshoot.Arg("fqdn", "Fully qualified domain name of the virtual host.").Required().StringVar(&ctx.FQDN)
+	troubleshoot.Arg("path", "Path of the request.").Default("/").StringVar(&ctx.Path)
+	troubleshoot.Flag("debug", "Contour debug host:port.").Default("127.0.0.1:6060").StringVar(&ctx.DebugAddr)
+	troubleshoot.Flag("envoy-admin", "Envoy admin host:port, to check the health of the endpoints.").PlaceHolder("127.0.0.1:9001").StringVar(&ctx.EnvoyAdminAddr)
+
+	return troubleshoot, &ctx
+}
+
+// troubleshootContext holds the configuration for the
+// troubleshoot subcommand.
+type troubleshootContext struct {
+	// FQDN and Path are the virtual host and
+	// path of the request to diagnose.
+	FQDN string
+	Path string
+
+	// DebugAddr is the address of the Contour debug service.
+	DebugAddr string
+
+	// EnvoyAdminAddr, if set, is the address of the admin
+	// interface of an Envoy to check endpoint health with.
+	EnvoyAdminAddr string
+}
+
+// troubleshootReport holds what is known about
+// how requests for a virtual host are routed.
+type troubleshootReport struct {
+	fqdn string
+	path string
+
+	// proxies holds the status of the HTTPProxies
+	// that contribute to the virtual host.
+	proxies []dagStatus
+
+	// routes holds the route of each route configuration
+	// that serves the virtual host.
+	routes []envoyRouteMatch
+
+	// clusters holds the endpoints of each
+	// cluster that the routes refer to.
+	clusters map[string]*clusterEndpoints
+}
+
+// envoyRouteMatch is the route of a route configuration that
+// matches a request.
+type envoyRouteMatch struct {
+	routeConfig string
+	virtualHost string
+
+	// route is nil if no route of the virtual host matches.
+	route *envoy_route_v3.Route
+}
+
+// clusterEndpoints counts the endpoints of a cluster.
+type clusterEndpoints struct {
+	// dns is true if Envoy resolves the
+	// endpoints of the cluster with DNS.
+	dns bool
+
+	// ready is the number of endpoints that Contour sent.
+	ready int
+
+	// healthy and hosts are the number of healthy and all
+	// hosts of the cluster in Envoy, or -1 if not known.
+	healthy int
+	hosts   int
+}
+
+// doTroubleshoot correlates the status of the HTTPProxies of a virtual
+// host, the routes and clusters Contour sends to Envoy for it, and the
+// health of the endpoints, and writes them to out with a diagnosis.
+func doTroubleshoot(ctx *troubleshootContext, client *Client, out io.Writer) error {
+	g, err := fetchDAG(ctx.DebugAddr)
+	if err != nil {
+		return fmt.Errorf("failed to fetch DAG: %w", err)
+	}
+
+	report := &troubleshootReport{
+		fqdn:     strings.ToLower(ctx.FQDN),
+		path:     ctx.Path,
+		proxies:  proxiesFor(g, strings.ToLower(ctx.FQDN)),
+		clusters: map[string]*clusterEndpoints{},
+	}
+
+	routes, err := fetchResources(client.RouteStream(), resource_v3.RouteType, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch routes: %w", err)
+	}
+	var routeConfigs []*envoy_route_v3.RouteConfiguration
+	vhds := false
+	for _, r := range routes {
+		rc := r.(*envoy_route_v3.RouteConfiguration)
+		routeConfigs = append(routeConfigs, rc)
+		vhds = vhds || rc.Vhds != nil
+	}
+
+	// Virtual hosts fetched on demand are not
+	// part of their route configuration.
+	var vhosts []*envoy_route_v3.VirtualHost
+	if vhds {
+		resources, err := fetchResources(client.RouteStream(), xdscache_v3.VirtualHostType, nil)
+		if err != nil {
+			return fmt.Errorf("failed to fetch virtual hosts: %w", err)
+		}
+		for _, r := range resources {
+			vhosts = append(vhosts, r.(*envoy_route_v3.VirtualHost))
+		}
+	}
+
+	report.routes = findRoutes(routeConfigs, vhosts, report.fqdn, report.path)
+
+	for _, m := range report.routes {
+		for _, name := range routeClusters(m.route) {
+			report.clusters[name] = &clusterEndpoints{healthy: -1, hosts: -1}
+		}
+	}
+
+	if len(report.clusters) > 0 {
+		if err := fetchEndpoints(client, report.clusters); err != nil {
+			return err
+		}
+	}
+
+	if ctx.EnvoyAdminAddr != "" && len(report.clusters) > 0 {
+		if err := fetchEnvoyHealth(ctx.EnvoyAdminAddr, report.clusters); err != nil {
+			return fmt.Errorf("failed to fetch cluster health from Envoy: %w", err)
+		}
+	}
+
+	return report.write(out)
+}
+
+// fetchResources sends a discovery request for the named resources of
+// the type on st, and returns the resources of the first response.
+func fetchResources(st stream, typeURL string, names []string) ([]proto.Message, error) {
+	err := st.Send(&envoy_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		ResourceNames: names,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := st.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []proto.Message
+	for _, a := range resp.Resources {
+		res, err := a.UnmarshalNew()
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, proto.MessageV1(res))
+	}
+	return resources, nil
+}
+
+// fetchEndpoints counts the endpoints that Contour sends for
+// each of clusters.
+func fetchEndpoints(client *Client, clusters map[string]*clusterEndpoints) error {
+	resources, err := fetchResources(client.ClusterStream(), resource_v3.ClusterType, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch clusters: %w", err)
+	}
+
+	// services holds the clusters of each EDS service name.
+	services := map[string][]string{}
+	for _, r := range resources {
+		c := r.(*envoy_cluster_v3.Cluster)
+		ce, ok := clusters[c.Name]
+		if !ok {
+			continue
+		}
+		if c.GetType() != envoy_cluster_v3.Cluster_EDS {
+			ce.dns = true
+			continue
+		}
+		name := c.GetEdsClusterConfig().GetServiceName()
+		if name == "" {
+			name = c.Name
+		}
+		services[name] = append(services[name], c.Name)
+	}
+
+	if len(services) == 0 {
+		return nil
+	}
+
+	var names []string
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resources, err = fetchResources(client.EndpointStream(), resource_v3.EndpointType, names)
+	if err != nil {
+		return fmt.Errorf("failed to fetch endpoints: %w", err)
+	}
+
+	for _, r := range resources {
+		cla := r.(*envoy_endpoint_v3.ClusterLoadAssignment)
+		ready := 0
+		for _, e := range cla.Endpoints {
+			ready += len(e.LbEndpoints)
+		}
+		for _, name := range services[cla.ClusterName] {
+			clusters[name].ready = ready
+		}
+	}
+
+	return nil
+}
+
+// envoyClusters is the part of the response of the Envoy
+// admin /clusters endpoint that holds the health of hosts.
+type envoyClusters struct {
+	ClusterStatuses []struct {
+		Name         string `json:"name"`
+		HostStatuses []struct {
+			HealthStatus map[string]interface{} `json:"health_status"`
+		} `json:"host_statuses"`
+	} `json:"cluster_statuses"`
+}
+
+// fetchEnvoyHealth counts the healthy hosts of each of clusters
+// in the Envoy whose admin interface is at addr.
+func fetchEnvoyHealth(addr string, clusters map[string]*clusterEndpoints) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	resp, err := client.Get(fmt.Sprintf("http://%s/clusters?format=json", addr))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from %s: %s", addr, resp.Status)
+	}
+
+	var status envoyClusters
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return err
+	}
+
+	for _, cs := range status.ClusterStatuses {
+		ce, ok := clusters[cs.Name]
+		if !ok {
+			continue
+		}
+		ce.hosts = len(cs.HostStatuses)
+		ce.healthy = 0
+		for _, hs := range cs.HostStatuses {
+			if hostHealthy(hs.HealthStatus) {
+				ce.healthy++
+			}
+		}
+	}
+
+	return nil
+}
+
+// hostHealthy returns true if the health status of a host in the
+// Envoy admin /clusters endpoint has no failed health checks.
+func hostHealthy(status map[string]interface{}) bool {
+	for k, v := range status {
+		switch k {
+		case "eds_health_status":
+			if v != "HEALTHY" {
+				return false
+			}
+		default:
+			if v == true {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// proxiesFor returns the status of the HTTPProxies of the
+// virtual host fqdn in g, sorted by namespace and name.
+func proxiesFor(g *dagGraph, fqdn string) []dagStatus {
+	var proxies []dagStatus
+	for _, s := range g.Status {
+		if s.Kind == "HTTPProxy" && s.VirtualHost == fqdn {
+			proxies = append(proxies, s)
+		}
+	}
+	sort.Slice(proxies, func(i, j int) bool {
+		if proxies[i].Namespace != proxies[j].Namespace {
+			return proxies[i].Namespace < proxies[j].Namespace
+		}
+		return proxies[i].Name < proxies[j].Name
+	})
+	return proxies
+}
+
+// findRoutes returns the route that matches path in the virtual host
+// for fqdn of each route configuration. The virtual hosts of route
+// configurations that fetch them on demand are taken from vhosts.
+func findRoutes(routeConfigs []*envoy_route_v3.RouteConfiguration, vhosts []*envoy_route_v3.VirtualHost, fqdn, path string) []envoyRouteMatch {
+	var matches []envoyRouteMatch
+
+	for _, rc := range routeConfigs {
+		candidates := rc.VirtualHosts
+		if rc.Vhds != nil {
+			candidates = nil
+			for _, vh := range vhosts {
+				if strings.HasPrefix(vh.Name, rc.Name+"/") {
+					candidates = append(candidates, vh)
+				}
+			}
+		}
+
+		vh := matchVirtualHost(candidates, fqdn)
+		if vh == nil {
+			continue
+		}
+
+		m := envoyRouteMatch{
+			routeConfig: rc.Name,
+			virtualHost: vh.Name,
+		}
+		// Envoy uses the first route that matches.
+		for _, r := range vh.Routes {
+			if pathMatches(r.GetMatch(), path) {
+				m.route = r
+				break
+			}
+		}
+		matches = append(matches, m)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].routeConfig < matches[j].routeConfig
+	})
+	return matches
+}
+
+// matchVirtualHost returns the virtual host of vhosts that Envoy
+// would select for requests for fqdn, or nil if there is none.
+func matchVirtualHost(vhosts []*envoy_route_v3.VirtualHost, fqdn string) *envoy_route_v3.VirtualHost {
+	var best *envoy_route_v3.VirtualHost
+	bestLen := -1
+
+	for _, vh := range vhosts {
+		for _, domain := range vh.Domains {
+			n := -1
+			switch {
+			case domain == fqdn || domain == fqdn+":*":
+				n = len(fqdn) + 1
+			case strings.HasPrefix(domain, "*") && strings.HasSuffix(fqdn, domain[1:]) && len(fqdn) > len(domain)-1:
+				n = len(domain) - 1
+			}
+			if n > bestLen {
+				best, bestLen = vh, n
+			}
+		}
+	}
+
+	return best
+}
+
+// pathMatches returns true if the path condition of m matches path.
+// Other conditions, such as headers, are not considered.
+func pathMatches(m *envoy_route_v3.RouteMatch, path string) bool {
+	switch {
+	case m.GetSafeRegex() != nil:
+		re, err := regexp.Compile("^(?:" + m.GetSafeRegex().GetRegex() + ")$")
+		return err == nil && re.MatchString(path)
+	case m.GetPath() != "":
+		return m.GetPath() == path
+	default:
+		return strings.HasPrefix(path, m.GetPrefix())
+	}
+}
+
+// routeClusters returns the clusters that route forwards requests to.
+func routeClusters(route *envoy_route_v3.Route) []string {
+	action := route.GetRoute()
+	if action == nil {
+		return nil
+	}
+	if action.GetCluster() != "" {
+		return []string{action.GetCluster()}
+	}
+	var clusters []string
+	for _, c := range action.GetWeightedClusters().GetClusters() {
+		clusters = append(clusters, c.Name)
+	}
+	return clusters
+}
+
+// describeRoute returns the condition and action of route.
+func describeRoute(route *envoy_route_v3.Route) string {
+	var match string
+	m := route.GetMatch()
+	switch {
+	case m.GetSafeRegex() != nil:
+		match = "regex " + m.GetSafeRegex().GetRegex()
+	case m.GetPath() != "":
+		match = "path " + m.GetPath()
+	default:
+		match = "prefix " + m.GetPrefix()
+	}
+	if len(m.GetHeaders()) > 0 {
+		match += fmt.Sprintf(" (and %d header conditions)", len(m.GetHeaders()))
+	}
+
+	switch {
+	case route.GetRedirect() != nil:
+		if route.GetRedirect().GetHttpsRedirect() {
+			return match + " -> redirect to HTTPS"
+		}
+		return match + " -> redirect"
+	case route.GetDirectResponse() != nil:
+		return match + fmt.Sprintf(" -> direct response %d", route.GetDirectResponse().GetStatus())
+	default:
+		return match + " -> " + strings.Join(routeClusters(route), ", ")
+	}
+}
+
+// write writes the report, followed by its diagnosis, to out.
+func (r *troubleshootReport) write(out io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("HTTPProxies:\n")
+	if len(r.proxies) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, p := range r.proxies {
+		valid := validCondition(p)
+		fmt.Fprintf(&b, "  %s/%s: %s\n", p.Namespace, p.Name, describeCondition(valid))
+		for _, e := range valid.Errors {
+			fmt.Fprintf(&b, "    error: %s: %s\n", e.Reason, e.Message)
+		}
+	}
+
+	b.WriteString("Envoy routes:\n")
+	if len(r.routes) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, m := range r.routes {
+		if m.route == nil {
+			fmt.Fprintf(&b, "  %s: no route matches %s\n", m.routeConfig, r.path)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", m.routeConfig, describeRoute(m.route))
+	}
+
+	var names []string
+	for name := range r.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		b.WriteString("Clusters:\n")
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s: %s\n", name, r.clusters[name])
+	}
+
+	fmt.Fprintf(&b, "Diagnosis: %s\n", r.diagnose())
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// String describes the endpoints of the cluster.
+func (ce *clusterEndpoints) String() string {
+	var s string
+	if ce.dns {
+		s = "endpoints resolved by DNS"
+	} else {
+		s = fmt.Sprintf("%d ready endpoints", ce.ready)
+	}
+	if ce.hosts >= 0 {
+		s += fmt.Sprintf(", %d of %d hosts healthy in Envoy", ce.healthy, ce.hosts)
+	}
+	return s
+}
+
+// diagnose returns the first problem found with the routing of
+// requests for the virtual host, or how they are routed if there is
+// none. Requests are diagnosed as HTTPS if the virtual host has TLS.
+func (r *troubleshootReport) diagnose() string {
+	if len(r.proxies) == 0 {
+		return fmt.Sprintf("no HTTPProxy has the virtual host %s", r.fqdn)
+	}
+
+	for _, p := range r.proxies {
+		valid := validCondition(p)
+		if valid.Status != "True" {
+			return fmt.Sprintf("HTTPProxy %s/%s is not valid: %s", p.Namespace, p.Name, valid.Message)
+		}
+	}
+
+	if len(r.routes) == 0 {
+		return fmt.Sprintf("Envoy has no virtual host for %s", r.fqdn)
+	}
+
+	m := r.routes[0]
+	scheme := "http"
+	for _, rm := range r.routes {
+		switch {
+		case strings.HasPrefix(rm.routeConfig, "https/"):
+			m = rm
+			scheme = "https"
+		case rm.routeConfig == xdscache_v3.ENVOY_HTTP_LISTENER && scheme == "http":
+			m = rm
+		}
+	}
+	url := scheme + "://" + r.fqdn + r.path
+
+	if m.route == nil {
+		return fmt.Sprintf("no route of the virtual host %s matches %s", m.virtualHost, url)
+	}
+
+	switch {
+	case m.route.GetRedirect() != nil:
+		return fmt.Sprintf("requests for %s are redirected", url)
+	case m.route.GetDirectResponse() != nil:
+		return fmt.Sprintf("requests for %s are answered with status %d", url, m.route.GetDirectResponse().GetStatus())
+	}
+
+	clusters := routeClusters(m.route)
+	for _, name := range clusters {
+		ce, ok := r.clusters[name]
+		switch {
+		case !ok:
+			continue
+		case !ce.dns && ce.ready == 0:
+			return fmt.Sprintf("requests for %s are routed to %s, which has no ready endpoints", url, name)
+		case ce.hosts >= 0 && ce.healthy == 0:
+			return fmt.Sprintf("requests for %s are routed to %s, which has no healthy hosts in Envoy", url, name)
+		}
+	}
+
+	return fmt.Sprintf("requests for %s are routed to %s", url, strings.Join(clusters, ", "))
+}
+
+// validCondition returns the Valid condition of the status s.
+func validCondition(s dagStatus) dagCondition {
+	for _, c := range s.Conditions {
+		if c.Type == "Valid" {
+			return c
+		}
+	}
+	return dagCondition{Type: "Valid", Status: "Unknown"}
+}
+
+// describeCondition returns whether a condition is true, and why.
+func describeCondition(c dagCondition) string {
+	switch c.Status {
+	case "True":
+		return "valid"
+	case "False":
+		return "invalid: " + c.Message
+	default:
+		return "unknown"
+	}
+}
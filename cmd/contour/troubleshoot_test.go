@@ -0,0 +1,159 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func prefixRoute(prefix, cluster string) *envoy_route_v3.Route {
+	return &envoy_route_v3.Route{
+		Match: &envoy_route_v3.RouteMatch{
+			PathSpecifier: &envoy_route_v3.RouteMatch_Prefix{Prefix: prefix},
+		},
+		Action: &envoy_route_v3.Route_Route{
+			Route: &envoy_route_v3.RouteAction{
+				ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{Cluster: cluster},
+			},
+		},
+	}
+}
+
+func TestFindRoutes(t *testing.T) {
+	redirect := &envoy_route_v3.Route{
+		Match: &envoy_route_v3.RouteMatch{
+			PathSpecifier: &envoy_route_v3.RouteMatch_Prefix{Prefix: "/"},
+		},
+		Action: &envoy_route_v3.Route_Redirect{
+			Redirect: &envoy_route_v3.RedirectAction{
+				SchemeRewriteSpecifier: &envoy_route_v3.RedirectAction_HttpsRedirect{HttpsRedirect: true},
+			},
+		},
+	}
+
+	routeConfigs := []*envoy_route_v3.RouteConfiguration{{
+		Name: "ingress_http",
+		VirtualHosts: []*envoy_route_v3.VirtualHost{{
+			Name:    "kuard.example.com",
+			Domains: []string{"kuard.example.com"},
+			Routes:  []*envoy_route_v3.Route{redirect},
+		}, {
+			Name:    "wildcard",
+			Domains: []string{"*.example.com"},
+			Routes:  []*envoy_route_v3.Route{prefixRoute("/", "default/wildcard/80/da39a3ee5e")},
+		}},
+	}, {
+		Name: "https/kuard.example.com",
+		VirtualHosts: []*envoy_route_v3.VirtualHost{{
+			Name:    "kuard.example.com",
+			Domains: []string{"kuard.example.com"},
+			Routes: []*envoy_route_v3.Route{
+				prefixRoute("/api", "default/api/80/da39a3ee5e"),
+				prefixRoute("/", "default/kuard/80/da39a3ee5e"),
+			},
+		}},
+	}}
+
+	got := findRoutes(routeConfigs, nil, "kuard.example.com", "/api/v1")
+	require.Len(t, got, 2)
+	assert.Equal(t, "https/kuard.example.com", got[0].routeConfig)
+	assert.Equal(t, []string{"default/api/80/da39a3ee5e"}, routeClusters(got[0].route))
+	assert.Equal(t, "ingress_http", got[1].routeConfig)
+	assert.Equal(t, "prefix / -> redirect to HTTPS", describeRoute(got[1].route))
+
+	got = findRoutes(routeConfigs, nil, "echo.example.com", "/")
+	require.Len(t, got, 1)
+	assert.Equal(t, "wildcard", got[0].virtualHost)
+
+	// Virtual hosts fetched on demand are found by
+	// the name of their route configuration.
+	vhds := []*envoy_route_v3.RouteConfiguration{{
+		Name: "ingress_http",
+		Vhds: &envoy_route_v3.Vhds{},
+	}}
+	vhosts := []*envoy_route_v3.VirtualHost{{
+		Name:    "ingress_http/kuard.example.com",
+		Domains: []string{"kuard.example.com"},
+		Routes:  []*envoy_route_v3.Route{prefixRoute("/api", "default/api/80/da39a3ee5e")},
+	}}
+	got = findRoutes(vhds, vhosts, "kuard.example.com", "/")
+	require.Len(t, got, 1)
+	assert.Nil(t, got[0].route)
+}
+
+func TestTroubleshootDiagnose(t *testing.T) {
+	valid := dagStatus{Kind: "HTTPProxy", Namespace: "default", Name: "kuard", VirtualHost: "kuard.example.com",
+		Conditions: []dagCondition{{Type: "Valid", Status: "True"}}}
+	invalid := dagStatus{Kind: "HTTPProxy", Namespace: "default", Name: "child", VirtualHost: "kuard.example.com",
+		Conditions: []dagCondition{{Type: "Valid", Status: "False", Message: "At least one error present"}}}
+
+	route := envoyRouteMatch{
+		routeConfig: "ingress_http",
+		virtualHost: "kuard.example.com",
+		route:       prefixRoute("/", "default/kuard/80/da39a3ee5e"),
+	}
+
+	tests := map[string]struct {
+		report troubleshootReport
+		want   string
+	}{
+		"no proxy": {
+			report: troubleshootReport{fqdn: "kuard.example.com", path: "/"},
+			want:   "no HTTPProxy has the virtual host kuard.example.com",
+		},
+		"invalid proxy": {
+			report: troubleshootReport{fqdn: "kuard.example.com", path: "/", proxies: []dagStatus{valid, invalid}},
+			want:   "HTTPProxy default/child is not valid: At least one error present",
+		},
+		"no route": {
+			report: troubleshootReport{fqdn: "kuard.example.com", path: "/", proxies: []dagStatus{valid},
+				routes: []envoyRouteMatch{{routeConfig: "ingress_http", virtualHost: "kuard.example.com"}}},
+			want: "no route of the virtual host kuard.example.com matches http://kuard.example.com/",
+		},
+		"no endpoints": {
+			report: troubleshootReport{fqdn: "kuard.example.com", path: "/", proxies: []dagStatus{valid},
+				routes:   []envoyRouteMatch{route},
+				clusters: map[string]*clusterEndpoints{"default/kuard/80/da39a3ee5e": {healthy: -1, hosts: -1}}},
+			want: "requests for http://kuard.example.com/ are routed to default/kuard/80/da39a3ee5e, which has no ready endpoints",
+		},
+		"unhealthy": {
+			report: troubleshootReport{fqdn: "kuard.example.com", path: "/", proxies: []dagStatus{valid},
+				routes:   []envoyRouteMatch{route},
+				clusters: map[string]*clusterEndpoints{"default/kuard/80/da39a3ee5e": {ready: 2, healthy: 0, hosts: 2}}},
+			want: "requests for http://kuard.example.com/ are routed to default/kuard/80/da39a3ee5e, which has no healthy hosts in Envoy",
+		},
+		"routed": {
+			report: troubleshootReport{fqdn: "kuard.example.com", path: "/", proxies: []dagStatus{valid},
+				routes:   []envoyRouteMatch{route},
+				clusters: map[string]*clusterEndpoints{"default/kuard/80/da39a3ee5e": {ready: 2, healthy: -1, hosts: -1}}},
+			want: "requests for http://kuard.example.com/ are routed to default/kuard/80/da39a3ee5e",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.report.diagnose())
+		})
+	}
+}
+
+func TestHostHealthy(t *testing.T) {
+	assert.True(t, hostHealthy(map[string]interface{}{"eds_health_status": "HEALTHY"}))
+	assert.False(t, hostHealthy(map[string]interface{}{"eds_health_status": "UNHEALTHY"}))
+	assert.False(t, hostHealthy(map[string]interface{}{"eds_health_status": "HEALTHY", "failed_active_health_check": true}))
+}
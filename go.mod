@@ -35,4 +35,5 @@ require (
 	sigs.k8s.io/controller-tools v0.5.0
 	sigs.k8s.io/gateway-api v0.3.0
 	sigs.k8s.io/kustomize/kyaml v0.1.1
+	sigs.k8s.io/yaml v1.2.0
 )
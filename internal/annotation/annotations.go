@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/projectcontour/contour/internal/timeout"
 	networking_v1 "k8s.io/api/networking/v1"
@@ -59,13 +60,32 @@ var annotationsByKind = map[string]map[string]struct{}{
 		"projectcontour.io/websocket-routes":             {},
 	},
 	"Service": {
-		"projectcontour.io/max-connections":       {},
-		"projectcontour.io/max-pending-requests":  {},
-		"projectcontour.io/max-requests":          {},
-		"projectcontour.io/max-retries":           {},
-		"projectcontour.io/upstream-protocol.h2":  {},
-		"projectcontour.io/upstream-protocol.h2c": {},
-		"projectcontour.io/upstream-protocol.tls": {},
+		"projectcontour.io/dns-lookup-family":           {},
+		"projectcontour.io/dns-refresh-rate":            {},
+		"projectcontour.io/max-connection-duration":     {},
+		"projectcontour.io/max-connections":             {},
+		"projectcontour.io/max-pending-requests":        {},
+		"projectcontour.io/max-requests":                {},
+		"projectcontour.io/max-requests-per-connection": {},
+		"projectcontour.io/max-retries":                 {},
+		"projectcontour.io/respect-dns-ttl":             {},
+		"projectcontour.io/tcp-keepalive-interval":      {},
+		"projectcontour.io/tcp-keepalive-probes":        {},
+		"projectcontour.io/tcp-keepalive-time":          {},
+		"projectcontour.io/upstream-protocol.h2":        {},
+		"projectcontour.io/upstream-protocol.h2c":       {},
+		"projectcontour.io/upstream-protocol.tls":       {},
+	},
+	"ServiceImport": {
+		"projectcontour.io/max-connection-duration":     {},
+		"projectcontour.io/max-connections":             {},
+		"projectcontour.io/max-pending-requests":        {},
+		"projectcontour.io/max-requests":                {},
+		"projectcontour.io/max-requests-per-connection": {},
+		"projectcontour.io/max-retries":                 {},
+		"projectcontour.io/upstream-protocol.h2":        {},
+		"projectcontour.io/upstream-protocol.h2c":       {},
+		"projectcontour.io/upstream-protocol.tls":       {},
 	},
 	"HTTPProxy": {
 		"kubernetes.io/ingress.class":     {},
@@ -222,3 +242,87 @@ func MaxRequests(o metav1.Object) uint32 {
 func MaxRetries(o metav1.Object) uint32 {
 	return parseUInt32(ContourAnnotation(o, "max-retries"))
 }
+
+// MaxRequestsPerConnection returns the value of the
+// projectcontour.io/max-requests-per-connection annotation, which limits
+// the number of requests sent on a single upstream connection.
+//
+// '0' is returned if the annotation is absent or unparsable.
+func MaxRequestsPerConnection(o metav1.Object) uint32 {
+	return parseUInt32(ContourAnnotation(o, "max-requests-per-connection"))
+}
+
+// MaxConnectionDuration returns the value of the
+// projectcontour.io/max-connection-duration annotation, which limits
+// the lifetime of upstream connections.
+//
+// '0' is returned if the annotation is absent, unparsable or not positive.
+func MaxConnectionDuration(o metav1.Object) time.Duration {
+	d, err := time.ParseDuration(ContourAnnotation(o, "max-connection-duration"))
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// DNSLookupFamily returns the value of the projectcontour.io/dns-lookup-family
+// annotation, which sets how the external name of a Service is looked up.
+//
+// An empty string is returned if the annotation is absent or is not one
+// of "v4", "v6" or "auto".
+func DNSLookupFamily(o metav1.Object) string {
+	switch family := ContourAnnotation(o, "dns-lookup-family"); family {
+	case "v4", "v6", "auto":
+		return family
+	default:
+		return ""
+	}
+}
+
+// DNSRefreshRate returns the value of the projectcontour.io/dns-refresh-rate
+// annotation, which sets how often the external name of a Service is resolved.
+//
+// '0' is returned if the annotation is absent, unparsable or not positive.
+func DNSRefreshRate(o metav1.Object) time.Duration {
+	d, err := time.ParseDuration(ContourAnnotation(o, "dns-refresh-rate"))
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// RespectDNSTTL returns the value of the projectcontour.io/respect-dns-ttl
+// annotation. If true, the external name of a Service is resolved again when
+// the TTL of the DNS records expires, rather than at the DNS refresh rate.
+//
+// 'false' is returned if the annotation is absent or unparsable.
+func RespectDNSTTL(o metav1.Object) bool {
+	respect, err := strconv.ParseBool(ContourAnnotation(o, "respect-dns-ttl"))
+	return err == nil && respect
+}
+
+// TCPKeepaliveProbes returns the value of the projectcontour.io/tcp-keepalive-probes
+// annotation, which sets the number of unanswered TCP keepalive probes sent
+// before an upstream connection is considered dead.
+//
+// '0' is returned if the annotation is absent or unparsable.
+func TCPKeepaliveProbes(o metav1.Object) uint32 {
+	return parseUInt32(ContourAnnotation(o, "tcp-keepalive-probes"))
+}
+
+// TCPKeepaliveTime returns the value of the projectcontour.io/tcp-keepalive-time
+// annotation, which sets the number of seconds an upstream connection must be
+// idle before TCP keepalive probes are sent.
+//
+// '0' is returned if the annotation is absent or unparsable.
+func TCPKeepaliveTime(o metav1.Object) uint32 {
+	return parseUInt32(ContourAnnotation(o, "tcp-keepalive-time"))
+}
+
+// TCPKeepaliveInterval returns the value of the projectcontour.io/tcp-keepalive-interval
+// annotation, which sets the number of seconds between TCP keepalive probes.
+//
+// '0' is returned if the annotation is absent or unparsable.
+func TCPKeepaliveInterval(o metav1.Object) uint32 {
+	return parseUInt32(ContourAnnotation(o, "tcp-keepalive-interval"))
+}
@@ -16,6 +16,7 @@ package annotation
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/stretchr/testify/assert"
@@ -237,6 +238,48 @@ func TestHttpAllowed(t *testing.T) {
 	}
 }
 
+func TestDNSAnnotations(t *testing.T) {
+	tests := map[string]struct {
+		annotations   map[string]string
+		lookupFamily  string
+		refreshRate   time.Duration
+		respectDNSTTL bool
+	}{
+		"no annotations": {},
+		"valid annotations": {
+			annotations: map[string]string{
+				"projectcontour.io/dns-lookup-family": "v6",
+				"projectcontour.io/dns-refresh-rate":  "5s",
+				"projectcontour.io/respect-dns-ttl":   "true",
+			},
+			lookupFamily:  "v6",
+			refreshRate:   5 * time.Second,
+			respectDNSTTL: true,
+		},
+		"invalid annotations": {
+			annotations: map[string]string{
+				"projectcontour.io/dns-lookup-family": "ipv4",
+				"projectcontour.io/dns-refresh-rate":  "-5s",
+				"projectcontour.io/respect-dns-ttl":   "yes",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+
+			assert.Equal(t, tc.lookupFamily, DNSLookupFamily(svc))
+			assert.Equal(t, tc.refreshRate, DNSRefreshRate(svc))
+			assert.Equal(t, tc.respectDNSTTL, RespectDNSTTL(svc))
+		})
+	}
+}
+
 func TestAnnotationCompat(t *testing.T) {
 	tests := map[string]struct {
 		svc   *v1.Service
@@ -391,3 +434,37 @@ func kindOf(obj interface{}) string {
 		return ""
 	}
 }
+
+func TestTCPKeepaliveAnnotations(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"projectcontour.io/tcp-keepalive-probes":   "3",
+				"projectcontour.io/tcp-keepalive-time":     "60",
+				"projectcontour.io/tcp-keepalive-interval": "10s",
+			},
+		},
+	}
+
+	assert.Equal(t, uint32(3), TCPKeepaliveProbes(svc))
+	assert.Equal(t, uint32(60), TCPKeepaliveTime(svc))
+	// Durations are not accepted, the interval is in seconds.
+	assert.Equal(t, uint32(0), TCPKeepaliveInterval(svc))
+}
+
+func TestConnectionLifetimeAnnotations(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"projectcontour.io/max-requests-per-connection": "100",
+				"projectcontour.io/max-connection-duration":     "5m",
+			},
+		},
+	}
+
+	assert.Equal(t, uint32(100), MaxRequestsPerConnection(svc))
+	assert.Equal(t, 5*time.Minute, MaxConnectionDuration(svc))
+
+	svc.Annotations["projectcontour.io/max-connection-duration"] = "-5m"
+	assert.Equal(t, time.Duration(0), MaxConnectionDuration(svc))
+}
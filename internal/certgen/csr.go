@@ -0,0 +1,188 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certgen
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/projectcontour/contour/pkg/certs"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ContourRequestKey is the file name of the Contour certificate signing request.
+	ContourRequestKey = "contourcert.csr"
+	// EnvoyRequestKey is the file name of the Envoy certificate signing request.
+	EnvoyRequestKey = "envoycert.csr"
+)
+
+// WriteCertificateRequestsPEM writes out the certificate signing
+// requests and private keys in requests to individual PEM files in
+// outputDir, to be signed by an external CA.
+func WriteCertificateRequestsPEM(outputDir string, requests *certs.CertificateRequests, force OverwritePolicy) error {
+	if err := writePEM(outputDir, ContourRequestKey, requests.ContourRequest, force); err != nil {
+		return err
+	}
+
+	if err := writePEM(outputDir, ContourPrivateKeyKey, requests.ContourPrivateKey, force); err != nil {
+		return err
+	}
+
+	if err := writePEM(outputDir, EnvoyRequestKey, requests.EnvoyRequest, force); err != nil {
+		return err
+	}
+
+	return writePEM(outputDir, EnvoyPrivateKeyKey, requests.EnvoyPrivateKey, force)
+}
+
+// KubeSigner signs certificate signing requests with the Kubernetes
+// certificates API.
+type KubeSigner struct {
+	Client *kubernetes.Clientset
+
+	// SignerName is the name of the signer that is requested
+	// to sign the certificates.
+	SignerName string
+
+	// Approve, if true, approves the requests, which needs
+	// the permission to approve requests for SignerName.
+	// Otherwise they must be approved by someone else.
+	Approve bool
+
+	// Timeout is how long to wait for a request to be signed.
+	Timeout time.Duration
+}
+
+// Sign creates a CertificateSigningRequest with the given name for
+// the PEM encoded request, and returns the PEM encoded certificate
+// once it is issued. Any existing CertificateSigningRequest with the
+// same name is replaced.
+func (s *KubeSigner) Sign(name string, request []byte) ([]byte, error) {
+	block, _ := pem.Decode(request)
+	if block == nil {
+		return nil, fmt.Errorf("certificate request %q is not in PEM form", name)
+	}
+	parsed, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []certificatesv1.KeyUsage{
+		certificatesv1.UsageDigitalSignature,
+		certificatesv1.UsageServerAuth,
+		certificatesv1.UsageClientAuth,
+	}
+	// Only RSA keys can be used for key encipherment.
+	if parsed.PublicKeyAlgorithm == x509.RSA {
+		usages = append(usages, certificatesv1.UsageKeyEncipherment)
+	}
+
+	ctx := context.TODO()
+	csrs := s.Client.CertificatesV1().CertificateSigningRequests()
+
+	// Requests can not be changed once they are created, so a
+	// previous request with the same name is deleted.
+	if err := csrs.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	csr, err := csrs.Create(ctx, &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"app": "contour",
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    request,
+			SignerName: s.SignerName,
+			Usages:     usages,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CertificateSigningRequest %q: %w", name, err)
+	}
+	fmt.Printf("certificatesigningrequest/%s created\n", name)
+
+	if s.Approve {
+		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+			Type:    certificatesv1.CertificateApproved,
+			Status:  corev1.ConditionTrue,
+			Reason:  "ContourCertgenApprove",
+			Message: "This request was approved by contour certgen.",
+		})
+		if _, err := csrs.UpdateApproval(ctx, name, csr, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to approve CertificateSigningRequest %q: %w", name, err)
+		}
+		fmt.Printf("certificatesigningrequest/%s approved\n", name)
+	}
+
+	var certificate []byte
+	err = wait.PollImmediate(time.Second, s.Timeout, func() (bool, error) {
+		csr, err := csrs.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, c := range csr.Status.Conditions {
+			switch c.Type {
+			case certificatesv1.CertificateDenied, certificatesv1.CertificateFailed:
+				return false, fmt.Errorf("CertificateSigningRequest %q is %s: %s", name, c.Type, c.Message)
+			}
+		}
+		certificate = csr.Status.Certificate
+		return len(certificate) > 0, nil
+	})
+	if err != nil {
+		if err == wait.ErrWaitTimeout {
+			return nil, fmt.Errorf("CertificateSigningRequest %q was not signed within %s", name, s.Timeout)
+		}
+		return nil, err
+	}
+	fmt.Printf("certificatesigningrequest/%s issued\n", name)
+
+	return certificate, nil
+}
+
+// SignCertificateRequests signs the requests with s and returns the
+// resulting Certificates, whose CA certificate is caCert. The names
+// of the CertificateSigningRequests are prefixed with namespace,
+// since they are cluster-scoped.
+func SignCertificateRequests(s *KubeSigner, namespace string, caCert []byte, requests *certs.CertificateRequests) (*certs.Certificates, error) {
+	contourCert, err := s.Sign(namespace+"-contourcert", requests.ContourRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	envoyCert, err := s.Sign(namespace+"-envoycert", requests.EnvoyRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certs.Certificates{
+		CACertificate:      caCert,
+		ContourCertificate: contourCert,
+		ContourPrivateKey:  requests.ContourPrivateKey,
+		EnvoyCertificate:   envoyCert,
+		EnvoyPrivateKey:    requests.EnvoyPrivateKey,
+	}, nil
+}
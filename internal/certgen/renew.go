@@ -0,0 +1,131 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certgen
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/pkg/certs"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReadCertsPEM reads the certificates written by WriteCertsPEM from
+// outputDir. The private keys are not read. Certificates whose files
+// do not exist are left empty.
+func ReadCertsPEM(outputDir string) (*certs.Certificates, error) {
+	read := func(filename string) ([]byte, error) {
+		data, err := ioutil.ReadFile(path.Join(outputDir, filename))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return data, err
+	}
+
+	var certdata certs.Certificates
+	var err error
+	if certdata.CACertificate, err = read(CACertificateKey); err != nil {
+		return nil, err
+	}
+	if certdata.ContourCertificate, err = read(ContourCertificateKey); err != nil {
+		return nil, err
+	}
+	if certdata.EnvoyCertificate, err = read(EnvoyCertificateKey); err != nil {
+		return nil, err
+	}
+	return &certdata, nil
+}
+
+// ReadSecretsKube reads the certificates of the Secrets written by
+// WriteSecretsKube, in either the legacy or the compact format, from
+// namespace. The private keys are not read. Certificates whose Secrets
+// do not exist are left empty.
+func ReadSecretsKube(client *kubernetes.Clientset, namespace string) (*certs.Certificates, error) {
+	get := func(name string) (*corev1.Secret, error) {
+		s, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return &corev1.Secret{}, nil
+		}
+		return s, err
+	}
+
+	contour, err := get("contourcert")
+	if err != nil {
+		return nil, err
+	}
+	envoy, err := get("envoycert")
+	if err != nil {
+		return nil, err
+	}
+
+	certdata := &certs.Certificates{
+		CACertificate:      contour.Data[dag.CACertificateKey],
+		ContourCertificate: contour.Data[corev1.TLSCertKey],
+		EnvoyCertificate:   envoy.Data[corev1.TLSCertKey],
+	}
+
+	// Legacy Secrets hold the CA certificate in a separate Secret.
+	if len(certdata.CACertificate) == 0 {
+		ca, err := get("cacert")
+		if err != nil {
+			return nil, err
+		}
+		certdata.CACertificate = ca.Data[CACertificateKey]
+	}
+
+	return certdata, nil
+}
+
+// RenewalRequired returns true, and the reason, if any certificate of
+// certdata is missing, can not be parsed or expires before deadline.
+func RenewalRequired(certdata *certs.Certificates, deadline time.Time) (bool, string) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"CA", certdata.CACertificate},
+		{"Contour", certdata.ContourCertificate},
+		{"Envoy", certdata.EnvoyCertificate},
+	} {
+		if len(c.data) == 0 {
+			return true, fmt.Sprintf("%s certificate does not exist", c.name)
+		}
+
+		block, _ := pem.Decode(c.data)
+		if block == nil {
+			return true, fmt.Sprintf("%s certificate is not in PEM form", c.name)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return true, fmt.Sprintf("%s certificate is not valid: %s", c.name, err)
+		}
+
+		if cert.NotAfter.Before(deadline) {
+			return true, fmt.Sprintf("%s certificate expires at %s", c.name, cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return false, ""
+}
@@ -0,0 +1,230 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// AuditObserver is a dag.Observer that records which virtual hosts'
+// routes, certificates, or clusters changed between DAG rebuilds,
+// naming the HTTPProxy that configured them, so that configuration
+// changes can be traced during incidents.
+type AuditObserver struct {
+	logrus.FieldLogger
+
+	// Recorder, if not nil, is used to emit a Kubernetes Event on
+	// the HTTPProxy that configured each changed virtual host.
+	Recorder record.EventRecorder
+
+	// IsLeader will become ready to read when this AuditObserver
+	// becomes the leader. If IsLeader is not readable, or nil,
+	// Kubernetes Events will be suppressed.
+	IsLeader chan struct{}
+
+	// NextObserver is passed each DAG.
+	NextObserver dag.Observer
+
+	// last holds the virtual hosts of the previous DAG,
+	// or nil if no DAG has been observed yet.
+	last map[string]vhostSummary
+}
+
+// vhostSummary is the configuration of a virtual host
+// that is compared between DAG rebuilds.
+type vhostSummary struct {
+	routes   []string
+	secret   string
+	clusters []string
+
+	// source is the root HTTPProxy of the virtual
+	// host, or empty if it is not known.
+	source types.NamespacedName
+}
+
+func (a *AuditObserver) OnChange(d *dag.DAG) {
+	current := summarizeVirtualHosts(d)
+
+	// Don't record the first DAG, everything in it was
+	// added by the initial sync of the informer caches.
+	if a.last != nil {
+		a.audit(a.last, current)
+	}
+	a.last = current
+
+	a.NextObserver.OnChange(d)
+}
+
+// audit logs, and optionally records a Kubernetes Event, for each
+// virtual host that differs between previous and current.
+func (a *AuditObserver) audit(previous, current map[string]vhostSummary) {
+	names := map[string]bool{}
+	for name := range previous {
+		names[name] = true
+	}
+	for name := range current {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		prev, hadPrev := previous[name]
+		cur, hasCur := current[name]
+
+		var changes []string
+		switch {
+		case !hadPrev:
+			changes = []string{"added"}
+		case !hasCur:
+			changes = []string{"removed"}
+			cur.source = prev.source
+		default:
+			if !equalStrings(prev.routes, cur.routes) {
+				changes = append(changes, "routes")
+			}
+			if prev.secret != cur.secret {
+				changes = append(changes, "certificate")
+			}
+			if !equalStrings(prev.clusters, cur.clusters) {
+				changes = append(changes, "clusters")
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		log := a.WithField("vhost", name).WithField("changes", strings.Join(changes, ","))
+		if cur.source.Name != "" {
+			log = log.WithField("source", "HTTPProxy/"+cur.source.String())
+		}
+		log.Info("virtual host configuration changed")
+
+		a.recordEvent(cur.source, fmt.Sprintf("Virtual host %s changed: %s", name, strings.Join(changes, ", ")))
+	}
+}
+
+// recordEvent records a Kubernetes Event on the HTTPProxy source,
+// if a Recorder is configured and this AuditObserver is the leader.
+func (a *AuditObserver) recordEvent(source types.NamespacedName, message string) {
+	if a.Recorder == nil || source.Name == "" {
+		return
+	}
+
+	select {
+	// If we are leader, the IsLeader channel is closed.
+	case <-a.IsLeader:
+		a.Recorder.Event(&v1.ObjectReference{
+			APIVersion: contour_api_v1.GroupVersion.String(),
+			Kind:       "HTTPProxy",
+			Namespace:  source.Namespace,
+			Name:       source.Name,
+		}, v1.EventTypeNormal, "ConfigurationChanged", message)
+	default:
+	}
+}
+
+// summarizeVirtualHosts returns the summary of each virtual host in the
+// DAG, keyed by its scheme and name, e.g. https://example.com.
+func summarizeVirtualHosts(d *dag.DAG) map[string]vhostSummary {
+	sources := map[string]types.NamespacedName{}
+	for _, u := range d.StatusCache.GetProxyUpdates() {
+		if u.Vhost != "" {
+			sources[u.Vhost] = u.Fullname
+		}
+	}
+
+	summaries := map[string]vhostSummary{}
+
+	d.Visit(func(v dag.Vertex) {
+		// The roots of the DAG are the listeners.
+		v.Visit(func(v dag.Vertex) {
+			switch v := v.(type) {
+			case *dag.VirtualHost:
+				s := summarizeRoutes(v)
+				s.source = sources[v.Name]
+				summaries["http://"+v.Name] = s
+			case *dag.SecureVirtualHost:
+				s := summarizeRoutes(v)
+				s.source = sources[v.VirtualHost.Name]
+				if v.Secret != nil {
+					s.secret = fmt.Sprintf("%s/%s@%s", v.Secret.Namespace(), v.Secret.Name(), v.Secret.Object.ResourceVersion)
+				}
+				summaries["https://"+v.VirtualHost.Name] = s
+			}
+		})
+	})
+
+	return summaries
+}
+
+// summarizeRoutes returns a vhostSummary of the routes and
+// clusters of vhost, which is a VirtualHost or SecureVirtualHost.
+func summarizeRoutes(vhost dag.Vertex) vhostSummary {
+	var s vhostSummary
+	clusters := map[string]bool{}
+
+	vhost.Visit(func(v dag.Vertex) {
+		route, ok := v.(*dag.Route)
+		if !ok {
+			return
+		}
+
+		var names []string
+		for _, c := range route.Clusters {
+			name := envoy.Clustername(c)
+			names = append(names, fmt.Sprintf("%s:%d", name, c.Weight))
+			clusters[name] = true
+		}
+
+		match := route.PathMatchCondition.String()
+		for i := range route.HeaderMatchConditions {
+			match += " " + route.HeaderMatchConditions[i].String()
+		}
+		s.routes = append(s.routes, match+" -> "+strings.Join(names, ","))
+	})
+
+	sort.Strings(s.routes)
+	s.clusters = sortedKeys(clusters)
+	return s
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
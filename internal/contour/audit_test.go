@@ -0,0 +1,115 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestAuditObserver(t *testing.T) {
+	build := func(fqdns map[string]string) *dag.DAG {
+		builder := dag.Builder{
+			Source: dag.KubernetesCache{
+				FieldLogger: fixture.NewTestLogger(t),
+			},
+			Processors: []dag.Processor{
+				&dag.HTTPProxyProcessor{},
+				&dag.ListenerProcessor{},
+			},
+		}
+		builder.Source.Insert(fixture.ServiceRootsKuard)
+		for fqdn, prefix := range fqdns {
+			builder.Source.Insert(&contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Name:      fqdn,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: fqdn,
+					},
+					Routes: []contour_api_v1.Route{{
+						Conditions: []contour_api_v1.MatchCondition{{
+							Prefix: prefix,
+						}},
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			})
+		}
+		return builder.Build()
+	}
+
+	isLeader := make(chan struct{})
+	close(isLeader)
+
+	recorder := record.NewFakeRecorder(10)
+	applied := 0
+	audit := &AuditObserver{
+		FieldLogger: fixture.NewTestLogger(t),
+		Recorder:    recorder,
+		IsLeader:    isLeader,
+		NextObserver: dag.ObserverFunc(func(*dag.DAG) {
+			applied++
+		}),
+	}
+
+	events := func() []string {
+		var got []string
+		for {
+			select {
+			case e := <-recorder.Events:
+				got = append(got, e)
+			default:
+				return got
+			}
+		}
+	}
+
+	// The first DAG is the initial sync, and is not recorded.
+	audit.OnChange(build(map[string]string{"a.example.com": "/"}))
+	assert.Equal(t, 1, applied)
+	assert.Empty(t, events())
+
+	// An unchanged DAG is not recorded.
+	audit.OnChange(build(map[string]string{"a.example.com": "/"}))
+	assert.Equal(t, 2, applied)
+	assert.Empty(t, events())
+
+	// Changing a route and adding a virtual host are recorded
+	// on the HTTPProxies that configured them.
+	audit.OnChange(build(map[string]string{"a.example.com": "/api", "b.example.com": "/"}))
+	assert.Equal(t, 3, applied)
+	assert.Equal(t, []string{
+		"Normal ConfigurationChanged Virtual host http://a.example.com changed: routes",
+		"Normal ConfigurationChanged Virtual host http://b.example.com changed: added",
+	}, events())
+
+	// Removing a virtual host is recorded on its last HTTPProxy.
+	audit.OnChange(build(map[string]string{"a.example.com": "/api"}))
+	assert.Equal(t, 4, applied)
+	assert.Equal(t, []string{
+		"Normal ConfigurationChanged Virtual host http://b.example.com changed: removed",
+	}, events())
+}
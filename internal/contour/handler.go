@@ -23,7 +23,9 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/health"
 	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/metrics"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -39,6 +41,14 @@ type EventHandler struct {
 
 	StatusUpdater k8s.StatusUpdater
 
+	// Metrics to emit. May be nil.
+	Metrics *metrics.Metrics
+
+	// Freshness records the changes observed. The Observer
+	// records when they are included in a DAG that is passed
+	// on to the xDS caches. May be nil.
+	Freshness *health.Freshness
+
 	logrus.FieldLogger
 
 	// IsLeader will become ready to read when this EventHandler becomes
@@ -72,6 +82,10 @@ type opDelete struct {
 	obj interface{}
 }
 
+type opReconfigure struct {
+	f func()
+}
+
 func (e *EventHandler) OnAdd(obj interface{}) {
 	e.update <- opAdd{obj: obj}
 }
@@ -89,6 +103,14 @@ func (e *EventHandler) UpdateNow() {
 	e.update <- true
 }
 
+// Reconfigure runs f on the event handling goroutine, so that f can
+// change the configuration of the Builder and the Observer without
+// racing with a DAG rebuild, and then enqueues a DAG update subject
+// to the holdoff timer.
+func (e *EventHandler) Reconfigure(f func()) {
+	e.update <- opReconfigure{f: f}
+}
+
 // Start initializes the EventHandler and returns a function suitable
 // for registration with a workgroup.Group.
 func (e *EventHandler) Start() func(<-chan struct{}) error {
@@ -112,6 +134,11 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 		// pending is a reference to the current timer's channel.
 		pending <-chan time.Time
 
+		// expiry holds the timer which will expire when part of
+		// the last DAG expires, and expired is its channel.
+		expiry  *time.Timer
+		expired <-chan time.Time
+
 		// lastDAGRebuild holds the last time rebuildDAG was called.
 		// lastDAGRebuild is seeded to the current time on entry to
 		// run to allow the holdoff timer to batch the updates from
@@ -125,19 +152,23 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 	}
 
 	for {
-		// In the main loop one of four things can happen.
-		// 1. We're waiting for an event on op, stop, or pending, noting that
-		//    pending may be nil if there are no pending events.
+		// In the main loop one of five things can happen.
+		// 1. We're waiting for an event on op, stop, pending, or expired,
+		//    noting that pending and expired may be nil if there are no
+		//    pending events and nothing in the last DAG expires.
 		// 2. We're processing an event.
 		// 3. The holdoff timer from a previous event has fired and we're
 		//    building a new DAG and sending to the Observer.
-		// 4. We're stopping.
+		// 4. Part of the last DAG has expired and we're scheduling a
+		//    rebuild immediately.
+		// 5. We're stopping.
 		//
 		// Only one of these things can happen at a time.
 		select {
 		case op := <-e.update:
 			if e.onUpdate(op) {
 				outstanding++
+				e.observe(op)
 				// If there is already a timer running, stop it.
 				if timer != nil {
 					timer.Stop()
@@ -158,9 +189,24 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 			}
 		case <-pending:
 			e.WithField("last_update", time.Since(lastDAGRebuild)).WithField("outstanding", reset()).Info("performing delayed update")
-			e.rebuildDAG()
+			if expiry != nil {
+				expiry.Stop()
+				expired = nil
+			}
+			if at := e.rebuildDAG(); !at.IsZero() {
+				expiry = time.NewTimer(time.Until(at))
+				expired = expiry.C
+			}
 			e.incSequence()
 			lastDAGRebuild = time.Now()
+		case <-expired:
+			e.Info("performing update for expired resources")
+			expired = nil
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(0)
+			pending = timer.C
 		case <-stop:
 			// shutdown
 			return nil
@@ -191,11 +237,32 @@ func (e *EventHandler) onUpdate(op interface{}) bool {
 		return e.Builder.Source.Remove(op.obj)
 	case bool:
 		return op
+	case opReconfigure:
+		op.f()
+		return true
 	default:
 		return false
 	}
 }
 
+// observe records the change made by op with e.Freshness.
+func (e *EventHandler) observe(op interface{}) {
+	if e.Freshness == nil {
+		return
+	}
+
+	switch op := op.(type) {
+	case opAdd:
+		e.Freshness.Observe(op.obj)
+	case opUpdate:
+		e.Freshness.Observe(op.newObj)
+	case opDelete:
+		e.Freshness.Observe(op.obj)
+	default:
+		e.Freshness.Observe(nil)
+	}
+}
+
 // incSequence bumps the sequence counter and sends it to e.Sequence.
 func (e *EventHandler) incSequence() {
 	e.seq++
@@ -209,12 +276,19 @@ func (e *EventHandler) incSequence() {
 
 // rebuildDAG builds a new DAG and sends it to the Observer,
 // the updates the status on objects, and updates the metrics.
-func (e *EventHandler) rebuildDAG() {
+// It returns the time at which part of the new DAG expires,
+// or zero if nothing in it expires.
+func (e *EventHandler) rebuildDAG() time.Time {
+	start := time.Now()
 	latestDAG := e.Builder.Build()
+	if e.Metrics != nil {
+		e.Metrics.SetDAGRebuildDuration(time.Since(start))
+	}
 	e.Observer.OnChange(latestDAG)
 
 	for _, upd := range latestDAG.StatusCache.GetStatusUpdates() {
 		e.StatusUpdater.Send(upd)
 	}
 
+	return latestDAG.Expiry
 }
@@ -75,6 +75,7 @@ type RebuildMetricsObserver struct {
 func (m *RebuildMetricsObserver) OnChange(d *dag.DAG) {
 	m.Metrics.SetDAGLastRebuilt(time.Now())
 	m.Metrics.SetDAGRebuiltTotal()
+	m.Metrics.SetDAGRouteAndClusterCount(countRoutesAndClusters(d))
 
 	timer := prometheus.NewTimer(m.Metrics.CacheHandlerOnUpdateSummary)
 	m.NextObserver.OnChange(d)
@@ -88,6 +89,30 @@ func (m *RebuildMetricsObserver) OnChange(d *dag.DAG) {
 	}
 }
 
+// countRoutesAndClusters returns the number of distinct routes and
+// clusters reachable from the roots of the DAG.
+func countRoutesAndClusters(d *dag.DAG) (int, int) {
+	routes := map[*dag.Route]bool{}
+	clusters := map[*dag.Cluster]bool{}
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		switch v := v.(type) {
+		case *dag.Route:
+			routes[v] = true
+		case *dag.Cluster:
+			if clusters[v] {
+				return
+			}
+			clusters[v] = true
+		}
+		v.Visit(visit)
+	}
+	d.Visit(visit)
+
+	return len(routes), len(clusters)
+}
+
 func calculateRouteMetric(updates []*status.ProxyUpdate) metrics.RouteMetric {
 	proxyMetricTotal := make(map[metrics.Meta]int)
 	proxyMetricValid := make(map[metrics.Meta]int)
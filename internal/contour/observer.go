@@ -13,6 +13,11 @@
 
 package contour
 
+import (
+	"sync"
+	"time"
+)
+
 // Observer is an interface for receiving notifications.
 type Observer interface {
 	Refresh()
@@ -30,6 +35,51 @@ func (f ObserverFunc) Refresh() {
 
 var _ Observer = ObserverFunc(nil)
 
+// HoldoffObserver is an Observer that coalesces calls to Refresh.
+// Each call delays the refresh of Next by HoldoffDelay, so that a
+// burst of calls causes a single refresh, unless HoldoffMaxDelay has
+// passed since Next was last refreshed, in which case Next is
+// refreshed immediately.
+type HoldoffObserver struct {
+	Next Observer
+
+	HoldoffDelay, HoldoffMaxDelay time.Duration
+
+	mu sync.Mutex
+
+	// timer holds the timer which will refresh Next.
+	timer *time.Timer
+
+	// lastRefresh holds the last time Next was refreshed.
+	lastRefresh time.Time
+}
+
+func (h *HoldoffObserver) Refresh() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// If there is already a timer running, stop it.
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+
+	delay := h.HoldoffDelay
+	if time.Since(h.lastRefresh) > h.HoldoffMaxDelay {
+		// the maximum holdoff delay has been exceeded so
+		// refresh immediately by delaying for 0ns.
+		delay = 0
+	}
+	h.timer = time.AfterFunc(delay, h.refresh)
+}
+
+func (h *HoldoffObserver) refresh() {
+	h.mu.Lock()
+	h.lastRefresh = time.Now()
+	h.mu.Unlock()
+
+	h.Next.Refresh()
+}
+
 // ComposeObservers returns a new Observer that calls each of its arguments in turn.
 func ComposeObservers(observers ...Observer) Observer {
 	return ObserverFunc(func() {
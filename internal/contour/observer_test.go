@@ -0,0 +1,48 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHoldoffObserver(t *testing.T) {
+	var refreshes int32
+	h := &HoldoffObserver{
+		Next: ObserverFunc(func() {
+			atomic.AddInt32(&refreshes, 1)
+		}),
+		HoldoffDelay:    100 * time.Millisecond,
+		HoldoffMaxDelay: time.Hour,
+	}
+
+	count := func() int32 { return atomic.LoadInt32(&refreshes) }
+
+	// The first refresh is immediate, as the maximum
+	// delay has passed since Next was last refreshed.
+	h.Refresh()
+	assert.Eventually(t, func() bool { return count() == 1 }, time.Second, 10*time.Millisecond)
+
+	// A burst of refreshes is coalesced into one.
+	for i := 0; i < 10; i++ {
+		h.Refresh()
+	}
+	assert.Equal(t, int32(1), count())
+	assert.Eventually(t, func() bool { return count() == 2 }, time.Second, 10*time.Millisecond)
+	assert.Never(t, func() bool { return count() > 2 }, 300*time.Millisecond, 10*time.Millisecond)
+}
@@ -0,0 +1,121 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/health"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/projectcontour/contour/internal/status"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RemovalGuard is a dag.Observer that refuses to pass on a DAG which
+// would remove more than MaxRemovalPercent of the routes or services
+// of the last DAG it was given. This keeps a control plane fault, such
+// as an emptied informer cache, or an accidental mass deletion from
+// blackholing all traffic in a single rebuild.
+//
+// Each DAG is compared with the last one that was passed on, so a
+// removal stays blocked until it is undone or MaxRemovalPercent is
+// raised to let it through. The status updates of a blocked DAG are
+// dropped, so that objects don't report changes that Envoy is not
+// serving, and its changes are not recorded as built, so that the
+// readiness check reports them as stale.
+type RemovalGuard struct {
+	// MaxRemovalPercent is the maximum percentage of routes or
+	// services that a single DAG rebuild may remove. If zero,
+	// every DAG is passed on. It may be changed between calls
+	// to OnChange, to let an intended removal through.
+	MaxRemovalPercent int
+
+	// Metrics to emit. May be nil.
+	Metrics *metrics.Metrics
+
+	// Freshness is told when a DAG is passed on. May be nil.
+	Freshness *health.Freshness
+
+	logrus.FieldLogger
+
+	// NextObserver is passed each DAG that is accepted.
+	NextObserver dag.Observer
+
+	// routes and services are the counts of the
+	// last DAG that was passed on.
+	routes, services int
+}
+
+func (g *RemovalGuard) OnChange(d *dag.DAG) {
+	routes, services := countRoutesAndServices(d)
+
+	blocked := g.MaxRemovalPercent > 0 &&
+		(exceedsRemovalLimit(g.routes, routes, g.MaxRemovalPercent) ||
+			exceedsRemovalLimit(g.services, services, g.MaxRemovalPercent))
+
+	if g.Metrics != nil {
+		g.Metrics.SetDAGRebuildBlocked(blocked)
+	}
+
+	if blocked {
+		g.WithField("routes", routes).
+			WithField("previous_routes", g.routes).
+			WithField("services", services).
+			WithField("previous_services", g.services).
+			WithField("max_removal_percent", g.MaxRemovalPercent).
+			Error("refusing to apply DAG rebuild that removes too many routes or services until max-removal-percent is raised")
+
+		d.StatusCache = status.NewCache(types.NamespacedName{})
+		return
+	}
+
+	g.routes, g.services = routes, services
+	if g.Freshness != nil {
+		g.Freshness.Built()
+	}
+	g.NextObserver.OnChange(d)
+}
+
+// exceedsRemovalLimit returns true if going from previous to current
+// removes more than maxPercent of previous.
+func exceedsRemovalLimit(previous, current, maxPercent int) bool {
+	if current >= previous {
+		return false
+	}
+	return (previous-current)*100 > previous*maxPercent
+}
+
+// countRoutesAndServices returns the number of distinct routes and
+// services reachable from the roots of the DAG.
+func countRoutesAndServices(d *dag.DAG) (int, int) {
+	routes := map[*dag.Route]bool{}
+	services := map[*dag.Service]bool{}
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		switch v := v.(type) {
+		case *dag.Route:
+			routes[v] = true
+		case *dag.Service:
+			if services[v] {
+				return
+			}
+			services[v] = true
+		}
+		v.Visit(visit)
+	}
+	d.Visit(visit)
+
+	return len(routes), len(services)
+}
@@ -0,0 +1,143 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"fmt"
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRemovalGuard(t *testing.T) {
+	build := func(proxies int) *dag.DAG {
+		builder := dag.Builder{
+			Source: dag.KubernetesCache{
+				FieldLogger: fixture.NewTestLogger(t),
+			},
+			Processors: []dag.Processor{
+				&dag.HTTPProxyProcessor{},
+				&dag.ListenerProcessor{},
+			},
+		}
+		builder.Source.Insert(fixture.ServiceRootsKuard)
+		for i := 0; i < proxies; i++ {
+			builder.Source.Insert(&contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+					Name:      fmt.Sprintf("proxy%d", i),
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: fmt.Sprintf("proxy%d.example.com", i),
+					},
+					Routes: []contour_api_v1.Route{{
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			})
+		}
+		return builder.Build()
+	}
+
+	var applied []*dag.DAG
+	var freshness health.Freshness
+	guard := &RemovalGuard{
+		MaxRemovalPercent: 50,
+		Freshness:         &freshness,
+		FieldLogger:       fixture.NewTestLogger(t),
+		NextObserver: dag.ObserverFunc(func(d *dag.DAG) {
+			applied = append(applied, d)
+		}),
+	}
+
+	// The first DAG is always applied.
+	d4 := build(4)
+	guard.OnChange(d4)
+	assert.Equal(t, []*dag.DAG{d4}, applied)
+
+	// Removing 3 of 4 routes exceeds the limit, the status
+	// updates of the blocked DAG are dropped, and its
+	// changes are not recorded as built.
+	freshness.Observe(nil)
+	d1 := build(1)
+	require.NotEmpty(t, d1.StatusCache.GetStatusUpdates())
+	guard.OnChange(d1)
+	assert.Equal(t, []*dag.DAG{d4}, applied)
+	assert.Empty(t, d1.StatusCache.GetStatusUpdates())
+	freshness.Synced()
+	assert.Error(t, freshness.Check(-1))
+
+	// The next DAG is still compared with the last one
+	// that was applied, so the removal stays blocked.
+	guard.OnChange(build(1))
+	assert.Equal(t, []*dag.DAG{d4}, applied)
+
+	// Raising the limit lets the removal through.
+	guard.MaxRemovalPercent = 75
+	d1 = build(1)
+	guard.OnChange(d1)
+	assert.Equal(t, []*dag.DAG{d4, d1}, applied)
+	freshness.Synced()
+	assert.NoError(t, freshness.Check(-1))
+
+	// Removing all services exceeds the limit.
+	guard.OnChange(build(0))
+	assert.Equal(t, []*dag.DAG{d4, d1}, applied)
+
+	// Adding routes is always applied.
+	d6 := build(6)
+	guard.OnChange(d6)
+	assert.Equal(t, []*dag.DAG{d4, d1, d6}, applied)
+
+	// Removing 3 of 6 routes is within the limit.
+	d3 := build(3)
+	guard.OnChange(d3)
+	assert.Equal(t, []*dag.DAG{d4, d1, d6, d3}, applied)
+
+	// With the guard disabled, every DAG is applied.
+	guard.MaxRemovalPercent = 0
+	d0 := build(0)
+	guard.OnChange(d0)
+	assert.Equal(t, []*dag.DAG{d4, d1, d6, d3, d0}, applied)
+}
+
+func TestExceedsRemovalLimit(t *testing.T) {
+	tests := map[string]struct {
+		previous, current, maxPercent int
+		want                          bool
+	}{
+		"no previous":     {previous: 0, current: 0, maxPercent: 10, want: false},
+		"growth":          {previous: 10, current: 20, maxPercent: 10, want: false},
+		"at the limit":    {previous: 10, current: 9, maxPercent: 10, want: false},
+		"over the limit":  {previous: 10, current: 8, maxPercent: 10, want: true},
+		"all removed":     {previous: 10, current: 0, maxPercent: 99, want: true},
+		"100 percent max": {previous: 10, current: 0, maxPercent: 100, want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, exceedsRemovalLimit(tc.previous, tc.current, tc.maxPercent))
+		})
+	}
+}
@@ -20,15 +20,20 @@ import (
 	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/k8s"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // RouteServiceName identifies a service used in a route.
 type RouteServiceName struct {
-	Name      string
-	Namespace string
-	Port      int32
+	Name          string
+	Namespace     string
+	Port          int32
+	ServiceImport bool
+	PodSelector   string
+	Static        bool
 }
 
 // GetServices returns all services in the DAG.
@@ -73,16 +78,122 @@ func (dag *DAG) EnsureService(meta types.NamespacedName, port intstr.IntOrString
 			ServicePort:      svcPort,
 			Weight:           1,
 		},
-		Protocol:           upstreamProtocol(svc, svcPort),
-		MaxConnections:     annotation.MaxConnections(svc),
-		MaxPendingRequests: annotation.MaxPendingRequests(svc),
-		MaxRequests:        annotation.MaxRequests(svc),
-		MaxRetries:         annotation.MaxRetries(svc),
-		ExternalName:       externalName(svc),
+		Protocol:                 upstreamProtocol(svc, svcPort),
+		MaxConnections:           annotation.MaxConnections(svc),
+		MaxPendingRequests:       annotation.MaxPendingRequests(svc),
+		MaxRequests:              annotation.MaxRequests(svc),
+		MaxRetries:               annotation.MaxRetries(svc),
+		MaxRequestsPerConnection: annotation.MaxRequestsPerConnection(svc),
+		MaxConnectionDuration:    annotation.MaxConnectionDuration(svc),
+		ExternalName:             externalName(svc),
+		DNSLookupFamily:          annotation.DNSLookupFamily(svc),
+		DNSRefreshRate:           annotation.DNSRefreshRate(svc),
+		RespectDNSTTL:            annotation.RespectDNSTTL(svc),
+		TCPKeepalive:             tcpKeepalive(svc),
 	}
 	return dagSvc, nil
 }
 
+// EnsureServiceImport looks for a multi-cluster ServiceImport in the cache matching
+// the provided namespace, name and port, and returns a DAG service for it. The
+// endpoints of the service are discovered from the EndpointSlices imported for it.
+// If a matching ServiceImport cannot be found in the cache, an error is returned.
+func (dag *DAG) EnsureServiceImport(meta types.NamespacedName, port intstr.IntOrString, cache *KubernetesCache) (*Service, error) {
+	svc, svcPort, err := cache.LookupServiceImport(meta, port)
+	if err != nil {
+		return nil, err
+	}
+
+	if dagSvc := dag.GetServices()[RouteServiceName{
+		Name:          svc.Name,
+		Namespace:     svc.Namespace,
+		Port:          svcPort.Port,
+		ServiceImport: true,
+	}]; dagSvc != nil {
+		return dagSvc, nil
+	}
+
+	dagSvc := &Service{
+		Weighted: WeightedService{
+			ServiceName:      svc.Name,
+			ServiceNamespace: svc.Namespace,
+			ServicePort:      svcPort,
+			ServiceImport:    true,
+			Weight:           1,
+		},
+		Protocol:                 upstreamProtocol(svc, svcPort),
+		MaxConnections:           annotation.MaxConnections(svc),
+		MaxPendingRequests:       annotation.MaxPendingRequests(svc),
+		MaxRequests:              annotation.MaxRequests(svc),
+		MaxRetries:               annotation.MaxRetries(svc),
+		MaxRequestsPerConnection: annotation.MaxRequestsPerConnection(svc),
+		MaxConnectionDuration:    annotation.MaxConnectionDuration(svc),
+	}
+	return dagSvc, nil
+}
+
+// EnsureHeadlessService looks for a headless Kubernetes service in the cache matching
+// the provided namespace, name and port, and returns a DAG service for the subset of
+// its endpoints whose pods have all of the labels in podSelector. If a matching service
+// cannot be found in the cache, or the service is not headless, an error is returned.
+func (dag *DAG) EnsureHeadlessService(meta types.NamespacedName, port intstr.IntOrString, cache *KubernetesCache, podSelector map[string]string) (*Service, error) {
+	svc, svcPort, err := cache.LookupService(meta, port)
+	if err != nil {
+		return nil, err
+	}
+
+	if svc.Spec.ClusterIP != v1.ClusterIPNone {
+		return nil, fmt.Errorf("%s/%s is not a headless service, pod selectors are only supported on headless services", svc.Namespace, svc.Name)
+	}
+
+	if dagSvc := dag.GetServices()[RouteServiceName{
+		Name:        svc.Name,
+		Namespace:   svc.Namespace,
+		Port:        svcPort.Port,
+		PodSelector: labels.Set(podSelector).String(),
+	}]; dagSvc != nil {
+		return dagSvc, nil
+	}
+
+	dagSvc := &Service{
+		Weighted: WeightedService{
+			ServiceName:      svc.Name,
+			ServiceNamespace: svc.Namespace,
+			ServicePort:      svcPort,
+			PodSelector:      podSelector,
+			Weight:           1,
+		},
+		Protocol:                 upstreamProtocol(svc, svcPort),
+		MaxConnections:           annotation.MaxConnections(svc),
+		MaxPendingRequests:       annotation.MaxPendingRequests(svc),
+		MaxRequests:              annotation.MaxRequests(svc),
+		MaxRetries:               annotation.MaxRetries(svc),
+		MaxRequestsPerConnection: annotation.MaxRequestsPerConnection(svc),
+		MaxConnectionDuration:    annotation.MaxConnectionDuration(svc),
+		TCPKeepalive:             tcpKeepalive(svc),
+	}
+	return dagSvc, nil
+}
+
+// EnsureStaticService returns a DAG service for a backend that is not
+// discovered from Kubernetes, with the provided namespace, name, port and
+// endpoints. Static services are not shared, since services with the
+// same name may have different endpoints.
+func (dag *DAG) EnsureStaticService(meta types.NamespacedName, port int32, endpoints []StaticEndpoint) *Service {
+	return &Service{
+		Weighted: WeightedService{
+			ServiceName:      meta.Name,
+			ServiceNamespace: meta.Namespace,
+			ServicePort: v1.ServicePort{
+				Protocol: v1.ProtocolTCP,
+				Port:     port,
+			},
+			Weight: 1,
+		},
+		StaticEndpoints: endpoints,
+	}
+}
+
 func validateExternalName(svc *v1.Service, enableExternalNameSvc bool) error {
 
 	// If this isn't an ExternalName Service, we're all good here.
@@ -115,8 +226,8 @@ func validateExternalName(svc *v1.Service, enableExternalNameSvc bool) error {
 	return nil
 }
 
-func upstreamProtocol(svc *v1.Service, port v1.ServicePort) string {
-	up := annotation.ParseUpstreamProtocols(svc.Annotations)
+func upstreamProtocol(obj metav1.Object, port v1.ServicePort) string {
+	up := annotation.ParseUpstreamProtocols(obj.GetAnnotations())
 	protocol := up[port.Name]
 	if protocol == "" {
 		protocol = up[strconv.Itoa(int(port.Port))]
@@ -131,6 +242,20 @@ func externalName(svc *v1.Service) string {
 	return svc.Spec.ExternalName
 }
 
+// tcpKeepalive returns the TCP keepalive settings from the annotations
+// of svc, or nil if none are set.
+func tcpKeepalive(svc *v1.Service) *TCPKeepalive {
+	k := TCPKeepalive{
+		Probes:   annotation.TCPKeepaliveProbes(svc),
+		Time:     annotation.TCPKeepaliveTime(svc),
+		Interval: annotation.TCPKeepaliveInterval(svc),
+	}
+	if k == (TCPKeepalive{}) {
+		return nil
+	}
+	return &k
+}
+
 // serviceGetter is a visitor that gets all services
 // in the DAG.
 type serviceGetter map[RouteServiceName]*Service
@@ -139,9 +264,12 @@ func (s serviceGetter) visit(vertex Vertex) {
 	switch obj := vertex.(type) {
 	case *Service:
 		s[RouteServiceName{
-			Name:      obj.Weighted.ServiceName,
-			Namespace: obj.Weighted.ServiceNamespace,
-			Port:      obj.Weighted.ServicePort.Port,
+			Name:          obj.Weighted.ServiceName,
+			Namespace:     obj.Weighted.ServiceNamespace,
+			Port:          obj.Weighted.ServicePort.Port,
+			ServiceImport: obj.Weighted.ServiceImport,
+			PodSelector:   labels.Set(obj.Weighted.PodSelector).String(),
+			Static:        len(obj.StaticEndpoints) > 0,
 		}] = obj
 	default:
 		vertex.Visit(s.visit)
@@ -233,6 +361,48 @@ func (v vhostGetter) visit(vertex Vertex) {
 	}
 }
 
+// GetTCPVirtualHosts returns all TCP virtual hosts in the DAG.
+func (dag *DAG) GetTCPVirtualHosts() map[ListenerName]*TCPVirtualHost {
+	getter := tcpVhostGetter(map[ListenerName]*TCPVirtualHost{})
+	dag.Visit(getter.visit)
+	return getter
+}
+
+// GetTCPVirtualHost returns the TCP virtual host in the DAG that matches
+// the provided name, or nil if no matching TCP virtual host is found.
+func (dag *DAG) GetTCPVirtualHost(ln ListenerName) *TCPVirtualHost {
+	return dag.GetTCPVirtualHosts()[ln]
+}
+
+// EnsureTCPVirtualHost adds a TCP virtual host with the provided name
+// and port to the DAG if it does not already exist, and returns it.
+func (dag *DAG) EnsureTCPVirtualHost(ln ListenerName, port int) *TCPVirtualHost {
+	if vhost := dag.GetTCPVirtualHost(ln); vhost != nil {
+		return vhost
+	}
+
+	vhost := &TCPVirtualHost{
+		Name:         ln.Name,
+		ListenerName: ln.ListenerName,
+		Port:         port,
+	}
+	dag.AddRoot(vhost)
+	return vhost
+}
+
+// tcpVhostGetter is a visitor that gets all TCP virtual hosts
+// in the DAG.
+type tcpVhostGetter map[ListenerName]*TCPVirtualHost
+
+func (v tcpVhostGetter) visit(vertex Vertex) {
+	switch obj := vertex.(type) {
+	case *TCPVirtualHost:
+		v[ListenerName{Name: obj.Name, ListenerName: obj.ListenerName}] = obj
+	default:
+		vertex.Visit(v.visit)
+	}
+}
+
 // GetExtensionClusters returns all extension clusters in the DAG.
 func (dag *DAG) GetExtensionClusters() map[string]*ExtensionCluster {
 	getter := extensionClusterGetter(map[string]*ExtensionCluster{})
@@ -15,6 +15,7 @@ package dag
 
 import (
 	"github.com/projectcontour/contour/internal/status"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // Processor constructs part of a DAG.
@@ -32,6 +33,43 @@ func (pf ProcessorFunc) Run(dag *DAG, source *KubernetesCache) {
 	}
 }
 
+// registry holds the Processors, and the resources they read,
+// added with RegisterProcessor.
+var registry struct {
+	processors []Processor
+	resources  []schema.GroupVersionResource
+}
+
+// RegisterProcessor adds p to the Processors that Contour runs to build
+// each DAG, after its own processors and before the ListenerProcessor.
+// This allows a build of Contour to add processors for its own custom
+// resources without modifying the processors in this package.
+//
+// resources are the custom resources that p reads. Contour informs on
+// them and inserts their objects into the KubernetesCache unconverted,
+// where p can read them with KubernetesCache.LookupUnstructured and
+// KubernetesCache.ListUnstructured. Contour must be granted RBAC
+// permission to list and watch them.
+//
+// RegisterProcessor is not safe for concurrent use; it should be
+// called from an init function.
+func RegisterProcessor(p Processor, resources ...schema.GroupVersionResource) {
+	registry.processors = append(registry.processors, p)
+	registry.resources = append(registry.resources, resources...)
+}
+
+// RegisteredProcessors returns the Processors added with
+// RegisterProcessor, in the order they were registered.
+func RegisteredProcessors() []Processor {
+	return append([]Processor(nil), registry.processors...)
+}
+
+// RegisteredResources returns the resources read by the
+// Processors added with RegisterProcessor.
+func RegisteredResources() []schema.GroupVersionResource {
+	return append([]schema.GroupVersionResource(nil), registry.resources...)
+}
+
 // Builder builds a DAG.
 type Builder struct {
 	// Source is the source of Kubernetes objects
@@ -43,6 +81,20 @@ type Builder struct {
 	Processors []Processor
 }
 
+// RegisterProcessor adds p to the Processors of the Builder. Since
+// the ListenerProcessor looks at the output of the other processors,
+// p is added before it if it is the last Processor.
+func (b *Builder) RegisterProcessor(p Processor) {
+	n := len(b.Processors)
+	if n > 0 {
+		if _, ok := b.Processors[n-1].(*ListenerProcessor); ok {
+			b.Processors = append(b.Processors[:n-1], p, b.Processors[n-1])
+			return
+		}
+	}
+	b.Processors = append(b.Processors, p)
+}
+
 // Build builds and returns a new DAG by running the
 // configured DAG processors, in order.
 func (b *Builder) Build() *DAG {
@@ -53,5 +105,7 @@ func (b *Builder) Build() *DAG {
 	for _, p := range b.Processors {
 		p.Run(&dag, &b.Source)
 	}
+
+	intern(&dag)
 	return &dag
 }
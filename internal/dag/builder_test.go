@@ -16,17 +16,28 @@ package dag
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sort"
 	"testing"
 	"time"
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
 	"github.com/projectcontour/contour/internal/fixture"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
+	"github.com/projectcontour/contour/internal/status"
 	"github.com/projectcontour/contour/internal/timeout"
+	"github.com/projectcontour/contour/pkg/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
@@ -104,6 +115,53 @@ func TestDAGInsertGatewayAPI(t *testing.T) {
 		},
 	}
 
+	kuardServiceImport := &mcs_v1alpha1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "projectcontour",
+		},
+		Spec: mcs_v1alpha1.ServiceImportSpec{
+			Type: mcs_v1alpha1.ClusterSetIP,
+			Ports: []mcs_v1alpha1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	serviceImportHTTPRoute := &gatewayapi_v1alpha1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "basic",
+			Namespace: "projectcontour",
+			Labels: map[string]string{
+				"app":      "contour",
+				"type":     "controller",
+				"protocol": "http",
+			},
+		},
+		Spec: gatewayapi_v1alpha1.HTTPRouteSpec{
+			Gateways: &gatewayapi_v1alpha1.RouteGateways{
+				Allow: gatewayAllowTypePtr(gatewayapi_v1alpha1.GatewayAllowSameNamespace),
+			},
+			Hostnames: []gatewayapi_v1alpha1.Hostname{
+				"test.projectcontour.io",
+			},
+			Rules: []gatewayapi_v1alpha1.HTTPRouteRule{{
+				Matches: httpRouteMatch(gatewayapi_v1alpha1.PathMatchPrefix, "/"),
+				ForwardTo: []gatewayapi_v1alpha1.HTTPRouteForwardTo{{
+					BackendRef: &gatewayapi_v1alpha1.LocalObjectReference{
+						Group: mcs_v1alpha1.GroupVersion.Group,
+						Kind:  mcs_v1alpha1.ServiceImportKind,
+						Name:  "kuard",
+					},
+					Port:   gatewayPort(8080),
+					Weight: pointer.Int32Ptr(1),
+				}},
+			}},
+		},
+	}
+
 	kuardServiceCustomNs := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kuard",
@@ -305,6 +363,26 @@ func TestDAGInsertGatewayAPI(t *testing.T) {
 		},
 	}
 
+	gatewayTCPRoute := &gatewayapi_v1alpha1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "contour",
+			Namespace: "projectcontour",
+		},
+		Spec: gatewayapi_v1alpha1.GatewaySpec{
+			GatewayClassName: validClass.Name,
+			Listeners: []gatewayapi_v1alpha1.Listener{{
+				Port:     9000,
+				Protocol: gatewayapi_v1alpha1.TCPProtocolType,
+				Routes: gatewayapi_v1alpha1.RouteBindingSelector{
+					Kind: KindTCPRoute,
+					Namespaces: &gatewayapi_v1alpha1.RouteNamespaces{
+						From: routeSelectTypePtr(gatewayapi_v1alpha1.RouteSelectSame),
+					},
+				},
+			}},
+		},
+	}
+
 	gatewayTLSRouteModePassthrough := &gatewayapi_v1alpha1.Gateway{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "contour",
@@ -720,6 +798,105 @@ func TestDAGInsertGatewayAPI(t *testing.T) {
 				},
 			),
 		},
+		"insert basic single route to a service import": {
+			gatewayclass: validClass,
+			gateway:      gatewayWithSelector,
+			objs: []interface{}{
+				kuardService,
+				kuardServiceImport,
+				serviceImportHTTPRoute,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("test.projectcontour.io", prefixrouteHTTPRoute("/", &Service{
+							Weighted: WeightedService{
+								Weight:           1,
+								ServiceName:      "kuard",
+								ServiceNamespace: "projectcontour",
+								ServicePort: v1.ServicePort{
+									Name:     "http",
+									Protocol: "TCP",
+									Port:     8080,
+								},
+								ServiceImport: true,
+							},
+						})),
+					),
+				},
+			),
+		},
+		"insert basic single route to a missing service import": {
+			gatewayclass: validClass,
+			gateway:      gatewayWithSelector,
+			objs: []interface{}{
+				kuardService,
+				serviceImportHTTPRoute,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("test.projectcontour.io", directResponseRoute("/", http.StatusServiceUnavailable)),
+					),
+				},
+			),
+		},
+		"insert basic single route with request mirror filter": {
+			gatewayclass: validClass,
+			gateway:      gatewayWithSelector,
+			objs: []interface{}{
+				kuardService,
+				kuardService2,
+				&gatewayapi_v1alpha1.HTTPRoute{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "basic",
+						Namespace: "projectcontour",
+						Labels: map[string]string{
+							"app":      "contour",
+							"type":     "controller",
+							"protocol": "http",
+						},
+					},
+					Spec: gatewayapi_v1alpha1.HTTPRouteSpec{
+						Gateways: &gatewayapi_v1alpha1.RouteGateways{
+							Allow: gatewayAllowTypePtr(gatewayapi_v1alpha1.GatewayAllowSameNamespace),
+						},
+						Hostnames: []gatewayapi_v1alpha1.Hostname{
+							"test.projectcontour.io",
+						},
+						Rules: []gatewayapi_v1alpha1.HTTPRouteRule{{
+							Matches:   httpRouteMatch(gatewayapi_v1alpha1.PathMatchPrefix, "/"),
+							ForwardTo: httpRouteForwardTo("kuard", 8080, 1),
+							Filters: []gatewayapi_v1alpha1.HTTPRouteFilter{{
+								Type: gatewayapi_v1alpha1.HTTPRouteFilterRequestMirror,
+								RequestMirror: &gatewayapi_v1alpha1.HTTPRequestMirrorFilter{
+									ServiceName: pointer.StringPtr("kuard2"),
+									Port:        gatewayPort(8080),
+								},
+							}},
+						}},
+					},
+				},
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("test.projectcontour.io", &Route{
+							PathMatchCondition: prefixString("/"),
+							Clusters:           clustersWeight(service(kuardService)),
+							MirrorPolicy: &MirrorPolicy{
+								Cluster: &Cluster{
+									Upstream: service(kuardService2),
+								},
+							},
+						}),
+					),
+				},
+			),
+		},
 		"gateway with unsupported addresses": {
 			gatewayclass: validClass,
 			gateway:      gatewayWithAddresses,
@@ -1142,6 +1319,74 @@ func TestDAGInsertGatewayAPI(t *testing.T) {
 				},
 			),
 		},
+		"TCPRoute bound to a configured TCP listener port": {
+			gatewayclass: validClass,
+			gateway:      gatewayTCPRoute,
+			objs: []interface{}{
+				kuardService,
+				kuardService2,
+				&gatewayapi_v1alpha1.TCPRoute{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "basic",
+						Namespace: "projectcontour",
+					},
+					Spec: gatewayapi_v1alpha1.TCPRouteSpec{
+						Rules: []gatewayapi_v1alpha1.TCPRouteRule{{
+							ForwardTo: append(tcpRouteForwardTo("kuard", 8080, 0), tcpRouteForwardTo("kuard2", 8080, 0)...),
+						}},
+					},
+				},
+			},
+			want: listeners(
+				&Listener{
+					Port: 9000,
+					VirtualHosts: virtualhosts(
+						&TCPVirtualHost{
+							Name:         "tcp-9000",
+							ListenerName: "tcp-9000",
+							Port:         9000,
+							TCPProxy: &TCPProxy{
+								Clusters: clusters(service(kuardService), service(kuardService2)),
+							},
+						},
+					),
+				},
+			),
+		},
+		"TCPRoute bound to a TCP listener port that is not configured": {
+			gatewayclass: validClass,
+			gateway: &gatewayapi_v1alpha1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "contour",
+					Namespace: "projectcontour",
+				},
+				Spec: gatewayapi_v1alpha1.GatewaySpec{
+					GatewayClassName: validClass.Name,
+					Listeners: []gatewayapi_v1alpha1.Listener{{
+						Port:     9001,
+						Protocol: gatewayapi_v1alpha1.TCPProtocolType,
+						Routes: gatewayapi_v1alpha1.RouteBindingSelector{
+							Kind: KindTCPRoute,
+						},
+					}},
+				},
+			},
+			objs: []interface{}{
+				kuardService,
+				&gatewayapi_v1alpha1.TCPRoute{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "basic",
+						Namespace: "projectcontour",
+					},
+					Spec: gatewayapi_v1alpha1.TCPRouteSpec{
+						Rules: []gatewayapi_v1alpha1.TCPRouteRule{{
+							ForwardTo: tcpRouteForwardTo("kuard", 8080, 0),
+						}},
+					},
+				},
+			},
+			want: listeners(),
+		},
 		"TLSRoute with TLS.Mode=Passthrough is invalid if certificateRef is specified": {
 			gatewayclass: validClass,
 			gateway: &gatewayapi_v1alpha1.Gateway{
@@ -3448,6 +3693,9 @@ func TestDAGInsertGatewayAPI(t *testing.T) {
 					},
 					&GatewayAPIProcessor{
 						FieldLogger: fixture.NewTestLogger(t),
+						TCPListeners: map[int]string{
+							9000: "tcp-9000",
+						},
 					},
 					&ListenerProcessor{},
 				},
@@ -4355,6 +4603,63 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	proxyAdditionalFqdns := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:            "foo.com",
+				AdditionalFqdns: []string{"www.foo.com", "bar.com"},
+				TLS: &contour_api_v1.TLS{
+					SecretName:             sec1.Name,
+					MinimumProtocolVersion: "1.2",
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	proxyGRPC := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				GRPC: &contour_api_v1.GRPCRoute{
+					Service:    "helloworld.Greeter",
+					MaxTimeout: "10s",
+				},
+				Services: []contour_api_v1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}, {
+				GRPC: &contour_api_v1.GRPCRoute{
+					Service: "helloworld.Greeter",
+					Method:  "SayHello",
+				},
+				Services: []contour_api_v1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
 	proxyMinTLS13 := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "example-com",
@@ -4537,6 +4842,17 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	// proxyHedgePolicy hedges requests on per-try timeouts.
+	proxyHedgePolicy := proxyRetryPolicyValidTimeout.DeepCopy()
+	proxyHedgePolicy.Spec.Routes[0].HedgePolicy = &contour_api_v1.HedgePolicy{
+		HedgeOnPerTryTimeout: true,
+	}
+
+	// proxyHedgePolicyNoPerTryTimeout hedges requests on per-try
+	// timeouts without a per-try timeout, invalid.
+	proxyHedgePolicyNoPerTryTimeout := proxyHedgePolicy.DeepCopy()
+	proxyHedgePolicyNoPerTryTimeout.Spec.Routes[0].RetryPolicy.PerTryTimeout = ""
+
 	proxyTimeoutPolicyInvalidResponse := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "bar-com",
@@ -5461,8 +5777,8 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
-	// proxy13 has two mirrors, invalid.
-	proxy13 := &contour_api_v1.HTTPProxy{
+	// proxy12a sets a header on the requests it mirrors.
+	proxy12a := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "example-com",
 			Namespace: s1.Namespace,
@@ -5482,29 +5798,228 @@ func TestDAGInsert(t *testing.T) {
 					Name:   s2.Name,
 					Port:   8080,
 					Mirror: true,
-				}, {
-					// it is legal to mention a service more that
-					// once, however it is not legal for more than one
-					// service to be marked as mirror.
-					Name:   s2.Name,
-					Port:   8080,
-					Mirror: true,
+					MirrorPolicy: &contour_api_v1.MirrorPolicy{
+						RequestHeaders: []contour_api_v1.HeaderValue{{
+							Name:  "x-shadow",
+							Value: "true",
+						}},
+					},
 				}},
 			}},
 		},
 	}
 
-	// proxy20 is downstream validation, skip cert validation
-	proxy20 := &contour_api_v1.HTTPProxy{
+	// s1h is a headless version of s1.
+	s1h := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "example-com",
+			Name:      "kuard",
 			Namespace: "default",
 		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
+	// proxyPodSelector routes to a subset of the endpoints of s1.
+	proxyPodSelector := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: s1.Namespace,
+		},
 		Spec: contour_api_v1.HTTPProxySpec{
 			VirtualHost: &contour_api_v1.VirtualHost{
 				Fqdn: "example.com",
-				TLS: &contour_api_v1.TLS{
-					SecretName: sec1.Name,
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: s1.Name,
+					Port: 8080,
+					PodSelector: map[string]string{
+						"shard": "1",
+					},
+				}},
+			}},
+		},
+	}
+
+	// proxyStaticEndpoints routes to a backend outside the cluster.
+	proxyStaticEndpoints := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "legacy",
+					Port: 8080,
+					Endpoints: []contour_api_v1.StaticEndpoint{{
+						Address: "10.0.0.1",
+					}, {
+						Address: "10.0.0.2",
+						Port:    9090,
+					}},
+				}},
+			}},
+		},
+	}
+
+	// proxyInvalidStaticEndpoints has an endpoint that is not an IP address.
+	proxyInvalidStaticEndpoints := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "legacy",
+					Port: 8080,
+					Endpoints: []contour_api_v1.StaticEndpoint{{
+						Address: "legacy.example.com",
+					}},
+				}},
+			}},
+		},
+	}
+
+	// proxyDynamicForwardProxy routes to the host named by the Host header.
+	proxyDynamicForwardProxy := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				DynamicForwardProxy: true,
+			}},
+		},
+	}
+
+	// proxyDynamicForwardProxyWithServices sets both services and
+	// dynamicForwardProxy, invalid.
+	proxyDynamicForwardProxyWithServices := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				DynamicForwardProxy: true,
+				Services: []contour_api_v1.Service{{
+					Name: s1.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	// proxy13 has two mirrors, invalid.
+	proxy13 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: s1.Name,
+					Port: 8080,
+				}, {
+					Name:   s2.Name,
+					Port:   8080,
+					Mirror: true,
+				}, {
+					// it is legal to mention a service more that
+					// once, however it is not legal for more than one
+					// service to be marked as mirror.
+					Name:   s2.Name,
+					Port:   8080,
+					Mirror: true,
+				}},
+			}},
+		},
+	}
+
+	// proxy14 sends oversized requests to an overflow service.
+	proxy14 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: s1.Name,
+					Port: 8080,
+				}},
+				OverflowPolicy: &contour_api_v1.OverflowPolicy{
+					MaxRequestBodyBytes: 1048576,
+					Service: contour_api_v1.Service{
+						Name: s2.Name,
+						Port: 8080,
+					},
+				},
+			}},
+		},
+	}
+
+	// proxy20 is downstream validation, skip cert validation
+	proxy20 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: sec1.Name,
 					ClientValidation: &contour_api_v1.DownstreamValidation{
 						SkipClientCertValidation: true,
 					},
@@ -5698,13 +6213,18 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
-	proxy40 := &contour_api_v1.HTTPProxy{
+	// proxy39plain is a valid TCPProxy on a plain TCP listener port
+	proxy39plain := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "foo",
+			Name:      "redis",
 			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "redis.example.com",
+			},
 			TCPProxy: &contour_api_v1.TCPProxy{
+				Port: 6379,
 				Services: []contour_api_v1.Service{{
 					Name: s1.Name,
 					Port: 8080,
@@ -5713,142 +6233,286 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
-	// issue 2309, each route must have at least one service
-	proxy41 := &contour_api_v1.HTTPProxy{
+	// proxy39plaintimeouts configures timeouts and connect attempts
+	proxy39plaintimeouts := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "missing-service",
+			Name:      "redis",
 			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
 			VirtualHost: &contour_api_v1.VirtualHost{
-				Fqdn: "missing-service.example.com",
+				Fqdn: "redis.example.com",
 			},
-			Routes: []contour_api_v1.Route{{
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/",
+			TCPProxy: &contour_api_v1.TCPProxy{
+				Port: 6379,
+				Services: []contour_api_v1.Service{{
+					Name: s1.Name,
+					Port: 8080,
 				}},
-				Services: nil, // missing
-			}},
+				TimeoutPolicy: &contour_api_v1.TCPProxyTimeoutPolicy{
+					Idle:    "infinity",
+					Connect: "2s",
+				},
+				MaxConnectAttempts: 3,
+			},
 		},
 	}
 
-	proxy100 := &contour_api_v1.HTTPProxy{
+	// proxy39plainunknown selects a port that is not a configured TCP listener
+	proxy39plainunknown := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "example-com",
+			Name:      "memcached",
 			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
 			VirtualHost: &contour_api_v1.VirtualHost{
-				Fqdn: "example.com",
+				Fqdn: "memcached.example.com",
 			},
-			Includes: []contour_api_v1.Include{{
-				Name:      "marketingwww",
-				Namespace: "marketing",
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/blog",
-				}},
-			}},
-			Routes: []contour_api_v1.Route{{
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/",
-				}},
+			TCPProxy: &contour_api_v1.TCPProxy{
+				Port: 11211,
 				Services: []contour_api_v1.Service{{
 					Name: s1.Name,
 					Port: 8080,
 				}},
-			}},
+			},
 		},
 	}
 
-	proxy100a := &contour_api_v1.HTTPProxy{
+	// proxyLegacyHTTP is served on an additional HTTP listener
+	proxyLegacyHTTP := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "marketingwww",
-			Namespace: "marketing",
+			Name:      "legacy",
+			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:      "legacy.example.com",
+				Listeners: []string{"legacy_http"},
+			},
 			Routes: []contour_api_v1.Route{{
 				Services: []contour_api_v1.Service{{
-					Name: "blog",
+					Name: s1.Name,
 					Port: 8080,
 				}},
 			}},
 		},
 	}
 
-	proxy100b := &contour_api_v1.HTTPProxy{
+	// proxyLegacyHTTPS is served on the default and an additional
+	// HTTPS listener, but not over plain HTTP
+	proxyLegacyHTTPS := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "marketingwww",
-			Namespace: "marketing",
+			Name:      "legacy-tls",
+			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:      "foo.com",
+				Listeners: []string{"ingress_https", "legacy_https"},
+				TLS: &contour_api_v1.TLS{
+					SecretName: sec1.Name,
+				},
+			},
 			Routes: []contour_api_v1.Route{{
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/infotech",
-				}},
 				Services: []contour_api_v1.Service{{
-					Name: "blog",
+					Name: s1.Name,
 					Port: 8080,
 				}},
 			}},
 		},
 	}
 
-	proxy100c := &contour_api_v1.HTTPProxy{
+	// proxyLegacyHTTPSNoTLS selects an HTTPS listener without TLS
+	proxyLegacyHTTPSNoTLS := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "marketingwww",
-			Namespace: "marketing",
+			Name:      "legacy-notls",
+			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
-			Includes: []contour_api_v1.Include{{
-				Name:      "marketingit",
-				Namespace: "it",
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/it",
-				}},
-			}},
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:      "legacy.example.com",
+				Listeners: []string{"legacy_https"},
+			},
 			Routes: []contour_api_v1.Route{{
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/infotech",
-				}},
 				Services: []contour_api_v1.Service{{
-					Name: "blog",
-					Port: 8080,
-				}},
-			}, {
-				Services: []contour_api_v1.Service{{
-					Name: "blog",
+					Name: s1.Name,
 					Port: 8080,
 				}},
 			}},
 		},
 	}
 
-	proxy100d := &contour_api_v1.HTTPProxy{
+	// proxyUnknownListener selects a listener that is not configured
+	proxyUnknownListener := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "marketingit",
-			Namespace: "it",
+			Name:      "unknown",
+			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn:      "unknown.example.com",
+				Listeners: []string{"unknown_http"},
+			},
 			Routes: []contour_api_v1.Route{{
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/foo",
-				}},
 				Services: []contour_api_v1.Service{{
-					Name: "blog",
+					Name: s1.Name,
 					Port: 8080,
 				}},
 			}},
 		},
 	}
 
-	// proxy101 and proxy101a test inclusion without a specified namespace.
-	proxy101 := &contour_api_v1.HTTPProxy{
+	proxy40 := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "example-com",
+			Name:      "foo",
 			Namespace: s1.Namespace,
 		},
 		Spec: contour_api_v1.HTTPProxySpec{
-			VirtualHost: &contour_api_v1.VirtualHost{
+			TCPProxy: &contour_api_v1.TCPProxy{
+				Services: []contour_api_v1.Service{{
+					Name: s1.Name,
+					Port: 8080,
+				}},
+			},
+		},
+	}
+
+	// issue 2309, each route must have at least one service
+	proxy41 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "missing-service",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "missing-service.example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: nil, // missing
+			}},
+		},
+	}
+
+	proxy100 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []contour_api_v1.Include{{
+				Name:      "marketingwww",
+				Namespace: "marketing",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/blog",
+				}},
+			}},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: s1.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	proxy100a := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "marketingwww",
+			Namespace: "marketing",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: "blog",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	proxy100b := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "marketingwww",
+			Namespace: "marketing",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/infotech",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "blog",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	proxy100c := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "marketingwww",
+			Namespace: "marketing",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Includes: []contour_api_v1.Include{{
+				Name:      "marketingit",
+				Namespace: "it",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/it",
+				}},
+			}},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/infotech",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "blog",
+					Port: 8080,
+				}},
+			}, {
+				Services: []contour_api_v1.Service{{
+					Name: "blog",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	proxy100d := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "marketingit",
+			Namespace: "it",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/foo",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "blog",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	// proxy101 and proxy101a test inclusion without a specified namespace.
+	proxy101 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
 				Fqdn: "example.com",
 			},
 			Includes: []contour_api_v1.Include{{
@@ -6421,6 +7085,52 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	proxyLoadBalancerHashPolicySourceIP := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "nginx",
+					Port: 80,
+				}},
+				LoadBalancerPolicy: &contour_api_v1.LoadBalancerPolicy{
+					Strategy: "RequestHash",
+					RequestHashPolicies: []contour_api_v1.RequestHashPolicy{
+						{
+							Terminal: true,
+							HeaderHashOptions: &contour_api_v1.HeaderHashOptions{
+								HeaderName: "X-Tenant-ID",
+							},
+						},
+						{
+							// Both hash options set, should be ignored.
+							HeaderHashOptions: &contour_api_v1.HeaderHashOptions{
+								HeaderName: "X-Some-Header",
+							},
+							HashSourceIP: true,
+						},
+						{
+							HashSourceIP: true,
+						},
+						{
+							// Duplicated, should be ignored.
+							HashSourceIP: true,
+						},
+					},
+				},
+			}},
+		},
+	}
+
 	proxyLoadBalancerHashPolicyHeaderAllInvalid := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "example-com",
@@ -6571,6 +7281,56 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	// proxyHTTP2Settings tunes the HTTP/2 connections to an h2c service.
+	proxyHTTP2Settings := proxy110.DeepCopy()
+	proxyHTTP2Settings.Spec.Routes[0].Services[0].HTTP2 = &contour_api_v1.HTTP2Settings{
+		MaxConcurrentStreams: 100,
+		KeepaliveInterval:    "10s",
+		KeepaliveTimeout:     "5s",
+	}
+
+	// proxyHTTP2SettingsNoProtocol sets HTTP/2 settings on a
+	// service that does not use HTTP/2, invalid.
+	proxyHTTP2SettingsNoProtocol := proxyHTTP2Settings.DeepCopy()
+	proxyHTTP2SettingsNoProtocol.Spec.Routes[0].Services[0].Protocol = nil
+
+	// proxyHTTP2SettingsNoKeepaliveTimeout sets a keepalive interval
+	// without a timeout, invalid.
+	proxyHTTP2SettingsNoKeepaliveTimeout := proxyHTTP2Settings.DeepCopy()
+	proxyHTTP2SettingsNoKeepaliveTimeout.Spec.Routes[0].Services[0].HTTP2.KeepaliveTimeout = ""
+
+	// proxyFailover fails over from kuard to kuarder.
+	proxyFailover := proxy110.DeepCopy()
+	proxyFailover.Spec.Routes[0].Services = []contour_api_v1.Service{{
+		Name: "kuard",
+		Port: 8080,
+	}, {
+		Name:     "kuarder",
+		Port:     8080,
+		Failover: true,
+	}}
+
+	// proxyFailoverOnly has no primary service, invalid.
+	proxyFailoverOnly := proxyFailover.DeepCopy()
+	proxyFailoverOnly.Spec.Routes[0].Services = proxyFailoverOnly.Spec.Routes[0].Services[1:]
+
+	// proxyFailoverMirror mirrors to a failover service, invalid.
+	proxyFailoverMirror := proxyFailover.DeepCopy()
+	proxyFailoverMirror.Spec.Routes[0].Services[1].Mirror = true
+
+	// proxyGRPCHealthCheck checks the health of an h2c service with gRPC.
+	proxyGRPCHealthCheck := proxy110.DeepCopy()
+	proxyGRPCHealthCheck.Spec.Routes[0].HealthCheckPolicy = &contour_api_v1.HTTPHealthCheckPolicy{
+		GRPC: &contour_api_v1.GRPCHealthCheckPolicy{
+			ServiceName: "helloworld.Greeter",
+		},
+	}
+
+	// proxyGRPCHealthCheckNoProtocol checks the health of an
+	// HTTP/1.1 service with gRPC, invalid.
+	proxyGRPCHealthCheckNoProtocol := proxyGRPCHealthCheck.DeepCopy()
+	proxyGRPCHealthCheckNoProtocol.Spec.Routes[0].Services[0].Protocol = nil
+
 	ingressExternalNameService := &networking_v1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "externalname",
@@ -6637,6 +7397,7 @@ func TestDAGInsert(t *testing.T) {
 		objs                         []interface{}
 		disablePermitInsecure        bool
 		enableExternalNameSvc        bool
+		enableDynamicForwardProxy    bool
 		fallbackCertificateName      string
 		fallbackCertificateNamespace string
 		want                         []Vertex
@@ -7153,19 +7914,32 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
-		"insert httpproxy with tls version 1.3": {
+		"insert httpproxy with additional fqdns": {
 			objs: []interface{}{
-				proxyMinTLS13, s1, sec1,
+				proxyAdditionalFqdns, s1, sec1,
 			},
 			want: listeners(
 				&Listener{
 					Port: 80,
 					VirtualHosts: virtualhosts(
+						virtualhost("bar.com", routeUpgrade("/", service(s1))),
 						virtualhost("foo.com", routeUpgrade("/", service(s1))),
+						virtualhost("www.foo.com", routeUpgrade("/", service(s1))),
 					),
 				}, &Listener{
 					Port: 443,
 					VirtualHosts: virtualhosts(
+						&SecureVirtualHost{
+							VirtualHost: VirtualHost{
+								Name:         "bar.com",
+								ListenerName: "ingress_https",
+								routes: routes(
+									routeUpgrade("/", service(s1)),
+								),
+							},
+							MinTLSVersion: "1.2",
+							Secret:        secret(sec1),
+						},
 						&SecureVirtualHost{
 							VirtualHost: VirtualHost{
 								Name:         "foo.com",
@@ -7174,55 +7948,119 @@ func TestDAGInsert(t *testing.T) {
 									routeUpgrade("/", service(s1)),
 								),
 							},
-							MinTLSVersion: "1.3",
+							MinTLSVersion: "1.2",
+							Secret:        secret(sec1),
+						},
+						&SecureVirtualHost{
+							VirtualHost: VirtualHost{
+								Name:         "www.foo.com",
+								ListenerName: "ingress_https",
+								routes: routes(
+									routeUpgrade("/", service(s1)),
+								),
+							},
+							MinTLSVersion: "1.2",
 							Secret:        secret(sec1),
 						},
 					),
 				},
 			),
 		},
-		"insert httpproxy with invalid tls version": {
+		"insert httpproxy with grpc routes": {
 			objs: []interface{}{
-				proxyMinTLSInvalid, s1, sec1,
+				proxyGRPC, s1,
 			},
 			want: listeners(
 				&Listener{
 					Port: 80,
 					VirtualHosts: virtualhosts(
-						virtualhost("foo.com", routeUpgrade("/", service(s1))),
-					),
-				}, &Listener{
-					Port: 443,
-					VirtualHosts: virtualhosts(
-						securevirtualhost("foo.com", sec1, routeUpgrade("/", service(s1))),
+						virtualhost("example.com",
+							&Route{
+								PathMatchCondition:   prefixString("/helloworld.Greeter/"),
+								Clusters:             clustermap(s1),
+								GRPC:                 true,
+								GRPCTimeoutHeaderMax: timeout.DurationSetting(10 * time.Second),
+							},
+							&Route{
+								PathMatchCondition: exact("/helloworld.Greeter/SayHello"),
+								Clusters:           clustermap(s1),
+								GRPC:               true,
+							},
+						),
 					),
 				},
 			),
 		},
-		"insert httpproxy referencing two backends, one missing": {
-			objs: []interface{}{
-				proxyMultipleBackends, s2,
-			},
-			want: listeners(),
-		},
-		"insert httpproxy referencing two backends": {
+		"insert httpproxy with tls version 1.3": {
 			objs: []interface{}{
-				proxyMultipleBackends, s1, s2,
+				proxyMinTLS13, s1, sec1,
 			},
 			want: listeners(
 				&Listener{
 					Port: 80,
 					VirtualHosts: virtualhosts(
-						virtualhost("example.com", prefixroute("/", service(s1), service(s2))),
+						virtualhost("foo.com", routeUpgrade("/", service(s1))),
 					),
-				},
-			),
-		},
-		"ingressv1: insert ingress w/ tls min proto annotation": {
-			objs: []interface{}{
-				i10aV1,
-				sec1,
-				s1,
+				}, &Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						&SecureVirtualHost{
+							VirtualHost: VirtualHost{
+								Name:         "foo.com",
+								ListenerName: "ingress_https",
+								routes: routes(
+									routeUpgrade("/", service(s1)),
+								),
+							},
+							MinTLSVersion: "1.3",
+							Secret:        secret(sec1),
+						},
+					),
+				},
+			),
+		},
+		"insert httpproxy with invalid tls version": {
+			objs: []interface{}{
+				proxyMinTLSInvalid, s1, sec1,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("foo.com", routeUpgrade("/", service(s1))),
+					),
+				}, &Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						securevirtualhost("foo.com", sec1, routeUpgrade("/", service(s1))),
+					),
+				},
+			),
+		},
+		"insert httpproxy referencing two backends, one missing": {
+			objs: []interface{}{
+				proxyMultipleBackends, s2,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy referencing two backends": {
+			objs: []interface{}{
+				proxyMultipleBackends, s1, s2,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", prefixroute("/", service(s1), service(s2))),
+					),
+				},
+			),
+		},
+		"ingressv1: insert ingress w/ tls min proto annotation": {
+			objs: []interface{}{
+				i10aV1,
+				sec1,
+				s1,
 			},
 			want: listeners(
 				&Listener{
@@ -7498,6 +8336,38 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert httpproxy with hedge policy": {
+			objs: []interface{}{
+				proxyHedgePolicy,
+				s1,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("bar.com", &Route{
+							PathMatchCondition: prefixString("/"),
+							Clusters:           clustermap(s1),
+							RetryPolicy: &RetryPolicy{
+								RetryOn:       "5xx",
+								NumRetries:    6,
+								PerTryTimeout: timeout.DurationSetting(10 * time.Second),
+							},
+							HedgePolicy: &HedgePolicy{
+								HedgeOnPerTryTimeout: true,
+							},
+						}),
+					),
+				},
+			),
+		},
+		"insert httpproxy with hedge policy without per-try timeout": {
+			objs: []interface{}{
+				proxyHedgePolicyNoPerTryTimeout,
+				s1,
+			},
+			want: listeners(),
+		},
 		"ingressv1: insert ingress with timeout policy": {
 			objs: []interface{}{
 				i14V1,
@@ -7986,12 +8856,158 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert httpproxy with mirroring route with mirror policy": {
+			objs: []interface{}{
+				proxy12a, s1, s2,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com",
+							&Route{
+								PathMatchCondition: prefixString("/"),
+								Clusters:           clusters(service(s1)),
+								MirrorPolicy: &MirrorPolicy{
+									Cluster: &Cluster{
+										Upstream: service(s2),
+									},
+									RequestHeadersPolicy: &HeadersPolicy{
+										Set: map[string]string{"X-Shadow": "true"},
+									},
+								},
+							},
+						),
+					),
+				},
+			),
+		},
 		"insert httpproxy with two mirrors": {
 			objs: []interface{}{
 				proxy13, s1, s2,
 			},
 			want: listeners(),
 		},
+		"insert httpproxy with pod selector on headless service": {
+			objs: []interface{}{
+				proxyPodSelector, s1h,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com",
+							prefixroute("/", &Service{
+								Weighted: WeightedService{
+									Weight:           1,
+									ServiceName:      s1h.Name,
+									ServiceNamespace: s1h.Namespace,
+									ServicePort:      s1h.Spec.Ports[0],
+									PodSelector: map[string]string{
+										"shard": "1",
+									},
+								},
+							}),
+						),
+					),
+				},
+			),
+		},
+		"insert httpproxy with pod selector on non-headless service": {
+			objs: []interface{}{
+				proxyPodSelector, s1,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy with static endpoints": {
+			objs: []interface{}{
+				proxyStaticEndpoints,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com",
+							prefixroute("/", &Service{
+								Weighted: WeightedService{
+									Weight:           1,
+									ServiceName:      "legacy",
+									ServiceNamespace: "default",
+									ServicePort: v1.ServicePort{
+										Protocol: "TCP",
+										Port:     8080,
+									},
+								},
+								StaticEndpoints: []StaticEndpoint{
+									{Address: "10.0.0.1", Port: 8080},
+									{Address: "10.0.0.2", Port: 9090},
+								},
+							}),
+						),
+					),
+				},
+			),
+		},
+		"insert httpproxy with invalid static endpoints": {
+			objs: []interface{}{
+				proxyInvalidStaticEndpoints,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy with dynamic forward proxy": {
+			objs: []interface{}{
+				proxyDynamicForwardProxy,
+			},
+			enableDynamicForwardProxy: true,
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", &Route{
+							PathMatchCondition:  prefixString("/"),
+							DynamicForwardProxy: &DynamicForwardProxyCluster{},
+						}),
+					),
+				},
+			),
+		},
+		"insert httpproxy with dynamic forward proxy not enabled": {
+			objs: []interface{}{
+				proxyDynamicForwardProxy,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy with dynamic forward proxy and services": {
+			objs: []interface{}{
+				proxyDynamicForwardProxyWithServices, s1,
+			},
+			enableDynamicForwardProxy: true,
+			want:                      listeners(),
+		},
+		"insert httpproxy with overflow policy": {
+			objs: []interface{}{
+				proxy14, s1, s2,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com",
+							prefixroute("/", service(s1)),
+							&Route{
+								PathMatchCondition: prefixString("/"),
+								HeaderMatchConditions: []HeaderMatchCondition{{
+									Name:      "Content-Length",
+									Value:     "1048576",
+									MatchType: HeaderMatchTypeGreaterThan,
+								}},
+								Clusters: clusters(service(s2)),
+							},
+						),
+					),
+				},
+			),
+		},
 		"insert httpproxy with websocket route and prefix rewrite": {
 			objs: []interface{}{
 				proxy10, s1,
@@ -8039,6 +9055,97 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert httpproxy with http2 settings": {
+			objs: []interface{}{
+				proxyHTTP2Settings, s1,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com",
+							routeCluster("/", &Cluster{
+								Upstream: service(s1),
+								Protocol: protocol,
+								HTTP2: &HTTP2Settings{
+									MaxConcurrentStreams: 100,
+									KeepaliveInterval:    10 * time.Second,
+									KeepaliveTimeout:     5 * time.Second,
+								},
+							})),
+					),
+				},
+			),
+		},
+		"insert httpproxy with http2 settings without http2 protocol": {
+			objs: []interface{}{
+				proxyHTTP2SettingsNoProtocol, s1,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy with http2 keepalive interval without timeout": {
+			objs: []interface{}{
+				proxyHTTP2SettingsNoKeepaliveTimeout, s1,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy with failover service": {
+			objs: []interface{}{
+				proxyFailover, s1, s2,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com",
+							routeCluster("/", &Cluster{
+								Upstream: service(s1),
+								Failover: []*Service{service(s2)},
+							})),
+					),
+				},
+			),
+		},
+		"insert httpproxy with only a failover service": {
+			objs: []interface{}{
+				proxyFailoverOnly, s2,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy with mirrored failover service": {
+			objs: []interface{}{
+				proxyFailoverMirror, s1, s2,
+			},
+			want: listeners(),
+		},
+		"insert httpproxy with grpc health check": {
+			objs: []interface{}{
+				proxyGRPCHealthCheck, s1,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com",
+							routeCluster("/", &Cluster{
+								Upstream: service(s1),
+								Protocol: protocol,
+								HTTPHealthCheckPolicy: &HTTPHealthCheckPolicy{
+									GRPC: &GRPCHealthCheckPolicy{
+										ServiceName: "helloworld.Greeter",
+									},
+								},
+							})),
+					),
+				},
+			),
+		},
+		"insert httpproxy with grpc health check without http2 protocol": {
+			objs: []interface{}{
+				proxyGRPCHealthCheckNoProtocol, s1,
+			},
+			want: listeners(),
+		},
 
 		"insert httpproxy without tls version": {
 			objs: []interface{}{
@@ -8372,18 +9479,16 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
-		// Issue #2218
-		"insert httpproxy w/tcpproxy w/include plural": {
-			objs: []interface{}{proxy39brootplural, proxy39bchild, s1},
+		"insert httpproxy w/tcpproxy on plain tcp listener": {
+			objs: []interface{}{proxy39plain, s1},
 			want: listeners(
 				&Listener{
-					Port: 443,
+					Port: 6379,
 					VirtualHosts: virtualhosts(
-						&SecureVirtualHost{
-							VirtualHost: VirtualHost{
-								Name:         "www.example.com",
-								ListenerName: "ingress_https",
-							},
+						&TCPVirtualHost{
+							Name:         "redis.example.com",
+							ListenerName: "redis",
+							Port:         6379,
 							TCPProxy: &TCPProxy{
 								Clusters: clusters(
 									service(s1),
@@ -8394,7 +9499,99 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
-		"insert httpproxy w/ tcpproxy w/ includes valid child": {
+		"insert httpproxy w/tcpproxy timeout policy": {
+			objs: []interface{}{proxy39plaintimeouts, s1},
+			want: listeners(
+				&Listener{
+					Port: 6379,
+					VirtualHosts: virtualhosts(
+						&TCPVirtualHost{
+							Name:         "redis.example.com",
+							ListenerName: "redis",
+							Port:         6379,
+							TCPProxy: &TCPProxy{
+								Clusters: []*Cluster{{
+									Upstream:       service(s1),
+									ConnectTimeout: 2 * time.Second,
+								}},
+								IdleTimeout:        timeout.DisabledSetting(),
+								MaxConnectAttempts: 3,
+							},
+						},
+					),
+				},
+			),
+		},
+		"insert httpproxy w/tcpproxy on unconfigured tcp listener": {
+			objs: []interface{}{proxy39plainunknown, s1},
+			want: listeners(),
+		},
+		"insert httpproxy on additional http listener": {
+			objs: []interface{}{proxyLegacyHTTP, s1},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						&VirtualHost{
+							Name:         "legacy.example.com",
+							ListenerName: "legacy_http",
+							routes:       routes(prefixroute("/", service(s1))),
+						},
+					),
+				},
+			),
+		},
+		"insert httpproxy on additional https listener": {
+			objs: []interface{}{proxyLegacyHTTPS, s1, sec1},
+			want: listeners(
+				&Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						securevirtualhost("foo.com", sec1, routeUpgrade("/", service(s1))),
+						&SecureVirtualHost{
+							VirtualHost: VirtualHost{
+								Name:         "foo.com",
+								ListenerName: "legacy_https",
+								routes:       routes(routeUpgrade("/", service(s1))),
+							},
+							MinTLSVersion: "1.2",
+							Secret:        secret(sec1),
+						},
+					),
+				},
+			),
+		},
+		"insert httpproxy on https listener without tls": {
+			objs: []interface{}{proxyLegacyHTTPSNoTLS, s1},
+			want: listeners(),
+		},
+		"insert httpproxy on unconfigured listener": {
+			objs: []interface{}{proxyUnknownListener, s1},
+			want: listeners(),
+		},
+		// Issue #2218
+		"insert httpproxy w/tcpproxy w/include plural": {
+			objs: []interface{}{proxy39brootplural, proxy39bchild, s1},
+			want: listeners(
+				&Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						&SecureVirtualHost{
+							VirtualHost: VirtualHost{
+								Name:         "www.example.com",
+								ListenerName: "ingress_https",
+							},
+							TCPProxy: &TCPProxy{
+								Clusters: clusters(
+									service(s1),
+								),
+							},
+						},
+					),
+				},
+			),
+		},
+		"insert httpproxy w/ tcpproxy w/ includes valid child": {
 			objs: []interface{}{proxy38, proxy40, s1},
 			want: listeners(
 				&Listener{
@@ -9404,6 +10601,36 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert proxy with load balancer request source ip hash policy fallback": {
+			objs: []interface{}{
+				proxyLoadBalancerHashPolicySourceIP,
+				s9,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", &Route{
+							PathMatchCondition: prefixString("/"),
+							Clusters: []*Cluster{
+								{Upstream: service(s9), LoadBalancerPolicy: "RequestHash"},
+							},
+							RequestHashPolicies: []RequestHashPolicy{
+								{
+									Terminal: true,
+									HeaderHashOptions: &HeaderHashOptions{
+										HeaderName: "X-Tenant-Id",
+									},
+								},
+								{
+									HashSourceIP: true,
+								},
+							},
+						}),
+					),
+				},
+			),
+		},
 		"insert proxy with all invalid request header hash policies": {
 			objs: []interface{}{
 				proxyLoadBalancerHashPolicyHeaderAllInvalid,
@@ -9905,11 +11132,299 @@ func TestDAGInsert(t *testing.T) {
 					},
 					&HTTPProxyProcessor{
 						EnableExternalNameService: tc.enableExternalNameSvc,
+						EnableDynamicForwardProxy: tc.enableDynamicForwardProxy,
 						DisablePermitInsecure:     tc.disablePermitInsecure,
 						FallbackCertificate: &types.NamespacedName{
 							Name:      tc.fallbackCertificateName,
 							Namespace: tc.fallbackCertificateNamespace,
 						},
+						TCPListeners: map[int]string{
+							6379: "redis",
+						},
+						HTTPListeners: map[string]bool{
+							"legacy_http": true,
+						},
+						HTTPSListeners: map[string]bool{
+							"legacy_https": true,
+						},
+					},
+					&ListenerProcessor{},
+				},
+			}
+
+			for _, o := range tc.objs {
+				builder.Source.Insert(o)
+			}
+			dag := builder.Build()
+
+			got := make(map[int]*Listener)
+			dag.Visit(listenerMap(got).Visit)
+
+			want := make(map[int]*Listener)
+			for _, v := range tc.want {
+				if l, ok := v.(*Listener); ok {
+					want[l.Port] = l
+				}
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestDAGInsertKnativeIngress(t *testing.T) {
+	hello1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hello-00001",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       80,
+				TargetPort: intstr.FromInt(8012),
+			}},
+		},
+	}
+
+	hello2 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hello-00002",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       80,
+				TargetPort: intstr.FromInt(8012),
+			}},
+		},
+	}
+
+	kingress := func(class string, spec knative_v1alpha1.IngressSpec) *knative_v1alpha1.Ingress {
+		return &knative_v1alpha1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "hello",
+				Namespace: "default",
+				Annotations: map[string]string{
+					knative_v1alpha1.ClassAnnotationKey: class,
+				},
+			},
+			Spec: spec,
+		}
+	}
+
+	split := func(name string, percent int) knative_v1alpha1.IngressBackendSplit {
+		return knative_v1alpha1.IngressBackendSplit{
+			IngressBackend: knative_v1alpha1.IngressBackend{
+				ServiceNamespace: "default",
+				ServiceName:      name,
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent: percent,
+			AppendHeaders: map[string]string{
+				"Knative-Serving-Revision": name,
+			},
+		}
+	}
+
+	cluster := func(s *v1.Service, weight uint32) *Cluster {
+		return &Cluster{
+			Upstream: service(s),
+			Weight:   weight,
+			RequestHeadersPolicy: &HeadersPolicy{
+				Set: map[string]string{
+					"Knative-Serving-Revision": s.Name,
+				},
+			},
+		}
+	}
+
+	knativeDefaults := func(r *Route) *Route {
+		r.Websocket = true
+		r.TimeoutPolicy = TimeoutPolicy{
+			ResponseTimeout: timeout.DisabledSetting(),
+		}
+		return r
+	}
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"traffic split with a revision tag": {
+			objs: []interface{}{
+				hello1,
+				hello2,
+				kingress(knative_v1alpha1.ContourIngressClassName, knative_v1alpha1.IngressSpec{
+					Rules: []knative_v1alpha1.IngressRule{{
+						Hosts:      []string{"hello.default.example.com"},
+						Visibility: knative_v1alpha1.IngressVisibilityExternalIP,
+						HTTP: &knative_v1alpha1.HTTPIngressRuleValue{
+							Paths: []knative_v1alpha1.HTTPIngressPath{{
+								Headers: map[string]knative_v1alpha1.HeaderMatch{
+									"Knative-Serving-Tag": {Exact: "latest"},
+								},
+								Splits: []knative_v1alpha1.IngressBackendSplit{split("hello-00002", 100)},
+							}, {
+								Splits: []knative_v1alpha1.IngressBackendSplit{split("hello-00001", 80), split("hello-00002", 20)},
+								AppendHeaders: map[string]string{
+									"Knative-Serving-Namespace": "default",
+								},
+							}},
+						},
+					}},
+				}),
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("hello.default.example.com",
+							knativeDefaults(&Route{
+								PathMatchCondition: prefixString("/"),
+								HeaderMatchConditions: []HeaderMatchCondition{{
+									Name:      "Knative-Serving-Tag",
+									Value:     "latest",
+									MatchType: HeaderMatchTypeExact,
+								}},
+								Clusters: []*Cluster{cluster(hello2, 100)},
+							}),
+							knativeDefaults(&Route{
+								PathMatchCondition: prefixString("/"),
+								Clusters:           []*Cluster{cluster(hello1, 80), cluster(hello2, 20)},
+								RequestHeadersPolicy: &HeadersPolicy{
+									Set: map[string]string{
+										"Knative-Serving-Namespace": "default",
+									},
+								},
+							}),
+						),
+					),
+				},
+			),
+		},
+		"tls with http redirect": {
+			objs: []interface{}{
+				hello1,
+				sec1,
+				kingress(knative_v1alpha1.ContourIngressClassName, knative_v1alpha1.IngressSpec{
+					TLS: []knative_v1alpha1.IngressTLS{{
+						Hosts:           []string{"hello.default.example.com"},
+						SecretName:      sec1.Name,
+						SecretNamespace: sec1.Namespace,
+					}},
+					HTTPOption: knative_v1alpha1.HTTPOptionRedirected,
+					Rules: []knative_v1alpha1.IngressRule{{
+						Hosts:      []string{"hello.default.example.com"},
+						Visibility: knative_v1alpha1.IngressVisibilityExternalIP,
+						HTTP: &knative_v1alpha1.HTTPIngressRuleValue{
+							Paths: []knative_v1alpha1.HTTPIngressPath{{
+								Splits: []knative_v1alpha1.IngressBackendSplit{split("hello-00001", 100)},
+							}},
+						},
+					}},
+				}),
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("hello.default.example.com", knativeDefaults(&Route{
+							PathMatchCondition: prefixString("/"),
+							Clusters:           []*Cluster{cluster(hello1, 100)},
+							HTTPSUpgrade:       true,
+						})),
+					),
+				},
+				&Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						securevirtualhost("hello.default.example.com", sec1, knativeDefaults(&Route{
+							PathMatchCondition: prefixString("/"),
+							Clusters:           []*Cluster{cluster(hello1, 100)},
+							HTTPSUpgrade:       true,
+						})),
+					),
+				},
+			),
+		},
+		"cluster-local rule with rewritten host is not redirected": {
+			objs: []interface{}{
+				hello1,
+				kingress(knative_v1alpha1.ContourIngressClassName, knative_v1alpha1.IngressSpec{
+					HTTPOption: knative_v1alpha1.HTTPOptionRedirected,
+					Rules: []knative_v1alpha1.IngressRule{{
+						Hosts:      []string{"hello.default.svc.cluster.local"},
+						Visibility: knative_v1alpha1.IngressVisibilityClusterLocal,
+						HTTP: &knative_v1alpha1.HTTPIngressRuleValue{
+							Paths: []knative_v1alpha1.HTTPIngressPath{{
+								RewriteHost: "hello.default.example.com",
+								Splits:      []knative_v1alpha1.IngressBackendSplit{split("hello-00001", 100)},
+							}},
+						},
+					}},
+				}),
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("hello.default.svc.cluster.local", knativeDefaults(&Route{
+							PathMatchCondition: prefixString("/"),
+							Clusters:           []*Cluster{cluster(hello1, 100)},
+							RequestHeadersPolicy: &HeadersPolicy{
+								HostRewrite: "hello.default.example.com",
+							},
+						})),
+					),
+				},
+			),
+		},
+		"ingress with a missing service is not programmed": {
+			objs: []interface{}{
+				hello1,
+				kingress(knative_v1alpha1.ContourIngressClassName, knative_v1alpha1.IngressSpec{
+					Rules: []knative_v1alpha1.IngressRule{{
+						Hosts: []string{"hello.default.example.com"},
+						HTTP: &knative_v1alpha1.HTTPIngressRuleValue{
+							Paths: []knative_v1alpha1.HTTPIngressPath{{
+								Splits: []knative_v1alpha1.IngressBackendSplit{split("hello-00001", 50), split("hello-00002", 50)},
+							}},
+						},
+					}},
+				}),
+			},
+			want: listeners(),
+		},
+		"ingress for another networking layer is ignored": {
+			objs: []interface{}{
+				hello1,
+				kingress("istio.ingress.networking.knative.dev", knative_v1alpha1.IngressSpec{
+					Rules: []knative_v1alpha1.IngressRule{{
+						Hosts: []string{"hello.default.example.com"},
+						HTTP: &knative_v1alpha1.HTTPIngressRuleValue{
+							Paths: []knative_v1alpha1.HTTPIngressPath{{
+								Splits: []knative_v1alpha1.IngressBackendSplit{split("hello-00001", 100)},
+							}},
+						},
+					}},
+				}),
+			},
+			want: listeners(),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&KnativeIngressProcessor{
+						FieldLogger: fixture.NewTestLogger(t),
 					},
 					&ListenerProcessor{},
 				},
@@ -10556,6 +12071,2049 @@ func TestBuilderRunsProcessorsInOrder(t *testing.T) {
 	assert.Equal(t, []string{"foo", "bar", "baz", "abc", "def"}, got)
 }
 
+func TestBuilderRegisterProcessor(t *testing.T) {
+	var got []string
+
+	b := Builder{
+		Processors: []Processor{
+			ProcessorFunc(func(*DAG, *KubernetesCache) { got = append(got, "foo") }),
+			&ListenerProcessor{},
+		},
+	}
+	b.RegisterProcessor(ProcessorFunc(func(*DAG, *KubernetesCache) { got = append(got, "bar") }))
+	b.RegisterProcessor(ProcessorFunc(func(*DAG, *KubernetesCache) { got = append(got, "baz") }))
+
+	// Registered processors run before the ListenerProcessor.
+	require.Len(t, b.Processors, 4)
+	assert.IsType(t, &ListenerProcessor{}, b.Processors[3])
+	b.Build()
+	assert.Equal(t, []string{"foo", "bar", "baz"}, got)
+
+	// Without a ListenerProcessor, they are appended.
+	b = Builder{}
+	b.RegisterProcessor(&ListenerProcessor{})
+	b.RegisterProcessor(ProcessorFunc(nil))
+	require.Len(t, b.Processors, 2)
+	assert.IsType(t, &ListenerProcessor{}, b.Processors[1])
+}
+
+func TestBuilderUnstructuredObjects(t *testing.T) {
+	kind := schema.GroupKind{Group: "example.com", Kind: "CanaryPolicy"}
+	canary := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "CanaryPolicy",
+			"metadata": map[string]interface{}{
+				"name":      "canary",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"weight": int64(10),
+			},
+		},
+	}
+
+	var found *unstructured.Unstructured
+	b := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			ProcessorFunc(func(_ *DAG, source *KubernetesCache) {
+				for _, obj := range source.ListUnstructured(kind) {
+					found = obj
+				}
+			}),
+		},
+	}
+	assert.True(t, b.Source.Insert(canary))
+
+	b.Build()
+	assert.Equal(t, canary, found)
+
+	got, ok := b.Source.LookupUnstructured(kind, types.NamespacedName{Namespace: "default", Name: "canary"})
+	assert.True(t, ok)
+	assert.Equal(t, canary, got)
+
+	assert.True(t, b.Source.Remove(canary))
+	_, ok = b.Source.LookupUnstructured(kind, types.NamespacedName{Namespace: "default", Name: "canary"})
+	assert.False(t, ok)
+	assert.Empty(t, b.Source.ListUnstructured(kind))
+}
+
+func TestHTTPProxyPartialValidity(t *testing.T) {
+	proxy := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/missing",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: "missing",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	routes := func(partialValidity bool) []string {
+		builder := Builder{
+			Source: KubernetesCache{
+				FieldLogger: fixture.NewTestLogger(t),
+			},
+			Processors: []Processor{
+				&HTTPProxyProcessor{
+					PartialValidity: partialValidity,
+				},
+				&ListenerProcessor{},
+			},
+		}
+		builder.Source.Insert(fixture.ServiceRootsKuard)
+		builder.Source.Insert(proxy)
+
+		var got []string
+		vh := builder.Build().GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+		if vh != nil {
+			for _, r := range vh.routes {
+				got = append(got, r.PathMatchCondition.(*PrefixMatchCondition).Prefix)
+			}
+		}
+		return got
+	}
+
+	// By default, the invalid route drops every route of the HTTPProxy.
+	assert.Empty(t, routes(false))
+
+	// With partial validity, only the invalid route is dropped.
+	assert.Equal(t, []string{"/"}, routes(true))
+}
+
+func TestHTTPProxyDefaultPolicies(t *testing.T) {
+	proxy := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/custom",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+				RetryPolicy: &contour_api_v1.RetryPolicy{
+					NumRetries: 7,
+				},
+				TimeoutPolicy: &contour_api_v1.TimeoutPolicy{
+					Response: "1m",
+				},
+			}},
+		},
+	}
+
+	builder := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&HTTPProxyProcessor{
+				DefaultRetryPolicy: &contour_api_v1.RetryPolicy{
+					NumRetries:    3,
+					PerTryTimeout: "250ms",
+					RetryOn:       []contour_api_v1.RetryOn{"gateway-error"},
+				},
+				DefaultTimeoutPolicy: &contour_api_v1.TimeoutPolicy{
+					Response: "30s",
+					Idle:     "5m",
+				},
+			},
+			&ListenerProcessor{},
+		},
+	}
+	builder.Source.Insert(fixture.ServiceRootsKuard)
+	builder.Source.Insert(proxy)
+
+	vh := builder.Build().GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+	require.NotNil(t, vh)
+
+	routes := map[string]*Route{}
+	for _, r := range vh.routes {
+		routes[r.PathMatchCondition.(*PrefixMatchCondition).Prefix] = r
+	}
+
+	// Routes without their own policies use the defaults.
+	r := routes["/"]
+	require.NotNil(t, r)
+	assert.Equal(t, &RetryPolicy{
+		RetryOn:       "gateway-error",
+		NumRetries:    3,
+		PerTryTimeout: timeout.DurationSetting(250 * time.Millisecond),
+	}, r.RetryPolicy)
+	assert.Equal(t, TimeoutPolicy{
+		ResponseTimeout: timeout.DurationSetting(30 * time.Second),
+		IdleTimeout:     timeout.DurationSetting(5 * time.Minute),
+	}, r.TimeoutPolicy)
+
+	// Routes with their own policies ignore the defaults.
+	r = routes["/custom"]
+	require.NotNil(t, r)
+	assert.Equal(t, &RetryPolicy{
+		RetryOn:       "5xx",
+		NumRetries:    7,
+		PerTryTimeout: timeout.DefaultSetting(),
+	}, r.RetryPolicy)
+	assert.Equal(t, TimeoutPolicy{
+		ResponseTimeout: timeout.DurationSetting(time.Minute),
+		IdleTimeout:     timeout.DefaultSetting(),
+	}, r.TimeoutPolicy)
+}
+
+func TestHTTPProxyContourPolicy(t *testing.T) {
+	h2c := "h2c"
+
+	route := func(prefix string) contour_api_v1.Route {
+		return contour_api_v1.Route{
+			Conditions: []contour_api_v1.MatchCondition{{
+				Prefix: prefix,
+			}},
+			Services: []contour_api_v1.Service{{
+				Name: fixture.ServiceRootsKuard.Name,
+				Port: 8080,
+			}},
+		}
+	}
+
+	tests := map[string]struct {
+		route      contour_api_v1.Route
+		policy     contour_api_v1alpha1.ContourPolicySpec
+		wantRetry  *RetryPolicy
+		wantValid  bool
+		wantReason string
+	}{
+		"route without retry policy uses the namespace default": {
+			route: route("/"),
+			policy: contour_api_v1alpha1.ContourPolicySpec{
+				RetryPolicy: &contour_api_v1.RetryPolicy{NumRetries: 2},
+			},
+			wantRetry: &RetryPolicy{
+				RetryOn:       "5xx",
+				NumRetries:    2,
+				PerTryTimeout: timeout.DefaultSetting(),
+			},
+			wantValid: true,
+		},
+		"route retry policy overrides the namespace default": {
+			route: func() contour_api_v1.Route {
+				r := route("/")
+				r.RetryPolicy = &contour_api_v1.RetryPolicy{NumRetries: 1}
+				return r
+			}(),
+			policy: contour_api_v1alpha1.ContourPolicySpec{
+				RetryPolicy: &contour_api_v1.RetryPolicy{NumRetries: 2},
+			},
+			wantRetry: &RetryPolicy{
+				RetryOn:       "5xx",
+				NumRetries:    1,
+				PerTryTimeout: timeout.DefaultSetting(),
+			},
+			wantValid: true,
+		},
+		"route exceeds the maximum number of retries": {
+			route: func() contour_api_v1.Route {
+				r := route("/")
+				r.RetryPolicy = &contour_api_v1.RetryPolicy{NumRetries: 5}
+				return r
+			}(),
+			policy: contour_api_v1alpha1.ContourPolicySpec{
+				MaxRetries: 3,
+			},
+			wantReason: "ContourPolicyLimitExceeded",
+		},
+		"route exceeds the maximum response timeout": {
+			route: func() contour_api_v1.Route {
+				r := route("/")
+				r.TimeoutPolicy = &contour_api_v1.TimeoutPolicy{Response: "infinity"}
+				return r
+			}(),
+			policy: contour_api_v1alpha1.ContourPolicySpec{
+				MaxResponseTimeout: "1m",
+			},
+			wantReason: "ContourPolicyLimitExceeded",
+		},
+		"route uses a protocol that is not allowed": {
+			route: func() contour_api_v1.Route {
+				r := route("/")
+				r.Services[0].Protocol = &h2c
+				return r
+			}(),
+			policy: contour_api_v1alpha1.ContourPolicySpec{
+				AllowedProtocols: []contour_api_v1alpha1.UpstreamProtocol{
+					contour_api_v1alpha1.UpstreamProtocolHTTP,
+				},
+			},
+			wantReason: "ProtocolNotPermitted",
+		},
+		"route uses an allowed protocol": {
+			route: route("/"),
+			policy: contour_api_v1alpha1.ContourPolicySpec{
+				AllowedProtocols: []contour_api_v1alpha1.UpstreamProtocol{
+					contour_api_v1alpha1.UpstreamProtocolHTTP,
+				},
+			},
+			wantValid: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+					},
+					Routes: []contour_api_v1.Route{tc.route},
+				},
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+			builder.Source.Insert(&contour_api_v1alpha1.ContourPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "policy",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: tc.policy,
+			})
+
+			dag := builder.Build()
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+
+			if !tc.wantValid {
+				assert.Nil(t, vh)
+				cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.NotNil(t, vh)
+			require.Len(t, vh.routes, 1)
+			for _, r := range vh.routes {
+				assert.Equal(t, tc.wantRetry, r.RetryPolicy)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyPermitInsecureNamespaces(t *testing.T) {
+	tests := map[string]struct {
+		namespaces      []string
+		selector        string
+		namespaceLabels map[string]string
+		disable         bool
+		wantReason      string
+	}{
+		"no restriction": {},
+		"namespace is listed": {
+			namespaces: []string{"legacy", fixture.ServiceRootsKuard.Namespace},
+		},
+		"namespace is not listed": {
+			namespaces: []string{"legacy"},
+			wantReason: "PermitInsecureNotPermitted",
+		},
+		"namespace labels match the selector": {
+			selector:        "permit-insecure=true",
+			namespaceLabels: map[string]string{"permit-insecure": "true"},
+		},
+		"namespace labels do not match the selector": {
+			selector:        "permit-insecure=true",
+			namespaceLabels: map[string]string{"permit-insecure": "false"},
+			wantReason:      "PermitInsecureNotPermitted",
+		},
+		"permitInsecure is disabled": {
+			namespaces: []string{"legacy"},
+			disable:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+					},
+					Routes: []contour_api_v1.Route{{
+						PermitInsecure: true,
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+
+			processor := &HTTPProxyProcessor{
+				DisablePermitInsecure:    tc.disable,
+				PermitInsecureNamespaces: tc.namespaces,
+			}
+			if tc.selector != "" {
+				selector, err := labels.Parse(tc.selector)
+				require.NoError(t, err)
+				processor.PermitInsecureSelector = selector
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					processor,
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+			builder.Source.Insert(&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   fixture.ServiceRootsKuard.Namespace,
+					Labels: tc.namespaceLabels,
+				},
+			})
+
+			dag := builder.Build()
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+
+			if tc.wantReason != "" {
+				assert.Nil(t, vh)
+				cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.NotNil(t, vh)
+		})
+	}
+}
+
+func TestHTTPProxyLua(t *testing.T) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "scripts",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Data: map[string]string{
+			"route.lua": "-- route",
+			"empty.lua": "",
+		},
+	}
+
+	tests := map[string]struct {
+		namespaces []string
+		vhost      *contour_api_v1.LuaPolicy
+		route      *contour_api_v1.LuaPolicy
+		wantVhost  string
+		wantRoute  string
+		wantReason string
+	}{
+		"no scripts": {},
+		"inline scripts": {
+			namespaces: []string{fixture.ServiceRootsKuard.Namespace},
+			vhost:      &contour_api_v1.LuaPolicy{InlineCode: "-- vhost"},
+			route:      &contour_api_v1.LuaPolicy{InlineCode: "-- route"},
+			wantVhost:  "-- vhost",
+			wantRoute:  "-- route",
+		},
+		"ConfigMap script": {
+			namespaces: []string{fixture.ServiceRootsKuard.Namespace},
+			route: &contour_api_v1.LuaPolicy{
+				ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{Name: "scripts", Key: "route.lua"},
+			},
+			wantRoute: "-- route",
+		},
+		"namespace is not permitted": {
+			namespaces: []string{"scripting"},
+			vhost:      &contour_api_v1.LuaPolicy{InlineCode: "-- vhost"},
+			wantReason: "LuaPolicyInvalid",
+		},
+		"inline and ConfigMap scripts": {
+			namespaces: []string{fixture.ServiceRootsKuard.Namespace},
+			route: &contour_api_v1.LuaPolicy{
+				InlineCode:   "-- route",
+				ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{Name: "scripts", Key: "route.lua"},
+			},
+			wantReason: "LuaPolicyInvalid",
+		},
+		"missing ConfigMap": {
+			namespaces: []string{fixture.ServiceRootsKuard.Namespace},
+			route: &contour_api_v1.LuaPolicy{
+				ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{Name: "missing", Key: "route.lua"},
+			},
+			wantReason: "LuaPolicyInvalid",
+		},
+		"empty ConfigMap key": {
+			namespaces: []string{fixture.ServiceRootsKuard.Namespace},
+			route: &contour_api_v1.LuaPolicy{
+				ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{Name: "scripts", Key: "empty.lua"},
+			},
+			wantReason: "LuaPolicyInvalid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+						Lua:  tc.vhost,
+					},
+					Routes: []contour_api_v1.Route{{
+						Lua: tc.route,
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{
+						LuaNamespaces: tc.namespaces,
+					},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+			builder.Source.Insert(configMap)
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+			require.NotNil(t, vh)
+			assert.Equal(t, tc.wantVhost, vh.LuaScript)
+			require.Len(t, vh.routes, 1)
+			for _, route := range vh.routes {
+				assert.Equal(t, tc.wantRoute, route.LuaScript)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyCachePolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy     *contour_api_v1.CachePolicy
+		want       *CachePolicy
+		wantReason string
+	}{
+		"no policy": {},
+		"policy": {
+			policy: &contour_api_v1.CachePolicy{
+				MaxObjectSize: 4096,
+				KeyHeaders:    []string{"Accept-Language"},
+			},
+			want: &CachePolicy{
+				Methods:       []string{"GET"},
+				MaxObjectSize: 4096,
+				KeyHeaders:    []string{"accept-language"},
+			},
+		},
+		"invalid method": {
+			policy: &contour_api_v1.CachePolicy{
+				Methods: []contour_api_v1.CacheMethod{"POST"},
+			},
+			wantReason: "CachePolicyInvalid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+					},
+					Routes: []contour_api_v1.Route{{
+						CachePolicy: tc.policy,
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+			require.NotNil(t, vh)
+			require.Len(t, vh.routes, 1)
+			for _, route := range vh.routes {
+				assert.Equal(t, tc.want, route.CachePolicy)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyBufferPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy     *contour_api_v1.BufferPolicy
+		want       []*Route
+		wantReason string
+	}{
+		"reject": {
+			policy: &contour_api_v1.BufferPolicy{
+				MaxRequestBytes: 1024,
+			},
+			want: []*Route{{
+				PathMatchCondition: prefixString("/"),
+				BufferPolicy:       &BufferPolicy{MaxRequestBytes: 1024},
+			}},
+		},
+		"passthrough": {
+			policy: &contour_api_v1.BufferPolicy{
+				MaxRequestBytes: 1024,
+				OnOverflow:      "Passthrough",
+			},
+			want: []*Route{{
+				PathMatchCondition: prefixString("/"),
+				HeaderMatchConditions: []HeaderMatchCondition{{
+					Name:      "Content-Length",
+					Value:     "1024",
+					MatchType: HeaderMatchTypeGreaterThan,
+				}},
+			}, {
+				PathMatchCondition: prefixString("/"),
+				BufferPolicy:       &BufferPolicy{MaxRequestBytes: 1024},
+			}},
+		},
+		"no limit": {
+			policy:     &contour_api_v1.BufferPolicy{},
+			wantReason: "BufferPolicyInvalid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+					},
+					Routes: []contour_api_v1.Route{{
+						BufferPolicy: tc.policy,
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+			require.NotNil(t, vh)
+			require.Len(t, vh.routes, len(tc.want))
+			for _, want := range tc.want {
+				route, ok := vh.routes[conditionsToString(want)]
+				require.True(t, ok)
+				assert.Equal(t, want.BufferPolicy, route.BufferPolicy)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyRBACPolicy(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := map[string]struct {
+		policy     *contour_api_v1.RBACPolicy
+		want       *RBACPolicy
+		wantReason string
+	}{
+		"source CIDR": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{
+						SourceCIDR: "10.0.0.0/8",
+					}},
+				}},
+			},
+			want: &RBACPolicy{
+				Rules: []RBACRule{{
+					Principals: []RBACPrincipal{{
+						SourceCIDR: cidr,
+					}},
+				}},
+			},
+		},
+		"subject alt name without client validation": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{
+						SubjectAltName: "client.example.com",
+					}},
+				}},
+			},
+			wantReason: "RBACPolicyInvalid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+					},
+					Routes: []contour_api_v1.Route{{
+						RBACPolicy: tc.policy,
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+			require.NotNil(t, vh)
+			route, ok := vh.routes[conditionsToString(&Route{PathMatchCondition: prefixString("/")})]
+			require.True(t, ok)
+			assert.Equal(t, tc.want, route.RBACPolicy)
+		})
+	}
+}
+
+// fakeWasmImageFetcher serves the code of the images it holds, and
+// reports that any other image is being pulled.
+type fakeWasmImageFetcher map[string][]byte
+
+func (f fakeWasmImageFetcher) Fetch(image string) ([]byte, error) {
+	code, ok := f[image]
+	if !ok {
+		return nil, errors.New("image is being pulled")
+	}
+	return code, nil
+}
+
+func TestHTTPProxyWasmModules(t *testing.T) {
+	code := []byte("\x00asm\x01\x00\x00\x00")
+	digest := "93a44bbb96c751218e4c00d479e4c14358122a389acca16205b1e4d0dc5f9476"
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "modules",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		BinaryData: map[string][]byte{
+			"headers.wasm": code,
+		},
+	}
+
+	module := func(name string, spec contour_api_v1alpha1.WasmModuleSpec) *contour_api_v1alpha1.WasmModule {
+		return &contour_api_v1alpha1.WasmModule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: fixture.ServiceRootsKuard.Namespace,
+			},
+			Spec: spec,
+		}
+	}
+
+	tests := map[string]struct {
+		modules     []*contour_api_v1alpha1.WasmModule
+		refs        []contour_api_v1.WasmModuleReference
+		insecure    bool
+		fallback    bool
+		want        []*WasmModule
+		wantReason  string
+		wantWarning string
+	}{
+		"ConfigMap module": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("headers", contour_api_v1alpha1.WasmModuleSpec{
+					ConfigMapRef:  &contour_api_v1.ConfigMapKeyReference{Name: "modules", Key: "headers.wasm"},
+					SHA256:        digest,
+					RootID:        "headers",
+					Configuration: `{"header":"x-wasm"}`,
+				}),
+			},
+			refs: []contour_api_v1.WasmModuleReference{{Name: "headers"}},
+			want: []*WasmModule{{
+				Name:          "roots/headers",
+				Code:          code,
+				RootID:        "headers",
+				Configuration: `{"header":"x-wasm"}`,
+			}},
+		},
+		"image modules in order": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("first", contour_api_v1alpha1.WasmModuleSpec{Image: "example.com/filter:v1"}),
+				module("second", contour_api_v1alpha1.WasmModuleSpec{
+					Image:         "example.com/filter:v1",
+					FailurePolicy: contour_api_v1alpha1.WasmFailurePolicyIgnore,
+				}),
+			},
+			refs: []contour_api_v1.WasmModuleReference{{Name: "second"}, {Namespace: "roots", Name: "first"}},
+			want: []*WasmModule{{
+				Name:     "roots/second",
+				Code:     code,
+				FailOpen: true,
+			}, {
+				Name: "roots/first",
+				Code: code,
+			}},
+		},
+		"image is being pulled": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("pending", contour_api_v1alpha1.WasmModuleSpec{Image: "example.com/filter:v2"}),
+			},
+			refs:        []contour_api_v1.WasmModuleReference{{Name: "pending"}},
+			want:        []*WasmModule{{Name: "roots/pending"}},
+			wantWarning: "WasmModuleNotReady",
+		},
+		"image is being pulled with failure policy Ignore": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("pending", contour_api_v1alpha1.WasmModuleSpec{
+					Image:         "example.com/filter:v2",
+					FailurePolicy: contour_api_v1alpha1.WasmFailurePolicyIgnore,
+				}),
+			},
+			refs:        []contour_api_v1.WasmModuleReference{{Name: "pending"}},
+			want:        nil,
+			wantWarning: "WasmModuleNotReady",
+		},
+		"digest does not match": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("tampered", contour_api_v1alpha1.WasmModuleSpec{
+					Image:  "example.com/filter:v1",
+					SHA256: "0000000000000000000000000000000000000000000000000000000000000000",
+				}),
+			},
+			refs:        []contour_api_v1.WasmModuleReference{{Name: "tampered"}},
+			want:        []*WasmModule{{Name: "roots/tampered"}},
+			wantWarning: "WasmModuleNotReady",
+		},
+		"missing ConfigMap key": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("headers", contour_api_v1alpha1.WasmModuleSpec{
+					ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{Name: "modules", Key: "missing.wasm"},
+				}),
+			},
+			refs:        []contour_api_v1.WasmModuleReference{{Name: "headers"}},
+			want:        []*WasmModule{{Name: "roots/headers"}},
+			wantWarning: "WasmModuleNotReady",
+		},
+		"missing module": {
+			refs:       []contour_api_v1.WasmModuleReference{{Name: "missing"}},
+			wantReason: "WasmModuleNotFound",
+		},
+		"image and ConfigMap": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("headers", contour_api_v1alpha1.WasmModuleSpec{
+					Image:        "example.com/filter:v1",
+					ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{Name: "modules", Key: "headers.wasm"},
+				}),
+			},
+			refs:       []contour_api_v1.WasmModuleReference{{Name: "headers"}},
+			wantReason: "WasmModuleInvalid",
+		},
+		"duplicate reference": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("first", contour_api_v1alpha1.WasmModuleSpec{Image: "example.com/filter:v1"}),
+			},
+			refs:       []contour_api_v1.WasmModuleReference{{Name: "first"}, {Namespace: "roots", Name: "first"}},
+			wantReason: "WasmModuleInvalid",
+		},
+		"insecure virtual host": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("first", contour_api_v1alpha1.WasmModuleSpec{Image: "example.com/filter:v1"}),
+			},
+			refs:       []contour_api_v1.WasmModuleReference{{Name: "first"}},
+			insecure:   true,
+			wantReason: "WasmModuleInvalid",
+		},
+		"fallback certificate": {
+			modules: []*contour_api_v1alpha1.WasmModule{
+				module("first", contour_api_v1alpha1.WasmModuleSpec{Image: "example.com/filter:v1"}),
+			},
+			refs:       []contour_api_v1.WasmModuleReference{{Name: "first"}},
+			fallback:   true,
+			wantReason: "TLSIncompatibleFeatures",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn:        "example.com",
+						WasmModules: tc.refs,
+					},
+					Routes: []contour_api_v1.Route{{
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+			if !tc.insecure {
+				proxy.Spec.VirtualHost.TLS = &contour_api_v1.TLS{
+					SecretName:                "secret",
+					EnableFallbackCertificate: tc.fallback,
+				}
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{
+						WasmImageFetcher: fakeWasmImageFetcher{
+							"example.com/filter:v1": code,
+						},
+					},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+			builder.Source.Insert(configMap)
+			builder.Source.Insert(&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "secret",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Type: v1.SecretTypeTLS,
+				Data: secretdata(fixture.CERTIFICATE, fixture.RSA_PRIVATE_KEY),
+			})
+			for _, m := range tc.modules {
+				builder.Source.Insert(m)
+			}
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			if tc.wantWarning != "" {
+				require.NotEmpty(t, cond.Warnings)
+				assert.Equal(t, tc.wantWarning, cond.Warnings[0].Reason)
+			} else {
+				assert.Empty(t, cond.Warnings)
+			}
+
+			svh := dag.GetSecureVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_https"})
+			require.NotNil(t, svh)
+			assert.Equal(t, tc.want, svh.WasmModules)
+		})
+	}
+}
+
+func TestHTTPProxyAdmissionControlPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy     *contour_api_v1.AdmissionControlPolicy
+		insecure   bool
+		fallback   bool
+		want       *AdmissionControlPolicy
+		wantReason string
+	}{
+		"policy": {
+			policy: &contour_api_v1.AdmissionControlPolicy{
+				SuccessRateThreshold: 90,
+				Aggression:           "2",
+			},
+			want: &AdmissionControlPolicy{
+				SuccessRateThreshold:   90,
+				Aggression:             2,
+				SamplingWindow:         30 * time.Second,
+				MaxRejectionPercentage: 80,
+			},
+		},
+		"invalid policy": {
+			policy: &contour_api_v1.AdmissionControlPolicy{
+				Aggression: "0",
+			},
+			wantReason: "AdmissionControlPolicyInvalid",
+		},
+		"insecure virtual host": {
+			policy:     &contour_api_v1.AdmissionControlPolicy{},
+			insecure:   true,
+			wantReason: "AdmissionControlPolicyInvalid",
+		},
+		"fallback certificate": {
+			policy:     &contour_api_v1.AdmissionControlPolicy{},
+			fallback:   true,
+			wantReason: "TLSIncompatibleFeatures",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn:                   "example.com",
+						AdmissionControlPolicy: tc.policy,
+					},
+					Routes: []contour_api_v1.Route{{
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+			if !tc.insecure {
+				proxy.Spec.VirtualHost.TLS = &contour_api_v1.TLS{
+					SecretName:                "secret",
+					EnableFallbackCertificate: tc.fallback,
+				}
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+			builder.Source.Insert(&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "secret",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Type: v1.SecretTypeTLS,
+				Data: secretdata(fixture.CERTIFICATE, fixture.RSA_PRIVATE_KEY),
+			})
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			svh := dag.GetSecureVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_https"})
+			require.NotNil(t, svh)
+			assert.Equal(t, tc.want, svh.AdmissionControlPolicy)
+		})
+	}
+}
+
+func TestHTTPProxyTapPolicy(t *testing.T) {
+	expiresAt := metav1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second))
+	expiredAt := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	tests := map[string]struct {
+		policy      *contour_api_v1.TapPolicy
+		namespaces  []string
+		directory   string
+		insecure    bool
+		want        *TapPolicy
+		wantReason  string
+		wantWarning string
+	}{
+		"file sink": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiresAt,
+			},
+			namespaces: []string{"roots"},
+			want: &TapPolicy{
+				ID:          "roots_example_0",
+				MaxRequests: 10,
+				PathPrefix:  "/tmp/roots_example_0",
+			},
+		},
+		"file sink in a directory, with header conditions": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt:   expiresAt,
+				MaxRequests: 100,
+				Headers: []contour_api_v1.HeaderMatchCondition{{
+					Name:  "x-debug",
+					Exact: "true",
+				}},
+				Sink: "File",
+			},
+			namespaces: []string{"roots"},
+			directory:  "/var/log/envoy",
+			want: &TapPolicy{
+				ID:          "roots_example_0",
+				MaxRequests: 100,
+				HeaderMatchConditions: []HeaderMatchCondition{{
+					Name:      "x-debug",
+					Value:     "true",
+					MatchType: HeaderMatchTypeExact,
+				}},
+				PathPrefix: "/var/log/envoy/roots_example_0",
+			},
+		},
+		"expired": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiredAt,
+			},
+			namespaces:  []string{"roots"},
+			wantWarning: "TapPolicyExpired",
+		},
+		"namespace not permitted": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiresAt,
+			},
+			namespaces: []string{"debug"},
+			wantReason: "TapPolicyInvalid",
+		},
+		"insecure virtual host": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiresAt,
+			},
+			namespaces: []string{"roots"},
+			insecure:   true,
+			wantReason: "TapPolicyInvalid",
+		},
+		"contradictory header conditions": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiresAt,
+				Headers: []contour_api_v1.HeaderMatchCondition{{
+					Name:    "x-debug",
+					Present: true,
+				}, {
+					Name:       "x-debug",
+					NotPresent: true,
+				}},
+			},
+			namespaces: []string{"roots"},
+			wantReason: "TapPolicyInvalid",
+		},
+		"gRPC sink without extensionRef": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiresAt,
+				Sink:      "GRPC",
+			},
+			namespaces: []string{"roots"},
+			wantReason: "TapPolicyInvalid",
+		},
+		"gRPC sink with a missing extension service": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiresAt,
+				Sink:      "GRPC",
+				ExtensionServiceRef: &contour_api_v1.ExtensionServiceReference{
+					Name: "tap",
+				},
+			},
+			namespaces: []string{"roots"},
+			wantReason: "TapPolicyInvalid",
+		},
+		"file sink with extensionRef": {
+			policy: &contour_api_v1.TapPolicy{
+				ExpiresAt: expiresAt,
+				ExtensionServiceRef: &contour_api_v1.ExtensionServiceReference{
+					Name: "tap",
+				},
+			},
+			namespaces: []string{"roots"},
+			wantReason: "TapPolicyInvalid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+					},
+					Routes: []contour_api_v1.Route{{
+						TapPolicy: tc.policy,
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+			if !tc.insecure {
+				proxy.Spec.VirtualHost.TLS = &contour_api_v1.TLS{
+					SecretName: "secret",
+				}
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{
+						TapNamespaces:    tc.namespaces,
+						TapFileDirectory: tc.directory,
+					},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+			builder.Source.Insert(&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "secret",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Type: v1.SecretTypeTLS,
+				Data: secretdata(fixture.CERTIFICATE, fixture.RSA_PRIVATE_KEY),
+			})
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			if tc.wantWarning != "" {
+				require.NotEmpty(t, cond.Warnings)
+				assert.Equal(t, tc.wantWarning, cond.Warnings[0].Reason)
+				assert.True(t, dag.Expiry.IsZero())
+			} else {
+				assert.Equal(t, expiresAt.Time, dag.Expiry)
+			}
+
+			svh := dag.GetSecureVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_https"})
+			require.NotNil(t, svh)
+			require.Len(t, svh.routes, 1)
+			for _, route := range svh.routes {
+				assert.Equal(t, tc.want, route.TapPolicy)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyTapPolicyWorkers(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	objs := []interface{}{
+		fixture.ServiceRootsKuard,
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: fixture.ServiceRootsKuard.Namespace,
+			},
+			Type: v1.SecretTypeTLS,
+			Data: secretdata(fixture.CERTIFICATE, fixture.RSA_PRIVATE_KEY),
+		},
+		&contour_api_v1alpha1.ExtensionService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "tap",
+				Namespace: fixture.ServiceRootsKuard.Namespace,
+			},
+			Spec: contour_api_v1alpha1.ExtensionServiceSpec{
+				Services: []contour_api_v1alpha1.ExtensionServiceTarget{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			},
+		},
+	}
+
+	// Every root taps its route, alternating between the gRPC
+	// sink, which looks up the extension service in the DAG, and
+	// the file sink. Each tap expires an hour after the last.
+	for i := 0; i < 50; i++ {
+		tap := &contour_api_v1.TapPolicy{
+			ExpiresAt: metav1.NewTime(now.Add(time.Duration(50-i) * time.Hour)),
+		}
+		if i%2 == 0 {
+			tap.Sink = "GRPC"
+			tap.ExtensionServiceRef = &contour_api_v1.ExtensionServiceReference{
+				Name: "tap",
+			}
+		}
+
+		objs = append(objs, &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("root%d", i),
+				Namespace: fixture.ServiceRootsKuard.Namespace,
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: fmt.Sprintf("root%d.example.com", i),
+					TLS: &contour_api_v1.TLS{
+						SecretName: "secret",
+					},
+				},
+				Routes: []contour_api_v1.Route{{
+					TapPolicy: tap,
+					Services: []contour_api_v1.Service{{
+						Name: fixture.ServiceRootsKuard.Name,
+						Port: 8080,
+					}},
+				}},
+			},
+		})
+	}
+
+	builder := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&ExtensionServiceProcessor{
+				FieldLogger: fixture.NewTestLogger(t),
+			},
+			&HTTPProxyProcessor{
+				TapNamespaces: []string{fixture.ServiceRootsKuard.Namespace},
+				Workers:       8,
+			},
+			&ListenerProcessor{},
+		},
+	}
+	for _, o := range objs {
+		builder.Source.Insert(o)
+	}
+
+	dag := builder.Build()
+	for _, pu := range dag.StatusCache.GetProxyUpdates() {
+		require.Empty(t, pu.ConditionFor(status.ValidCondition).Errors, pu.Fullname)
+	}
+
+	// The earliest expiry wins, whichever worker records it.
+	assert.Equal(t, now.Add(time.Hour), dag.Expiry)
+
+	for i := 0; i < 50; i++ {
+		svh := dag.GetSecureVirtualHost(ListenerName{Name: fmt.Sprintf("root%d.example.com", i), ListenerName: "ingress_https"})
+		require.NotNil(t, svh)
+		for _, route := range svh.routes {
+			require.NotNil(t, route.TapPolicy)
+			assert.Equal(t, i%2 == 0, route.TapPolicy.Service != nil)
+		}
+	}
+}
+
+func TestHTTPProxyGeoMatchConditions(t *testing.T) {
+	tests := map[string]struct {
+		countries, asns bool
+		conditions      []contour_api_v1.MatchCondition
+		want            []HeaderMatchCondition
+		wantReason      string
+	}{
+		"countries": {
+			countries: true,
+			conditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"DE", "FR"},
+				},
+			}},
+			want: []HeaderMatchCondition{{
+				Name:      "x-contour-geo-country",
+				Value:     "DE|FR",
+				MatchType: HeaderMatchTypeRegex,
+			}},
+		},
+		"not ASNs": {
+			asns: true,
+			conditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					NotASNs: []uint32{64496},
+				},
+			}},
+			want: []HeaderMatchCondition{{
+				Name:      "x-contour-geo-asn",
+				Value:     "64496",
+				MatchType: HeaderMatchTypeRegex,
+				Invert:    true,
+			}},
+		},
+		"countries not looked up": {
+			asns: true,
+			conditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"DE"},
+				},
+			}},
+			wantReason: "GeoMatchConditionsNotValid",
+		},
+		"invalid country code": {
+			countries: true,
+			conditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"germany"},
+				},
+			}},
+			wantReason: "GeoMatchConditionsNotValid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn: "example.com",
+					},
+					Routes: []contour_api_v1.Route{{
+						Conditions: tc.conditions,
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{
+						EnableGeoIPCountry: tc.countries,
+						EnableGeoIPASN:     tc.asns,
+					},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+			require.NotNil(t, vh)
+			require.Len(t, vh.routes, 1)
+			for _, route := range vh.routes {
+				assert.Equal(t, tc.want, route.HeaderMatchConditions)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyUserAgentPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy     *contour_api_v1.UserAgentPolicy
+		wantRoutes int
+		wantReason string
+	}{
+		"deny": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"(?i)bot"},
+			},
+			wantRoutes: 2,
+		},
+		"invalid pattern": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"bot("},
+			},
+			wantReason: "UserAgentPolicyInvalid",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			proxy := &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: fixture.ServiceRootsKuard.Namespace,
+				},
+				Spec: contour_api_v1.HTTPProxySpec{
+					VirtualHost: &contour_api_v1.VirtualHost{
+						Fqdn:            "example.com",
+						UserAgentPolicy: tc.policy,
+					},
+					Routes: []contour_api_v1.Route{{
+						Services: []contour_api_v1.Service{{
+							Name: fixture.ServiceRootsKuard.Name,
+							Port: 8080,
+						}},
+					}},
+				},
+			}
+
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy)
+
+			dag := builder.Build()
+			cond := dag.StatusCache.GetProxyUpdates()[0].ConditionFor(status.ValidCondition)
+
+			if tc.wantReason != "" {
+				require.NotEmpty(t, cond.Errors)
+				assert.Equal(t, tc.wantReason, cond.Errors[0].Reason)
+				return
+			}
+
+			require.Empty(t, cond.Errors)
+			vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+			require.NotNil(t, vh)
+			assert.Len(t, vh.routes, tc.wantRoutes)
+		})
+	}
+}
+
+func TestHTTPProxyIncludeNotPrefix(t *testing.T) {
+	services := []contour_api_v1.Service{{
+		Name: fixture.ServiceRootsKuard.Name,
+		Port: 8080,
+	}}
+
+	root := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []contour_api_v1.Include{{
+				Name: "app",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}, {
+					NotPrefix: "/admin",
+				}},
+			}},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/admin",
+				}},
+				Services: services,
+			}},
+		},
+	}
+	app := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Services: services,
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/static",
+				}, {
+					NotPrefix: "/private",
+				}},
+				Services: services,
+			}},
+		},
+	}
+
+	builder := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&HTTPProxyProcessor{},
+			&ListenerProcessor{},
+		},
+	}
+	builder.Source.Insert(fixture.ServiceRootsKuard)
+	builder.Source.Insert(root)
+	builder.Source.Insert(app)
+
+	dag := builder.Build()
+	for _, pu := range dag.StatusCache.GetProxyUpdates() {
+		require.Empty(t, pu.ConditionFor(status.ValidCondition).Errors)
+	}
+
+	notPath := func(prefix string) HeaderMatchCondition {
+		return HeaderMatchCondition{
+			Name:      ":path",
+			Value:     prefix + ".*",
+			MatchType: HeaderMatchTypeRegex,
+			Invert:    true,
+		}
+	}
+
+	vh := dag.GetVirtualHost(ListenerName{Name: "example.com", ListenerName: "ingress_http"})
+	require.NotNil(t, vh)
+
+	got := map[string][]HeaderMatchCondition{}
+	for _, route := range vh.routes {
+		got[route.PathMatchCondition.(*PrefixMatchCondition).Prefix] = route.HeaderMatchConditions
+	}
+	assert.Equal(t, map[string][]HeaderMatchCondition{
+		"/admin":  nil,
+		"/":       {notPath("/admin")},
+		"/static": {notPath("/admin"), notPath("/static/private")},
+	}, got)
+}
+
+func TestHTTPProxyDefaultHTTPProxy(t *testing.T) {
+	proxy := func(name string) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: fixture.ServiceRootsKuard.Namespace,
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn:      name + ".example.com",
+					StatsName: name,
+				},
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name: fixture.ServiceRootsKuard.Name,
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		defaultProxy *types.NamespacedName
+		want         string
+	}{
+		"not set": {},
+		"designated proxy": {
+			defaultProxy: &types.NamespacedName{Namespace: fixture.ServiceRootsKuard.Namespace, Name: "unknown"},
+			want:         "unknown",
+		},
+		"missing proxy": {
+			defaultProxy: &types.NamespacedName{Namespace: fixture.ServiceRootsKuard.Namespace, Name: "missing"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{
+						DefaultHTTPProxy: tc.defaultProxy,
+					},
+					&ListenerProcessor{},
+				},
+			}
+			builder.Source.Insert(fixture.ServiceRootsKuard)
+			builder.Source.Insert(proxy("app"))
+			builder.Source.Insert(proxy("unknown"))
+
+			dag := builder.Build()
+			vh := dag.GetVirtualHost(ListenerName{Name: "*", ListenerName: "ingress_http"})
+			if tc.want == "" {
+				assert.Nil(t, vh)
+				return
+			}
+
+			require.NotNil(t, vh)
+			assert.Equal(t, tc.want, vh.StatsName)
+			assert.Len(t, vh.routes, 1)
+			assert.Nil(t, dag.GetSecureVirtualHost(ListenerName{Name: "*", ListenerName: "ingress_https"}))
+		})
+	}
+}
+
+func TestListenerProcessorInsecureListener(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	proxy := func(name string, tls bool) *contour_api_v1.HTTPProxy {
+		p := &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: name + ".example.com",
+				},
+				Routes: []contour_api_v1.Route{{
+					Conditions: []contour_api_v1.MatchCondition{{
+						Prefix: "/api",
+					}},
+					Services: []contour_api_v1.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+		if tls {
+			p.Spec.VirtualHost.TLS = &contour_api_v1.TLS{SecretName: sec1.Name}
+		}
+		return p
+	}
+
+	tests := map[string]struct {
+		mode      config.InsecureListenerMode
+		wantHosts []string
+	}{
+		"enabled": {
+			mode:      config.InsecureListenerEnabled,
+			wantHosts: []string{"plain.example.com", "secure.example.com"},
+		},
+		"redirect": {
+			mode:      config.InsecureListenerRedirect,
+			wantHosts: []string{"secure.example.com"},
+		},
+		"disabled": {
+			mode: config.InsecureListenerDisabled,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&HTTPProxyProcessor{},
+					&ListenerProcessor{InsecureListener: tc.mode},
+				},
+			}
+			builder.Source.Insert(service)
+			builder.Source.Insert(sec1)
+			builder.Source.Insert(proxy("plain", false))
+			builder.Source.Insert(proxy("secure", true))
+
+			dag := builder.Build()
+
+			var hosts []string
+			for ln, vh := range dag.GetVirtualHosts() {
+				hosts = append(hosts, ln.Name)
+
+				if tc.mode == config.InsecureListenerRedirect {
+					require.Len(t, vh.routes, 1)
+					for _, r := range vh.routes {
+						assert.Equal(t, &PrefixMatchCondition{Prefix: "/"}, r.PathMatchCondition)
+						assert.True(t, r.HTTPSUpgrade)
+					}
+				}
+			}
+			sort.Strings(hosts)
+			assert.Equal(t, tc.wantHosts, hosts)
+
+			// The secure virtual host is served in every mode.
+			assert.NotNil(t, dag.GetSecureVirtualHost(ListenerName{Name: "secure.example.com", ListenerName: "ingress_https"}))
+		})
+	}
+}
+
+func TestHTTPProxyProcessorWorkers(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	// child is included by every valid root.
+	child := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "child",
+			Namespace: "default",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	orphan := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "orphan",
+			Namespace: "default",
+		},
+		Spec: child.Spec,
+	}
+
+	objs := []interface{}{service, child, orphan}
+	for i := 0; i < 100; i++ {
+		proxy := &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("root%d", i),
+				Namespace: "default",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn:            fmt.Sprintf("root%d.example.com", i),
+					AdditionalFqdns: []string{fmt.Sprintf("alias%d.example.com", i)},
+				},
+				Includes: []contour_api_v1.Include{{
+					Name: "child",
+					Conditions: []contour_api_v1.MatchCondition{{
+						Prefix: "/child",
+					}},
+				}},
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+		// Every tenth root refers to a missing service.
+		if i%10 == 0 {
+			proxy.Spec.Routes[0].Services[0].Name = "missing"
+		}
+		objs = append(objs, proxy)
+	}
+
+	// build returns the routes of each virtual host, and the
+	// validity of each HTTPProxy, built with the given workers.
+	build := func(workers int) (map[string][]string, map[string]contour_api_v1.ConditionStatus) {
+		builder := Builder{
+			Source: KubernetesCache{
+				FieldLogger: fixture.NewTestLogger(t),
+			},
+			Processors: []Processor{
+				&HTTPProxyProcessor{
+					Workers: workers,
+				},
+				&ListenerProcessor{},
+			},
+		}
+		for _, o := range objs {
+			builder.Source.Insert(o)
+		}
+		dag := builder.Build()
+
+		vhosts := map[string][]string{}
+		for name, vh := range dag.GetVirtualHosts() {
+			for cond := range vh.routes {
+				vhosts[name.Name] = append(vhosts[name.Name], cond)
+			}
+			sort.Strings(vhosts[name.Name])
+		}
+
+		valid := map[string]contour_api_v1.ConditionStatus{}
+		for _, u := range dag.StatusCache.GetProxyUpdates() {
+			valid[u.Fullname.String()] = u.Conditions[status.ValidCondition].Status
+		}
+
+		return vhosts, valid
+	}
+
+	wantVhosts, wantValid := build(0)
+	// The roots that refer to the missing service are not
+	// valid, so only the other 90 build a virtual host for
+	// their fqdn and one for their alias.
+	assert.Len(t, wantVhosts, 180)
+	assert.Equal(t, contour_api_v1.ConditionFalse, wantValid["default/orphan"])
+	assert.Equal(t, contour_api_v1.ConditionFalse, wantValid["default/root0"])
+	assert.Equal(t, contour_api_v1.ConditionTrue, wantValid["default/root1"])
+
+	gotVhosts, gotValid := build(8)
+	assert.Equal(t, wantVhosts, gotVhosts)
+	assert.Equal(t, wantValid, gotValid)
+}
+
 func routes(routes ...*Route) map[string]*Route {
 	if len(routes) == 0 {
 		return nil
@@ -23,10 +23,15 @@ import (
 	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/ingressclass"
 	"github.com/projectcontour/contour/internal/k8s"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
+	"github.com/projectcontour/contour/internal/shard"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/cache"
@@ -42,9 +47,9 @@ type KubernetesCache struct {
 	// namespace.
 	RootNamespaces []string
 
-	// Contour's IngressClassName.
+	// Contour's IngressClassNames.
 	// If not set, defaults to DEFAULT_INGRESS_CLASS.
-	IngressClassName string
+	IngressClassNames []string
 
 	// ConfiguredGateway defines the current Gateway which Contour is configured to watch.
 	ConfiguredGateway types.NamespacedName
@@ -52,10 +57,15 @@ type KubernetesCache struct {
 	// Secrets that are referred from the configuration file.
 	ConfiguredSecretRefs []*types.NamespacedName
 
+	// Shard selects the Ingresses and root HTTPProxies whose
+	// virtual hosts Contour serves. If nil, all are served.
+	Shard *shard.Selector
+
 	ingresses                 map[types.NamespacedName]*networking_v1.Ingress
-	ingressclass              *networking_v1.IngressClass
+	ingressclasses            map[string]*networking_v1.IngressClass
 	httpproxies               map[types.NamespacedName]*contour_api_v1.HTTPProxy
 	secrets                   map[types.NamespacedName]*v1.Secret
+	configmaps                map[types.NamespacedName]*v1.ConfigMap
 	tlscertificatedelegations map[types.NamespacedName]*contour_api_v1.TLSCertificateDelegation
 	services                  map[types.NamespacedName]*v1.Service
 	namespaces                map[string]*v1.Namespace
@@ -67,6 +77,11 @@ type KubernetesCache struct {
 	udproutes                 map[types.NamespacedName]*gatewayapi_v1alpha1.UDPRoute
 	backendpolicies           map[types.NamespacedName]*gatewayapi_v1alpha1.BackendPolicy
 	extensions                map[types.NamespacedName]*contour_api_v1alpha1.ExtensionService
+	contourpolicies           map[types.NamespacedName]*contour_api_v1alpha1.ContourPolicy
+	wasmmodules               map[types.NamespacedName]*contour_api_v1alpha1.WasmModule
+	kingresses                map[types.NamespacedName]*knative_v1alpha1.Ingress
+	serviceimports            map[types.NamespacedName]*mcs_v1alpha1.ServiceImport
+	unstructured              map[schema.GroupKind]map[types.NamespacedName]*unstructured.Unstructured
 
 	initialize sync.Once
 
@@ -76,8 +91,10 @@ type KubernetesCache struct {
 // init creates the internal cache storage. It is called implicitly from the public API.
 func (kc *KubernetesCache) init() {
 	kc.ingresses = make(map[types.NamespacedName]*networking_v1.Ingress)
+	kc.ingressclasses = make(map[string]*networking_v1.IngressClass)
 	kc.httpproxies = make(map[types.NamespacedName]*contour_api_v1.HTTPProxy)
 	kc.secrets = make(map[types.NamespacedName]*v1.Secret)
+	kc.configmaps = make(map[types.NamespacedName]*v1.ConfigMap)
 	kc.tlscertificatedelegations = make(map[types.NamespacedName]*contour_api_v1.TLSCertificateDelegation)
 	kc.services = make(map[types.NamespacedName]*v1.Service)
 	kc.namespaces = make(map[string]*v1.Namespace)
@@ -87,21 +104,29 @@ func (kc *KubernetesCache) init() {
 	kc.tlsroutes = make(map[types.NamespacedName]*gatewayapi_v1alpha1.TLSRoute)
 	kc.backendpolicies = make(map[types.NamespacedName]*gatewayapi_v1alpha1.BackendPolicy)
 	kc.extensions = make(map[types.NamespacedName]*contour_api_v1alpha1.ExtensionService)
+	kc.contourpolicies = make(map[types.NamespacedName]*contour_api_v1alpha1.ContourPolicy)
+	kc.wasmmodules = make(map[types.NamespacedName]*contour_api_v1alpha1.WasmModule)
+	kc.kingresses = make(map[types.NamespacedName]*knative_v1alpha1.Ingress)
+	kc.serviceimports = make(map[types.NamespacedName]*mcs_v1alpha1.ServiceImport)
+	kc.unstructured = make(map[schema.GroupKind]map[types.NamespacedName]*unstructured.Unstructured)
 }
 
-// matchesIngressClass returns true if the given IngressClass
-// is the one this cache is using.
-func (kc *KubernetesCache) matchesIngressClass(obj *networking_v1.IngressClass) bool {
-	// If no ingress class name set, we allow an ingress class that is named
-	// with the default Contour accepted name.
-	if kc.IngressClassName == "" {
-		return obj.Name == ingressclass.DefaultClassName
-	}
-	// Otherwise, the name of the ingress class must match what has been
-	// configured.
-	if obj.Name == kc.IngressClassName {
+// admitsIngress returns true if the given Ingress belongs to
+// Contour, either by naming one of Contour's ingress classes or by
+// naming no ingress class while one of Contour's IngressClasses is
+// the default.
+func (kc *KubernetesCache) admitsIngress(obj *networking_v1.Ingress) bool {
+	if ingressclass.MatchesIngress(obj, kc.IngressClassNames) {
 		return true
 	}
+	if ingressclass.HasIngressClass(obj) {
+		return false
+	}
+	for _, class := range kc.ingressclasses {
+		if ingressclass.IsDefault(class) {
+			return true
+		}
+	}
 	return false
 }
 
@@ -167,6 +192,9 @@ func (kc *KubernetesCache) Insert(obj interface{}) bool {
 
 		kc.secrets[k8s.NamespacedNameOf(obj)] = obj
 		return kc.secretTriggersRebuild(obj)
+	case *v1.ConfigMap:
+		kc.configmaps[k8s.NamespacedNameOf(obj)] = obj
+		return kc.configMapTriggersRebuild(obj)
 	case *v1.Service:
 		kc.services[k8s.NamespacedNameOf(obj)] = obj
 		return kc.serviceTriggersRebuild(obj)
@@ -174,34 +202,44 @@ func (kc *KubernetesCache) Insert(obj interface{}) bool {
 		kc.namespaces[obj.Name] = obj
 		return true
 	case *networking_v1.Ingress:
-		if !ingressclass.MatchesIngress(obj, kc.IngressClassName) {
+		// Ingresses without an ingress class are kept, since they belong
+		// to Contour if its IngressClass is the cluster default.
+		if !ingressclass.MatchesIngress(obj, kc.IngressClassNames) && ingressclass.HasIngressClass(obj) {
 			// We didn't get a match so report this object is being ignored.
 			kc.WithField("name", obj.GetName()).
 				WithField("namespace", obj.GetNamespace()).
 				WithField("kind", k8s.KindOf(obj)).
 				WithField("ingress-class-annotation", annotation.IngressClass(obj)).
 				WithField("ingress-class-name", pointer.StringPtrDerefOr(obj.Spec.IngressClassName, "")).
-				WithField("target-ingress-class", kc.IngressClassName).
+				WithField("target-ingress-classes", kc.IngressClassNames).
 				Debug("ignoring Ingress with unmatched ingress class")
 			return false
 		}
 
+		if !kc.Shard.Matches(obj) {
+			kc.WithField("name", obj.GetName()).
+				WithField("namespace", obj.GetNamespace()).
+				WithField("kind", k8s.KindOf(obj)).
+				Debug("ignoring Ingress outside of shard")
+			return false
+		}
+
 		kc.ingresses[k8s.NamespacedNameOf(obj)] = obj
 		return true
 	case *networking_v1.IngressClass:
-		if kc.matchesIngressClass(obj) {
-			kc.ingressclass = obj
+		if ingressclass.MatchesIngressClass(obj, kc.IngressClassNames) {
+			kc.ingressclasses[obj.Name] = obj
 			return true
 		}
 	case *contour_api_v1.HTTPProxy:
-		if !ingressclass.MatchesHTTPProxy(obj, kc.IngressClassName) {
+		if !ingressclass.MatchesHTTPProxy(obj, kc.IngressClassNames) {
 			// We didn't get a match so report this object is being ignored.
 			kc.WithField("name", obj.GetName()).
 				WithField("namespace", obj.GetNamespace()).
 				WithField("kind", k8s.KindOf(obj)).
 				WithField("ingress-class-annotation", annotation.IngressClass(obj)).
 				WithField("ingress-class-name", obj.Spec.IngressClassName).
-				WithField("target-ingress-class", kc.IngressClassName).
+				WithField("target-ingress-classes", kc.IngressClassNames).
 				Debug("ignoring HTTPProxy with unmatched ingress class")
 			return false
 		}
@@ -241,6 +279,44 @@ func (kc *KubernetesCache) Insert(obj interface{}) bool {
 	case *contour_api_v1alpha1.ExtensionService:
 		kc.extensions[k8s.NamespacedNameOf(obj)] = obj
 		return true
+	case *contour_api_v1alpha1.ContourPolicy:
+		kc.contourpolicies[k8s.NamespacedNameOf(obj)] = obj
+		return true
+	case *contour_api_v1alpha1.WasmModule:
+		kc.wasmmodules[k8s.NamespacedNameOf(obj)] = obj
+		return true
+	case *knative_v1alpha1.Ingress:
+		if obj.GetAnnotations()[knative_v1alpha1.ClassAnnotationKey] != knative_v1alpha1.ContourIngressClassName {
+			kc.WithField("name", obj.GetName()).
+				WithField("namespace", obj.GetNamespace()).
+				WithField("kind", k8s.KindOf(obj)).
+				WithField("ingress-class-annotation", obj.GetAnnotations()[knative_v1alpha1.ClassAnnotationKey]).
+				Debug("ignoring Knative Ingress with unmatched ingress class")
+			return false
+		}
+
+		if !kc.Shard.Matches(obj) {
+			kc.WithField("name", obj.GetName()).
+				WithField("namespace", obj.GetNamespace()).
+				WithField("kind", k8s.KindOf(obj)).
+				Debug("ignoring Knative Ingress outside of shard")
+			return false
+		}
+
+		kc.kingresses[k8s.NamespacedNameOf(obj)] = obj
+		return true
+	case *mcs_v1alpha1.ServiceImport:
+		kc.serviceimports[k8s.NamespacedNameOf(obj)] = obj
+		return true
+	case *unstructured.Unstructured:
+		// Objects of the resources read by registered
+		// processors are not converted to a typed struct.
+		kind := obj.GroupVersionKind().GroupKind()
+		if kc.unstructured[kind] == nil {
+			kc.unstructured[kind] = make(map[types.NamespacedName]*unstructured.Unstructured)
+		}
+		kc.unstructured[kind][k8s.NamespacedNameOf(obj)] = obj
+		return true
 
 	default:
 		// not an interesting object
@@ -271,6 +347,11 @@ func (kc *KubernetesCache) remove(obj interface{}) bool {
 		_, ok := kc.secrets[m]
 		delete(kc.secrets, m)
 		return ok
+	case *v1.ConfigMap:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.configmaps[m]
+		delete(kc.configmaps, m)
+		return ok && kc.configMapTriggersRebuild(obj)
 	case *v1.Service:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.services[m]
@@ -286,11 +367,9 @@ func (kc *KubernetesCache) remove(obj interface{}) bool {
 		delete(kc.ingresses, m)
 		return ok
 	case *networking_v1.IngressClass:
-		if kc.matchesIngressClass(obj) {
-			kc.ingressclass = nil
-			return true
-		}
-		return false
+		_, ok := kc.ingressclasses[obj.Name]
+		delete(kc.ingressclasses, obj.Name)
+		return ok
 	case *contour_api_v1.HTTPProxy:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.httpproxies[m]
@@ -343,6 +422,32 @@ func (kc *KubernetesCache) remove(obj interface{}) bool {
 		_, ok := kc.extensions[m]
 		delete(kc.extensions, m)
 		return ok
+	case *contour_api_v1alpha1.ContourPolicy:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.contourpolicies[m]
+		delete(kc.contourpolicies, m)
+		return ok
+	case *contour_api_v1alpha1.WasmModule:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.wasmmodules[m]
+		delete(kc.wasmmodules, m)
+		return ok
+	case *knative_v1alpha1.Ingress:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.kingresses[m]
+		delete(kc.kingresses, m)
+		return ok
+	case *mcs_v1alpha1.ServiceImport:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.serviceimports[m]
+		delete(kc.serviceimports, m)
+		return ok
+	case *unstructured.Unstructured:
+		kind := obj.GroupVersionKind().GroupKind()
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.unstructured[kind][m]
+		delete(kc.unstructured[kind], m)
+		return ok
 
 	default:
 		// not interesting
@@ -412,6 +517,55 @@ func (kc *KubernetesCache) serviceTriggersRebuild(service *v1.Service) bool {
 		}
 	}
 
+	for _, ingress := range kc.kingresses {
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				for _, split := range path.Splits {
+					if split.ServiceNamespace == service.Namespace && split.ServiceName == service.Name {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// configMapTriggersRebuild returns true if the Lua script of a virtual
+// host or route of an HTTPProxy, or a WasmModule, in the same namespace
+// refers to configMap.
+func (kc *KubernetesCache) configMapTriggersRebuild(configMap *v1.ConfigMap) bool {
+	refers := func(lua *contour_api_v1.LuaPolicy) bool {
+		return lua != nil && lua.ConfigMapRef != nil && lua.ConfigMapRef.Name == configMap.Name
+	}
+
+	for _, proxy := range kc.httpproxies {
+		if proxy.Namespace != configMap.Namespace {
+			continue
+		}
+		if vhost := proxy.Spec.VirtualHost; vhost != nil && refers(vhost.Lua) {
+			return true
+		}
+		for _, route := range proxy.Spec.Routes {
+			if refers(route.Lua) {
+				return true
+			}
+		}
+	}
+
+	for _, module := range kc.wasmmodules {
+		if module.Namespace != configMap.Namespace {
+			continue
+		}
+		if ref := module.Spec.ConfigMapRef; ref != nil && ref.Name == configMap.Name {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -491,6 +645,14 @@ func (kc *KubernetesCache) secretTriggersRebuild(secret *v1.Secret) bool {
 		}
 	}
 
+	for _, ingress := range kc.kingresses {
+		for _, tls := range ingress.Spec.TLS {
+			if tls.SecretNamespace == secret.Namespace && tls.SecretName == secret.Name {
+				return true
+			}
+		}
+	}
+
 	// Secrets referred by the configuration file shall also trigger rebuild.
 	for _, s := range kc.ConfiguredSecretRefs {
 		if s.Namespace == secret.Namespace && s.Name == secret.Name {
@@ -519,6 +681,23 @@ func (kc *KubernetesCache) secretTriggersRebuild(secret *v1.Secret) bool {
 	return false
 }
 
+// LookupUnstructured returns the object of the given kind and name,
+// for kinds read by processors added with RegisterProcessor.
+func (kc *KubernetesCache) LookupUnstructured(kind schema.GroupKind, name types.NamespacedName) (*unstructured.Unstructured, bool) {
+	obj, ok := kc.unstructured[kind][name]
+	return obj, ok
+}
+
+// ListUnstructured returns the objects of the given kind, for kinds
+// read by processors added with RegisterProcessor.
+func (kc *KubernetesCache) ListUnstructured(kind schema.GroupKind) []*unstructured.Unstructured {
+	objs := make([]*unstructured.Unstructured, 0, len(kc.unstructured[kind]))
+	for _, obj := range kc.unstructured[kind] {
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
 // LookupSecret returns a Secret if present or nil if the underlying kubernetes
 // secret fails validation or is missing.
 func (kc *KubernetesCache) LookupSecret(name types.NamespacedName, validate func(*v1.Secret) error) (*Secret, error) {
@@ -538,6 +717,18 @@ func (kc *KubernetesCache) LookupSecret(name types.NamespacedName, validate func
 	return s, nil
 }
 
+// LookupConfigMap returns the ConfigMap with the given name, if present.
+func (kc *KubernetesCache) LookupConfigMap(name types.NamespacedName) (*v1.ConfigMap, bool) {
+	configMap, ok := kc.configmaps[name]
+	return configMap, ok
+}
+
+// LookupWasmModule returns the WasmModule with the given name, if present.
+func (kc *KubernetesCache) LookupWasmModule(name types.NamespacedName) (*contour_api_v1alpha1.WasmModule, bool) {
+	module, ok := kc.wasmmodules[name]
+	return module, ok
+}
+
 func (kc *KubernetesCache) LookupUpstreamValidation(uv *contour_api_v1.UpstreamValidation, caCertificate types.NamespacedName) (*PeerValidationContext, error) {
 	if uv == nil {
 		// no upstream validation requested, nothing to do
@@ -564,18 +755,6 @@ func (kc *KubernetesCache) LookupUpstreamValidation(uv *contour_api_v1.UpstreamV
 // DelegationPermitted returns true if the referenced secret has been delegated
 // to the namespace where the ingress object is located.
 func (kc *KubernetesCache) DelegationPermitted(secret types.NamespacedName, targetNamespace string) bool {
-	contains := func(haystack []string, needle string) bool {
-		if len(haystack) == 1 && haystack[0] == "*" {
-			return true
-		}
-		for _, h := range haystack {
-			if h == needle {
-				return true
-			}
-		}
-		return false
-	}
-
 	if secret.Namespace == targetNamespace {
 		// secret is in the same namespace as target
 		return true
@@ -586,16 +765,47 @@ func (kc *KubernetesCache) DelegationPermitted(secret types.NamespacedName, targ
 			continue
 		}
 		for _, d := range d.Spec.Delegations {
-			if contains(d.TargetNamespaces, targetNamespace) {
-				if secret.Name == d.SecretName {
-					return true
-				}
+			if delegatesTo(d, secret.Name, targetNamespace) {
+				return true
 			}
 		}
 	}
 	return false
 }
 
+// LookupContourPolicy returns the ContourPolicy of the namespace, or
+// nil if it has none. If the namespace has more than one ContourPolicy,
+// the first by name is returned.
+func (kc *KubernetesCache) LookupContourPolicy(namespace string) *contour_api_v1alpha1.ContourPolicy {
+	var policy *contour_api_v1alpha1.ContourPolicy
+	for _, p := range kc.contourpolicies {
+		if p.Namespace != namespace {
+			continue
+		}
+		if policy == nil || p.Name < policy.Name {
+			policy = p
+		}
+	}
+	return policy
+}
+
+// delegatesTo returns true if the delegation grants the target
+// namespace the authority to reference the named secret.
+func delegatesTo(d contour_api_v1.CertificateDelegation, secretName, targetNamespace string) bool {
+	if d.SecretName != secretName {
+		return false
+	}
+	if len(d.TargetNamespaces) == 1 && d.TargetNamespaces[0] == "*" {
+		return true
+	}
+	for _, n := range d.TargetNamespaces {
+		if n == targetNamespace {
+			return true
+		}
+	}
+	return false
+}
+
 func validCA(s *v1.Secret) error {
 	if len(s.Data[CACertificateKey]) == 0 {
 		return fmt.Errorf("empty %q key", CACertificateKey)
@@ -626,3 +836,31 @@ func (kc *KubernetesCache) LookupService(meta types.NamespacedName, port intstr.
 
 	return nil, v1.ServicePort{}, fmt.Errorf("port %q on service %q not matched", port.String(), meta)
 }
+
+// LookupServiceImport returns the multi-cluster ServiceImport and port matching the
+// provided parameters, or an error if a match can't be found. The port is returned
+// as a v1.ServicePort so that it can be used like the port of a Service.
+func (kc *KubernetesCache) LookupServiceImport(meta types.NamespacedName, port intstr.IntOrString) (*mcs_v1alpha1.ServiceImport, v1.ServicePort, error) {
+	svc, ok := kc.serviceimports[meta]
+	if !ok {
+		return nil, v1.ServicePort{}, fmt.Errorf("service import %q not found", meta)
+	}
+
+	for _, p := range svc.Spec.Ports {
+		if int(p.Port) == port.IntValue() || port.String() == p.Name {
+			switch p.Protocol {
+			case "", v1.ProtocolTCP:
+				return svc, v1.ServicePort{
+					Name:        p.Name,
+					Protocol:    p.Protocol,
+					AppProtocol: p.AppProtocol,
+					Port:        p.Port,
+				}, nil
+			default:
+				return nil, v1.ServicePort{}, fmt.Errorf("unsupported service protocol %q", p.Protocol)
+			}
+		}
+	}
+
+	return nil, v1.ServicePort{}, fmt.Errorf("port %q on service import %q not matched", port.String(), meta)
+}
@@ -21,11 +21,16 @@ import (
 	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/ingressclass"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
+	"github.com/projectcontour/contour/internal/shard"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
@@ -836,6 +841,62 @@ func TestKubernetesCacheInsert(t *testing.T) {
 			},
 			want: true,
 		},
+		"insert configmap": {
+			obj: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "scripts",
+					Namespace: "default",
+				},
+			},
+			want: false,
+		},
+		"insert configmap referenced by httpproxy lua policy": {
+			pre: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: contour_api_v1.HTTPProxySpec{
+						Routes: []contour_api_v1.Route{{
+							Lua: &contour_api_v1.LuaPolicy{
+								ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{
+									Name: "scripts",
+									Key:  "route.lua",
+								},
+							},
+						}},
+					},
+				},
+			},
+			obj: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "scripts",
+					Namespace: "default",
+				},
+			},
+			want: true,
+		},
+		"insert configmap referenced by wasm module": {
+			pre: []interface{}{
+				&contour_api_v1alpha1.WasmModule{
+					ObjectMeta: fixture.ObjectMeta("default/headers"),
+					Spec: contour_api_v1alpha1.WasmModuleSpec{
+						ConfigMapRef: &contour_api_v1.ConfigMapKeyReference{
+							Name: "modules",
+							Key:  "headers.wasm",
+						},
+					},
+				},
+			},
+			obj: &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "modules",
+					Namespace: "default",
+				},
+			},
+			want: true,
+		},
 		// invalid gatewayclass test case is unneeded since the controller
 		// uses a predicate to filter events before they're given to the EventHandler.
 		"insert valid gatewayclass": {
@@ -915,6 +976,64 @@ func TestKubernetesCacheInsert(t *testing.T) {
 			},
 			want: true,
 		},
+		"insert contour policy": {
+			obj: &contour_api_v1alpha1.ContourPolicy{
+				ObjectMeta: fixture.ObjectMeta("default/policy"),
+			},
+			want: true,
+		},
+		"insert wasm module": {
+			obj: &contour_api_v1alpha1.WasmModule{
+				ObjectMeta: fixture.ObjectMeta("default/headers"),
+			},
+			want: true,
+		},
+		"insert knative ingress with contour ingress class": {
+			obj: &knative_v1alpha1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "hello",
+					Namespace: "default",
+					Annotations: map[string]string{
+						knative_v1alpha1.ClassAnnotationKey: knative_v1alpha1.ContourIngressClassName,
+					},
+				},
+			},
+			want: true,
+		},
+		"insert knative ingress with another ingress class": {
+			obj: &knative_v1alpha1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "hello",
+					Namespace: "default",
+					Annotations: map[string]string{
+						knative_v1alpha1.ClassAnnotationKey: "istio.ingress.networking.knative.dev",
+					},
+				},
+			},
+			want: false,
+		},
+		"insert service import": {
+			obj: &mcs_v1alpha1.ServiceImport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kuard",
+					Namespace: "default",
+				},
+			},
+			want: true,
+		},
+		"insert unstructured": {
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "example.com/v1",
+					"kind":       "CanaryPolicy",
+					"metadata": map[string]interface{}{
+						"name":      "canary",
+						"namespace": "default",
+					},
+				},
+			},
+			want: true,
+		},
 		"insert secret that is referred by configuration file": {
 			obj: &v1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1244,6 +1363,47 @@ func TestKubernetesCacheRemove(t *testing.T) {
 			},
 			want: true,
 		},
+		"remove contour policy": {
+			cache: cache(&contour_api_v1alpha1.ContourPolicy{
+				ObjectMeta: fixture.ObjectMeta("default/policy"),
+			}),
+			obj: &contour_api_v1alpha1.ContourPolicy{
+				ObjectMeta: fixture.ObjectMeta("default/policy"),
+			},
+			want: true,
+		},
+		"remove wasm module": {
+			cache: cache(&contour_api_v1alpha1.WasmModule{
+				ObjectMeta: fixture.ObjectMeta("default/headers"),
+			}),
+			obj: &contour_api_v1alpha1.WasmModule{
+				ObjectMeta: fixture.ObjectMeta("default/headers"),
+			},
+			want: true,
+		},
+		"remove unstructured": {
+			cache: cache(&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "example.com/v1",
+					"kind":       "CanaryPolicy",
+					"metadata": map[string]interface{}{
+						"name":      "canary",
+						"namespace": "default",
+					},
+				},
+			}),
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "example.com/v1",
+					"kind":       "CanaryPolicy",
+					"metadata": map[string]interface{}{
+						"name":      "canary",
+						"namespace": "default",
+					},
+				},
+			},
+			want: true,
+		},
 		"remove unknown": {
 			cache: cache("not an object"),
 			obj:   "not an object",
@@ -1855,3 +2015,155 @@ func TestSecretTriggersRebuild(t *testing.T) {
 		})
 	}
 }
+
+func TestKubernetesCacheAdmitsIngress(t *testing.T) {
+	defaultClass := &networking_v1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "something",
+			Annotations: map[string]string{
+				ingressclass.DefaultClassAnnotation: "true",
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		pre  []interface{}
+		obj  *networking_v1.Ingress
+		want bool
+	}{
+		"ingress in configured class": {
+			obj: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "default"},
+				Spec: networking_v1.IngressSpec{
+					IngressClassName: pointer.StringPtr("something"),
+				},
+			},
+			want: true,
+		},
+		"ingress without class, configured class not default": {
+			pre: []interface{}{
+				&networking_v1.IngressClass{
+					ObjectMeta: metav1.ObjectMeta{Name: "something"},
+				},
+			},
+			obj: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "default"},
+			},
+			want: false,
+		},
+		"ingress without class, configured class is default": {
+			pre: []interface{}{defaultClass},
+			obj: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "default"},
+			},
+			want: true,
+		},
+		"ingress in other class, configured class is default": {
+			pre: []interface{}{defaultClass},
+			obj: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "default"},
+				Spec: networking_v1.IngressSpec{
+					IngressClassName: pointer.StringPtr("nginx"),
+				},
+			},
+			want: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cache := KubernetesCache{
+				IngressClassNames: []string{"something"},
+				FieldLogger:       fixture.NewTestLogger(t),
+			}
+			for _, p := range tc.pre {
+				cache.Insert(p)
+			}
+			assert.Equal(t, tc.want, cache.admitsIngress(tc.obj))
+		})
+	}
+}
+
+func TestKubernetesCacheInsertShard(t *testing.T) {
+	tests := map[string]struct {
+		obj  interface{}
+		want bool
+	}{
+		"insert ingress in shard": {
+			obj: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ingress",
+					Namespace: "default",
+					Labels:    map[string]string{"environment": "prod"},
+				},
+			},
+			want: true,
+		},
+		"insert ingress outside of shard": {
+			obj: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ingress",
+					Namespace: "default",
+					Labels:    map[string]string{"environment": "staging"},
+				},
+			},
+			want: false,
+		},
+		"insert knative ingress outside of shard": {
+			obj: &knative_v1alpha1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "hello",
+					Namespace: "default",
+					Annotations: map[string]string{
+						knative_v1alpha1.ClassAnnotationKey: knative_v1alpha1.ContourIngressClassName,
+					},
+				},
+			},
+			want: false,
+		},
+		"insert httpproxy outside of shard": {
+			// HTTPProxies are kept, since a root HTTPProxy
+			// in the shard may include them.
+			obj: &contour_api_v1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "proxy",
+					Namespace: "default",
+				},
+			},
+			want: true,
+		},
+	}
+
+	selector, err := labels.Parse("environment=prod")
+	require.NoError(t, err)
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cache := KubernetesCache{
+				Shard:       &shard.Selector{Labels: selector},
+				FieldLogger: fixture.NewTestLogger(t),
+			}
+			assert.Equal(t, tc.want, cache.Insert(tc.obj))
+		})
+	}
+}
+
+func TestKubernetesCacheLookupContourPolicy(t *testing.T) {
+	cache := KubernetesCache{
+		FieldLogger: fixture.NewTestLogger(t),
+	}
+	cache.Insert(&contour_api_v1alpha1.ContourPolicy{
+		ObjectMeta: fixture.ObjectMeta("default/policy-b"),
+	})
+	cache.Insert(&contour_api_v1alpha1.ContourPolicy{
+		ObjectMeta: fixture.ObjectMeta("default/policy-a"),
+	})
+	cache.Insert(&contour_api_v1alpha1.ContourPolicy{
+		ObjectMeta: fixture.ObjectMeta("other/policy"),
+	})
+
+	// The first policy of the namespace by name is used.
+	assert.Equal(t, "policy-a", cache.LookupContourPolicy("default").Name)
+	assert.Equal(t, "policy", cache.LookupContourPolicy("other").Name)
+	assert.Nil(t, cache.LookupContourPolicy("missing"))
+}
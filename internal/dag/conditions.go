@@ -17,11 +17,17 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/geoip"
 )
 
+// countryCode matches ISO 3166-1 alpha-2 country codes.
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
 // mergePathMatchConditions merges the given slice of prefix MatchConditions into a single
 // prefix Condition.
 // pathMatchConditionsValid guarantees that if a prefix is present, it will start with a
@@ -64,11 +70,40 @@ func pathMatchConditionsValid(conds []contour_api_v1.MatchCondition) error {
 		if prefixCount > 1 {
 			return errors.New("more than one prefix is not allowed in a condition block")
 		}
+		if cond.NotPrefix != "" {
+			if cond.NotPrefix[0] != '/' {
+				return fmt.Errorf("notprefix conditions must start with /, %s was supplied", cond.NotPrefix)
+			}
+			if strings.Trim(cond.NotPrefix, "/") == "" {
+				return fmt.Errorf("notprefix condition %s excludes every request", cond.NotPrefix)
+			}
+		}
 	}
 
 	return nil
 }
 
+// mergeNotPrefixConditions returns conds, the conditions of an include or
+// route, with each of their NotPrefix values appended to the prefix that
+// conds match along with the conditions of the includes that lead to
+// them, outer. Once merged, NotPrefix values hold the whole prefix that
+// they exclude.
+func mergeNotPrefixConditions(outer, conds []contour_api_v1.MatchCondition) []contour_api_v1.MatchCondition {
+	prefix := mergePathMatchConditions(append(outer[:len(outer):len(outer)], conds...)).(*PrefixMatchCondition).Prefix
+
+	var merged []contour_api_v1.MatchCondition
+	for _, cond := range conds {
+		if cond.NotPrefix != "" {
+			cond.NotPrefix = mergePathMatchConditions([]contour_api_v1.MatchCondition{
+				{Prefix: prefix},
+				{Prefix: cond.NotPrefix},
+			}).(*PrefixMatchCondition).Prefix
+		}
+		merged = append(merged, cond)
+	}
+	return merged
+}
+
 func mergeHeaderMatchConditions(conds []contour_api_v1.MatchCondition) []HeaderMatchCondition {
 	var headerConditions []contour_api_v1.HeaderMatchCondition
 	for _, cond := range conds {
@@ -77,7 +112,107 @@ func mergeHeaderMatchConditions(conds []contour_api_v1.MatchCondition) []HeaderM
 		}
 	}
 
-	return headerMatchConditions(headerConditions)
+	hc := append(headerMatchConditions(headerConditions), geoMatchConditions(conds)...)
+	return append(hc, notPrefixMatchConditions(conds)...)
+}
+
+// notPrefixMatchConditions returns the header conditions on the :path
+// pseudo-header that implement the merged NotPrefix conditions of conds.
+// Since the path of a request includes its query string, the prefix is
+// matched like a prefix condition matches it.
+func notPrefixMatchConditions(conds []contour_api_v1.MatchCondition) []HeaderMatchCondition {
+	var hc []HeaderMatchCondition
+	for _, cond := range conds {
+		if cond.NotPrefix != "" {
+			hc = append(hc, HeaderMatchCondition{
+				Name:      ":path",
+				Value:     regexp.QuoteMeta(cond.NotPrefix) + ".*",
+				MatchType: HeaderMatchTypeRegex,
+				Invert:    true,
+			})
+		}
+	}
+	return hc
+}
+
+// geoMatchConditions returns the header conditions on the GeoIP
+// headers that implement the geo conditions of conds.
+func geoMatchConditions(conds []contour_api_v1.MatchCondition) []HeaderMatchCondition {
+	var hc []HeaderMatchCondition
+
+	regex := func(name string, values []string, invert bool) {
+		hc = append(hc, HeaderMatchCondition{
+			Name:      name,
+			Value:     strings.Join(values, "|"),
+			MatchType: HeaderMatchTypeRegex,
+			Invert:    invert,
+		})
+	}
+	asns := func(numbers []uint32) []string {
+		var s []string
+		for _, n := range numbers {
+			s = append(s, strconv.FormatUint(uint64(n), 10))
+		}
+		return s
+	}
+
+	for _, cond := range conds {
+		switch g := cond.Geo; {
+		case g == nil:
+		case len(g.Countries) > 0:
+			regex(geoip.CountryHeader, g.Countries, false)
+		case len(g.NotCountries) > 0:
+			regex(geoip.CountryHeader, g.NotCountries, true)
+		case len(g.ASNs) > 0:
+			regex(geoip.ASNHeader, asns(g.ASNs), false)
+		case len(g.NotASNs) > 0:
+			regex(geoip.ASNHeader, asns(g.NotASNs), true)
+		}
+	}
+	return hc
+}
+
+// geoMatchConditionsValid validates the geo conditions within a slice of
+// MatchConditions. Each must set exactly one of its fields, to country
+// codes if countries are looked up, or to autonomous system numbers if
+// they are looked up.
+func geoMatchConditionsValid(conds []contour_api_v1.MatchCondition, countries, asns bool) error {
+	for _, cond := range conds {
+		g := cond.Geo
+		if g == nil {
+			continue
+		}
+
+		set := 0
+		for _, n := range []int{len(g.Countries), len(g.NotCountries), len(g.ASNs), len(g.NotASNs)} {
+			if n > 0 {
+				set++
+			}
+		}
+		if set != 1 {
+			return errors.New("geo conditions must set exactly one of countries, notcountries, asns or notasns")
+		}
+
+		for _, c := range append(g.Countries, g.NotCountries...) {
+			if !countries {
+				return errors.New("geo country conditions require a GeoIP country database")
+			}
+			if !countryCode.MatchString(c) {
+				return fmt.Errorf("invalid country code %q, must be an upper case ISO 3166-1 alpha-2 code", c)
+			}
+		}
+
+		for _, n := range append(g.ASNs, g.NotASNs...) {
+			if !asns {
+				return errors.New("geo ASN conditions require a GeoIP ASN database")
+			}
+			if n == 0 {
+				return errors.New("invalid autonomous system number 0")
+			}
+		}
+	}
+
+	return nil
 }
 
 func headerMatchConditions(conditions []contour_api_v1.HeaderMatchCondition) []HeaderMatchCondition {
@@ -211,6 +346,195 @@ func headerMatchConditionsValid(conditions []contour_api_v1.MatchCondition) erro
 	return nil
 }
 
+// includeMatchConditionsShadowed returns a map of include index to the index
+// of another include that matches every request it matches and whose routes
+// sort ahead of its own, so they receive all of its requests. Routes are
+// sorted by their merged conditions: the longest path prefix first, then the
+// most header conditions, then the most specific ones, with routes that sort
+// the same kept in include order. So a broader include only shadows another
+// with the same path prefix whose header conditions don't sort ahead of its
+// own, for example `contains: abc` shadows a later `contains: xabcx`, while
+// `present` doesn't shadow `exact: abc`, which sorts first. Conditions that
+// implement geo and notprefix conditions, and regex conditions, only imply
+// themselves. Route conditions and priorities in the included proxies are
+// not considered.
+func includeMatchConditionsShadowed(includes []contour_api_v1.Include) map[int]int {
+	paths := make([]string, len(includes))
+	headers := make([][]HeaderMatchCondition, len(includes))
+	for i, include := range includes {
+		paths[i] = mergePathMatchConditions(include.Conditions).String()
+		headers[i] = mergeHeaderMatchConditions(mergeNotPrefixConditions(nil, include.Conditions))
+		sort.SliceStable(headers[i], func(a, b int) bool {
+			return HeaderMatchConditionLess(headers[i][a], headers[i][b])
+		})
+	}
+
+	shadowed := map[int]int{}
+	for j := range includes {
+		for i := range includes {
+			if i == j || paths[i] != paths[j] || !headerMatchConditionsSubsume(headers[i], headers[j]) {
+				continue
+			}
+			if HeaderMatchConditionsLess(headers[j], headers[i]) {
+				continue
+			}
+			if i < j || HeaderMatchConditionsLess(headers[i], headers[j]) {
+				shadowed[j] = i
+				break
+			}
+		}
+	}
+	return shadowed
+}
+
+// headerMatchConditionsSubsume returns true if every request that matches
+// all the conditions in b also matches all the conditions in a.
+func headerMatchConditionsSubsume(a, b []HeaderMatchCondition) bool {
+	for _, ca := range a {
+		implied := false
+		for _, cb := range b {
+			if headerMatchConditionImplies(cb, ca) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// headerMatchConditionImplies returns true if every request that matches
+// b also matches a. Header names are compared case-insensitively. Envoy
+// matches an inverted condition when the header is missing, so only a
+// condition that is not inverted implies that the header is present.
+func headerMatchConditionImplies(b, a HeaderMatchCondition) bool {
+	if !strings.EqualFold(a.Name, b.Name) {
+		return false
+	}
+	if a.MatchType == b.MatchType && a.Value == b.Value && a.Invert == b.Invert {
+		return true
+	}
+
+	if b.Invert {
+		// A header that doesn't contain b's value doesn't
+		// contain any value that contains it either.
+		return a.Invert && a.MatchType == HeaderMatchTypeContains &&
+			b.MatchType == HeaderMatchTypeContains && strings.Contains(a.Value, b.Value)
+	}
+
+	switch a.MatchType {
+	case HeaderMatchTypePresent:
+		return !a.Invert
+	case HeaderMatchTypeExact:
+		return a.Invert && b.MatchType == HeaderMatchTypeExact && b.Value != a.Value
+	case HeaderMatchTypeContains:
+		switch b.MatchType {
+		case HeaderMatchTypeExact:
+			return strings.Contains(b.Value, a.Value) != a.Invert
+		case HeaderMatchTypeContains:
+			return !a.Invert && strings.Contains(b.Value, a.Value)
+		}
+	case HeaderMatchTypeGreaterThan:
+		if a.Invert {
+			return false
+		}
+		av, err := strconv.ParseInt(a.Value, 10, 64)
+		if err != nil {
+			return false
+		}
+		bv, err := strconv.ParseInt(b.Value, 10, 64)
+		if err != nil {
+			return false
+		}
+		switch b.MatchType {
+		case HeaderMatchTypeExact:
+			return bv > av
+		case HeaderMatchTypeGreaterThan:
+			return bv >= av
+		}
+	}
+	return false
+}
+
+// headerMatchTypeOrder ranks the header match types from
+// the most to the least specific.
+var headerMatchTypeOrder = map[string]int{
+	HeaderMatchTypeExact:       0,
+	HeaderMatchTypeRegex:       1,
+	HeaderMatchTypeGreaterThan: 2,
+	HeaderMatchTypeContains:    3,
+	HeaderMatchTypePresent:     4,
+}
+
+// HeaderMatchConditionLess returns true if a sorts ahead of b: by header
+// name, then from the most to the least specific match type, then by
+// value, with the match that is not inverted first.
+func HeaderMatchConditionLess(a, b HeaderMatchCondition) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.MatchType != b.MatchType {
+		return headerMatchTypeOrder[a.MatchType] < headerMatchTypeOrder[b.MatchType]
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return !a.Invert
+}
+
+// HeaderMatchConditionsLess returns true if a route with the sorted header
+// conditions a sorts ahead of a route with the same path and priority and
+// the sorted header conditions b: a is longer, or as long and its first
+// condition that differs sorts ahead, so that routes sort the same way
+// whatever order they start in.
+func HeaderMatchConditionsLess(a, b []HeaderMatchCondition) bool {
+	if len(a) == len(b) {
+		for i := range a {
+			switch {
+			case a[i] == b[i]:
+				continue
+			case HeaderMatchConditionLess(a[i], b[i]):
+				return true
+			case HeaderMatchConditionLess(b[i], a[i]):
+				return false
+			}
+		}
+	}
+
+	return len(a) > len(b)
+}
+
+// grpcPathMatchCondition returns the path MatchCondition for requests to
+// the gRPC service and, if set, method. gRPC requests use a path of the
+// form /<service>/<method>.
+func grpcPathMatchCondition(g *contour_api_v1.GRPCRoute) MatchCondition {
+	if g.Method == "" {
+		return &PrefixMatchCondition{
+			Prefix: "/" + g.Service + "/",
+		}
+	}
+	return &ExactMatchCondition{
+		Path: "/" + g.Service + "/" + g.Method,
+	}
+}
+
+// pathMatchConditionString returns the path matched by the MatchCondition,
+// without the prefix match type, for use in status messages.
+func pathMatchConditionString(mc MatchCondition) string {
+	switch c := mc.(type) {
+	case *PrefixMatchCondition:
+		return "prefix: " + c.Prefix
+	case *ExactMatchCondition:
+		return "exact: " + c.Path
+	case *RegexMatchCondition:
+		return "regex: " + c.Regex
+	default:
+		return ""
+	}
+}
+
 // ValidateRegex returns an error if the supplied
 // RE2 regex syntax is invalid.
 func ValidateRegex(regex string) error {
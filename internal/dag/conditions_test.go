@@ -138,6 +138,39 @@ func TestHeaderMatchConditions(t *testing.T) {
 				Invert:    true,
 			}},
 		},
+		"geo countries": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"DE", "FR"},
+				},
+			}},
+			want: []HeaderMatchCondition{{
+				Name:      "x-contour-geo-country",
+				Value:     "DE|FR",
+				MatchType: "regex",
+			}},
+		},
+		"geo not ASNs after header": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					NotASNs: []uint32{64496, 64497},
+				},
+			}, {
+				Header: &contour_api_v1.HeaderMatchCondition{
+					Name:    "x-request-id",
+					Present: true,
+				},
+			}},
+			want: []HeaderMatchCondition{{
+				Name:      "x-request-id",
+				MatchType: "present",
+			}, {
+				Name:      "x-contour-geo-asn",
+				Value:     "64496|64497",
+				MatchType: "regex",
+				Invert:    true,
+			}},
+		},
 		"header name but missing condition": {
 			matchconditions: []contour_api_v1.MatchCondition{{
 				Header: &contour_api_v1.HeaderMatchCondition{
@@ -246,6 +279,19 @@ func TestHeaderMatchConditions(t *testing.T) {
 				Value:     "abcdef",
 			}},
 		},
+		"not prefix": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Prefix: "/",
+			}, {
+				NotPrefix: "/admin.v1",
+			}},
+			want: []HeaderMatchCondition{{
+				Name:      ":path",
+				MatchType: "regex",
+				Value:     `/admin\.v1.*`,
+				Invert:    true,
+			}},
+		},
 	}
 
 	for name, tc := range tests {
@@ -317,6 +363,30 @@ func TestPrefixMatchConditionsValid(t *testing.T) {
 			}},
 			want: false,
 		},
+		"valid not prefix condition": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Prefix: "/",
+			}, {
+				NotPrefix: "/admin",
+			}, {
+				NotPrefix: "/internal",
+			}},
+			want: true,
+		},
+		"invalid not prefix condition": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				NotPrefix: "admin",
+			}},
+			want: false,
+		},
+		"not prefix condition that excludes every request": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Prefix: "/api",
+			}, {
+				NotPrefix: "/",
+			}},
+			want: false,
+		},
 	}
 
 	for name, tc := range tests {
@@ -327,6 +397,79 @@ func TestPrefixMatchConditionsValid(t *testing.T) {
 	}
 }
 
+func TestMergeNotPrefixConditions(t *testing.T) {
+	tests := map[string]struct {
+		outer []contour_api_v1.MatchCondition
+		conds []contour_api_v1.MatchCondition
+		want  []contour_api_v1.MatchCondition
+	}{
+		"no not prefix": {
+			conds: []contour_api_v1.MatchCondition{{
+				Prefix: "/api",
+			}},
+			want: []contour_api_v1.MatchCondition{{
+				Prefix: "/api",
+			}},
+		},
+		"root prefix": {
+			conds: []contour_api_v1.MatchCondition{{
+				Prefix: "/",
+			}, {
+				NotPrefix: "/admin",
+			}},
+			want: []contour_api_v1.MatchCondition{{
+				Prefix: "/",
+			}, {
+				NotPrefix: "/admin",
+			}},
+		},
+		"no prefix": {
+			conds: []contour_api_v1.MatchCondition{{
+				NotPrefix: "/admin",
+			}},
+			want: []contour_api_v1.MatchCondition{{
+				NotPrefix: "/admin",
+			}},
+		},
+		"under prefix": {
+			conds: []contour_api_v1.MatchCondition{{
+				NotPrefix: "/admin",
+			}, {
+				Prefix: "/api/",
+			}},
+			want: []contour_api_v1.MatchCondition{{
+				NotPrefix: "/api/admin",
+			}, {
+				Prefix: "/api/",
+			}},
+		},
+		"under outer prefix": {
+			outer: []contour_api_v1.MatchCondition{{
+				Prefix: "/api",
+			}, {
+				NotPrefix: "/api/admin",
+			}},
+			conds: []contour_api_v1.MatchCondition{{
+				Prefix: "/v1",
+			}, {
+				NotPrefix: "/internal",
+			}},
+			want: []contour_api_v1.MatchCondition{{
+				Prefix: "/v1",
+			}, {
+				NotPrefix: "/api/v1/internal",
+			}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := mergeNotPrefixConditions(tc.outer, tc.conds)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestValidateHeaderMatchConditions(t *testing.T) {
 	tests := map[string]struct {
 		matchconditions []contour_api_v1.MatchCondition
@@ -549,3 +692,350 @@ func TestValidateHeaderMatchConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateGeoMatchConditions(t *testing.T) {
+	tests := map[string]struct {
+		matchconditions []contour_api_v1.MatchCondition
+		countries, asns bool
+		wantErr         bool
+	}{
+		"no geo conditions": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Prefix: "/",
+			}},
+		},
+		"countries": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"DE", "FR"},
+				},
+			}, {
+				Geo: &contour_api_v1.GeoMatchCondition{
+					NotASNs: []uint32{64496},
+				},
+			}},
+			countries: true,
+			asns:      true,
+		},
+		"countries without a country database": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					NotCountries: []string{"DE"},
+				},
+			}},
+			asns:    true,
+			wantErr: true,
+		},
+		"ASNs without an ASN database": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					ASNs: []uint32{64496},
+				},
+			}},
+			countries: true,
+			wantErr:   true,
+		},
+		"lower case country": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"de"},
+				},
+			}},
+			countries: true,
+			wantErr:   true,
+		},
+		"country name": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"DEU"},
+				},
+			}},
+			countries: true,
+			wantErr:   true,
+		},
+		"ASN 0": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					ASNs: []uint32{0},
+				},
+			}},
+			asns:    true,
+			wantErr: true,
+		},
+		"empty geo condition": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{},
+			}},
+			countries: true,
+			asns:      true,
+			wantErr:   true,
+		},
+		"countries and ASNs in one condition": {
+			matchconditions: []contour_api_v1.MatchCondition{{
+				Geo: &contour_api_v1.GeoMatchCondition{
+					Countries: []string{"DE"},
+					ASNs:      []uint32{64496},
+				},
+			}},
+			countries: true,
+			asns:      true,
+			wantErr:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := geoMatchConditionsValid(tc.matchconditions, tc.countries, tc.asns)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIncludeMatchConditionsShadowed(t *testing.T) {
+	tests := map[string]struct {
+		includes []contour_api_v1.Include
+		want     map[int]int
+	}{
+		"no includes": {
+			includes: nil,
+			want:     map[int]int{},
+		},
+		"distinct prefixes": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/bar"}},
+			}},
+			want: map[int]int{},
+		},
+		"longer prefix is not shadowed": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo"}},
+			}},
+			want: map[int]int{},
+		},
+		"no conditions on either include": {
+			includes: []contour_api_v1.Include{{}, {}},
+			want:     map[int]int{1: 0},
+		},
+		"trailing slash matches different requests": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo/"}},
+			}},
+			want: map[int]int{},
+		},
+		"repeated slashes": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo/bar"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo//bar"}},
+			}},
+			want: map[int]int{1: 0},
+		},
+		"non-adjacent duplicate": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/bar"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo"}},
+			}},
+			want: map[int]int{2: 0},
+		},
+		"same header conditions in a different form": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/foo",
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "X-Header", Exact: "abc"},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/foo",
+				}, {
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Exact: "abc"},
+				}},
+			}},
+			want: map[int]int{1: 0},
+		},
+		"later include with more header conditions sorts first": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/foo",
+				}, {
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Present: true},
+				}},
+			}},
+			want: map[int]int{},
+		},
+		"header present does not hide exact match": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Present: true},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Exact: "abc"},
+				}},
+			}},
+			want: map[int]int{},
+		},
+		"geo conditions": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Geo: &contour_api_v1.GeoMatchCondition{Countries: []string{"DE"}},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Geo: &contour_api_v1.GeoMatchCondition{Countries: []string{"FR"}},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Geo: &contour_api_v1.GeoMatchCondition{Countries: []string{"DE"}},
+				}},
+			}},
+			want: map[int]int{2: 0},
+		},
+		"notprefix conditions": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/foo",
+				}, {
+					NotPrefix: "/admin",
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/foo",
+				}, {
+					NotPrefix: "/private",
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/foo",
+				}, {
+					NotPrefix: "/admin",
+				}},
+			}},
+			want: map[int]int{2: 0},
+		},
+		"broader contains condition": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Contains: "abc"},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Contains: "xabcx"},
+				}},
+			}},
+			want: map[int]int{1: 0},
+		},
+		"later broader include that sorts first": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Contains: "xabcx"},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Contains: "abc"},
+				}},
+			}},
+			want: map[int]int{0: 1},
+		},
+		"more conditions implied by a single condition": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Present: true},
+				}, {
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Contains: "a"},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Exact: "abc"},
+				}},
+			}},
+			want: map[int]int{1: 0},
+		},
+		"condition not implied": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Contains: "abc"},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", NotContains: "xabcx"},
+				}},
+			}},
+			want: map[int]int{},
+		},
+		"longer prefix sorts first": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo"}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{Prefix: "/foo/bar"}},
+			}},
+			want: map[int]int{},
+		},
+		"different header values": {
+			includes: []contour_api_v1.Include{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Exact: "abc"},
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Header: &contour_api_v1.HeaderMatchCondition{Name: "x-header", Exact: "def"},
+				}},
+			}},
+			want: map[int]int{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := includeMatchConditionsShadowed(tc.includes)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestHeaderMatchConditionImplies(t *testing.T) {
+	cond := func(matchType, value string, invert bool) HeaderMatchCondition {
+		return HeaderMatchCondition{Name: "x-header", MatchType: matchType, Value: value, Invert: invert}
+	}
+
+	tests := map[string]struct {
+		b, a HeaderMatchCondition
+		want bool
+	}{
+		"identical":                        {b: cond(HeaderMatchTypeRegex, "a.*", false), a: cond(HeaderMatchTypeRegex, "a.*", false), want: true},
+		"header name case":                 {b: HeaderMatchCondition{Name: "X-Header", MatchType: HeaderMatchTypePresent}, a: cond(HeaderMatchTypePresent, "", false), want: true},
+		"other header":                     {b: HeaderMatchCondition{Name: "x-other", MatchType: HeaderMatchTypePresent}, a: cond(HeaderMatchTypePresent, "", false), want: false},
+		"exact implies present":            {b: cond(HeaderMatchTypeExact, "abc", false), a: cond(HeaderMatchTypePresent, "", false), want: true},
+		"not exact does not imply present": {b: cond(HeaderMatchTypeExact, "abc", true), a: cond(HeaderMatchTypePresent, "", false), want: false},
+		"exact implies not exact":          {b: cond(HeaderMatchTypeExact, "abc", false), a: cond(HeaderMatchTypeExact, "def", true), want: true},
+		"exact implies contains":           {b: cond(HeaderMatchTypeExact, "abc", false), a: cond(HeaderMatchTypeContains, "b", false), want: true},
+		"exact implies not contains":       {b: cond(HeaderMatchTypeExact, "abc", false), a: cond(HeaderMatchTypeContains, "d", true), want: true},
+		"contains implies contains":        {b: cond(HeaderMatchTypeContains, "abc", false), a: cond(HeaderMatchTypeContains, "b", false), want: true},
+		"not contains implies not contains": {
+			b: cond(HeaderMatchTypeContains, "b", true), a: cond(HeaderMatchTypeContains, "abc", true), want: true,
+		},
+		"greater than":       {b: cond(HeaderMatchTypeGreaterThan, "10", false), a: cond(HeaderMatchTypeGreaterThan, "5", false), want: true},
+		"exact greater than": {b: cond(HeaderMatchTypeExact, "5", false), a: cond(HeaderMatchTypeGreaterThan, "5", false), want: false},
+		"regex":              {b: cond(HeaderMatchTypeExact, "abc", false), a: cond(HeaderMatchTypeRegex, "a.*", false), want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, headerMatchConditionImplies(tc.b, tc.a))
+		})
+	}
+}
@@ -18,6 +18,7 @@ package dag
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +27,7 @@ import (
 	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/projectcontour/contour/internal/xds"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -67,10 +69,22 @@ type DAG struct {
 	// StatusCache holds a cache of status updates to send.
 	StatusCache status.Cache
 
+	// Expiry is the earliest time at which part of this DAG
+	// expires, after which it should be rebuilt. It is zero
+	// if nothing in the DAG expires.
+	Expiry time.Time
+
 	// roots are the root vertices of this DAG.
 	roots []Vertex
 }
 
+// ExpireAt records that part of the DAG expires at t.
+func (d *DAG) ExpireAt(t time.Time) {
+	if d.Expiry.IsZero() || t.Before(d.Expiry) {
+		d.Expiry = t
+	}
+}
+
 // Visit calls fn on each root of this DAG.
 func (d *DAG) Visit(fn func(Vertex)) {
 	for _, r := range d.roots {
@@ -165,6 +179,10 @@ const (
 	// HeaderMatchTypeRegex matches a header if it matches the provided regular
 	// expression.
 	HeaderMatchTypeRegex = "regex"
+
+	// HeaderMatchTypeGreaterThan matches a header if its value is an
+	// integer greater than the provided value.
+	HeaderMatchTypeGreaterThan = "greater_than"
 )
 
 // HeaderMatchCondition matches request headers by MatchType
@@ -204,8 +222,17 @@ type Route struct {
 	// match on the request headers.
 	HeaderMatchConditions []HeaderMatchCondition
 
+	// Priority orders the route among the routes with the same
+	// PathMatchCondition. Routes with a higher priority are
+	// matched first.
+	Priority int32
+
 	Clusters []*Cluster
 
+	// DynamicForwardProxy, if set, proxies requests for this route to
+	// the host named by the request's Host header instead of Clusters.
+	DynamicForwardProxy *DynamicForwardProxyCluster
+
 	// Should this route generate a 301 upgrade if accessed
 	// over HTTP?
 	HTTPSUpgrade bool
@@ -228,6 +255,21 @@ type Route struct {
 	// RetryPolicy defines the retry / number / timeout options for a route
 	RetryPolicy *RetryPolicy
 
+	// HedgePolicy defines the request hedging options for a route
+	HedgePolicy *HedgePolicy
+
+	// TracingPolicy overrides the tracing of requests to this route.
+	TracingPolicy *TracingPolicy
+
+	// StatsName, if not empty, is the name of the Envoy virtual
+	// cluster that reports request statistics for this route.
+	StatsName string
+
+	// AccessLogSampling, if not nil, is the percentage of requests
+	// to this route that are written to the access log. Zero turns
+	// off access logging of the route.
+	AccessLogSampling *uint32
+
 	// Indicates that during forwarding, the matched prefix (or path) should be swapped with this value
 	PrefixRewrite string
 
@@ -240,6 +282,31 @@ type Route struct {
 	// ResponseHeadersPolicy defines how headers are managed during forwarding
 	ResponseHeadersPolicy *HeadersPolicy
 
+	// CookieRewritePolicies defines how the attributes of the
+	// cookies set by responses are rewritten.
+	CookieRewritePolicies []CookieRewritePolicy
+
+	// LuaScript, if not empty, is the source code of the Lua script
+	// that Envoy runs on the requests of the route, in place of the
+	// script of the virtual host.
+	LuaScript string
+
+	// CachePolicy defines how the responses of the route are
+	// cached. If nil, they are not cached.
+	CachePolicy *CachePolicy
+
+	// BufferPolicy defines how the bodies of requests to
+	// the route are buffered. If nil, they are not buffered.
+	BufferPolicy *BufferPolicy
+
+	// TapPolicy defines how requests to the route, and their
+	// responses, are captured. If nil, they are not captured.
+	TapPolicy *TapPolicy
+
+	// RBACPolicy defines which requests to the route are
+	// allowed. If nil, all of them are allowed.
+	RBACPolicy *RBACPolicy
+
 	// RateLimitPolicy defines if/how requests for the route are rate limited.
 	RateLimitPolicy *RateLimitPolicy
 
@@ -251,6 +318,13 @@ type Route struct {
 	// to be the response to a route request vs routing to
 	// an envoy cluster.
 	DirectResponse *DirectResponse
+
+	// GRPC restricts the route to gRPC requests.
+	GRPC bool
+
+	// GRPCTimeoutHeaderMax, if not the default, enables the
+	// grpc-timeout request header, limited to this value.
+	GRPCTimeoutHeaderMax timeout.Setting
 }
 
 // HasPathPrefix returns whether this route has a PrefixPathCondition.
@@ -293,9 +367,43 @@ type RetryPolicy struct {
 	PerTryTimeout timeout.Setting
 }
 
+// HedgePolicy defines the request hedging options for a route.
+type HedgePolicy struct {
+	// InitialRequests is the number of requests sent
+	// upstream at once. Zero uses the Envoy default of one.
+	InitialRequests uint32
+
+	// HedgeOnPerTryTimeout sends a new request when the
+	// per-try timeout elapses, without cancelling the
+	// outstanding one.
+	HedgeOnPerTryTimeout bool
+}
+
+// TracingPolicy defines how requests to a route are traced.
+type TracingPolicy struct {
+	// SamplingPercentage, if not nil, overrides the percentage
+	// of requests that are traced.
+	SamplingPercentage *uint32
+
+	// CustomTags are tags added to the spans of requests.
+	CustomTags []TracingCustomTag
+}
+
+// TracingCustomTag is a tag added to spans, with either a literal
+// value or the value of a request header.
+type TracingCustomTag struct {
+	TagName           string
+	Literal           string
+	RequestHeaderName string
+}
+
 // MirrorPolicy defines the mirroring policy for a route.
 type MirrorPolicy struct {
 	Cluster *Cluster
+
+	// RequestHeadersPolicy, if not nil, defines how the headers
+	// of mirrored requests are managed.
+	RequestHeadersPolicy *HeadersPolicy
 }
 
 // HeadersPolicy defines how headers are managed during forwarding
@@ -306,6 +414,125 @@ type HeadersPolicy struct {
 	Add    map[string]string
 	Set    map[string]string
 	Remove []string
+
+	// RemoveMatching holds the matches of the names of the
+	// headers to remove, before any headers are set.
+	RemoveMatching []HeaderNameMatch
+}
+
+// HeaderNameMatch matches the lower case names of headers.
+type HeaderNameMatch struct {
+	// Prefix, if not empty, matches the names that start with it.
+	Prefix string
+
+	// Pattern, if not empty, is a Lua pattern that matches the
+	// names. It is translated from a regular expression.
+	Pattern string
+}
+
+// CookieRewritePolicy defines how the attributes of a cookie
+// set by a Set-Cookie response header are rewritten.
+type CookieRewritePolicy struct {
+	// Name is the name of the cookie to rewrite. If empty, the
+	// policy applies to every cookie no other policy names.
+	Name string
+
+	// Domain, if not nil, replaces the Domain attribute. The
+	// empty string removes it.
+	Domain *string
+
+	// Secure and HTTPOnly, if not nil, add or remove the
+	// Secure and HttpOnly attributes.
+	Secure   *bool
+	HTTPOnly *bool
+
+	// SameSite, if not empty, replaces the SameSite attribute.
+	SameSite string
+}
+
+// BufferPolicy defines how the bodies of requests to a route are buffered.
+type BufferPolicy struct {
+	// MaxRequestBytes is the size of the largest request
+	// body that is buffered.
+	MaxRequestBytes uint32
+}
+
+// CachePolicy defines how the responses of a route are cached.
+type CachePolicy struct {
+	// Methods are the methods of the requests that are
+	// served from the cache.
+	Methods []string
+
+	// MaxObjectSize, if not zero, is the size of the largest
+	// response body that is cached.
+	MaxObjectSize uint32
+
+	// KeyHeaders are the lower case names of the request
+	// headers that are part of the cache key.
+	KeyHeaders []string
+}
+
+// TapPolicy defines how the requests to a route, and their
+// responses, are captured.
+type TapPolicy struct {
+	// ID identifies the tap. It is unique to the route
+	// of the HTTPProxy that the tap policy belongs to.
+	ID string
+
+	// MaxRequests is the number of requests that are captured.
+	MaxRequests uint32
+
+	// HeaderMatchConditions select the requests that are captured.
+	HeaderMatchConditions []HeaderMatchCondition
+
+	// PathPrefix is the prefix of the paths of the files
+	// that captured requests are written to, if Service
+	// is nil.
+	PathPrefix string
+
+	// Service is the extension service that captured
+	// requests are streamed to, if not nil.
+	Service *ExtensionCluster
+}
+
+// RBACPolicy defines which requests to a route are allowed.
+type RBACPolicy struct {
+	// Deny is true if the requests that match a rule are
+	// denied, rather than the requests that match no rule.
+	Deny bool
+
+	// Rules are the rules that requests are matched against.
+	Rules []RBACRule
+}
+
+// RBACRule matches the requests that match one of its
+// principals and one of its permissions. Empty lists
+// match any request.
+type RBACRule struct {
+	Principals  []RBACPrincipal
+	Permissions []RBACPermission
+}
+
+// RBACPrincipal matches the client of a request. Exactly
+// one field is set.
+type RBACPrincipal struct {
+	// SubjectAltName matches a subject alternative name
+	// of the client certificate.
+	SubjectAltName string
+
+	// SourceCIDR matches the address of the client.
+	SourceCIDR *net.IPNet
+
+	// Header matches a request header.
+	Header *HeaderMatchCondition
+}
+
+// RBACPermission matches the requests for any of its methods
+// whose path starts with its prefix. Empty fields match any
+// request.
+type RBACPermission struct {
+	Methods    []string
+	PathPrefix string
 }
 
 // RateLimitPolicy holds rate limiting parameters.
@@ -353,6 +580,9 @@ type RequestHashPolicy struct {
 
 	// CookieHashOptions is set when a cookie hash is desired.
 	CookieHashOptions *CookieHashOptions
+
+	// HashSourceIP is set to true when source ip hashing is desired.
+	HashSourceIP bool
 }
 
 // GlobalRateLimitPolicy holds global rate limiting parameters.
@@ -457,6 +687,9 @@ func (r *Route) Visit(f func(Vertex)) {
 	for _, c := range r.Clusters {
 		f(c)
 	}
+	if r.DynamicForwardProxy != nil {
+		f(r.DynamicForwardProxy)
+	}
 	// Allow any mirror clusters to also be visited so that
 	// they are also added to CDS.
 	if r.MirrorPolicy != nil && r.MirrorPolicy.Cluster != nil {
@@ -479,6 +712,18 @@ type VirtualHost struct {
 	// are rate limited.
 	RateLimitPolicy *RateLimitPolicy
 
+	// StatsName, if not empty, is the name of the Envoy virtual
+	// cluster that reports request statistics for the virtual host.
+	StatsName string
+
+	// RequestIDHeader, if not empty, is the name of the request
+	// header whose value is used as the x-request-id of requests.
+	RequestIDHeader string
+
+	// LuaScript, if not empty, is the source code of the Lua script
+	// that Envoy runs on the requests of the virtual host.
+	LuaScript string
+
 	routes map[string]*Route
 }
 
@@ -527,6 +772,10 @@ type SecureVirtualHost struct {
 	// DownstreamValidation defines how to verify the client's certificate.
 	DownstreamValidation *PeerValidationContext
 
+	// ClientCertificateHeaders maps the names of request headers to
+	// the fields of the client certificate that they are set to.
+	ClientCertificateHeaders map[string]string
+
 	// AuthorizationService points to the extension that client
 	// requests are forwarded to for authorization. If nil, no
 	// authorization is enabled for this host.
@@ -541,6 +790,71 @@ type SecureVirtualHost struct {
 	// only reason to set this to `true` is when you are migrating
 	// from internal to external authorization.
 	AuthorizationFailOpen bool
+
+	// XffNumTrustedHops overrides the number of additional ingress
+	// proxy hops from the right side of the X-Forwarded-For header
+	// to trust. If nil, the listener default is used.
+	XffNumTrustedHops *uint32
+
+	// SkipXffAppend disables appending the client address to the
+	// X-Forwarded-For header.
+	SkipXffAppend bool
+
+	// ReplaceExternalRequestID overrides whether the x-request-id
+	// header of requests from external clients is replaced with a
+	// generated ID. If nil, the listener default is used.
+	ReplaceExternalRequestID *bool
+
+	// WasmModules are the Wasm modules that filter the requests
+	// to this host, in order.
+	WasmModules []*WasmModule
+
+	// AdmissionControlPolicy defines how requests to this host are
+	// rejected while its services fail. If nil, none are rejected.
+	AdmissionControlPolicy *AdmissionControlPolicy
+}
+
+// AdmissionControlPolicy defines how requests to a virtual host are
+// rejected while its services fail.
+type AdmissionControlPolicy struct {
+	// SuccessRateThreshold is the percentage of successful
+	// requests below which requests are rejected.
+	SuccessRateThreshold uint32
+
+	// Aggression defines how quickly the share of rejected
+	// requests grows as the success rate drops.
+	Aggression float64
+
+	// SamplingWindow is the length of the window over which
+	// the success rate is measured.
+	SamplingWindow time.Duration
+
+	// MinRequestsPerSecond is the rate of requests below
+	// which no requests are rejected.
+	MinRequestsPerSecond uint32
+
+	// MaxRejectionPercentage is the largest percentage
+	// of requests that are rejected.
+	MaxRejectionPercentage uint32
+}
+
+// WasmModule is a Wasm module that runs as an HTTP filter.
+type WasmModule struct {
+	// Name is the namespace and name of the WasmModule.
+	Name string
+
+	// Code is the compiled module. If nil, the module could not
+	// be loaded, and requests fail in its place.
+	Code []byte
+
+	// RootID selects the root context of the module.
+	RootID string
+
+	// Configuration is passed to the module when it starts.
+	Configuration string
+
+	// FailOpen sets whether requests pass on when the module fails.
+	FailOpen bool
 }
 
 func (s *SecureVirtualHost) Visit(f func(Vertex)) {
@@ -560,6 +874,32 @@ func (s *SecureVirtualHost) Valid() bool {
 	return (s.Secret != nil && len(s.routes) > 0) || s.TCPProxy != nil
 }
 
+// A TCPVirtualHost represents a TCP proxy bound to a dedicated
+// plain TCP listener.
+type TCPVirtualHost struct {
+	// Name is the fully qualified domain name of the HTTPProxy
+	// that selected the listener.
+	Name string
+
+	ListenerName string
+
+	// Port is the TCP port of the listener.
+	Port int
+
+	// Service to TCP proxy all incoming connections.
+	*TCPProxy
+}
+
+func (t *TCPVirtualHost) Visit(f func(Vertex)) {
+	if t.TCPProxy != nil {
+		f(t.TCPProxy)
+	}
+}
+
+func (t *TCPVirtualHost) Valid() bool {
+	return t.TCPProxy != nil
+}
+
 type ListenerName struct {
 	Name         string
 	ListenerName string
@@ -591,6 +931,18 @@ type TCPProxy struct {
 	// Clusters is the, possibly weighted, set
 	// of upstream services to forward decrypted traffic.
 	Clusters []*Cluster
+
+	// IdleTimeout is the timeout after which idle connections
+	// through the proxy are closed.
+	IdleTimeout timeout.Setting
+
+	// MaxConnectAttempts is the maximum number of unsuccessful
+	// upstream connection attempts. If zero, the default is used.
+	MaxConnectAttempts uint32
+
+	// DisableAccessLog, if true, turns off access logging of
+	// connections through the proxy.
+	DisableAccessLog bool
 }
 
 func (t *TCPProxy) Visit(f func(Vertex)) {
@@ -625,22 +977,57 @@ type Service struct {
 	// Envoy will allow to the upstream cluster.
 	MaxRetries uint32
 
+	// MaxRequestsPerConnection is the maximum number of requests
+	// Envoy will send on a single upstream connection.
+	MaxRequestsPerConnection uint32
+
+	// MaxConnectionDuration is the maximum lifetime of an upstream
+	// connection, after which it is drained and closed.
+	MaxConnectionDuration time.Duration
+
 	// ExternalName is an optional field referencing a dns entry for Service type "ExternalName"
 	ExternalName string
+
+	// DNSLookupFamily optionally overrides the DNSLookupFamily of
+	// the Clusters of an ExternalName Service.
+	DNSLookupFamily string
+
+	// DNSRefreshRate is how often the external name is resolved.
+	// If zero, the Envoy default is used.
+	DNSRefreshRate time.Duration
+
+	// RespectDNSTTL resolves the external name again when the
+	// TTL of its DNS records expires.
+	RespectDNSTTL bool
+
+	// TCPKeepalive configures TCP keepalive probes on connections
+	// to the upstream cluster. If nil, the configured default is used.
+	TCPKeepalive *TCPKeepalive
+
+	// StaticEndpoints is an optional list of addresses for a backend
+	// that is not discovered from a Kubernetes Service.
+	StaticEndpoints []StaticEndpoint
+}
+
+// StaticEndpoint is an address of a Service that is
+// not discovered from Kubernetes.
+type StaticEndpoint struct {
+	Address string
+	Port    int32
 }
 
 // Visit applies the visitor function to the Service vertex.
 func (s *Service) Visit(f func(Vertex)) {
+	// Static services have no endpoints to discover.
+	if len(s.StaticEndpoints) > 0 {
+		return
+	}
+
 	// A Service has only one WeightedService entry. Fake up a
 	// ServiceCluster so that the visitor can pretend to not
 	// know this.
 	c := ServiceCluster{
-		ClusterName: xds.ClusterLoadAssignmentName(
-			types.NamespacedName{
-				Name:      s.Weighted.ServiceName,
-				Namespace: s.Weighted.ServiceNamespace,
-			},
-			s.Weighted.ServicePort.Name),
+		ClusterName: s.Weighted.ClusterLoadAssignmentName(),
 		Services: []WeightedService{
 			s.Weighted,
 		},
@@ -701,10 +1088,134 @@ type Cluster struct {
 	// ClientCertificate is the optional identifier of the TLS secret containing client certificate and
 	// private key to be used when establishing TLS connection to upstream cluster.
 	ClientCertificate *Secret
+
+	// SPIFFE is the optional SPIFFE identity used in place of
+	// ClientCertificate when establishing TLS connection to
+	// upstream cluster.
+	SPIFFE *SPIFFEIdentity
+
+	// UpstreamProxyProtocol is the version of the PROXY protocol
+	// header sent to the upstream cluster, either "v1" or "v2".
+	// If empty, no PROXY protocol header is sent.
+	UpstreamProxyProtocol string
+
+	// ConnectTimeout is the timeout for establishing a connection
+	// to the upstream cluster. If zero, the default is used.
+	ConnectTimeout time.Duration
+
+	// HTTP2 tunes the HTTP/2 connections to an h2 or h2c
+	// upstream cluster. If nil, Envoy's defaults are used.
+	HTTP2 *HTTP2Settings
+
+	// Failover are the services that receive the traffic of
+	// this cluster when all the endpoints of Upstream are unhealthy.
+	Failover []*Service
 }
 
 func (c Cluster) Visit(f func(Vertex)) {
 	f(c.Upstream)
+	for _, s := range c.Failover {
+		f(s)
+	}
+
+	port := c.healthCheckPort()
+	if len(c.Failover) == 0 && port == 0 {
+		return
+	}
+
+	// The primary and failover endpoints share a
+	// ClusterLoadAssignment, in which the failover
+	// endpoints have a lower priority.
+	primary := c.Upstream.Weighted
+	primary.HealthCheckPort = port
+	sc := ServiceCluster{
+		ClusterName: c.ClusterLoadAssignmentName(),
+		Services: []WeightedService{
+			primary,
+		},
+	}
+	for _, s := range c.Failover {
+		w := s.Weighted
+		w.Priority = 1
+		w.HealthCheckPort = port
+		sc.Services = append(sc.Services, w)
+	}
+
+	f(&sc)
+}
+
+// healthCheckPort returns the port that the health checks
+// of this cluster are sent to, or zero for the service port.
+func (c *Cluster) healthCheckPort() int32 {
+	if c.HTTPHealthCheckPolicy == nil {
+		return 0
+	}
+	return c.HTTPHealthCheckPolicy.Port
+}
+
+// ClusterLoadAssignmentName returns the name of the EDS
+// ClusterLoadAssignment that holds the endpoints of this cluster.
+func (c *Cluster) ClusterLoadAssignmentName() string {
+	name := c.Upstream.Weighted.ClusterLoadAssignmentName()
+
+	// The endpoints carry the health check port, so clusters
+	// that health check another port have endpoints of their own.
+	if port := c.healthCheckPort(); port > 0 {
+		name += "/healthcheck=" + strconv.Itoa(int(port))
+	}
+
+	if len(c.Failover) == 0 {
+		return name
+	}
+
+	// The same service may fail over to different
+	// services, so each combination has its own endpoints.
+	failover := make([]string, 0, len(c.Failover))
+	for _, s := range c.Failover {
+		failover = append(failover, s.Weighted.ClusterLoadAssignmentName())
+	}
+
+	return name + "/failover=" + strings.Join(failover, ",")
+}
+
+// TCPKeepalive defines the TCP keepalive probes sent on upstream
+// connections. Zero values use the operating system default.
+type TCPKeepalive struct {
+	// Probes is the number of unanswered probes sent before the
+	// connection is considered dead.
+	Probes uint32
+
+	// Time is the number of seconds a connection must be idle
+	// before probes are sent.
+	Time uint32
+
+	// Interval is the number of seconds between probes.
+	Interval uint32
+}
+
+// HTTP2Settings defines the HTTP/2 protocol settings for
+// connections to an upstream cluster. Zero values are unset.
+type HTTP2Settings struct {
+	MaxConcurrentStreams        uint32
+	InitialStreamWindowSize     uint32
+	InitialConnectionWindowSize uint32
+	KeepaliveInterval           time.Duration
+	KeepaliveTimeout            time.Duration
+}
+
+// SPIFFEIdentity names the SDS secrets that Envoy fetches
+// from a SPIFFE Workload API for upstream TLS.
+type SPIFFEIdentity struct {
+	// ID is the SPIFFE ID of the X.509 SVID presented as
+	// the client certificate. If empty, ClientCertificate
+	// is presented instead.
+	ID string
+
+	// TrustDomain is the SPIFFE ID of the trust domain whose
+	// bundle validates the upstream cluster when it has no
+	// UpstreamValidation. If empty, such clusters are not
+	// validated.
+	TrustDomain string
 }
 
 // WeightedService represents the load balancing weight of a
@@ -718,6 +1229,41 @@ type WeightedService struct {
 	ServiceNamespace string
 	// ServicePort is the port to which we forward traffic.
 	ServicePort v1.ServicePort
+	// ServiceImport is true if ServiceName names a multi-cluster
+	// ServiceImport rather than a v1.Service.
+	ServiceImport bool
+	// PodSelector, if set, restricts the endpoints of a headless
+	// v1.Service to those whose pods have all of these labels.
+	PodSelector map[string]string
+	// Priority is the Envoy priority of the endpoints of this
+	// service. Endpoints only receive traffic when the endpoints
+	// of all lower priorities are unhealthy.
+	Priority uint32
+	// HealthCheckPort, if not zero, is the port of the endpoints
+	// of this service that health checks are sent to.
+	HealthCheckPort int32
+}
+
+// ClusterLoadAssignmentName returns the name of the EDS
+// ClusterLoadAssignment that holds the endpoints of this service.
+func (w *WeightedService) ClusterLoadAssignmentName() string {
+	name := xds.ClusterLoadAssignmentName(
+		types.NamespacedName{Name: w.ServiceName, Namespace: w.ServiceNamespace},
+		w.ServicePort.Name,
+	)
+
+	// A ServiceImport may have the same name as a local Service,
+	// so its endpoints are published under a distinct name.
+	if w.ServiceImport {
+		return "serviceimport/" + name
+	}
+
+	// Each subset of a headless Service has its own endpoints.
+	if len(w.PodSelector) > 0 {
+		return name + "/" + labels.Set(w.PodSelector).String()
+	}
+
+	return name
 }
 
 // ServiceCluster capture the set of Kubernetes Services that will
@@ -743,6 +1289,12 @@ func (s *ServiceCluster) DeepCopy() *ServiceCluster {
 	for i, w := range s.Services {
 		s2.Services[i] = w
 		w.ServicePort.DeepCopyInto(&s2.Services[i].ServicePort)
+		if w.PodSelector != nil {
+			s2.Services[i].PodSelector = make(map[string]string, len(w.PodSelector))
+			for k, v := range w.PodSelector {
+				s2.Services[i].PodSelector[k] = v
+			}
+		}
 	}
 
 	return &s2
@@ -843,6 +1395,35 @@ type HTTPHealthCheckPolicy struct {
 	Timeout            time.Duration
 	UnhealthyThreshold uint32
 	HealthyThreshold   uint32
+
+	// GRPC, if not nil, checks health with the gRPC
+	// health checking protocol rather than Path.
+	GRPC *GRPCHealthCheckPolicy
+
+	// ExpectedStatuses are the half-open ranges of
+	// status codes of healthy hosts. If empty, only
+	// 200 is expected.
+	ExpectedStatuses []HTTPStatusRange
+
+	// Port, if not zero, is the endpoint port that
+	// health checks are sent to.
+	Port int32
+
+	// RequestHeaders are added to health check requests.
+	RequestHeaders map[string]string
+}
+
+// HTTPStatusRange is a range of HTTP status codes,
+// from Start inclusive to End exclusive.
+type HTTPStatusRange struct {
+	Start int64
+	End   int64
+}
+
+// GRPCHealthCheckPolicy grpc health check policy
+type GRPCHealthCheckPolicy struct {
+	ServiceName string
+	Authority   string
 }
 
 // TCPHealthCheckPolicy tcp health check policy
@@ -851,8 +1432,24 @@ type TCPHealthCheckPolicy struct {
 	Timeout            time.Duration
 	UnhealthyThreshold uint32
 	HealthyThreshold   uint32
+
+	// Send is the hex encoded payload to send, if any.
+	Send string
+
+	// Receive are the hex encoded payloads expected in response.
+	Receive []string
 }
 
+// DynamicForwardProxyCluster generates an Envoy dynamic forward proxy
+// cluster, which resolves and connects to the upstream named by the
+// request's Host header.
+type DynamicForwardProxyCluster struct {
+	// DNSLookupFamily defines how the upstream host is resolved.
+	DNSLookupFamily string
+}
+
+func (c *DynamicForwardProxyCluster) Visit(func(Vertex)) {}
+
 // ExtensionCluster generates an Envoy cluster (aka ClusterLoadAssignment)
 // for an ExtensionService resource.
 type ExtensionCluster struct {
@@ -15,6 +15,7 @@ package dag
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -107,6 +108,21 @@ func TestObserverFunc(t *testing.T) {
 	assert.Equal(t, true, result)
 }
 
+func TestDAGExpireAt(t *testing.T) {
+	now := time.Now()
+
+	var d DAG
+	assert.True(t, d.Expiry.IsZero())
+
+	d.ExpireAt(now.Add(time.Hour))
+	assert.Equal(t, now.Add(time.Hour), d.Expiry)
+
+	// Only the earliest expiry is kept.
+	d.ExpireAt(now.Add(time.Minute))
+	d.ExpireAt(now.Add(2 * time.Hour))
+	assert.Equal(t, now.Add(time.Minute), d.Expiry)
+}
+
 func TestServiceClusterValid(t *testing.T) {
 	invalid := []ServiceCluster{
 		{},
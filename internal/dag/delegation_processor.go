@@ -0,0 +1,146 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"sort"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/status"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TLSCertificateDelegationProcessor reports the status of
+// TLSCertificateDelegation objects.
+type TLSCertificateDelegationProcessor struct {
+	logrus.FieldLogger
+}
+
+var _ Processor = &TLSCertificateDelegationProcessor{}
+
+// secretReference is a reference to a secret by an HTTPProxy or Ingress.
+type secretReference struct {
+	secret   types.NamespacedName
+	consumer contour_api_v1.DelegationConsumer
+}
+
+func (p *TLSCertificateDelegationProcessor) Run(dag *DAG, cache *KubernetesCache) {
+	refs := crossNamespaceSecretReferences(cache)
+
+	for _, d := range cache.tlscertificatedelegations {
+		entry, commit := status.DelegationAccessor(&dag.StatusCache, d)
+		validCondition := entry.ConditionFor(status.ValidCondition)
+
+		for _, cd := range d.Spec.Delegations {
+			secretName := types.NamespacedName{Namespace: d.Namespace, Name: cd.SecretName}
+
+			// Secrets that are not valid TLS or CA certificates
+			// are not added to the cache.
+			if _, ok := cache.secrets[secretName]; !ok {
+				validCondition.AddErrorf(contour_api_v1.ConditionTypeTLSError, "SecretNotFound",
+					"delegated Secret %q not found", secretName)
+			}
+
+			if len(cd.TargetNamespaces) == 0 {
+				validCondition.AddWarningf(contour_api_v1.ConditionTypeSpecError, "NoTargetNamespaces",
+					"delegation of Secret %q has no target namespaces and is ignored", secretName)
+			}
+
+			ds := contour_api_v1.CertificateDelegationStatus{
+				SecretName: cd.SecretName,
+			}
+			for _, ref := range refs {
+				if ref.secret != secretName || !delegatesTo(cd, ref.secret.Name, ref.consumer.Namespace) {
+					continue
+				}
+				// An HTTPProxy may reference the same secret
+				// more than once.
+				if n := len(ds.Consumers); n > 0 && ds.Consumers[n-1] == ref.consumer {
+					continue
+				}
+				ds.Consumers = append(ds.Consumers, ref.consumer)
+			}
+			ds.InUse = len(ds.Consumers) > 0
+			entry.Delegations = append(entry.Delegations, ds)
+		}
+
+		if len(validCondition.Errors) == 0 {
+			validCondition.Status = contour_api_v1.ConditionTrue
+			validCondition.Reason = "Valid"
+			validCondition.Message = "Valid TLSCertificateDelegation"
+		}
+
+		commit()
+	}
+}
+
+// crossNamespaceSecretReferences returns the references of HTTPProxies
+// and Ingresses to secrets in other namespaces, which require a
+// delegation. The references are sorted by consumer.
+func crossNamespaceSecretReferences(cache *KubernetesCache) []secretReference {
+	var refs []secretReference
+
+	add := func(kind, namespace, name, secret string) {
+		if secret == "" {
+			return
+		}
+		secretName := k8s.NamespacedNameFrom(secret, k8s.DefaultNamespace(namespace))
+		if secretName.Namespace == namespace {
+			return
+		}
+		refs = append(refs, secretReference{
+			secret: secretName,
+			consumer: contour_api_v1.DelegationConsumer{
+				Kind:      kind,
+				Namespace: namespace,
+				Name:      name,
+			},
+		})
+	}
+
+	for _, proxy := range cache.httpproxies {
+		if proxy.Spec.VirtualHost == nil || proxy.Spec.VirtualHost.TLS == nil {
+			continue
+		}
+		tls := proxy.Spec.VirtualHost.TLS
+		add("HTTPProxy", proxy.Namespace, proxy.Name, tls.SecretName)
+		if tls.ClientValidation != nil {
+			add("HTTPProxy", proxy.Namespace, proxy.Name, tls.ClientValidation.CACertificate)
+		}
+	}
+
+	for _, ing := range cache.ingresses {
+		if !cache.admitsIngress(ing) {
+			continue
+		}
+		for _, tls := range ing.Spec.TLS {
+			add("Ingress", ing.Namespace, ing.Name, tls.SecretName)
+		}
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		a, b := refs[i].consumer, refs[j].consumer
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	return refs
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/projectcontour/contour/internal/errors"
 	"github.com/projectcontour/contour/internal/k8s"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
 	"github.com/projectcontour/contour/internal/status"
 
 	"github.com/sirupsen/logrus"
@@ -38,6 +39,7 @@ import (
 const (
 	KindHTTPRoute = "HTTPRoute"
 	KindTLSRoute  = "TLSRoute"
+	KindTCPRoute  = "TCPRoute"
 )
 
 // GatewayAPIProcessor translates Gateway API types into DAG
@@ -52,6 +54,11 @@ type GatewayAPIProcessor struct {
 	// This is normally disabled for security reasons.
 	// See https://github.com/projectcontour/contour/security/advisories/GHSA-5ph6-qq5x-7jwc for details.
 	EnableExternalNameService bool
+
+	// TCPListeners maps the port of each configured plain TCP
+	// listener to its Envoy listener name. Gateway listeners of
+	// protocol TCP must use one of these ports.
+	TCPListeners map[int]string
 }
 
 // matchConditions holds match rules.
@@ -93,7 +100,9 @@ func (p *GatewayAPIProcessor) Run(dag *DAG, source *KubernetesCache) {
 
 		var matchingHTTPRoutes []*gatewayapi_v1alpha1.HTTPRoute
 		var matchingTLSRoutes []*gatewayapi_v1alpha1.TLSRoute
+		var matchingTCPRoutes []*gatewayapi_v1alpha1.TCPRoute
 		var listenerSecret *Secret
+		var tcpListenerName string
 
 		// Validate the Protocol on the selector is a supported type.
 		switch listener.Protocol {
@@ -136,6 +145,14 @@ func (p *GatewayAPIProcessor) Run(dag *DAG, source *KubernetesCache) {
 			}
 		case gatewayapi_v1alpha1.HTTPProtocolType:
 			break
+		case gatewayapi_v1alpha1.TCPProtocolType:
+			// TCP listeners are bound to one of the configured plain TCP listeners.
+			name, ok := p.TCPListeners[int(listener.Port)]
+			if !ok {
+				p.Errorf("Listener.Port %d is not a configured TCP listener port.", listener.Port)
+				continue
+			}
+			tcpListenerName = name
 		default:
 			p.Errorf("Listener.Protocol %q is not supported.", listener.Protocol)
 			continue
@@ -150,7 +167,7 @@ func (p *GatewayAPIProcessor) Run(dag *DAG, source *KubernetesCache) {
 		}
 
 		// Validate the Kind on the selector is a supported type.
-		if listener.Routes.Kind != KindHTTPRoute && listener.Routes.Kind != KindTLSRoute {
+		if listener.Routes.Kind != KindHTTPRoute && listener.Routes.Kind != KindTLSRoute && listener.Routes.Kind != KindTCPRoute {
 			p.Errorf("Listener.Routes.Kind %q is not supported.", listener.Routes.Kind)
 			continue
 		}
@@ -242,6 +259,44 @@ func (p *GatewayAPIProcessor) Run(dag *DAG, source *KubernetesCache) {
 					matchingTLSRoutes = append(matchingTLSRoutes, route)
 				}
 			}
+		case KindTCPRoute:
+
+			// Validate the listener protocol is type=TCP.
+			if listener.Protocol != gatewayapi_v1alpha1.TCPProtocolType {
+				p.Errorf("invalid listener protocol %q for Kind: TCPRoute", listener.Protocol)
+				continue
+			}
+
+			for _, route := range p.source.tcproutes {
+				// Filter the TCPRoutes that match the gateway which Contour is configured to watch.
+				// If Namespaces and Selector are defined, only routes matching both selectors are associated with the Gateway.
+
+				nsMatches, err := p.namespaceMatches(listener.Routes.Namespaces, route.Namespace)
+				if err != nil {
+					p.Errorf("error validating namespaces against Listener.Routes.Namespaces: %s", err)
+				}
+
+				selMatches, err := selectorMatches(listener.Routes.Selector, route.Labels)
+				if err != nil {
+					p.Errorf("error validating routes against Listener.Routes.Selector: %s", err)
+				}
+
+				if selMatches && nsMatches {
+
+					if !p.gatewayMatches(route.Spec.Gateways, route.Namespace) {
+
+						// If a label selector or namespace selector matches, but the gateway Allow doesn't
+						// then set the "Admitted: false" for the route.
+						routeAccessor, commit := p.dag.StatusCache.RouteConditionsAccessor(k8s.NamespacedNameOf(route), route.Generation, status.ResourceTCPRoute, route.Status.Gateways)
+						routeAccessor.AddCondition(gatewayapi_v1alpha1.ConditionRouteAdmitted, metav1.ConditionFalse, status.ReasonGatewayAllowMismatch, "Gateway RouteSelector matches, but GatewayAllow has mismatch.")
+						commit()
+						continue
+					}
+
+					// Empty Selector matches all routes.
+					matchingTCPRoutes = append(matchingTCPRoutes, route)
+				}
+			}
 		}
 
 		validGateway := len(gatewayErrors) == 0
@@ -255,6 +310,11 @@ func (p *GatewayAPIProcessor) Run(dag *DAG, source *KubernetesCache) {
 		for _, matchingRoute := range matchingTLSRoutes {
 			p.computeTLSRoute(matchingRoute, validGateway, listenerSecret)
 		}
+
+		// Process all the TCPRoutes that match this Gateway.
+		for _, matchingRoute := range matchingTCPRoutes {
+			p.computeTCPRoute(matchingRoute, validGateway, tcpListenerName, int(listener.Port))
+		}
 	}
 
 	p.computeGateway(p.source.gateway, gatewayErrors)
@@ -539,7 +599,7 @@ func (p *GatewayAPIProcessor) computeTLSRoute(route *gatewayapi_v1alpha1.TLSRout
 		var proxy TCPProxy
 		for _, forward := range rule.ForwardTo {
 
-			service, err := p.validateForwardTo(forward.ServiceName, forward.Port, route.Namespace)
+			service, err := p.validateForwardTo(forward.ServiceName, forward.BackendRef, forward.Port, route.Namespace)
 			if err != nil {
 				routeAccessor.AddCondition(status.ConditionResolvedRefs, metav1.ConditionFalse, status.ReasonDegraded, err.Error())
 				continue
@@ -578,6 +638,70 @@ func (p *GatewayAPIProcessor) computeTLSRoute(route *gatewayapi_v1alpha1.TLSRout
 	}
 }
 
+// computeTCPRoute forwards all connections accepted by the TCP listener
+// to the services of the supplied TCPRoute. Routes bound to the same
+// listener share a single TCP proxy.
+func (p *GatewayAPIProcessor) computeTCPRoute(route *gatewayapi_v1alpha1.TCPRoute, validGateway bool, listenerName string, port int) {
+
+	routeAccessor, commit := p.dag.StatusCache.RouteConditionsAccessor(k8s.NamespacedNameOf(route), route.Generation, status.ResourceTCPRoute, route.Status.Gateways)
+	defer commit()
+
+	// If the Gateway is invalid, set status on the route.
+	if !validGateway {
+		routeAccessor.AddCondition(gatewayapi_v1alpha1.ConditionRouteAdmitted, metav1.ConditionFalse, status.ReasonInvalidGateway, "Invalid Gateway")
+		return
+	}
+
+	for _, rule := range route.Spec.Rules {
+		for _, match := range rule.Matches {
+			if match.ExtensionRef != nil {
+				routeAccessor.AddCondition(status.ConditionNotImplemented, metav1.ConditionTrue, status.ReasonNotImplemented, "TCPRoute.Spec.Rules.Matches.ExtensionRef: Not yet implemented.")
+			}
+		}
+
+		if len(rule.ForwardTo) == 0 {
+			routeAccessor.AddCondition(status.ConditionResolvedRefs, metav1.ConditionFalse, status.ReasonDegraded, "At least one Spec.Rules.ForwardTo must be specified.")
+			continue
+		}
+
+		var clusters []*Cluster
+		for _, forward := range rule.ForwardTo {
+
+			service, err := p.validateForwardTo(forward.ServiceName, forward.BackendRef, forward.Port, route.Namespace)
+			if err != nil {
+				routeAccessor.AddCondition(status.ConditionResolvedRefs, metav1.ConditionFalse, status.ReasonDegraded, err.Error())
+				continue
+			}
+
+			clusters = append(clusters, &Cluster{
+				Upstream: service,
+				Weight:   uint32(pointer.Int32Deref(forward.Weight, 0)),
+				SNI:      service.ExternalName,
+			})
+		}
+
+		if len(clusters) == 0 {
+			// No valid clusters so the rule should get rejected.
+			continue
+		}
+
+		vhost := p.dag.EnsureTCPVirtualHost(ListenerName{Name: listenerName, ListenerName: listenerName}, port)
+		if vhost.TCPProxy == nil {
+			vhost.TCPProxy = &TCPProxy{}
+		}
+		vhost.TCPProxy.Clusters = append(vhost.TCPProxy.Clusters, clusters...)
+	}
+
+	// Determine if any errors exist in conditions and set the "Admitted"
+	// condition accordingly.
+	switch len(routeAccessor.Conditions) {
+	case 0:
+		routeAccessor.AddCondition(gatewayapi_v1alpha1.ConditionRouteAdmitted, metav1.ConditionTrue, status.ReasonValid, "Valid TCPRoute")
+	default:
+		routeAccessor.AddCondition(gatewayapi_v1alpha1.ConditionRouteAdmitted, metav1.ConditionFalse, status.ReasonErrorsExist, "Errors found, check other Conditions for details.")
+	}
+}
+
 func (p *GatewayAPIProcessor) computeHTTPRoute(route *gatewayapi_v1alpha1.HTTPRoute, listenerSecret *Secret, listenerHostname *gatewayapi_v1alpha1.Hostname, validGateway bool) {
 	routeAccessor, commit := p.dag.StatusCache.RouteConditionsAccessor(k8s.NamespacedNameOf(route), route.Generation, status.ResourceHTTPRoute, route.Status.Gateways)
 	defer commit()
@@ -631,7 +755,7 @@ func (p *GatewayAPIProcessor) computeHTTPRoute(route *gatewayapi_v1alpha1.HTTPRo
 		totalWeight := uint32(0)
 		for _, forward := range rule.ForwardTo {
 
-			service, err := p.validateForwardTo(forward.ServiceName, forward.Port, route.Namespace)
+			service, err := p.validateForwardTo(forward.ServiceName, forward.BackendRef, forward.Port, route.Namespace)
 			if err != nil {
 				routeAccessor.AddCondition(status.ConditionResolvedRefs, metav1.ConditionFalse, status.ReasonDegraded, err.Error())
 				continue
@@ -667,6 +791,7 @@ func (p *GatewayAPIProcessor) computeHTTPRoute(route *gatewayapi_v1alpha1.HTTPRo
 		}
 
 		var headerPolicy *HeadersPolicy
+		var mirrorPolicy *MirrorPolicy
 		for _, filter := range rule.Filters {
 			switch filter.Type {
 			case gatewayapi_v1alpha1.HTTPRouteFilterRequestHeaderModifier:
@@ -675,12 +800,22 @@ func (p *GatewayAPIProcessor) computeHTTPRoute(route *gatewayapi_v1alpha1.HTTPRo
 				if err != nil {
 					routeAccessor.AddCondition(status.ConditionResolvedRefs, metav1.ConditionFalse, status.ReasonDegraded, fmt.Sprintf("%s on request headers", err))
 				}
+			case gatewayapi_v1alpha1.HTTPRouteFilterRequestMirror:
+				if mirrorPolicy != nil {
+					routeAccessor.AddCondition(status.ConditionResolvedRefs, metav1.ConditionFalse, status.ReasonDegraded, "HTTPRoute.Spec.Rules.Filters: Only one RequestMirror filter is supported.")
+					continue
+				}
+				var err error
+				mirrorPolicy, err = p.mirrorPolicy(filter.RequestMirror, route.Namespace)
+				if err != nil {
+					routeAccessor.AddCondition(status.ConditionResolvedRefs, metav1.ConditionFalse, status.ReasonDegraded, err.Error())
+				}
 			default:
-				routeAccessor.AddCondition(status.ConditionNotImplemented, metav1.ConditionTrue, status.ReasonHTTPRouteFilterType, "HTTPRoute.Spec.Rules.Filters: Only RequestHeaderModifier type is supported.")
+				routeAccessor.AddCondition(status.ConditionNotImplemented, metav1.ConditionTrue, status.ReasonHTTPRouteFilterType, "HTTPRoute.Spec.Rules.Filters: Only RequestHeaderModifier and RequestMirror types are supported.")
 			}
 		}
 
-		routes := p.routes(matchconditions, headerPolicy, clusters)
+		routes := p.routes(matchconditions, headerPolicy, mirrorPolicy, clusters)
 		for host := range hosts {
 			for _, route := range routes {
 				// If there aren't any valid services, or the total weight of all of
@@ -734,7 +869,12 @@ func (p *GatewayAPIProcessor) computeHTTPRoute(route *gatewayapi_v1alpha1.HTTPRo
 
 // validateForwardTo verifies that the specified forwardTo is valid.
 // Returns an error if not or the service found in the cache.
-func (p *GatewayAPIProcessor) validateForwardTo(serviceName *string, port *gatewayapi_v1alpha1.PortNumber, namespace string) (*Service, error) {
+func (p *GatewayAPIProcessor) validateForwardTo(serviceName *string, backendRef *gatewayapi_v1alpha1.LocalObjectReference, port *gatewayapi_v1alpha1.PortNumber, namespace string) (*Service, error) {
+	// A multi-cluster ServiceImport is referenced with a BackendRef.
+	if serviceName == nil && isServiceImportRef(backendRef) {
+		return p.validateServiceImportRef(backendRef.Name, port, namespace)
+	}
+
 	// Verify the service is valid
 	if serviceName == nil {
 		return nil, fmt.Errorf("Spec.Rules.ForwardTo.ServiceName must be specified")
@@ -756,6 +896,31 @@ func (p *GatewayAPIProcessor) validateForwardTo(serviceName *string, port *gatew
 	return service, nil
 }
 
+// isServiceImportRef returns true if the supplied BackendRef refers
+// to a multi-cluster ServiceImport.
+func isServiceImportRef(ref *gatewayapi_v1alpha1.LocalObjectReference) bool {
+	return ref != nil &&
+		ref.Group == mcs_v1alpha1.GroupVersion.Group &&
+		ref.Kind == mcs_v1alpha1.ServiceImportKind
+}
+
+// validateServiceImportRef verifies that the specified ServiceImport is valid.
+// Returns an error if not or the service found in the cache.
+func (p *GatewayAPIProcessor) validateServiceImportRef(name string, port *gatewayapi_v1alpha1.PortNumber, namespace string) (*Service, error) {
+	if port == nil {
+		return nil, fmt.Errorf("Spec.Rules.ForwardTo.ServicePort must be specified")
+	}
+
+	meta := types.NamespacedName{Name: name, Namespace: namespace}
+
+	service, err := p.dag.EnsureServiceImport(meta, intstr.FromInt(int(*port)), p.source)
+	if err != nil {
+		return nil, fmt.Errorf("service import %q is invalid: %s", meta.Name, err)
+	}
+
+	return service, nil
+}
+
 func pathMatchCondition(mc *matchConditions, match *gatewayapi_v1alpha1.HTTPPathMatch) error {
 
 	if match == nil {
@@ -804,8 +969,33 @@ func headerMatchCondition(mc *matchConditions, match *gatewayapi_v1alpha1.HTTPHe
 	return nil
 }
 
-// routes builds a []*dag.Route for the supplied set of matchConditions, headerPolicy and clusters.
-func (p *GatewayAPIProcessor) routes(matchConditions []*matchConditions, headerPolicy *HeadersPolicy, clusters []*Cluster) []*Route {
+// mirrorPolicy builds a *dag.MirrorPolicy for the supplied RequestMirror filter.
+// Returns an error if the filter does not reference a valid service.
+func (p *GatewayAPIProcessor) mirrorPolicy(filter *gatewayapi_v1alpha1.HTTPRequestMirrorFilter, namespace string) (*MirrorPolicy, error) {
+	if filter == nil || filter.ServiceName == nil {
+		return nil, fmt.Errorf("Spec.Rules.Filters.RequestMirror.ServiceName must be specified")
+	}
+
+	if filter.Port == nil {
+		return nil, fmt.Errorf("Spec.Rules.Filters.RequestMirror.Port must be specified")
+	}
+
+	meta := types.NamespacedName{Name: *filter.ServiceName, Namespace: namespace}
+	service, err := p.dag.EnsureService(meta, intstr.FromInt(int(*filter.Port)), p.source, p.EnableExternalNameService)
+	if err != nil {
+		return nil, fmt.Errorf("mirror service %q is invalid: %s", meta.Name, err)
+	}
+
+	return &MirrorPolicy{
+		Cluster: &Cluster{
+			Upstream: service,
+			Protocol: service.Protocol,
+		},
+	}, nil
+}
+
+// routes builds a []*dag.Route for the supplied set of matchConditions, headerPolicy, mirrorPolicy and clusters.
+func (p *GatewayAPIProcessor) routes(matchConditions []*matchConditions, headerPolicy *HeadersPolicy, mirrorPolicy *MirrorPolicy, clusters []*Cluster) []*Route {
 	var routes []*Route
 
 	for _, mc := range matchConditions {
@@ -816,6 +1006,7 @@ func (p *GatewayAPIProcessor) routes(matchConditions []*matchConditions, headerP
 			r.PathMatchCondition = pathMatch
 			r.HeaderMatchConditions = mc.headerMatchCondition
 			r.RequestHeadersPolicy = headerPolicy
+			r.MirrorPolicy = mirrorPolicy
 			routes = append(routes, r)
 		}
 	}
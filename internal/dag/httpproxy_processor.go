@@ -14,10 +14,17 @@
 package dag
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
@@ -27,6 +34,7 @@ import (
 	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/projectcontour/contour/pkg/config"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -41,6 +49,13 @@ func defaultExtensionRef(ref contour_api_v1.ExtensionServiceReference) contour_a
 	return ref
 }
 
+// WasmImageFetcher looks up the code of Wasm modules in OCI images.
+type WasmImageFetcher interface {
+	// Fetch returns the code of the module in the named image,
+	// or an error if it is not available (yet).
+	Fetch(image string) ([]byte, error)
+}
+
 // HTTPProxyProcessor translates HTTPProxies into DAG
 // objects and adds them to the DAG.
 type HTTPProxyProcessor struct {
@@ -48,20 +63,76 @@ type HTTPProxyProcessor struct {
 	source   *KubernetesCache
 	orphaned map[types.NamespacedName]bool
 
+	// included holds the HTTPProxies included by the root
+	// HTTPProxy being computed.
+	included map[types.NamespacedName]bool
+
+	// extensionClusters holds the extension clusters
+	// of the DAG, indexed by name.
+	extensionClusters map[string]*ExtensionCluster
+
+	// overQuota holds the HTTPProxies excluded
+	// for exceeding their namespace's quota.
+	overQuota map[types.NamespacedName]bool
+
 	// DisablePermitInsecure disables the use of the
 	// permitInsecure field in HTTPProxy.
 	DisablePermitInsecure bool
 
+	// PermitInsecureNamespaces and PermitInsecureSelector restrict
+	// the use of the permitInsecure field to the listed namespaces
+	// and the namespaces whose labels match the selector. Routes in
+	// other namespaces that use it are not valid. If neither is set,
+	// routes in any namespace may use the field.
+	PermitInsecureNamespaces []string
+	PermitInsecureSelector   labels.Selector
+
+	// LuaNamespaces are the namespaces whose HTTPProxies may run
+	// Lua scripts. HTTPProxies in other namespaces that set one
+	// are not valid.
+	LuaNamespaces []string
+
+	// TapNamespaces are the namespaces whose HTTPProxies may
+	// capture requests with tap policies. HTTPProxies in other
+	// namespaces that set one are not valid.
+	TapNamespaces []string
+
+	// TapFileDirectory is the directory of the Envoy pod that tap
+	// policies write captured requests to. Defaults to /tmp.
+	TapFileDirectory string
+
+	// WasmImageFetcher pulls the code of the WasmModules that are
+	// published as OCI images. If nil, such modules can't be loaded.
+	WasmImageFetcher WasmImageFetcher
+
 	// FallbackCertificate is the optional identifier of the
 	// TLS secret to use by default when SNI is not set on a
 	// request.
 	FallbackCertificate *types.NamespacedName
 
+	// DefaultHTTPProxy is the optional identifier of the root
+	// HTTPProxy whose insecure virtual host also serves the
+	// requests whose Host matches no other virtual host.
+	DefaultHTTPProxy *types.NamespacedName
+
 	// EnableExternalNameService allows processing of ExternalNameServices
 	// This is normally disabled for security reasons.
 	// See https://github.com/projectcontour/contour/security/advisories/GHSA-5ph6-qq5x-7jwc for details.
 	EnableExternalNameService bool
 
+	// EnableDynamicForwardProxy allows routes to proxy requests to
+	// the host named by their Host header. This is normally disabled
+	// since such routes can reach any host that Envoy can resolve.
+	EnableDynamicForwardProxy bool
+
+	// EnableGeoIPCountry and EnableGeoIPASN allow match conditions
+	// on the country and autonomous system of the client address,
+	// which Contour looks up if it has a GeoIP database of them.
+	// Routes with geo conditions that can't be looked up are not
+	// valid.
+	EnableGeoIPCountry bool
+	EnableGeoIPASN     bool
+
 	// DNSLookupFamily defines how external names are looked up
 	// When configured as V4, the DNS resolver will only perform a lookup
 	// for addresses in the IPv4 family. If V6 is configured, the DNS resolver
@@ -76,11 +147,53 @@ type HTTPProxyProcessor struct {
 	// private key to be used when establishing TLS connection to upstream cluster.
 	ClientCertificate *types.NamespacedName
 
+	// SPIFFE is the optional SPIFFE identity used in place of ClientCertificate
+	// when establishing TLS connection to upstream cluster.
+	SPIFFE *SPIFFEIdentity
+
 	// Request headers that will be set on all routes (optional).
 	RequestHeadersPolicy *HeadersPolicy
 
 	// Response headers that will be set on all routes (optional).
 	ResponseHeadersPolicy *HeadersPolicy
+
+	// DefaultRetryPolicy and DefaultTimeoutPolicy are the retry
+	// and timeout policies of the routes that do not set their own.
+	DefaultRetryPolicy   *contour_api_v1.RetryPolicy
+	DefaultTimeoutPolicy *contour_api_v1.TimeoutPolicy
+
+	// TCPListeners maps the port of each configured plain TCP
+	// listener to the listener's name.
+	TCPListeners map[int]string
+
+	// HTTPListeners and HTTPSListeners hold the names of the
+	// additional HTTP and HTTPS listeners that virtual hosts
+	// may select.
+	HTTPListeners  map[string]bool
+	HTTPSListeners map[string]bool
+
+	// Workers is the number of root HTTPProxies to process
+	// concurrently. If less than 2, root HTTPProxies are
+	// processed one at a time.
+	Workers int
+
+	// MaxIncludeDepth is the maximum number of HTTPProxies that
+	// may be followed through includes from a root HTTPProxy.
+	// If zero, the include depth is not limited.
+	MaxIncludeDepth int
+
+	// PartialValidity keeps the valid routes of an HTTPProxy with
+	// invalid routes or includes, instead of dropping all of them.
+	// The HTTPProxy's Valid condition still lists every error.
+	PartialValidity bool
+
+	// DefaultNamespaceQuota limits the routing configuration
+	// of namespaces not listed in NamespaceQuotas.
+	DefaultNamespaceQuota NamespaceQuota
+
+	// NamespaceQuotas limits the routing configuration
+	// of each namespace listed.
+	NamespaceQuotas map[string]NamespaceQuota
 }
 
 // Run translates HTTPProxies into DAG objects and
@@ -89,16 +202,27 @@ func (p *HTTPProxyProcessor) Run(dag *DAG, source *KubernetesCache) {
 	p.dag = dag
 	p.source = source
 	p.orphaned = make(map[types.NamespacedName]bool, len(p.orphaned))
+	p.overQuota = make(map[types.NamespacedName]bool, len(p.overQuota))
+	p.extensionClusters = dag.GetExtensionClusters()
 
 	// reset the processor when we're done
 	defer func() {
 		p.dag = nil
 		p.source = nil
 		p.orphaned = nil
+		p.overQuota = nil
+		p.extensionClusters = nil
 	}()
 
-	for _, proxy := range p.validHTTPProxies() {
-		p.computeHTTPProxy(proxy)
+	p.computeHTTPProxies(p.validHTTPProxies())
+
+	// The HTTPProxies included by the roots of other shards
+	// are part of a delegation chain, so are not orphaned.
+	claimed := map[types.NamespacedName]bool{}
+	for _, proxy := range p.source.httpproxies {
+		if proxy.Spec.VirtualHost != nil && !p.source.Shard.Matches(proxy) {
+			p.claimIncludes(proxy, claimed)
+		}
 	}
 
 	for meta := range p.orphaned {
@@ -113,6 +237,111 @@ func (p *HTTPProxyProcessor) Run(dag *DAG, source *KubernetesCache) {
 	}
 }
 
+// computeHTTPProxies computes each of proxies. Each root HTTPProxy
+// is computed into a DAG and status cache of its own, by up to
+// p.Workers workers at a time, and the results are merged in the
+// order of the roots, so they don't depend on the number of workers.
+func (p *HTTPProxyProcessor) computeHTTPProxies(proxies []*contour_api_v1.HTTPProxy) {
+	var roots []*contour_api_v1.HTTPProxy
+	for _, proxy := range proxies {
+		// Mark every non-root HTTPProxy as orphaned before
+		// any root HTTPProxy can include it.
+		if proxy.Spec.VirtualHost == nil {
+			p.computeHTTPProxy(proxy)
+			continue
+		}
+		roots = append(roots, proxy)
+	}
+
+	results := make([]*HTTPProxyProcessor, len(roots))
+
+	if p.Workers < 2 {
+		for i, proxy := range roots {
+			results[i] = p.computeRoot(proxy)
+		}
+	} else {
+		work := make(chan int)
+
+		var wg sync.WaitGroup
+		for i := 0; i < p.Workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range work {
+					results[i] = p.computeRoot(roots[i])
+				}
+			}()
+		}
+
+		for i := range roots {
+			work <- i
+		}
+		close(work)
+		wg.Wait()
+	}
+
+	for _, result := range results {
+		p.merge(result)
+	}
+}
+
+// computeRoot computes the root HTTPProxy proxy with a copy of p
+// whose DAG and status cache hold only what proxy adds, and returns
+// the copy. The DAG of p is not changed, so roots can be computed
+// concurrently.
+func (p *HTTPProxyProcessor) computeRoot(proxy *contour_api_v1.HTTPProxy) *HTTPProxyProcessor {
+	result := *p
+	result.dag = &DAG{
+		StatusCache: status.NewCache(types.NamespacedName{}),
+	}
+	result.orphaned = nil
+	result.included = map[types.NamespacedName]bool{}
+
+	result.computeHTTPProxy(proxy)
+	return &result
+}
+
+// merge adds the virtual hosts, status updates and includes of a
+// root HTTPProxy computed by computeRoot to the DAG of p.
+func (p *HTTPProxyProcessor) merge(result *HTTPProxyProcessor) {
+	for _, root := range result.dag.roots {
+		switch root := root.(type) {
+		case *VirtualHost:
+			ln := ListenerName{Name: root.Name, ListenerName: root.ListenerName}
+			if vh := p.dag.GetVirtualHost(ln); vh != nil {
+				copyVirtualHost(vh, root)
+				continue
+			}
+		case *SecureVirtualHost:
+			ln := ListenerName{Name: root.Name, ListenerName: root.ListenerName}
+			if secure := p.dag.GetSecureVirtualHost(ln); secure != nil {
+				vh := secure.VirtualHost
+				*secure = *root
+				secure.VirtualHost = vh
+				copyVirtualHost(&secure.VirtualHost, &root.VirtualHost)
+				continue
+			}
+		case *TCPVirtualHost:
+			ln := ListenerName{Name: root.Name, ListenerName: root.ListenerName}
+			if vh := p.dag.GetTCPVirtualHost(ln); vh != nil {
+				vh.TCPProxy = root.TCPProxy
+				continue
+			}
+		}
+		p.dag.AddRoot(root)
+	}
+
+	if !result.dag.Expiry.IsZero() {
+		p.dag.ExpireAt(result.dag.Expiry)
+	}
+
+	p.dag.StatusCache.MergeProxies(&result.dag.StatusCache)
+
+	for name := range result.included {
+		delete(p.orphaned, name)
+	}
+}
+
 func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 	pa, commit := p.dag.StatusCache.ProxyAccessor(proxy)
 	validCond := pa.ConditionFor(status.ValidCondition)
@@ -147,6 +376,40 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 		return
 	}
 
+	seen := map[string]bool{strings.ToLower(host): true}
+	for _, fqdn := range proxy.Spec.VirtualHost.AdditionalFqdns {
+		if isBlank(fqdn) {
+			validCond.AddError(contour_api_v1.ConditionTypeVirtualHostError, "FQDNNotSpecified",
+				"Spec.VirtualHost.AdditionalFqdns must not contain empty names")
+			return
+		}
+		if strings.Contains(fqdn, "*") {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "WildCardNotAllowed",
+				"Spec.VirtualHost.AdditionalFqdns %q cannot use wildcards", fqdn)
+			return
+		}
+		if seen[strings.ToLower(fqdn)] {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "DuplicateFqdn",
+				"Spec.VirtualHost.AdditionalFqdns %q is already a name of this virtual host", fqdn)
+			return
+		}
+		seen[strings.ToLower(fqdn)] = true
+	}
+
+	listeners := p.virtualHostListeners(validCond, proxy)
+	if listeners == nil {
+		return
+	}
+
+	// Whatever is built for the fqdn below is also served on the
+	// additional fqdns and listeners, including any partial state
+	// left behind by a validation error.
+	defer p.aliasVirtualHosts(host, proxy.Spec.VirtualHost.AdditionalFqdns, listeners)
+
+	if p.DefaultHTTPProxy != nil && *p.DefaultHTTPProxy == k8s.NamespacedNameOf(proxy) {
+		defer p.defaultVirtualHosts(host, listeners)
+	}
+
 	if len(proxy.Spec.Routes) == 0 && len(proxy.Spec.Includes) == 0 && proxy.Spec.TCPProxy == nil {
 		validCond.AddError(contour_api_v1.ConditionTypeSpecError, "NothingDefined",
 			"HTTPProxy.Spec must have at least one Route, Include, or a TCPProxy")
@@ -195,7 +458,7 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 				return
 			}
 
-			svhost := p.dag.EnsureSecureVirtualHost(ListenerName{Name: host, ListenerName: "ingress_https"})
+			svhost := p.dag.EnsureSecureVirtualHost(ListenerName{Name: host, ListenerName: listeners.https[0]})
 			svhost.Secret = sec
 			// default to a minimum TLS version of 1.2 if it's not specified
 			svhost.MinTLSVersion = annotation.MinTLSVersion(tls.MinimumProtocolVersion, "1.2")
@@ -218,6 +481,21 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 				return
 			}
 
+			// Likewise, requests that select the fallback
+			// certificate would bypass the Wasm modules.
+			if tls.EnableFallbackCertificate && len(proxy.Spec.VirtualHost.WasmModules) > 0 {
+				validCond.AddError(contour_api_v1.ConditionTypeTLSError, "TLSIncompatibleFeatures",
+					"Spec.Virtualhost.TLS fallback & Wasm modules are incompatible")
+				return
+			}
+
+			// And they would never be rejected by admission control.
+			if tls.EnableFallbackCertificate && proxy.Spec.VirtualHost.AdmissionControlPolicy != nil {
+				validCond.AddError(contour_api_v1.ConditionTypeTLSError, "TLSIncompatibleFeatures",
+					"Spec.Virtualhost.TLS fallback & admission control are incompatible")
+				return
+			}
+
 			// If FallbackCertificate is enabled, but no cert passed, set error
 			if tls.EnableFallbackCertificate {
 				if p.FallbackCertificate == nil {
@@ -262,6 +540,14 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 						"Spec.VirtualHost.TLS client validation is invalid: CA Secret must be specified")
 				}
 				svhost.DownstreamValidation = dv
+
+				headers, err := clientCertificateHeaders(tls.ClientValidation.CertificateHeaders)
+				if err != nil {
+					validCond.AddErrorf(contour_api_v1.ConditionTypeTLSError, "ClientValidationInvalid",
+						"Spec.VirtualHost.TLS client validation is invalid: %s", err)
+					return
+				}
+				svhost.ClientCertificateHeaders = headers
 			}
 
 			if proxy.Spec.VirtualHost.AuthorizationConfigured() {
@@ -280,7 +566,7 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 					Namespace: stringOrDefault(ref.Namespace, proxy.Namespace),
 				}
 
-				ext := p.dag.GetExtensionCluster(ExtensionClusterName(extensionName))
+				ext := p.extensionClusters[ExtensionClusterName(extensionName)]
 				if ext == nil {
 					validCond.AddErrorf(contour_api_v1.ConditionTypeAuthError, "ExtensionServiceNotFound",
 						"Spec.Virtualhost.Authorization.ServiceRef extension service %q not found", extensionName)
@@ -303,29 +589,102 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 					svhost.AuthorizationResponseTimeout = timeout
 				}
 			}
+
+			if len(proxy.Spec.VirtualHost.WasmModules) > 0 {
+				modules, ok := p.wasmModules(validCond, proxy)
+				if !ok {
+					return
+				}
+				svhost.WasmModules = modules
+			}
+
+			acp, err := admissionControlPolicy(proxy.Spec.VirtualHost.AdmissionControlPolicy)
+			if err != nil {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "AdmissionControlPolicyInvalid",
+					"Spec.VirtualHost.AdmissionControlPolicy is invalid: %s", err)
+				return
+			}
+			svhost.AdmissionControlPolicy = acp
+
+			if xff := proxy.Spec.VirtualHost.XffPolicy; xff != nil {
+				svhost.XffNumTrustedHops = xff.NumTrustedHops
+				svhost.SkipXffAppend = xff.SkipAppend
+			}
+
+			if rid := proxy.Spec.VirtualHost.RequestIDPolicy; rid != nil && rid.Policy != "" {
+				replace := rid.Policy == "Generate"
+				svhost.ReplaceExternalRequestID = &replace
+			}
 		}
 	}
 
+	if len(proxy.Spec.VirtualHost.WasmModules) > 0 && (proxy.Spec.VirtualHost.TLS == nil || proxy.Spec.VirtualHost.TLS.Passthrough) {
+		validCond.AddError(contour_api_v1.ConditionTypeVirtualHostError, "WasmModuleInvalid",
+			"Spec.VirtualHost.WasmModules requires that Spec.VirtualHost.TLS.SecretName be set")
+		return
+	}
+
+	if proxy.Spec.VirtualHost.AdmissionControlPolicy != nil && (proxy.Spec.VirtualHost.TLS == nil || proxy.Spec.VirtualHost.TLS.Passthrough) {
+		validCond.AddError(contour_api_v1.ConditionTypeVirtualHostError, "AdmissionControlPolicyInvalid",
+			"Spec.VirtualHost.AdmissionControlPolicy requires that Spec.VirtualHost.TLS.SecretName be set")
+		return
+	}
+
+	if proxy.Spec.VirtualHost.XffPolicy != nil && (proxy.Spec.VirtualHost.TLS == nil || proxy.Spec.VirtualHost.TLS.Passthrough) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeVirtualHostError, "IgnoredField",
+			"ignoring field %q; it requires that Spec.VirtualHost.TLS.SecretName be set", "Spec.VirtualHost.XffPolicy")
+	}
+
+	if rid := proxy.Spec.VirtualHost.RequestIDPolicy; rid != nil && rid.Policy != "" && (proxy.Spec.VirtualHost.TLS == nil || proxy.Spec.VirtualHost.TLS.Passthrough) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeVirtualHostError, "IgnoredField",
+			"ignoring field %q; it requires that Spec.VirtualHost.TLS.SecretName be set", "Spec.VirtualHost.RequestIDPolicy.Policy")
+	}
+
 	if proxy.Spec.TCPProxy != nil {
-		if !tlsEnabled {
-			validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "TLSMustBeConfigured",
-				"Spec.TCPProxy requires that either Spec.TLS.Passthrough or Spec.TLS.SecretName be set")
-			return
-		}
-		if !p.processHTTPProxyTCPProxy(validCond, proxy, nil, host) {
-			return
+		if port := proxy.Spec.TCPProxy.Port; port != 0 {
+			if proxy.Spec.VirtualHost.TLS != nil {
+				validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "TLSNotSupported",
+					"Spec.TCPProxy.Port cannot be combined with Spec.VirtualHost.TLS")
+				return
+			}
+			listener, ok := p.TCPListeners[port]
+			if !ok {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyError, "PortNotConfigured",
+					"Spec.TCPProxy.Port %d is not a configured TCP listener port", port)
+				return
+			}
+			tcpproxy, ok := p.processHTTPProxyTCPProxy(pa, proxy, nil)
+			if !ok {
+				return
+			}
+			if tcpproxy != nil {
+				vhost := p.dag.EnsureTCPVirtualHost(ListenerName{Name: host, ListenerName: listener}, port)
+				vhost.TCPProxy = tcpproxy
+			}
+		} else {
+			if !tlsEnabled {
+				validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "TLSMustBeConfigured",
+					"Spec.TCPProxy requires that either Spec.TLS.Passthrough or Spec.TLS.SecretName be set")
+				return
+			}
+			tcpproxy, ok := p.processHTTPProxyTCPProxy(pa, proxy, nil)
+			if !ok {
+				return
+			}
+			if tcpproxy != nil {
+				secure := p.dag.EnsureSecureVirtualHost(ListenerName{Name: host, ListenerName: listeners.https[0]})
+				secure.TCPProxy = tcpproxy
+			}
 		}
 	}
 
-	routes := p.computeRoutes(validCond, proxy, proxy, nil, nil, tlsEnabled)
-	insecure := p.dag.EnsureVirtualHost(ListenerName{Name: host, ListenerName: "ingress_http"})
+	routes := p.computeRoutes(pa, proxy, proxy, nil, nil, tlsEnabled)
 	cp, err := toCORSPolicy(proxy.Spec.VirtualHost.CORSPolicy)
 	if err != nil {
 		validCond.AddErrorf(contour_api_v1.ConditionTypeCORSError, "PolicyDidNotParse",
 			"Spec.VirtualHost.CORSPolicy: %s", err)
 		return
 	}
-	insecure.CORSPolicy = cp
 
 	rlp, err := rateLimitPolicy(proxy.Spec.VirtualHost.RateLimitPolicy)
 	if err != nil {
@@ -333,14 +692,45 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 			"Spec.VirtualHost.RateLimitPolicy is invalid: %s", err)
 		return
 	}
-	insecure.RateLimitPolicy = rlp
 
-	addRoutes(insecure, routes)
+	requestIDHeader, err := requestIDHeader(proxy.Spec.VirtualHost.RequestIDPolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "RequestIDPolicyNotValid",
+			"Spec.VirtualHost.RequestIDPolicy is invalid: %s", err)
+		return
+	}
+
+	luaScript, err := p.luaScript(proxy.Namespace, proxy.Spec.VirtualHost.Lua)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "LuaPolicyInvalid",
+			"Spec.VirtualHost.Lua is invalid: %s", err)
+		return
+	}
+
+	routes, err = userAgentRoutes(proxy.Spec.VirtualHost.UserAgentPolicy, routes)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "UserAgentPolicyInvalid",
+			"Spec.VirtualHost.UserAgentPolicy is invalid: %s", err)
+		return
+	}
+
+	// The virtual host is only served over plain HTTP if it
+	// selects an HTTP listener.
+	if len(listeners.http) > 0 {
+		insecure := p.dag.EnsureVirtualHost(ListenerName{Name: host, ListenerName: listeners.http[0]})
+		insecure.CORSPolicy = cp
+		insecure.RateLimitPolicy = rlp
+		insecure.StatsName = proxy.Spec.VirtualHost.StatsName
+		insecure.RequestIDHeader = requestIDHeader
+		insecure.LuaScript = luaScript
+
+		addRoutes(insecure, routes)
+	}
 
 	// if TLS is enabled for this virtual host and there is no tcp proxy defined,
 	// then add routes to the secure virtualhost definition.
 	if tlsEnabled && proxy.Spec.TCPProxy == nil {
-		secure := p.dag.EnsureSecureVirtualHost(ListenerName{Name: host, ListenerName: "ingress_https"})
+		secure := p.dag.EnsureSecureVirtualHost(ListenerName{Name: host, ListenerName: listeners.https[0]})
 		secure.CORSPolicy = cp
 
 		rlp, err := rateLimitPolicy(proxy.Spec.VirtualHost.RateLimitPolicy)
@@ -350,11 +740,137 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *contour_api_v1.HTTPProxy) {
 			return
 		}
 		secure.RateLimitPolicy = rlp
+		secure.StatsName = proxy.Spec.VirtualHost.StatsName
+		secure.RequestIDHeader = requestIDHeader
+		secure.LuaScript = luaScript
 
 		addRoutes(secure, routes)
 	}
 }
 
+// virtualHostListeners are the names of the listeners that serve a
+// virtual host. The virtual host is built on the first listener of
+// each kind, then copied to the others.
+type virtualHostListeners struct {
+	http  []string
+	https []string
+}
+
+// virtualHostListeners returns the listeners that proxy selects, or
+// nil if the selection is not valid. The details of the error are
+// recorded on validCond.
+func (p *HTTPProxyProcessor) virtualHostListeners(validCond *contour_api_v1.DetailedCondition, proxy *contour_api_v1.HTTPProxy) *virtualHostListeners {
+	if len(proxy.Spec.VirtualHost.Listeners) == 0 {
+		return &virtualHostListeners{
+			http:  []string{"ingress_http"},
+			https: []string{"ingress_https"},
+		}
+	}
+
+	listeners := &virtualHostListeners{}
+	seen := map[string]bool{}
+	for _, name := range proxy.Spec.VirtualHost.Listeners {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch {
+		case name == "ingress_http" || p.HTTPListeners[name]:
+			listeners.http = append(listeners.http, name)
+		case name == "ingress_https" || p.HTTPSListeners[name]:
+			listeners.https = append(listeners.https, name)
+		default:
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "ListenerNotConfigured",
+				"Spec.VirtualHost.Listeners %q is not a configured HTTP or HTTPS listener", name)
+			return nil
+		}
+	}
+
+	tlsEnabled := proxy.Spec.VirtualHost.TLS != nil
+	if tlsEnabled && len(listeners.https) == 0 {
+		validCond.AddError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerNotValid",
+			"Spec.VirtualHost.TLS requires that Spec.VirtualHost.Listeners select an HTTPS listener")
+		return nil
+	}
+	if !tlsEnabled && len(listeners.https) > 0 {
+		validCond.AddError(contour_api_v1.ConditionTypeVirtualHostError, "ListenerNotValid",
+			"Spec.VirtualHost.Listeners selects an HTTPS listener, which requires that Spec.VirtualHost.TLS be set")
+		return nil
+	}
+
+	return listeners
+}
+
+// aliasVirtualHosts copies the insecure and secure virtual hosts built
+// for host on the first of the listeners to the remaining listeners,
+// and to each of the aliases on all the listeners, so they serve the
+// same routes and TLS configuration.
+func (p *HTTPProxyProcessor) aliasVirtualHosts(host string, aliases []string, listeners *virtualHostListeners) {
+	names := append([]string{host}, aliases...)
+
+	if len(listeners.http) > 0 {
+		if vh := p.dag.GetVirtualHost(ListenerName{Name: host, ListenerName: listeners.http[0]}); vh != nil {
+			for _, name := range names {
+				for _, listener := range listeners.http {
+					if name == host && listener == vh.ListenerName {
+						continue
+					}
+					copyVirtualHost(p.dag.EnsureVirtualHost(ListenerName{Name: name, ListenerName: listener}), vh)
+				}
+			}
+		}
+	}
+
+	if len(listeners.https) > 0 {
+		if svh := p.dag.GetSecureVirtualHost(ListenerName{Name: host, ListenerName: listeners.https[0]}); svh != nil {
+			for _, name := range names {
+				for _, listener := range listeners.https {
+					if name == host && listener == svh.ListenerName {
+						continue
+					}
+					secure := p.dag.EnsureSecureVirtualHost(ListenerName{Name: name, ListenerName: listener})
+					vh := secure.VirtualHost
+					*secure = *svh
+					secure.VirtualHost = vh
+					copyVirtualHost(&secure.VirtualHost, &svh.VirtualHost)
+				}
+			}
+		}
+	}
+}
+
+// defaultVirtualHosts copies the insecure virtual host built for host
+// on the first of the listeners to the "*" virtual host of each of the
+// HTTP listeners, so it serves the requests for unknown hosts. Secure
+// virtual hosts are not copied, since TLS is only served to the SNI
+// names of their certificates.
+func (p *HTTPProxyProcessor) defaultVirtualHosts(host string, listeners *virtualHostListeners) {
+	if len(listeners.http) == 0 {
+		return
+	}
+
+	vh := p.dag.GetVirtualHost(ListenerName{Name: host, ListenerName: listeners.http[0]})
+	if vh == nil {
+		return
+	}
+	for _, listener := range listeners.http {
+		copyVirtualHost(p.dag.EnsureVirtualHost(ListenerName{Name: "*", ListenerName: listener}), vh)
+	}
+}
+
+// copyVirtualHost copies the policies and routes of src to dst.
+func copyVirtualHost(dst, src *VirtualHost) {
+	dst.CORSPolicy = src.CORSPolicy
+	dst.RateLimitPolicy = src.RateLimitPolicy
+	dst.StatsName = src.StatsName
+	dst.RequestIDHeader = src.RequestIDHeader
+	dst.LuaScript = src.LuaScript
+	for _, route := range src.routes {
+		dst.addRoute(route)
+	}
+}
+
 type vhost interface {
 	addRoute(*Route)
 }
@@ -367,13 +883,15 @@ func addRoutes(vhost vhost, routes []*Route) {
 }
 
 func (p *HTTPProxyProcessor) computeRoutes(
-	validCond *contour_api_v1.DetailedCondition,
+	pu *status.ProxyUpdate,
 	rootProxy *contour_api_v1.HTTPProxy,
 	proxy *contour_api_v1.HTTPProxy,
 	conditions []contour_api_v1.MatchCondition,
 	visited []*contour_api_v1.HTTPProxy,
 	enforceTLS bool,
 ) []*Route {
+	validCond := pu.ConditionFor(status.ValidCondition)
+
 	for _, v := range visited {
 		// ensure we are not following an edge that produces a cycle
 		var path []string
@@ -390,6 +908,7 @@ func (p *HTTPProxyProcessor) computeRoutes(
 
 	visited = append(visited, proxy)
 	var routes []*Route
+	var invalid bool
 
 	// Check for duplicate conditions on the includes
 	if includeMatchConditionsIdentical(proxy.Spec.Includes) {
@@ -398,6 +917,20 @@ func (p *HTTPProxyProcessor) computeRoutes(
 		return nil
 	}
 
+	// Warn about includes whose requests are all matched by
+	// the routes of another include first.
+	shadowed := includeMatchConditionsShadowed(proxy.Spec.Includes)
+	for j, include := range proxy.Spec.Includes {
+		i, ok := shadowed[j]
+		if !ok {
+			continue
+		}
+		validCond.AddWarningf(contour_api_v1.ConditionTypeIncludeError, "IncludeShadowed",
+			"include %s/%s is shadowed by include %s/%s, which matches all of its requests first",
+			includeNamespace(include, proxy.Namespace), include.Name,
+			includeNamespace(proxy.Spec.Includes[i], proxy.Namespace), proxy.Spec.Includes[i].Name)
+	}
+
 	// Loop over and process all includes
 	for _, include := range proxy.Spec.Includes {
 		namespace := include.Namespace
@@ -407,271 +940,772 @@ func (p *HTTPProxyProcessor) computeRoutes(
 
 		includedProxy, ok := p.source.httpproxies[types.NamespacedName{Name: include.Name, Namespace: namespace}]
 		if !ok {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeIncludeError, "IncludeNotFound",
+			pu.IncludeErrorf(namespace, include.Name, contour_api_v1.ConditionTypeIncludeError, "IncludeNotFound",
 				"include %s/%s not found", namespace, include.Name)
-			return nil
+			invalid = true
+			continue
 		}
 		if includedProxy.Spec.VirtualHost != nil {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeIncludeError, "RootIncludesRoot",
+			pu.IncludeErrorf(namespace, include.Name, contour_api_v1.ConditionTypeIncludeError, "RootIncludesRoot",
 				"root httpproxy cannot include another root httpproxy")
-			return nil
+			invalid = true
+			continue
+		}
+		if p.overQuota[k8s.NamespacedNameOf(includedProxy)] {
+			pu.IncludeErrorf(namespace, include.Name, contour_api_v1.ConditionTypeIncludeError, "IncludeOverQuota",
+				"include %s/%s exceeds the quota of its namespace", namespace, include.Name)
+			invalid = true
+			continue
 		}
 
 		if err := pathMatchConditionsValid(include.Conditions); err != nil {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeIncludeError, "PathMatchConditionsNotValid",
+			pu.IncludeErrorf(namespace, include.Name, contour_api_v1.ConditionTypeIncludeError, "PathMatchConditionsNotValid",
 				"include: %s", err)
-			return nil
+			invalid = true
+			continue
+		}
+
+		if p.includeDepthExceeded(visited) {
+			pu.IncludeErrorf(namespace, include.Name, contour_api_v1.ConditionTypeIncludeError, "IncludeDepthExceeded",
+				"include %s/%s exceeds the maximum include depth of %d: %s",
+				namespace, include.Name, p.MaxIncludeDepth, includePath(visited, includedProxy))
+			invalid = true
+			continue
 		}
 
 		inc, incCommit := p.dag.StatusCache.ProxyAccessor(includedProxy)
-		incValidCond := inc.ConditionFor(status.ValidCondition)
-		routes = append(routes, p.computeRoutes(incValidCond, rootProxy, includedProxy, append(conditions, include.Conditions...), visited, enforceTLS)...)
+		routes = append(routes, p.computeRoutes(inc, rootProxy, includedProxy, append(conditions, mergeNotPrefixConditions(conditions, include.Conditions)...), visited, enforceTLS)...)
+		recordInclude(pu, inc, includedProxy)
 		incCommit()
 
 		// dest is not an orphaned httpproxy, as there is an httpproxy that points to it
-		delete(p.orphaned, types.NamespacedName{Name: includedProxy.Name, Namespace: includedProxy.Namespace})
+		p.included[types.NamespacedName{Name: includedProxy.Name, Namespace: includedProxy.Namespace}] = true
 	}
 
 	dynamicHeaders := map[string]string{
 		"CONTOUR_NAMESPACE": proxy.Namespace,
 	}
 
-	for _, route := range proxy.Spec.Routes {
-		if err := pathMatchConditionsValid(route.Conditions); err != nil {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "PathMatchConditionsNotValid",
-				"route: %s", err)
-			return nil
-		}
-
-		conds := append(conditions, route.Conditions...)
-
-		// Look for invalid header conditions on this route
-		if err := headerMatchConditionsValid(conds); err != nil {
-			validCond.AddError(contour_api_v1.ConditionTypeRouteError, "HeaderMatchConditionsNotValid",
-				err.Error())
-			return nil
+	for i := range proxy.Spec.Routes {
+		r := p.computeRoute(validCond, rootProxy, proxy, &proxy.Spec.Routes[i], conditions, enforceTLS, dynamicHeaders)
+		if r == nil {
+			invalid = true
+			continue
 		}
+		routes = append(routes, r...)
+	}
 
-		reqHP, err := headersPolicyRoute(route.RequestHeadersPolicy, true /* allow Host */, dynamicHeaders)
-		if err != nil {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "RequestHeadersPolicyInvalid",
-				"%s on request headers", err)
+	// An HTTPProxy with an invalid route or include contributes no
+	// routes, but every error is reported so that they can all be
+	// fixed at once. With partial validity, only the invalid routes
+	// and includes are dropped.
+	if invalid {
+		if !p.PartialValidity || len(routes) == 0 {
 			return nil
 		}
+		validCond.Reason = status.PartiallyValidReason
+		validCond.Message = "Some routes or includes are not valid and are not in use, see Errors for details"
+	}
 
-		respHP, err := headersPolicyRoute(route.ResponseHeadersPolicy, false /* disallow Host */, dynamicHeaders)
-		if err != nil {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "ResponseHeaderPolicyInvalid",
-				"%s on response headers", err)
-			return nil
-		}
+	routes = expandPrefixMatches(routes)
 
-		if len(route.Services) < 1 {
-			validCond.AddError(contour_api_v1.ConditionTypeRouteError, "NoServicesPresent",
-				"route.services must have at least one entry")
-			return nil
-		}
+	return routes
+}
 
-		tp, err := timeoutPolicy(route.TimeoutPolicy)
-		if err != nil {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "TimeoutPolicyNotValid",
-				"route.timeoutPolicy failed to parse: %s", err)
-			return nil
-		}
+// computeRoute returns the routes for route, a route of proxy, or nil
+// if it is invalid, in which case the error is added to validCond.
+func (p *HTTPProxyProcessor) computeRoute(
+	validCond *contour_api_v1.DetailedCondition,
+	rootProxy *contour_api_v1.HTTPProxy,
+	proxy *contour_api_v1.HTTPProxy,
+	route *contour_api_v1.Route,
+	conditions []contour_api_v1.MatchCondition,
+	enforceTLS bool,
+	dynamicHeaders map[string]string,
+) []*Route {
+	var routes []*Route
 
-		rlp, err := rateLimitPolicy(route.RateLimitPolicy)
-		if err != nil {
-			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "RateLimitPolicyNotValid",
-				"route.rateLimitPolicy is invalid: %s", err)
-			return nil
-		}
+	if err := pathMatchConditionsValid(route.Conditions); err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "PathMatchConditionsNotValid",
+			"route: %s", err)
+		return nil
+	}
 
-		requestHashPolicies, lbPolicy := loadBalancerRequestHashPolicies(route.LoadBalancerPolicy, validCond)
-
-		r := &Route{
-			PathMatchCondition:    mergePathMatchConditions(conds),
-			HeaderMatchConditions: mergeHeaderMatchConditions(conds),
-			Websocket:             route.EnableWebsockets,
-			HTTPSUpgrade:          routeEnforceTLS(enforceTLS, route.PermitInsecure && !p.DisablePermitInsecure),
-			TimeoutPolicy:         tp,
-			RetryPolicy:           retryPolicy(route.RetryPolicy),
-			RequestHeadersPolicy:  reqHP,
-			ResponseHeadersPolicy: respHP,
-			RateLimitPolicy:       rlp,
-			RequestHashPolicies:   requestHashPolicies,
-		}
-
-		// If the enclosing root proxy enabled authorization,
-		// enable it on the route and propagate defaults
-		// downwards.
-		if rootProxy.Spec.VirtualHost.AuthorizationConfigured() {
-			// When the ext_authz filter is added to a
-			// vhost, it is in enabled state, but we can
-			// disable it per route. We emulate disabling
-			// it at the vhost layer by defaulting the state
-			// from the root proxy.
-			disabled := rootProxy.Spec.VirtualHost.DisableAuthorization()
-
-			// Take the default for enabling authorization
-			// from the virtual host. If this route has a
-			// policy, let that override.
-			if route.AuthPolicy != nil {
-				disabled = route.AuthPolicy.Disabled
-			}
+	conds := append(conditions, mergeNotPrefixConditions(conditions, route.Conditions)...)
 
-			r.AuthDisabled = disabled
-			r.AuthContext = route.AuthorizationContext(rootProxy.Spec.VirtualHost.AuthorizationContext())
-		}
+	// Look for invalid header conditions on this route
+	if err := headerMatchConditionsValid(conds); err != nil {
+		validCond.AddError(contour_api_v1.ConditionTypeRouteError, "HeaderMatchConditionsNotValid",
+			err.Error())
+		return nil
+	}
 
-		if len(route.GetPrefixReplacements()) > 0 {
-			if !r.HasPathPrefix() {
-				validCond.AddError(contour_api_v1.ConditionTypePrefixReplaceError, "MustHavePrefix",
-					"cannot specify prefix replacements without a prefix condition")
-				return nil
-			}
+	if err := geoMatchConditionsValid(conds, p.EnableGeoIPCountry, p.EnableGeoIPASN); err != nil {
+		validCond.AddError(contour_api_v1.ConditionTypeRouteError, "GeoMatchConditionsNotValid",
+			err.Error())
+		return nil
+	}
 
-			if reason, err := prefixReplacementsAreValid(route.GetPrefixReplacements()); err != nil {
-				validCond.AddError(contour_api_v1.ConditionTypePrefixReplaceError, reason, err.Error())
-				return nil
-			}
+	reqHP, err := headersPolicyRoute(route.RequestHeadersPolicy, true /* allow Host */, dynamicHeaders)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "RequestHeadersPolicyInvalid",
+			"%s on request headers", err)
+		return nil
+	}
 
-			// Note that we are guaranteed to always have a prefix
-			// condition. Even if the CRD user didn't specify a
-			// prefix condition, mergePathConditions() guarantees
-			// a prefix of '/'.
-			routingPrefix := r.PathMatchCondition.(*PrefixMatchCondition).Prefix
+	respHP, err := headersPolicyRoute(route.ResponseHeadersPolicy, false /* disallow Host */, dynamicHeaders)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "ResponseHeaderPolicyInvalid",
+			"%s on response headers", err)
+		return nil
+	}
 
-			// First, try to apply an exact prefix match.
-			for _, prefix := range route.GetPrefixReplacements() {
-				if len(prefix.Prefix) > 0 && routingPrefix == prefix.Prefix {
-					r.PrefixRewrite = prefix.Replacement
-					break
-				}
-			}
+	for _, warning := range headersPolicyWarnings(route.RequestHeadersPolicy, dynamicHeaders) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeRouteError, "UnknownHeaderOperator",
+			"%s on request headers", warning)
+	}
+	for _, warning := range headersPolicyWarnings(route.ResponseHeadersPolicy, dynamicHeaders) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeRouteError, "UnknownHeaderOperator",
+			"%s on response headers", warning)
+	}
 
-			// If there wasn't a match, we can apply the default replacement.
-			if len(r.PrefixRewrite) == 0 {
-				for _, prefix := range route.GetPrefixReplacements() {
-					if len(prefix.Prefix) == 0 {
-						r.PrefixRewrite = prefix.Replacement
-						break
-					}
-				}
-			}
+	cookieRewrite, err := cookieRewritePolicies(route.CookieRewritePolicies)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "CookieRewritePolicyInvalid",
+			"%s", err)
+		return nil
+	}
 
-		}
+	luaScript, err := p.luaScript(proxy.Namespace, route.Lua)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "LuaPolicyInvalid",
+			"route.lua is invalid: %s", err)
+		return nil
+	}
 
-		for _, service := range route.Services {
-			if service.Port < 1 || service.Port > 65535 {
-				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ServicePortInvalid",
-					"service %q: port must be in the range 1-65535", service.Name)
-				return nil
-			}
-			m := types.NamespacedName{Name: service.Name, Namespace: proxy.Namespace}
-			s, err := p.dag.EnsureService(m, intstr.FromInt(service.Port), p.source, p.EnableExternalNameService)
-			if err != nil {
-				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ServiceUnresolvedReference",
-					"Spec.Routes unresolved service reference: %s", err)
-				return nil
-			}
+	cp, err := cachePolicy(route.CachePolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "CachePolicyInvalid",
+			"route.cachePolicy is invalid: %s", err)
+		return nil
+	}
 
-			// Determine the protocol to use to speak to this Cluster.
-			protocol, err := getProtocol(service, s)
-			if err != nil {
-				validCond.AddError(contour_api_v1.ConditionTypeServiceError, "UnsupportedProtocol", err.Error())
-				return nil
+	bp, err := bufferPolicy(route.BufferPolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "BufferPolicyInvalid",
+			"route.bufferPolicy is invalid: %s", err)
+		return nil
+	}
+
+	tap, ok := p.tapPolicy(validCond, rootProxy, proxy, route)
+	if !ok {
+		return nil
+	}
+
+	clientValidation := rootProxy.Spec.VirtualHost != nil && rootProxy.Spec.VirtualHost.TLS != nil &&
+		rootProxy.Spec.VirtualHost.TLS.ClientValidation != nil
+	rbac, err := rbacPolicy(route.RBACPolicy, clientValidation)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "RBACPolicyInvalid",
+			"route.rbacPolicy is invalid: %s", err)
+		return nil
+	}
+
+	if route.DynamicForwardProxy {
+		if !p.EnableDynamicForwardProxy {
+			validCond.AddError(contour_api_v1.ConditionTypeRouteError, "DynamicForwardProxyNotEnabled",
+				"route.dynamicForwardProxy is not enabled. See the config.enableDynamicForwardProxy config file setting")
+			return nil
+		}
+		if len(route.Services) > 0 || route.OverflowPolicy != nil {
+			validCond.AddError(contour_api_v1.ConditionTypeRouteError, "DynamicForwardProxyNotValid",
+				"route.dynamicForwardProxy cannot be combined with route.services or route.overflowPolicy")
+			return nil
+		}
+	} else if len(route.Services) < 1 {
+		validCond.AddError(contour_api_v1.ConditionTypeRouteError, "NoServicesPresent",
+			"route.services must have at least one entry")
+		return nil
+	}
+
+	// Policies not set on the route are taken from the ContourPolicy
+	// of the namespace, then from the Contour configuration.
+	policy := p.contourPolicy(proxy.Namespace)
+
+	routeTimeoutPolicy := route.TimeoutPolicy
+	if routeTimeoutPolicy == nil && policy != nil {
+		routeTimeoutPolicy = policy.TimeoutPolicy
+	}
+	if routeTimeoutPolicy == nil {
+		routeTimeoutPolicy = p.DefaultTimeoutPolicy
+	}
+	tp, err := timeoutPolicy(routeTimeoutPolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "TimeoutPolicyNotValid",
+			"route.timeoutPolicy failed to parse: %s", err)
+		return nil
+	}
+
+	rlp, err := rateLimitPolicy(route.RateLimitPolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "RateLimitPolicyNotValid",
+			"route.rateLimitPolicy is invalid: %s", err)
+		return nil
+	}
+
+	pathMatch := mergePathMatchConditions(conds)
+	var grpcTimeoutHeaderMax timeout.Setting
+	if g := route.GRPC; g != nil {
+		if pathMatch.(*PrefixMatchCondition).Prefix != "/" {
+			validCond.AddError(contour_api_v1.ConditionTypeRouteError, "GRPCMatchNotValid",
+				"route.grpc cannot be combined with a prefix condition")
+			return nil
+		}
+		if isBlank(g.Service) || strings.Contains(g.Service, "/") || strings.Contains(g.Method, "/") {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "GRPCMatchNotValid",
+				"route.grpc service %q and method %q must be valid gRPC names", g.Service, g.Method)
+			return nil
+		}
+		grpcTimeoutHeaderMax, err = timeout.Parse(g.MaxTimeout)
+		if err != nil {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "GRPCMatchNotValid",
+				"route.grpc.maxTimeout failed to parse: %s", err)
+			return nil
+		}
+		pathMatch = grpcPathMatchCondition(g)
+	}
+
+	requestHashPolicies, lbPolicy := loadBalancerRequestHashPolicies(route.LoadBalancerPolicy, validCond)
+
+	routeRetryPolicy := route.RetryPolicy
+	if routeRetryPolicy == nil && policy != nil {
+		routeRetryPolicy = policy.RetryPolicy
+	}
+	if routeRetryPolicy == nil {
+		routeRetryPolicy = p.DefaultRetryPolicy
+	}
+	rp := retryPolicy(routeRetryPolicy)
+
+	if err := contourPolicyLimitsValid(policy, tp, rp); err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "ContourPolicyLimitExceeded",
+			"route exceeds the limits of the ContourPolicy of namespace %q: %s", proxy.Namespace, err)
+		return nil
+	}
+
+	permitInsecureDisabled := p.DisablePermitInsecure || (policy != nil && policy.DisablePermitInsecure)
+	if route.PermitInsecure && !permitInsecureDisabled && !p.permitInsecurePermitted(proxy.Namespace) {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "PermitInsecureNotPermitted",
+			"field %q is not permitted in namespace %q by the Contour configuration", "route.permitInsecure", proxy.Namespace)
+		return nil
+	}
+
+	hp, err := hedgePolicy(route.HedgePolicy, rp)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "HedgePolicyNotValid",
+			"route.hedgePolicy is invalid: %s", err)
+		return nil
+	}
+
+	tracing, err := tracingPolicy(route.TracingPolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "TracingPolicyNotValid",
+			"route.tracingPolicy is invalid: %s", err)
+		return nil
+	}
+
+	r := &Route{
+		PathMatchCondition:    pathMatch,
+		HeaderMatchConditions: mergeHeaderMatchConditions(conds),
+		Priority:              route.Priority,
+		Websocket:             route.EnableWebsockets,
+		HTTPSUpgrade:          routeEnforceTLS(enforceTLS, route.PermitInsecure && !permitInsecureDisabled),
+		TimeoutPolicy:         tp,
+		RetryPolicy:           rp,
+		HedgePolicy:           hp,
+		TracingPolicy:         tracing,
+		StatsName:             route.StatsName,
+		RequestHeadersPolicy:  reqHP,
+		ResponseHeadersPolicy: respHP,
+		CookieRewritePolicies: cookieRewrite,
+		LuaScript:             luaScript,
+		CachePolicy:           cp,
+		BufferPolicy:          bp,
+		TapPolicy:             tap,
+		RBACPolicy:            rbac,
+		RateLimitPolicy:       rlp,
+		RequestHashPolicies:   requestHashPolicies,
+		GRPC:                  route.GRPC != nil,
+		GRPCTimeoutHeaderMax:  grpcTimeoutHeaderMax,
+	}
+
+	if route.PermitInsecure && enforceTLS {
+		switch {
+		case p.DisablePermitInsecure:
+			validCond.AddWarningf(contour_api_v1.ConditionTypeRouteError, "IgnoredField",
+				"ignoring field %q; it is disabled by the Contour configuration", "route.permitInsecure")
+		case permitInsecureDisabled:
+			validCond.AddWarningf(contour_api_v1.ConditionTypeRouteError, "IgnoredField",
+				"ignoring field %q; it is disabled by the ContourPolicy of namespace %q", "route.permitInsecure", proxy.Namespace)
+		default:
+			validCond.AddWarningf(contour_api_v1.ConditionTypeRouteError, "PermitInsecure",
+				"route %q permits insecure requests to a virtual host that terminates TLS", pathMatchConditionString(r.PathMatchCondition))
+		}
+	}
+
+	// Take the access log policy from the virtual host,
+	// unless this route has a policy of its own.
+	if route.AccessLogPolicy != nil {
+		r.AccessLogSampling = accessLogSampling(route.AccessLogPolicy)
+	} else if rootProxy.Spec.VirtualHost != nil {
+		r.AccessLogSampling = accessLogSampling(rootProxy.Spec.VirtualHost.AccessLogPolicy)
+	}
+
+	if route.DynamicForwardProxy {
+		r.DynamicForwardProxy = &DynamicForwardProxyCluster{
+			DNSLookupFamily: string(p.DNSLookupFamily),
+		}
+	}
+
+	// If the enclosing root proxy enabled authorization,
+	// enable it on the route and propagate defaults
+	// downwards.
+	if rootProxy.Spec.VirtualHost.AuthorizationConfigured() {
+		// When the ext_authz filter is added to a
+		// vhost, it is in enabled state, but we can
+		// disable it per route. We emulate disabling
+		// it at the vhost layer by defaulting the state
+		// from the root proxy.
+		disabled := rootProxy.Spec.VirtualHost.DisableAuthorization()
+
+		// Take the default for enabling authorization
+		// from the virtual host. If this route has a
+		// policy, let that override.
+		if route.AuthPolicy != nil {
+			disabled = route.AuthPolicy.Disabled
+		}
+
+		r.AuthDisabled = disabled
+		r.AuthContext = route.AuthorizationContext(rootProxy.Spec.VirtualHost.AuthorizationContext())
+	}
+
+	if len(route.GetPrefixReplacements()) > 0 {
+		if !r.HasPathPrefix() {
+			validCond.AddError(contour_api_v1.ConditionTypePrefixReplaceError, "MustHavePrefix",
+				"cannot specify prefix replacements without a prefix condition")
+			return nil
+		}
+
+		if reason, err := prefixReplacementsAreValid(route.GetPrefixReplacements()); err != nil {
+			validCond.AddError(contour_api_v1.ConditionTypePrefixReplaceError, reason, err.Error())
+			return nil
+		}
+
+		// Note that we are guaranteed to always have a prefix
+		// condition. Even if the CRD user didn't specify a
+		// prefix condition, mergePathConditions() guarantees
+		// a prefix of '/'.
+		routingPrefix := r.PathMatchCondition.(*PrefixMatchCondition).Prefix
+
+		// First, try to apply an exact prefix match.
+		for _, prefix := range route.GetPrefixReplacements() {
+			if len(prefix.Prefix) > 0 && routingPrefix == prefix.Prefix {
+				r.PrefixRewrite = prefix.Replacement
+				break
 			}
+		}
 
-			var uv *PeerValidationContext
-			if (protocol == "tls" || protocol == "h2") && service.UpstreamValidation != nil {
-				// If the CACertificate name in the UpstreamValidation is namespaced and the namespace
-				// is not the proxy's namespace, check if the referenced secret is permitted to be
-				// delegated to the proxy's namespace.
-				// By default, a non-namespaced CACertificate is expected to reside in the proxy's namespace.
-				caCertNamespacedName := k8s.NamespacedNameFrom(service.UpstreamValidation.CACertificate, k8s.DefaultNamespace(proxy.Namespace))
-				if !p.source.DelegationPermitted(caCertNamespacedName, proxy.Namespace) {
-					validCond.AddErrorf(contour_api_v1.ConditionTypeTLSError, "CACertificateNotDelegated",
-						"service.UpstreamValidation.CACertificate Secret %q is not configured for certificate delegation", caCertNamespacedName)
-					return nil
-				}
-				// we can only validate TLS connections to services that talk TLS
-				uv, err = p.source.LookupUpstreamValidation(service.UpstreamValidation, caCertNamespacedName)
-				if err != nil {
-					validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "TLSUpstreamValidation",
-						"Service [%s:%d] TLS upstream validation policy error: %s", service.Name, service.Port, err)
-					return nil
+		// If there wasn't a match, we can apply the default replacement.
+		if len(r.PrefixRewrite) == 0 {
+			for _, prefix := range route.GetPrefixReplacements() {
+				if len(prefix.Prefix) == 0 {
+					r.PrefixRewrite = prefix.Replacement
+					break
 				}
 			}
+		}
 
-			dynamicHeaders["CONTOUR_SERVICE_NAME"] = service.Name
-			dynamicHeaders["CONTOUR_SERVICE_PORT"] = strconv.Itoa(service.Port)
+	}
 
-			reqHP, err := headersPolicyService(p.RequestHeadersPolicy, service.RequestHeadersPolicy, dynamicHeaders)
-			if err != nil {
-				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "RequestHeadersPolicyInvalid",
-					"%s on request headers", err)
-				return nil
+	failover, ok := p.failoverServices(validCond, proxy, route.Services)
+	if !ok {
+		return nil
+	}
+
+	for _, service := range route.Services {
+		if service.Failover {
+			continue
+		}
+
+		c := p.computeServiceCluster(validCond, proxy, route.HealthCheckPolicy, r, service, lbPolicy, dynamicHeaders)
+		if c == nil {
+			return nil
+		}
+		if service.Mirror && r.MirrorPolicy != nil {
+			validCond.AddError(contour_api_v1.ConditionTypeServiceError, "OnlyOneMirror",
+				"only one service per route may be nominated as mirror")
+			return nil
+		}
+		if service.Mirror {
+			// Envoy applies neither header policy of a
+			// service to the requests mirrored to it.
+			if service.RequestHeadersPolicy != nil || service.ResponseHeadersPolicy != nil {
+				validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "IgnoredField",
+					"ignoring header policies on mirror service %q; the headers of mirrored requests are set by its mirrorPolicy", service.Name)
 			}
-			respHP, err := headersPolicyService(p.ResponseHeadersPolicy, service.ResponseHeadersPolicy, dynamicHeaders)
-			if err != nil {
-				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ResponseHeadersPolicyInvalid",
-					"%s on response headers", err)
-				return nil
+			r.MirrorPolicy = &MirrorPolicy{
+				Cluster: c,
 			}
-
-			var clientCertSecret *Secret
-			if p.ClientCertificate != nil {
-				clientCertSecret, err = p.source.LookupSecret(*p.ClientCertificate, validSecret)
+			if mp := service.MirrorPolicy; mp != nil {
+				hp, err := headersPolicyService(nil, &contour_api_v1.HeadersPolicy{Set: mp.RequestHeaders}, dynamicHeaders)
 				if err != nil {
-					validCond.AddErrorf(contour_api_v1.ConditionTypeTLSError, "SecretNotValid",
-						"tls.envoy-client-certificate Secret %q is invalid: %s", p.ClientCertificate, err)
+					validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "MirrorPolicyInvalid",
+						"%s on mirror service %q", err, service.Name)
 					return nil
 				}
+				r.MirrorPolicy.RequestHeadersPolicy = hp
 			}
+		} else {
+			if service.MirrorPolicy != nil {
+				validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "IgnoredField",
+					"ignoring mirrorPolicy on service %q, which is not a mirror", service.Name)
+			}
+			r.Clusters = append(r.Clusters, c)
+		}
+	}
 
-			c := &Cluster{
-				Upstream:              s,
-				LoadBalancerPolicy:    lbPolicy,
-				Weight:                uint32(service.Weight),
-				HTTPHealthCheckPolicy: httpHealthCheckPolicy(route.HealthCheckPolicy),
-				UpstreamValidation:    uv,
-				RequestHeadersPolicy:  reqHP,
-				ResponseHeadersPolicy: respHP,
-				Protocol:              protocol,
-				SNI:                   determineSNI(r.RequestHeadersPolicy, reqHP, s),
-				DNSLookupFamily:       string(p.DNSLookupFamily),
-				ClientCertificate:     clientCertSecret,
+	// When any service of the route has a weight, those without
+	// one receive no traffic at all.
+	if weighted(route.Services) {
+		for _, service := range route.Services {
+			if service.Weight == 0 && !service.Mirror && !service.Failover {
+				validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "ZeroWeightService",
+					"service %q has a weight of zero and receives no traffic", service.Name)
 			}
-			if service.Mirror && r.MirrorPolicy != nil {
-				validCond.AddError(contour_api_v1.ConditionTypeServiceError, "OnlyOneMirror",
-					"only one service per route may be nominated as mirror")
+		}
+	}
+
+	if len(failover) > 0 {
+		if len(r.Clusters) == 0 {
+			validCond.AddError(contour_api_v1.ConditionTypeServiceError, "FailoverServiceNotValid",
+				"route must have at least one service that is not a failover or mirror service")
+			return nil
+		}
+
+		for _, c := range r.Clusters {
+			if !discoveredByEDS(c.Upstream) {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "FailoverServiceNotValid",
+					"service %q: failover is not supported for ExternalName services or services with endpoints", c.Upstream.Weighted.ServiceName)
 				return nil
 			}
-			if service.Mirror {
-				r.MirrorPolicy = &MirrorPolicy{
-					Cluster: c,
-				}
-			} else {
-				r.Clusters = append(r.Clusters, c)
-			}
+			c.Failover = failover
 		}
-		routes = append(routes, r)
 	}
 
-	routes = expandPrefixMatches(routes)
+	if op := route.OverflowPolicy; op != nil {
+		if op.Service.Mirror {
+			validCond.AddError(contour_api_v1.ConditionTypeServiceError, "OverflowServiceMirror",
+				"route.overflowPolicy.service cannot be nominated as mirror")
+			return nil
+		}
+		if op.MaxRequestBodyBytes < 0 {
+			validCond.AddError(contour_api_v1.ConditionTypeRouteError, "OverflowPolicyNotValid",
+				"route.overflowPolicy.maxRequestBodyBytes must not be negative")
+			return nil
+		}
+
+		c := p.computeServiceCluster(validCond, proxy, route.HealthCheckPolicy, r, op.Service, lbPolicy, dynamicHeaders)
+		if c == nil {
+			return nil
+		}
+
+		// The overflow route shares everything with the primary
+		// route, but additionally matches requests whose declared
+		// body exceeds the threshold. It has more header conditions
+		// than the primary route, so it always sorts ahead of it.
+		overflow := *r
+		overflow.HeaderMatchConditions = append(append([]HeaderMatchCondition{}, r.HeaderMatchConditions...),
+			HeaderMatchCondition{
+				Name:      "Content-Length",
+				Value:     strconv.FormatInt(op.MaxRequestBodyBytes, 10),
+				MatchType: HeaderMatchTypeGreaterThan,
+			})
+		overflow.Clusters = []*Cluster{c}
+		routes = append(routes, &overflow)
+	}
+
+	routes = append(routes, r)
+
+	// Requests that declare a larger body than the buffer policy allows
+	// are passed through by a copy of each route that does not buffer
+	// them. Like the overflow route, the copy sorts ahead of the route.
+	if route.BufferPolicy != nil && route.BufferPolicy.OnOverflow == "Passthrough" {
+		for _, rt := range routes {
+			passthrough := *rt
+			passthrough.HeaderMatchConditions = append(append([]HeaderMatchCondition{}, rt.HeaderMatchConditions...),
+				HeaderMatchCondition{
+					Name:      "Content-Length",
+					Value:     strconv.FormatUint(uint64(bp.MaxRequestBytes), 10),
+					MatchType: HeaderMatchTypeGreaterThan,
+				})
+			passthrough.BufferPolicy = nil
+			routes = append(routes, &passthrough)
+		}
+	}
 
 	return routes
 }
 
+// ensureService returns the DAG service for the given route service. A
+// service with a pod selector is resolved to a subset of a headless Service,
+// and a service with endpoints is not resolved to a Kubernetes Service at all.
+func (p *HTTPProxyProcessor) ensureService(m types.NamespacedName, service contour_api_v1.Service) (*Service, error) {
+	if len(service.Endpoints) > 0 {
+		if len(service.PodSelector) > 0 {
+			return nil, fmt.Errorf("service %q: endpoints cannot be combined with a pod selector", service.Name)
+		}
+
+		endpoints, err := staticEndpoints(service)
+		if err != nil {
+			return nil, err
+		}
+
+		return p.dag.EnsureStaticService(m, int32(service.Port), endpoints), nil
+	}
+
+	if len(service.PodSelector) > 0 {
+		return p.dag.EnsureHeadlessService(m, intstr.FromInt(service.Port), p.source, service.PodSelector)
+	}
+
+	return p.dag.EnsureService(m, intstr.FromInt(service.Port), p.source, p.EnableExternalNameService)
+}
+
+// failoverServices returns the DAG services of the given route services
+// that are marked as failover. If any of them is not valid, the error is
+// recorded on the condition and false is returned.
+func (p *HTTPProxyProcessor) failoverServices(validCond *contour_api_v1.DetailedCondition, proxy *contour_api_v1.HTTPProxy, services []contour_api_v1.Service) ([]*Service, bool) {
+	var failover []*Service
+	for _, service := range services {
+		if !service.Failover {
+			continue
+		}
+
+		if service.Mirror {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "FailoverServiceNotValid",
+				"service %q: a failover service cannot be nominated as mirror", service.Name)
+			return nil, false
+		}
+
+		m := types.NamespacedName{Name: service.Name, Namespace: proxy.Namespace}
+		s, err := p.ensureService(m, service)
+		if err != nil {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ServiceUnresolvedReference",
+				"Spec.Routes unresolved service reference: %s", err)
+			return nil, false
+		}
+
+		if !discoveredByEDS(s) {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "FailoverServiceNotValid",
+				"service %q: failover is not supported for ExternalName services or services with endpoints", service.Name)
+			return nil, false
+		}
+
+		failover = append(failover, s)
+	}
+
+	return failover, true
+}
+
+// discoveredByEDS returns true if the endpoints of s
+// are discovered from Kubernetes rather than from DNS
+// or a static list.
+func discoveredByEDS(s *Service) bool {
+	return s.ExternalName == "" && len(s.StaticEndpoints) == 0
+}
+
+// computeServiceCluster returns the Cluster for the given route service. If the
+// service is not valid, the error is recorded on the condition and nil is returned.
+func (p *HTTPProxyProcessor) computeServiceCluster(
+	validCond *contour_api_v1.DetailedCondition,
+	proxy *contour_api_v1.HTTPProxy,
+	healthCheckPolicy *contour_api_v1.HTTPHealthCheckPolicy,
+	r *Route,
+	service contour_api_v1.Service,
+	lbPolicy string,
+	dynamicHeaders map[string]string,
+) *Cluster {
+	if service.Port < 1 || service.Port > 65535 {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ServicePortInvalid",
+			"service %q: port must be in the range 1-65535", service.Name)
+		return nil
+	}
+	m := types.NamespacedName{Name: service.Name, Namespace: proxy.Namespace}
+	s, err := p.ensureService(m, service)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ServiceUnresolvedReference",
+			"Spec.Routes unresolved service reference: %s", err)
+		return nil
+	}
+
+	// Determine the protocol to use to speak to this Cluster.
+	protocol, err := getProtocol(service, s)
+	if err != nil {
+		validCond.AddError(contour_api_v1.ConditionTypeServiceError, "UnsupportedProtocol", err.Error())
+		return nil
+	}
+	if !p.protocolPermitted(proxy.Namespace, protocol) {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ProtocolNotPermitted",
+			"service %q: protocol %q is not permitted by the ContourPolicy of namespace %q", service.Name, policyProtocol(protocol), proxy.Namespace)
+		return nil
+	}
+
+	proxyProtocol, err := getProxyProtocol(service)
+	if err != nil {
+		validCond.AddError(contour_api_v1.ConditionTypeServiceError, "UnsupportedProxyProtocol", err.Error())
+		return nil
+	}
+
+	http2, err := http2Settings(service, protocol)
+	if err != nil {
+		validCond.AddError(contour_api_v1.ConditionTypeServiceError, "HTTP2SettingsNotValid", err.Error())
+		return nil
+	}
+
+	hcp, err := httpHealthCheckPolicy(healthCheckPolicy)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "HealthCheckPolicyNotValid",
+			"route.healthCheckPolicy is invalid: %s", err)
+		return nil
+	}
+	if hcp != nil && hcp.GRPC != nil && protocol != "h2" && protocol != "h2c" {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "HealthCheckPolicyNotValid",
+			"route.healthCheckPolicy.grpc requires service %q to use the h2 or h2c protocol", service.Name)
+		return nil
+	}
+	if hcp != nil && hcp.Port > 0 && !discoveredByEDS(s) {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "HealthCheckPolicyNotValid",
+			"route.healthCheckPolicy.port is not supported for service %q; it is an ExternalName service or has endpoints", service.Name)
+		return nil
+	}
+	if hcp != nil && s.ExternalName != "" {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "HealthCheckExternalName",
+			"service %q is an ExternalName service; route.healthCheckPolicy checks the health of %q rather than of the service's endpoints", service.Name, s.ExternalName)
+	}
+
+	uv, ok := p.upstreamValidation(validCond, proxy, service, protocol)
+	if !ok {
+		return nil
+	}
+
+	dynamicHeaders["CONTOUR_SERVICE_NAME"] = service.Name
+	dynamicHeaders["CONTOUR_SERVICE_PORT"] = strconv.Itoa(service.Port)
+
+	reqHP, err := headersPolicyService(p.RequestHeadersPolicy, service.RequestHeadersPolicy, dynamicHeaders)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "RequestHeadersPolicyInvalid",
+			"%s on request headers", err)
+		return nil
+	}
+	respHP, err := headersPolicyService(p.ResponseHeadersPolicy, service.ResponseHeadersPolicy, dynamicHeaders)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ResponseHeadersPolicyInvalid",
+			"%s on response headers", err)
+		return nil
+	}
+	for _, warning := range headersPolicyWarnings(service.RequestHeadersPolicy, dynamicHeaders) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "UnknownHeaderOperator",
+			"%s on request headers", warning)
+	}
+	for _, warning := range headersPolicyWarnings(service.ResponseHeadersPolicy, dynamicHeaders) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "UnknownHeaderOperator",
+			"%s on response headers", warning)
+	}
+
+	clientCertSecret, ok := p.clientCertificate(validCond)
+	if !ok {
+		return nil
+	}
+
+	return &Cluster{
+		Upstream:              s,
+		LoadBalancerPolicy:    lbPolicy,
+		Weight:                uint32(service.Weight),
+		HTTPHealthCheckPolicy: hcp,
+		UpstreamValidation:    uv,
+		RequestHeadersPolicy:  reqHP,
+		ResponseHeadersPolicy: respHP,
+		Protocol:              protocol,
+		SNI:                   determineSNI(r.RequestHeadersPolicy, reqHP, s),
+		DNSLookupFamily:       string(p.DNSLookupFamily),
+		ClientCertificate:     clientCertSecret,
+		SPIFFE:                p.SPIFFE,
+		UpstreamProxyProtocol: proxyProtocol,
+		HTTP2:                 http2,
+	}
+}
+
+// upstreamValidation returns the validation context for TLS connections
+// to the supplied service of the HTTPProxy, which is nil if the service
+// does not speak TLS or has no UpstreamValidation. It returns false if
+// the UpstreamValidation is invalid, recording the error on validCond.
+func (p *HTTPProxyProcessor) upstreamValidation(validCond *contour_api_v1.DetailedCondition, proxy *contour_api_v1.HTTPProxy, service contour_api_v1.Service, protocol string) (*PeerValidationContext, bool) {
+	// we can only validate TLS connections to services that talk TLS
+	if (protocol != "tls" && protocol != "h2") || service.UpstreamValidation == nil {
+		return nil, true
+	}
+
+	// If the CACertificate name in the UpstreamValidation is namespaced and the namespace
+	// is not the proxy's namespace, check if the referenced secret is permitted to be
+	// delegated to the proxy's namespace.
+	// By default, a non-namespaced CACertificate is expected to reside in the proxy's namespace.
+	caCertNamespacedName := k8s.NamespacedNameFrom(service.UpstreamValidation.CACertificate, k8s.DefaultNamespace(proxy.Namespace))
+	if !p.source.DelegationPermitted(caCertNamespacedName, proxy.Namespace) {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeTLSError, "CACertificateNotDelegated",
+			"service.UpstreamValidation.CACertificate Secret %q is not configured for certificate delegation", caCertNamespacedName)
+		return nil, false
+	}
+
+	uv, err := p.source.LookupUpstreamValidation(service.UpstreamValidation, caCertNamespacedName)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "TLSUpstreamValidation",
+			"Service [%s:%d] TLS upstream validation policy error: %s", service.Name, service.Port, err)
+		return nil, false
+	}
+	return uv, true
+}
+
+// clientCertificate returns the client certificate Envoy presents to
+// TLS upstreams, which is nil if none is configured. It returns false
+// if the configured Secret is invalid, recording the error on validCond.
+func (p *HTTPProxyProcessor) clientCertificate(validCond *contour_api_v1.DetailedCondition) (*Secret, bool) {
+	if p.ClientCertificate == nil {
+		return nil, true
+	}
+
+	secret, err := p.source.LookupSecret(*p.ClientCertificate, validSecret)
+	if err != nil {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeTLSError, "SecretNotValid",
+			"tls.envoy-client-certificate Secret %q is invalid: %s", p.ClientCertificate, err)
+		return nil, false
+	}
+	return secret, true
+}
+
 // processHTTPProxyTCPProxy processes the spec.tcpproxy stanza in a HTTPProxy document
-// following the chain of spec.tcpproxy.include references. It returns true if processing
-// was successful, otherwise false if an error was encountered. The details of the error
+// following the chain of spec.tcpproxy.include references. It returns the resulting
+// TCPProxy, which is nil if there is nothing to proxy, and true if processing was
+// successful, otherwise false if an error was encountered. The details of the error
 // will be recorded on the status of the relevant HTTPProxy object,
-func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.DetailedCondition, httpproxy *contour_api_v1.HTTPProxy, visited []*contour_api_v1.HTTPProxy, host string) bool {
+func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(pu *status.ProxyUpdate, httpproxy *contour_api_v1.HTTPProxy, visited []*contour_api_v1.HTTPProxy) (*TCPProxy, bool) {
+	validCond := pu.ConditionFor(status.ValidCondition)
 	tcpproxy := httpproxy.Spec.TCPProxy
 	if tcpproxy == nil {
 		// nothing to do
-		return true
+		return nil, true
+	}
+
+	if len(visited) > 0 && tcpproxy.Port != 0 {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeTCPProxyError, "IgnoredField",
+			"ignoring field %q; it can only be set on a root HTTPProxy", "Spec.TCPProxy.Port")
 	}
 
 	visited = append(visited, httpproxy)
@@ -686,7 +1720,7 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 	if len(tcpproxy.Services) > 0 && tcpProxyInclude != nil {
 		validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "NoServicesAndInclude",
 			"cannot specify services and include in the same httpproxy")
-		return false
+		return nil, false
 	}
 
 	lbPolicy := loadBalancerPolicy(tcpproxy.LoadBalancerPolicy)
@@ -700,42 +1734,111 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 	}
 
 	if len(tcpproxy.Services) > 0 {
-		var proxy TCPProxy
+		var connectTimeout time.Duration
+		proxy := TCPProxy{
+			MaxConnectAttempts: tcpproxy.MaxConnectAttempts,
+			DisableAccessLog:   tcpproxy.DisableAccessLog,
+		}
+		if tp := tcpproxy.TimeoutPolicy; tp != nil {
+			idle, err := timeout.Parse(tp.Idle)
+			if err != nil {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyError, "TimeoutPolicyNotValid",
+					"Spec.TCPProxy.TimeoutPolicy failed to parse: error parsing idle timeout: %s", err)
+				return nil, false
+			}
+			proxy.IdleTimeout = idle
+
+			connect, err := timeout.Parse(tp.Connect)
+			if err != nil {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyError, "TimeoutPolicyNotValid",
+					"Spec.TCPProxy.TimeoutPolicy failed to parse: error parsing connect timeout: %s", err)
+				return nil, false
+			}
+			if connect.IsDisabled() {
+				validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "TimeoutPolicyNotValid",
+					"Spec.TCPProxy.TimeoutPolicy connect timeout cannot be disabled")
+				return nil, false
+			}
+			connectTimeout = connect.Duration()
+		}
+
+		healthCheckPolicy, err := tcpHealthCheckPolicy(tcpproxy.HealthCheckPolicy)
+		if err != nil {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyError, "HealthCheckPolicyNotValid",
+				"Spec.TCPProxy.HealthCheckPolicy is invalid: %s", err)
+			return nil, false
+		}
+
 		for _, service := range httpproxy.Spec.TCPProxy.Services {
+			if service.Failover {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyError, "FailoverServiceNotValid",
+					"service %q: failover services are only supported on routes", service.Name)
+				return nil, false
+			}
+
 			m := types.NamespacedName{Name: service.Name, Namespace: httpproxy.Namespace}
-			s, err := p.dag.EnsureService(m, intstr.FromInt(service.Port), p.source, p.EnableExternalNameService)
+			s, err := p.ensureService(m, service)
 			if err != nil {
 				validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyError, "ServiceUnresolvedReference",
 					"Spec.TCPProxy unresolved service reference: %s", err)
-				return false
+				return nil, false
+			}
+			if healthCheckPolicy != nil && s.ExternalName != "" {
+				validCond.AddWarningf(contour_api_v1.ConditionTypeServiceError, "HealthCheckExternalName",
+					"service %q is an ExternalName service; Spec.TCPProxy.HealthCheckPolicy checks the health of %q rather than of the service's endpoints", service.Name, s.ExternalName)
 			}
 
 			// Determine the protocol to use to speak to this Cluster.
 			protocol, err := getProtocol(service, s)
 			if err != nil {
 				validCond.AddError(contour_api_v1.ConditionTypeServiceError, "UnsupportedProtocol", err.Error())
-				return false
+				return nil, false
+			}
+			if !p.protocolPermitted(httpproxy.Namespace, protocol) {
+				validCond.AddErrorf(contour_api_v1.ConditionTypeServiceError, "ProtocolNotPermitted",
+					"service %q: protocol %q is not permitted by the ContourPolicy of namespace %q", service.Name, policyProtocol(protocol), httpproxy.Namespace)
+				return nil, false
+			}
+
+			proxyProtocol, err := getProxyProtocol(service)
+			if err != nil {
+				validCond.AddError(contour_api_v1.ConditionTypeServiceError, "UnsupportedProxyProtocol", err.Error())
+				return nil, false
+			}
+
+			uv, ok := p.upstreamValidation(validCond, httpproxy, service, protocol)
+			if !ok {
+				return nil, false
+			}
+
+			var clientCertSecret *Secret
+			if protocol == "tls" {
+				if clientCertSecret, ok = p.clientCertificate(validCond); !ok {
+					return nil, false
+				}
 			}
 
 			proxy.Clusters = append(proxy.Clusters, &Cluster{
-				Upstream:             s,
-				Protocol:             protocol,
-				LoadBalancerPolicy:   lbPolicy,
-				TCPHealthCheckPolicy: tcpHealthCheckPolicy(tcpproxy.HealthCheckPolicy),
-				SNI:                  s.ExternalName,
+				Upstream:              s,
+				Protocol:              protocol,
+				LoadBalancerPolicy:    lbPolicy,
+				TCPHealthCheckPolicy:  healthCheckPolicy,
+				SNI:                   s.ExternalName,
+				UpstreamProxyProtocol: proxyProtocol,
+				ConnectTimeout:        connectTimeout,
+				UpstreamValidation:    uv,
+				ClientCertificate:     clientCertSecret,
+				SPIFFE:                p.SPIFFE,
 			})
 		}
-		secure := p.dag.EnsureSecureVirtualHost(ListenerName{Name: host, ListenerName: "ingress_https"})
-		secure.TCPProxy = &proxy
-
-		return true
+		return &proxy, true
 	}
 
 	if tcpProxyInclude == nil {
 		// We don't allow an empty TCPProxy object.
 		validCond.AddError(contour_api_v1.ConditionTypeTCPProxyError, "NothingDefined",
 			"either services or inclusion must be specified")
-		return false
+		return nil, false
 	}
 
 	namespace := tcpProxyInclude.Namespace
@@ -747,20 +1850,26 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 	m := types.NamespacedName{Name: tcpProxyInclude.Name, Namespace: namespace}
 	dest, ok := p.source.httpproxies[m]
 	if !ok {
-		validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeNotFound",
+		pu.IncludeErrorf(m.Namespace, m.Name, contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeNotFound",
 			"include %s/%s not found", m.Namespace, m.Name)
-		return false
+		return nil, false
 	}
 
 	if dest.Spec.VirtualHost != nil {
 
-		validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyIncludeError, "RootIncludesRoot",
+		pu.IncludeErrorf(m.Namespace, m.Name, contour_api_v1.ConditionTypeTCPProxyIncludeError, "RootIncludesRoot",
 			"root httpproxy cannot include another root httpproxy")
-		return false
+		return nil, false
+	}
+
+	if p.overQuota[m] {
+		pu.IncludeErrorf(m.Namespace, m.Name, contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeOverQuota",
+			"include %s/%s exceeds the quota of its namespace", m.Namespace, m.Name)
+		return nil, false
 	}
 
 	// dest is no longer an orphan
-	delete(p.orphaned, k8s.NamespacedNameOf(dest))
+	p.included[k8s.NamespacedNameOf(dest)] = true
 
 	// ensure we are not following an edge that produces a cycle
 	var path []string
@@ -770,18 +1879,94 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 	for _, hp := range visited {
 		if dest.Name == hp.Name && dest.Namespace == hp.Namespace {
 			path = append(path, fmt.Sprintf("%s/%s", dest.Namespace, dest.Name))
-			validCond.AddErrorf(contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeCreatesCycle",
+			pu.IncludeErrorf(m.Namespace, m.Name, contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeCreatesCycle",
 				"include creates a cycle: %s", strings.Join(path, " -> "))
-			return false
+			return nil, false
 		}
 	}
 
+	if p.includeDepthExceeded(visited) {
+		pu.IncludeErrorf(m.Namespace, m.Name, contour_api_v1.ConditionTypeTCPProxyIncludeError, "IncludeDepthExceeded",
+			"include %s/%s exceeds the maximum include depth of %d: %s",
+			dest.Namespace, dest.Name, p.MaxIncludeDepth, includePath(visited, dest))
+		return nil, false
+	}
+
 	// follow the link and process the target tcpproxy
 	inc, commit := p.dag.StatusCache.ProxyAccessor(dest)
-	incValidCond := inc.ConditionFor(status.ValidCondition)
 	defer commit()
-	ok = p.processHTTPProxyTCPProxy(incValidCond, dest, visited, host)
-	return ok
+	included, ok := p.processHTTPProxyTCPProxy(inc, dest, visited)
+	recordInclude(pu, inc, dest)
+	return included, ok
+}
+
+// recordInclude records the status of the include of dest on pu,
+// according to the Valid condition of the included HTTPProxy.
+func recordInclude(pu, inc *status.ProxyUpdate, dest *contour_api_v1.HTTPProxy) {
+	incValidCond := inc.ConditionFor(status.ValidCondition)
+	if incValidCond.Status == contour_api_v1.ConditionTrue {
+		pu.IncludeValid(dest.Namespace, dest.Name)
+		return
+	}
+	pu.IncludeNotValid(dest.Namespace, dest.Name, "IncludeNotValid",
+		fmt.Sprintf("included HTTPProxy %s/%s is not valid: %s", dest.Namespace, dest.Name, incValidCond.Message))
+}
+
+// includeDepthExceeded returns true if including another HTTPProxy
+// from the last of visited, the chain of HTTPProxies followed from
+// the root, would exceed the maximum include depth.
+func (p *HTTPProxyProcessor) includeDepthExceeded(visited []*contour_api_v1.HTTPProxy) bool {
+	return p.MaxIncludeDepth > 0 && len(visited) > p.MaxIncludeDepth
+}
+
+// includePath formats the chain of HTTPProxies visited followed by
+// next, e.g. "roots/root -> marketing/blog -> marketing/posts".
+func includePath(visited []*contour_api_v1.HTTPProxy, next *contour_api_v1.HTTPProxy) string {
+	var path []string
+	for _, hp := range visited {
+		path = append(path, fmt.Sprintf("%s/%s", hp.Namespace, hp.Name))
+	}
+	path = append(path, fmt.Sprintf("%s/%s", next.Namespace, next.Name))
+	return strings.Join(path, " -> ")
+}
+
+// claimIncludes removes the HTTPProxies that proxy includes, directly
+// or through other HTTPProxies, from the orphaned set. It is called for
+// the roots of other shards, which update the status of the HTTPProxies
+// that only they include, so those updates are discarded here. The
+// HTTPProxies in claimed have already been followed.
+func (p *HTTPProxyProcessor) claimIncludes(proxy *contour_api_v1.HTTPProxy, claimed map[types.NamespacedName]bool) {
+	var includes []types.NamespacedName
+	for _, include := range proxy.Spec.Includes {
+		includes = append(includes, types.NamespacedName{Name: include.Name, Namespace: include.Namespace})
+	}
+	if tcpproxy := proxy.Spec.TCPProxy; tcpproxy != nil {
+		include := tcpproxy.Include
+		if include == nil {
+			include = tcpproxy.IncludesDeprecated
+		}
+		if include != nil {
+			includes = append(includes, types.NamespacedName{Name: include.Name, Namespace: include.Namespace})
+		}
+	}
+
+	for _, m := range includes {
+		if m.Namespace == "" {
+			m.Namespace = proxy.Namespace
+		}
+		if claimed[m] {
+			continue
+		}
+		claimed[m] = true
+		if p.orphaned[m] {
+			delete(p.orphaned, m)
+			p.dag.StatusCache.DiscardProxy(m)
+		}
+
+		if dest, ok := p.source.httpproxies[m]; ok && dest.Spec.VirtualHost == nil {
+			p.claimIncludes(dest, claimed)
+		}
+	}
 }
 
 // validHTTPProxies returns a slice of *contour_api_v1.HTTPProxy objects.
@@ -790,39 +1975,190 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(validCond *contour_api_v1.
 func (p *HTTPProxyProcessor) validHTTPProxies() []*contour_api_v1.HTTPProxy {
 	// ensure that a given fqdn is only referenced in a single HTTPProxy resource
 	var valid []*contour_api_v1.HTTPProxy
+	var roots []*contour_api_v1.HTTPProxy
 	fqdnHTTPProxies := make(map[string][]*contour_api_v1.HTTPProxy)
 	for _, proxy := range p.source.httpproxies {
 		if proxy.Spec.VirtualHost == nil {
 			valid = append(valid, proxy)
 			continue
 		}
-		fqdn := strings.ToLower(proxy.Spec.VirtualHost.Fqdn)
-		fqdnHTTPProxies[fqdn] = append(fqdnHTTPProxies[fqdn], proxy)
+		if !p.source.Shard.Matches(proxy) {
+			// The root belongs to another shard, which
+			// serves its virtual host and updates its status.
+			continue
+		}
+		roots = append(roots, proxy)
+		for _, fqdn := range virtualHostNames(proxy.Spec.VirtualHost) {
+			fqdnHTTPProxies[fqdn] = append(fqdnHTTPProxies[fqdn], proxy)
+		}
 	}
 
+	duplicated := make(map[*contour_api_v1.HTTPProxy]bool)
 	for fqdn, proxies := range fqdnHTTPProxies {
-		switch len(proxies) {
-		case 1:
-			valid = append(valid, proxies[0])
-		default:
-			// multiple proxies use the same fqdn. mark them as invalid.
-			var conflicting []string
-			for _, proxy := range proxies {
-				conflicting = append(conflicting, proxy.Namespace+"/"+proxy.Name)
+		if len(proxies) == 1 {
+			continue
+		}
+		// multiple proxies use the same fqdn. mark them as invalid.
+		var conflicting []string
+		for _, proxy := range proxies {
+			conflicting = append(conflicting, proxy.Namespace+"/"+proxy.Name)
+		}
+		sort.Strings(conflicting) // sort for test stability
+		msg := fmt.Sprintf("fqdn %q is used in multiple HTTPProxies: %s", fqdn, strings.Join(conflicting, ", "))
+		for _, proxy := range proxies {
+			duplicated[proxy] = true
+			pa, commit := p.dag.StatusCache.ProxyAccessor(proxy)
+			pa.Vhost = fqdn
+			pa.ConditionFor(status.ValidCondition).AddError(contour_api_v1.ConditionTypeVirtualHostError,
+				"DuplicateVhost",
+				msg)
+			commit()
+		}
+	}
+
+	for _, proxy := range roots {
+		if !duplicated[proxy] {
+			valid = append(valid, proxy)
+		}
+	}
+
+	// ensure that a given TCP listener port is only selected by a single HTTPProxy resource
+	portHTTPProxies := make(map[int][]*contour_api_v1.HTTPProxy)
+	for _, proxy := range valid {
+		if proxy.Spec.VirtualHost == nil || proxy.Spec.TCPProxy == nil || proxy.Spec.TCPProxy.Port == 0 {
+			continue
+		}
+		portHTTPProxies[proxy.Spec.TCPProxy.Port] = append(portHTTPProxies[proxy.Spec.TCPProxy.Port], proxy)
+	}
+
+	conflicted := make(map[*contour_api_v1.HTTPProxy]bool)
+	for port, proxies := range portHTTPProxies {
+		if len(proxies) == 1 {
+			continue
+		}
+		// multiple proxies select the same TCP listener port. mark them as invalid.
+		var conflicting []string
+		for _, proxy := range proxies {
+			conflicting = append(conflicting, proxy.Namespace+"/"+proxy.Name)
+		}
+		sort.Strings(conflicting) // sort for test stability
+		msg := fmt.Sprintf("Spec.TCPProxy.Port %d is used in multiple HTTPProxies: %s", port, strings.Join(conflicting, ", "))
+		for _, proxy := range proxies {
+			conflicted[proxy] = true
+			pa, commit := p.dag.StatusCache.ProxyAccessor(proxy)
+			pa.Vhost = strings.ToLower(proxy.Spec.VirtualHost.Fqdn)
+			pa.ConditionFor(status.ValidCondition).AddError(contour_api_v1.ConditionTypeTCPProxyError,
+				"DuplicatePort",
+				msg)
+			commit()
+		}
+	}
+
+	if len(conflicted) > 0 {
+		var unconflicted []*contour_api_v1.HTTPProxy
+		for _, proxy := range valid {
+			if !conflicted[proxy] {
+				unconflicted = append(unconflicted, proxy)
 			}
-			sort.Strings(conflicting) // sort for test stability
-			msg := fmt.Sprintf("fqdn %q is used in multiple HTTPProxies: %s", fqdn, strings.Join(conflicting, ", "))
-			for _, proxy := range proxies {
-				pa, commit := p.dag.StatusCache.ProxyAccessor(proxy)
-				pa.Vhost = fqdn
-				pa.ConditionFor(status.ValidCondition).AddError(contour_api_v1.ConditionTypeVirtualHostError,
-					"DuplicateVhost",
-					msg)
-				commit()
+		}
+		valid = unconflicted
+	}
+
+	return p.withinQuota(valid)
+}
+
+// withinQuota returns the proxies that fit within the quota of their
+// namespace, counting the oldest proxies first. The others are
+// excluded from the slice and their status updated accordingly.
+func (p *HTTPProxyProcessor) withinQuota(proxies []*contour_api_v1.HTTPProxy) []*contour_api_v1.HTTPProxy {
+	if p.DefaultNamespaceQuota == (NamespaceQuota{}) && len(p.NamespaceQuotas) == 0 {
+		return proxies
+	}
+
+	sorted := append([]*contour_api_v1.HTTPProxy(nil), proxies...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return k8s.NamespacedNameOf(a).String() < k8s.NamespacedNameOf(b).String()
+	})
+
+	used := map[string]NamespaceQuota{}
+	var within []*contour_api_v1.HTTPProxy
+	for _, proxy := range sorted {
+		quota, ok := p.NamespaceQuotas[proxy.Namespace]
+		if !ok {
+			quota = p.DefaultNamespaceQuota
+		}
+
+		use := used[proxy.Namespace]
+		if proxy.Spec.VirtualHost != nil {
+			use.VirtualHosts++
+		}
+		use.Routes += len(proxy.Spec.Routes)
+		use.Includes += len(proxy.Spec.Includes)
+
+		if limit := quota.exceededBy(use); limit != "" {
+			p.overQuota[k8s.NamespacedNameOf(proxy)] = true
+			pa, commit := p.dag.StatusCache.ProxyAccessor(proxy)
+			if proxy.Spec.VirtualHost != nil {
+				pa.Vhost = strings.ToLower(proxy.Spec.VirtualHost.Fqdn)
 			}
+			pa.ConditionFor(status.ValidCondition).AddErrorf(contour_api_v1.ConditionTypeQuotaError, "QuotaExceeded",
+				"namespace %q would exceed its quota of %s", proxy.Namespace, limit)
+			commit()
+			continue
+		}
+
+		used[proxy.Namespace] = use
+		within = append(within, proxy)
+	}
+
+	return within
+}
+
+// NamespaceQuota limits the routing configuration that the HTTPProxies
+// in a namespace may contribute. A limit of zero is unlimited.
+type NamespaceQuota struct {
+	// VirtualHosts is the maximum number of root HTTPProxies.
+	VirtualHosts int
+
+	// Routes is the maximum number of routes.
+	Routes int
+
+	// Includes is the maximum number of includes.
+	Includes int
+}
+
+// exceededBy returns the first limit of q that use exceeds,
+// e.g. "10 routes", or the empty string if it exceeds none.
+func (q NamespaceQuota) exceededBy(use NamespaceQuota) string {
+	switch {
+	case q.VirtualHosts > 0 && use.VirtualHosts > q.VirtualHosts:
+		return fmt.Sprintf("%d virtual hosts", q.VirtualHosts)
+	case q.Routes > 0 && use.Routes > q.Routes:
+		return fmt.Sprintf("%d routes", q.Routes)
+	case q.Includes > 0 && use.Includes > q.Includes:
+		return fmt.Sprintf("%d includes", q.Includes)
+	default:
+		return ""
+	}
+}
+
+// virtualHostNames returns the lower cased, de-duplicated set of names
+// served by the virtual host, starting with its fqdn.
+func virtualHostNames(vhost *contour_api_v1.VirtualHost) []string {
+	names := []string{strings.ToLower(vhost.Fqdn)}
+	seen := map[string]bool{names[0]: true}
+	for _, fqdn := range vhost.AdditionalFqdns {
+		fqdn = strings.ToLower(fqdn)
+		if !seen[fqdn] {
+			seen[fqdn] = true
+			names = append(names, fqdn)
 		}
 	}
-	return valid
+	return names
 }
 
 // rootAllowed returns true if the HTTPProxy lives in a permitted root namespace.
@@ -863,6 +2199,7 @@ func expandPrefixMatches(routes []*Route) []*Route {
 		// If there is no path prefix, we won't do any expansion, so skip it.
 		if !r.HasPathPrefix() {
 			expandedRoutes = append(expandedRoutes, r)
+			continue
 		}
 
 		routingPrefix := r.PathMatchCondition.(*PrefixMatchCondition).Prefix
@@ -929,6 +2266,32 @@ func expandPrefixMatches(routes []*Route) []*Route {
 	return expandedRoutes
 }
 
+// staticEndpoints returns the DAG endpoints of the given route service.
+// Endpoints without a port use the port of the service.
+func staticEndpoints(service contour_api_v1.Service) ([]StaticEndpoint, error) {
+	var endpoints []StaticEndpoint
+	for _, ep := range service.Endpoints {
+		if net.ParseIP(ep.Address) == nil {
+			return nil, fmt.Errorf("service %q: endpoint address %q is not an IP address", service.Name, ep.Address)
+		}
+
+		port := ep.Port
+		if port == 0 {
+			port = service.Port
+		}
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("service %q: endpoint port must be in the range 1-65535", service.Name)
+		}
+
+		endpoints = append(endpoints, StaticEndpoint{
+			Address: ep.Address,
+			Port:    int32(port),
+		})
+	}
+
+	return endpoints, nil
+}
+
 func getProtocol(service contour_api_v1.Service, s *Service) (string, error) {
 	// Determine the protocol to use to speak to this Cluster.
 	var protocol string
@@ -946,6 +2309,112 @@ func getProtocol(service contour_api_v1.Service, s *Service) (string, error) {
 	return protocol, nil
 }
 
+// contourPolicy returns the spec of the ContourPolicy
+// of the namespace, or nil if it has none.
+func (p *HTTPProxyProcessor) contourPolicy(namespace string) *contour_api_v1alpha1.ContourPolicySpec {
+	if policy := p.source.LookupContourPolicy(namespace); policy != nil {
+		return &policy.Spec
+	}
+	return nil
+}
+
+// contourPolicyLimitsValid returns an error if the timeout or retry
+// policy of a route exceeds the limits of the ContourPolicy spec.
+// Routes that use Envoy's default response timeout are not checked.
+func contourPolicyLimitsValid(policy *contour_api_v1alpha1.ContourPolicySpec, tp TimeoutPolicy, rp *RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MaxResponseTimeout != "" {
+		max, err := time.ParseDuration(policy.MaxResponseTimeout)
+		if err != nil {
+			return fmt.Errorf("maxResponseTimeout %q is not valid: %w", policy.MaxResponseTimeout, err)
+		}
+		if tp.ResponseTimeout.IsDisabled() || tp.ResponseTimeout.Duration() > max {
+			return fmt.Errorf("response timeout exceeds the maximum of %s", max)
+		}
+	}
+
+	if policy.MaxRetries > 0 && rp != nil && rp.NumRetries > policy.MaxRetries {
+		return fmt.Errorf("%d retries exceed the maximum of %d", rp.NumRetries, policy.MaxRetries)
+	}
+
+	return nil
+}
+
+// policyProtocol returns the ContourPolicy name of a cluster
+// protocol, in which plaintext HTTP/1.1 is named "http".
+func policyProtocol(protocol string) contour_api_v1alpha1.UpstreamProtocol {
+	if protocol == "" {
+		return contour_api_v1alpha1.UpstreamProtocolHTTP
+	}
+	return contour_api_v1alpha1.UpstreamProtocol(protocol)
+}
+
+// protocolPermitted returns true if the ContourPolicy of the
+// namespace permits services to be reached with protocol.
+func (p *HTTPProxyProcessor) protocolPermitted(namespace, protocol string) bool {
+	policy := p.contourPolicy(namespace)
+	if policy == nil || len(policy.AllowedProtocols) == 0 {
+		return true
+	}
+
+	for _, allowed := range policy.AllowedProtocols {
+		if allowed == policyProtocol(protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// getProxyProtocol returns the version of the PROXY protocol
+// to send to the service, or an empty string if none.
+func getProxyProtocol(service contour_api_v1.Service) (string, error) {
+	switch service.ProxyProtocol {
+	case "", "v1", "v2":
+		return service.ProxyProtocol, nil
+	default:
+		return "", fmt.Errorf("unsupported proxy protocol version: %v", service.ProxyProtocol)
+	}
+}
+
+// http2Settings returns the HTTP/2 settings for connections to the
+// service, or nil if none are set.
+func http2Settings(service contour_api_v1.Service, protocol string) (*HTTP2Settings, error) {
+	h := service.HTTP2
+	if h == nil {
+		return nil, nil
+	}
+
+	if protocol != "h2" && protocol != "h2c" {
+		return nil, fmt.Errorf("http2 settings require protocol h2 or h2c, got %q", protocol)
+	}
+
+	interval, err := timeout.Parse(h.KeepaliveInterval)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing keepalive interval: %s", err)
+	}
+	keepaliveTimeout, err := timeout.Parse(h.KeepaliveTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing keepalive timeout: %s", err)
+	}
+	if interval.UseDefault() != keepaliveTimeout.UseDefault() {
+		return nil, errors.New("keepaliveInterval and keepaliveTimeout must be set together")
+	}
+	if interval.IsDisabled() || keepaliveTimeout.IsDisabled() {
+		return nil, errors.New("keepalive interval and timeout cannot be disabled")
+	}
+
+	return &HTTP2Settings{
+		MaxConcurrentStreams:        h.MaxConcurrentStreams,
+		InitialStreamWindowSize:     h.InitialStreamWindowSize,
+		InitialConnectionWindowSize: h.InitialConnectionWindowSize,
+		KeepaliveInterval:           interval.Duration(),
+		KeepaliveTimeout:            keepaliveTimeout.Duration(),
+	}, nil
+}
+
 // determineSNI decides what the SNI should be on the request. It is configured via RequestHeadersPolicy.Host key.
 // Policies set on service are used before policies set on a route. Otherwise the value of the externalService
 // is used if the route is configured to proxy to an externalService type.
@@ -1003,7 +2472,7 @@ func includeMatchConditionsIdentical(includes []contour_api_v1.Include) bool {
 		// Now compare each include's set of conditions
 		for _, cA := range includes[i].Conditions {
 			for _, cB := range includes[j].Conditions {
-				if (cA.Prefix == cB.Prefix) && equality.Semantic.DeepEqual(cA.Header, cB.Header) {
+				if (cA.Prefix == cB.Prefix) && (cA.NotPrefix == cB.NotPrefix) && equality.Semantic.DeepEqual(cA.Header, cB.Header) && equality.Semantic.DeepEqual(cA.Geo, cB.Geo) {
 					return true
 				}
 			}
@@ -1013,12 +2482,304 @@ func includeMatchConditionsIdentical(includes []contour_api_v1.Include) bool {
 	return false
 }
 
+// includeNamespace returns the namespace of the include, defaulting
+// to the namespace of the including proxy.
+func includeNamespace(include contour_api_v1.Include, namespace string) string {
+	if include.Namespace != "" {
+		return include.Namespace
+	}
+	return namespace
+}
+
 // isBlank indicates if a string contains nothing but blank characters.
 func isBlank(s string) bool {
 	return len(strings.TrimSpace(s)) == 0
 }
 
 // routeEnforceTLS determines if the route should redirect the user to a secure TLS listener
+// weighted returns true if any of the services that are neither mirror
+// nor failover services has a weight.
+func weighted(services []contour_api_v1.Service) bool {
+	for _, service := range services {
+		if service.Weight > 0 && !service.Mirror && !service.Failover {
+			return true
+		}
+	}
+	return false
+}
+
+// permitInsecurePermitted returns true if the routes of the HTTPProxies
+// in namespace may use the permitInsecure field.
+func (p *HTTPProxyProcessor) permitInsecurePermitted(namespace string) bool {
+	if len(p.PermitInsecureNamespaces) == 0 && p.PermitInsecureSelector == nil {
+		return true
+	}
+
+	for _, ns := range p.PermitInsecureNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+
+	if p.PermitInsecureSelector == nil {
+		return false
+	}
+
+	ns, ok := p.source.namespaces[namespace]
+	return ok && p.PermitInsecureSelector.Matches(labels.Set(ns.Labels))
+}
+
+// luaScript returns the source code of the Lua script of policy, if any,
+// for an HTTPProxy in namespace. Lua scripts are only permitted in the
+// namespaces listed in LuaNamespaces.
+func (p *HTTPProxyProcessor) luaScript(namespace string, policy *contour_api_v1.LuaPolicy) (string, error) {
+	if policy == nil {
+		return "", nil
+	}
+
+	permitted := false
+	for _, ns := range p.LuaNamespaces {
+		if ns == namespace {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return "", fmt.Errorf("Lua scripts are not permitted in namespace %q by the Contour configuration", namespace)
+	}
+
+	switch {
+	case policy.InlineCode != "" && policy.ConfigMapRef != nil:
+		return "", errors.New("only one of inlineCode or configMapRef may be set")
+	case policy.InlineCode != "":
+		return policy.InlineCode, nil
+	case policy.ConfigMapRef != nil:
+		name := types.NamespacedName{Namespace: namespace, Name: policy.ConfigMapRef.Name}
+		configMap, ok := p.source.LookupConfigMap(name)
+		if !ok {
+			return "", fmt.Errorf("ConfigMap %q not found", name)
+		}
+		code := configMap.Data[policy.ConfigMapRef.Key]
+		if code == "" {
+			return "", fmt.Errorf("ConfigMap %q has no Lua script in key %q", name, policy.ConfigMapRef.Key)
+		}
+		return code, nil
+	default:
+		return "", errors.New("either inlineCode or configMapRef must be set")
+	}
+}
+
+// tapPolicy returns the tap policy of route, a route of proxy whose root
+// is rootProxy. It returns nil if the route has no tap policy, or if it
+// has expired, in which case a warning is added to validCond. Tap policies
+// are only permitted in the namespaces listed in TapNamespaces.
+func (p *HTTPProxyProcessor) tapPolicy(
+	validCond *contour_api_v1.DetailedCondition,
+	rootProxy *contour_api_v1.HTTPProxy,
+	proxy *contour_api_v1.HTTPProxy,
+	route *contour_api_v1.Route,
+) (*TapPolicy, bool) {
+	tap := route.TapPolicy
+	if tap == nil {
+		return nil, true
+	}
+
+	invalid := func(format string, args ...interface{}) (*TapPolicy, bool) {
+		validCond.AddErrorf(contour_api_v1.ConditionTypeRouteError, "TapPolicyInvalid",
+			"route.tapPolicy is invalid: "+format, args...)
+		return nil, false
+	}
+
+	permitted := false
+	for _, ns := range p.TapNamespaces {
+		if ns == proxy.Namespace {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return invalid("tap policies are not permitted in namespace %q by the Contour configuration", proxy.Namespace)
+	}
+
+	// Every tap is a filter of its own, so like Wasm modules,
+	// taps only run on virtual hosts that terminate TLS, which
+	// have their own HTTP connection manager.
+	tls := rootProxy.Spec.VirtualHost.TLS
+	if tls == nil || tls.Passthrough {
+		return invalid("tap policies require that Spec.VirtualHost.TLS.SecretName be set")
+	}
+	if tls.EnableFallbackCertificate {
+		return invalid("tap policies can't be combined with the fallback certificate")
+	}
+
+	var conds []contour_api_v1.MatchCondition
+	for i := range tap.Headers {
+		conds = append(conds, contour_api_v1.MatchCondition{Header: &tap.Headers[i]})
+	}
+	if err := headerMatchConditionsValid(conds); err != nil {
+		return invalid("%s", err)
+	}
+
+	// The index of the route tells its tap apart from the
+	// taps of the other routes of the HTTPProxy. Since names
+	// of Kubernetes objects can't contain underscores, the
+	// ID is unique.
+	index := 0
+	for i := range proxy.Spec.Routes {
+		if &proxy.Spec.Routes[i] == route {
+			index = i
+			break
+		}
+	}
+
+	policy := &TapPolicy{
+		ID:                    fmt.Sprintf("%s_%s_%d", proxy.Namespace, proxy.Name, index),
+		MaxRequests:           tap.MaxRequests,
+		HeaderMatchConditions: headerMatchConditions(tap.Headers),
+	}
+	if policy.MaxRequests == 0 {
+		policy.MaxRequests = 10
+	}
+
+	switch tap.Sink {
+	case "", "File":
+		if tap.ExtensionServiceRef != nil {
+			return invalid("extensionRef may only be set when the sink is GRPC")
+		}
+		dir := p.TapFileDirectory
+		if dir == "" {
+			dir = "/tmp"
+		}
+		policy.PathPrefix = path.Join(dir, policy.ID)
+	case "GRPC":
+		if tap.ExtensionServiceRef == nil {
+			return invalid("extensionRef must be set when the sink is GRPC")
+		}
+		ref := defaultExtensionRef(*tap.ExtensionServiceRef)
+		if ref.APIVersion != contour_api_v1alpha1.GroupVersion.String() {
+			return invalid("extensionRef specifies an unsupported resource version %q", ref.APIVersion)
+		}
+		extensionName := types.NamespacedName{
+			Name:      ref.Name,
+			Namespace: stringOrDefault(ref.Namespace, proxy.Namespace),
+		}
+		ext := p.extensionClusters[ExtensionClusterName(extensionName)]
+		if ext == nil {
+			return invalid("extension service %q not found", extensionName)
+		}
+		policy.Service = ext
+	default:
+		return invalid("unsupported sink %q", tap.Sink)
+	}
+
+	// An expired tap is dropped, rather than making the route
+	// invalid, so that it can be left in place after an incident.
+	// The DAG is rebuilt when the earliest tap expires.
+	if !tap.ExpiresAt.After(time.Now()) {
+		validCond.AddWarningf(contour_api_v1.ConditionTypeRouteError, "TapPolicyExpired",
+			"route.tapPolicy expired at %s and no longer captures requests", tap.ExpiresAt.UTC().Format(time.RFC3339))
+		return nil, true
+	}
+	p.dag.ExpireAt(tap.ExpiresAt.Time)
+
+	return policy, true
+}
+
+// wasmModules returns the Wasm modules that the virtual host of proxy
+// refers to. Modules whose code can't be loaded yet are reported with a
+// warning, and either left out or, unless their failure policy is
+// Ignore, returned without code so that requests fail in their place.
+func (p *HTTPProxyProcessor) wasmModules(validCond *contour_api_v1.DetailedCondition, proxy *contour_api_v1.HTTPProxy) ([]*WasmModule, bool) {
+	var modules []*WasmModule
+	seen := map[types.NamespacedName]bool{}
+
+	for _, ref := range proxy.Spec.VirtualHost.WasmModules {
+		name := types.NamespacedName{
+			Namespace: stringOrDefault(ref.Namespace, proxy.Namespace),
+			Name:      ref.Name,
+		}
+		if seen[name] {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "WasmModuleInvalid",
+				"Spec.VirtualHost.WasmModules refers to WasmModule %q more than once", name)
+			return nil, false
+		}
+		seen[name] = true
+
+		module, ok := p.source.LookupWasmModule(name)
+		if !ok {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "WasmModuleNotFound",
+				"Spec.VirtualHost.WasmModules WasmModule %q not found", name)
+			return nil, false
+		}
+
+		spec := module.Spec
+		if (spec.Image == "") == (spec.ConfigMapRef == nil) {
+			validCond.AddErrorf(contour_api_v1.ConditionTypeVirtualHostError, "WasmModuleInvalid",
+				"WasmModule %q is invalid: exactly one of image or configMapRef must be set", name)
+			return nil, false
+		}
+
+		m := &WasmModule{
+			Name:          name.String(),
+			RootID:        spec.RootID,
+			Configuration: spec.Configuration,
+			FailOpen:      spec.FailurePolicy == contour_api_v1alpha1.WasmFailurePolicyIgnore,
+		}
+
+		code, err := p.wasmCode(module)
+		if err != nil {
+			validCond.AddWarningf(contour_api_v1.ConditionTypeVirtualHostError, "WasmModuleNotReady",
+				"WasmModule %q is not ready: %s", name, err)
+			if m.FailOpen {
+				continue
+			}
+		}
+		m.Code = code
+
+		modules = append(modules, m)
+	}
+
+	return modules, true
+}
+
+// wasmCode returns the code of module, from its image or ConfigMap.
+func (p *HTTPProxyProcessor) wasmCode(module *contour_api_v1alpha1.WasmModule) ([]byte, error) {
+	spec := module.Spec
+
+	var code []byte
+	if spec.Image != "" {
+		if p.WasmImageFetcher == nil {
+			return nil, errors.New("images can't be pulled")
+		}
+		var err error
+		if code, err = p.WasmImageFetcher.Fetch(spec.Image); err != nil {
+			return nil, fmt.Errorf("image %q: %s", spec.Image, err)
+		}
+	} else {
+		name := types.NamespacedName{Namespace: module.Namespace, Name: spec.ConfigMapRef.Name}
+		configMap, ok := p.source.LookupConfigMap(name)
+		if !ok {
+			return nil, fmt.Errorf("ConfigMap %q not found", name)
+		}
+		code = configMap.BinaryData[spec.ConfigMapRef.Key]
+		if len(code) == 0 {
+			code = []byte(configMap.Data[spec.ConfigMapRef.Key])
+		}
+		if len(code) == 0 {
+			return nil, fmt.Errorf("ConfigMap %q has no Wasm module in key %q", name, spec.ConfigMapRef.Key)
+		}
+	}
+
+	if spec.SHA256 != "" {
+		sum := sha256.Sum256(code)
+		if digest := hex.EncodeToString(sum[:]); digest != spec.SHA256 {
+			return nil, fmt.Errorf("module has SHA-256 digest %s, not %s", digest, spec.SHA256)
+		}
+	}
+
+	return code, nil
+}
+
 func routeEnforceTLS(enforceTLS, permitInsecure bool) bool {
 	return enforceTLS && !permitInsecure
 }
@@ -38,6 +38,10 @@ type IngressProcessor struct {
 	// private key to be used when establishing TLS connection to upstream cluster.
 	ClientCertificate *types.NamespacedName
 
+	// SPIFFE is the optional SPIFFE identity used in place of ClientCertificate
+	// when establishing TLS connection to upstream cluster.
+	SPIFFE *SPIFFEIdentity
+
 	// EnableExternalNameService allows processing of ExternalNameServices
 	// This is normally disabled for security reasons.
 	// See https://github.com/projectcontour/contour/security/advisories/GHSA-5ph6-qq5x-7jwc for details.
@@ -67,6 +71,10 @@ func (p *IngressProcessor) Run(dag *DAG, source *KubernetesCache) {
 // secure virtual hosts.
 func (p *IngressProcessor) computeSecureVirtualhosts() {
 	for _, ing := range p.source.ingresses {
+		if !p.source.admitsIngress(ing) {
+			continue
+		}
+
 		for _, tls := range ing.Spec.TLS {
 			secretName := k8s.NamespacedNameFrom(tls.SecretName, k8s.DefaultNamespace(ing.GetNamespace()))
 			sec, err := p.source.LookupSecret(secretName, validSecret)
@@ -104,6 +112,9 @@ func (p *IngressProcessor) computeSecureVirtualhosts() {
 func (p *IngressProcessor) computeIngresses() {
 	// deconstruct each ingress into routes and virtualhost entries
 	for _, ing := range p.source.ingresses {
+		if !p.source.admitsIngress(ing) {
+			continue
+		}
 
 		// rewrite the default ingress to a stock ingress rule.
 		rules := rulesFromSpec(ing.Spec)
@@ -159,7 +170,7 @@ func (p *IngressProcessor) computeIngressRule(ing *networking_v1.Ingress, rule n
 			continue
 		}
 
-		r, err := route(ing, rule.Host, path, pathType, s, clientCertSecret, p.FieldLogger)
+		r, err := route(ing, rule.Host, path, pathType, s, clientCertSecret, p.SPIFFE, p.FieldLogger)
 		if err != nil {
 			p.WithError(err).
 				WithField("name", ing.GetName()).
@@ -189,7 +200,7 @@ const singleDNSLabelWildcardRegex = "^[a-z0-9]([-a-z0-9]*[a-z0-9])?"
 var _ = regexp.MustCompile(singleDNSLabelWildcardRegex)
 
 // route builds a dag.Route for the supplied Ingress.
-func route(ingress *networking_v1.Ingress, host string, path string, pathType networking_v1.PathType, service *Service, clientCertSecret *Secret, log logrus.FieldLogger) (*Route, error) {
+func route(ingress *networking_v1.Ingress, host string, path string, pathType networking_v1.PathType, service *Service, clientCertSecret *Secret, spiffe *SPIFFEIdentity, log logrus.FieldLogger) (*Route, error) {
 	log = log.WithFields(logrus.Fields{
 		"name":      ingress.Name,
 		"namespace": ingress.Namespace,
@@ -204,6 +215,7 @@ func route(ingress *networking_v1.Ingress, host string, path string, pathType ne
 			Upstream:          service,
 			Protocol:          service.Protocol,
 			ClientCertificate: clientCertSecret,
+			SPIFFE:            spiffe,
 		}},
 	}
 
@@ -0,0 +1,144 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// interner replaces Services, Clusters and Secrets in the DAG
+// with an identical one already seen, so that a service or secret
+// referenced by many routes is a single vertex.
+type interner struct {
+	secrets  map[*v1.Secret]*Secret
+	services map[serviceKey][]*Service
+	clusters map[clusterKey][]*Cluster
+}
+
+// serviceKey holds the fields of a Service that are cheap to
+// compare. Services with the same key are compared in full.
+type serviceKey struct {
+	namespace, name string
+	port            int32
+}
+
+// clusterKey holds the fields of a Cluster that are cheap to
+// compare. Clusters with the same key are compared in full.
+type clusterKey struct {
+	upstream *Service
+	weight   uint32
+	protocol string
+	sni      string
+}
+
+// intern interns the Services, Clusters and Secrets of the DAG.
+func intern(dag *DAG) {
+	in := &interner{
+		secrets:  map[*v1.Secret]*Secret{},
+		services: map[serviceKey][]*Service{},
+		clusters: map[clusterKey][]*Cluster{},
+	}
+	dag.Visit(in.visit)
+}
+
+func (in *interner) visit(vertex Vertex) {
+	switch v := vertex.(type) {
+	case *SecureVirtualHost:
+		v.Secret = in.secret(v.Secret)
+		v.FallbackCertificate = in.secret(v.FallbackCertificate)
+		if v.DownstreamValidation != nil {
+			v.DownstreamValidation.CACertificate = in.secret(v.DownstreamValidation.CACertificate)
+		}
+	case *Route:
+		for i := range v.Clusters {
+			v.Clusters[i] = in.cluster(v.Clusters[i])
+		}
+		if v.MirrorPolicy != nil {
+			v.MirrorPolicy.Cluster = in.cluster(v.MirrorPolicy.Cluster)
+		}
+	case *TCPProxy:
+		for i := range v.Clusters {
+			v.Clusters[i] = in.cluster(v.Clusters[i])
+		}
+	}
+
+	vertex.Visit(in.visit)
+}
+
+// secret returns the first Secret seen for the same
+// Kubernetes Secret as s, or s if it is the first.
+func (in *interner) secret(s *Secret) *Secret {
+	if s == nil {
+		return nil
+	}
+	if seen, ok := in.secrets[s.Object]; ok {
+		return seen
+	}
+	in.secrets[s.Object] = s
+	return s
+}
+
+// service returns the first Service seen that is
+// identical to s, or s if it is the first.
+func (in *interner) service(s *Service) *Service {
+	if s == nil {
+		return nil
+	}
+
+	key := serviceKey{
+		namespace: s.Weighted.ServiceNamespace,
+		name:      s.Weighted.ServiceName,
+		port:      s.Weighted.ServicePort.Port,
+	}
+	for _, seen := range in.services[key] {
+		if seen == s || reflect.DeepEqual(seen, s) {
+			return seen
+		}
+	}
+	in.services[key] = append(in.services[key], s)
+	return s
+}
+
+// cluster returns the first Cluster seen that is
+// identical to c, or c if it is the first.
+func (in *interner) cluster(c *Cluster) *Cluster {
+	if c == nil {
+		return nil
+	}
+
+	c.Upstream = in.service(c.Upstream)
+	for i := range c.Failover {
+		c.Failover[i] = in.service(c.Failover[i])
+	}
+	c.ClientCertificate = in.secret(c.ClientCertificate)
+	if c.UpstreamValidation != nil {
+		c.UpstreamValidation.CACertificate = in.secret(c.UpstreamValidation.CACertificate)
+	}
+
+	key := clusterKey{
+		upstream: c.Upstream,
+		weight:   c.Weight,
+		protocol: c.Protocol,
+		sni:      c.SNI,
+	}
+	for _, seen := range in.clusters[key] {
+		if seen == c || reflect.DeepEqual(seen, c) {
+			return seen
+		}
+	}
+	in.clusters[key] = append(in.clusters[key], c)
+	return c
+}
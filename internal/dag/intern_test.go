@@ -0,0 +1,114 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInternClustersAndSecrets(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	proxy := func(name, fqdn string, lbPolicy string) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: fqdn,
+					TLS: &contour_api_v1.TLS{
+						SecretName: sec1.Name,
+					},
+				},
+				Routes: []contour_api_v1.Route{{
+					LoadBalancerPolicy: &contour_api_v1.LoadBalancerPolicy{
+						Strategy: lbPolicy,
+					},
+					Services: []contour_api_v1.Service{{
+						Name: service.Name,
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+	}
+
+	builder := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&HTTPProxyProcessor{},
+			&ListenerProcessor{},
+		},
+	}
+	for _, o := range []interface{}{
+		service,
+		sec1,
+		proxy("a", "a.example.com", "RoundRobin"),
+		proxy("b", "b.example.com", "RoundRobin"),
+		proxy("c", "c.example.com", "Random"),
+	} {
+		builder.Source.Insert(o)
+	}
+	dag := builder.Build()
+
+	clusterOf := func(fqdn string) *Cluster {
+		vh := dag.GetVirtualHost(ListenerName{Name: fqdn, ListenerName: "ingress_http"})
+		require.NotNil(t, vh)
+		require.Len(t, vh.routes, 1)
+		for _, r := range vh.routes {
+			require.Len(t, r.Clusters, 1)
+			return r.Clusters[0]
+		}
+		return nil
+	}
+
+	secretOf := func(fqdn string) *Secret {
+		svh := dag.GetSecureVirtualHost(ListenerName{Name: fqdn, ListenerName: "ingress_https"})
+		require.NotNil(t, svh)
+		return svh.Secret
+	}
+
+	// Identical clusters are the same vertex.
+	assert.Same(t, clusterOf("a.example.com"), clusterOf("b.example.com"))
+
+	// Clusters that differ are not.
+	assert.NotSame(t, clusterOf("a.example.com"), clusterOf("c.example.com"))
+
+	// The same Secret is a single vertex.
+	assert.Same(t, secretOf("a.example.com"), secretOf("b.example.com"))
+	assert.Same(t, secretOf("a.example.com"), secretOf("c.example.com"))
+}
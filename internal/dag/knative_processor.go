@@ -0,0 +1,256 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"sort"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/annotation"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	"github.com/projectcontour/contour/internal/status"
+	"github.com/projectcontour/contour/internal/timeout"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// KnativeIngressProcessor translates Knative Ingresses into DAG
+// objects and adds them to the DAG.
+type KnativeIngressProcessor struct {
+	logrus.FieldLogger
+
+	dag    *DAG
+	source *KubernetesCache
+
+	// LoadBalancerDomain is the cluster-local DNS name of the
+	// Envoy service. It is reported as the load balancer of
+	// each Knative Ingress that is programmed successfully.
+	LoadBalancerDomain string
+
+	// ClientCertificate is the optional identifier of the TLS secret containing client certificate and
+	// private key to be used when establishing TLS connection to upstream cluster.
+	ClientCertificate *types.NamespacedName
+
+	// SPIFFE is the optional SPIFFE identity used in place of ClientCertificate
+	// when establishing TLS connection to upstream cluster.
+	SPIFFE *SPIFFEIdentity
+
+	// EnableExternalNameService allows processing of ExternalNameServices
+	// This is normally disabled for security reasons.
+	// See https://github.com/projectcontour/contour/security/advisories/GHSA-5ph6-qq5x-7jwc for details.
+	EnableExternalNameService bool
+}
+
+// knativeRoute is a route computed from a Knative Ingress path
+// along with the hosts it should be added to.
+type knativeRoute struct {
+	hosts []string
+	route *Route
+}
+
+// Run translates Knative Ingresses into DAG objects and
+// adds them to the DAG.
+func (p *KnativeIngressProcessor) Run(dag *DAG, source *KubernetesCache) {
+	p.dag = dag
+	p.source = source
+
+	// reset the processor when we're done
+	defer func() {
+		p.dag = nil
+		p.source = nil
+	}()
+
+	for _, ing := range p.source.kingresses {
+		p.computeKnativeIngress(ing)
+	}
+}
+
+// computeKnativeIngress programs the supplied Knative Ingress and
+// records the outcome in its status.
+func (p *KnativeIngressProcessor) computeKnativeIngress(ing *knative_v1alpha1.Ingress) {
+	ingStatus, commit := status.KnativeIngressAccessor(&p.dag.StatusCache, ing)
+	defer commit()
+
+	conditions := []*contour_api_v1.DetailedCondition{
+		ingStatus.ConditionFor(status.ConditionType(knative_v1alpha1.IngressConditionReady)),
+		ingStatus.ConditionFor(status.ConditionType(knative_v1alpha1.IngressConditionNetworkConfigured)),
+		ingStatus.ConditionFor(status.ConditionType(knative_v1alpha1.IngressConditionLoadBalancerReady)),
+	}
+
+	if reason, err := p.computeIngress(ing); err != nil {
+		p.WithError(err).
+			WithField("name", ing.GetName()).
+			WithField("namespace", ing.GetNamespace()).
+			Error("invalid Knative Ingress")
+
+		for _, cond := range conditions {
+			cond.Status = contour_api_v1.ConditionFalse
+			cond.Reason = reason
+			cond.Message = err.Error()
+		}
+		return
+	}
+
+	for _, cond := range conditions {
+		cond.Status = contour_api_v1.ConditionTrue
+		cond.Reason = ""
+		cond.Message = ""
+	}
+
+	ingStatus.LoadBalancer = &knative_v1alpha1.LoadBalancerStatus{
+		Ingress: []knative_v1alpha1.LoadBalancerIngressStatus{{
+			DomainInternal: p.LoadBalancerDomain,
+		}},
+	}
+}
+
+// computeIngress adds the virtual hosts and routes of the supplied
+// Knative Ingress to the DAG. Nothing is added if any part of the
+// Ingress is invalid, in which case the reason and error are returned.
+func (p *KnativeIngressProcessor) computeIngress(ing *knative_v1alpha1.Ingress) (string, error) {
+	secrets := map[string]*Secret{}
+	for _, tls := range ing.Spec.TLS {
+		secretName := types.NamespacedName{
+			Namespace: stringOrDefault(tls.SecretNamespace, ing.GetNamespace()),
+			Name:      tls.SecretName,
+		}
+
+		sec, err := p.source.LookupSecret(secretName, validSecret)
+		if err != nil {
+			return "SecretInvalid", fmt.Errorf("Secret %q is invalid: %s", secretName, err)
+		}
+
+		if !p.source.DelegationPermitted(secretName, ing.GetNamespace()) {
+			return "SecretNotDelegated", fmt.Errorf("Secret %q certificate delegation not permitted", secretName)
+		}
+
+		for _, host := range tls.Hosts {
+			secrets[host] = sec
+		}
+	}
+
+	var clientCertSecret *Secret
+	if p.ClientCertificate != nil {
+		var err error
+		clientCertSecret, err = p.source.LookupSecret(*p.ClientCertificate, validSecret)
+		if err != nil {
+			return "SecretInvalid", fmt.Errorf("tls.envoy-client-certificate Secret %q is invalid: %s", p.ClientCertificate, err)
+		}
+	}
+
+	var routes []knativeRoute
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			r, err := p.route(ing, path, clientCertSecret)
+			if err != nil {
+				return "ServiceInvalid", err
+			}
+
+			// Cluster-local traffic is never redirected since it
+			// does not use the external TLS hosts.
+			r.HTTPSUpgrade = ing.Spec.HTTPOption == knative_v1alpha1.HTTPOptionRedirected &&
+				rule.Visibility != knative_v1alpha1.IngressVisibilityClusterLocal
+
+			routes = append(routes, knativeRoute{hosts: rule.Hosts, route: r})
+		}
+	}
+
+	for _, kr := range routes {
+		for _, host := range kr.hosts {
+			vhost := p.dag.EnsureVirtualHost(ListenerName{Name: host, ListenerName: "ingress_http"})
+			vhost.addRoute(kr.route)
+
+			if sec, ok := secrets[host]; ok {
+				svhost := p.dag.EnsureSecureVirtualHost(ListenerName{Name: host, ListenerName: "ingress_https"})
+				svhost.Secret = sec
+				svhost.MinTLSVersion = annotation.MinTLSVersion("", "1.2")
+				svhost.addRoute(kr.route)
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// route builds a dag.Route for the supplied Knative Ingress path.
+func (p *KnativeIngressProcessor) route(ing *knative_v1alpha1.Ingress, path knative_v1alpha1.HTTPIngressPath, clientCertSecret *Secret) (*Route, error) {
+	r := &Route{
+		PathMatchCondition: &PrefixMatchCondition{
+			Prefix:          stringOrDefault(path.Path, "/"),
+			PrefixMatchType: PrefixMatchString,
+		},
+		Websocket: true,
+		// Knative enforces request timeouts in its own data
+		// plane, so Envoy should not time out responses.
+		TimeoutPolicy: TimeoutPolicy{
+			ResponseTimeout: timeout.DisabledSetting(),
+		},
+	}
+
+	// Header matches select a revision tag, so sort them to
+	// keep the route conditions stable across rebuilds.
+	var headers []string
+	for name := range path.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		r.HeaderMatchConditions = append(r.HeaderMatchConditions, HeaderMatchCondition{
+			Name:      name,
+			Value:     path.Headers[name].Exact,
+			MatchType: HeaderMatchTypeExact,
+		})
+	}
+
+	if len(path.AppendHeaders) > 0 || path.RewriteHost != "" {
+		r.RequestHeadersPolicy = &HeadersPolicy{
+			HostRewrite: path.RewriteHost,
+			Set:         path.AppendHeaders,
+		}
+	}
+
+	for _, split := range path.Splits {
+		m := types.NamespacedName{
+			Namespace: stringOrDefault(split.ServiceNamespace, ing.GetNamespace()),
+			Name:      split.ServiceName,
+		}
+
+		s, err := p.dag.EnsureService(m, split.ServicePort, p.source, p.EnableExternalNameService)
+		if err != nil {
+			return nil, fmt.Errorf("Service %q is invalid: %s", m, err)
+		}
+
+		c := &Cluster{
+			Upstream:          s,
+			Protocol:          s.Protocol,
+			Weight:            uint32(split.Percent),
+			ClientCertificate: clientCertSecret,
+			SPIFFE:            p.SPIFFE,
+		}
+		if len(split.AppendHeaders) > 0 {
+			c.RequestHeadersPolicy = &HeadersPolicy{
+				Set: split.AppendHeaders,
+			}
+		}
+
+		r.Clusters = append(r.Clusters, c)
+	}
+
+	return r, nil
+}
@@ -13,19 +13,30 @@
 
 package dag
 
-import "sort"
+import (
+	"sort"
+
+	"github.com/projectcontour/contour/pkg/config"
+)
 
 // ListenerProcessor adds an HTTP and an HTTPS listener to
 // the DAG if there are virtual hosts and secure virtual
-// hosts already defined as roots in the DAG.
-type ListenerProcessor struct{}
+// hosts already defined as roots in the DAG, and a TCP
+// listener for each TCP virtual host.
+type ListenerProcessor struct {
+	// InsecureListener sets what the default HTTP listener
+	// serves. If empty, it serves the virtual hosts as they
+	// are configured.
+	InsecureListener config.InsecureListenerMode
+}
 
 // Run adds HTTP and HTTPS listeners to the DAG if there are
 // virtual hosts and secure virtual hosts already defined as
-// roots in the DAG.
+// roots in the DAG, and a TCP listener for each TCP virtual host.
 func (p *ListenerProcessor) Run(dag *DAG, _ *KubernetesCache) {
 	p.buildHTTPListener(dag)
 	p.buildHTTPSListener(dag)
+	p.buildTCPListeners(dag)
 }
 
 // buildHTTPListener builds a *dag.Listener for the vhosts bound to port 80.
@@ -35,12 +46,41 @@ func (p *ListenerProcessor) buildHTTPListener(dag *DAG) {
 	var virtualhosts []Vertex
 	var remove []Vertex
 
+	// secure holds the names of the valid secure virtual
+	// hosts of the default HTTPS listener.
+	secure := map[string]bool{}
+	for _, root := range dag.roots {
+		if svh, ok := root.(*SecureVirtualHost); ok && defaultListener(svh.ListenerName, "ingress_https") && svh.Valid() {
+			secure[svh.Name] = true
+		}
+	}
+
 	for _, root := range dag.roots {
 		switch obj := root.(type) {
 		case *VirtualHost:
 			remove = append(remove, obj)
 
-			if obj.Valid() {
+			if !obj.Valid() {
+				continue
+			}
+
+			// Only the virtual hosts of the default HTTP
+			// listener are subject to the insecure mode.
+			if !defaultListener(obj.ListenerName, "ingress_http") {
+				virtualhosts = append(virtualhosts, obj)
+				continue
+			}
+
+			switch p.InsecureListener {
+			case config.InsecureListenerDisabled:
+				// Nothing is served in plaintext.
+			case config.InsecureListenerRedirect:
+				// Plaintext requests to hosts that are
+				// not served over TLS are not served.
+				if secure[obj.Name] {
+					virtualhosts = append(virtualhosts, redirectVirtualHost(obj))
+				}
+			default:
 				virtualhosts = append(virtualhosts, obj)
 			}
 		}
@@ -67,6 +107,26 @@ func (p *ListenerProcessor) buildHTTPListener(dag *DAG) {
 	dag.AddRoot(http)
 }
 
+// defaultListener returns true if the virtual hosts whose listener
+// name is name are served by the default listener named def.
+func defaultListener(name, def string) bool {
+	return name == "" || name == def
+}
+
+// redirectVirtualHost returns a virtual host in place of vh
+// that redirects all of its requests to HTTPS.
+func redirectVirtualHost(vh *VirtualHost) *VirtualHost {
+	redirect := &VirtualHost{
+		Name:         vh.Name,
+		ListenerName: vh.ListenerName,
+	}
+	redirect.addRoute(&Route{
+		PathMatchCondition: &PrefixMatchCondition{Prefix: "/"},
+		HTTPSUpgrade:       true,
+	})
+	return redirect
+}
+
 // buildHTTPSListener builds a *dag.Listener for the vhosts bound to port 443.
 // The list of virtual hosts will attached to the listener will be sorted
 // by hostname.
@@ -105,3 +165,38 @@ func (p *ListenerProcessor) buildHTTPSListener(dag *DAG) {
 
 	dag.AddRoot(https)
 }
+
+// buildTCPListeners builds a *dag.Listener for each TCP virtual host,
+// bound to the virtual host's port. The listeners are added to the DAG
+// sorted by port.
+func (p *ListenerProcessor) buildTCPListeners(dag *DAG) {
+	var virtualhosts []*TCPVirtualHost
+	var remove []Vertex
+
+	for _, root := range dag.roots {
+		switch obj := root.(type) {
+		case *TCPVirtualHost:
+			remove = append(remove, obj)
+
+			if obj.Valid() {
+				virtualhosts = append(virtualhosts, obj)
+			}
+		}
+	}
+
+	// Update the DAG's roots to not include TCP virtual hosts.
+	for _, r := range remove {
+		dag.RemoveRoot(r)
+	}
+
+	sort.SliceStable(virtualhosts, func(i, j int) bool {
+		return virtualhosts[i].Port < virtualhosts[j].Port
+	})
+
+	for _, vh := range virtualhosts {
+		dag.AddRoot(&Listener{
+			Port:         vh.Port,
+			VirtualHosts: []Vertex{vh},
+		})
+	}
+}
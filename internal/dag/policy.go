@@ -14,12 +14,19 @@
 package dag
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"regexp"
+	"regexp/syntax"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	networking_v1 "k8s.io/api/networking/v1"
 	gatewayapi_v1alpha1 "sigs.k8s.io/gateway-api/apis/v1alpha1"
@@ -92,7 +99,129 @@ func retryPolicy(rp *contour_api_v1.RetryPolicy) *RetryPolicy {
 	}
 }
 
+// hedgePolicy returns the HedgePolicy of a route with the given
+// RetryPolicy. Hedging on per-try timeouts requires a per-try timeout.
+func hedgePolicy(hp *contour_api_v1.HedgePolicy, rp *RetryPolicy) (*HedgePolicy, error) {
+	if hp == nil {
+		return nil, nil
+	}
+
+	if hp.HedgeOnPerTryTimeout && (rp == nil || rp.PerTryTimeout.UseDefault() || rp.PerTryTimeout.IsDisabled()) {
+		return nil, errors.New("hedgeOnPerTryTimeout requires a retry policy with a perTryTimeout")
+	}
+
+	return &HedgePolicy{
+		InitialRequests:      hp.InitialRequests,
+		HedgeOnPerTryTimeout: hp.HedgeOnPerTryTimeout,
+	}, nil
+}
+
+// tracingPolicy returns the TracingPolicy of a route. Each custom tag
+// must have a unique name and exactly one of a literal value or a
+// request header name.
+func tracingPolicy(tp *contour_api_v1.TracingPolicy) (*TracingPolicy, error) {
+	if tp == nil {
+		return nil, nil
+	}
+
+	policy := &TracingPolicy{
+		SamplingPercentage: tp.SamplingPercentage,
+	}
+
+	names := sets.NewString()
+	for _, tag := range tp.CustomTags {
+		if tag.TagName == "" {
+			return nil, errors.New("custom tag name must be specified")
+		}
+		if names.Has(tag.TagName) {
+			return nil, fmt.Errorf("duplicate custom tag %q", tag.TagName)
+		}
+		names.Insert(tag.TagName)
+
+		if (tag.Literal == "") == (tag.RequestHeaderName == "") {
+			return nil, fmt.Errorf("custom tag %q must specify exactly one of literal or requestHeaderName", tag.TagName)
+		}
+		if tag.RequestHeaderName != "" {
+			if msgs := validation.IsHTTPHeaderName(tag.RequestHeaderName); len(msgs) != 0 {
+				return nil, fmt.Errorf("invalid request header name %q for custom tag %q: %s", tag.RequestHeaderName, tag.TagName, strings.Join(msgs, ","))
+			}
+		}
+
+		policy.CustomTags = append(policy.CustomTags, TracingCustomTag{
+			TagName:           tag.TagName,
+			Literal:           tag.Literal,
+			RequestHeaderName: tag.RequestHeaderName,
+		})
+	}
+
+	return policy, nil
+}
+
+// accessLogSampling returns the percentage of requests to log for
+// the given policy, or nil if every request should be logged.
+func accessLogSampling(policy *contour_api_v1.AccessLogPolicy) *uint32 {
+	switch {
+	case policy == nil:
+		return nil
+	case policy.Disabled:
+		none := uint32(0)
+		return &none
+	case policy.SamplingPercentage == nil || *policy.SamplingPercentage >= 100:
+		return nil
+	default:
+		percentage := *policy.SamplingPercentage
+		return &percentage
+	}
+}
+
+// requestIDHeader returns the name of the header whose value is used
+// as the x-request-id of requests, or "" if none is set.
+func requestIDHeader(policy *contour_api_v1.RequestIDPolicy) (string, error) {
+	if policy == nil || policy.Header == "" {
+		return "", nil
+	}
+
+	if msgs := validation.IsHTTPHeaderName(policy.Header); len(msgs) != 0 {
+		return "", fmt.Errorf("invalid header name %q: %s", policy.Header, strings.Join(msgs, ","))
+	}
+	if strings.EqualFold(policy.Header, "x-request-id") {
+		return "", errors.New("header must not be x-request-id")
+	}
+
+	return policy.Header, nil
+}
+
+// clientCertificateHeaders returns the names of the request headers
+// that are set to fields of the client certificate, mapped to their
+// fields, or nil if there are none.
+func clientCertificateHeaders(headers []contour_api_v1.ClientCertificateHeader) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	fields := make(map[string]string, len(headers))
+	for _, h := range headers {
+		key := http.CanonicalHeaderKey(h.Name)
+		if msgs := validation.IsHTTPHeaderName(key); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid certificate header name %q: %s", h.Name, strings.Join(msgs, ","))
+		}
+		if _, ok := fields[key]; ok {
+			return nil, fmt.Errorf("duplicate certificate header %q", h.Name)
+		}
+		switch h.Field {
+		case "SubjectAltName", "Subject", "Issuer", "Serial", "SHA256":
+		default:
+			return nil, fmt.Errorf("invalid field %q of certificate header %q", h.Field, h.Name)
+		}
+		fields[key] = h.Field
+	}
+	return fields, nil
+}
+
 func headersPolicyService(defaultPolicy *HeadersPolicy, policy *contour_api_v1.HeadersPolicy, dynamicHeaders map[string]string) (*HeadersPolicy, error) {
+	if policy != nil && len(policy.RemoveMatching) > 0 {
+		return nil, errors.New("removing headers that match their names is not supported on services")
+	}
 	if defaultPolicy == nil {
 		return headersPolicyRoute(policy, false, dynamicHeaders)
 	}
@@ -116,7 +245,10 @@ func headersPolicyService(defaultPolicy *HeadersPolicy, policy *contour_api_v1.H
 			return nil, fmt.Errorf("invalid set header %q: %v", key, msgs)
 		}
 		// if the user policy set on the object does not contain this header then use the default
-		if _, exists := userPolicy.Set[key]; !exists {
+		if _, exists := userPolicy.Set[key]; exists {
+			continue
+		}
+		if _, exists := userPolicy.Add[key]; !exists {
 			userPolicy.Set[key] = escapeHeaderValue(v, dynamicHeaders)
 		}
 	}
@@ -143,24 +275,34 @@ func headersPolicyRoute(policy *contour_api_v1.HeadersPolicy, allowHostRewrite b
 		return nil, nil
 	}
 
-	set := make(map[string]string, len(policy.Set))
+	set, add := make(map[string]string, len(policy.Set)), make(map[string]string)
 	hostRewrite := ""
 	for _, entry := range policy.Set {
 		key := http.CanonicalHeaderKey(entry.Name)
 		if _, ok := set[key]; ok {
 			return nil, fmt.Errorf("duplicate header addition: %q", key)
 		}
+		if _, ok := add[key]; ok {
+			return nil, fmt.Errorf("duplicate header addition: %q", key)
+		}
 		if key == "Host" {
 			if !allowHostRewrite {
 				return nil, fmt.Errorf("rewriting %q header is not supported", key)
 			}
+			if entry.Append {
+				return nil, fmt.Errorf("appending to %q header is not supported", key)
+			}
 			hostRewrite = entry.Value
 			continue
 		}
 		if msgs := validation.IsHTTPHeaderName(key); len(msgs) != 0 {
 			return nil, fmt.Errorf("invalid set header %q: %v", key, msgs)
 		}
-		set[key] = escapeHeaderValue(entry.Value, dynamicHeaders)
+		if entry.Append {
+			add[key] = escapeHeaderValue(entry.Value, dynamicHeaders)
+		} else {
+			set[key] = escapeHeaderValue(entry.Value, dynamicHeaders)
+		}
 	}
 
 	remove := sets.NewString()
@@ -176,20 +318,184 @@ func headersPolicyRoute(policy *contour_api_v1.HeadersPolicy, allowHostRewrite b
 	}
 	rl := remove.List()
 
+	removeMatching, err := headerNameMatches(policy.RemoveMatching)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(set) == 0 {
 		set = nil
 	}
+	if len(add) == 0 {
+		add = nil
+	}
 	if len(rl) == 0 {
 		rl = nil
 	}
 
 	return &HeadersPolicy{
-		Set:         set,
-		HostRewrite: hostRewrite,
-		Remove:      rl,
+		Set:            set,
+		Add:            add,
+		HostRewrite:    hostRewrite,
+		Remove:         rl,
+		RemoveMatching: removeMatching,
 	}, nil
 }
 
+// headerNameMatches validates the matches of the names of the headers
+// to remove, and translates their regular expressions to Lua patterns.
+func headerNameMatches(matches []contour_api_v1.HeaderNameMatch) ([]HeaderNameMatch, error) {
+	var hnm []HeaderNameMatch
+	for _, match := range matches {
+		switch {
+		case match.Prefix != "" && match.Regex != "":
+			return nil, fmt.Errorf("header name match has both a prefix %q and a regex %q", match.Prefix, match.Regex)
+		case match.Prefix != "":
+			if msgs := validation.IsHTTPHeaderName(match.Prefix); len(msgs) != 0 {
+				return nil, fmt.Errorf("invalid remove header prefix %q: %v", match.Prefix, msgs)
+			}
+			hnm = append(hnm, HeaderNameMatch{Prefix: strings.ToLower(match.Prefix)})
+		case match.Regex != "":
+			pattern, err := luaPattern(match.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid remove header regex %q: %v", match.Regex, err)
+			}
+			hnm = append(hnm, HeaderNameMatch{Pattern: pattern})
+		default:
+			return nil, errors.New("header name match has neither a prefix nor a regex")
+		}
+	}
+	return hnm, nil
+}
+
+// luaPattern translates a regular expression without groups, alternations
+// or repetition counts to the equivalent Lua pattern, ignoring case. Since
+// Envoy keeps header names in lower case, the pattern only matches lower
+// case letters.
+func luaPattern(regex string) (string, error) {
+	re, err := syntax.Parse(regex, syntax.Perl|syntax.FoldCase)
+	if err != nil {
+		return "", err
+	}
+
+	items := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		items = re.Sub
+	}
+
+	var pattern strings.Builder
+	for i, item := range items {
+		switch item.Op {
+		case syntax.OpBeginText:
+			if i != 0 {
+				return "", errors.New("^ is only supported at the start")
+			}
+			pattern.WriteString("^")
+		case syntax.OpEndText:
+			if i != len(items)-1 {
+				return "", errors.New("$ is only supported at the end")
+			}
+			pattern.WriteString("$")
+		case syntax.OpLiteral:
+			for _, r := range item.Rune {
+				if err := writeLuaPatternClass(&pattern, []rune{unicode.ToLower(r), unicode.ToLower(r)}); err != nil {
+					return "", err
+				}
+			}
+		case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+			if err := writeLuaPatternItem(&pattern, item.Sub[0]); err != nil {
+				return "", err
+			}
+			pattern.WriteString(map[syntax.Op]string{
+				syntax.OpStar:  "*",
+				syntax.OpPlus:  "+",
+				syntax.OpQuest: "?",
+			}[item.Op])
+		default:
+			if err := writeLuaPatternItem(&pattern, item); err != nil {
+				return "", err
+			}
+		}
+	}
+	return pattern.String(), nil
+}
+
+// writeLuaPatternItem writes the Lua pattern of a regular expression
+// that matches a single character.
+func writeLuaPatternItem(pattern *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		pattern.WriteString(".")
+		return nil
+	case syntax.OpLiteral:
+		if len(re.Rune) != 1 {
+			return errors.New("repetition of more than one character is not supported")
+		}
+		r := unicode.ToLower(re.Rune[0])
+		return writeLuaPatternClass(pattern, []rune{r, r})
+	case syntax.OpCharClass:
+		return writeLuaPatternClass(pattern, re.Rune)
+	case syntax.OpCapture:
+		return errors.New("groups are not supported")
+	case syntax.OpAlternate:
+		return errors.New("alternations are not supported")
+	case syntax.OpRepeat:
+		return errors.New("repetition counts are not supported")
+	default:
+		return fmt.Errorf("%s is not supported", re)
+	}
+}
+
+// writeLuaPatternClass writes the Lua pattern of the characters in
+// ranges, which are pairs of the first and last characters of each
+// range. Only the characters that are valid in lower case header names
+// are kept.
+func writeLuaPatternClass(pattern *strings.Builder, ranges []rune) error {
+	var chars []byte
+	for i := 0; i < len(ranges); i += 2 {
+		for r := ranges[i]; r <= ranges[i+1] && r <= '~'; r++ {
+			if r > ' ' && !unicode.IsUpper(r) {
+				chars = append(chars, byte(r))
+			}
+		}
+	}
+
+	alnum := func(c byte) bool {
+		return ('a' <= c && c <= 'z') || ('0' <= c && c <= '9')
+	}
+	escape := func(c byte) string {
+		if alnum(c) {
+			return string(c)
+		}
+		return "%" + string(c)
+	}
+
+	switch len(chars) {
+	case 0:
+		return errors.New("no characters that are valid in header names are matched")
+	case 1:
+		pattern.WriteString(escape(chars[0]))
+		return nil
+	}
+
+	pattern.WriteString("[")
+	for i := 0; i < len(chars); i++ {
+		// Write runs of letters or digits as ranges.
+		j := i
+		for alnum(chars[i]) && j+1 < len(chars) && chars[j+1] == chars[j]+1 && alnum(chars[j+1]) {
+			j++
+		}
+		if j-i >= 2 {
+			pattern.WriteString(escape(chars[i]) + "-" + escape(chars[j]))
+			i = j
+			continue
+		}
+		pattern.WriteString(escape(chars[i]))
+	}
+	pattern.WriteString("]")
+	return nil
+}
+
 // headersPolicyGatewayAPI builds a *HeaderPolicy for the supplied HTTPRequestHeaderFilter.
 // TODO: Take care about the order of operators once https://github.com/kubernetes-sigs/gateway-api/issues/480 was solved.
 func headersPolicyGatewayAPI(hf *gatewayapi_v1alpha1.HTTPRequestHeaderFilter) (*HeadersPolicy, error) {
@@ -259,49 +565,154 @@ func headersPolicyGatewayAPI(hf *gatewayapi_v1alpha1.HTTPRequestHeaderFilter) (*
 	}, utilerrors.NewAggregate(errlist)
 }
 
-func escapeHeaderValue(value string, dynamicHeaders map[string]string) string {
-	// Envoy supports %-encoded variables, so literal %'s in the header's value must be escaped.  See:
-	// https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_conn_man/headers#custom-request-response-headers
-	// Only allow a specific set of known good Envoy dynamic headers to pass through unescaped
+// envoyHeaderVariables are the Envoy command operators without
+// arguments that may be used in header values. See:
+// https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_conn_man/headers#custom-request-response-headers
+var envoyHeaderVariables = sets.NewString(
+	"DOWNSTREAM_REMOTE_ADDRESS",
+	"DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT",
+	"DOWNSTREAM_DIRECT_REMOTE_ADDRESS",
+	"DOWNSTREAM_DIRECT_REMOTE_ADDRESS_WITHOUT_PORT",
+	"DOWNSTREAM_LOCAL_ADDRESS",
+	"DOWNSTREAM_LOCAL_ADDRESS_WITHOUT_PORT",
+	"DOWNSTREAM_LOCAL_PORT",
+	"DOWNSTREAM_LOCAL_URI_SAN",
+	"DOWNSTREAM_PEER_URI_SAN",
+	"DOWNSTREAM_LOCAL_SUBJECT",
+	"DOWNSTREAM_PEER_SUBJECT",
+	"DOWNSTREAM_PEER_ISSUER",
+	"DOWNSTREAM_TLS_SESSION_ID",
+	"DOWNSTREAM_TLS_CIPHER",
+	"DOWNSTREAM_TLS_VERSION",
+	"DOWNSTREAM_PEER_FINGERPRINT_256",
+	"DOWNSTREAM_PEER_FINGERPRINT_1",
+	"DOWNSTREAM_PEER_SERIAL",
+	"DOWNSTREAM_PEER_CERT",
+	"DOWNSTREAM_PEER_CERT_V_START",
+	"DOWNSTREAM_PEER_CERT_V_END",
+	"HOSTNAME",
+	"PROTOCOL",
+	"UPSTREAM_REMOTE_ADDRESS",
+	"RESPONSE_FLAGS",
+	"RESPONSE_CODE_DETAILS",
+)
+
+// contourHeaderVariables are the variables that Contour replaces
+// in header values, when they are known.
+var contourHeaderVariables = sets.NewString(
+	"CONTOUR_NAMESPACE",
+	"CONTOUR_SERVICE_NAME",
+	"CONTOUR_SERVICE_PORT",
+)
+
+var (
+	// headerOperatorRegex matches a command operator, with optional
+	// arguments, at the start of a string.
+	headerOperatorRegex = regexp.MustCompile(`^%([A-Z][A-Z0-9_]*)(\((.*?)\))?%`)
+
+	// reqOperatorArgRegex and perRequestStateOperatorArgRegex match
+	// the arguments of the REQ and PER_REQUEST_STATE command operators.
+	reqOperatorArgRegex             = regexp.MustCompile(`^[\w-]+$`)
+	perRequestStateOperatorArgRegex = regexp.MustCompile(`^[\w.-]+$`)
+)
+
+// validHeaderOperator returns true if the named command operator, with args
+// if hasArgs is true, may be used in a header value.
+func validHeaderOperator(name string, args string, hasArgs bool) bool {
+	switch name {
+	case "REQ":
+		return hasArgs && reqOperatorArgRegex.MatchString(args)
+	case "DYNAMIC_METADATA", "UPSTREAM_METADATA":
+		// The arguments are a JSON list of the metadata
+		// namespace followed by the path to the key.
+		var path []string
+		return hasArgs && json.Unmarshal([]byte(args), &path) == nil && len(path) >= 2
+	case "PER_REQUEST_STATE":
+		return hasArgs && perRequestStateOperatorArgRegex.MatchString(args)
+	case "START_TIME":
+		// START_TIME takes an optional format.
+		return true
+	default:
+		return !hasArgs && envoyHeaderVariables.Has(name)
+	}
+}
+
+// parseHeaderValue escapes the literal %'s of a header value, since Envoy supports
+// %-encoded command operators, and replaces the Contour variables in dynamicHeaders
+// with their values. Only the known good command operators pass through unescaped.
+// The command operators that are escaped are returned.
+func parseHeaderValue(value string, dynamicHeaders map[string]string) (string, []string) {
 	if !strings.Contains(value, "%") {
-		return value
-	}
-	escapedValue := strings.Replace(value, "%", "%%", -1)
-	for dynamicVar, dynamicVal := range dynamicHeaders {
-		escapedValue = strings.ReplaceAll(escapedValue, "%%"+dynamicVar+"%%", dynamicVal)
-	}
-	for _, envoyVar := range []string{
-		"DOWNSTREAM_REMOTE_ADDRESS",
-		"DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT",
-		"DOWNSTREAM_LOCAL_ADDRESS",
-		"DOWNSTREAM_LOCAL_ADDRESS_WITHOUT_PORT",
-		"DOWNSTREAM_LOCAL_PORT",
-		"DOWNSTREAM_LOCAL_URI_SAN",
-		"DOWNSTREAM_PEER_URI_SAN",
-		"DOWNSTREAM_LOCAL_SUBJECT",
-		"DOWNSTREAM_PEER_SUBJECT",
-		"DOWNSTREAM_PEER_ISSUER",
-		"DOWNSTREAM_TLS_SESSION_ID",
-		"DOWNSTREAM_TLS_CIPHER",
-		"DOWNSTREAM_TLS_VERSION",
-		"DOWNSTREAM_PEER_FINGERPRINT_256",
-		"DOWNSTREAM_PEER_FINGERPRINT_1",
-		"DOWNSTREAM_PEER_SERIAL",
-		"DOWNSTREAM_PEER_CERT",
-		"DOWNSTREAM_PEER_CERT_V_START",
-		"DOWNSTREAM_PEER_CERT_V_END",
-		"HOSTNAME",
-		"PROTOCOL",
-		"UPSTREAM_REMOTE_ADDRESS",
-		"RESPONSE_FLAGS",
-		"RESPONSE_CODE_DETAILS",
-	} {
-		escapedValue = strings.ReplaceAll(escapedValue, "%%"+envoyVar+"%%", "%"+envoyVar+"%")
-	}
-	// REQ(header-name)
-	var validReqEnvoyVar = regexp.MustCompile(`%(%REQ\([\w-]+\)%)%`)
-	escapedValue = validReqEnvoyVar.ReplaceAllString(escapedValue, "$1")
-	return escapedValue
+		return value, nil
+	}
+
+	var escaped strings.Builder
+	var invalid []string
+	for len(value) > 0 {
+		i := strings.IndexByte(value, '%')
+		if i < 0 {
+			escaped.WriteString(value)
+			break
+		}
+		escaped.WriteString(value[:i])
+		value = value[i:]
+
+		m := headerOperatorRegex.FindStringSubmatch(value)
+		if m == nil {
+			// A literal %.
+			escaped.WriteString("%%")
+			value = value[1:]
+			continue
+		}
+
+		name, args, hasArgs := m[1], m[3], m[2] != ""
+		switch {
+		case !hasArgs && dynamicHeaders[name] != "":
+			escaped.WriteString(dynamicHeaders[name])
+		case validHeaderOperator(name, args, hasArgs):
+			escaped.WriteString(m[0])
+		default:
+			// Escape the leading % and carry on after it, since
+			// the trailing % may start the next operator.
+			invalid = append(invalid, m[0])
+			escaped.WriteString("%%")
+			value = value[1:]
+			continue
+		}
+		value = value[len(m[0]):]
+	}
+
+	return escaped.String(), invalid
+}
+
+func escapeHeaderValue(value string, dynamicHeaders map[string]string) string {
+	escaped, _ := parseHeaderValue(value, dynamicHeaders)
+	return escaped
+}
+
+// headersPolicyWarnings returns a warning for each command operator of the
+// header values of policy that is not valid, and is set literally.
+func headersPolicyWarnings(policy *contour_api_v1.HeadersPolicy, dynamicHeaders map[string]string) []string {
+	if policy == nil {
+		return nil
+	}
+
+	var warnings []string
+	for _, entry := range policy.Set {
+		_, invalid := parseHeaderValue(entry.Value, dynamicHeaders)
+		for _, op := range invalid {
+			name := headerOperatorRegex.FindStringSubmatch(op)[1]
+			switch {
+			case contourHeaderVariables.Has(name):
+				warnings = append(warnings, fmt.Sprintf("header %q: %s is only known in the headers policies of services and is set literally",
+					http.CanonicalHeaderKey(entry.Name), op))
+			default:
+				warnings = append(warnings, fmt.Sprintf("header %q: %s is not a valid command operator and is set literally",
+					http.CanonicalHeaderKey(entry.Name), op))
+			}
+		}
+	}
+	return warnings
 }
 
 // ingressRetryPolicy builds a RetryPolicy from ingress annotations.
@@ -381,30 +792,95 @@ func timeoutPolicy(tp *contour_api_v1.TimeoutPolicy) (TimeoutPolicy, error) {
 	}, nil
 }
 
-func httpHealthCheckPolicy(hc *contour_api_v1.HTTPHealthCheckPolicy) *HTTPHealthCheckPolicy {
+func httpHealthCheckPolicy(hc *contour_api_v1.HTTPHealthCheckPolicy) (*HTTPHealthCheckPolicy, error) {
 	if hc == nil {
-		return nil
+		return nil, nil
 	}
-	return &HTTPHealthCheckPolicy{
+
+	switch {
+	case hc.GRPC == nil && hc.Path == "":
+		return nil, errors.New("path must be set unless grpc is set")
+	case hc.GRPC != nil && hc.Path != "":
+		return nil, errors.New("path and grpc cannot both be set")
+	case hc.GRPC != nil && (len(hc.ExpectedStatuses) > 0 || len(hc.RequestHeaders) > 0):
+		return nil, errors.New("expectedStatuses and requestHeaders cannot be combined with grpc")
+	case hc.Port < 0 || hc.Port > 65535:
+		return nil, fmt.Errorf("port %d must be in the range 1-65535", hc.Port)
+	}
+
+	policy := &HTTPHealthCheckPolicy{
 		Path:               hc.Path,
 		Host:               hc.Host,
 		Interval:           time.Duration(hc.IntervalSeconds) * time.Second,
 		Timeout:            time.Duration(hc.TimeoutSeconds) * time.Second,
 		UnhealthyThreshold: uint32(hc.UnhealthyThresholdCount),
 		HealthyThreshold:   uint32(hc.HealthyThresholdCount),
+		Port:               int32(hc.Port),
+	}
+
+	for _, r := range hc.ExpectedStatuses {
+		end := r.End
+		if end == 0 {
+			end = r.Start
+		}
+		if r.Start < 100 || end > 599 || end < r.Start {
+			return nil, fmt.Errorf("expected status range %d-%d is not valid", r.Start, end)
+		}
+
+		// Envoy status ranges exclude their end.
+		policy.ExpectedStatuses = append(policy.ExpectedStatuses, HTTPStatusRange{
+			Start: r.Start,
+			End:   end + 1,
+		})
+	}
+
+	for _, header := range hc.RequestHeaders {
+		if header.Append {
+			return nil, fmt.Errorf("appending to %q header is not supported on health check request headers", http.CanonicalHeaderKey(header.Name))
+		}
 	}
+	if len(hc.RequestHeaders) > 0 {
+		headers, err := headersPolicyRoute(&contour_api_v1.HeadersPolicy{Set: hc.RequestHeaders}, false /* disallow Host */, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s on health check request headers", err)
+		}
+		policy.RequestHeaders = headers.Set
+	}
+	if g := hc.GRPC; g != nil {
+		policy.GRPC = &GRPCHealthCheckPolicy{
+			ServiceName: g.ServiceName,
+			Authority:   g.Authority,
+		}
+	}
+
+	return policy, nil
 }
 
-func tcpHealthCheckPolicy(hc *contour_api_v1.TCPHealthCheckPolicy) *TCPHealthCheckPolicy {
+func tcpHealthCheckPolicy(hc *contour_api_v1.TCPHealthCheckPolicy) (*TCPHealthCheckPolicy, error) {
 	if hc == nil {
-		return nil
+		return nil, nil
+	}
+
+	if _, err := hex.DecodeString(hc.Send); err != nil {
+		return nil, fmt.Errorf("send payload %q is not hex encoded", hc.Send)
+	}
+	for _, r := range hc.Receive {
+		if len(r) == 0 {
+			return nil, errors.New("receive payloads must not be empty")
+		}
+		if _, err := hex.DecodeString(r); err != nil {
+			return nil, fmt.Errorf("receive payload %q is not hex encoded", r)
+		}
 	}
+
 	return &TCPHealthCheckPolicy{
 		Interval:           time.Duration(hc.IntervalSeconds) * time.Second,
 		Timeout:            time.Duration(hc.TimeoutSeconds) * time.Second,
 		UnhealthyThreshold: hc.UnhealthyThresholdCount,
 		HealthyThreshold:   hc.HealthyThresholdCount,
-	}
+		Send:               hc.Send,
+		Receive:            hc.Receive,
+	}, nil
 }
 
 // loadBalancerPolicy returns the load balancer strategy or
@@ -510,6 +986,9 @@ func localRateLimitPolicy(in *contour_api_v1.LocalRateLimitPolicy) (*LocalRateLi
 		if msgs := validation.IsHTTPHeaderName(key); len(msgs) != 0 {
 			return nil, fmt.Errorf("invalid header name %q: %v", key, msgs)
 		}
+		if header.Append {
+			return nil, fmt.Errorf("appending to %q header is not supported", key)
+		}
 		res.ResponseHeadersToAdd[key] = escapeHeaderValue(header.Value, map[string]string{})
 	}
 
@@ -583,6 +1062,332 @@ func globalRateLimitPolicy(in *contour_api_v1.GlobalRateLimitPolicy) (*GlobalRat
 	return res, nil
 }
 
+// cookieNameRegex matches the tokens that are valid cookie names.
+var cookieNameRegex = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// cookieRewritePolicies validates the cookie rewrite policies of a route.
+// cachePolicy validates policy and returns the cache policy of a route,
+// or nil if policy is nil.
+func admissionControlPolicy(policy *contour_api_v1.AdmissionControlPolicy) (*AdmissionControlPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	acp := &AdmissionControlPolicy{
+		SuccessRateThreshold:   95,
+		Aggression:             1,
+		SamplingWindow:         30 * time.Second,
+		MinRequestsPerSecond:   policy.MinRequestsPerSecond,
+		MaxRejectionPercentage: 80,
+	}
+
+	if policy.SuccessRateThreshold > 0 {
+		if policy.SuccessRateThreshold > 100 {
+			return nil, fmt.Errorf("successRateThreshold %d is not a percentage", policy.SuccessRateThreshold)
+		}
+		acp.SuccessRateThreshold = policy.SuccessRateThreshold
+	}
+
+	if policy.Aggression != "" {
+		aggression, err := strconv.ParseFloat(policy.Aggression, 64)
+		if err != nil {
+			return nil, fmt.Errorf("aggression %q is not a number", policy.Aggression)
+		}
+		if aggression < 1 || math.IsNaN(aggression) {
+			return nil, fmt.Errorf("aggression %q must be at least 1", policy.Aggression)
+		}
+		acp.Aggression = aggression
+	}
+
+	if policy.SamplingWindowSeconds < 0 {
+		return nil, errors.New("samplingWindowSeconds must be positive")
+	}
+	if policy.SamplingWindowSeconds > 0 {
+		acp.SamplingWindow = time.Duration(policy.SamplingWindowSeconds) * time.Second
+	}
+
+	if policy.MaxRejectionPercentage > 0 {
+		if policy.MaxRejectionPercentage > 100 {
+			return nil, fmt.Errorf("maxRejectionPercentage %d is not a percentage", policy.MaxRejectionPercentage)
+		}
+		acp.MaxRejectionPercentage = policy.MaxRejectionPercentage
+	}
+
+	return acp, nil
+}
+
+func bufferPolicy(policy *contour_api_v1.BufferPolicy) (*BufferPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	if policy.MaxRequestBytes == 0 {
+		return nil, errors.New("maxRequestBytes must be positive")
+	}
+
+	switch policy.OnOverflow {
+	case "", "Reject", "Passthrough":
+	default:
+		return nil, fmt.Errorf("invalid onOverflow %q", policy.OnOverflow)
+	}
+
+	return &BufferPolicy{
+		MaxRequestBytes: policy.MaxRequestBytes,
+	}, nil
+}
+
+// rbacPolicy validates an RBAC policy and returns its DAG
+// representation. Subject alternative name principals are only
+// valid when the virtual host validates client certificates.
+func rbacPolicy(policy *contour_api_v1.RBACPolicy, clientValidation bool) (*RBACPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	rp := &RBACPolicy{}
+	switch policy.Action {
+	case "", "Allow":
+	case "Deny":
+		rp.Deny = true
+	default:
+		return nil, fmt.Errorf("invalid action %q", policy.Action)
+	}
+
+	if len(policy.Rules) == 0 {
+		return nil, errors.New("at least one rule must be specified")
+	}
+
+	for _, rule := range policy.Rules {
+		var r RBACRule
+
+		for _, p := range rule.Principals {
+			var principal RBACPrincipal
+			set := 0
+			if p.SubjectAltName != "" {
+				if !clientValidation {
+					return nil, errors.New("subjectAltName principals require that Spec.VirtualHost.TLS.ClientValidation be set")
+				}
+				principal.SubjectAltName = p.SubjectAltName
+				set++
+			}
+			if p.SourceCIDR != "" {
+				_, cidr, err := net.ParseCIDR(p.SourceCIDR)
+				if err != nil {
+					return nil, fmt.Errorf("invalid sourceCIDR %q", p.SourceCIDR)
+				}
+				principal.SourceCIDR = cidr
+				set++
+			}
+			if p.Header != nil {
+				conds := headerMatchConditions([]contour_api_v1.HeaderMatchCondition{*p.Header})
+				if len(conds) == 0 {
+					return nil, fmt.Errorf("header principal %q has no condition", p.Header.Name)
+				}
+				if msgs := validation.IsHTTPHeaderName(p.Header.Name); len(msgs) != 0 {
+					return nil, fmt.Errorf("invalid header name %q: %s", p.Header.Name, strings.Join(msgs, ","))
+				}
+				principal.Header = &conds[0]
+				set++
+			}
+			if set != 1 {
+				return nil, errors.New("exactly one of subjectAltName, sourceCIDR or header must be set on each principal")
+			}
+			r.Principals = append(r.Principals, principal)
+		}
+
+		for _, p := range rule.Permissions {
+			for _, m := range p.Methods {
+				if msgs := validation.IsHTTPHeaderName(m); len(msgs) != 0 || strings.ToUpper(m) != m {
+					return nil, fmt.Errorf("invalid method %q", m)
+				}
+			}
+			if p.PathPrefix != "" && !strings.HasPrefix(p.PathPrefix, "/") {
+				return nil, fmt.Errorf("pathPrefix %q must start with '/'", p.PathPrefix)
+			}
+			r.Permissions = append(r.Permissions, RBACPermission{
+				Methods:    p.Methods,
+				PathPrefix: p.PathPrefix,
+			})
+		}
+
+		rp.Rules = append(rp.Rules, r)
+	}
+
+	return rp, nil
+}
+
+// userAgentRoutes validates a User-Agent policy and returns routes, with
+// a copy of each that matches the requests whose User-Agent header
+// matches the policy. The copies have one more header condition than
+// the routes they are copied from, and the same priority, so they
+// sort before them. They respond with a 403, or set the header of the
+// policy, which the routes remove. If policy is nil, routes are
+// returned as they are.
+func userAgentRoutes(policy *contour_api_v1.UserAgentPolicy, routes []*Route) ([]*Route, error) {
+	if policy == nil {
+		return routes, nil
+	}
+
+	if len(policy.Patterns) == 0 {
+		return nil, errors.New("at least one pattern must be specified")
+	}
+
+	var patterns []string
+	for _, pattern := range policy.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", pattern, err)
+		}
+		patterns = append(patterns, "(?:"+pattern+")")
+	}
+
+	// Envoy matches the whole header value, so the
+	// patterns are searched for anywhere in it.
+	cond := HeaderMatchCondition{
+		Name:      "User-Agent",
+		Value:     ".*(?:" + strings.Join(patterns, "|") + ").*",
+		MatchType: HeaderMatchTypeRegex,
+	}
+
+	var header *contour_api_v1.HeaderValue
+	switch policy.Action {
+	case "", "Deny":
+		if policy.Header != nil {
+			return nil, errors.New("header requires the Header action")
+		}
+	case "Header":
+		if policy.Header == nil {
+			return nil, errors.New("the Header action requires that header be set")
+		}
+		if msgs := validation.IsHTTPHeaderName(policy.Header.Name); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid header name %q: %s", policy.Header.Name, strings.Join(msgs, ","))
+		}
+		header = policy.Header
+	default:
+		return nil, fmt.Errorf("invalid action %q", policy.Action)
+	}
+
+	var res []*Route
+	for _, route := range routes {
+		conds := append(append([]HeaderMatchCondition(nil), route.HeaderMatchConditions...), cond)
+
+		if header == nil {
+			res = append(res, route, &Route{
+				PathMatchCondition:    route.PathMatchCondition,
+				HeaderMatchConditions: conds,
+				Priority:              route.Priority,
+				HTTPSUpgrade:          route.HTTPSUpgrade,
+				AuthDisabled:          route.AuthDisabled,
+				AuthContext:           route.AuthContext,
+				DirectResponse:        &DirectResponse{StatusCode: http.StatusForbidden},
+			})
+			continue
+		}
+
+		var hp HeadersPolicy
+		if route.RequestHeadersPolicy != nil {
+			hp = *route.RequestHeadersPolicy
+		}
+
+		matched := *route
+		matched.HeaderMatchConditions = conds
+		matched.RequestHeadersPolicy = &HeadersPolicy{
+			HostRewrite:    hp.HostRewrite,
+			Add:            hp.Add,
+			Set:            map[string]string{},
+			Remove:         hp.Remove,
+			RemoveMatching: hp.RemoveMatching,
+		}
+		for k, v := range hp.Set {
+			matched.RequestHeadersPolicy.Set[k] = v
+		}
+		matched.RequestHeadersPolicy.Set[http.CanonicalHeaderKey(header.Name)] = header.Value
+
+		hp.Remove = append(append([]string(nil), hp.Remove...), http.CanonicalHeaderKey(header.Name))
+		unmatched := *route
+		unmatched.RequestHeadersPolicy = &hp
+
+		res = append(res, &unmatched, &matched)
+	}
+	return res, nil
+}
+
+func cachePolicy(policy *contour_api_v1.CachePolicy) (*CachePolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	methods := sets.NewString()
+	for _, method := range policy.Methods {
+		switch method {
+		case "GET", "HEAD":
+			methods.Insert(string(method))
+		default:
+			return nil, fmt.Errorf("invalid method %q", method)
+		}
+	}
+	if methods.Len() == 0 {
+		methods.Insert("GET")
+	}
+
+	keyHeaders := sets.NewString()
+	for _, name := range policy.KeyHeaders {
+		if msgs := validation.IsHTTPHeaderName(name); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid key header name %q: %s", name, strings.Join(msgs, ","))
+		}
+		keyHeaders.Insert(strings.ToLower(name))
+	}
+
+	return &CachePolicy{
+		Methods:       methods.List(),
+		MaxObjectSize: policy.MaxObjectSize,
+		KeyHeaders:    keyHeaders.List(),
+	}, nil
+}
+
+func cookieRewritePolicies(policies []contour_api_v1.CookieRewritePolicy) ([]CookieRewritePolicy, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	names := sets.NewString()
+	var crp []CookieRewritePolicy
+	for _, policy := range policies {
+		if names.Has(policy.Name) {
+			if policy.Name == "" {
+				return nil, errors.New("duplicate cookie rewrite policy without a cookie name")
+			}
+			return nil, fmt.Errorf("duplicate cookie rewrite policy for cookie %q", policy.Name)
+		}
+		names.Insert(policy.Name)
+
+		if policy.Name != "" && !cookieNameRegex.MatchString(policy.Name) {
+			return nil, fmt.Errorf("invalid cookie name %q", policy.Name)
+		}
+		if policy.Domain == nil && policy.Secure == nil && policy.HTTPOnly == nil && policy.SameSite == "" {
+			return nil, fmt.Errorf("cookie rewrite policy for cookie %q does not rewrite any attributes", policy.Name)
+		}
+		if policy.Domain != nil && *policy.Domain != "" {
+			if msgs := validation.IsDNS1123Subdomain(strings.TrimPrefix(*policy.Domain, ".")); len(msgs) != 0 {
+				return nil, fmt.Errorf("invalid cookie domain %q: %v", *policy.Domain, msgs)
+			}
+		}
+		switch policy.SameSite {
+		case "", "Strict", "Lax", "None":
+		default:
+			return nil, fmt.Errorf("invalid cookie SameSite attribute %q", policy.SameSite)
+		}
+
+		crp = append(crp, CookieRewritePolicy{
+			Name:     policy.Name,
+			Domain:   policy.Domain,
+			Secure:   policy.Secure,
+			HTTPOnly: policy.HTTPOnly,
+			SameSite: policy.SameSite,
+		})
+	}
+	return crp, nil
+}
+
 // Validates and returns list of hash policies along with lb actual strategy to
 // be used. Will return default strategy and empty list of hash policies if
 // validation fails.
@@ -605,7 +1410,27 @@ func loadBalancerRequestHashPolicies(lbp *contour_api_v1.LoadBalancerPolicy, val
 		actualStrategy := strategy
 		// Map of unique header names.
 		headerHashPolicies := map[string]bool{}
+		sourceIPHashPolicy := false
 		for _, hashPolicy := range lbp.RequestHashPolicies {
+			if hashPolicy.HashSourceIP {
+				if hashPolicy.HeaderHashOptions != nil {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
+						"ignoring invalid hash policy with both source ip and header hash options")
+					continue
+				}
+				if sourceIPHashPolicy {
+					validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
+						"ignoring invalid duplicated source ip hash policy")
+					continue
+				}
+				sourceIPHashPolicy = true
+
+				rhp = append(rhp, RequestHashPolicy{
+					Terminal:     hashPolicy.Terminal,
+					HashSourceIP: true,
+				})
+				continue
+			}
 			if hashPolicy.HeaderHashOptions == nil {
 				validCond.AddWarningf(contour_api_v1.ConditionTypeSpecError, "IgnoredField",
 					"ignoring invalid nil hash policy options")
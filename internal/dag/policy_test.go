@@ -15,6 +15,8 @@ package dag
 
 import (
 	"io/ioutil"
+	"net"
+	"net/http"
 	"testing"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	networking_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 )
 
 func TestRetryPolicyIngress(t *testing.T) {
@@ -302,6 +305,415 @@ func TestLoadBalancerPolicy(t *testing.T) {
 	}
 }
 
+func TestTracingPolicy(t *testing.T) {
+	percentage := uint32(1)
+
+	tests := map[string]struct {
+		tp      *contour_api_v1.TracingPolicy
+		want    *TracingPolicy
+		wantErr bool
+	}{
+		"nil": {
+			tp:   nil,
+			want: nil,
+		},
+		"sampling and custom tags": {
+			tp: &contour_api_v1.TracingPolicy{
+				SamplingPercentage: &percentage,
+				CustomTags: []contour_api_v1.CustomTag{
+					{TagName: "team", Literal: "payments"},
+					{TagName: "customer", RequestHeaderName: "X-Customer-Id"},
+				},
+			},
+			want: &TracingPolicy{
+				SamplingPercentage: &percentage,
+				CustomTags: []TracingCustomTag{
+					{TagName: "team", Literal: "payments"},
+					{TagName: "customer", RequestHeaderName: "X-Customer-Id"},
+				},
+			},
+		},
+		"duplicate tag": {
+			tp: &contour_api_v1.TracingPolicy{
+				CustomTags: []contour_api_v1.CustomTag{
+					{TagName: "team", Literal: "payments"},
+					{TagName: "team", Literal: "billing"},
+				},
+			},
+			wantErr: true,
+		},
+		"literal and header": {
+			tp: &contour_api_v1.TracingPolicy{
+				CustomTags: []contour_api_v1.CustomTag{
+					{TagName: "team", Literal: "payments", RequestHeaderName: "x-team"},
+				},
+			},
+			wantErr: true,
+		},
+		"no value": {
+			tp: &contour_api_v1.TracingPolicy{
+				CustomTags: []contour_api_v1.CustomTag{
+					{TagName: "team"},
+				},
+			},
+			wantErr: true,
+		},
+		"invalid header name": {
+			tp: &contour_api_v1.TracingPolicy{
+				CustomTags: []contour_api_v1.CustomTag{
+					{TagName: "customer", RequestHeaderName: "x customer"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tracingPolicy(tc.tp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	tests := map[string]struct {
+		policy  *contour_api_v1.RequestIDPolicy
+		want    string
+		wantErr bool
+	}{
+		"nil": {
+			policy: nil,
+			want:   "",
+		},
+		"policy only": {
+			policy: &contour_api_v1.RequestIDPolicy{
+				Policy: "Preserve",
+			},
+			want: "",
+		},
+		"header": {
+			policy: &contour_api_v1.RequestIDPolicy{
+				Header: "x-cdn-request-id",
+			},
+			want: "x-cdn-request-id",
+		},
+		"invalid header": {
+			policy: &contour_api_v1.RequestIDPolicy{
+				Header: "x-cdn-request-id!@#",
+			},
+			wantErr: true,
+		},
+		"x-request-id header": {
+			policy: &contour_api_v1.RequestIDPolicy{
+				Header: "X-Request-ID",
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := requestIDHeader(tc.policy)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClientCertificateHeaders(t *testing.T) {
+	tests := map[string]struct {
+		headers []contour_api_v1.ClientCertificateHeader
+		want    map[string]string
+		wantErr bool
+	}{
+		"nil": {
+			headers: nil,
+			want:    nil,
+		},
+		"headers": {
+			headers: []contour_api_v1.ClientCertificateHeader{{
+				Name:  "x-client-san",
+				Field: "SubjectAltName",
+			}, {
+				Name:  "X-Client-Fingerprint",
+				Field: "SHA256",
+			}},
+			want: map[string]string{
+				"X-Client-San":         "SubjectAltName",
+				"X-Client-Fingerprint": "SHA256",
+			},
+		},
+		"invalid header": {
+			headers: []contour_api_v1.ClientCertificateHeader{{
+				Name:  "x-client-san!@#",
+				Field: "SubjectAltName",
+			}},
+			wantErr: true,
+		},
+		"duplicate header": {
+			headers: []contour_api_v1.ClientCertificateHeader{{
+				Name:  "x-client-cert",
+				Field: "Subject",
+			}, {
+				Name:  "X-Client-Cert",
+				Field: "Serial",
+			}},
+			wantErr: true,
+		},
+		"invalid field": {
+			headers: []contour_api_v1.ClientCertificateHeader{{
+				Name:  "x-client-cert",
+				Field: "PEM",
+			}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := clientCertificateHeaders(tc.headers)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	zero, ten, hundred := uint32(0), uint32(10), uint32(100)
+
+	tests := map[string]struct {
+		policy *contour_api_v1.AccessLogPolicy
+		want   *uint32
+	}{
+		"nil": {
+			policy: nil,
+			want:   nil,
+		},
+		"empty": {
+			policy: &contour_api_v1.AccessLogPolicy{},
+			want:   nil,
+		},
+		"disabled": {
+			policy: &contour_api_v1.AccessLogPolicy{
+				Disabled:           true,
+				SamplingPercentage: &ten,
+			},
+			want: &zero,
+		},
+		"sampled": {
+			policy: &contour_api_v1.AccessLogPolicy{
+				SamplingPercentage: &ten,
+			},
+			want: &ten,
+		},
+		"zero percent": {
+			policy: &contour_api_v1.AccessLogPolicy{
+				SamplingPercentage: &zero,
+			},
+			want: &zero,
+		},
+		"hundred percent": {
+			policy: &contour_api_v1.AccessLogPolicy{
+				SamplingPercentage: &hundred,
+			},
+			want: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := accessLogSampling(tc.policy)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestHTTPHealthCheckPolicy(t *testing.T) {
+	tests := map[string]struct {
+		hc      *contour_api_v1.HTTPHealthCheckPolicy
+		want    *HTTPHealthCheckPolicy
+		wantErr bool
+	}{
+		"nil": {
+			hc:   nil,
+			want: nil,
+		},
+		"path": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				Path:            "/healthz",
+				IntervalSeconds: 5,
+			},
+			want: &HTTPHealthCheckPolicy{
+				Path:     "/healthz",
+				Interval: 5 * time.Second,
+			},
+		},
+		"grpc": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				GRPC: &contour_api_v1.GRPCHealthCheckPolicy{
+					ServiceName: "helloworld.Greeter",
+				},
+			},
+			want: &HTTPHealthCheckPolicy{
+				GRPC: &GRPCHealthCheckPolicy{
+					ServiceName: "helloworld.Greeter",
+				},
+			},
+		},
+		"neither path nor grpc": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				IntervalSeconds: 5,
+			},
+			wantErr: true,
+		},
+		"path and grpc": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				Path: "/healthz",
+				GRPC: &contour_api_v1.GRPCHealthCheckPolicy{},
+			},
+			wantErr: true,
+		},
+		"expected statuses, port and request headers": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				Path: "/healthz",
+				ExpectedStatuses: []contour_api_v1.HTTPStatusRange{
+					{Start: 200, End: 299},
+					{Start: 404},
+				},
+				Port: 15020,
+				RequestHeaders: []contour_api_v1.HeaderValue{
+					{Name: "x-health-token", Value: "s3cr3t"},
+				},
+			},
+			want: &HTTPHealthCheckPolicy{
+				Path: "/healthz",
+				ExpectedStatuses: []HTTPStatusRange{
+					{Start: 200, End: 300},
+					{Start: 404, End: 405},
+				},
+				Port: 15020,
+				RequestHeaders: map[string]string{
+					"X-Health-Token": "s3cr3t",
+				},
+			},
+		},
+		"inverted expected status range": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				Path: "/healthz",
+				ExpectedStatuses: []contour_api_v1.HTTPStatusRange{
+					{Start: 299, End: 200},
+				},
+			},
+			wantErr: true,
+		},
+		"host request header": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				Path: "/healthz",
+				RequestHeaders: []contour_api_v1.HeaderValue{
+					{Name: "Host", Value: "example.com"},
+				},
+			},
+			wantErr: true,
+		},
+		"grpc and request headers": {
+			hc: &contour_api_v1.HTTPHealthCheckPolicy{
+				GRPC: &contour_api_v1.GRPCHealthCheckPolicy{},
+				RequestHeaders: []contour_api_v1.HeaderValue{
+					{Name: "x-health-token", Value: "s3cr3t"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := httpHealthCheckPolicy(tc.hc)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTCPHealthCheckPolicy(t *testing.T) {
+	tests := map[string]struct {
+		hc      *contour_api_v1.TCPHealthCheckPolicy
+		want    *TCPHealthCheckPolicy
+		wantErr bool
+	}{
+		"nil": {
+			hc:   nil,
+			want: nil,
+		},
+		"connect only": {
+			hc: &contour_api_v1.TCPHealthCheckPolicy{
+				IntervalSeconds: 5,
+			},
+			want: &TCPHealthCheckPolicy{
+				Interval: 5 * time.Second,
+			},
+		},
+		"send and receive": {
+			hc: &contour_api_v1.TCPHealthCheckPolicy{
+				Send:    "50494e470d0a",
+				Receive: []string{"2b504f4e470d0a"},
+			},
+			want: &TCPHealthCheckPolicy{
+				Send:    "50494e470d0a",
+				Receive: []string{"2b504f4e470d0a"},
+			},
+		},
+		"send not hex": {
+			hc: &contour_api_v1.TCPHealthCheckPolicy{
+				Send: "PING",
+			},
+			wantErr: true,
+		},
+		"receive not hex": {
+			hc: &contour_api_v1.TCPHealthCheckPolicy{
+				Receive: []string{"abc"},
+			},
+			wantErr: true,
+		},
+		"receive empty": {
+			hc: &contour_api_v1.TCPHealthCheckPolicy{
+				Receive: []string{""},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tcpHealthCheckPolicy(tc.hc)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestHeadersPolicy(t *testing.T) {
 	tests := map[string]struct {
 		hp      *contour_api_v1.HeadersPolicy
@@ -393,92 +805,254 @@ func TestHeadersPolicy(t *testing.T) {
 				},
 			},
 		},
-		"header value with dynamic and non-dynamic content and multiple dynamic fields": {
+		"Envoy dynamic metadata unescaped": {
 			hp: &contour_api_v1.HeadersPolicy{
 				Set: []contour_api_v1.HeaderValue{{
-					Name:  "X-Host-Protocol",
-					Value: "%HOSTNAME% - %PROTOCOL%",
+					Name:  "X-Dynamic",
+					Value: `%DYNAMIC_METADATA(["com.example.auth", "tenant"])%`,
 				}},
 			},
 			dhp: HeadersPolicy{},
 			want: HeadersPolicy{
 				Set: map[string]string{
-					"X-Host-Protocol": "%HOSTNAME% - %PROTOCOL%",
+					"X-Dynamic": `%DYNAMIC_METADATA(["com.example.auth", "tenant"])%`,
 				},
 			},
 		},
-		"dynamic service headers": {
+		"Envoy dynamic metadata without key is escaped": {
 			hp: &contour_api_v1.HeadersPolicy{
 				Set: []contour_api_v1.HeaderValue{{
-					Name:  "l5d-dst-override",
-					Value: "%CONTOUR_SERVICE_NAME%.%CONTOUR_NAMESPACE%.svc.cluster.local:%CONTOUR_SERVICE_PORT%",
+					Name:  "X-Dynamic",
+					Value: `%DYNAMIC_METADATA(["com.example.auth"])%`,
 				}},
 			},
+			dhp: HeadersPolicy{},
 			want: HeadersPolicy{
 				Set: map[string]string{
-					"L5d-Dst-Override": "myservice.myns.svc.cluster.local:80",
+					"X-Dynamic": `%%DYNAMIC_METADATA(["com.example.auth"])%%`,
 				},
 			},
 		},
-		"default header value with different object header value combined": {
+		"Envoy upstream metadata unescaped": {
 			hp: &contour_api_v1.HeadersPolicy{
 				Set: []contour_api_v1.HeaderValue{{
-					Name:  "X-Host-Protocol",
-					Value: "%HOSTNAME% - %PROTOCOL%",
+					Name:  "X-Dynamic",
+					Value: `%UPSTREAM_METADATA(["envoy.lb", "zone"])%`,
 				}},
 			},
-			dhp: HeadersPolicy{
-				Set: map[string]string{
-					"X-Envoy-Hostname": "%HOSTNAME%",
-				},
-			},
+			dhp: HeadersPolicy{},
 			want: HeadersPolicy{
 				Set: map[string]string{
-					"X-Envoy-Hostname": "%HOSTNAME%",
-					"X-Host-Protocol":  "%HOSTNAME% - %PROTOCOL%",
+					"X-Dynamic": `%UPSTREAM_METADATA(["envoy.lb", "zone"])%`,
 				},
 			},
 		},
-		"default header value with same object header value not replaced": {
+		"Envoy per request state unescaped": {
 			hp: &contour_api_v1.HeadersPolicy{
 				Set: []contour_api_v1.HeaderValue{{
-					Name:  "X-App-Weight",
-					Value: "100",
+					Name:  "X-Dynamic",
+					Value: "%PER_REQUEST_STATE(com.example.tenant)%",
 				}},
 			},
-			dhp: HeadersPolicy{
+			dhp: HeadersPolicy{},
+			want: HeadersPolicy{
 				Set: map[string]string{
-					"X-App-Weight": "10",
+					"X-Dynamic": "%PER_REQUEST_STATE(com.example.tenant)%",
 				},
 			},
+		},
+		"Envoy start time with format unescaped": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "X-Dynamic",
+					Value: "t=%START_TIME(%s.%3f)%",
+				}},
+			},
+			dhp: HeadersPolicy{},
 			want: HeadersPolicy{
 				Set: map[string]string{
-					"X-App-Weight": "100",
+					"X-Dynamic": "t=%START_TIME(%s.%3f)%",
 				},
 			},
 		},
-		"same header removed in default and object": {
+		"Envoy variable with arguments is escaped": {
 			hp: &contour_api_v1.HeadersPolicy{
-				Remove: []string{"X-Sensitive-Header"},
-			},
-			dhp: HeadersPolicy{
-				Remove: []string{"X-Sensitive-Header"},
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "X-Dynamic",
+					Value: "%HOSTNAME(short)%",
+				}},
 			},
+			dhp: HeadersPolicy{},
 			want: HeadersPolicy{
-				Set:    map[string]string{},
-				Remove: []string{"X-Sensitive-Header"},
+				Set: map[string]string{
+					"X-Dynamic": "%%HOSTNAME(short)%%",
+				},
 			},
 		},
-		"default headers with nil object headers": {
-			hp: nil,
-			dhp: HeadersPolicy{
-				Remove: []string{"X-Sensitive-Header"},
+		"unknown Envoy dynamic header followed by known header": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "X-Dynamic",
+					Value: "%UNKNOWN%HOSTNAME%",
+				}},
 			},
+			dhp: HeadersPolicy{},
 			want: HeadersPolicy{
-				Set:    map[string]string{},
-				Remove: []string{"X-Sensitive-Header"},
+				Set: map[string]string{
+					"X-Dynamic": "%%UNKNOWN%HOSTNAME%",
+				},
 			},
 		},
+		"header value with dynamic and non-dynamic content and multiple dynamic fields": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "X-Host-Protocol",
+					Value: "%HOSTNAME% - %PROTOCOL%",
+				}},
+			},
+			dhp: HeadersPolicy{},
+			want: HeadersPolicy{
+				Set: map[string]string{
+					"X-Host-Protocol": "%HOSTNAME% - %PROTOCOL%",
+				},
+			},
+		},
+		"dynamic service headers": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "l5d-dst-override",
+					Value: "%CONTOUR_SERVICE_NAME%.%CONTOUR_NAMESPACE%.svc.cluster.local:%CONTOUR_SERVICE_PORT%",
+				}},
+			},
+			want: HeadersPolicy{
+				Set: map[string]string{
+					"L5d-Dst-Override": "myservice.myns.svc.cluster.local:80",
+				},
+			},
+		},
+		"default header value with different object header value combined": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "X-Host-Protocol",
+					Value: "%HOSTNAME% - %PROTOCOL%",
+				}},
+			},
+			dhp: HeadersPolicy{
+				Set: map[string]string{
+					"X-Envoy-Hostname": "%HOSTNAME%",
+				},
+			},
+			want: HeadersPolicy{
+				Set: map[string]string{
+					"X-Envoy-Hostname": "%HOSTNAME%",
+					"X-Host-Protocol":  "%HOSTNAME% - %PROTOCOL%",
+				},
+			},
+		},
+		"default header value with same object header value not replaced": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "X-App-Weight",
+					Value: "100",
+				}},
+			},
+			dhp: HeadersPolicy{
+				Set: map[string]string{
+					"X-App-Weight": "10",
+				},
+			},
+			want: HeadersPolicy{
+				Set: map[string]string{
+					"X-App-Weight": "100",
+				},
+			},
+		},
+		"same header removed in default and object": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Remove: []string{"X-Sensitive-Header"},
+			},
+			dhp: HeadersPolicy{
+				Remove: []string{"X-Sensitive-Header"},
+			},
+			want: HeadersPolicy{
+				Set:    map[string]string{},
+				Remove: []string{"X-Sensitive-Header"},
+			},
+		},
+		"default headers with nil object headers": {
+			hp: nil,
+			dhp: HeadersPolicy{
+				Remove: []string{"X-Sensitive-Header"},
+			},
+			want: HeadersPolicy{
+				Set:    map[string]string{},
+				Remove: []string{"X-Sensitive-Header"},
+			},
+		},
+		"appended header value": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "X-Forwarded-Via",
+					Value: "contour",
+				}, {
+					Name:   "Via",
+					Value:  "1.1 %HOSTNAME%",
+					Append: true,
+				}},
+			},
+			dhp: HeadersPolicy{},
+			want: HeadersPolicy{
+				Set: map[string]string{
+					"X-Forwarded-Via": "contour",
+				},
+				Add: map[string]string{
+					"Via": "1.1 %HOSTNAME%",
+				},
+			},
+		},
+		"default header value with same appended object header value not replaced": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:   "Via",
+					Value:  "1.1 contour",
+					Append: true,
+				}},
+			},
+			dhp: HeadersPolicy{
+				Set: map[string]string{
+					"Via": "1.1 default",
+				},
+			},
+			want: HeadersPolicy{
+				Set: map[string]string{},
+				Add: map[string]string{
+					"Via": "1.1 contour",
+				},
+			},
+		},
+		"header both set and appended": {
+			hp: &contour_api_v1.HeadersPolicy{
+				Set: []contour_api_v1.HeaderValue{{
+					Name:  "Via",
+					Value: "1.1 contour",
+				}, {
+					Name:   "via",
+					Value:  "1.1 contour",
+					Append: true,
+				}},
+			},
+			dhp:     HeadersPolicy{},
+			wantErr: true,
+		},
+		"remove matching on a service": {
+			hp: &contour_api_v1.HeadersPolicy{
+				RemoveMatching: []contour_api_v1.HeaderNameMatch{{
+					Prefix: "X-Internal-",
+				}},
+			},
+			dhp:     HeadersPolicy{},
+			wantErr: true,
+		},
 	}
 
 	dynamicHeaders := map[string]string{
@@ -499,6 +1073,689 @@ func TestHeadersPolicy(t *testing.T) {
 	}
 }
 
+func TestHeaderNameMatches(t *testing.T) {
+	tests := map[string]struct {
+		matches []contour_api_v1.HeaderNameMatch
+		want    []HeaderNameMatch
+		wantErr bool
+	}{
+		"no matches": {
+			matches: nil,
+			want:    nil,
+		},
+		"prefix in lower case": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Prefix: "X-Internal-",
+			}},
+			want: []HeaderNameMatch{{
+				Prefix: "x-internal-",
+			}},
+		},
+		"regex translated to a Lua pattern": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Regex: `^X-B3-[a-z0-9]+\.id?$`,
+			}, {
+				Regex: "debug",
+			}},
+			want: []HeaderNameMatch{{
+				Pattern: "^x%-b3%-[0-9a-z]+%.id?$",
+			}, {
+				Pattern: "debug",
+			}},
+		},
+		"single characters of a regex alternation": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Regex: "^x-(?:a|b)$",
+			}},
+			want: []HeaderNameMatch{{
+				Pattern: "^x%-[ab]$",
+			}},
+		},
+		"invalid prefix": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Prefix: "x internal",
+			}},
+			wantErr: true,
+		},
+		"both prefix and regex": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Prefix: "x-internal-",
+				Regex:  "^x-internal-",
+			}},
+			wantErr: true,
+		},
+		"neither prefix nor regex": {
+			matches: []contour_api_v1.HeaderNameMatch{{}},
+			wantErr: true,
+		},
+		"invalid regex": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Regex: "x-[",
+			}},
+			wantErr: true,
+		},
+		"regex with a group": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Regex: "^x-(foo|bar)-id$",
+			}},
+			wantErr: true,
+		},
+		"regex with a repetition count": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Regex: "^x-a{2}",
+			}},
+			wantErr: true,
+		},
+		"regex with an anchor in the middle": {
+			matches: []contour_api_v1.HeaderNameMatch{{
+				Regex: "x-^a",
+			}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := headerNameMatches(tc.matches)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestHeadersPolicyWarnings(t *testing.T) {
+	policy := &contour_api_v1.HeadersPolicy{
+		Set: []contour_api_v1.HeaderValue{{
+			Name:  "x-valid",
+			Value: "100% %HOSTNAME% %REQ(Host)% %CONTOUR_NAMESPACE%",
+		}, {
+			Name:  "x-typo",
+			Value: "%DOWNSTREAM_REMOTE_ADRESS%",
+		}, {
+			Name:  "x-service",
+			Value: "%CONTOUR_SERVICE_NAME%",
+		}},
+	}
+
+	assert.Equal(t, []string{
+		`header "X-Typo": %DOWNSTREAM_REMOTE_ADRESS% is not a valid command operator and is set literally`,
+		`header "X-Service": %CONTOUR_SERVICE_NAME% is only known in the headers policies of services and is set literally`,
+	}, headersPolicyWarnings(policy, map[string]string{
+		"CONTOUR_NAMESPACE": "myns",
+	}))
+
+	assert.Equal(t, []string{
+		`header "X-Typo": %DOWNSTREAM_REMOTE_ADRESS% is not a valid command operator and is set literally`,
+	}, headersPolicyWarnings(policy, map[string]string{
+		"CONTOUR_NAMESPACE":    "myns",
+		"CONTOUR_SERVICE_NAME": "myservice",
+		"CONTOUR_SERVICE_PORT": "80",
+	}))
+	assert.Empty(t, headersPolicyWarnings(nil, nil))
+}
+
+func TestAdmissionControlPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  *contour_api_v1.AdmissionControlPolicy
+		want    *AdmissionControlPolicy
+		wantErr bool
+	}{
+		"no policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"defaults": {
+			policy: &contour_api_v1.AdmissionControlPolicy{},
+			want: &AdmissionControlPolicy{
+				SuccessRateThreshold:   95,
+				Aggression:             1,
+				SamplingWindow:         30 * time.Second,
+				MaxRejectionPercentage: 80,
+			},
+		},
+		"all fields": {
+			policy: &contour_api_v1.AdmissionControlPolicy{
+				SuccessRateThreshold:   90,
+				Aggression:             "1.5",
+				SamplingWindowSeconds:  60,
+				MinRequestsPerSecond:   10,
+				MaxRejectionPercentage: 50,
+			},
+			want: &AdmissionControlPolicy{
+				SuccessRateThreshold:   90,
+				Aggression:             1.5,
+				SamplingWindow:         time.Minute,
+				MinRequestsPerSecond:   10,
+				MaxRejectionPercentage: 50,
+			},
+		},
+		"aggression is not a number": {
+			policy: &contour_api_v1.AdmissionControlPolicy{
+				Aggression: "high",
+			},
+			wantErr: true,
+		},
+		"aggression is less than one": {
+			policy: &contour_api_v1.AdmissionControlPolicy{
+				Aggression: "0.5",
+			},
+			wantErr: true,
+		},
+		"success rate threshold is not a percentage": {
+			policy: &contour_api_v1.AdmissionControlPolicy{
+				SuccessRateThreshold: 101,
+			},
+			wantErr: true,
+		},
+		"negative sampling window": {
+			policy: &contour_api_v1.AdmissionControlPolicy{
+				SamplingWindowSeconds: -1,
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := admissionControlPolicy(tc.policy)
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.want, got)
+				assert.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestBufferPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  *contour_api_v1.BufferPolicy
+		want    *BufferPolicy
+		wantErr bool
+	}{
+		"no policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"reject": {
+			policy: &contour_api_v1.BufferPolicy{
+				MaxRequestBytes: 65536,
+			},
+			want: &BufferPolicy{
+				MaxRequestBytes: 65536,
+			},
+		},
+		"passthrough": {
+			policy: &contour_api_v1.BufferPolicy{
+				MaxRequestBytes: 65536,
+				OnOverflow:      "Passthrough",
+			},
+			want: &BufferPolicy{
+				MaxRequestBytes: 65536,
+			},
+		},
+		"no limit": {
+			policy:  &contour_api_v1.BufferPolicy{},
+			wantErr: true,
+		},
+		"invalid overflow action": {
+			policy: &contour_api_v1.BufferPolicy{
+				MaxRequestBytes: 65536,
+				OnOverflow:      "Drop",
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := bufferPolicy(tc.policy)
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.want, got)
+				assert.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestRBACPolicy(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := map[string]struct {
+		policy           *contour_api_v1.RBACPolicy
+		clientValidation bool
+		want             *RBACPolicy
+		wantErr          bool
+	}{
+		"no policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"allow": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{
+						SubjectAltName: "spiffe://cluster.local/ns/default/sa/client",
+					}, {
+						SourceCIDR: "10.1.2.3/8",
+					}, {
+						Header: &contour_api_v1.HeaderMatchCondition{
+							Name:  "x-user-role",
+							Exact: "admin",
+						},
+					}},
+					Permissions: []contour_api_v1.RBACPermission{{
+						Methods:    []string{"GET", "HEAD"},
+						PathPrefix: "/admin",
+					}},
+				}},
+			},
+			clientValidation: true,
+			want: &RBACPolicy{
+				Rules: []RBACRule{{
+					Principals: []RBACPrincipal{{
+						SubjectAltName: "spiffe://cluster.local/ns/default/sa/client",
+					}, {
+						SourceCIDR: cidr,
+					}, {
+						Header: &HeaderMatchCondition{
+							Name:      "x-user-role",
+							Value:     "admin",
+							MatchType: HeaderMatchTypeExact,
+						},
+					}},
+					Permissions: []RBACPermission{{
+						Methods:    []string{"GET", "HEAD"},
+						PathPrefix: "/admin",
+					}},
+				}},
+			},
+		},
+		"deny": {
+			policy: &contour_api_v1.RBACPolicy{
+				Action: "Deny",
+				Rules:  []contour_api_v1.RBACRule{{}},
+			},
+			want: &RBACPolicy{
+				Deny:  true,
+				Rules: []RBACRule{{}},
+			},
+		},
+		"invalid action": {
+			policy: &contour_api_v1.RBACPolicy{
+				Action: "Log",
+				Rules:  []contour_api_v1.RBACRule{{}},
+			},
+			wantErr: true,
+		},
+		"no rules": {
+			policy:  &contour_api_v1.RBACPolicy{},
+			wantErr: true,
+		},
+		"subject alt name without client validation": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{
+						SubjectAltName: "client.example.com",
+					}},
+				}},
+			},
+			wantErr: true,
+		},
+		"invalid source CIDR": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{
+						SourceCIDR: "10.0.0.1",
+					}},
+				}},
+			},
+			wantErr: true,
+		},
+		"empty principal": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{}},
+				}},
+			},
+			wantErr: true,
+		},
+		"principal with two fields": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{
+						SourceCIDR: "10.0.0.0/8",
+						Header: &contour_api_v1.HeaderMatchCondition{
+							Name:    "x-user-role",
+							Present: true,
+						},
+					}},
+				}},
+			},
+			wantErr: true,
+		},
+		"header principal without condition": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Principals: []contour_api_v1.RBACPrincipal{{
+						Header: &contour_api_v1.HeaderMatchCondition{
+							Name: "x-user-role",
+						},
+					}},
+				}},
+			},
+			wantErr: true,
+		},
+		"invalid method": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Permissions: []contour_api_v1.RBACPermission{{
+						Methods: []string{"get"},
+					}},
+				}},
+			},
+			wantErr: true,
+		},
+		"invalid path prefix": {
+			policy: &contour_api_v1.RBACPolicy{
+				Rules: []contour_api_v1.RBACRule{{
+					Permissions: []contour_api_v1.RBACPermission{{
+						PathPrefix: "admin",
+					}},
+				}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := rbacPolicy(tc.policy, tc.clientValidation)
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.want, got)
+				assert.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestUserAgentRoutes(t *testing.T) {
+	route := &Route{
+		PathMatchCondition: prefixString("/"),
+		HeaderMatchConditions: []HeaderMatchCondition{{
+			Name:      "x-tenant",
+			MatchType: HeaderMatchTypePresent,
+		}},
+		Clusters: []*Cluster{{
+			Upstream: &Service{},
+		}},
+		RequestHeadersPolicy: &HeadersPolicy{
+			Set: map[string]string{"X-Tenant-Route": "default"},
+		},
+	}
+	bot := HeaderMatchCondition{
+		Name:      "User-Agent",
+		Value:     ".*(?:(?:(?i)bot)|(?:^curl/)).*",
+		MatchType: HeaderMatchTypeRegex,
+	}
+
+	tests := map[string]struct {
+		policy  *contour_api_v1.UserAgentPolicy
+		want    []*Route
+		wantErr bool
+	}{
+		"no policy": {
+			policy: nil,
+			want:   []*Route{route},
+		},
+		"deny": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"(?i)bot", "^curl/"},
+			},
+			want: []*Route{route, {
+				PathMatchCondition:    prefixString("/"),
+				HeaderMatchConditions: append(route.HeaderMatchConditions[:1:1], bot),
+				DirectResponse:        &DirectResponse{StatusCode: http.StatusForbidden},
+			}},
+		},
+		"header": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"(?i)bot", "^curl/"},
+				Action:   "Header",
+				Header: &contour_api_v1.HeaderValue{
+					Name:  "x-bot",
+					Value: "true",
+				},
+			},
+			want: []*Route{{
+				PathMatchCondition:    prefixString("/"),
+				HeaderMatchConditions: route.HeaderMatchConditions,
+				Clusters:              route.Clusters,
+				RequestHeadersPolicy: &HeadersPolicy{
+					Set:    map[string]string{"X-Tenant-Route": "default"},
+					Remove: []string{"X-Bot"},
+				},
+			}, {
+				PathMatchCondition:    prefixString("/"),
+				HeaderMatchConditions: append(route.HeaderMatchConditions[:1:1], bot),
+				Clusters:              route.Clusters,
+				RequestHeadersPolicy: &HeadersPolicy{
+					Set: map[string]string{"X-Tenant-Route": "default", "X-Bot": "true"},
+				},
+			}},
+		},
+		"no patterns": {
+			policy:  &contour_api_v1.UserAgentPolicy{},
+			wantErr: true,
+		},
+		"invalid pattern": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"bot("},
+			},
+			wantErr: true,
+		},
+		"header action without header": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"bot"},
+				Action:   "Header",
+			},
+			wantErr: true,
+		},
+		"deny action with header": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"bot"},
+				Header: &contour_api_v1.HeaderValue{
+					Name:  "x-bot",
+					Value: "true",
+				},
+			},
+			wantErr: true,
+		},
+		"invalid action": {
+			policy: &contour_api_v1.UserAgentPolicy{
+				Patterns: []string{"bot"},
+				Action:   "Drop",
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := userAgentRoutes(tc.policy, []*Route{route})
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.want, got)
+				assert.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy  *contour_api_v1.CachePolicy
+		want    *CachePolicy
+		wantErr bool
+	}{
+		"no policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"default methods": {
+			policy: &contour_api_v1.CachePolicy{},
+			want: &CachePolicy{
+				Methods:    []string{"GET"},
+				KeyHeaders: []string{},
+			},
+		},
+		"methods, max object size and key headers": {
+			policy: &contour_api_v1.CachePolicy{
+				Methods:       []contour_api_v1.CacheMethod{"HEAD", "GET", "GET"},
+				MaxObjectSize: 1024,
+				KeyHeaders:    []string{"X-Tenant", "Accept-Language", "x-tenant"},
+			},
+			want: &CachePolicy{
+				Methods:       []string{"GET", "HEAD"},
+				MaxObjectSize: 1024,
+				KeyHeaders:    []string{"accept-language", "x-tenant"},
+			},
+		},
+		"invalid method": {
+			policy: &contour_api_v1.CachePolicy{
+				Methods: []contour_api_v1.CacheMethod{"POST"},
+			},
+			wantErr: true,
+		},
+		"invalid key header": {
+			policy: &contour_api_v1.CachePolicy{
+				KeyHeaders: []string{"x tenant"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := cachePolicy(tc.policy)
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.want, got)
+				assert.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestCookieRewritePolicies(t *testing.T) {
+	tests := map[string]struct {
+		policies []contour_api_v1.CookieRewritePolicy
+		want     []CookieRewritePolicy
+		wantErr  bool
+	}{
+		"no policies": {
+			policies: nil,
+			want:     nil,
+		},
+		"named and default policies": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Name:     "session",
+				Domain:   pointer.StringPtr(".example.com"),
+				SameSite: "Strict",
+			}, {
+				Secure:   pointer.BoolPtr(true),
+				HTTPOnly: pointer.BoolPtr(false),
+			}},
+			want: []CookieRewritePolicy{{
+				Name:     "session",
+				Domain:   pointer.StringPtr(".example.com"),
+				SameSite: "Strict",
+			}, {
+				Secure:   pointer.BoolPtr(true),
+				HTTPOnly: pointer.BoolPtr(false),
+			}},
+		},
+		"remove domain": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Name:   "session",
+				Domain: pointer.StringPtr(""),
+			}},
+			want: []CookieRewritePolicy{{
+				Name:   "session",
+				Domain: pointer.StringPtr(""),
+			}},
+		},
+		"duplicate name": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Name:   "session",
+				Secure: pointer.BoolPtr(true),
+			}, {
+				Name:     "session",
+				SameSite: "Lax",
+			}},
+			wantErr: true,
+		},
+		"duplicate default": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Secure: pointer.BoolPtr(true),
+			}, {
+				SameSite: "Lax",
+			}},
+			wantErr: true,
+		},
+		"invalid name": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Name:   "my session",
+				Secure: pointer.BoolPtr(true),
+			}},
+			wantErr: true,
+		},
+		"no attributes": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Name: "session",
+			}},
+			wantErr: true,
+		},
+		"invalid domain": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Name:   "session",
+				Domain: pointer.StringPtr("example.com;secure"),
+			}},
+			wantErr: true,
+		},
+		"invalid same site": {
+			policies: []contour_api_v1.CookieRewritePolicy{{
+				Name:     "session",
+				SameSite: "strict",
+			}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotErr := cookieRewritePolicies(tc.policies)
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			} else {
+				assert.Equal(t, tc.want, got)
+				assert.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
 func TestRateLimitPolicy(t *testing.T) {
 	tests := map[string]struct {
 		in      *contour_api_v1.RateLimitPolicy
@@ -15,11 +15,13 @@ package dag
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/shard"
 	"github.com/projectcontour/contour/internal/status"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -48,6 +50,9 @@ func TestDAGStatus(t *testing.T) {
 	type testcase struct {
 		objs                []interface{}
 		fallbackCertificate *types.NamespacedName
+		maxIncludeDepth     int
+		namespaceQuota      NamespaceQuota
+		partialValidity     bool
 		want                map[types.NamespacedName]contour_api_v1.DetailedCondition
 	}
 
@@ -65,7 +70,13 @@ func TestDAGStatus(t *testing.T) {
 						FieldLogger: fixture.NewTestLogger(t),
 					},
 					&HTTPProxyProcessor{
-						FallbackCertificate: tc.fallbackCertificate,
+						FallbackCertificate:   tc.fallbackCertificate,
+						MaxIncludeDepth:       tc.maxIncludeDepth,
+						DefaultNamespaceQuota: tc.namespaceQuota,
+						PartialValidity:       tc.partialValidity,
+						TCPListeners: map[int]string{
+							6379: "redis",
+						},
 					},
 					&GatewayAPIProcessor{
 						FieldLogger: fixture.NewTestLogger(t),
@@ -587,12 +598,16 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
+	// The routes are still validated after the invalid include, so the
+	// missing service is reported too.
+	includeCycle := fixture.NewValidCondition().WithGeneration(proxyInvalidIncludeCycle.Generation)
+	includeCycle.WithError(contour_api_v1.ConditionTypeIncludeError, "RootIncludesRoot", "root httpproxy cannot include another root httpproxy")
+
 	run(t, "proxy self-edge produces a cycle", testcase{
 		objs: []interface{}{proxyInvalidIncludeCycle, fixture.ServiceRootsKuard},
 		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
-			{Name: proxyInvalidIncludeCycle.Name, Namespace: proxyInvalidIncludeCycle.Namespace}: fixture.NewValidCondition().
-				WithGeneration(proxyInvalidIncludeCycle.Generation).
-				WithError(contour_api_v1.ConditionTypeIncludeError, "RootIncludesRoot", "root httpproxy cannot include another root httpproxy"),
+			{Name: proxyInvalidIncludeCycle.Name, Namespace: proxyInvalidIncludeCycle.Namespace}: includeCycle.
+				WithError(contour_api_v1.ConditionTypeServiceError, "ServiceUnresolvedReference", `Spec.Routes unresolved service reference: service "roots/green" not found`),
 		},
 	})
 
@@ -643,6 +658,52 @@ func TestDAGStatus(t *testing.T) {
 		},
 	})
 
+	proxyIncludesChildWithInclude := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "child",
+			Namespace: "roots",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Includes: []contour_api_v1.Include{{
+				Name:      "grandchild",
+				Namespace: "roots",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/bar",
+				}},
+			}},
+		},
+	}
+
+	proxyIncludedGrandchild := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "grandchild",
+			Namespace: "roots",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	run(t, "proxy include exceeds the maximum include depth", testcase{
+		objs:            []interface{}{proxyIncludesProxyWithIncludeCycle, proxyIncludesChildWithInclude, proxyIncludedGrandchild},
+		maxIncludeDepth: 1,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyIncludesProxyWithIncludeCycle.Name, Namespace: proxyIncludesProxyWithIncludeCycle.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyIncludesProxyWithIncludeCycle.Generation).Valid(),
+			{Name: proxyIncludesChildWithInclude.Name, Namespace: proxyIncludesChildWithInclude.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyIncludesChildWithInclude.Generation).
+				WithError(contour_api_v1.ConditionTypeIncludeError, "IncludeDepthExceeded", "include roots/grandchild exceeds the maximum include depth of 1: roots/parent -> roots/child -> roots/grandchild"),
+			{Name: proxyIncludedGrandchild.Name, Namespace: proxyIncludedGrandchild.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyIncludedGrandchild.Generation).
+				Orphaned(),
+		},
+	})
+
 	run(t, "proxy orphaned route", testcase{
 		objs: []interface{}{proxyIncludedChildInvalidIncludeCycle},
 		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
@@ -871,6 +932,75 @@ func TestDAGStatus(t *testing.T) {
 		},
 	})
 
+	proxyAdditionalFqdns := func(name string, fqdn string, additional ...string) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "roots",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn:            fqdn,
+					AdditionalFqdns: additional,
+				},
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+	}
+
+	proxyAliasesExampleCom := proxyAdditionalFqdns("alias-example", "example.org", "www.example.org", "EXAMPLE.com")
+
+	run(t, "conflicting proxies due to additional fqdn reuse", testcase{
+		objs: []interface{}{proxyValidExampleCom, proxyAliasesExampleCom},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyValidExampleCom.Name, Namespace: proxyValidExampleCom.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyValidExampleCom.Generation).
+				WithError(contour_api_v1.ConditionTypeVirtualHostError, "DuplicateVhost", `fqdn "example.com" is used in multiple HTTPProxies: roots/alias-example, roots/example-com`),
+			{Name: proxyAliasesExampleCom.Name, Namespace: proxyAliasesExampleCom.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyAliasesExampleCom.Generation).
+				WithError(contour_api_v1.ConditionTypeVirtualHostError, "DuplicateVhost", `fqdn "example.com" is used in multiple HTTPProxies: roots/alias-example, roots/example-com`),
+		},
+	})
+
+	proxyAliasesValid := proxyAdditionalFqdns("alias-example", "example.org", "www.example.org")
+
+	run(t, "valid proxy with additional fqdns", testcase{
+		objs: []interface{}{proxyValidExampleCom, proxyAliasesValid, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyValidExampleCom.Name, Namespace: proxyValidExampleCom.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyValidExampleCom.Generation).Valid(),
+			{Name: proxyAliasesValid.Name, Namespace: proxyAliasesValid.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyAliasesValid.Generation).Valid(),
+		},
+	})
+
+	proxyAliasesWildcard := proxyAdditionalFqdns("alias-example", "example.org", "*.example.org")
+
+	run(t, "additional fqdn with wildcard", testcase{
+		objs: []interface{}{proxyAliasesWildcard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyAliasesWildcard.Name, Namespace: proxyAliasesWildcard.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyAliasesWildcard.Generation).
+				WithError(contour_api_v1.ConditionTypeVirtualHostError, "WildCardNotAllowed", `Spec.VirtualHost.AdditionalFqdns "*.example.org" cannot use wildcards`),
+		},
+	})
+
+	proxyAliasesDuplicate := proxyAdditionalFqdns("alias-example", "example.org", "www.example.org", "Example.org")
+
+	run(t, "additional fqdn duplicates the fqdn", testcase{
+		objs: []interface{}{proxyAliasesDuplicate},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyAliasesDuplicate.Name, Namespace: proxyAliasesDuplicate.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyAliasesDuplicate.Generation).
+				WithError(contour_api_v1.ConditionTypeVirtualHostError, "DuplicateFqdn", `Spec.VirtualHost.AdditionalFqdns "Example.org" is already a name of this virtual host`),
+		},
+	})
+
 	proxyRootIncludesRoot := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "root-blog",
@@ -1055,6 +1185,261 @@ func TestDAGStatus(t *testing.T) {
 		},
 	})
 
+	proxyMirrorWithHeaders := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}, {
+					Name:   fixture.ServiceRootsKuard.Name,
+					Port:   8080,
+					Mirror: true,
+					RequestHeadersPolicy: &contour_api_v1.HeadersPolicy{
+						Set: []contour_api_v1.HeaderValue{{
+							Name:  "x-shadow",
+							Value: "true",
+						}},
+					},
+					ResponseHeadersPolicy: &contour_api_v1.HeadersPolicy{
+						Set: []contour_api_v1.HeaderValue{{
+							Name:  "x-shadow",
+							Value: "true",
+						}},
+					},
+				}},
+			}},
+		},
+	}
+
+	mirrorWithHeadersCondition := fixture.NewValidCondition().WithGeneration(proxyMirrorWithHeaders.Generation)
+	mirrorWithHeadersCondition.Valid()
+
+	run(t, "proxy with mirror with header policies", testcase{
+		objs: []interface{}{proxyMirrorWithHeaders, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyMirrorWithHeaders.Name, Namespace: proxyMirrorWithHeaders.Namespace}: mirrorWithHeadersCondition.
+				WithWarning(contour_api_v1.ConditionTypeServiceError, "IgnoredField",
+					`ignoring header policies on mirror service "kuard"; the headers of mirrored requests are set by its mirrorPolicy`),
+		},
+	})
+
+	proxyMirrorPolicyHost := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}, {
+					Name:   fixture.ServiceRootsKuard.Name,
+					Port:   8080,
+					Mirror: true,
+					MirrorPolicy: &contour_api_v1.MirrorPolicy{
+						RequestHeaders: []contour_api_v1.HeaderValue{{
+							Name:  "Host",
+							Value: "shadow.example.com",
+						}},
+					},
+				}},
+			}},
+		},
+	}
+
+	run(t, "proxy with mirror policy that rewrites the host header", testcase{
+		objs: []interface{}{proxyMirrorPolicyHost, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyMirrorPolicyHost.Name, Namespace: proxyMirrorPolicyHost.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyMirrorPolicyHost.Generation).
+				WithError(contour_api_v1.ConditionTypeServiceError, "MirrorPolicyInvalid",
+					`rewriting "Host" header is not supported on mirror service "kuard"`),
+		},
+	})
+
+	proxyMirrorPolicyNotMirror := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+					MirrorPolicy: &contour_api_v1.MirrorPolicy{
+						RequestHeaders: []contour_api_v1.HeaderValue{{
+							Name:  "x-shadow",
+							Value: "true",
+						}},
+					},
+				}},
+			}},
+		},
+	}
+
+	mirrorPolicyNotMirrorCondition := fixture.NewValidCondition().WithGeneration(proxyMirrorPolicyNotMirror.Generation)
+	mirrorPolicyNotMirrorCondition.Valid()
+
+	run(t, "proxy with mirror policy on a service that is not a mirror", testcase{
+		objs: []interface{}{proxyMirrorPolicyNotMirror, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyMirrorPolicyNotMirror.Name, Namespace: proxyMirrorPolicyNotMirror.Namespace}: mirrorPolicyNotMirrorCondition.
+				WithWarning(contour_api_v1.ConditionTypeServiceError, "IgnoredField",
+					`ignoring mirrorPolicy on service "kuard", which is not a mirror`),
+		},
+	})
+
+	proxyZeroWeightService := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name:   fixture.ServiceRootsKuard.Name,
+					Port:   8080,
+					Weight: 90,
+				}, {
+					Name: fixture.ServiceRootsHome.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	zeroWeightServiceCondition := fixture.NewValidCondition().WithGeneration(proxyZeroWeightService.Generation)
+	zeroWeightServiceCondition.Valid()
+
+	run(t, "proxy with a service of zero weight", testcase{
+		objs: []interface{}{proxyZeroWeightService, fixture.ServiceRootsKuard, fixture.ServiceRootsHome},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyZeroWeightService.Name, Namespace: proxyZeroWeightService.Namespace}: zeroWeightServiceCondition.
+				WithWarning(contour_api_v1.ConditionTypeServiceError, "ZeroWeightService",
+					`service "home" has a weight of zero and receives no traffic`),
+		},
+	})
+
+	proxyPermitInsecureTLS := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "www",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: fixture.SecretRootsCert.Name,
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/insecure",
+				}},
+				PermitInsecure: true,
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	permitInsecureTLSCondition := fixture.NewValidCondition().WithGeneration(proxyPermitInsecureTLS.Generation)
+	permitInsecureTLSCondition.Valid()
+
+	run(t, "proxy permits insecure requests to a TLS virtual host", testcase{
+		objs: []interface{}{proxyPermitInsecureTLS, fixture.SecretRootsCert, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyPermitInsecureTLS.Name, Namespace: proxyPermitInsecureTLS.Namespace}: permitInsecureTLSCondition.
+				WithWarning(contour_api_v1.ConditionTypeRouteError, "PermitInsecure",
+					`route "prefix: /insecure" permits insecure requests to a virtual host that terminates TLS`),
+		},
+	})
+
+	proxyGRPCWithPrefix := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "grpc",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/api",
+				}},
+				GRPC: &contour_api_v1.GRPCRoute{
+					Service: "helloworld.Greeter",
+				},
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	run(t, "proxy with grpc route and prefix condition", testcase{
+		objs: []interface{}{proxyGRPCWithPrefix, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyGRPCWithPrefix.Name, Namespace: proxyGRPCWithPrefix.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyGRPCWithPrefix.Generation).
+				WithError(contour_api_v1.ConditionTypeRouteError, "GRPCMatchNotValid", "route.grpc cannot be combined with a prefix condition"),
+		},
+	})
+
+	proxyGRPCInvalidMaxTimeout := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "grpc",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				GRPC: &contour_api_v1.GRPCRoute{
+					Service:    "helloworld.Greeter",
+					MaxTimeout: "forever",
+				},
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	run(t, "proxy with grpc route and invalid max timeout", testcase{
+		objs: []interface{}{proxyGRPCInvalidMaxTimeout, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyGRPCInvalidMaxTimeout.Name, Namespace: proxyGRPCInvalidMaxTimeout.Namespace}: fixture.NewValidCondition().
+				WithGeneration(proxyGRPCInvalidMaxTimeout.Generation).
+				WithError(contour_api_v1.ConditionTypeRouteError, "GRPCMatchNotValid", `route.grpc.maxTimeout failed to parse: unable to parse timeout string "forever": time: invalid duration "forever"`),
+		},
+	})
+
 	proxyInvalidTwoMirrors := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "www",
@@ -1665,7 +2050,7 @@ func TestDAGStatus(t *testing.T) {
 		},
 	})
 
-	proxyInvalidDuplicateHeaderAndPathConditions := &contour_api_v1.HTTPProxy{
+	proxyShadowedIncludeConditions := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: "roots",
 			Name:      "example",
@@ -1675,29 +2060,85 @@ func TestDAGStatus(t *testing.T) {
 				Fqdn: "example.com",
 			},
 			Includes: []contour_api_v1.Include{{
-				Name:      "blogteama",
-				Namespace: "teama",
+				Name:      proxyValidBlogTeamA.Name,
+				Namespace: proxyValidBlogTeamA.Namespace,
 				Conditions: []contour_api_v1.MatchCondition{{
 					Prefix: "/blog",
 					Header: &contour_api_v1.HeaderMatchCondition{
-						Name:     "x-header",
-						Contains: "abc",
+						Name:    "X-Header",
+						Present: true,
 					},
 				}},
 			}, {
-				Name:      "blogteamb",
-				Namespace: "teamb",
+				Name:      proxyValidBlogTeamB.Name,
+				Namespace: proxyValidBlogTeamB.Namespace,
 				Conditions: []contour_api_v1.MatchCondition{{
 					Prefix: "/blog",
+				}, {
 					Header: &contour_api_v1.HeaderMatchCondition{
 						Name:     "x-header",
 						Contains: "abc",
 					},
 				}},
 			}},
-			Routes: []contour_api_v1.Route{{
-				Conditions: []contour_api_v1.MatchCondition{{
-					Prefix: "/",
+		},
+	}
+
+	blogTeamAKuard := fixture.NewService("blogteama/kuard").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+	blogTeamBKuard := fixture.NewService("blogteamb/kuard").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+
+	shadowedIncludeCondition := fixture.NewValidCondition()
+	shadowedIncludeCondition.Valid()
+
+	run(t, "include shadowed by a broader include", testcase{
+		objs: []interface{}{proxyShadowedIncludeConditions, proxyValidBlogTeamA, proxyValidBlogTeamB, blogTeamAKuard, blogTeamBKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyShadowedIncludeConditions.Name,
+				Namespace: proxyShadowedIncludeConditions.Namespace}: shadowedIncludeCondition.
+				WithWarning(contour_api_v1.ConditionTypeIncludeError, "IncludeShadowed",
+					"include blogteamb/teamb is shadowed by include blogteama/teama, which matches all of its requests first"),
+			{Name: proxyValidBlogTeamA.Name,
+				Namespace: proxyValidBlogTeamA.Namespace}: fixture.NewValidCondition().Valid(),
+			{Name: proxyValidBlogTeamB.Name,
+				Namespace: proxyValidBlogTeamB.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyInvalidDuplicateHeaderAndPathConditions := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "example",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []contour_api_v1.Include{{
+				Name:      "blogteama",
+				Namespace: "teama",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/blog",
+					Header: &contour_api_v1.HeaderMatchCondition{
+						Name:     "x-header",
+						Contains: "abc",
+					},
+				}},
+			}, {
+				Name:      "blogteamb",
+				Namespace: "teamb",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/blog",
+					Header: &contour_api_v1.HeaderMatchCondition{
+						Name:     "x-header",
+						Contains: "abc",
+					},
+				}},
+			}},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
 				}},
 				Services: []contour_api_v1.Service{{
 					Name: "home",
@@ -1737,6 +2178,58 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
+	proxyIncludesChildOverQuota := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "roots",
+			Name:              "example",
+			CreationTimestamp: metav1.NewTime(time.Unix(1, 0)),
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}},
+			Includes: []contour_api_v1.Include{{
+				Name: "child",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/child",
+				}},
+			}},
+		},
+	}
+
+	proxyChildOverQuota := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "roots",
+			Name:              "child",
+			CreationTimestamp: metav1.NewTime(time.Unix(2, 0)),
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	run(t, "httpproxy includes a proxy over its namespace quota", testcase{
+		objs:           []interface{}{proxyIncludesChildOverQuota, proxyChildOverQuota, fixture.ServiceRootsKuard},
+		namespaceQuota: NamespaceQuota{Routes: 1},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyIncludesChildOverQuota.Name, Namespace: proxyIncludesChildOverQuota.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeIncludeError, "IncludeOverQuota", "include roots/child exceeds the quota of its namespace"),
+			{Name: proxyChildOverQuota.Name, Namespace: proxyChildOverQuota.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeQuotaError, "QuotaExceeded", `namespace "roots" would exceed its quota of 1 routes`),
+		},
+	})
+
 	run(t, "httpproxy w/ missing include", testcase{
 		objs: []interface{}{proxyInvalidMissingInclude, fixture.ServiceRootsKuard},
 		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
@@ -1829,6 +2322,130 @@ func TestDAGStatus(t *testing.T) {
 		},
 	})
 
+	proxyTCPPlainPort := func(name, fqdn string, port int, tls *contour_api_v1.TLS) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: fixture.ServiceRootsKuard.Namespace,
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: fqdn,
+					TLS:  tls,
+				},
+				TCPProxy: &contour_api_v1.TCPProxy{
+					Port: port,
+					Services: []contour_api_v1.Service{{
+						Name: fixture.ServiceRootsKuard.Name,
+						Port: 8080,
+					}},
+				},
+			},
+		}
+	}
+
+	proxyTCPValidPlainPort := proxyTCPPlainPort("redis", "redis.example.com", 6379, nil)
+
+	run(t, "httpproxy w/ tcpproxy on plain tcp listener", testcase{
+		objs: []interface{}{proxyTCPValidPlainPort, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPValidPlainPort.Name, Namespace: proxyTCPValidPlainPort.Namespace}: fixture.NewValidCondition().Valid(),
+		},
+	})
+
+	proxyTCPInvalidPlainPortTLS := proxyTCPPlainPort("redis", "redis.example.com", 6379, &contour_api_v1.TLS{Passthrough: true})
+
+	run(t, "httpproxy w/ tcpproxy on plain tcp listener with tls", testcase{
+		objs: []interface{}{proxyTCPInvalidPlainPortTLS, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPInvalidPlainPortTLS.Name, Namespace: proxyTCPInvalidPlainPortTLS.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTCPProxyError, "TLSNotSupported", "Spec.TCPProxy.Port cannot be combined with Spec.VirtualHost.TLS"),
+		},
+	})
+
+	proxyTCPInvalidPlainPortUnknown := proxyTCPPlainPort("memcached", "memcached.example.com", 11211, nil)
+
+	run(t, "httpproxy w/ tcpproxy on unconfigured tcp listener", testcase{
+		objs: []interface{}{proxyTCPInvalidPlainPortUnknown, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPInvalidPlainPortUnknown.Name, Namespace: proxyTCPInvalidPlainPortUnknown.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTCPProxyError, "PortNotConfigured", "Spec.TCPProxy.Port 11211 is not a configured TCP listener port"),
+		},
+	})
+
+	proxyTCPInvalidPlainPortDuplicate := proxyTCPPlainPort("redis-replica", "redis-replica.example.com", 6379, nil)
+
+	run(t, "httpproxies w/ tcpproxy on the same plain tcp listener", testcase{
+		objs: []interface{}{proxyTCPValidPlainPort, proxyTCPInvalidPlainPortDuplicate, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPValidPlainPort.Name, Namespace: proxyTCPValidPlainPort.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTCPProxyError, "DuplicatePort", "Spec.TCPProxy.Port 6379 is used in multiple HTTPProxies: roots/redis, roots/redis-replica"),
+			{Name: proxyTCPInvalidPlainPortDuplicate.Name, Namespace: proxyTCPInvalidPlainPortDuplicate.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTCPProxyError, "DuplicatePort", "Spec.TCPProxy.Port 6379 is used in multiple HTTPProxies: roots/redis, roots/redis-replica"),
+		},
+	})
+
+	proxyTCPInvalidConnectTimeout := proxyTCPPlainPort("redis", "redis.example.com", 6379, nil)
+	proxyTCPInvalidConnectTimeout.Spec.TCPProxy.TimeoutPolicy = &contour_api_v1.TCPProxyTimeoutPolicy{
+		Connect: "infinity",
+	}
+
+	run(t, "httpproxy w/ tcpproxy with disabled connect timeout", testcase{
+		objs: []interface{}{proxyTCPInvalidConnectTimeout, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPInvalidConnectTimeout.Name, Namespace: proxyTCPInvalidConnectTimeout.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTCPProxyError, "TimeoutPolicyNotValid", "Spec.TCPProxy.TimeoutPolicy connect timeout cannot be disabled"),
+		},
+	})
+
+	proxyTCPInvalidIdleTimeout := proxyTCPPlainPort("redis", "redis.example.com", 6379, nil)
+	proxyTCPInvalidIdleTimeout.Spec.TCPProxy.TimeoutPolicy = &contour_api_v1.TCPProxyTimeoutPolicy{
+		Idle: "forever",
+	}
+
+	run(t, "httpproxy w/ tcpproxy with invalid idle timeout", testcase{
+		objs: []interface{}{proxyTCPInvalidIdleTimeout, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPInvalidIdleTimeout.Name, Namespace: proxyTCPInvalidIdleTimeout.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTCPProxyError, "TimeoutPolicyNotValid", `Spec.TCPProxy.TimeoutPolicy failed to parse: error parsing idle timeout: unable to parse timeout string "forever": time: invalid duration "forever"`),
+		},
+	})
+
+	kuardTLS := fixture.NewService("roots/kuard-tls").
+		Annotate("projectcontour.io/upstream-protocol.tls", "8080").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+
+	proxyTCPUpstreamCANotDelegated := proxyTCPPlainPort("redis", "redis.example.com", 6379, nil)
+	proxyTCPUpstreamCANotDelegated.Spec.TCPProxy.Services = []contour_api_v1.Service{{
+		Name: kuardTLS.Name,
+		Port: 8080,
+		UpstreamValidation: &contour_api_v1.UpstreamValidation{
+			CACertificate: "other/ca",
+			SubjectName:   "kuard.example.com",
+		},
+	}}
+
+	run(t, "httpproxy w/ tcpproxy with undelegated upstream validation CA", testcase{
+		objs: []interface{}{proxyTCPUpstreamCANotDelegated, kuardTLS},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPUpstreamCANotDelegated.Name, Namespace: proxyTCPUpstreamCANotDelegated.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTLSError, "CACertificateNotDelegated", `service.UpstreamValidation.CACertificate Secret "other/ca" is not configured for certificate delegation`),
+		},
+	})
+
+	proxyTCPInvalidHealthCheckPayload := proxyTCPPlainPort("redis", "redis.example.com", 6379, nil)
+	proxyTCPInvalidHealthCheckPayload.Spec.TCPProxy.HealthCheckPolicy = &contour_api_v1.TCPHealthCheckPolicy{
+		Send: "PING",
+	}
+
+	run(t, "httpproxy w/ tcpproxy with invalid health check payload", testcase{
+		objs: []interface{}{proxyTCPInvalidHealthCheckPayload, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyTCPInvalidHealthCheckPayload.Name, Namespace: proxyTCPInvalidHealthCheckPayload.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeTCPProxyError, "HealthCheckPolicyNotValid", `Spec.TCPProxy.HealthCheckPolicy is invalid: send payload "PING" is not hex encoded`),
+		},
+	})
+
 	proxyInvalidMissingServiceWithTCPProxy := &contour_api_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "missing-route-service",
@@ -1993,6 +2610,82 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
+	proxyInvalidMultipleErrors := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "multiple-errors",
+			Namespace: fixture.ServiceRootsKuard.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "multiple-errors.example.com",
+			},
+			Includes: []contour_api_v1.Include{{
+				Name: "missing",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/missing",
+				}},
+			}},
+			Routes: []contour_api_v1.Route{{
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "api",
+				}},
+				Services: []contour_api_v1.Service{{
+					Name: fixture.ServiceRootsKuard.Name,
+					Port: 8080,
+				}},
+			}, {
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/empty",
+				}},
+			}},
+		},
+	}
+
+	// Every invalid include and route is reported, not just the first.
+	multipleErrors := fixture.NewValidCondition()
+	multipleErrors.WithError(contour_api_v1.ConditionTypeIncludeError, "IncludeNotFound", "include roots/missing not found")
+	multipleErrors.WithError(contour_api_v1.ConditionTypeRouteError, "PathMatchConditionsNotValid", "route: prefix conditions must start with /, api was supplied")
+
+	run(t, "invalid HTTPProxy reports all of its errors", testcase{
+		objs: []interface{}{proxyInvalidMultipleErrors, fixture.ServiceRootsKuard},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInvalidMultipleErrors.Name, Namespace: proxyInvalidMultipleErrors.Namespace}: multipleErrors.
+				WithError(contour_api_v1.ConditionTypeRouteError, "NoServicesPresent", "route.services must have at least one entry"),
+		},
+	})
+
+	// With partial validity the same errors are reported,
+	// but the HTTPProxy's valid route is still in use.
+	partiallyValid := contour_api_v1.DetailedCondition(*multipleErrors)
+	partiallyValid.Reason = "PartiallyValid"
+	partiallyValid.Message = "Some routes or includes are not valid and are not in use, see Errors for details"
+
+	run(t, "partially valid HTTPProxy reports all of its errors", testcase{
+		objs:            []interface{}{proxyInvalidMultipleErrors, fixture.ServiceRootsKuard},
+		partialValidity: true,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInvalidMultipleErrors.Name, Namespace: proxyInvalidMultipleErrors.Namespace}: partiallyValid,
+		},
+	})
+
+	// A partially valid HTTPProxy without any valid routes is invalid.
+	run(t, "invalid HTTPProxy due to empty route.service with partial validity", testcase{
+		objs:            []interface{}{proxyInvalidNoServices, fixture.ServiceRootsKuard},
+		partialValidity: true,
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: proxyInvalidNoServices.Name, Namespace: proxyInvalidNoServices.Namespace}: fixture.NewValidCondition().
+				WithError(contour_api_v1.ConditionTypeRouteError, "NoServicesPresent", "route.services must have at least one entry"),
+		},
+	})
+
 	run(t, "invalid HTTPProxy due to empty route.service", testcase{
 		objs: []interface{}{proxyInvalidNoServices, fixture.ServiceRootsKuard},
 		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
@@ -2485,14 +3178,298 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
-	run(t, "valid TLS passthrough", testcase{
-		objs: []interface{}{tlsPassthrough, fixture.ServiceRootsHome},
-		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
-			{Name: tlsPassthrough.Name, Namespace: tlsPassthrough.Namespace}: fixture.NewValidCondition().
-				WithGeneration(tlsPassthrough.Generation).
-				Valid(),
-		},
-	})
+	run(t, "valid TLS passthrough", testcase{
+		objs: []interface{}{tlsPassthrough, fixture.ServiceRootsHome},
+		want: map[types.NamespacedName]contour_api_v1.DetailedCondition{
+			{Name: tlsPassthrough.Name, Namespace: tlsPassthrough.Namespace}: fixture.NewValidCondition().
+				WithGeneration(tlsPassthrough.Generation).
+				Valid(),
+		},
+	})
+}
+
+func TestDAGIncludeStatus(t *testing.T) {
+	parent := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "parent",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []contour_api_v1.Include{{
+				Name: "child",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/child",
+				}},
+			}, {
+				Name: "missing",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/missing",
+				}},
+			}, {
+				Name: "broken",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/broken",
+				}},
+			}},
+		},
+	}
+
+	child := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "child",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	// broken is invalid because its route has no services.
+	broken := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "broken",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Routes: []contour_api_v1.Route{{}},
+		},
+	}
+
+	builder := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&HTTPProxyProcessor{},
+			&ListenerProcessor{},
+		},
+	}
+	for _, o := range []interface{}{parent, child, broken, fixture.ServiceRootsHome} {
+		builder.Source.Insert(o)
+	}
+	dag := builder.Build()
+
+	got := make(map[types.NamespacedName][]contour_api_v1.IncludeStatus)
+	for _, pu := range dag.StatusCache.GetProxyUpdates() {
+		got[pu.Fullname] = pu.Includes
+	}
+
+	want := map[types.NamespacedName][]contour_api_v1.IncludeStatus{
+		{Namespace: "roots", Name: "parent"}: {{
+			Name:      "child",
+			Namespace: "roots",
+			Status:    contour_api_v1.ConditionTrue,
+		}, {
+			Name:      "missing",
+			Namespace: "roots",
+			Status:    contour_api_v1.ConditionFalse,
+			Reason:    "IncludeNotFound",
+			Message:   "include roots/missing not found",
+		}, {
+			Name:      "broken",
+			Namespace: "roots",
+			Status:    contour_api_v1.ConditionFalse,
+			Reason:    "IncludeNotValid",
+			Message:   "included HTTPProxy roots/broken is not valid: At least one error present, see Errors for details",
+		}},
+		{Namespace: "roots", Name: "child"}:  nil,
+		{Namespace: "roots", Name: "broken"}: nil,
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestDAGShardStatus(t *testing.T) {
+	root := func(namespace, fqdn, include string) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      "root",
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: fqdn,
+				},
+				Includes: []contour_api_v1.Include{{
+					Name:      include,
+					Namespace: "roots",
+				}},
+			},
+		}
+	}
+
+	child := func(name string) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "roots",
+				Name:      name,
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name: "home",
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+	}
+
+	// stagingchild is only included by the root of another
+	// shard, through which grandchild is included.
+	stagingChild := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "stagingchild",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			Includes: []contour_api_v1.Include{{
+				Name: "grandchild",
+				Conditions: []contour_api_v1.MatchCondition{{
+					Prefix: "/grandchild",
+				}},
+			}},
+		},
+	}
+
+	builder := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+			Shard:       &shard.Selector{Namespaces: []string{"prod"}},
+		},
+		Processors: []Processor{
+			&HTTPProxyProcessor{},
+			&ListenerProcessor{},
+		},
+	}
+	for _, o := range []interface{}{
+		root("prod", "prod.example.com", "prodchild"),
+		root("staging", "staging.example.com", "stagingchild"),
+		child("prodchild"),
+		stagingChild,
+		child("grandchild"),
+		child("orphan"),
+		fixture.ServiceRootsHome,
+	} {
+		builder.Source.Insert(o)
+	}
+	dag := builder.Build()
+
+	got := make(map[types.NamespacedName]contour_api_v1.ConditionStatus)
+	for _, pu := range dag.StatusCache.GetProxyUpdates() {
+		got[pu.Fullname] = pu.Conditions[status.ValidCondition].Status
+	}
+
+	want := map[types.NamespacedName]contour_api_v1.ConditionStatus{
+		{Namespace: "prod", Name: "root"}:       contour_api_v1.ConditionTrue,
+		{Namespace: "roots", Name: "prodchild"}: contour_api_v1.ConditionTrue,
+		{Namespace: "roots", Name: "orphan"}:    contour_api_v1.ConditionFalse,
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestTLSCertificateDelegationDAGStatus(t *testing.T) {
+	delegation := &contour_api_v1.TLSCertificateDelegation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  fixture.SecretRootsCert.Namespace,
+			Name:       "delegation",
+			Generation: 3,
+		},
+		Spec: contour_api_v1.TLSCertificateDelegationSpec{
+			Delegations: []contour_api_v1.CertificateDelegation{{
+				SecretName:       fixture.SecretRootsCert.Name,
+				TargetNamespaces: []string{"marketing"},
+			}, {
+				SecretName:       "missing",
+				TargetNamespaces: []string{"*"},
+			}, {
+				SecretName: fixture.SecretRootsFallback.Name,
+			}},
+		},
+	}
+
+	proxy := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "marketing",
+			Name:      "www",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: fixture.SecretRootsCert.Namespace + "/" + fixture.SecretRootsCert.Name,
+				},
+			},
+		},
+	}
+
+	// notDelegated references the secret from a namespace it is
+	// not delegated to.
+	notDelegated := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "teama",
+			Name:      "www",
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.org",
+				TLS: &contour_api_v1.TLS{
+					SecretName: fixture.SecretRootsCert.Namespace + "/" + fixture.SecretRootsCert.Name,
+				},
+			},
+		},
+	}
+
+	builder := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&TLSCertificateDelegationProcessor{
+				FieldLogger: fixture.NewTestLogger(t),
+			},
+		},
+	}
+	for _, o := range []interface{}{delegation, proxy, notDelegated, fixture.SecretRootsCert, fixture.SecretRootsFallback} {
+		builder.Source.Insert(o)
+	}
+	dag := builder.Build()
+
+	entry, ok := dag.StatusCache.Get(delegation).(*status.DelegationCacheEntry)
+	if !ok {
+		t.Fatalf("no status for TLSCertificateDelegation %s/%s", delegation.Namespace, delegation.Name)
+	}
+
+	want := fixture.NewValidCondition()
+	want.WithError(contour_api_v1.ConditionTypeTLSError, "SecretNotFound",
+		`delegated Secret "roots/missing" not found`)
+	assert.Equal(t,
+		want.WithWarning(contour_api_v1.ConditionTypeSpecError, "NoTargetNamespaces",
+			`delegation of Secret "roots/fallbacksecret" has no target namespaces and is ignored`),
+		*entry.ConditionFor(status.ValidCondition))
+
+	assert.Equal(t, []contour_api_v1.CertificateDelegationStatus{{
+		SecretName: fixture.SecretRootsCert.Name,
+		InUse:      true,
+		Consumers: []contour_api_v1.DelegationConsumer{{
+			Kind:      "HTTPProxy",
+			Namespace: "marketing",
+			Name:      "www",
+		}},
+	}, {
+		SecretName: "missing",
+	}, {
+		SecretName: fixture.SecretRootsFallback.Name,
+	}}, entry.Delegations)
 }
 
 func TestGatewayAPIHTTPRouteDAGStatus(t *testing.T) {
@@ -2621,6 +3598,21 @@ func TestGatewayAPIHTTPRouteDAGStatus(t *testing.T) {
 		},
 	}
 
+	kuardService2 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard2",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
 	run(t, "simple httproute", testcase{
 		objs: []interface{}{
 			kuardService,
@@ -3199,9 +4191,10 @@ func TestGatewayAPIHTTPRouteDAGStatus(t *testing.T) {
 		wantGatewayConditions: validGatewayConditionsUpdate,
 	})
 
-	run(t, "HTTPRouteFilterRequestMirror not yet supported for httproute rule", testcase{
+	run(t, "HTTPRouteFilterRequestMirror for httproute rule", testcase{
 		objs: []interface{}{
 			kuardService,
+			kuardService2,
 			&gatewayapi_v1alpha1.HTTPRoute{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "basic",
@@ -3224,7 +4217,11 @@ func TestGatewayAPIHTTPRouteDAGStatus(t *testing.T) {
 							Port:        gatewayPort(8080),
 						}},
 						Filters: []gatewayapi_v1alpha1.HTTPRouteFilter{{
-							Type: gatewayapi_v1alpha1.HTTPRouteFilterRequestMirror, // HTTPRouteFilterRequestMirror is not supported yet.
+							Type: gatewayapi_v1alpha1.HTTPRouteFilterRequestMirror,
+							RequestMirror: &gatewayapi_v1alpha1.HTTPRequestMirrorFilter{
+								ServiceName: pointer.StringPtr("kuard2"),
+								Port:        gatewayPort(8080),
+							},
 						}},
 					}},
 				},
@@ -3232,11 +4229,58 @@ func TestGatewayAPIHTTPRouteDAGStatus(t *testing.T) {
 		wantRouteConditions: []*status.RouteConditionsUpdate{{
 			FullName: types.NamespacedName{Namespace: "default", Name: "basic"},
 			Conditions: map[gatewayapi_v1alpha1.RouteConditionType]metav1.Condition{
-				status.ConditionNotImplemented: {
-					Type:    string(status.ConditionNotImplemented),
+				gatewayapi_v1alpha1.ConditionRouteAdmitted: {
+					Type:    string(gatewayapi_v1alpha1.ConditionRouteAdmitted),
 					Status:  contour_api_v1.ConditionTrue,
-					Reason:  string(status.ReasonHTTPRouteFilterType),
-					Message: "HTTPRoute.Spec.Rules.Filters: Only RequestHeaderModifier type is supported.",
+					Reason:  string(status.ValidCondition),
+					Message: "Valid HTTPRoute",
+				},
+			},
+		}},
+		wantGatewayConditions: validGatewayConditionsUpdate,
+	})
+
+	run(t, "HTTPRouteFilterRequestMirror with missing service for httproute rule", testcase{
+		objs: []interface{}{
+			kuardService,
+			&gatewayapi_v1alpha1.HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "basic",
+					Namespace: "default",
+					Labels: map[string]string{
+						"app": "contour",
+					},
+				},
+				Spec: gatewayapi_v1alpha1.HTTPRouteSpec{
+					Gateways: &gatewayapi_v1alpha1.RouteGateways{
+						Allow: gatewayAllowTypePtr(gatewayapi_v1alpha1.GatewayAllowAll),
+					},
+					Hostnames: []gatewayapi_v1alpha1.Hostname{
+						"test.projectcontour.io",
+					},
+					Rules: []gatewayapi_v1alpha1.HTTPRouteRule{{
+						Matches: httpRouteMatch(gatewayapi_v1alpha1.PathMatchPrefix, "/"),
+						ForwardTo: []gatewayapi_v1alpha1.HTTPRouteForwardTo{{
+							ServiceName: pointer.StringPtr("kuard"),
+							Port:        gatewayPort(8080),
+						}},
+						Filters: []gatewayapi_v1alpha1.HTTPRouteFilter{{
+							Type: gatewayapi_v1alpha1.HTTPRouteFilterRequestMirror,
+							RequestMirror: &gatewayapi_v1alpha1.HTTPRequestMirrorFilter{
+								Port: gatewayPort(8080),
+							},
+						}},
+					}},
+				},
+			}},
+		wantRouteConditions: []*status.RouteConditionsUpdate{{
+			FullName: types.NamespacedName{Namespace: "default", Name: "basic"},
+			Conditions: map[gatewayapi_v1alpha1.RouteConditionType]metav1.Condition{
+				status.ConditionResolvedRefs: {
+					Type:    string(status.ConditionResolvedRefs),
+					Status:  contour_api_v1.ConditionFalse,
+					Reason:  string(status.ReasonDegraded),
+					Message: "Spec.Rules.Filters.RequestMirror.ServiceName must be specified",
 				},
 				gatewayapi_v1alpha1.ConditionRouteAdmitted: {
 					Type:    string(gatewayapi_v1alpha1.ConditionRouteAdmitted),
@@ -4003,3 +5047,217 @@ func TestGatewayAPITLSRouteDAGStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestGatewayAPITCPRouteDAGStatus(t *testing.T) {
+
+	type testcase struct {
+		objs                  []interface{}
+		wantRouteConditions   []*status.RouteConditionsUpdate
+		wantGatewayConditions []*status.GatewayConditionsUpdate
+	}
+
+	run := func(t *testing.T, desc string, tc testcase) {
+		t.Helper()
+		t.Run(desc, func(t *testing.T) {
+			t.Helper()
+			builder := Builder{
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+					ConfiguredGateway: types.NamespacedName{
+						Namespace: "contour",
+						Name:      "projectcontour",
+					},
+					gateway: &gatewayapi_v1alpha1.Gateway{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "contour",
+							Namespace: "projectcontour",
+						},
+						Spec: gatewayapi_v1alpha1.GatewaySpec{
+							Listeners: []gatewayapi_v1alpha1.Listener{{
+								Port:     9000,
+								Protocol: gatewayapi_v1alpha1.TCPProtocolType,
+								Routes: gatewayapi_v1alpha1.RouteBindingSelector{
+									Kind: KindTCPRoute,
+								},
+							}},
+						},
+					},
+					gatewayclass: &gatewayapi_v1alpha1.GatewayClass{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "test-gc",
+						},
+						Spec: gatewayapi_v1alpha1.GatewayClassSpec{
+							Controller: "projectcontour.io/contour",
+						},
+						Status: gatewayapi_v1alpha1.GatewayClassStatus{
+							Conditions: []metav1.Condition{
+								{
+									Type:   string(gatewayapi_v1alpha1.GatewayClassConditionStatusAdmitted),
+									Status: metav1.ConditionTrue,
+								},
+							},
+						},
+					},
+				},
+				Processors: []Processor{
+					&GatewayAPIProcessor{
+						FieldLogger: fixture.NewTestLogger(t),
+						TCPListeners: map[int]string{
+							9000: "tcp-9000",
+						},
+					},
+					&ListenerProcessor{},
+				},
+			}
+
+			for _, o := range tc.objs {
+				builder.Source.Insert(o)
+			}
+			dag := builder.Build()
+			gotRouteUpdates := dag.StatusCache.GetRouteUpdates()
+			gotGatewayUpdates := dag.StatusCache.GetGatewayUpdates()
+
+			ops := []cmp.Option{
+				cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime"),
+				cmpopts.IgnoreFields(status.RouteConditionsUpdate{}, "ExistingConditions"),
+				cmpopts.IgnoreFields(status.RouteConditionsUpdate{}, "GatewayRef"),
+				cmpopts.IgnoreFields(status.RouteConditionsUpdate{}, "Generation"),
+				cmpopts.IgnoreFields(status.RouteConditionsUpdate{}, "TransitionTime"),
+				cmpopts.IgnoreFields(status.RouteConditionsUpdate{}, "Resource"),
+				cmpopts.IgnoreFields(status.GatewayConditionsUpdate{}, "ExistingConditions"),
+				cmpopts.IgnoreFields(status.GatewayConditionsUpdate{}, "GatewayRef"),
+				cmpopts.IgnoreFields(status.GatewayConditionsUpdate{}, "Generation"),
+				cmpopts.IgnoreFields(status.GatewayConditionsUpdate{}, "TransitionTime"),
+				cmpopts.IgnoreFields(status.GatewayConditionsUpdate{}, "Resource"),
+				cmpopts.SortSlices(func(i, j metav1.Condition) bool {
+					return i.Message < j.Message
+				}),
+			}
+
+			if diff := cmp.Diff(tc.wantRouteConditions, gotRouteUpdates, ops...); diff != "" {
+				t.Fatalf("expected route status: %v, got %v", tc.wantRouteConditions, diff)
+			}
+
+			if diff := cmp.Diff(tc.wantGatewayConditions, gotGatewayUpdates, ops...); diff != "" {
+				t.Fatalf("expected gateway status: %v, got %v", tc.wantGatewayConditions, diff)
+			}
+		})
+	}
+
+	kuardService := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
+	run(t, "TCPRoute: valid", testcase{
+		objs: []interface{}{
+			kuardService,
+			&gatewayapi_v1alpha1.TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "basic",
+					Namespace: "default",
+				},
+				Spec: gatewayapi_v1alpha1.TCPRouteSpec{
+					Rules: []gatewayapi_v1alpha1.TCPRouteRule{{
+						ForwardTo: tcpRouteForwardTo("kuard", 8080, 0),
+					}},
+				},
+			}},
+		wantRouteConditions: []*status.RouteConditionsUpdate{{
+			FullName: types.NamespacedName{Namespace: "default", Name: "basic"},
+			Conditions: map[gatewayapi_v1alpha1.RouteConditionType]metav1.Condition{
+				gatewayapi_v1alpha1.ConditionRouteAdmitted: {
+					Type:    string(gatewayapi_v1alpha1.ConditionRouteAdmitted),
+					Status:  contour_api_v1.ConditionTrue,
+					Reason:  string(status.ValidCondition),
+					Message: "Valid TCPRoute",
+				},
+			},
+		}},
+		wantGatewayConditions: validGatewayConditionsUpdate,
+	})
+
+	run(t, "TCPRoute: spec.rules.forwardTo.serviceName not found", testcase{
+		objs: []interface{}{
+			&gatewayapi_v1alpha1.TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "basic",
+					Namespace: "default",
+				},
+				Spec: gatewayapi_v1alpha1.TCPRouteSpec{
+					Rules: []gatewayapi_v1alpha1.TCPRouteRule{{
+						ForwardTo: tcpRouteForwardTo("invalid-one", 8080, 0),
+					}},
+				},
+			}},
+		wantRouteConditions: []*status.RouteConditionsUpdate{{
+			FullName: types.NamespacedName{Namespace: "default", Name: "basic"},
+			Conditions: map[gatewayapi_v1alpha1.RouteConditionType]metav1.Condition{
+				status.ConditionResolvedRefs: {
+					Type:    string(status.ConditionResolvedRefs),
+					Status:  contour_api_v1.ConditionFalse,
+					Reason:  string(status.ReasonDegraded),
+					Message: "service \"invalid-one\" is invalid: service \"default/invalid-one\" not found",
+				},
+				gatewayapi_v1alpha1.ConditionRouteAdmitted: {
+					Type:    string(gatewayapi_v1alpha1.ConditionRouteAdmitted),
+					Status:  contour_api_v1.ConditionFalse,
+					Reason:  "ErrorsExist",
+					Message: "Errors found, check other Conditions for details.",
+				},
+			},
+		}},
+		wantGatewayConditions: validGatewayConditionsUpdate,
+	})
+
+	run(t, "TCPRoute: spec.rules.matches.extensionRef not implemented", testcase{
+		objs: []interface{}{
+			kuardService,
+			&gatewayapi_v1alpha1.TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "basic",
+					Namespace: "default",
+				},
+				Spec: gatewayapi_v1alpha1.TCPRouteSpec{
+					Rules: []gatewayapi_v1alpha1.TCPRouteRule{{
+						Matches: []gatewayapi_v1alpha1.TCPRouteMatch{{
+							ExtensionRef: &gatewayapi_v1alpha1.LocalObjectReference{
+								Group: "example.com",
+								Kind:  "Filter",
+								Name:  "filter",
+							},
+						}},
+						ForwardTo: tcpRouteForwardTo("kuard", 8080, 0),
+					}},
+				},
+			}},
+		wantRouteConditions: []*status.RouteConditionsUpdate{{
+			FullName: types.NamespacedName{Namespace: "default", Name: "basic"},
+			Conditions: map[gatewayapi_v1alpha1.RouteConditionType]metav1.Condition{
+				status.ConditionNotImplemented: {
+					Type:    string(status.ConditionNotImplemented),
+					Status:  contour_api_v1.ConditionTrue,
+					Reason:  string(status.ReasonNotImplemented),
+					Message: "TCPRoute.Spec.Rules.Matches.ExtensionRef: Not yet implemented.",
+				},
+				gatewayapi_v1alpha1.ConditionRouteAdmitted: {
+					Type:    string(gatewayapi_v1alpha1.ConditionRouteAdmitted),
+					Status:  contour_api_v1.ConditionFalse,
+					Reason:  "ErrorsExist",
+					Message: "Errors found, check other Conditions for details.",
+				},
+			},
+		}},
+		wantGatewayConditions: validGatewayConditionsUpdate,
+	})
+}
@@ -16,6 +16,7 @@
 package debug
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/pprof"
 
@@ -50,11 +51,26 @@ func registerProfile(mux *http.ServeMux) {
 	mux.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
 }
 
+// registerDotWriter registers the /debug/dag endpoint, which writes
+// the DAG in DOT format, or in JSON format with ?format=json.
 func registerDotWriter(mux *http.ServeMux, builder *dag.Builder) {
 	mux.HandleFunc("/debug/dag", func(w http.ResponseWriter, r *http.Request) {
-		dw := &dotWriter{
-			Builder: builder,
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "dot":
+			dw := &dotWriter{
+				Builder: builder,
+			}
+			dw.writeDot(w)
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			jw := &jsonWriter{
+				Builder: builder,
+			}
+			if err := jw.writeJSON(w); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		default:
+			http.Error(w, fmt.Sprintf("unsupported format %q, must be dot or json", format), http.StatusBadRequest)
 		}
-		dw.writeDot(w)
 	})
 }
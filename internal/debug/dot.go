@@ -19,6 +19,7 @@ import (
 
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/status"
 )
 
 // quick and dirty dot debugging package
@@ -54,6 +55,8 @@ func (c *ctx) writeVertex(v dag.Vertex) {
 		fmt.Fprintf(c.w, `"%p" [shape=record, label="{http://%s}"]`+"\n", v, v.Name)
 	case *dag.SecureVirtualHost:
 		fmt.Fprintf(c.w, `"%p" [shape=record, label="{https://%s}"]`+"\n", v, v.VirtualHost.Name)
+	case *dag.TCPVirtualHost:
+		fmt.Fprintf(c.w, `"%p" [shape=record, label="{tcp://%s:%d}"]`+"\n", v, v.Name, v.Port)
 	case *dag.Route:
 		fmt.Fprintf(c.w, `"%p" [shape=record, label="{%s}"]`+"\n", v, v.PathMatchCondition.String())
 	case *dag.TCPProxy:
@@ -80,8 +83,19 @@ func (dw *dotWriter) writeDot(w io.Writer) {
 		edges: make(map[pair]bool),
 	}
 
+	// vhosts holds the virtual hosts of each fqdn, so that
+	// the HTTPProxy that configured them can be linked.
+	vhosts := map[string][]dag.Vertex{}
+
 	var visit func(dag.Vertex)
 	visit = func(parent dag.Vertex) {
+		switch v := parent.(type) {
+		case *dag.VirtualHost:
+			vhosts[v.Name] = append(vhosts[v.Name], v)
+		case *dag.SecureVirtualHost:
+			vhosts[v.VirtualHost.Name] = append(vhosts[v.VirtualHost.Name], v)
+		}
+
 		ctx.writeVertex(parent)
 		parent.Visit(func(child dag.Vertex) {
 			visit(child)
@@ -89,7 +103,27 @@ func (dw *dotWriter) writeDot(w io.Writer) {
 		})
 	}
 
-	dw.Builder.Build().Visit(visit)
+	d := dw.Builder.Build()
+	d.Visit(visit)
+
+	// Write the generation and validity of each root HTTPProxy,
+	// linked to the virtual hosts it configured.
+	for _, u := range d.StatusCache.GetProxyUpdates() {
+		if u.Vhost == "" {
+			continue
+		}
+
+		valid := "Valid=Unknown"
+		if c, ok := u.Conditions[status.ValidCondition]; ok {
+			valid = fmt.Sprintf("Valid=%s", c.Status)
+		}
+
+		fmt.Fprintf(w, `"%p" [shape=record, style=dashed, label="{httpproxy|%s/%s|generation %d|%s}"]`+"\n",
+			u, u.Fullname.Namespace, u.Fullname.Name, u.Generation, valid)
+		for _, vh := range vhosts[u.Vhost] {
+			fmt.Fprintf(w, `"%p" -> "%p" [style=dashed]`+"\n", u, vh)
+		}
+	}
 
 	fmt.Fprintln(w, "}")
 }
@@ -0,0 +1,223 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// jsonWriter writes the DAG, and the status of the objects it was
+// built from, as JSON.
+type jsonWriter struct {
+	*dag.Builder
+}
+
+type jsonDAG struct {
+	Vertices []jsonVertex `json:"vertices"`
+	Edges    []jsonEdge   `json:"edges"`
+	Status   []jsonStatus `json:"status"`
+}
+
+type jsonVertex struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type jsonEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type jsonStatus struct {
+	Kind        string      `json:"kind"`
+	Namespace   string      `json:"namespace"`
+	Name        string      `json:"name"`
+	Generation  int64       `json:"generation"`
+	VirtualHost string      `json:"virtualhost,omitempty"`
+	Conditions  interface{} `json:"conditions"`
+}
+
+// jsonVertexOf returns the kind, name and attributes of a vertex.
+func jsonVertexOf(v dag.Vertex) jsonVertex {
+	switch v := v.(type) {
+	case *dag.Listener:
+		return jsonVertex{Kind: "Listener", Name: fmt.Sprintf("%s:%d", v.Address, v.Port)}
+	case *dag.Secret:
+		return jsonVertex{Kind: "Secret", Name: v.Namespace() + "/" + v.Name()}
+	case *dag.Service:
+		return jsonVertex{Kind: "Service", Name: v.Weighted.ServiceNamespace + "/" + v.Weighted.ServiceName, Attributes: map[string]string{
+			"port": strconv.Itoa(int(v.Weighted.ServicePort.Port)),
+		}}
+	case *dag.VirtualHost:
+		return jsonVertex{Kind: "VirtualHost", Name: v.Name}
+	case *dag.SecureVirtualHost:
+		return jsonVertex{Kind: "SecureVirtualHost", Name: v.VirtualHost.Name, Attributes: map[string]string{
+			"minTLSVersion": v.MinTLSVersion,
+		}}
+	case *dag.TCPVirtualHost:
+		return jsonVertex{Kind: "TCPVirtualHost", Name: v.Name, Attributes: map[string]string{
+			"port": strconv.Itoa(v.Port),
+		}}
+	case *dag.Route:
+		attrs := map[string]string{}
+		if v.PathMatchCondition != nil {
+			attrs["path"] = v.PathMatchCondition.String()
+		}
+		var headers []string
+		for _, h := range v.HeaderMatchConditions {
+			headers = append(headers, h.String())
+		}
+		if len(headers) > 0 {
+			attrs["headers"] = strings.Join(headers, ", ")
+		}
+		return jsonVertex{Kind: "Route", Name: attrs["path"], Attributes: attrs}
+	case *dag.TCPProxy:
+		return jsonVertex{Kind: "TCPProxy"}
+	case *dag.Cluster:
+		return jsonVertex{Kind: "Cluster", Name: envoy.Clustername(v), Attributes: map[string]string{
+			"weight": strconv.Itoa(int(v.Weight)),
+		}}
+	default:
+		return jsonVertex{Kind: fmt.Sprintf("%T", v)}
+	}
+}
+
+func (jw *jsonWriter) writeJSON(w io.Writer) error {
+	d := jw.Builder.Build()
+
+	out := jsonDAG{
+		Vertices: []jsonVertex{},
+		Edges:    []jsonEdge{},
+	}
+
+	ids := map[dag.Vertex]string{}
+	edges := map[jsonEdge]bool{}
+
+	id := func(v dag.Vertex) string {
+		if id, ok := ids[v]; ok {
+			return id
+		}
+		vertex := jsonVertexOf(v)
+		vertex.ID = strconv.Itoa(len(ids))
+		ids[v] = vertex.ID
+		out.Vertices = append(out.Vertices, vertex)
+		return vertex.ID
+	}
+
+	var visit func(dag.Vertex)
+	visit = func(parent dag.Vertex) {
+		from := id(parent)
+		parent.Visit(func(child dag.Vertex) {
+			visit(child)
+			edge := jsonEdge{From: from, To: id(child)}
+			if !edges[edge] {
+				edges[edge] = true
+				out.Edges = append(out.Edges, edge)
+			}
+		})
+	}
+	d.Visit(visit)
+
+	out.Status = statusOf(&d.StatusCache)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// statusOf returns the status updates of the objects that the DAG was
+// built from, sorted by kind, namespace and name.
+func statusOf(cache *status.Cache) []jsonStatus {
+	result := []jsonStatus{}
+
+	for _, u := range cache.GetProxyUpdates() {
+		var conditions []contour_api_v1.DetailedCondition
+		for _, c := range u.Conditions {
+			conditions = append(conditions, *c)
+		}
+		sort.Slice(conditions, func(i, j int) bool {
+			return conditions[i].Type < conditions[j].Type
+		})
+
+		result = append(result, jsonStatus{
+			Kind:        "HTTPProxy",
+			Namespace:   u.Fullname.Namespace,
+			Name:        u.Fullname.Name,
+			Generation:  u.Generation,
+			VirtualHost: u.Vhost,
+			Conditions:  conditions,
+		})
+	}
+
+	for _, u := range cache.GetGatewayUpdates() {
+		var conditions []metav1.Condition
+		for _, c := range u.Conditions {
+			conditions = append(conditions, c)
+		}
+		sort.Slice(conditions, func(i, j int) bool {
+			return conditions[i].Type < conditions[j].Type
+		})
+
+		result = append(result, jsonStatus{
+			Kind:       "Gateway",
+			Namespace:  u.FullName.Namespace,
+			Name:       u.FullName.Name,
+			Generation: u.Generation,
+			Conditions: conditions,
+		})
+	}
+
+	for _, u := range cache.GetRouteUpdates() {
+		var conditions []metav1.Condition
+		for _, c := range u.Conditions {
+			conditions = append(conditions, c)
+		}
+		sort.Slice(conditions, func(i, j int) bool {
+			return conditions[i].Type < conditions[j].Type
+		})
+
+		result = append(result, jsonStatus{
+			Kind:       u.Resource,
+			Namespace:  u.FullName.Namespace,
+			Name:       u.FullName.Name,
+			Generation: u.Generation,
+			Conditions: conditions,
+		})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
@@ -0,0 +1,290 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestJSONVertexOf(t *testing.T) {
+	service := &dag.Service{
+		Weighted: dag.WeightedService{
+			ServiceName:      "kuard",
+			ServiceNamespace: "default",
+			ServicePort:      v1.ServicePort{Port: 8080},
+		},
+	}
+
+	tests := map[string]struct {
+		vertex dag.Vertex
+		want   jsonVertex
+	}{
+		"listener": {
+			vertex: &dag.Listener{Address: "0.0.0.0", Port: 8080},
+			want:   jsonVertex{Kind: "Listener", Name: "0.0.0.0:8080"},
+		},
+		"secret": {
+			vertex: &dag.Secret{Object: &v1.Secret{ObjectMeta: fixture.ObjectMeta("default/tls")}},
+			want:   jsonVertex{Kind: "Secret", Name: "default/tls"},
+		},
+		"service": {
+			vertex: service,
+			want: jsonVertex{Kind: "Service", Name: "default/kuard", Attributes: map[string]string{
+				"port": "8080",
+			}},
+		},
+		"virtual host": {
+			vertex: &dag.VirtualHost{Name: "example.com"},
+			want:   jsonVertex{Kind: "VirtualHost", Name: "example.com"},
+		},
+		"secure virtual host": {
+			vertex: &dag.SecureVirtualHost{
+				VirtualHost:   dag.VirtualHost{Name: "example.com"},
+				MinTLSVersion: "1.2",
+			},
+			want: jsonVertex{Kind: "SecureVirtualHost", Name: "example.com", Attributes: map[string]string{
+				"minTLSVersion": "1.2",
+			}},
+		},
+		"tcp virtual host": {
+			vertex: &dag.TCPVirtualHost{Name: "tcp.example.com", Port: 9000},
+			want: jsonVertex{Kind: "TCPVirtualHost", Name: "tcp.example.com", Attributes: map[string]string{
+				"port": "9000",
+			}},
+		},
+		"route": {
+			vertex: &dag.Route{
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/api"},
+			},
+			want: jsonVertex{Kind: "Route", Name: "prefix: /api type: string", Attributes: map[string]string{
+				"path": "prefix: /api type: string",
+			}},
+		},
+		"route with header conditions": {
+			vertex: &dag.Route{
+				PathMatchCondition: &dag.ExactMatchCondition{Path: "/login"},
+				HeaderMatchConditions: []dag.HeaderMatchCondition{{
+					Name:      "x-user",
+					Value:     "admin",
+					MatchType: dag.HeaderMatchTypeExact,
+				}, {
+					Name:      "x-debug",
+					MatchType: dag.HeaderMatchTypePresent,
+				}},
+			},
+			want: jsonVertex{Kind: "Route", Name: "exact: /login", Attributes: map[string]string{
+				"path":    "exact: /login",
+				"headers": "header: name=x-user&value=admin&matchtype=&exact&invert=&false, header: name=x-debug&value=&matchtype=&present&invert=&false",
+			}},
+		},
+		"tcp proxy": {
+			vertex: &dag.TCPProxy{},
+			want:   jsonVertex{Kind: "TCPProxy"},
+		},
+		"cluster": {
+			vertex: &dag.Cluster{Upstream: service, Weight: 20},
+			want: jsonVertex{Kind: "Cluster", Name: "default/kuard/8080/da39a3ee5e", Attributes: map[string]string{
+				"weight": "20",
+			}},
+		},
+		"other": {
+			vertex: &dag.ServiceCluster{ClusterName: "default/kuard/http"},
+			want:   jsonVertex{Kind: "*dag.ServiceCluster"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, jsonVertexOf(tc.vertex))
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	// condition is the part of a status condition that is compared.
+	type condition struct {
+		Type   string `json:"type"`
+		Status string `json:"status"`
+	}
+
+	// status is a jsonStatus whose conditions are decoded.
+	type status struct {
+		Kind        string      `json:"kind"`
+		Namespace   string      `json:"namespace"`
+		Name        string      `json:"name"`
+		Generation  int64       `json:"generation"`
+		VirtualHost string      `json:"virtualhost"`
+		Conditions  []condition `json:"conditions"`
+	}
+
+	type output struct {
+		Vertices []jsonVertex `json:"vertices"`
+		Edges    []jsonEdge   `json:"edges"`
+		Status   []status     `json:"status"`
+	}
+
+	service := fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+
+	proxy := func(name, service string) *contour_api_v1.HTTPProxy {
+		return &contour_api_v1.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "default",
+				Generation: 3,
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{Fqdn: name + ".example.com"},
+				Routes: []contour_api_v1.Route{{
+					Services: []contour_api_v1.Service{{
+						Name: service,
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		objs []interface{}
+		want output
+	}{
+		"empty": {
+			want: output{
+				Vertices: []jsonVertex{},
+				Edges:    []jsonEdge{},
+				Status:   []status{},
+			},
+		},
+		"httpproxy": {
+			objs: []interface{}{
+				service,
+				proxy("simple", "kuard"),
+			},
+			want: output{
+				Vertices: []jsonVertex{
+					{ID: "0", Kind: "Listener", Name: ":80"},
+					{ID: "1", Kind: "VirtualHost", Name: "simple.example.com"},
+					{ID: "2", Kind: "Route", Name: "prefix: / type: string", Attributes: map[string]string{
+						"path": "prefix: / type: string",
+					}},
+					{ID: "3", Kind: "Cluster", Name: "default/kuard/8080/da39a3ee5e", Attributes: map[string]string{
+						"weight": "0",
+					}},
+					{ID: "4", Kind: "Service", Name: "default/kuard", Attributes: map[string]string{
+						"port": "8080",
+					}},
+					{ID: "5", Kind: "*dag.ServiceCluster"},
+				},
+				Edges: []jsonEdge{
+					{From: "4", To: "5"},
+					{From: "3", To: "4"},
+					{From: "2", To: "3"},
+					{From: "1", To: "2"},
+					{From: "0", To: "1"},
+				},
+				Status: []status{{
+					Kind:        "HTTPProxy",
+					Namespace:   "default",
+					Name:        "simple",
+					Generation:  3,
+					VirtualHost: "simple.example.com",
+					Conditions:  []condition{{Type: "Valid", Status: "True"}},
+				}},
+			},
+		},
+		"httpproxy with missing service": {
+			objs: []interface{}{
+				service,
+				proxy("simple", "kuard"),
+				proxy("missing", "missing"),
+			},
+			want: output{
+				Vertices: []jsonVertex{
+					{ID: "0", Kind: "Listener", Name: ":80"},
+					{ID: "1", Kind: "VirtualHost", Name: "simple.example.com"},
+					{ID: "2", Kind: "Route", Name: "prefix: / type: string", Attributes: map[string]string{
+						"path": "prefix: / type: string",
+					}},
+					{ID: "3", Kind: "Cluster", Name: "default/kuard/8080/da39a3ee5e", Attributes: map[string]string{
+						"weight": "0",
+					}},
+					{ID: "4", Kind: "Service", Name: "default/kuard", Attributes: map[string]string{
+						"port": "8080",
+					}},
+					{ID: "5", Kind: "*dag.ServiceCluster"},
+				},
+				Edges: []jsonEdge{
+					{From: "4", To: "5"},
+					{From: "3", To: "4"},
+					{From: "2", To: "3"},
+					{From: "1", To: "2"},
+					{From: "0", To: "1"},
+				},
+				Status: []status{{
+					Kind:        "HTTPProxy",
+					Namespace:   "default",
+					Name:        "missing",
+					Generation:  3,
+					VirtualHost: "missing.example.com",
+					Conditions:  []condition{{Type: "Valid", Status: "False"}},
+				}, {
+					Kind:        "HTTPProxy",
+					Namespace:   "default",
+					Name:        "simple",
+					Generation:  3,
+					VirtualHost: "simple.example.com",
+					Conditions:  []condition{{Type: "Valid", Status: "True"}},
+				}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			jw := &jsonWriter{
+				Builder: &dag.Builder{
+					Source: dag.KubernetesCache{
+						FieldLogger: fixture.NewTestLogger(t),
+					},
+					Processors: []dag.Processor{
+						&dag.HTTPProxyProcessor{},
+						&dag.ListenerProcessor{},
+					},
+				},
+			}
+			for _, o := range tc.objs {
+				jw.Builder.Source.Insert(o)
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, jw.writeJSON(&buf))
+
+			var got output
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
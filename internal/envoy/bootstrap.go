@@ -34,6 +34,10 @@ const SDSTLSCertificateFile = "xds-tls-certificate.json"
 // CA certificates for Envoy to use for the XDS gRPC connection.
 const SDSValidationContextFile = "xds-validation-context.json"
 
+// SPIFFEWorkloadAPIClusterName is the name of the bootstrap cluster
+// that connects Envoy to the SPIFFE Workload API.
+const SPIFFEWorkloadAPIClusterName = "spiffe_workload_api"
+
 // BootstrapConfig holds configuration values for a Bootstrap configuration.
 type BootstrapConfig struct {
 	// AdminAccessLogPath is the path to write the access log for the administration server.
@@ -48,6 +52,21 @@ type BootstrapConfig struct {
 	// Defaults to 9001.
 	AdminPort int
 
+	// AdminSocket is the path of the Unix domain socket that the
+	// administration server will listen on. If set, it is used
+	// instead of AdminAddress and AdminPort.
+	AdminSocket string
+
+	// OverloadMaxHeapSizeBytes is the maximum heap size of Envoy. The
+	// overload manager shrinks the heap once 95% of it is in use, and
+	// stops accepting requests at 98%. If 0, the heap is not limited.
+	OverloadMaxHeapSizeBytes uint64
+
+	// OverloadMaxDownstreamConnections is the maximum number of
+	// downstream connections that Envoy accepts across all of its
+	// listeners. If 0, the number of connections is not limited.
+	OverloadMaxDownstreamConnections uint64
+
 	// XDSAddress is the TCP address of the gRPC XDS management server.
 	// Defaults to 127.0.0.1.
 	XDSAddress string
@@ -56,10 +75,25 @@ type BootstrapConfig struct {
 	// Defaults to 8001.
 	XDSGRPCPort int
 
+	// XDSSocket is the path of the Unix domain socket of the gRPC XDS
+	// management server. If set, Envoy connects to it rather than to
+	// XDSAddress and XDSGRPCPort, without TLS.
+	XDSSocket string
+
 	// XDSResourceVersion defines the XDS Server Version to use.
 	// Defaults to "v3"
 	XDSResourceVersion config.ResourceVersion
 
+	// XDSDelta requests listeners and clusters from Contour with the
+	// incremental (delta) variant of the xDS protocol, so that only
+	// the resources that change are sent.
+	XDSDelta bool
+
+	// RuntimeDiscovery adds a runtime layer that Envoy fetches from
+	// Contour with the runtime discovery service (RTDS), so that
+	// runtime values can be set from Contour's configuration file.
+	RuntimeDiscovery bool
+
 	// Namespace is the namespace where Contour is running
 	Namespace string
 
@@ -88,6 +122,39 @@ type BootstrapConfig struct {
 	// DNSLookupFamily specifies DNS Resolution Policy to use for Envoy -> Contour cluster name lookup.
 	// Either v4, v6 or auto.
 	DNSLookupFamily string
+
+	// SPIFFEWorkloadAPISocket is the path of the Unix domain socket
+	// that serves the SPIFFE Workload API SDS service, for example a
+	// SPIRE agent socket. If empty, Envoy does not connect to a
+	// SPIFFE Workload API.
+	SPIFFEWorkloadAPISocket string
+
+	// StatsSink is the kind of stats sink that Envoy pushes its metrics
+	// to, either "statsd" or "dogstatsd". If empty, no stats sink is
+	// configured.
+	StatsSink string
+
+	// StatsSinkAddress is the IP address of the stats sink.
+	// Defaults to 127.0.0.1.
+	StatsSinkAddress string
+
+	// StatsSinkPort is the UDP port of the stats sink.
+	// Defaults to 8125.
+	StatsSinkPort int
+
+	// StatsSinkPrefix is the prefix of the metric names sent to the
+	// stats sink. If empty, Envoy uses "envoy".
+	StatsSinkPrefix string
+
+	// StatsTags maps tag names to regular expressions that extract
+	// the tag value from Envoy metric names. The first capture group
+	// of the regular expression is removed from the metric name and
+	// used as the tag value.
+	StatsTags map[string]string
+
+	// StatsFixedTags maps tag names to fixed values that are added
+	// to every Envoy metric.
+	StatsFixedTags map[string]string
 }
 
 func (c *BootstrapConfig) GetXdsAddress() string { return stringOrDefault(c.XDSAddress, "127.0.0.1") }
@@ -102,6 +169,10 @@ func (c *BootstrapConfig) GetAdminAccessLogPath() string {
 func (c *BootstrapConfig) GetDNSLookupFamily() string {
 	return stringOrDefault(c.DNSLookupFamily, "auto")
 }
+func (c *BootstrapConfig) GetStatsSinkAddress() string {
+	return stringOrDefault(c.StatsSinkAddress, "127.0.0.1")
+}
+func (c *BootstrapConfig) GetStatsSinkPort() int { return intOrDefault(c.StatsSinkPort, 8125) }
 func stringOrDefault(s, def string) string {
 	if s == "" {
 		return def
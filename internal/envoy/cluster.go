@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"github.com/projectcontour/contour/internal/dag"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // Clustername returns the name of the CDS cluster for this service.
@@ -41,11 +42,47 @@ func Clustername(cluster *dag.Cluster) string {
 			buf += strconv.Itoa(int(hc.HealthyThreshold))
 		}
 		buf += hc.Path
+		if g := hc.GRPC; g != nil {
+			buf += "grpc:" + g.ServiceName + "," + g.Authority
+		}
+		for _, r := range hc.ExpectedStatuses {
+			buf += fmt.Sprintf("status:%d-%d", r.Start, r.End)
+		}
+		if hc.Port > 0 {
+			buf += "port:" + strconv.Itoa(int(hc.Port))
+		}
+		if len(hc.RequestHeaders) > 0 {
+			buf += labels.Set(hc.RequestHeaders).String()
+		}
+	}
+	if hc := cluster.TCPHealthCheckPolicy; hc != nil {
+		buf += hc.Send
+		buf += strings.Join(hc.Receive, ",")
 	}
 	if uv := cluster.UpstreamValidation; uv != nil {
 		buf += uv.CACertificate.Object.ObjectMeta.Name
 		buf += uv.SubjectName
 	}
+	buf += cluster.UpstreamProxyProtocol
+	if cluster.ConnectTimeout > 0 {
+		buf += cluster.ConnectTimeout.String()
+	}
+	if h := cluster.HTTP2; h != nil {
+		buf += fmt.Sprintf("h2:%d,%d,%d,%s,%s", h.MaxConcurrentStreams, h.InitialStreamWindowSize,
+			h.InitialConnectionWindowSize, h.KeepaliveInterval, h.KeepaliveTimeout)
+	}
+	if service.Weighted.ServiceImport {
+		buf += "serviceimport"
+	}
+	if len(service.Weighted.PodSelector) > 0 {
+		buf += labels.Set(service.Weighted.PodSelector).String()
+	}
+	for _, ep := range service.StaticEndpoints {
+		buf += ep.Address + ":" + strconv.Itoa(int(ep.Port))
+	}
+	for _, s := range cluster.Failover {
+		buf += "failover:" + s.Weighted.ClusterLoadAssignmentName()
+	}
 
 	// This isn't a crypto hash, we just want a unique name.
 	hash := sha1.Sum([]byte(buf)) // nolint:gosec
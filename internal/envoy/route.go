@@ -70,6 +70,7 @@ func SingleSimpleCluster(clusters []*dag.Cluster) bool {
 	if cluster.ResponseHeadersPolicy == nil {
 		// no response headers policy
 	} else if len(cluster.ResponseHeadersPolicy.Set) != 0 ||
+		len(cluster.ResponseHeadersPolicy.Add) != 0 ||
 		len(cluster.ResponseHeadersPolicy.Remove) != 0 {
 		return false
 	}
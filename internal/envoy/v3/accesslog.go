@@ -14,10 +14,14 @@
 package v3
 
 import (
+	"strconv"
+
 	envoy_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_file_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
 	envoy_req_without_query_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/formatter/req_without_query/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -85,6 +89,97 @@ func FileAccessLogJSON(path string, fields config.AccessLogFields, extensions []
 	}}
 }
 
+// AccessLogSamplingHeader is the request header that carries the
+// access log sampling percentage of the matched route to the access
+// log filter.
+const AccessLogSamplingHeader = "x-contour-access-log-sampling"
+
+// AccessLogSampling returns the request headers that set the access log
+// sampling percentage of a route.
+func AccessLogSampling(percentage uint32) []*envoy_config_core_v3.HeaderValueOption {
+	return HeaderValueList(map[string]string{
+		AccessLogSamplingHeader: strconv.FormatUint(uint64(percentage), 10),
+	}, false)
+}
+
+// AccessLogSamplingFilter returns an access log filter that logs
+// requests according to the sampling percentage set by their route.
+// Requests that did not match a route are always logged, requests
+// with 100 percent are always logged, and requests with zero
+// percent, or any percentage not in the given list, are never
+// logged.
+func AccessLogSamplingFilter(percentages []uint32) *envoy_accesslog_v3.AccessLogFilter {
+	header := func(match *envoy_route_v3.HeaderMatcher) *envoy_accesslog_v3.AccessLogFilter {
+		match.Name = AccessLogSamplingHeader
+		return &envoy_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_HeaderFilter{
+				HeaderFilter: &envoy_accesslog_v3.HeaderFilter{
+					Header: match,
+				},
+			},
+		}
+	}
+
+	filters := []*envoy_accesslog_v3.AccessLogFilter{
+		header(&envoy_route_v3.HeaderMatcher{
+			HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PresentMatch{PresentMatch: true},
+			InvertMatch:          true,
+		}),
+		header(&envoy_route_v3.HeaderMatcher{
+			HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{ExactMatch: "100"},
+		}),
+	}
+
+	for _, p := range percentages {
+		value := strconv.FormatUint(uint64(p), 10)
+		filters = append(filters, &envoy_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_AndFilter{
+				AndFilter: &envoy_accesslog_v3.AndFilter{
+					Filters: []*envoy_accesslog_v3.AccessLogFilter{
+						header(&envoy_route_v3.HeaderMatcher{
+							HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{ExactMatch: value},
+						}),
+						{
+							FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_RuntimeFilter{
+								RuntimeFilter: &envoy_accesslog_v3.RuntimeFilter{
+									RuntimeKey: "contour.access_log.sampling." + value,
+									PercentSampled: &envoy_type.FractionalPercent{
+										Numerator:   p,
+										Denominator: envoy_type.FractionalPercent_HUNDRED,
+									},
+									UseIndependentRandomness: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return &envoy_accesslog_v3.AccessLogFilter{
+		FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_OrFilter{
+			OrFilter: &envoy_accesslog_v3.OrFilter{
+				Filters: filters,
+			},
+		},
+	}
+}
+
+// FilterAccessLogs applies the given filter to each of the access logs.
+// A nil filter leaves the access logs unchanged.
+func FilterAccessLogs(logs []*envoy_accesslog_v3.AccessLog, filter *envoy_accesslog_v3.AccessLogFilter) []*envoy_accesslog_v3.AccessLog {
+	if filter == nil {
+		return logs
+	}
+
+	for _, log := range logs {
+		log.Filter = filter
+	}
+
+	return logs
+}
+
 func sv(s string) *_struct.Value {
 	return &_struct.Value{
 		Kind: &_struct.Value_StringValue{
@@ -18,8 +18,10 @@ import (
 
 	envoy_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_file_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
 	envoy_req_without_query_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/formatter/req_without_query/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -176,3 +178,58 @@ func TestJSONFileAccessLog(t *testing.T) {
 		})
 	}
 }
+
+func TestAccessLogSamplingFilter(t *testing.T) {
+	header := func(match *envoy_route_v3.HeaderMatcher) *envoy_accesslog_v3.AccessLogFilter {
+		match.Name = "x-contour-access-log-sampling"
+		return &envoy_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_HeaderFilter{
+				HeaderFilter: &envoy_accesslog_v3.HeaderFilter{Header: match},
+			},
+		}
+	}
+
+	want := &envoy_accesslog_v3.AccessLogFilter{
+		FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_OrFilter{
+			OrFilter: &envoy_accesslog_v3.OrFilter{
+				Filters: []*envoy_accesslog_v3.AccessLogFilter{
+					header(&envoy_route_v3.HeaderMatcher{
+						HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PresentMatch{PresentMatch: true},
+						InvertMatch:          true,
+					}),
+					header(&envoy_route_v3.HeaderMatcher{
+						HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{ExactMatch: "100"},
+					}),
+					{
+						FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_AndFilter{
+							AndFilter: &envoy_accesslog_v3.AndFilter{
+								Filters: []*envoy_accesslog_v3.AccessLogFilter{
+									header(&envoy_route_v3.HeaderMatcher{
+										HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{ExactMatch: "10"},
+									}),
+									{
+										FilterSpecifier: &envoy_accesslog_v3.AccessLogFilter_RuntimeFilter{
+											RuntimeFilter: &envoy_accesslog_v3.RuntimeFilter{
+												RuntimeKey: "contour.access_log.sampling.10",
+												PercentSampled: &envoy_type.FractionalPercent{
+													Numerator:   10,
+													Denominator: envoy_type.FractionalPercent_HUNDRED,
+												},
+												UseIndependentRandomness: true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, AccessLogSamplingFilter([]uint32{10}))
+
+	logs := FilterAccessLogs(FileAccessLogEnvoy("/dev/stdout", "", nil), want)
+	protobuf.ExpectEqual(t, want, logs[0].Filter)
+}
@@ -0,0 +1,66 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+	"time"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_admission_control_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/admission_control/v3alpha"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestFilterAdmissionControl(t *testing.T) {
+	protobuf.ExpectEqual(t, (*http.HttpFilter)(nil), FilterAdmissionControl(nil))
+
+	got := FilterAdmissionControl(&dag.AdmissionControlPolicy{
+		SuccessRateThreshold:   90,
+		Aggression:             1.5,
+		SamplingWindow:         time.Minute,
+		MinRequestsPerSecond:   5,
+		MaxRejectionPercentage: 80,
+	})
+	want := &http.HttpFilter{
+		Name: "envoy.filters.http.admission_control",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_admission_control_v3.AdmissionControl{
+				EvaluationCriteria: &envoy_admission_control_v3.AdmissionControl_SuccessCriteria_{
+					SuccessCriteria: &envoy_admission_control_v3.AdmissionControl_SuccessCriteria{},
+				},
+				SamplingWindow: protobuf.Duration(time.Minute),
+				Aggression: &envoy_core_v3.RuntimeDouble{
+					DefaultValue: 1.5,
+					RuntimeKey:   "contour.admission_control.aggression",
+				},
+				SrThreshold: &envoy_core_v3.RuntimePercent{
+					DefaultValue: &envoy_type.Percent{Value: 90},
+					RuntimeKey:   "contour.admission_control.sr_threshold",
+				},
+				RpsThreshold: &envoy_core_v3.RuntimeUInt32{
+					DefaultValue: 5,
+					RuntimeKey:   "contour.admission_control.rps_threshold",
+				},
+				MaxRejectionProbability: &envoy_core_v3.RuntimePercent{
+					DefaultValue: &envoy_type.Percent{Value: 80},
+					RuntimeKey:   "contour.admission_control.max_rejection_probability",
+				},
+			}),
+		},
+	}
+	protobuf.ExpectEqual(t, want, got)
+}
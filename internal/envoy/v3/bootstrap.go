@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -28,11 +29,15 @@ import (
 	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_metrics_v3 "github.com/envoyproxy/go-control-plane/envoy/config/metrics/v3"
+	envoy_overload_v3 "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	envoy_fixed_heap_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/fixed_heap/v3"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	envoy_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
+	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/protobuf"
 )
@@ -71,6 +76,12 @@ type bootstrapf func(*envoy.BootstrapConfig) (string, proto.Message)
 func bootstrap(c *envoy.BootstrapConfig) ([]bootstrapf, error) {
 	var steps []bootstrapf
 
+	if c.XDSSocket != "" && (c.GrpcClientCert != "" || c.GrpcClientKey != "" || c.GrpcCABundle != "") {
+		return nil, fmt.Errorf(
+			"the xDS socket is served without TLS, so %q, %q and %q must not be supplied with %q",
+			"--envoy-cafile", "--envoy-cert-file", "--envoy-key-file", "--xds-socket")
+	}
+
 	if c.GrpcClientCert == "" && c.GrpcClientKey == "" && c.GrpcCABundle == "" {
 		steps = append(steps,
 			func(*envoy.BootstrapConfig) (string, proto.Message) {
@@ -152,10 +163,15 @@ func bootstrap(c *envoy.BootstrapConfig) ([]bootstrapf, error) {
 }
 
 func bootstrapConfig(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.Bootstrap {
-	return &envoy_bootstrap_v3.Bootstrap{
+	configSource := ConfigSource
+	if c.XDSDelta {
+		configSource = DeltaConfigSource
+	}
+
+	b := &envoy_bootstrap_v3.Bootstrap{
 		DynamicResources: &envoy_bootstrap_v3.Bootstrap_DynamicResources{
-			LdsConfig: ConfigSource("contour"),
-			CdsConfig: ConfigSource("contour"),
+			LdsConfig: configSource("contour"),
+			CdsConfig: configSource("contour"),
 		},
 		StaticResources: &envoy_bootstrap_v3.Bootstrap_StaticResources{
 			Clusters: []*envoy_cluster_v3.Cluster{{
@@ -213,6 +229,244 @@ func bootstrapConfig(c *envoy.BootstrapConfig) *envoy_bootstrap_v3.Bootstrap {
 			Address:       SocketAddress(c.GetAdminAddress(), c.GetAdminPort()),
 		},
 	}
+
+	if c.XDSSocket != "" {
+		// TCP keepalive options cannot be set on Unix domain sockets.
+		contour := b.StaticResources.Clusters[0]
+		contour.ClusterDiscoveryType = ClusterDiscoveryType(envoy_cluster_v3.Cluster_STATIC)
+		contour.LoadAssignment.Endpoints = Endpoints(&envoy_core_v3.Address{
+			Address: &envoy_core_v3.Address_Pipe{
+				Pipe: &envoy_core_v3.Pipe{
+					Path: c.XDSSocket,
+				},
+			},
+		})
+		contour.UpstreamConnectionOptions = nil
+	}
+
+	if c.AdminSocket != "" {
+		admin := &envoy_core_v3.Address{
+			Address: &envoy_core_v3.Address_Pipe{
+				Pipe: &envoy_core_v3.Pipe{
+					Path: c.AdminSocket,
+				},
+			},
+		}
+		b.Admin.Address = admin
+
+		stats := b.StaticResources.Clusters[1]
+		stats.AltStatName = strings.Join([]string{c.Namespace, "service-stats"}, "_")
+		stats.ClusterDiscoveryType = ClusterDiscoveryType(envoy_cluster_v3.Cluster_STATIC)
+		stats.LoadAssignment.Endpoints = Endpoints(admin)
+	}
+
+	b.LayeredRuntime = layeredRuntime(c, configSource)
+	b.OverloadManager = overloadManager(c)
+
+	if c.SPIFFEWorkloadAPISocket != "" {
+		b.StaticResources.Clusters = append(b.StaticResources.Clusters, spiffeWorkloadAPICluster(c))
+	}
+
+	if sink := statsSink(c); sink != nil {
+		b.StatsSinks = []*envoy_metrics_v3.StatsSink{sink}
+	}
+
+	if len(c.StatsTags) > 0 || len(c.StatsFixedTags) > 0 {
+		b.StatsConfig = &envoy_metrics_v3.StatsConfig{
+			StatsTags: statsTags(c),
+		}
+	}
+
+	return b
+}
+
+// layeredRuntime returns the runtime layers of the bootstrap
+// configuration, or nil if Envoy's default runtime is used.
+func layeredRuntime(c *envoy.BootstrapConfig, configSource func(string) *envoy_core_v3.ConfigSource) *envoy_bootstrap_v3.LayeredRuntime {
+	var layers []*envoy_bootstrap_v3.RuntimeLayer
+
+	if c.OverloadMaxDownstreamConnections > 0 {
+		layers = append(layers, &envoy_bootstrap_v3.RuntimeLayer{
+			Name: "static",
+			LayerSpecifier: &envoy_bootstrap_v3.RuntimeLayer_StaticLayer{
+				StaticLayer: &_struct.Struct{
+					Fields: map[string]*_struct.Value{
+						"overload.global_downstream_max_connections": {
+							Kind: &_struct.Value_NumberValue{
+								NumberValue: float64(c.OverloadMaxDownstreamConnections),
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	if c.RuntimeDiscovery {
+		layers = append(layers, &envoy_bootstrap_v3.RuntimeLayer{
+			Name: RuntimeLayerName,
+			LayerSpecifier: &envoy_bootstrap_v3.RuntimeLayer_RtdsLayer_{
+				RtdsLayer: &envoy_bootstrap_v3.RuntimeLayer_RtdsLayer{
+					Name:       RuntimeLayerName,
+					RtdsConfig: configSource("contour"),
+				},
+			},
+		})
+	}
+
+	if len(layers) == 0 {
+		return nil
+	}
+
+	// The admin layer is kept last, so that values set
+	// through the admin interface still take precedence.
+	layers = append(layers, &envoy_bootstrap_v3.RuntimeLayer{
+		Name: "admin",
+		LayerSpecifier: &envoy_bootstrap_v3.RuntimeLayer_AdminLayer_{
+			AdminLayer: &envoy_bootstrap_v3.RuntimeLayer_AdminLayer{},
+		},
+	})
+
+	return &envoy_bootstrap_v3.LayeredRuntime{
+		Layers: layers,
+	}
+}
+
+// overloadManager returns the overload manager that shrinks Envoy's
+// heap, then stops accepting requests, as the heap approaches its
+// maximum size, or nil if the heap size is not limited.
+func overloadManager(c *envoy.BootstrapConfig) *envoy_overload_v3.OverloadManager {
+	if c.OverloadMaxHeapSizeBytes == 0 {
+		return nil
+	}
+
+	threshold := func(value float64) []*envoy_overload_v3.Trigger {
+		return []*envoy_overload_v3.Trigger{{
+			Name: "envoy.resource_monitors.fixed_heap",
+			TriggerOneof: &envoy_overload_v3.Trigger_Threshold{
+				Threshold: &envoy_overload_v3.ThresholdTrigger{
+					Value: value,
+				},
+			},
+		}}
+	}
+
+	return &envoy_overload_v3.OverloadManager{
+		RefreshInterval: protobuf.Duration(250 * time.Millisecond),
+		ResourceMonitors: []*envoy_overload_v3.ResourceMonitor{{
+			Name: "envoy.resource_monitors.fixed_heap",
+			ConfigType: &envoy_overload_v3.ResourceMonitor_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_fixed_heap_v3.FixedHeapConfig{
+					MaxHeapSizeBytes: c.OverloadMaxHeapSizeBytes,
+				}),
+			},
+		}},
+		Actions: []*envoy_overload_v3.OverloadAction{{
+			Name:     "envoy.overload_actions.shrink_heap",
+			Triggers: threshold(0.95),
+		}, {
+			Name:     "envoy.overload_actions.stop_accepting_requests",
+			Triggers: threshold(0.98),
+		}},
+	}
+}
+
+// statsSink returns the statsd or dogstatsd sink that Envoy pushes
+// its metrics to, or nil if no stats sink is configured.
+func statsSink(c *envoy.BootstrapConfig) *envoy_metrics_v3.StatsSink {
+	address := &envoy_core_v3.Address{
+		Address: &envoy_core_v3.Address_SocketAddress{
+			SocketAddress: &envoy_core_v3.SocketAddress{
+				Protocol: envoy_core_v3.SocketAddress_UDP,
+				Address:  c.GetStatsSinkAddress(),
+				PortSpecifier: &envoy_core_v3.SocketAddress_PortValue{
+					PortValue: uint32(c.GetStatsSinkPort()),
+				},
+			},
+		},
+	}
+
+	switch c.StatsSink {
+	case "statsd":
+		return &envoy_metrics_v3.StatsSink{
+			Name: "envoy.stat_sinks.statsd",
+			ConfigType: &envoy_metrics_v3.StatsSink_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_metrics_v3.StatsdSink{
+					StatsdSpecifier: &envoy_metrics_v3.StatsdSink_Address{
+						Address: address,
+					},
+					Prefix: c.StatsSinkPrefix,
+				}),
+			},
+		}
+	case "dogstatsd":
+		return &envoy_metrics_v3.StatsSink{
+			Name: "envoy.stat_sinks.dog_statsd",
+			ConfigType: &envoy_metrics_v3.StatsSink_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_metrics_v3.DogStatsdSink{
+					DogStatsdSpecifier: &envoy_metrics_v3.DogStatsdSink_Address{
+						Address: address,
+					},
+					Prefix: c.StatsSinkPrefix,
+				}),
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+// statsTags returns the tag specifiers for the configured stats tags,
+// sorted by tag name so that the bootstrap configuration is stable.
+func statsTags(c *envoy.BootstrapConfig) []*envoy_metrics_v3.TagSpecifier {
+	var tags []*envoy_metrics_v3.TagSpecifier
+
+	for name, regex := range c.StatsTags {
+		tags = append(tags, &envoy_metrics_v3.TagSpecifier{
+			TagName: name,
+			TagValue: &envoy_metrics_v3.TagSpecifier_Regex{
+				Regex: regex,
+			},
+		})
+	}
+	for name, value := range c.StatsFixedTags {
+		tags = append(tags, &envoy_metrics_v3.TagSpecifier{
+			TagName: name,
+			TagValue: &envoy_metrics_v3.TagSpecifier_FixedValue{
+				FixedValue: value,
+			},
+		})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].TagName < tags[j].TagName
+	})
+
+	return tags
+}
+
+// spiffeWorkloadAPICluster returns the cluster that Envoy uses to
+// fetch SDS secrets from the SPIFFE Workload API. SDS config sources
+// must refer to a static cluster, so this cannot be served over CDS.
+func spiffeWorkloadAPICluster(c *envoy.BootstrapConfig) *envoy_cluster_v3.Cluster {
+	return &envoy_cluster_v3.Cluster{
+		Name:                 envoy.SPIFFEWorkloadAPIClusterName,
+		AltStatName:          strings.Join([]string{c.Namespace, envoy.SPIFFEWorkloadAPIClusterName}, "_"),
+		ConnectTimeout:       protobuf.Duration(250 * time.Millisecond),
+		ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_STATIC),
+		LbPolicy:             envoy_cluster_v3.Cluster_ROUND_ROBIN,
+		LoadAssignment: &envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: envoy.SPIFFEWorkloadAPIClusterName,
+			Endpoints: Endpoints(&envoy_core_v3.Address{
+				Address: &envoy_core_v3.Address_Pipe{
+					Pipe: &envoy_core_v3.Pipe{
+						Path: c.SPIFFEWorkloadAPISocket,
+					},
+				},
+			}),
+		},
+		TypedExtensionProtocolOptions: http2ProtocolOptions(),
+	}
 }
 
 func upstreamFileTLSContext(c *envoy.BootstrapConfig) *envoy_tls_v3.UpstreamTlsContext {
@@ -0,0 +1,63 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	envoy_buffer_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// FilterBuffer returns the buffer filter, which buffers the bodies of
+// requests before they are proxied, limited to maxRequestBytes. The
+// filter is disabled on the virtual hosts, and enabled per route with
+// BufferPerRoute. If maxRequestBytes is zero, FilterBuffer returns nil.
+func FilterBuffer(maxRequestBytes uint32) *http.HttpFilter {
+	if maxRequestBytes == 0 {
+		return nil
+	}
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.buffer",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_buffer_v3.Buffer{
+				MaxRequestBytes: protobuf.UInt32(maxRequestBytes),
+			}),
+		},
+	}
+}
+
+// BufferDisabled returns the per virtual host or route configuration of
+// the buffer filter that disables it.
+func BufferDisabled() *any.Any {
+	return protobuf.MustMarshalAny(&envoy_buffer_v3.BufferPerRoute{
+		Override: &envoy_buffer_v3.BufferPerRoute_Disabled{
+			Disabled: true,
+		},
+	})
+}
+
+// BufferPerRoute returns the per-route configuration of the buffer
+// filter for the given buffer policy.
+func BufferPerRoute(policy *dag.BufferPolicy) *any.Any {
+	return protobuf.MustMarshalAny(&envoy_buffer_v3.BufferPerRoute{
+		Override: &envoy_buffer_v3.BufferPerRoute_Buffer{
+			Buffer: &envoy_buffer_v3.Buffer{
+				MaxRequestBytes: protobuf.UInt32(policy.MaxRequestBytes),
+			},
+		},
+	})
+}
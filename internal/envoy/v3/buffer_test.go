@@ -0,0 +1,51 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_buffer_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferPerRoute(t *testing.T) {
+	want := protobuf.MustMarshalAny(&envoy_buffer_v3.BufferPerRoute{
+		Override: &envoy_buffer_v3.BufferPerRoute_Buffer{
+			Buffer: &envoy_buffer_v3.Buffer{
+				MaxRequestBytes: protobuf.UInt32(65536),
+			},
+		},
+	})
+
+	protobuf.ExpectEqual(t, want, BufferPerRoute(&dag.BufferPolicy{MaxRequestBytes: 65536}))
+}
+
+func TestFilterBuffer(t *testing.T) {
+	assert.Nil(t, FilterBuffer(0))
+
+	want := &http.HttpFilter{
+		Name: "envoy.filters.http.buffer",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_buffer_v3.Buffer{
+				MaxRequestBytes: protobuf.UInt32(65536),
+			}),
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, FilterBuffer(65536))
+}
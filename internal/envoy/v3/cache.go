@@ -0,0 +1,267 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"strings"
+
+	envoy_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/config/common/matcher/v3"
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_simple_http_cache_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/cache/simple_http_cache/v3alpha"
+	envoy_matching_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	envoy_matcher_action_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/matcher/action/v3"
+	envoy_cache_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/cache/v3alpha"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// cacheMetadataKey is the route metadata key that holds the
+// cache policy of the route.
+const cacheMetadataKey = "cache_policy"
+
+// cacheSelectHeader is the request header that the cache select
+// filter sets on the requests that the cache filter looks up. It
+// is removed by the cache store filter before the request is sent
+// upstream.
+const cacheSelectHeader = "x-contour-cache"
+
+// cacheControlHeader is the response header that holds the original
+// Cache-Control header of responses that must not be stored, while
+// the cache filter sees them.
+const cacheControlHeader = "x-contour-cache-control"
+
+// cacheSelectCode marks the requests to routes with a cache policy
+// whose method the policy allows, and restores the Cache-Control
+// header of responses after the cache filter has seen them.
+const cacheSelectCode = `
+function envoy_on_request(request_handle)
+	local headers = request_handle:headers()
+	headers:remove("` + cacheSelectHeader + `")
+
+	local policy = request_handle:metadata():get("` + cacheMetadataKey + `")
+	if policy == nil then
+		return
+	end
+	local method = headers:get(":method")
+	for _, allowed in ipairs(policy["methods"]) do
+		if method == allowed then
+			headers:add("` + cacheSelectHeader + `", "1")
+			return
+		end
+	end
+end
+
+function envoy_on_response(response_handle)
+	local headers = response_handle:headers()
+	local cache_control = headers:get("` + cacheControlHeader + `")
+	if cache_control == nil then
+		return
+	end
+	headers:remove("` + cacheControlHeader + `")
+	if cache_control == "" then
+		headers:remove("cache-control")
+	else
+		headers:replace("cache-control", cache_control)
+	end
+end
+`
+
+// cacheStoreCode adds the key headers of the route's cache policy to
+// the Vary header of responses, and stops the cache filter from storing
+// responses that are larger than the policy allows.
+const cacheStoreCode = `
+function envoy_on_request(request_handle)
+	request_handle:headers():remove("` + cacheSelectHeader + `")
+end
+
+function envoy_on_response(response_handle)
+	local headers = response_handle:headers()
+	headers:remove("` + cacheControlHeader + `")
+
+	local policy = response_handle:metadata():get("` + cacheMetadataKey + `")
+	if policy == nil then
+		return
+	end
+
+	local vary = policy["vary"]
+	if vary ~= nil then
+		local current = headers:get("vary")
+		if current == nil or current == "" then
+			headers:replace("vary", vary)
+		elseif current ~= "*" then
+			headers:replace("vary", current .. ", " .. vary)
+		end
+	end
+
+	local max_object_size = policy["max_object_size"]
+	if max_object_size ~= nil then
+		local size = tonumber(headers:get("content-length"))
+		if size == nil or size > max_object_size then
+			headers:add("` + cacheControlHeader + `", headers:get("cache-control") or "")
+			headers:replace("cache-control", "no-store")
+		end
+	end
+end
+`
+
+// FilterCacheSelect returns a Lua filter that selects the requests that
+// the cache filter looks up, with the route metadata returned by
+// CacheMetadata. It must come before the cache filter.
+func FilterCacheSelect() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "cache_select",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: cacheSelectCode,
+			}),
+		},
+	}
+}
+
+// FilterCache returns the cache filter, which caches responses in
+// memory. Envoy's cache filter can't be configured per route, so it
+// is skipped for the requests that FilterCacheSelect does not select.
+// Responses that vary on any request header may be cached, since the
+// key headers of cache policies are added to the Vary header.
+func FilterCache() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.cache",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_matching_v3.ExtensionWithMatcher{
+				Matcher: &envoy_matcher_v3.Matcher{
+					MatcherType: &envoy_matcher_v3.Matcher_MatcherList_{
+						MatcherList: &envoy_matcher_v3.Matcher_MatcherList{
+							Matchers: []*envoy_matcher_v3.Matcher_MatcherList_FieldMatcher{{
+								Predicate: &envoy_matcher_v3.Matcher_MatcherList_Predicate{
+									MatchType: &envoy_matcher_v3.Matcher_MatcherList_Predicate_NotMatcher{
+										NotMatcher: &envoy_matcher_v3.Matcher_MatcherList_Predicate{
+											MatchType: &envoy_matcher_v3.Matcher_MatcherList_Predicate_SinglePredicate_{
+												SinglePredicate: &envoy_matcher_v3.Matcher_MatcherList_Predicate_SinglePredicate{
+													Input: &envoy_core_v3.TypedExtensionConfig{
+														Name: "request-headers",
+														TypedConfig: protobuf.MustMarshalAny(&matcher.HttpRequestHeaderMatchInput{
+															HeaderName: cacheSelectHeader,
+														}),
+													},
+													Matcher: &envoy_matcher_v3.Matcher_MatcherList_Predicate_SinglePredicate_ValueMatch{
+														ValueMatch: &matcher.StringMatcher{
+															MatchPattern: &matcher.StringMatcher_Exact{
+																Exact: "1",
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+								OnMatch: &envoy_matcher_v3.Matcher_OnMatch{
+									OnMatch: &envoy_matcher_v3.Matcher_OnMatch_Action{
+										Action: &envoy_core_v3.TypedExtensionConfig{
+											Name:        "skip",
+											TypedConfig: protobuf.MustMarshalAny(&envoy_matcher_action_v3.SkipFilter{}),
+										},
+									},
+								},
+							}},
+						},
+					},
+				},
+				ExtensionConfig: &envoy_core_v3.TypedExtensionConfig{
+					Name: "envoy.filters.http.cache",
+					TypedConfig: protobuf.MustMarshalAny(&envoy_cache_v3.CacheConfig{
+						TypedConfig: protobuf.MustMarshalAny(&envoy_simple_http_cache_v3.SimpleHttpCacheConfig{}),
+						AllowedVaryHeaders: []*matcher.StringMatcher{{
+							MatchPattern: &matcher.StringMatcher_SafeRegex{
+								SafeRegex: SafeRegexMatch(".*"),
+							},
+						}},
+					}),
+				},
+			}),
+		},
+	}
+}
+
+// FilterCacheStore returns a Lua filter that prepares the responses of
+// routes with a cache policy for the cache filter. It must come after
+// the cache filter.
+func FilterCacheStore() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "cache_store",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: cacheStoreCode,
+			}),
+		},
+	}
+}
+
+// isCacheFilter returns true if f is one of the filters that
+// implement cache policies.
+func isCacheFilter(f *http.HttpFilter) bool {
+	switch f.Name {
+	case "cache_select", "envoy.filters.http.cache", "cache_store":
+		return true
+	default:
+		return false
+	}
+}
+
+// CacheMetadata returns the route metadata that configures the cache
+// filters with the cache policy of a route, or nil if it has none.
+func CacheMetadata(policy *dag.CachePolicy) *envoy_core_v3.Metadata {
+	if policy == nil {
+		return nil
+	}
+
+	methods := make([]*_struct.Value, 0, len(policy.Methods))
+	for _, m := range policy.Methods {
+		methods = append(methods, stringValue(m))
+	}
+
+	fields := map[string]*_struct.Value{
+		"methods": {
+			Kind: &_struct.Value_ListValue{
+				ListValue: &_struct.ListValue{Values: methods},
+			},
+		},
+	}
+	if len(policy.KeyHeaders) > 0 {
+		fields["vary"] = stringValue(strings.Join(policy.KeyHeaders, ", "))
+	}
+	if policy.MaxObjectSize > 0 {
+		fields["max_object_size"] = &_struct.Value{
+			Kind: &_struct.Value_NumberValue{NumberValue: float64(policy.MaxObjectSize)},
+		}
+	}
+
+	return &envoy_core_v3.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			luaMetadataNamespace: {
+				Fields: map[string]*_struct.Value{
+					cacheMetadataKey: {
+						Kind: &_struct.Value_StructValue{
+							StructValue: &_struct.Struct{Fields: fields},
+						},
+					},
+				},
+			},
+		},
+	}
+}
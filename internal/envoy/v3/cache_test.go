@@ -0,0 +1,91 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestCacheMetadata(t *testing.T) {
+	tests := map[string]struct {
+		policy *dag.CachePolicy
+		want   map[string]*_struct.Value
+	}{
+		"nil policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"methods only": {
+			policy: &dag.CachePolicy{
+				Methods: []string{"GET"},
+			},
+			want: map[string]*_struct.Value{
+				"methods": {
+					Kind: &_struct.Value_ListValue{
+						ListValue: &_struct.ListValue{
+							Values: []*_struct.Value{stringValue("GET")},
+						},
+					},
+				},
+			},
+		},
+		"key headers and max object size": {
+			policy: &dag.CachePolicy{
+				Methods:       []string{"GET", "HEAD"},
+				MaxObjectSize: 1024,
+				KeyHeaders:    []string{"accept-language", "x-tenant"},
+			},
+			want: map[string]*_struct.Value{
+				"methods": {
+					Kind: &_struct.Value_ListValue{
+						ListValue: &_struct.ListValue{
+							Values: []*_struct.Value{stringValue("GET"), stringValue("HEAD")},
+						},
+					},
+				},
+				"vary": stringValue("accept-language, x-tenant"),
+				"max_object_size": {
+					Kind: &_struct.Value_NumberValue{NumberValue: 1024},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var want *envoy_core_v3.Metadata
+			if tc.want != nil {
+				want = &envoy_core_v3.Metadata{
+					FilterMetadata: map[string]*_struct.Struct{
+						"envoy.filters.http.lua": {
+							Fields: map[string]*_struct.Value{
+								"cache_policy": {
+									Kind: &_struct.Value_StructValue{
+										StructValue: &_struct.Struct{Fields: tc.want},
+									},
+								},
+							},
+						},
+					},
+				}
+			}
+			protobuf.ExpectEqual(t, want, CacheMetadata(tc.policy))
+		})
+	}
+}
@@ -21,7 +21,12 @@ import (
 	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_cluster_dynamic_forward_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/clusters/dynamic_forward_proxy/v3"
+	envoy_common_dynamic_forward_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/dynamic_forward_proxy/v3"
+	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_extensions_upstream_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -47,16 +52,33 @@ func Cluster(c *dag.Cluster) *envoy_cluster_v3.Cluster {
 	cluster.LbPolicy = lbPolicy(c.LoadBalancerPolicy)
 	cluster.HealthChecks = edshealthcheck(c)
 	cluster.DnsLookupFamily = parseDNSLookupFamily(c.DNSLookupFamily)
+	cluster.UpstreamConnectionOptions = UpstreamTCPKeepalive(service.TCPKeepalive)
 
-	switch len(service.ExternalName) {
-	case 0:
+	if c.ConnectTimeout > 0 {
+		cluster.ConnectTimeout = protobuf.Duration(c.ConnectTimeout)
+	}
+
+	switch {
+	case len(service.StaticEndpoints) > 0:
+		// static endpoints set, use hard coded addresses
+		cluster.ClusterDiscoveryType = ClusterDiscoveryType(envoy_cluster_v3.Cluster_STATIC)
+		cluster.LoadAssignment = StaticEndpointsClusterLoadAssignment(service)
+	case len(service.ExternalName) == 0:
 		// external name not set, cluster will be discovered via EDS
 		cluster.ClusterDiscoveryType = ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS)
-		cluster.EdsClusterConfig = edsconfig("contour", service)
+		cluster.EdsClusterConfig = edsconfig("contour", c)
 	default:
 		// external name set, use hard coded DNS name
 		cluster.ClusterDiscoveryType = ClusterDiscoveryType(envoy_cluster_v3.Cluster_STRICT_DNS)
 		cluster.LoadAssignment = StaticClusterLoadAssignment(service)
+
+		if service.DNSLookupFamily != "" {
+			cluster.DnsLookupFamily = parseDNSLookupFamily(service.DNSLookupFamily)
+		}
+		if service.DNSRefreshRate > 0 {
+			cluster.DnsRefreshRate = protobuf.Duration(service.DNSRefreshRate)
+		}
+		cluster.RespectDnsTtl = service.RespectDNSTTL
 	}
 
 	// Drain connections immediately if using healthchecks and the endpoint is known to be removed
@@ -75,32 +97,116 @@ func Cluster(c *dag.Cluster) *envoy_cluster_v3.Cluster {
 		}
 	}
 
+	cluster.MaxRequestsPerConnection = protobuf.UInt32OrNil(service.MaxRequestsPerConnection)
+	cluster.TypedExtensionProtocolOptions = clusterHTTPProtocolOptions(c)
+
 	switch c.Protocol {
 	case "tls":
 		cluster.TransportSocket = UpstreamTLSTransportSocket(
-			UpstreamTLSContext(
-				c.UpstreamValidation,
-				c.SNI,
-				c.ClientCertificate,
-			),
+			clusterTLSContext(c),
 		)
 	case "h2":
-		cluster.TypedExtensionProtocolOptions = http2ProtocolOptions()
 		cluster.TransportSocket = UpstreamTLSTransportSocket(
-			UpstreamTLSContext(
-				c.UpstreamValidation,
-				c.SNI,
-				c.ClientCertificate,
-				"h2",
-			),
+			clusterTLSContext(c, "h2"),
 		)
-	case "h2c":
-		cluster.TypedExtensionProtocolOptions = http2ProtocolOptions()
+	}
+
+	if c.UpstreamProxyProtocol != "" {
+		cluster.TransportSocket = UpstreamProxyProtocolTransportSocket(c.UpstreamProxyProtocol, cluster.TransportSocket)
 	}
 
 	return cluster
 }
 
+// clusterHTTPProtocolOptions returns the HTTP protocol options for an
+// upstream cluster, or nil if the cluster uses Envoy's defaults.
+func clusterHTTPProtocolOptions(c *dag.Cluster) map[string]*any.Any {
+	opts := &envoy_extensions_upstream_http_v3.HttpProtocolOptions{}
+
+	switch c.Protocol {
+	case "h2", "h2c":
+		opts.UpstreamProtocolOptions = &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+					Http2ProtocolOptions: http2Settings(c.HTTP2),
+				},
+			},
+		}
+	default:
+		if c.Upstream.MaxConnectionDuration == 0 {
+			return nil
+		}
+		opts.UpstreamProtocolOptions = &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_HttpProtocolOptions{},
+			},
+		}
+	}
+
+	if d := c.Upstream.MaxConnectionDuration; d > 0 {
+		opts.CommonHttpProtocolOptions = &envoy_core_v3.HttpProtocolOptions{
+			MaxConnectionDuration: protobuf.Duration(d),
+		}
+	}
+
+	return map[string]*any.Any{
+		"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": protobuf.MustMarshalAny(opts),
+	}
+}
+
+// http2Settings returns the HTTP/2 protocol options for the given
+// settings, or nil if they are not set.
+func http2Settings(h *dag.HTTP2Settings) *envoy_core_v3.Http2ProtocolOptions {
+	if h == nil {
+		return nil
+	}
+
+	opts := &envoy_core_v3.Http2ProtocolOptions{
+		MaxConcurrentStreams:        protobuf.UInt32OrNil(h.MaxConcurrentStreams),
+		InitialStreamWindowSize:     protobuf.UInt32OrNil(h.InitialStreamWindowSize),
+		InitialConnectionWindowSize: protobuf.UInt32OrNil(h.InitialConnectionWindowSize),
+	}
+	if h.KeepaliveTimeout > 0 {
+		opts.ConnectionKeepalive = &envoy_core_v3.KeepaliveSettings{
+			Interval: protobuf.Duration(h.KeepaliveInterval),
+			Timeout:  protobuf.Duration(h.KeepaliveTimeout),
+		}
+	}
+
+	return opts
+}
+
+// clusterTLSContext returns the upstream TLS context for the given
+// cluster. If the cluster has a SPIFFE identity, its client certificate
+// and trust bundle are fetched from the SPIFFE Workload API over SDS.
+func clusterTLSContext(c *dag.Cluster, alpnProtocols ...string) *envoy_tls_v3.UpstreamTlsContext {
+	context := UpstreamTLSContext(c.UpstreamValidation, c.SNI, c.ClientCertificate, alpnProtocols...)
+
+	if c.SPIFFE == nil {
+		return context
+	}
+
+	if c.SPIFFE.ID != "" {
+		context.CommonTlsContext.TlsCertificateSdsSecretConfigs = []*envoy_tls_v3.SdsSecretConfig{{
+			Name:      c.SPIFFE.ID,
+			SdsConfig: ConfigSource(envoy.SPIFFEWorkloadAPIClusterName),
+		}}
+	}
+
+	// An explicit UpstreamValidation takes precedence over
+	// the SPIFFE trust bundle.
+	if c.SPIFFE.TrustDomain != "" && context.CommonTlsContext.ValidationContextType == nil {
+		context.CommonTlsContext.ValidationContextType = &envoy_tls_v3.CommonTlsContext_ValidationContextSdsSecretConfig{
+			ValidationContextSdsSecretConfig: &envoy_tls_v3.SdsSecretConfig{
+				Name:      c.SPIFFE.TrustDomain,
+				SdsConfig: ConfigSource(envoy.SPIFFEWorkloadAPIClusterName),
+			},
+		}
+	}
+
+	return context
+}
+
 // ExtensionCluster builds a envoy_cluster_v3.Cluster struct for the given extension service.
 func ExtensionCluster(ext *dag.ExtensionCluster) *envoy_cluster_v3.Cluster {
 	cluster := clusterDefaults()
@@ -146,6 +252,64 @@ func ExtensionCluster(ext *dag.ExtensionCluster) *envoy_cluster_v3.Cluster {
 	return cluster
 }
 
+// DynamicForwardProxyClusterName is the name of the Envoy cluster that
+// serves routes using the dynamic forward proxy.
+const DynamicForwardProxyClusterName = "dynamic_forward_proxy"
+
+// DynamicForwardProxyCluster builds a envoy_cluster_v3.Cluster that
+// connects to the upstream resolved by the dynamic forward proxy DNS cache.
+func DynamicForwardProxyCluster(c *dag.DynamicForwardProxyCluster) *envoy_cluster_v3.Cluster {
+	cluster := clusterDefaults()
+
+	cluster.Name = DynamicForwardProxyClusterName
+	cluster.AltStatName = DynamicForwardProxyClusterName
+	cluster.LbPolicy = envoy_cluster_v3.Cluster_CLUSTER_PROVIDED
+	cluster.ClusterDiscoveryType = &envoy_cluster_v3.Cluster_ClusterType{
+		ClusterType: &envoy_cluster_v3.Cluster_CustomClusterType{
+			Name: "envoy.clusters.dynamic_forward_proxy",
+			TypedConfig: protobuf.MustMarshalAny(&envoy_cluster_dynamic_forward_proxy_v3.ClusterConfig{
+				DnsCacheConfig: dynamicForwardProxyDNSCacheConfig(c.DNSLookupFamily),
+			}),
+		},
+	}
+
+	return cluster
+}
+
+// MirrorClusterName is the name of the Envoy cluster that mirrored
+// requests whose headers are changed are sent to. The cluster
+// connects back to Envoy, whose mirror listener changes the headers
+// and proxies the requests on to the mirror service.
+const MirrorClusterName = "contour_mirror"
+
+// MirrorCluster builds a envoy_cluster_v3.Cluster that connects to
+// Envoy's mirror listener at address and port. HTTP/2 is used, so
+// that mirrored gRPC requests keep their trailers.
+func MirrorCluster(address string, port int) *envoy_cluster_v3.Cluster {
+	cluster := clusterDefaults()
+
+	cluster.Name = MirrorClusterName
+	cluster.AltStatName = MirrorClusterName
+	cluster.ClusterDiscoveryType = ClusterDiscoveryType(envoy_cluster_v3.Cluster_STATIC)
+	cluster.LoadAssignment = &envoy_endpoint_v3.ClusterLoadAssignment{
+		ClusterName: MirrorClusterName,
+		Endpoints:   Endpoints(SocketAddress(address, port)),
+	}
+	cluster.TypedExtensionProtocolOptions = http2ProtocolOptions()
+
+	return cluster
+}
+
+// dynamicForwardProxyDNSCacheConfig returns the DNS cache shared by the
+// dynamic forward proxy cluster and HTTP filter. Envoy requires both to
+// reference an identical cache configuration.
+func dynamicForwardProxyDNSCacheConfig(dnsLookupFamily string) *envoy_common_dynamic_forward_proxy_v3.DnsCacheConfig {
+	return &envoy_common_dynamic_forward_proxy_v3.DnsCacheConfig{
+		Name:            DynamicForwardProxyClusterName,
+		DnsLookupFamily: parseDNSLookupFamily(dnsLookupFamily),
+	}
+}
+
 // StaticClusterLoadAssignment creates a *envoy_endpoint_v3.ClusterLoadAssignment pointing to the external DNS address of the service
 func StaticClusterLoadAssignment(service *dag.Service) *envoy_endpoint_v3.ClusterLoadAssignment {
 	addr := SocketAddress(service.ExternalName, int(service.Weighted.ServicePort.Port))
@@ -158,13 +322,23 @@ func StaticClusterLoadAssignment(service *dag.Service) *envoy_endpoint_v3.Cluste
 	}
 }
 
-func edsconfig(cluster string, service *dag.Service) *envoy_cluster_v3.Cluster_EdsClusterConfig {
+// StaticEndpointsClusterLoadAssignment creates a *envoy_endpoint_v3.ClusterLoadAssignment pointing to the static endpoints of the service
+func StaticEndpointsClusterLoadAssignment(service *dag.Service) *envoy_endpoint_v3.ClusterLoadAssignment {
+	var addrs []*envoy_core_v3.Address
+	for _, ep := range service.StaticEndpoints {
+		addrs = append(addrs, SocketAddress(ep.Address, int(ep.Port)))
+	}
+
+	return &envoy_endpoint_v3.ClusterLoadAssignment{
+		Endpoints:   Endpoints(addrs...),
+		ClusterName: service.Weighted.ClusterLoadAssignmentName(),
+	}
+}
+
+func edsconfig(cluster string, c *dag.Cluster) *envoy_cluster_v3.Cluster_EdsClusterConfig {
 	return &envoy_cluster_v3.Cluster_EdsClusterConfig{
-		EdsConfig: ConfigSource(cluster),
-		ServiceName: xds.ClusterLoadAssignmentName(
-			types.NamespacedName{Name: service.Weighted.ServiceName, Namespace: service.Weighted.ServiceNamespace},
-			service.Weighted.ServicePort.Name,
-		),
+		EdsConfig:   ConfigSource(cluster),
+		ServiceName: c.ClusterLoadAssignmentName(),
 	}
 }
 
@@ -206,6 +380,19 @@ func ClusterCommonLBConfig() *envoy_cluster_v3.Cluster_CommonLbConfig {
 	}
 }
 
+// ZoneAwareLBConfig returns a *envoy_cluster_v3.Cluster_CommonLbConfig_ZoneAwareLbConfig
+// that always enables zone-aware routing for upstream clusters of at
+// least minClusterSize hosts. If minClusterSize is zero, the Envoy
+// default applies.
+func ZoneAwareLBConfig(minClusterSize uint64) *envoy_cluster_v3.Cluster_CommonLbConfig_ZoneAwareLbConfig {
+	return &envoy_cluster_v3.Cluster_CommonLbConfig_ZoneAwareLbConfig{
+		RoutingEnabled: &envoy_type.Percent{
+			Value: 100,
+		},
+		MinClusterSize: protobuf.UInt64OrNil(minClusterSize),
+	}
+}
+
 // ConfigSource returns a *envoy_core_v3.ConfigSource for cluster.
 func ConfigSource(cluster string) *envoy_core_v3.ConfigSource {
 	return &envoy_core_v3.ConfigSource{
@@ -226,6 +413,14 @@ func ConfigSource(cluster string) *envoy_core_v3.ConfigSource {
 	}
 }
 
+// DeltaConfigSource returns a *envoy_core_v3.ConfigSource for cluster
+// that uses the incremental (delta) variant of the xDS protocol.
+func DeltaConfigSource(cluster string) *envoy_core_v3.ConfigSource {
+	cs := ConfigSource(cluster)
+	cs.GetApiConfigSource().ApiType = envoy_core_v3.ApiConfigSource_DELTA_GRPC
+	return cs
+}
+
 // ClusterDiscoveryType returns the type of a ClusterDiscovery as a Cluster_type.
 func ClusterDiscoveryType(t envoy_cluster_v3.Cluster_DiscoveryType) *envoy_cluster_v3.Cluster_Type {
 	return &envoy_cluster_v3.Cluster_Type{Type: t}
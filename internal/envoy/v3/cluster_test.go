@@ -19,6 +19,10 @@ import (
 
 	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_cluster_dynamic_forward_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/clusters/dynamic_forward_proxy/v3"
+	envoy_common_dynamic_forward_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/dynamic_forward_proxy/v3"
+	envoy_v3_tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoy_extensions_upstream_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/proto"
@@ -151,6 +155,136 @@ func TestCluster(t *testing.T) {
 				},
 			},
 		},
+		"max requests per connection and connection duration": {
+			cluster: &dag.Cluster{
+				Upstream: &dag.Service{
+					Weighted: dag.WeightedService{
+						Weight:           1,
+						ServiceName:      s1.Name,
+						ServiceNamespace: s1.Namespace,
+						ServicePort:      s1.Spec.Ports[0],
+					},
+					MaxRequestsPerConnection: 100,
+					MaxConnectionDuration:    5 * time.Minute,
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/da39a3ee5e",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				MaxRequestsPerConnection: protobuf.UInt32(100),
+				TypedExtensionProtocolOptions: map[string]*any.Any{
+					"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": protobuf.MustMarshalAny(
+						&envoy_extensions_upstream_http_v3.HttpProtocolOptions{
+							CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{
+								MaxConnectionDuration: protobuf.Duration(5 * time.Minute),
+							},
+							UpstreamProtocolOptions: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+								ExplicitHttpConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+									ProtocolConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_HttpProtocolOptions{},
+								},
+							},
+						}),
+				},
+			},
+		},
+		"tcp keepalive": {
+			cluster: &dag.Cluster{
+				Upstream: &dag.Service{
+					Weighted: dag.WeightedService{
+						Weight:           1,
+						ServiceName:      s1.Name,
+						ServiceNamespace: s1.Namespace,
+						ServicePort:      s1.Spec.Ports[0],
+					},
+					TCPKeepalive: &dag.TCPKeepalive{
+						Probes: 3,
+						Time:   60,
+					},
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/da39a3ee5e",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				UpstreamConnectionOptions: &envoy_cluster_v3.UpstreamConnectionOptions{
+					TcpKeepalive: &envoy_core_v3.TcpKeepalive{
+						KeepaliveProbes: protobuf.UInt32(3),
+						KeepaliveTime:   protobuf.UInt32(60),
+					},
+				},
+			},
+		},
+		"h2c upstream with http2 settings": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1, "h2c"),
+				Protocol: "h2c",
+				HTTP2: &dag.HTTP2Settings{
+					MaxConcurrentStreams:    100,
+					InitialStreamWindowSize: 1048576,
+					KeepaliveInterval:       10 * time.Second,
+					KeepaliveTimeout:        5 * time.Second,
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/4678450047",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				TypedExtensionProtocolOptions: map[string]*any.Any{
+					"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": protobuf.MustMarshalAny(
+						&envoy_extensions_upstream_http_v3.HttpProtocolOptions{
+							UpstreamProtocolOptions: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+								ExplicitHttpConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+									ProtocolConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+										Http2ProtocolOptions: &envoy_core_v3.Http2ProtocolOptions{
+											MaxConcurrentStreams:    protobuf.UInt32(100),
+											InitialStreamWindowSize: protobuf.UInt32(1048576),
+											ConnectionKeepalive: &envoy_core_v3.KeepaliveSettings{
+												Interval: protobuf.Duration(10 * time.Second),
+												Timeout:  protobuf.Duration(5 * time.Second),
+											},
+										},
+									},
+								},
+							},
+						}),
+				},
+			},
+		},
+		"failover service": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1),
+				Failover: []*dag.Service{{
+					Weighted: dag.WeightedService{
+						Weight:           1,
+						ServiceName:      "kuarder",
+						ServiceNamespace: "default",
+						ServicePort:      s1.Spec.Ports[0],
+					},
+				}},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/d194fed88b",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http/failover=default/kuarder/http",
+				},
+			},
+		},
 		"h2 upstream": {
 			cluster: &dag.Cluster{
 				Upstream: service(s1, "h2"),
@@ -190,6 +324,55 @@ func TestCluster(t *testing.T) {
 				LoadAssignment:       StaticClusterLoadAssignment(service(s2)),
 			},
 		},
+		"static endpoints": {
+			cluster: &dag.Cluster{
+				Upstream: &dag.Service{
+					Weighted: dag.WeightedService{
+						Weight:           1,
+						ServiceName:      "legacy",
+						ServiceNamespace: "default",
+						ServicePort:      v1.ServicePort{Protocol: "TCP", Port: 8080},
+					},
+					StaticEndpoints: []dag.StaticEndpoint{
+						{Address: "10.0.0.1", Port: 8080},
+						{Address: "192.168.0.9", Port: 9090},
+					},
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/legacy/8080/6a6d03ffa0",
+				AltStatName:          "default_legacy_8080",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_STATIC),
+				LoadAssignment: &envoy_endpoint_v3.ClusterLoadAssignment{
+					ClusterName: "default/legacy",
+					Endpoints: Endpoints(
+						SocketAddress("10.0.0.1", 8080),
+						SocketAddress("192.168.0.9", 9090),
+					),
+				},
+			},
+		},
+		"externalName service - dns annotations": {
+			cluster: &dag.Cluster{
+				Upstream: func() *dag.Service {
+					svc := service(s2)
+					svc.DNSLookupFamily = "v4"
+					svc.DNSRefreshRate = 5 * time.Second
+					svc.RespectDNSTTL = true
+					return svc
+				}(),
+				DNSLookupFamily: "auto",
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/da39a3ee5e",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_STRICT_DNS),
+				LoadAssignment:       StaticClusterLoadAssignment(service(s2)),
+				DnsLookupFamily:      envoy_cluster_v3.Cluster_V4_ONLY,
+				DnsRefreshRate:       protobuf.Duration(5 * time.Second),
+				RespectDnsTtl:        true,
+			},
+		},
 		"externalName service - dns-lookup-family v4": {
 			cluster: &dag.Cluster{
 				Upstream:        service(s2),
@@ -260,6 +443,59 @@ func TestCluster(t *testing.T) {
 				),
 			},
 		},
+		"upstream proxy protocol": {
+			cluster: &dag.Cluster{
+				Upstream:              service(s1),
+				UpstreamProxyProtocol: "v1",
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/5a6df72054",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				TransportSocket: UpstreamProxyProtocolTransportSocket("v1", nil),
+			},
+		},
+		"tls upstream with proxy protocol": {
+			cluster: &dag.Cluster{
+				Upstream:              service(s1, "tls"),
+				Protocol:              "tls",
+				UpstreamProxyProtocol: "v2",
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/a1047eab10",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				TransportSocket: UpstreamProxyProtocolTransportSocket("v2",
+					UpstreamTLSTransportSocket(
+						UpstreamTLSContext(nil, "", nil),
+					),
+				),
+			},
+		},
+		"connect timeout": {
+			cluster: &dag.Cluster{
+				Upstream:       service(s1),
+				ConnectTimeout: 5 * time.Second,
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/1878db53f3",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				ConnectTimeout: protobuf.Duration(5 * time.Second),
+			},
+		},
 		"tls upstream - external name": {
 			cluster: &dag.Cluster{
 				Upstream: service(svcExternal, "tls"),
@@ -489,6 +725,45 @@ func TestCluster(t *testing.T) {
 				}},
 			},
 		},
+		"tcp service with healthcheck payloads": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1),
+				TCPHealthCheckPolicy: &dag.TCPHealthCheckPolicy{
+					Send:    "50494e470d0a",
+					Receive: []string{"2b504f4e470d0a"},
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/45abaea14d",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				IgnoreHealthOnHostRemoval: true,
+				HealthChecks: []*envoy_core_v3.HealthCheck{{
+					Timeout:            protobuf.Duration(envoy.HCTimeout),
+					Interval:           protobuf.Duration(envoy.HCInterval),
+					UnhealthyThreshold: protobuf.UInt32(envoy.HCUnhealthyThreshold),
+					HealthyThreshold:   protobuf.UInt32(envoy.HCHealthyThreshold),
+					HealthChecker: &envoy_core_v3.HealthCheck_TcpHealthCheck_{
+						TcpHealthCheck: &envoy_core_v3.HealthCheck_TcpHealthCheck{
+							Send: &envoy_core_v3.HealthCheck_Payload{
+								Payload: &envoy_core_v3.HealthCheck_Payload_Text{
+									Text: "50494e470d0a",
+								},
+							},
+							Receive: []*envoy_core_v3.HealthCheck_Payload{{
+								Payload: &envoy_core_v3.HealthCheck_Payload_Text{
+									Text: "2b504f4e470d0a",
+								},
+							}},
+						},
+					},
+				}},
+			},
+		},
 		"use client certificate to authentication towards backend": {
 			cluster: &dag.Cluster{
 				Upstream:          service(s1, "tls"),
@@ -508,6 +783,72 @@ func TestCluster(t *testing.T) {
 				),
 			},
 		},
+		"use SPIFFE identity to authenticate towards backend": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1, "tls"),
+				Protocol: "tls",
+				SPIFFE: &dag.SPIFFEIdentity{
+					ID:          "spiffe://example.org/ns/projectcontour/sa/envoy",
+					TrustDomain: "spiffe://example.org",
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/da39a3ee5e",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				TransportSocket: UpstreamTLSTransportSocket(
+					&envoy_v3_tls.UpstreamTlsContext{
+						CommonTlsContext: &envoy_v3_tls.CommonTlsContext{
+							TlsCertificateSdsSecretConfigs: []*envoy_v3_tls.SdsSecretConfig{{
+								Name:      "spiffe://example.org/ns/projectcontour/sa/envoy",
+								SdsConfig: ConfigSource("spiffe_workload_api"),
+							}},
+							ValidationContextType: &envoy_v3_tls.CommonTlsContext_ValidationContextSdsSecretConfig{
+								ValidationContextSdsSecretConfig: &envoy_v3_tls.SdsSecretConfig{
+									Name:      "spiffe://example.org",
+									SdsConfig: ConfigSource("spiffe_workload_api"),
+								},
+							},
+						},
+					},
+				),
+			},
+		},
+		"SPIFFE trust bundle does not replace upstream validation": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1, "tls"),
+				Protocol: "tls",
+				UpstreamValidation: &dag.PeerValidationContext{
+					CACertificate: secret,
+					SubjectName:   "foo.bar.io",
+				},
+				SPIFFE: &dag.SPIFFEIdentity{
+					TrustDomain: "spiffe://example.org",
+				},
+			},
+			want: &envoy_cluster_v3.Cluster{
+				Name:                 "default/kuard/443/3ac4e90987",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_EDS),
+				EdsClusterConfig: &envoy_cluster_v3.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				TransportSocket: UpstreamTLSTransportSocket(
+					UpstreamTLSContext(
+						&dag.PeerValidationContext{
+							CACertificate: secret,
+							SubjectName:   "foo.bar.io",
+						},
+						"",
+						nil),
+				),
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -517,6 +858,12 @@ func TestCluster(t *testing.T) {
 
 			proto.Merge(want, tc.want)
 
+			// proto.Merge merges the fields of the default connect
+			// timeout with the expected one, so replace it instead.
+			if tc.want.ConnectTimeout != nil {
+				want.ConnectTimeout = tc.want.ConnectTimeout
+			}
+
 			protobuf.ExpectEqual(t, want, got)
 		})
 	}
@@ -638,6 +985,58 @@ func TestClustername(t *testing.T) {
 	}
 }
 
+func TestDynamicForwardProxyCluster(t *testing.T) {
+	got := DynamicForwardProxyCluster(&dag.DynamicForwardProxyCluster{
+		DNSLookupFamily: "v4",
+	})
+	want := &envoy_cluster_v3.Cluster{
+		Name:           "dynamic_forward_proxy",
+		AltStatName:    "dynamic_forward_proxy",
+		ConnectTimeout: protobuf.Duration(250 * time.Millisecond),
+		CommonLbConfig: ClusterCommonLBConfig(),
+		LbPolicy:       envoy_cluster_v3.Cluster_CLUSTER_PROVIDED,
+		ClusterDiscoveryType: &envoy_cluster_v3.Cluster_ClusterType{
+			ClusterType: &envoy_cluster_v3.Cluster_CustomClusterType{
+				Name: "envoy.clusters.dynamic_forward_proxy",
+				TypedConfig: protobuf.MustMarshalAny(&envoy_cluster_dynamic_forward_proxy_v3.ClusterConfig{
+					DnsCacheConfig: &envoy_common_dynamic_forward_proxy_v3.DnsCacheConfig{
+						Name:            "dynamic_forward_proxy",
+						DnsLookupFamily: envoy_cluster_v3.Cluster_V4_ONLY,
+					},
+				}),
+			},
+		},
+	}
+	protobuf.ExpectEqual(t, want, got)
+}
+
+func TestMirrorCluster(t *testing.T) {
+	got := MirrorCluster("127.0.0.1", 8009)
+	want := &envoy_cluster_v3.Cluster{
+		Name:                 "contour_mirror",
+		AltStatName:          "contour_mirror",
+		ConnectTimeout:       protobuf.Duration(250 * time.Millisecond),
+		CommonLbConfig:       ClusterCommonLBConfig(),
+		LbPolicy:             envoy_cluster_v3.Cluster_ROUND_ROBIN,
+		ClusterDiscoveryType: ClusterDiscoveryType(envoy_cluster_v3.Cluster_STATIC),
+		LoadAssignment: &envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "contour_mirror",
+			Endpoints:   Endpoints(SocketAddress("127.0.0.1", 8009)),
+		},
+		TypedExtensionProtocolOptions: map[string]*any.Any{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": protobuf.MustMarshalAny(
+				&envoy_extensions_upstream_http_v3.HttpProtocolOptions{
+					UpstreamProtocolOptions: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_{
+						ExplicitHttpConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig{
+							ProtocolConfig: &envoy_extensions_upstream_http_v3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{},
+						},
+					},
+				}),
+		},
+	}
+	protobuf.ExpectEqual(t, want, got)
+}
+
 func TestLBPolicy(t *testing.T) {
 	tests := map[string]envoy_cluster_v3.Cluster_LbPolicy{
 		"WeightedLeastRequest": envoy_cluster_v3.Cluster_LEAST_REQUEST,
@@ -0,0 +1,184 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// luaMetadataNamespace is the namespace of the route metadata
+// that is visible to Lua filters.
+const luaMetadataNamespace = "envoy.filters.http.lua"
+
+// cookieRewriteMetadataKey is the route metadata key that holds
+// the cookie rewrite policies of the route.
+const cookieRewriteMetadataKey = "cookie_rewrite_policies"
+
+// cookieRewriteCode rewrites the Set-Cookie headers of responses
+// with the cookie rewrite policies in the route metadata. Routes
+// without policies are left alone.
+const cookieRewriteCode = `
+local function trim(s)
+	return (string.gsub(s, "^%s*(.-)%s*$", "%1"))
+end
+
+local function find_policy(policies, name)
+	local default = nil
+	for _, policy in ipairs(policies) do
+		if policy["name"] == name then
+			return policy
+		end
+		if policy["name"] == nil then
+			default = policy
+		end
+	end
+	return default
+end
+
+local function rewrite(policies, cookie)
+	local policy = find_policy(policies, trim(string.match(cookie, "^[^=;]*")))
+	if policy == nil then
+		return cookie
+	end
+
+	local rewritten = {
+		domain = policy["domain"] ~= nil,
+		secure = policy["secure"] ~= nil,
+		httponly = policy["http_only"] ~= nil,
+		samesite = policy["same_site"] ~= nil,
+	}
+
+	local parts = {}
+	for part in string.gmatch(cookie, "[^;]+") do
+		part = trim(part)
+		if #parts == 0 then
+			table.insert(parts, part)
+		elseif not rewritten[string.lower(trim(string.match(part, "^[^=]*")))] then
+			table.insert(parts, part)
+		end
+	end
+
+	if policy["domain"] ~= nil and policy["domain"] ~= "" then
+		table.insert(parts, "Domain=" .. policy["domain"])
+	end
+	if policy["secure"] then
+		table.insert(parts, "Secure")
+	end
+	if policy["http_only"] then
+		table.insert(parts, "HttpOnly")
+	end
+	if policy["same_site"] ~= nil then
+		table.insert(parts, "SameSite=" .. policy["same_site"])
+	end
+
+	return table.concat(parts, "; ")
+end
+
+function envoy_on_response(response_handle)
+	local policies = response_handle:metadata():get("` + cookieRewriteMetadataKey + `")
+	if policies == nil then
+		return
+	end
+
+	local headers = response_handle:headers()
+	local cookies = {}
+	for key, value in pairs(headers) do
+		if key == "set-cookie" then
+			table.insert(cookies, value)
+		end
+	end
+	if #cookies == 0 then
+		return
+	end
+
+	headers:remove("set-cookie")
+	for _, cookie in ipairs(cookies) do
+		headers:add("set-cookie", rewrite(policies, cookie))
+	end
+end
+`
+
+// FilterCookieRewrite returns a Lua filter that rewrites the attributes
+// of the cookies set by responses, as configured by the route metadata
+// returned by CookieRewriteMetadata.
+func FilterCookieRewrite() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "cookie_rewrite",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: cookieRewriteCode,
+			}),
+		},
+	}
+}
+
+// CookieRewriteMetadata returns the route metadata that configures the
+// cookie rewrite filter with the given policies, or nil if there are none.
+func CookieRewriteMetadata(policies []dag.CookieRewritePolicy) *envoy_core_v3.Metadata {
+	if len(policies) == 0 {
+		return nil
+	}
+
+	values := make([]*_struct.Value, 0, len(policies))
+	for _, p := range policies {
+		fields := map[string]*_struct.Value{}
+		if p.Name != "" {
+			fields["name"] = stringValue(p.Name)
+		}
+		if p.Domain != nil {
+			fields["domain"] = stringValue(*p.Domain)
+		}
+		if p.Secure != nil {
+			fields["secure"] = boolValue(*p.Secure)
+		}
+		if p.HTTPOnly != nil {
+			fields["http_only"] = boolValue(*p.HTTPOnly)
+		}
+		if p.SameSite != "" {
+			fields["same_site"] = stringValue(p.SameSite)
+		}
+		values = append(values, &_struct.Value{
+			Kind: &_struct.Value_StructValue{
+				StructValue: &_struct.Struct{Fields: fields},
+			},
+		})
+	}
+
+	return &envoy_core_v3.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			luaMetadataNamespace: {
+				Fields: map[string]*_struct.Value{
+					cookieRewriteMetadataKey: {
+						Kind: &_struct.Value_ListValue{
+							ListValue: &_struct.ListValue{Values: values},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func stringValue(s string) *_struct.Value {
+	return &_struct.Value{Kind: &_struct.Value_StringValue{StringValue: s}}
+}
+
+func boolValue(b bool) *_struct.Value {
+	return &_struct.Value{Kind: &_struct.Value_BoolValue{BoolValue: b}}
+}
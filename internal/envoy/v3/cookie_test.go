@@ -0,0 +1,86 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"k8s.io/utils/pointer"
+)
+
+func TestCookieRewriteMetadata(t *testing.T) {
+	tests := map[string]struct {
+		policies []dag.CookieRewritePolicy
+		want     *envoy_core_v3.Metadata
+	}{
+		"no policies": {
+			policies: nil,
+			want:     nil,
+		},
+		"named and default policies": {
+			policies: []dag.CookieRewritePolicy{{
+				Name:     "session",
+				Domain:   pointer.StringPtr(""),
+				SameSite: "Lax",
+			}, {
+				Secure:   pointer.BoolPtr(true),
+				HTTPOnly: pointer.BoolPtr(false),
+			}},
+			want: &envoy_core_v3.Metadata{
+				FilterMetadata: map[string]*_struct.Struct{
+					"envoy.filters.http.lua": {
+						Fields: map[string]*_struct.Value{
+							"cookie_rewrite_policies": {
+								Kind: &_struct.Value_ListValue{
+									ListValue: &_struct.ListValue{
+										Values: []*_struct.Value{{
+											Kind: &_struct.Value_StructValue{
+												StructValue: &_struct.Struct{
+													Fields: map[string]*_struct.Value{
+														"name":      stringValue("session"),
+														"domain":    stringValue(""),
+														"same_site": stringValue("Lax"),
+													},
+												},
+											},
+										}, {
+											Kind: &_struct.Value_StructValue{
+												StructValue: &_struct.Struct{
+													Fields: map[string]*_struct.Value{
+														"secure":    boolValue(true),
+														"http_only": boolValue(false),
+													},
+												},
+											},
+										}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, CookieRewriteMetadata(tc.policies))
+		})
+	}
+}
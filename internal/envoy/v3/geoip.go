@@ -0,0 +1,80 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"time"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/projectcontour/contour/internal/geoip"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// geoIPTimeout is how long Envoy waits for Contour to look up
+// the client address of a request.
+const geoIPTimeout = 250 * time.Millisecond
+
+// geoIPClearCode removes the GeoIP headers that clients send, so that
+// they are not passed on if Contour can't be reached.
+const geoIPClearCode = `
+function envoy_on_request(request_handle)
+	local headers = request_handle:headers()
+	headers:remove("` + geoip.CountryHeader + `")
+	headers:remove("` + geoip.ASNHeader + `")
+end
+`
+
+// FilterGeoIPClear returns a Lua filter that removes the GeoIP headers
+// from requests. It must come before the filter returned by FilterGeoIP.
+func FilterGeoIPClear() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "geoip_clear",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: geoIPClearCode,
+			}),
+		},
+	}
+}
+
+// FilterGeoIP returns an `ext_authz` filter that has Contour, which
+// Envoy reaches through its xDS cluster, set the GeoIP headers of
+// each request. The route of the request is looked up again, so that
+// routes can match on the headers. Requests are allowed if Contour
+// can't be reached.
+func FilterGeoIP() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "geoip",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_config_filter_http_ext_authz_v3.ExtAuthz{
+				Services: &envoy_config_filter_http_ext_authz_v3.ExtAuthz_GrpcService{
+					GrpcService: &envoy_core_v3.GrpcService{
+						TargetSpecifier: &envoy_core_v3.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &envoy_core_v3.GrpcService_EnvoyGrpc{
+								ClusterName: "contour",
+							},
+						},
+						Timeout: protobuf.Duration(geoIPTimeout),
+					},
+				},
+				ClearRouteCache:     true,
+				FailureModeAllow:    true,
+				TransportApiVersion: envoy_core_v3.ApiVersion_V3,
+			}),
+		},
+	}
+}
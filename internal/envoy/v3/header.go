@@ -0,0 +1,228 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"strings"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// headerRemoveMetadataKey is the route metadata key that holds the
+// matches of the names of the headers to remove for the route.
+const headerRemoveMetadataKey = "header_remove_matching"
+
+// headerRemoveCode removes the request and response headers whose names
+// match the route metadata. Headers are removed before they are set, as
+// with the Remove headers of a route, but the router sets the response
+// headers before the Lua filter sees them, so the headers that the route
+// sets are kept.
+const headerRemoveCode = `
+local function matches(name, policy)
+	for _, match in ipairs(policy["match"]) do
+		if match["prefix"] ~= nil and string.sub(name, 1, #match["prefix"]) == match["prefix"] then
+			return true
+		end
+		if match["pattern"] ~= nil and string.find(name, match["pattern"]) ~= nil then
+			return true
+		end
+	end
+	return false
+end
+
+local function remove_headers(handle, policy)
+	if policy == nil then
+		return
+	end
+
+	local keep = {}
+	for _, name in ipairs(policy["keep"] or {}) do
+		keep[name] = true
+	end
+
+	local headers = handle:headers()
+	local names = {}
+	for key, _ in pairs(headers) do
+		local name = string.lower(key)
+		if string.sub(name, 1, 1) ~= ":" and not keep[name] and matches(name, policy) then
+			table.insert(names, key)
+		end
+	end
+	for _, name in ipairs(names) do
+		headers:remove(name)
+	end
+end
+
+function envoy_on_request(request_handle)
+	local policies = request_handle:metadata():get("` + headerRemoveMetadataKey + `")
+	if policies ~= nil then
+		remove_headers(request_handle, policies["request"])
+	end
+end
+
+function envoy_on_response(response_handle)
+	local policies = response_handle:metadata():get("` + headerRemoveMetadataKey + `")
+	if policies ~= nil then
+		remove_headers(response_handle, policies["response"])
+	end
+end
+`
+
+// FilterHeaderRemove returns a Lua filter that removes the request and
+// response headers whose names match the route metadata returned by
+// HeaderRemoveMetadata.
+func FilterHeaderRemove() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "header_remove",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: headerRemoveCode,
+			}),
+		},
+	}
+}
+
+// HeaderRemoveMetadata returns the route metadata that configures the
+// header remove filter with the RemoveMatching matches of the headers
+// policies of the route, or nil if there are none.
+func HeaderRemoveMetadata(route *dag.Route) *envoy_core_v3.Metadata {
+	var requestPolicies, responsePolicies []*dag.HeadersPolicy
+	for _, c := range route.Clusters {
+		requestPolicies = append(requestPolicies, c.RequestHeadersPolicy)
+		responsePolicies = append(responsePolicies, c.ResponseHeadersPolicy)
+	}
+
+	fields := map[string]*_struct.Value{}
+	if v := headerRemoveValue(route.RequestHeadersPolicy, requestPolicies); v != nil {
+		fields["request"] = v
+	}
+	if v := headerRemoveValue(route.ResponseHeadersPolicy, responsePolicies); v != nil {
+		fields["response"] = v
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &envoy_core_v3.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			luaMetadataNamespace: {
+				Fields: map[string]*_struct.Value{
+					headerRemoveMetadataKey: {
+						Kind: &_struct.Value_StructValue{
+							StructValue: &_struct.Struct{Fields: fields},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// headerRemoveValue returns the matches of policy, along with the lower
+// case names of the headers that are set by policy or clusterPolicies,
+// or nil if policy has no matches.
+func headerRemoveValue(policy *dag.HeadersPolicy, clusterPolicies []*dag.HeadersPolicy) *_struct.Value {
+	if policy == nil || len(policy.RemoveMatching) == 0 {
+		return nil
+	}
+
+	matches := make([]*_struct.Value, 0, len(policy.RemoveMatching))
+	for _, m := range policy.RemoveMatching {
+		fields := map[string]*_struct.Value{}
+		if m.Prefix != "" {
+			fields["prefix"] = stringValue(m.Prefix)
+		}
+		if m.Pattern != "" {
+			fields["pattern"] = stringValue(m.Pattern)
+		}
+		matches = append(matches, &_struct.Value{
+			Kind: &_struct.Value_StructValue{
+				StructValue: &_struct.Struct{Fields: fields},
+			},
+		})
+	}
+
+	keep := sets.NewString()
+	for _, p := range append([]*dag.HeadersPolicy{policy}, clusterPolicies...) {
+		if p == nil {
+			continue
+		}
+		for name := range p.Set {
+			keep.Insert(strings.ToLower(name))
+		}
+		for name := range p.Add {
+			keep.Insert(strings.ToLower(name))
+		}
+	}
+	keepValues := make([]*_struct.Value, 0, keep.Len())
+	for _, name := range keep.List() {
+		keepValues = append(keepValues, stringValue(name))
+	}
+
+	return &_struct.Value{
+		Kind: &_struct.Value_StructValue{
+			StructValue: &_struct.Struct{
+				Fields: map[string]*_struct.Value{
+					"match": {
+						Kind: &_struct.Value_ListValue{
+							ListValue: &_struct.ListValue{Values: matches},
+						},
+					},
+					"keep": {
+						Kind: &_struct.Value_ListValue{
+							ListValue: &_struct.ListValue{Values: keepValues},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// RouteMetadata returns the route metadata that configures the Lua
+// filters for the route, or nil if none of them apply to it.
+func RouteMetadata(route *dag.Route) *envoy_core_v3.Metadata {
+	var fields map[string]*_struct.Value
+	for _, md := range []*envoy_core_v3.Metadata{
+		CookieRewriteMetadata(route.CookieRewritePolicies),
+		HeaderRemoveMetadata(route),
+		CacheMetadata(route.CachePolicy),
+		TapMetadata(route.TapPolicy),
+	} {
+		if md == nil {
+			continue
+		}
+		if fields == nil {
+			fields = map[string]*_struct.Value{}
+		}
+		for k, v := range md.FilterMetadata[luaMetadataNamespace].Fields {
+			fields[k] = v
+		}
+	}
+	if fields == nil {
+		return nil
+	}
+
+	return &envoy_core_v3.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			luaMetadataNamespace: {Fields: fields},
+		},
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"k8s.io/utils/pointer"
+)
+
+func TestHeaderRemoveMetadata(t *testing.T) {
+	listValue := func(values ...*_struct.Value) *_struct.Value {
+		return &_struct.Value{
+			Kind: &_struct.Value_ListValue{
+				ListValue: &_struct.ListValue{Values: values},
+			},
+		}
+	}
+	structValue := func(fields map[string]*_struct.Value) *_struct.Value {
+		return &_struct.Value{
+			Kind: &_struct.Value_StructValue{
+				StructValue: &_struct.Struct{Fields: fields},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		route *dag.Route
+		want  *envoy_core_v3.Metadata
+	}{
+		"no headers policies": {
+			route: &dag.Route{},
+			want:  nil,
+		},
+		"headers policies without matches": {
+			route: &dag.Route{
+				RequestHeadersPolicy: &dag.HeadersPolicy{
+					Remove: []string{"X-Internal-Id"},
+				},
+			},
+			want: nil,
+		},
+		"request and response matches": {
+			route: &dag.Route{
+				RequestHeadersPolicy: &dag.HeadersPolicy{
+					RemoveMatching: []dag.HeaderNameMatch{{
+						Prefix: "x-internal-",
+					}},
+				},
+				ResponseHeadersPolicy: &dag.HeadersPolicy{
+					Set: map[string]string{
+						"X-Debug-Route": "%ROUTE_NAME%",
+					},
+					RemoveMatching: []dag.HeaderNameMatch{{
+						Pattern: "^x%-debug%-",
+					}},
+				},
+				Clusters: []*dag.Cluster{{
+					ResponseHeadersPolicy: &dag.HeadersPolicy{
+						Add: map[string]string{
+							"X-Debug-Cluster": "s1",
+						},
+					},
+				}, {
+					ResponseHeadersPolicy: nil,
+				}},
+			},
+			want: &envoy_core_v3.Metadata{
+				FilterMetadata: map[string]*_struct.Struct{
+					"envoy.filters.http.lua": {
+						Fields: map[string]*_struct.Value{
+							"header_remove_matching": structValue(map[string]*_struct.Value{
+								"request": structValue(map[string]*_struct.Value{
+									"match": listValue(structValue(map[string]*_struct.Value{
+										"prefix": stringValue("x-internal-"),
+									})),
+									"keep": listValue(),
+								}),
+								"response": structValue(map[string]*_struct.Value{
+									"match": listValue(structValue(map[string]*_struct.Value{
+										"pattern": stringValue("^x%-debug%-"),
+									})),
+									"keep": listValue(
+										stringValue("x-debug-cluster"),
+										stringValue("x-debug-route"),
+									),
+								}),
+							}),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, HeaderRemoveMetadata(tc.route))
+		})
+	}
+}
+
+func TestRouteMetadata(t *testing.T) {
+	protobuf.ExpectEqual(t, (*envoy_core_v3.Metadata)(nil), RouteMetadata(&dag.Route{}))
+
+	route := &dag.Route{
+		RequestHeadersPolicy: &dag.HeadersPolicy{
+			RemoveMatching: []dag.HeaderNameMatch{{
+				Prefix: "x-internal-",
+			}},
+		},
+		CookieRewritePolicies: []dag.CookieRewritePolicy{{
+			Secure: pointer.BoolPtr(true),
+		}},
+		CachePolicy: &dag.CachePolicy{
+			Methods: []string{"GET"},
+		},
+	}
+
+	got := RouteMetadata(route)
+	want := &envoy_core_v3.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			"envoy.filters.http.lua": {
+				Fields: map[string]*_struct.Value{
+					"cache_policy": CacheMetadata(route.CachePolicy).
+						FilterMetadata["envoy.filters.http.lua"].Fields["cache_policy"],
+					"cookie_rewrite_policies": CookieRewriteMetadata(route.CookieRewritePolicies).
+						FilterMetadata["envoy.filters.http.lua"].Fields["cookie_rewrite_policies"],
+					"header_remove_matching": HeaderRemoveMetadata(route).
+						FilterMetadata["envoy.filters.http.lua"].Fields["header_remove_matching"],
+				},
+			},
+		},
+	}
+	protobuf.ExpectEqual(t, want, got)
+}
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
@@ -33,31 +34,73 @@ func httpHealthCheck(cluster *dag.Cluster) *envoy_core_v3.HealthCheck {
 
 	// TODO(dfc) why do we need to specify our own default, what is the default
 	// that envoy applies if these fields are left nil?
-	return &envoy_core_v3.HealthCheck{
+	check := &envoy_core_v3.HealthCheck{
 		Timeout:            durationOrDefault(hc.Timeout, envoy.HCTimeout),
 		Interval:           durationOrDefault(hc.Interval, envoy.HCInterval),
 		UnhealthyThreshold: protobuf.UInt32OrDefault(hc.UnhealthyThreshold, envoy.HCUnhealthyThreshold),
 		HealthyThreshold:   protobuf.UInt32OrDefault(hc.HealthyThreshold, envoy.HCHealthyThreshold),
 		HealthChecker: &envoy_core_v3.HealthCheck_HttpHealthCheck_{
 			HttpHealthCheck: &envoy_core_v3.HealthCheck_HttpHealthCheck{
-				Path: hc.Path,
-				Host: host,
+				Path:                hc.Path,
+				Host:                host,
+				ExpectedStatuses:    expectedStatuses(hc.ExpectedStatuses),
+				RequestHeadersToAdd: HeaderValueList(hc.RequestHeaders, false),
 			},
 		},
 	}
+
+	if g := hc.GRPC; g != nil {
+		check.HealthChecker = &envoy_core_v3.HealthCheck_GrpcHealthCheck_{
+			GrpcHealthCheck: &envoy_core_v3.HealthCheck_GrpcHealthCheck{
+				ServiceName: g.ServiceName,
+				Authority:   g.Authority,
+			},
+		}
+	}
+
+	return check
 }
 
 // tcpHealthCheck returns a *envoy_core_v3.HealthCheck value for TCPProxies
 func tcpHealthCheck(cluster *dag.Cluster) *envoy_core_v3.HealthCheck {
 	hc := cluster.TCPHealthCheckPolicy
 
+	tcp := &envoy_core_v3.HealthCheck_TcpHealthCheck{}
+	if hc.Send != "" {
+		tcp.Send = textPayload(hc.Send)
+	}
+	for _, r := range hc.Receive {
+		tcp.Receive = append(tcp.Receive, textPayload(r))
+	}
+
 	return &envoy_core_v3.HealthCheck{
 		Timeout:            durationOrDefault(hc.Timeout, envoy.HCTimeout),
 		Interval:           durationOrDefault(hc.Interval, envoy.HCInterval),
 		UnhealthyThreshold: protobuf.UInt32OrDefault(hc.UnhealthyThreshold, envoy.HCUnhealthyThreshold),
 		HealthyThreshold:   protobuf.UInt32OrDefault(hc.HealthyThreshold, envoy.HCHealthyThreshold),
 		HealthChecker: &envoy_core_v3.HealthCheck_TcpHealthCheck_{
-			TcpHealthCheck: &envoy_core_v3.HealthCheck_TcpHealthCheck{},
+			TcpHealthCheck: tcp,
+		},
+	}
+}
+
+// expectedStatuses returns the Envoy ranges of the given status ranges.
+func expectedStatuses(ranges []dag.HTTPStatusRange) []*envoy_type.Int64Range {
+	var statuses []*envoy_type.Int64Range
+	for _, r := range ranges {
+		statuses = append(statuses, &envoy_type.Int64Range{
+			Start: r.Start,
+			End:   r.End,
+		})
+	}
+	return statuses
+}
+
+// textPayload returns a health check payload of hex encoded bytes.
+func textPayload(hex string) *envoy_core_v3.HealthCheck_Payload {
+	return &envoy_core_v3.HealthCheck_Payload{
+		Payload: &envoy_core_v3.HealthCheck_Payload_Text{
+			Text: hex,
 		},
 	}
 }
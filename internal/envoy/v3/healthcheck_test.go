@@ -18,6 +18,7 @@ import (
 	"time"
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -90,6 +91,63 @@ func TestHealthCheck(t *testing.T) {
 				},
 			},
 		},
+		"healthcheck with expected statuses and request headers": {
+			cluster: &dag.Cluster{
+				HTTPHealthCheckPolicy: &dag.HTTPHealthCheckPolicy{
+					Path: "/healthy",
+					ExpectedStatuses: []dag.HTTPStatusRange{
+						{Start: 200, End: 300},
+					},
+					RequestHeaders: map[string]string{
+						"X-Health-Token": "s3cr3t",
+					},
+				},
+			},
+			want: &envoy_core_v3.HealthCheck{
+				Timeout:            protobuf.Duration(envoy.HCTimeout),
+				Interval:           protobuf.Duration(envoy.HCInterval),
+				UnhealthyThreshold: protobuf.UInt32(3),
+				HealthyThreshold:   protobuf.UInt32(2),
+				HealthChecker: &envoy_core_v3.HealthCheck_HttpHealthCheck_{
+					HttpHealthCheck: &envoy_core_v3.HealthCheck_HttpHealthCheck{
+						Path: "/healthy",
+						Host: "contour-envoy-healthcheck",
+						ExpectedStatuses: []*envoy_type.Int64Range{
+							{Start: 200, End: 300},
+						},
+						RequestHeadersToAdd: []*envoy_core_v3.HeaderValueOption{{
+							Header: &envoy_core_v3.HeaderValue{
+								Key:   "X-Health-Token",
+								Value: "s3cr3t",
+							},
+							Append: protobuf.Bool(false),
+						}},
+					},
+				},
+			},
+		},
+		"grpc healthcheck": {
+			cluster: &dag.Cluster{
+				HTTPHealthCheckPolicy: &dag.HTTPHealthCheckPolicy{
+					GRPC: &dag.GRPCHealthCheckPolicy{
+						ServiceName: "helloworld.Greeter",
+						Authority:   "greeter.example.com",
+					},
+				},
+			},
+			want: &envoy_core_v3.HealthCheck{
+				Timeout:            protobuf.Duration(envoy.HCTimeout),
+				Interval:           protobuf.Duration(envoy.HCInterval),
+				UnhealthyThreshold: protobuf.UInt32(3),
+				HealthyThreshold:   protobuf.UInt32(2),
+				HealthChecker: &envoy_core_v3.HealthCheck_GrpcHealthCheck_{
+					GrpcHealthCheck: &envoy_core_v3.HealthCheck_GrpcHealthCheck{
+						ServiceName: "helloworld.Greeter",
+						Authority:   "greeter.example.com",
+					},
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
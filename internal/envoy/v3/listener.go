@@ -25,9 +25,11 @@ import (
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	envoy_compressor_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	envoy_config_filter_http_dynamic_forward_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/dynamic_forward_proxy/v3"
 	envoy_config_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
 	envoy_config_filter_http_local_ratelimit_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
 	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	envoy_config_filter_http_on_demand_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/on_demand/v3"
 	envoy_extensions_filters_http_router_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
 	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
@@ -153,6 +155,12 @@ type httpConnectionManagerBuilder struct {
 	codec                         HTTPVersionType // Note the zero value is AUTO, which is the default we want.
 	allowChunkedLength            bool
 	numTrustedHops                uint32
+	skipXffAppend                 bool
+	replaceExternalRequestID      bool
+	acceptHTTP10                  bool
+	defaultHostForHTTP10          string
+	tracing                       *http.HttpConnectionManager_Tracing
+	deltaRDS                      bool
 }
 
 // RouteConfigName sets the name of the RDS element that contains
@@ -230,6 +238,42 @@ func (b *httpConnectionManagerBuilder) NumTrustedHops(num uint32) *httpConnectio
 	return b
 }
 
+// SkipXffAppend disables appending the client address to the
+// X-Forwarded-For header.
+func (b *httpConnectionManagerBuilder) SkipXffAppend(skip bool) *httpConnectionManagerBuilder {
+	b.skipXffAppend = skip
+	return b
+}
+
+// ReplaceExternalRequestID replaces the x-request-id header of requests
+// from external clients with a generated ID, instead of preserving it.
+func (b *httpConnectionManagerBuilder) ReplaceExternalRequestID(replace bool) *httpConnectionManagerBuilder {
+	b.replaceExternalRequestID = replace
+	return b
+}
+
+// AcceptHTTP10 enables support for HTTP/1.0 requests. If defaultHost
+// is not empty, it is used as the Host header for HTTP/1.0 requests
+// that do not supply one.
+func (b *httpConnectionManagerBuilder) AcceptHTTP10(enabled bool, defaultHost string) *httpConnectionManagerBuilder {
+	b.acceptHTTP10 = enabled
+	b.defaultHostForHTTP10 = defaultHost
+	return b
+}
+
+// Tracing sets the request tracing configuration.
+func (b *httpConnectionManagerBuilder) Tracing(tracing *http.HttpConnectionManager_Tracing) *httpConnectionManagerBuilder {
+	b.tracing = tracing
+	return b
+}
+
+// DeltaRDS requests the routing table of this manager with the
+// incremental (delta) variant of the xDS protocol.
+func (b *httpConnectionManagerBuilder) DeltaRDS(enabled bool) *httpConnectionManagerBuilder {
+	b.deltaRDS = enabled
+	return b
+}
+
 func (b *httpConnectionManagerBuilder) DefaultFilters() *httpConnectionManagerBuilder {
 
 	// Add a default set of ordered http filters.
@@ -277,6 +321,11 @@ func (b *httpConnectionManagerBuilder) DefaultFilters() *httpConnectionManagerBu
 				),
 			},
 		},
+		FilterLua(),
+		FilterRBAC(),
+		FilterCacheSelect(),
+		FilterCache(),
+		FilterCacheStore(),
 		&http.HttpFilter{
 			Name: "router",
 			ConfigType: &http.HttpFilter_TypedConfig{
@@ -295,7 +344,9 @@ func (b *httpConnectionManagerBuilder) DefaultFilters() *httpConnectionManagerBu
 // (filters with TypeUrl `type.googleapis.com/envoy.extensions.filters.http.router.v3.Router`)
 // are specially treated. There may only be one of these filters, and it must be the last.
 // AddFilter will ensure that the router filter, if present, is last, and will panic
-// if a second Router is added when one is already present.
+// if a second Router is added when one is already present. Likewise, f is added
+// before the RBAC and cache filters, so that RBAC policies see the headers that f
+// sets, and only the requests that pass f are served from the cache.
 func (b *httpConnectionManagerBuilder) AddFilter(f *http.HttpFilter) *httpConnectionManagerBuilder {
 	if f == nil {
 		return b
@@ -320,15 +371,24 @@ func (b *httpConnectionManagerBuilder) AddFilter(f *http.HttpFilter) *httpConnec
 	// If this happens, it has to be programmer error, so we panic to tell them
 	// it needs to be fixed. Note that in hitting this case, it doesn't matter we added
 	// the second one earlier, because we're panicking anyway.
-	if routerIndex != -1 && f.GetTypedConfig().MessageIs(&envoy_extensions_filters_http_router_v3.Router{}) {
+	if routerIndex != lastIndex && f.GetTypedConfig().MessageIs(&envoy_extensions_filters_http_router_v3.Router{}) {
 		panic("Can't add more than one router to a filter chain")
 	}
+	if routerIndex == lastIndex {
+		return b
+	}
 
-	if routerIndex != lastIndex {
-		// Move the router to the end of the filters array.
-		routerFilter := b.filters[routerIndex]
-		b.filters = append(b.filters[:routerIndex], b.filters[routerIndex+1])
-		b.filters = append(b.filters, routerFilter)
+	// Move f in front of the RBAC and cache filters and the router.
+	tailIndex := lastIndex
+	for i, filter := range b.filters[:lastIndex] {
+		if i == routerIndex || filter.Name == "envoy.filters.http.rbac" || isCacheFilter(filter) {
+			tailIndex = i
+			break
+		}
+	}
+	if tailIndex != lastIndex {
+		copy(b.filters[tailIndex+1:], b.filters[tailIndex:lastIndex])
+		b.filters[tailIndex] = f
 	}
 
 	return b
@@ -367,12 +427,17 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_listener_v3.Filter {
 		panic(err.Error())
 	}
 
+	rdsConfig := ConfigSource("contour")
+	if b.deltaRDS {
+		rdsConfig = DeltaConfigSource("contour")
+	}
+
 	cm := &http.HttpConnectionManager{
 		CodecType: b.codec,
 		RouteSpecifier: &http.HttpConnectionManager_Rds{
 			Rds: &http.Rds{
 				RouteConfigName: b.routeConfigName,
-				ConfigSource:    ConfigSource("contour"),
+				ConfigSource:    rdsConfig,
 			},
 		},
 		HttpFilters: b.filters,
@@ -380,10 +445,9 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_listener_v3.Filter {
 			IdleTimeout: envoy.Timeout(b.connectionIdleTimeout),
 		},
 		HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-			// Enable support for HTTP/1.0 requests that carry
-			// a Host: header. See #537.
-			AcceptHttp_10:      true,
-			AllowChunkedLength: b.allowChunkedLength,
+			AcceptHttp_10:         b.acceptHTTP10,
+			DefaultHostForHttp_10: b.defaultHostForHTTP10,
+			AllowChunkedLength:    b.allowChunkedLength,
 		},
 		UseRemoteAddress: protobuf.Bool(true),
 		NormalizePath:    protobuf.Bool(true),
@@ -397,7 +461,7 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_listener_v3.Filter {
 		},
 
 		// issue #1487 pass through X-Request-Id if provided.
-		PreserveExternalRequestId: true,
+		PreserveExternalRequestId: !b.replaceExternalRequestID,
 		MergeSlashes:              true,
 
 		RequestTimeout:      envoy.Timeout(b.requestTimeout),
@@ -405,6 +469,8 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_listener_v3.Filter {
 		DrainTimeout:        envoy.Timeout(b.connectionShutdownGracePeriod),
 		DelayedCloseTimeout: envoy.Timeout(b.delayedCloseTimeout),
 		XffNumTrustedHops:   b.numTrustedHops,
+		SkipXffAppend:       b.skipXffAppend,
+		Tracing:             b.tracing,
 	}
 
 	// Max connection duration is infinite/disabled by default in Envoy, so if the timeout setting
@@ -461,6 +527,10 @@ func TCPProxy(statPrefix string, proxy *dag.TCPProxy, accesslogger []*accesslog.
 	// https://github.com/projectcontour/contour/issues/1074
 	// Set to 9001 because now it's OVER NINE THOUSAND.
 	idleTimeout := protobuf.Duration(9001 * time.Second)
+	if !proxy.IdleTimeout.UseDefault() {
+		idleTimeout = envoy.Timeout(proxy.IdleTimeout)
+	}
+	maxConnectAttempts := protobuf.UInt32OrNil(proxy.MaxConnectAttempts)
 
 	switch len(proxy.Clusters) {
 	case 1:
@@ -472,8 +542,9 @@ func TCPProxy(statPrefix string, proxy *dag.TCPProxy, accesslogger []*accesslog.
 					ClusterSpecifier: &tcp.TcpProxy_Cluster{
 						Cluster: envoy.Clustername(proxy.Clusters[0]),
 					},
-					AccessLog:   accesslogger,
-					IdleTimeout: idleTimeout,
+					AccessLog:          accesslogger,
+					IdleTimeout:        idleTimeout,
+					MaxConnectAttempts: maxConnectAttempts,
 				}),
 			},
 		}
@@ -500,8 +571,9 @@ func TCPProxy(statPrefix string, proxy *dag.TCPProxy, accesslogger []*accesslog.
 							Clusters: clusters,
 						},
 					},
-					AccessLog:   accesslogger,
-					IdleTimeout: idleTimeout,
+					AccessLog:          accesslogger,
+					IdleTimeout:        idleTimeout,
+					MaxConnectAttempts: maxConnectAttempts,
 				}),
 			},
 		}
@@ -644,6 +716,34 @@ func FilterExternalAuthz(authzClusterName string, failOpen bool, timeout timeout
 }
 
 // FilterChainTLS returns a TLS enabled envoy_listener_v3.FilterChain.
+// FilterDynamicForwardProxy returns a `dynamic_forward_proxy` filter that
+// resolves the request's Host header for the dynamic forward proxy cluster.
+func FilterDynamicForwardProxy(c *dag.DynamicForwardProxyCluster) *http.HttpFilter {
+	if c == nil {
+		return nil
+	}
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.dynamic_forward_proxy",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_config_filter_http_dynamic_forward_proxy_v3.FilterConfig{
+				DnsCacheConfig: dynamicForwardProxyDNSCacheConfig(c.DNSLookupFamily),
+			}),
+		},
+	}
+}
+
+// FilterOnDemand returns an `on_demand` filter that fetches the virtual
+// host of a request from VHDS when Envoy does not yet have it.
+func FilterOnDemand() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.on_demand",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_config_filter_http_on_demand_v3.OnDemand{}),
+		},
+	}
+}
+
 func FilterChainTLS(domain string, downstream *envoy_tls_v3.DownstreamTlsContext, filters []*envoy_listener_v3.Filter) *envoy_listener_v3.FilterChain {
 	fc := &envoy_listener_v3.FilterChain{
 		Filters: filters,
@@ -385,6 +385,8 @@ func TestHTTPConnectionManager(t *testing.T) {
 		connectionShutdownGracePeriod timeout.Setting
 		allowChunkedLength            bool
 		xffNumTrustedHops             uint32
+		acceptHTTP10                  bool
+		defaultHostForHTTP10          string
 		want                          *envoy_listener_v3.Filter
 	}{
 		"default": {
@@ -451,7 +453,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -459,11 +461,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions:       &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
 						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
 						UseRemoteAddress:          protobuf.Bool(true),
@@ -542,7 +540,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -550,11 +548,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions:       &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
 						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
 						UseRemoteAddress:          protobuf.Bool(true),
@@ -634,7 +628,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -642,11 +636,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{
 							IdleTimeout: protobuf.Duration(90 * time.Second),
 						},
@@ -727,7 +717,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -735,11 +725,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions:       &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
 						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
 						UseRemoteAddress:          protobuf.Bool(true),
@@ -819,7 +805,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -827,11 +813,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{
 							MaxConnectionDuration: protobuf.Duration(90 * time.Second),
 						},
@@ -912,7 +894,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -920,11 +902,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions:       &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
 						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
 						UseRemoteAddress:          protobuf.Bool(true),
@@ -1003,7 +981,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -1011,11 +989,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions:       &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
 						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
 						UseRemoteAddress:          protobuf.Bool(true),
@@ -1095,7 +1069,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -1103,11 +1077,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 								},
 							},
 						}},
-						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
-						},
+						HttpProtocolOptions:       &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
 						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
 						UseRemoteAddress:          protobuf.Bool(true),
@@ -1188,7 +1158,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -1197,9 +1167,6 @@ func TestHTTPConnectionManager(t *testing.T) {
 							},
 						}},
 						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10:      true,
 							AllowChunkedLength: true,
 						},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
@@ -1216,11 +1183,12 @@ func TestHTTPConnectionManager(t *testing.T) {
 				},
 			},
 		},
-		"enable XffNumTrustedHops": {
+		"accept HTTP/1.0 with default host": {
 			routename:                     "default/kuard",
 			accesslogger:                  FileAccessLogEnvoy("/dev/stdout", "", nil),
 			connectionShutdownGracePeriod: timeout.DurationSetting(90 * time.Second),
-			xffNumTrustedHops:             1,
+			acceptHTTP10:                  true,
+			defaultHostForHTTP10:          "legacy.example.com",
 			want: &envoy_listener_v3.Filter{
 				Name: wellknown.HTTPConnectionManager,
 				ConfigType: &envoy_listener_v3.Filter_TypedConfig{
@@ -1282,7 +1250,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 									},
 								),
 							},
-						}, {
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
 							Name: "router",
 							ConfigType: &http.HttpFilter_TypedConfig{
 								TypedConfig: &any.Any{
@@ -1291,10 +1259,98 @@ func TestHTTPConnectionManager(t *testing.T) {
 							},
 						}},
 						HttpProtocolOptions: &envoy_core_v3.Http1ProtocolOptions{
-							// Enable support for HTTP/1.0 requests that carry
-							// a Host: header. See #537.
-							AcceptHttp_10: true,
+							AcceptHttp_10:         true,
+							DefaultHostForHttp_10: "legacy.example.com",
+						},
+						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
+						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
+						UseRemoteAddress:          protobuf.Bool(true),
+						NormalizePath:             protobuf.Bool(true),
+						StripPortMode: &http.HttpConnectionManager_StripAnyHostPort{
+							StripAnyHostPort: true,
+						},
+						PreserveExternalRequestId: true,
+						MergeSlashes:              true,
+						DrainTimeout:              protobuf.Duration(90 * time.Second),
+					}),
+				},
+			},
+		},
+		"enable XffNumTrustedHops": {
+			routename:                     "default/kuard",
+			accesslogger:                  FileAccessLogEnvoy("/dev/stdout", "", nil),
+			connectionShutdownGracePeriod: timeout.DurationSetting(90 * time.Second),
+			xffNumTrustedHops:             1,
+			want: &envoy_listener_v3.Filter{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &envoy_listener_v3.Filter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&http.HttpConnectionManager{
+						StatPrefix: "default/kuard",
+						RouteSpecifier: &http.HttpConnectionManager_Rds{
+							Rds: &http.Rds{
+								RouteConfigName: "default/kuard",
+								ConfigSource: &envoy_core_v3.ConfigSource{
+									ResourceApiVersion: envoy_core_v3.ApiVersion_V3,
+									ConfigSourceSpecifier: &envoy_core_v3.ConfigSource_ApiConfigSource{
+										ApiConfigSource: &envoy_core_v3.ApiConfigSource{
+											ApiType:             envoy_core_v3.ApiConfigSource_GRPC,
+											TransportApiVersion: envoy_core_v3.ApiVersion_V3,
+											GrpcServices: []*envoy_core_v3.GrpcService{{
+												TargetSpecifier: &envoy_core_v3.GrpcService_EnvoyGrpc_{
+													EnvoyGrpc: &envoy_core_v3.GrpcService_EnvoyGrpc{
+														ClusterName: "contour",
+													},
+												},
+											}},
+										},
+									},
+								},
+							},
 						},
+						HttpFilters: []*http.HttpFilter{{
+							Name: "compressor",
+							ConfigType: &http.HttpFilter_TypedConfig{
+								TypedConfig: protobuf.MustMarshalAny(&envoy_compressor_v3.Compressor{
+									CompressorLibrary: &envoy_core_v3.TypedExtensionConfig{
+										Name: "gzip",
+										TypedConfig: &any.Any{
+											TypeUrl: HTTPFilterGzip,
+										},
+									},
+								}),
+							},
+						}, {
+							Name: "grpcweb",
+							ConfigType: &http.HttpFilter_TypedConfig{
+								TypedConfig: &any.Any{
+									TypeUrl: HTTPFilterGrpcWeb,
+								},
+							},
+						}, {
+							Name: "cors",
+							ConfigType: &http.HttpFilter_TypedConfig{
+								TypedConfig: &any.Any{
+									TypeUrl: HTTPFilterCORS,
+								},
+							},
+						}, {
+							Name: "local_ratelimit",
+							ConfigType: &http.HttpFilter_TypedConfig{
+								TypedConfig: protobuf.MustMarshalAny(
+									&envoy_config_filter_http_local_ratelimit_v3.LocalRateLimit{
+										StatPrefix: "http",
+									},
+								),
+							},
+						}, FilterLua(), FilterRBAC(), FilterCacheSelect(), FilterCache(), FilterCacheStore(), {
+							Name: "router",
+							ConfigType: &http.HttpFilter_TypedConfig{
+								TypedConfig: &any.Any{
+									TypeUrl: HTTPFilterRouter,
+								},
+							},
+						}},
+						HttpProtocolOptions:       &envoy_core_v3.Http1ProtocolOptions{},
 						CommonHttpProtocolOptions: &envoy_core_v3.HttpProtocolOptions{},
 						AccessLog:                 FileAccessLogEnvoy("/dev/stdout", "", nil),
 						UseRemoteAddress:          protobuf.Bool(true),
@@ -1325,6 +1381,7 @@ func TestHTTPConnectionManager(t *testing.T) {
 				ConnectionShutdownGracePeriod(tc.connectionShutdownGracePeriod).
 				AllowChunkedLength(tc.allowChunkedLength).
 				NumTrustedHops(tc.xffNumTrustedHops).
+				AcceptHTTP10(tc.acceptHTTP10, tc.defaultHostForHTTP10).
 				DefaultFilters().
 				Get()
 
@@ -1416,6 +1473,46 @@ func TestTCPProxy(t *testing.T) {
 				},
 			},
 		},
+		"idle timeout and max connect attempts": {
+			proxy: &dag.TCPProxy{
+				Clusters:           []*dag.Cluster{c1},
+				IdleTimeout:        timeout.DurationSetting(time.Hour),
+				MaxConnectAttempts: 3,
+			},
+			want: &envoy_listener_v3.Filter{
+				Name: wellknown.TCPProxy,
+				ConfigType: &envoy_listener_v3.Filter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&envoy_tcp_proxy_v3.TcpProxy{
+						StatPrefix: statPrefix,
+						ClusterSpecifier: &envoy_tcp_proxy_v3.TcpProxy_Cluster{
+							Cluster: envoy.Clustername(c1),
+						},
+						AccessLog:          FileAccessLogEnvoy(accessLogPath, "", nil),
+						IdleTimeout:        protobuf.Duration(time.Hour),
+						MaxConnectAttempts: protobuf.UInt32(3),
+					}),
+				},
+			},
+		},
+		"idle timeout disabled": {
+			proxy: &dag.TCPProxy{
+				Clusters:    []*dag.Cluster{c1},
+				IdleTimeout: timeout.DisabledSetting(),
+			},
+			want: &envoy_listener_v3.Filter{
+				Name: wellknown.TCPProxy,
+				ConfigType: &envoy_listener_v3.Filter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&envoy_tcp_proxy_v3.TcpProxy{
+						StatPrefix: statPrefix,
+						ClusterSpecifier: &envoy_tcp_proxy_v3.TcpProxy_Cluster{
+							Cluster: envoy.Clustername(c1),
+						},
+						AccessLog:   FileAccessLogEnvoy(accessLogPath, "", nil),
+						IdleTimeout: protobuf.Duration(0),
+					}),
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -1625,7 +1722,12 @@ func TestAddFilter(t *testing.T) {
 						),
 					},
 				},
+				FilterLua(),
 				FilterExternalAuthz("test", false, timeout.Setting{}),
+				FilterRBAC(),
+				FilterCacheSelect(),
+				FilterCache(),
+				FilterCacheStore(),
 				{
 					Name: "router",
 					ConfigType: &http.HttpFilter_TypedConfig{
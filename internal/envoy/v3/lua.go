@@ -0,0 +1,52 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// FilterLua returns the Lua filter that runs the Lua scripts of virtual
+// hosts and routes. The filter does nothing by itself, the scripts are
+// configured per virtual host or route with LuaPerRoute.
+func FilterLua() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.lua",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				// The Lua filter requires some code, so this is
+				// a comment that leaves requests alone.
+				InlineCode: "-- Lua scripts are configured per virtual host or route.",
+			}),
+		},
+	}
+}
+
+// LuaPerRoute returns the per virtual host or route configuration of
+// the Lua filter that replaces its code with the given Lua script.
+func LuaPerRoute(code string) *any.Any {
+	return protobuf.MustMarshalAny(&lua.LuaPerRoute{
+		Override: &lua.LuaPerRoute_SourceCode{
+			SourceCode: &envoy_core_v3.DataSource{
+				Specifier: &envoy_core_v3.DataSource_InlineString{
+					InlineString: code,
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,183 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"fmt"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// FilterRBAC returns the RBAC filter, which allows or denies requests
+// by their client and what they ask for. The filter has no rules of
+// its own, so it allows all requests, except on the routes that
+// configure it with RBACPerRoute.
+func FilterRBAC() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.rbac",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_rbac_v3.RBAC{}),
+		},
+	}
+}
+
+// RBACPerRoute returns the per-route configuration of the RBAC
+// filter for the given RBAC policy.
+func RBACPerRoute(policy *dag.RBACPolicy) *any.Any {
+	action := envoy_config_rbac_v3.RBAC_ALLOW
+	if policy.Deny {
+		action = envoy_config_rbac_v3.RBAC_DENY
+	}
+
+	policies := map[string]*envoy_config_rbac_v3.Policy{}
+	for i, rule := range policy.Rules {
+		policies[fmt.Sprintf("rule-%d", i)] = &envoy_config_rbac_v3.Policy{
+			Permissions: rbacPermissions(rule.Permissions),
+			Principals:  rbacPrincipals(rule.Principals),
+		}
+	}
+
+	return protobuf.MustMarshalAny(&envoy_rbac_v3.RBACPerRoute{
+		Rbac: &envoy_rbac_v3.RBAC{
+			Rules: &envoy_config_rbac_v3.RBAC{
+				Action:   action,
+				Policies: policies,
+			},
+		},
+	})
+}
+
+// rbacPermissions returns the Envoy permissions that match any of the
+// supplied permissions. Envoy requires at least one permission, so no
+// permissions match any request.
+func rbacPermissions(permissions []dag.RBACPermission) []*envoy_config_rbac_v3.Permission {
+	if len(permissions) == 0 {
+		return []*envoy_config_rbac_v3.Permission{{
+			Rule: &envoy_config_rbac_v3.Permission_Any{Any: true},
+		}}
+	}
+
+	var perms []*envoy_config_rbac_v3.Permission
+	for _, p := range permissions {
+		var rules []*envoy_config_rbac_v3.Permission
+
+		if len(p.Methods) > 0 {
+			var methods []*envoy_config_rbac_v3.Permission
+			for _, m := range p.Methods {
+				methods = append(methods, &envoy_config_rbac_v3.Permission{
+					Rule: &envoy_config_rbac_v3.Permission_Header{
+						Header: &envoy_route_v3.HeaderMatcher{
+							Name: ":method",
+							HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{
+								ExactMatch: m,
+							},
+						},
+					},
+				})
+			}
+			rules = append(rules, &envoy_config_rbac_v3.Permission{
+				Rule: &envoy_config_rbac_v3.Permission_OrRules{
+					OrRules: &envoy_config_rbac_v3.Permission_Set{Rules: methods},
+				},
+			})
+		}
+
+		if p.PathPrefix != "" {
+			rules = append(rules, &envoy_config_rbac_v3.Permission{
+				Rule: &envoy_config_rbac_v3.Permission_UrlPath{
+					UrlPath: &matcher.PathMatcher{
+						Rule: &matcher.PathMatcher_Path{
+							Path: &matcher.StringMatcher{
+								MatchPattern: &matcher.StringMatcher_Prefix{
+									Prefix: p.PathPrefix,
+								},
+							},
+						},
+					},
+				},
+			})
+		}
+
+		switch len(rules) {
+		case 0:
+			perms = append(perms, &envoy_config_rbac_v3.Permission{
+				Rule: &envoy_config_rbac_v3.Permission_Any{Any: true},
+			})
+		case 1:
+			perms = append(perms, rules[0])
+		default:
+			perms = append(perms, &envoy_config_rbac_v3.Permission{
+				Rule: &envoy_config_rbac_v3.Permission_AndRules{
+					AndRules: &envoy_config_rbac_v3.Permission_Set{Rules: rules},
+				},
+			})
+		}
+	}
+	return perms
+}
+
+// rbacPrincipals returns the Envoy principals that match any of the
+// supplied principals. Envoy requires at least one principal, so no
+// principals match any client.
+func rbacPrincipals(principals []dag.RBACPrincipal) []*envoy_config_rbac_v3.Principal {
+	if len(principals) == 0 {
+		return []*envoy_config_rbac_v3.Principal{{
+			Identifier: &envoy_config_rbac_v3.Principal_Any{Any: true},
+		}}
+	}
+
+	var ids []*envoy_config_rbac_v3.Principal
+	for _, p := range principals {
+		switch {
+		case p.SubjectAltName != "":
+			ids = append(ids, &envoy_config_rbac_v3.Principal{
+				Identifier: &envoy_config_rbac_v3.Principal_Authenticated_{
+					Authenticated: &envoy_config_rbac_v3.Principal_Authenticated{
+						PrincipalName: &matcher.StringMatcher{
+							MatchPattern: &matcher.StringMatcher_Exact{
+								Exact: p.SubjectAltName,
+							},
+						},
+					},
+				},
+			})
+		case p.SourceCIDR != nil:
+			ones, _ := p.SourceCIDR.Mask.Size()
+			ids = append(ids, &envoy_config_rbac_v3.Principal{
+				Identifier: &envoy_config_rbac_v3.Principal_RemoteIp{
+					RemoteIp: &envoy_core_v3.CidrRange{
+						AddressPrefix: p.SourceCIDR.IP.String(),
+						PrefixLen:     &wrappers.UInt32Value{Value: uint32(ones)},
+					},
+				},
+			})
+		case p.Header != nil:
+			ids = append(ids, &envoy_config_rbac_v3.Principal{
+				Identifier: &envoy_config_rbac_v3.Principal_Header{
+					Header: headerMatcher([]dag.HeaderMatchCondition{*p.Header})[0],
+				},
+			})
+		}
+	}
+	return ids
+}
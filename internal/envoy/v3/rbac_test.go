@@ -0,0 +1,179 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"net"
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestRBACPerRoute(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/8")
+
+	anyPermission := &envoy_config_rbac_v3.Permission{
+		Rule: &envoy_config_rbac_v3.Permission_Any{Any: true},
+	}
+	anyPrincipal := &envoy_config_rbac_v3.Principal{
+		Identifier: &envoy_config_rbac_v3.Principal_Any{Any: true},
+	}
+	method := func(m string) *envoy_config_rbac_v3.Permission {
+		return &envoy_config_rbac_v3.Permission{
+			Rule: &envoy_config_rbac_v3.Permission_Header{
+				Header: &envoy_route_v3.HeaderMatcher{
+					Name: ":method",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{
+						ExactMatch: m,
+					},
+				},
+			},
+		}
+	}
+	pathPrefix := &envoy_config_rbac_v3.Permission{
+		Rule: &envoy_config_rbac_v3.Permission_UrlPath{
+			UrlPath: &matcher.PathMatcher{
+				Rule: &matcher.PathMatcher_Path{
+					Path: &matcher.StringMatcher{
+						MatchPattern: &matcher.StringMatcher_Prefix{
+							Prefix: "/admin",
+						},
+					},
+				},
+			},
+		},
+	}
+	rbac := func(action envoy_config_rbac_v3.RBAC_Action, policies map[string]*envoy_config_rbac_v3.Policy) *any.Any {
+		return protobuf.MustMarshalAny(&envoy_rbac_v3.RBACPerRoute{
+			Rbac: &envoy_rbac_v3.RBAC{
+				Rules: &envoy_config_rbac_v3.RBAC{
+					Action:   action,
+					Policies: policies,
+				},
+			},
+		})
+	}
+
+	tests := map[string]struct {
+		policy *dag.RBACPolicy
+		want   *any.Any
+	}{
+		"empty rule": {
+			policy: &dag.RBACPolicy{
+				Deny:  true,
+				Rules: []dag.RBACRule{{}},
+			},
+			want: rbac(envoy_config_rbac_v3.RBAC_DENY, map[string]*envoy_config_rbac_v3.Policy{
+				"rule-0": {
+					Permissions: []*envoy_config_rbac_v3.Permission{anyPermission},
+					Principals:  []*envoy_config_rbac_v3.Principal{anyPrincipal},
+				},
+			}),
+		},
+		"principals and permissions": {
+			policy: &dag.RBACPolicy{
+				Rules: []dag.RBACRule{{
+					Principals: []dag.RBACPrincipal{{
+						SubjectAltName: "client.example.com",
+					}, {
+						SourceCIDR: cidr,
+					}, {
+						Header: &dag.HeaderMatchCondition{
+							Name:      "x-user-role",
+							MatchType: dag.HeaderMatchTypePresent,
+						},
+					}},
+					Permissions: []dag.RBACPermission{{
+						Methods:    []string{"GET", "HEAD"},
+						PathPrefix: "/admin",
+					}, {
+						Methods: []string{"POST"},
+					}, {}},
+				}, {
+					Permissions: []dag.RBACPermission{{
+						PathPrefix: "/admin",
+					}},
+				}},
+			},
+			want: rbac(envoy_config_rbac_v3.RBAC_ALLOW, map[string]*envoy_config_rbac_v3.Policy{
+				"rule-0": {
+					Permissions: []*envoy_config_rbac_v3.Permission{{
+						Rule: &envoy_config_rbac_v3.Permission_AndRules{
+							AndRules: &envoy_config_rbac_v3.Permission_Set{
+								Rules: []*envoy_config_rbac_v3.Permission{{
+									Rule: &envoy_config_rbac_v3.Permission_OrRules{
+										OrRules: &envoy_config_rbac_v3.Permission_Set{
+											Rules: []*envoy_config_rbac_v3.Permission{method("GET"), method("HEAD")},
+										},
+									},
+								}, pathPrefix},
+							},
+						},
+					}, {
+						Rule: &envoy_config_rbac_v3.Permission_OrRules{
+							OrRules: &envoy_config_rbac_v3.Permission_Set{
+								Rules: []*envoy_config_rbac_v3.Permission{method("POST")},
+							},
+						},
+					}, anyPermission},
+					Principals: []*envoy_config_rbac_v3.Principal{{
+						Identifier: &envoy_config_rbac_v3.Principal_Authenticated_{
+							Authenticated: &envoy_config_rbac_v3.Principal_Authenticated{
+								PrincipalName: &matcher.StringMatcher{
+									MatchPattern: &matcher.StringMatcher_Exact{
+										Exact: "client.example.com",
+									},
+								},
+							},
+						},
+					}, {
+						Identifier: &envoy_config_rbac_v3.Principal_RemoteIp{
+							RemoteIp: &envoy_core_v3.CidrRange{
+								AddressPrefix: "10.0.0.0",
+								PrefixLen:     &wrappers.UInt32Value{Value: 8},
+							},
+						},
+					}, {
+						Identifier: &envoy_config_rbac_v3.Principal_Header{
+							Header: &envoy_route_v3.HeaderMatcher{
+								Name: "x-user-role",
+								HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PresentMatch{
+									PresentMatch: true,
+								},
+							},
+						},
+					}},
+				},
+				"rule-1": {
+					Permissions: []*envoy_config_rbac_v3.Permission{pathPrefix},
+					Principals:  []*envoy_config_rbac_v3.Principal{anyPrincipal},
+				},
+			}),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, RBACPerRoute(tc.policy))
+		})
+	}
+}
@@ -15,14 +15,17 @@ package v3
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_config_filter_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/ptypes/any"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/projectcontour/contour/internal/dag"
@@ -62,6 +65,14 @@ var _ = regexp.MustCompile(prefixPathMatchSegmentRegex)
 
 // RouteMatch creates a *envoy_route_v3.RouteMatch for the supplied *dag.Route.
 func RouteMatch(route *dag.Route) *envoy_route_v3.RouteMatch {
+	match := pathRouteMatch(route)
+	if route.GRPC {
+		match.Grpc = &envoy_route_v3.RouteMatch_GrpcRouteMatchOptions{}
+	}
+	return match
+}
+
+func pathRouteMatch(route *dag.Route) *envoy_route_v3.RouteMatch {
 	switch c := route.PathMatchCondition.(type) {
 	case *dag.RegexMatchCondition:
 		return &envoy_route_v3.RouteMatch{
@@ -103,6 +114,126 @@ func RouteMatch(route *dag.Route) *envoy_route_v3.RouteMatch {
 	}
 }
 
+// RequestIDFromHeader returns the request headers that set the
+// x-request-id of requests to the value of the supplied header. Envoy
+// does not add headers whose value is empty, so requests without the
+// header keep their x-request-id.
+func RequestIDFromHeader(header string) []*envoy_core_v3.HeaderValueOption {
+	return HeaderValueList(map[string]string{
+		"x-request-id": "%REQ(" + header + ")%",
+	}, false)
+}
+
+// ClientCertificateHeaders returns the request headers that set the
+// supplied headers to fields of the client certificate. Envoy does not
+// add headers whose value is empty, so the headers must also be removed
+// from requests, to stop clients without a certificate setting them.
+func ClientCertificateHeaders(headers map[string]string) []*envoy_core_v3.HeaderValueOption {
+	values := make(map[string]string, len(headers))
+	for name, field := range headers {
+		switch field {
+		case "SubjectAltName":
+			values[name] = "%DOWNSTREAM_PEER_URI_SAN%"
+		case "Subject":
+			values[name] = "%DOWNSTREAM_PEER_SUBJECT%"
+		case "Issuer":
+			values[name] = "%DOWNSTREAM_PEER_ISSUER%"
+		case "Serial":
+			values[name] = "%DOWNSTREAM_PEER_SERIAL%"
+		case "SHA256":
+			values[name] = "%DOWNSTREAM_PEER_FINGERPRINT_256%"
+		}
+	}
+	return HeaderValueList(values, false)
+}
+
+// VirtualClusters returns the Envoy virtual clusters that report request
+// statistics for the supplied routes of a virtual host. Routes that have a
+// StatsName are given their own virtual cluster, matched in route order.
+// If statsName is not empty, a final virtual cluster of that name collects
+// the statistics of all other requests to the virtual host.
+func VirtualClusters(statsName string, routes []*dag.Route) []*envoy_route_v3.VirtualCluster {
+	var vclusters []*envoy_route_v3.VirtualCluster
+
+	for _, route := range routes {
+		if route.StatsName == "" {
+			continue
+		}
+
+		var headers []*envoy_route_v3.HeaderMatcher
+		if path := pathHeaderMatcher(route.PathMatchCondition); path != nil {
+			headers = append(headers, path)
+		}
+		headers = append(headers, headerMatcher(route.HeaderMatchConditions)...)
+		if route.GRPC {
+			headers = append(headers, &envoy_route_v3.HeaderMatcher{
+				Name: "content-type",
+				HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+					PrefixMatch: "application/grpc",
+				},
+			})
+		}
+
+		vclusters = append(vclusters, &envoy_route_v3.VirtualCluster{
+			Name:    route.StatsName,
+			Headers: headers,
+		})
+	}
+
+	if statsName != "" {
+		vclusters = append(vclusters, &envoy_route_v3.VirtualCluster{
+			Name: statsName,
+			Headers: []*envoy_route_v3.HeaderMatcher{{
+				Name: ":path",
+				HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+					PrefixMatch: "/",
+				},
+			}},
+		})
+	}
+
+	return vclusters
+}
+
+// pathHeaderMatcher returns a :path HeaderMatcher equivalent to the
+// supplied path MatchCondition. Unlike route matching, the :path header
+// includes the query string, so exact and regex matches must allow one.
+func pathHeaderMatcher(cond dag.MatchCondition) *envoy_route_v3.HeaderMatcher {
+	const queryString = `(\?.*)?`
+
+	header := &envoy_route_v3.HeaderMatcher{
+		Name: ":path",
+	}
+
+	switch c := cond.(type) {
+	case *dag.RegexMatchCondition:
+		header.HeaderMatchSpecifier = &envoy_route_v3.HeaderMatcher_SafeRegexMatch{
+			SafeRegexMatch: SafeRegexMatch("(?:" + c.Regex + ")" + queryString),
+		}
+	case *dag.PrefixMatchCondition:
+		switch c.PrefixMatchType {
+		case dag.PrefixMatchSegment:
+			header.HeaderMatchSpecifier = &envoy_route_v3.HeaderMatcher_SafeRegexMatch{
+				SafeRegexMatch: SafeRegexMatch(regexp.QuoteMeta(c.Prefix) + `([/?].*)?`),
+			}
+		case dag.PrefixMatchString:
+			fallthrough
+		default:
+			header.HeaderMatchSpecifier = &envoy_route_v3.HeaderMatcher_PrefixMatch{
+				PrefixMatch: c.Prefix,
+			}
+		}
+	case *dag.ExactMatchCondition:
+		header.HeaderMatchSpecifier = &envoy_route_v3.HeaderMatcher_SafeRegexMatch{
+			SafeRegexMatch: SafeRegexMatch(regexp.QuoteMeta(c.Path) + queryString),
+		}
+	default:
+		return nil
+	}
+
+	return header
+}
+
 // Route_DirectResponse creates a *envoy_route_v3.Route_DirectResponse for the
 // http status code supplied. This allows a direct response to a route request
 // with an HTTP status code without needing to route to a specific cluster.
@@ -120,6 +251,7 @@ func RouteDirectResponse(response *dag.DirectResponse) *envoy_route_v3.Route_Dir
 func RouteRoute(r *dag.Route) *envoy_route_v3.Route_Route {
 	ra := envoy_route_v3.RouteAction{
 		RetryPolicy:           retryPolicy(r),
+		HedgePolicy:           hedgePolicy(r),
 		Timeout:               envoy.Timeout(r.TimeoutPolicy.ResponseTimeout),
 		IdleTimeout:           envoy.Timeout(r.TimeoutPolicy.IdleTimeout),
 		PrefixRewrite:         r.PrefixRewrite,
@@ -127,6 +259,12 @@ func RouteRoute(r *dag.Route) *envoy_route_v3.Route_Route {
 		RequestMirrorPolicies: mirrorPolicy(r),
 	}
 
+	if !r.GRPCTimeoutHeaderMax.UseDefault() {
+		ra.MaxStreamDuration = &envoy_route_v3.RouteAction_MaxStreamDuration{
+			GrpcTimeoutHeaderMax: envoy.Timeout(r.GRPCTimeoutHeaderMax),
+		}
+	}
+
 	if r.RateLimitPolicy != nil && r.RateLimitPolicy.Global != nil {
 		ra.RateLimits = GlobalRateLimits(r.RateLimitPolicy.Global.Descriptors)
 	}
@@ -146,11 +284,16 @@ func RouteRoute(r *dag.Route) *envoy_route_v3.Route_Route {
 		)
 	}
 
-	if envoy.SingleSimpleCluster(r.Clusters) {
+	switch {
+	case r.DynamicForwardProxy != nil:
+		ra.ClusterSpecifier = &envoy_route_v3.RouteAction_Cluster{
+			Cluster: DynamicForwardProxyClusterName,
+		}
+	case envoy.SingleSimpleCluster(r.Clusters):
 		ra.ClusterSpecifier = &envoy_route_v3.RouteAction_Cluster{
 			Cluster: envoy.Clustername(r.Clusters[0]),
 		}
-	} else {
+	default:
 		ra.ClusterSpecifier = &envoy_route_v3.RouteAction_WeightedClusters{
 			WeightedClusters: weightedClusters(r.Clusters),
 		}
@@ -161,8 +304,8 @@ func RouteRoute(r *dag.Route) *envoy_route_v3.Route_Route {
 }
 
 // hashPolicy returns a slice of Envoy hash policies from the passed in Contour
-// request hash policy configuration. Only one of header or cookie hash policies
-// should be set on any RequestHashPolicy element.
+// request hash policy configuration. Only one of header, cookie or source IP hash
+// policies should be set on any RequestHashPolicy element.
 func hashPolicy(requestHashPolicies []dag.RequestHashPolicy) []*envoy_route_v3.RouteAction_HashPolicy {
 	if len(requestHashPolicies) == 0 {
 		return nil
@@ -188,6 +331,13 @@ func hashPolicy(requestHashPolicies []dag.RequestHashPolicy) []*envoy_route_v3.R
 				},
 			}
 		}
+		if rhp.HashSourceIP {
+			newHP.PolicySpecifier = &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties_{
+				ConnectionProperties: &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties{
+					SourceIp: true,
+				},
+			}
+		}
 		hashPolicies = append(hashPolicies, newHP)
 	}
 	return hashPolicies
@@ -198,11 +348,44 @@ func mirrorPolicy(r *dag.Route) []*envoy_route_v3.RouteAction_RequestMirrorPolic
 		return nil
 	}
 
+	cluster := envoy.Clustername(r.MirrorPolicy.Cluster)
+	if r.MirrorPolicy.RequestHeadersPolicy != nil {
+		// Envoy can't change the headers of mirrored requests,
+		// so they are routed through its mirror listener, whose
+		// route for them does so.
+		cluster = MirrorClusterName
+	}
+
 	return []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
-		Cluster: envoy.Clustername(r.MirrorPolicy.Cluster),
+		Cluster: cluster,
 	}}
 }
 
+// MirrorRoute returns the route of Envoy's mirror listener that
+// proxies the requests mirrored by r to the mirror service, applying
+// the request headers policy of the mirror. Mirrored requests have
+// already been rewritten by r, so the caller must supply a route whose
+// match conditions match the rewritten requests.
+func MirrorRoute(r *dag.Route) *envoy_route_v3.Route {
+	hp := r.MirrorPolicy.RequestHeadersPolicy
+
+	return &envoy_route_v3.Route{
+		Match: RouteMatch(r),
+		Action: &envoy_route_v3.Route_Route{
+			Route: &envoy_route_v3.RouteAction{
+				ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+					Cluster: envoy.Clustername(r.MirrorPolicy.Cluster),
+				},
+				// The timeout of the route that mirrors
+				// the requests applies instead.
+				Timeout: protobuf.Duration(0),
+			},
+		},
+		RequestHeadersToAdd:    append(HeaderValueList(hp.Set, false), HeaderValueList(hp.Add, true)...),
+		RequestHeadersToRemove: hp.Remove,
+	}
+}
+
 func retryPolicy(r *dag.Route) *envoy_route_v3.RetryPolicy {
 	if r.RetryPolicy == nil {
 		return nil
@@ -223,6 +406,17 @@ func retryPolicy(r *dag.Route) *envoy_route_v3.RetryPolicy {
 	return rp
 }
 
+func hedgePolicy(r *dag.Route) *envoy_route_v3.HedgePolicy {
+	if r.HedgePolicy == nil {
+		return nil
+	}
+
+	return &envoy_route_v3.HedgePolicy{
+		InitialRequests:      protobuf.UInt32OrNil(r.HedgePolicy.InitialRequests),
+		HedgeOnPerTryTimeout: r.HedgePolicy.HedgeOnPerTryTimeout,
+	}
+}
+
 // UpgradeHTTPS returns a route Action that redirects the request to HTTPS.
 func UpgradeHTTPS() *envoy_route_v3.Route_Redirect {
 	return &envoy_route_v3.Route_Redirect{
@@ -273,7 +467,7 @@ func weightedClusters(clusters []*dag.Cluster) *envoy_route_v3.WeightedCluster {
 			c.RequestHeadersToRemove = cluster.RequestHeadersPolicy.Remove
 		}
 		if cluster.ResponseHeadersPolicy != nil {
-			c.ResponseHeadersToAdd = HeaderValueList(cluster.ResponseHeadersPolicy.Set, false)
+			c.ResponseHeadersToAdd = append(HeaderValueList(cluster.ResponseHeadersPolicy.Set, false), HeaderValueList(cluster.ResponseHeadersPolicy.Add, true)...)
 			c.ResponseHeadersToRemove = cluster.ResponseHeadersPolicy.Remove
 		}
 		wc.Clusters = append(wc.Clusters, c)
@@ -318,6 +512,15 @@ func RouteConfiguration(name string, virtualhosts ...*envoy_route_v3.VirtualHost
 	}
 }
 
+// VirtualHostDiscovery returns a *envoy_route_v3.Vhds that fetches the
+// virtual hosts of a route configuration on demand from cluster. VHDS is
+// only served with the incremental (delta) variant of the xDS protocol.
+func VirtualHostDiscovery(cluster string) *envoy_route_v3.Vhds {
+	return &envoy_route_v3.Vhds{
+		ConfigSource: DeltaConfigSource(cluster),
+	}
+}
+
 // CORSPolicy returns a *envoy_route_v3.CORSPolicy
 func CORSPolicy(cp *dag.CORSPolicy) *envoy_route_v3.CorsPolicy {
 	if cp == nil {
@@ -384,12 +587,36 @@ func headerMatcher(headers []dag.HeaderMatchCondition) []*envoy_route_v3.HeaderM
 			header.HeaderMatchSpecifier = &envoy_route_v3.HeaderMatcher_SafeRegexMatch{
 				SafeRegexMatch: SafeRegexMatch(h.Value),
 			}
+		case dag.HeaderMatchTypeGreaterThan:
+			header.HeaderMatchSpecifier = greaterThanMatch(h.Value)
 		}
 		envoyHeaders = append(envoyHeaders, header)
 	}
 	return envoyHeaders
 }
 
+// greaterThanMatch returns a HeaderMatchSpecifier which will match
+// header values that are integers greater than the supplied value.
+func greaterThanMatch(s string) *envoy_route_v3.HeaderMatcher_RangeMatch {
+	// The DAG guarantees that the value is a valid integer.
+	n, _ := strconv.ParseInt(s, 10, 64)
+
+	// The range ends, exclusively, at math.MaxInt64, so for
+	// math.MaxInt64 it is empty rather than wrapping around
+	// and matching every value.
+	start := int64(math.MaxInt64)
+	if n < math.MaxInt64 {
+		start = n + 1
+	}
+
+	return &envoy_route_v3.HeaderMatcher_RangeMatch{
+		RangeMatch: &envoy_type_v3.Int64Range{
+			Start: start,
+			End:   math.MaxInt64,
+		},
+	}
+}
+
 // containsMatch returns a HeaderMatchSpecifier which will match the
 // supplied substring
 func containsMatch(s string) *envoy_route_v3.HeaderMatcher_SafeRegexMatch {
@@ -14,12 +14,14 @@
 package v3
 
 import (
+	"math"
 	"testing"
 	"time"
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/fixture"
@@ -83,6 +85,18 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"dynamic forward proxy": {
+			route: &dag.Route{
+				DynamicForwardProxy: &dag.DynamicForwardProxyCluster{},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "dynamic_forward_proxy",
+					},
+				},
+			},
+		},
 		"websocket": {
 			route: &dag.Route{
 				Websocket: true,
@@ -99,6 +113,23 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"grpc timeout header max": {
+			route: &dag.Route{
+				GRPC:                 true,
+				GRPCTimeoutHeaderMax: timeout.DurationSetting(30 * time.Second),
+				Clusters:             []*dag.Cluster{c1},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					MaxStreamDuration: &envoy_route_v3.RouteAction_MaxStreamDuration{
+						GrpcTimeoutHeaderMax: protobuf.Duration(30 * time.Second),
+					},
+				},
+			},
+		},
 		"multiple": {
 			route: &dag.Route{
 				Clusters: []*dag.Cluster{{
@@ -296,6 +327,36 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"hedge on per-try timeout": {
+			route: &dag.Route{
+				RetryPolicy: &dag.RetryPolicy{
+					RetryOn:       "5xx",
+					NumRetries:    1,
+					PerTryTimeout: timeout.DurationSetting(100 * time.Millisecond),
+				},
+				HedgePolicy: &dag.HedgePolicy{
+					InitialRequests:      2,
+					HedgeOnPerTryTimeout: true,
+				},
+				Clusters: []*dag.Cluster{c1},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					RetryPolicy: &envoy_route_v3.RetryPolicy{
+						RetryOn:       "5xx",
+						NumRetries:    protobuf.UInt32(1),
+						PerTryTimeout: protobuf.Duration(100 * time.Millisecond),
+					},
+					HedgePolicy: &envoy_route_v3.HedgePolicy{
+						InitialRequests:      protobuf.UInt32(2),
+						HedgeOnPerTryTimeout: true,
+					},
+				},
+			},
+		},
 		"retriable status codes: 502, 503, 504": {
 			route: &dag.Route{
 				RetryPolicy: &dag.RetryPolicy{
@@ -491,6 +552,46 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"single service w/ request header and source ip hashing": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{c3},
+				RequestHashPolicies: []dag.RequestHashPolicy{
+					{
+						Terminal: true,
+						HeaderHashOptions: &dag.HeaderHashOptions{
+							HeaderName: "X-Tenant-Id",
+						},
+					},
+					{
+						HashSourceIP: true,
+					},
+				},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/1a2ffc1fef",
+					},
+					HashPolicy: []*envoy_route_v3.RouteAction_HashPolicy{
+						{
+							Terminal: true,
+							PolicySpecifier: &envoy_route_v3.RouteAction_HashPolicy_Header_{
+								Header: &envoy_route_v3.RouteAction_HashPolicy_Header{
+									HeaderName: "X-Tenant-Id",
+								},
+							},
+						},
+						{
+							PolicySpecifier: &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties_{
+								ConnectionProperties: &envoy_route_v3.RouteAction_HashPolicy_ConnectionProperties{
+									SourceIp: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		"host header rewrite": {
 			route: &dag.Route{
 				RequestHeadersPolicy: &dag.HeadersPolicy{
@@ -544,6 +645,27 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"mirror with request headers policy": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{c1},
+				MirrorPolicy: &dag.MirrorPolicy{
+					Cluster: c1,
+					RequestHeadersPolicy: &dag.HeadersPolicy{
+						Set: map[string]string{"X-Shadow": "true"},
+					},
+				},
+			},
+			want: &envoy_route_v3.Route_Route{
+				Route: &envoy_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					RequestMirrorPolicies: []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
+						Cluster: "contour_mirror",
+					}},
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -554,6 +676,67 @@ func TestRouteRoute(t *testing.T) {
 	}
 }
 
+func TestMirrorRoute(t *testing.T) {
+	mirror := &dag.Cluster{
+		Upstream: &dag.Service{
+			Weighted: dag.WeightedService{
+				Weight:           1,
+				ServiceName:      "kuard",
+				ServiceNamespace: "default",
+				ServicePort: v1.ServicePort{
+					Protocol:   "TCP",
+					Port:       8080,
+					TargetPort: intstr.FromInt(8080),
+				},
+			},
+		},
+	}
+
+	got := MirrorRoute(&dag.Route{
+		PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/api"},
+		MirrorPolicy: &dag.MirrorPolicy{
+			Cluster: mirror,
+			RequestHeadersPolicy: &dag.HeadersPolicy{
+				Set:    map[string]string{"X-Shadow": "true"},
+				Add:    map[string]string{"X-Replay": "1"},
+				Remove: []string{"Authorization"},
+			},
+		},
+	})
+
+	want := &envoy_route_v3.Route{
+		Match: &envoy_route_v3.RouteMatch{
+			PathSpecifier: &envoy_route_v3.RouteMatch_Prefix{
+				Prefix: "/api",
+			},
+		},
+		Action: &envoy_route_v3.Route_Route{
+			Route: &envoy_route_v3.RouteAction{
+				ClusterSpecifier: &envoy_route_v3.RouteAction_Cluster{
+					Cluster: "default/kuard/8080/da39a3ee5e",
+				},
+				Timeout: protobuf.Duration(0),
+			},
+		},
+		RequestHeadersToAdd: []*envoy_core_v3.HeaderValueOption{{
+			Header: &envoy_core_v3.HeaderValue{
+				Key:   "X-Shadow",
+				Value: "true",
+			},
+			Append: protobuf.Bool(false),
+		}, {
+			Header: &envoy_core_v3.HeaderValue{
+				Key:   "X-Replay",
+				Value: "1",
+			},
+			Append: protobuf.Bool(true),
+		}},
+		RequestHeadersToRemove: []string{"Authorization"},
+	}
+
+	protobuf.ExpectEqual(t, want, got)
+}
+
 func TestRouteDirectResponse(t *testing.T) {
 	tests := map[string]struct {
 		directResponse *dag.DirectResponse
@@ -1079,6 +1262,20 @@ func TestRouteMatch(t *testing.T) {
 				}},
 			},
 		},
+		"grpc method": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.ExactMatchCondition{
+					Path: "/helloworld.Greeter/SayHello",
+				},
+				GRPC: true,
+			},
+			want: &envoy_route_v3.RouteMatch{
+				PathSpecifier: &envoy_route_v3.RouteMatch_Path{
+					Path: "/helloworld.Greeter/SayHello",
+				},
+				Grpc: &envoy_route_v3.RouteMatch_GrpcRouteMatchOptions{},
+			},
+		},
 		"path prefix string prefix": {
 			route: &dag.Route{
 				PathMatchCondition: &dag.PrefixMatchCondition{
@@ -1169,6 +1366,46 @@ func TestRouteMatch(t *testing.T) {
 				}},
 			},
 		},
+		"header greater than match": {
+			route: &dag.Route{
+				HeaderMatchConditions: []dag.HeaderMatchCondition{{
+					Name:      "Content-Length",
+					Value:     "1024",
+					MatchType: dag.HeaderMatchTypeGreaterThan,
+				}},
+			},
+			want: &envoy_route_v3.RouteMatch{
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: "Content-Length",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_RangeMatch{
+						RangeMatch: &envoy_type_v3.Int64Range{
+							Start: 1025,
+							End:   math.MaxInt64,
+						},
+					},
+				}},
+			},
+		},
+		"header greater than match out of range": {
+			route: &dag.Route{
+				HeaderMatchConditions: []dag.HeaderMatchCondition{{
+					Name:      "Content-Length",
+					Value:     "9223372036854775807",
+					MatchType: dag.HeaderMatchTypeGreaterThan,
+				}},
+			},
+			want: &envoy_route_v3.RouteMatch{
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: "Content-Length",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_RangeMatch{
+						RangeMatch: &envoy_type_v3.Int64Range{
+							Start: math.MaxInt64,
+							End:   math.MaxInt64,
+						},
+					},
+				}},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -1179,4 +1416,177 @@ func TestRouteMatch(t *testing.T) {
 	}
 }
 
+func TestVirtualClusters(t *testing.T) {
+	tests := map[string]struct {
+		statsName string
+		routes    []*dag.Route
+		want      []*envoy_route_v3.VirtualCluster
+	}{
+		"no stats names": {
+			routes: []*dag.Route{{
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/"},
+			}},
+			want: nil,
+		},
+		"virtual host stats name": {
+			statsName: "app",
+			routes: []*dag.Route{{
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/"},
+			}},
+			want: []*envoy_route_v3.VirtualCluster{{
+				Name: "app",
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: ":path",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+						PrefixMatch: "/",
+					},
+				}},
+			}},
+		},
+		"route stats names": {
+			statsName: "app",
+			routes: []*dag.Route{{
+				PathMatchCondition: &dag.ExactMatchCondition{Path: "/login"},
+				StatsName:          "login",
+			}, {
+				PathMatchCondition: &dag.PrefixMatchCondition{
+					Prefix:          "/api",
+					PrefixMatchType: dag.PrefixMatchSegment,
+				},
+				HeaderMatchConditions: []dag.HeaderMatchCondition{{
+					Name:      "x-api-version",
+					Value:     "2",
+					MatchType: dag.HeaderMatchTypeExact,
+				}},
+				StatsName: "api-v2",
+			}, {
+				PathMatchCondition: &dag.RegexMatchCondition{Regex: "/img/.*"},
+				StatsName:          "images",
+			}, {
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/grpc.Service/"},
+				GRPC:               true,
+				StatsName:          "grpc",
+			}, {
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/"},
+			}},
+			want: []*envoy_route_v3.VirtualCluster{{
+				Name: "login",
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: ":path",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_SafeRegexMatch{
+						SafeRegexMatch: SafeRegexMatch(`/login(\?.*)?`),
+					},
+				}},
+			}, {
+				Name: "api-v2",
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: ":path",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_SafeRegexMatch{
+						SafeRegexMatch: SafeRegexMatch(`/api([/?].*)?`),
+					},
+				}, {
+					Name: "x-api-version",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{
+						ExactMatch: "2",
+					},
+				}},
+			}, {
+				Name: "images",
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: ":path",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_SafeRegexMatch{
+						SafeRegexMatch: SafeRegexMatch(`(?:/img/.*)(\?.*)?`),
+					},
+				}},
+			}, {
+				Name: "grpc",
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: ":path",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+						PrefixMatch: "/grpc.Service/",
+					},
+				}, {
+					Name: "content-type",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+						PrefixMatch: "application/grpc",
+					},
+				}},
+			}, {
+				Name: "app",
+				Headers: []*envoy_route_v3.HeaderMatcher{{
+					Name: ":path",
+					HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+						PrefixMatch: "/",
+					},
+				}},
+			}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := VirtualClusters(tc.statsName, tc.routes)
+			protobuf.ExpectEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestClientCertificateHeaders(t *testing.T) {
+	tests := map[string]struct {
+		headers map[string]string
+		want    []*envoy_core_v3.HeaderValueOption
+	}{
+		"no headers": {
+			headers: nil,
+			want:    nil,
+		},
+		"headers": {
+			headers: map[string]string{
+				"X-Client-San":         "SubjectAltName",
+				"X-Client-Subject":     "Subject",
+				"X-Client-Issuer":      "Issuer",
+				"X-Client-Serial":      "Serial",
+				"X-Client-Fingerprint": "SHA256",
+			},
+			want: []*envoy_core_v3.HeaderValueOption{{
+				Header: &envoy_core_v3.HeaderValue{
+					Key:   "X-Client-Fingerprint",
+					Value: "%DOWNSTREAM_PEER_FINGERPRINT_256%",
+				},
+				Append: &wrappers.BoolValue{Value: false},
+			}, {
+				Header: &envoy_core_v3.HeaderValue{
+					Key:   "X-Client-Issuer",
+					Value: "%DOWNSTREAM_PEER_ISSUER%",
+				},
+				Append: &wrappers.BoolValue{Value: false},
+			}, {
+				Header: &envoy_core_v3.HeaderValue{
+					Key:   "X-Client-San",
+					Value: "%DOWNSTREAM_PEER_URI_SAN%",
+				},
+				Append: &wrappers.BoolValue{Value: false},
+			}, {
+				Header: &envoy_core_v3.HeaderValue{
+					Key:   "X-Client-Serial",
+					Value: "%DOWNSTREAM_PEER_SERIAL%",
+				},
+				Append: &wrappers.BoolValue{Value: false},
+			}, {
+				Header: &envoy_core_v3.HeaderValue{
+					Key:   "X-Client-Subject",
+					Value: "%DOWNSTREAM_PEER_SUBJECT%",
+				},
+				Append: &wrappers.BoolValue{Value: false},
+			}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, ClientCertificateHeaders(tc.headers))
+		})
+	}
+}
+
 func virtualhosts(v ...*envoy_route_v3.VirtualHost) []*envoy_route_v3.VirtualHost { return v }
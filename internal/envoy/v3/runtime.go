@@ -0,0 +1,63 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"strconv"
+
+	envoy_service_runtime_v3 "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+)
+
+// RuntimeLayerName is the name of the runtime resource that Contour
+// serves with RTDS, and of the runtime layer that Envoy loads it into.
+const RuntimeLayerName = "contour"
+
+// Runtime returns the runtime resource holding the given values, keyed
+// by runtime key. Values that parse as a boolean or a number are set
+// as such, so that Envoy reads them as feature flags or numbers.
+func Runtime(values map[string]string) *envoy_service_runtime_v3.Runtime {
+	layer := &_struct.Struct{
+		Fields: make(map[string]*_struct.Value, len(values)),
+	}
+
+	for key, value := range values {
+		layer.Fields[key] = runtimeValue(value)
+	}
+
+	return &envoy_service_runtime_v3.Runtime{
+		Name:  RuntimeLayerName,
+		Layer: layer,
+	}
+}
+
+func runtimeValue(s string) *_struct.Value {
+	if s == "true" || s == "false" {
+		return &_struct.Value{
+			Kind: &_struct.Value_BoolValue{
+				BoolValue: s == "true",
+			},
+		}
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return &_struct.Value{
+			Kind: &_struct.Value_NumberValue{
+				NumberValue: f,
+			},
+		}
+	}
+
+	return sv(s)
+}
@@ -0,0 +1,63 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_service_runtime_v3 "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestRuntime(t *testing.T) {
+	tests := map[string]struct {
+		values map[string]string
+		want   *envoy_service_runtime_v3.Runtime
+	}{
+		"empty": {
+			want: &envoy_service_runtime_v3.Runtime{
+				Name:  "contour",
+				Layer: &_struct.Struct{Fields: map[string]*_struct.Value{}},
+			},
+		},
+		"typed values": {
+			values: map[string]string{
+				"envoy.reloadable_features.http_reject_path_with_fragment": "false",
+				"overload.global_downstream_max_connections":               "50000",
+				"upstream.healthy_panic_threshold":                         "12.5",
+				"health_check.min_interval":                                "1",
+				"contour.example":                                          "value",
+			},
+			want: &envoy_service_runtime_v3.Runtime{
+				Name: "contour",
+				Layer: &_struct.Struct{
+					Fields: map[string]*_struct.Value{
+						"envoy.reloadable_features.http_reject_path_with_fragment": {Kind: &_struct.Value_BoolValue{BoolValue: false}},
+						"overload.global_downstream_max_connections":               {Kind: &_struct.Value_NumberValue{NumberValue: 50000}},
+						"upstream.healthy_panic_threshold":                         {Kind: &_struct.Value_NumberValue{NumberValue: 12.5}},
+						"health_check.min_interval":                                {Kind: &_struct.Value_NumberValue{NumberValue: 1}},
+						"contour.example":                                          {Kind: &_struct.Value_StringValue{StringValue: "value"}},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, Runtime(tc.values))
+		})
+	}
+}
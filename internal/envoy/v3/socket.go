@@ -15,6 +15,8 @@ package v3
 
 import (
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_proxy_protocol_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/proxy_protocol/v3"
+	envoy_raw_buffer_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/raw_buffer/v3"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/projectcontour/contour/internal/protobuf"
 )
@@ -38,3 +40,35 @@ func DownstreamTLSTransportSocket(tls *envoy_tls_v3.DownstreamTlsContext) *envoy
 		},
 	}
 }
+
+// UpstreamProxyProtocolTransportSocket returns a custom transport socket that
+// sends a PROXY protocol header of the given version ("v1" or "v2") before
+// passing the connection to the inner transport socket. If inner is nil, the
+// connection is passed to a plaintext transport socket.
+func UpstreamProxyProtocolTransportSocket(version string, inner *envoy_core_v3.TransportSocket) *envoy_core_v3.TransportSocket {
+	if inner == nil {
+		inner = &envoy_core_v3.TransportSocket{
+			Name: "envoy.transport_sockets.raw_buffer",
+			ConfigType: &envoy_core_v3.TransportSocket_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_raw_buffer_v3.RawBuffer{}),
+			},
+		}
+	}
+
+	config := &envoy_core_v3.ProxyProtocolConfig{
+		Version: envoy_core_v3.ProxyProtocolConfig_V1,
+	}
+	if version == "v2" {
+		config.Version = envoy_core_v3.ProxyProtocolConfig_V2
+	}
+
+	return &envoy_core_v3.TransportSocket{
+		Name: "envoy.transport_sockets.upstream_proxy_protocol",
+		ConfigType: &envoy_core_v3.TransportSocket_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_proxy_protocol_v3.ProxyProtocolUpstreamTransport{
+				Config:          config,
+				TransportSocket: inner,
+			}),
+		},
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"testing"
 
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_proxy_protocol_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/proxy_protocol/v3"
+	envoy_raw_buffer_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/raw_buffer/v3"
 	envoy_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -83,3 +85,56 @@ func TestDownstreamTLSTransportSocket(t *testing.T) {
 		})
 	}
 }
+
+func TestUpstreamProxyProtocolTransportSocket(t *testing.T) {
+	rawBuffer := &envoy_core_v3.TransportSocket{
+		Name: "envoy.transport_sockets.raw_buffer",
+		ConfigType: &envoy_core_v3.TransportSocket_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_raw_buffer_v3.RawBuffer{}),
+		},
+	}
+	tls := UpstreamTLSTransportSocket(UpstreamTLSContext(nil, "", nil))
+
+	tests := map[string]struct {
+		version string
+		inner   *envoy_core_v3.TransportSocket
+		want    *envoy_core_v3.TransportSocket
+	}{
+		"v1 plaintext": {
+			version: "v1",
+			want: &envoy_core_v3.TransportSocket{
+				Name: "envoy.transport_sockets.upstream_proxy_protocol",
+				ConfigType: &envoy_core_v3.TransportSocket_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&envoy_proxy_protocol_v3.ProxyProtocolUpstreamTransport{
+						Config: &envoy_core_v3.ProxyProtocolConfig{
+							Version: envoy_core_v3.ProxyProtocolConfig_V1,
+						},
+						TransportSocket: rawBuffer,
+					}),
+				},
+			},
+		},
+		"v2 tls": {
+			version: "v2",
+			inner:   tls,
+			want: &envoy_core_v3.TransportSocket{
+				Name: "envoy.transport_sockets.upstream_proxy_protocol",
+				ConfigType: &envoy_core_v3.TransportSocket_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&envoy_proxy_protocol_v3.ProxyProtocolUpstreamTransport{
+						Config: &envoy_core_v3.ProxyProtocolConfig{
+							Version: envoy_core_v3.ProxyProtocolConfig_V2,
+						},
+						TransportSocket: tls,
+					}),
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := UpstreamProxyProtocolTransportSocket(tc.version, tc.inner)
+			protobuf.ExpectEqual(t, tc.want, got)
+		})
+	}
+}
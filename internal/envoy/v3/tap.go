@@ -0,0 +1,219 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	envoy_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/config/common/matcher/v3"
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_config_tap_v3 "github.com/envoyproxy/go-control-plane/envoy/config/tap/v3"
+	envoy_common_tap_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/tap/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	envoy_tap_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/tap/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// tapMetadataKey is the route metadata key that holds the
+// tap policy of the route.
+const tapMetadataKey = "tap_policy"
+
+// TapHeader is the request header that the tap select filter sets
+// to the ID of the tap that captures a request. Routes with a tap
+// policy remove it before the request is sent upstream.
+const TapHeader = "x-contour-tap"
+
+// tapSelectCode selects the requests to routes with a tap policy that
+// match the header conditions of the policy, until the policy's number
+// of requests have been captured. The count is kept by each worker
+// thread of Envoy, and starts over when the filter is updated.
+const tapSelectCode = `
+local captured = {}
+
+local function matches(headers, condition)
+	local value = headers:get(condition["name"])
+	if value == nil then
+		return condition["invert"] == true and condition["match"] == "present"
+	end
+
+	local match = false
+	if condition["match"] == "present" then
+		match = true
+	elseif condition["match"] == "exact" then
+		match = value == condition["value"]
+	elseif condition["match"] == "contains" then
+		match = string.find(value, condition["value"], 1, true) ~= nil
+	end
+	if condition["invert"] then
+		return not match
+	end
+	return match
+end
+
+function envoy_on_request(request_handle)
+	local headers = request_handle:headers()
+	headers:remove("` + TapHeader + `")
+
+	local policy = request_handle:metadata():get("` + tapMetadataKey + `")
+	if policy == nil then
+		return
+	end
+	for _, condition in ipairs(policy["headers"] or {}) do
+		if not matches(headers, condition) then
+			return
+		end
+	end
+
+	local id = policy["id"]
+	local count = captured[id] or 0
+	if count >= policy["max_requests"] then
+		return
+	end
+	captured[id] = count + 1
+	headers:add("` + TapHeader + `", id)
+end
+`
+
+// FilterTapSelect returns a Lua filter that selects the requests that
+// the tap filters capture, with the route metadata returned by
+// TapMetadata. It must come before the tap filters.
+func FilterTapSelect() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: "tap_select",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: tapSelectCode,
+			}),
+		},
+	}
+}
+
+// FilterTap returns the tap filter that captures the requests that
+// FilterTapSelect selects for the tap policy, and their responses.
+// Envoy's tap filter can't be configured per route, so there is a
+// filter for each tap policy.
+func FilterTap(policy *dag.TapPolicy) *http.HttpFilter {
+	if policy == nil {
+		return nil
+	}
+
+	var sink *envoy_config_tap_v3.OutputSink
+	if policy.Service != nil {
+		sink = &envoy_config_tap_v3.OutputSink{
+			Format: envoy_config_tap_v3.OutputSink_PROTO_BINARY,
+			OutputSinkType: &envoy_config_tap_v3.OutputSink_StreamingGrpc{
+				StreamingGrpc: &envoy_config_tap_v3.StreamingGrpcSink{
+					TapId: policy.ID,
+					GrpcService: &envoy_core_v3.GrpcService{
+						TargetSpecifier: &envoy_core_v3.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &envoy_core_v3.GrpcService_EnvoyGrpc{
+								ClusterName: policy.Service.Name,
+							},
+						},
+					},
+				},
+			},
+		}
+	} else {
+		sink = &envoy_config_tap_v3.OutputSink{
+			Format: envoy_config_tap_v3.OutputSink_JSON_BODY_AS_STRING,
+			OutputSinkType: &envoy_config_tap_v3.OutputSink_FilePerTap{
+				FilePerTap: &envoy_config_tap_v3.FilePerTapSink{
+					PathPrefix: policy.PathPrefix,
+				},
+			},
+		}
+	}
+
+	return &http.HttpFilter{
+		Name: "tap/" + policy.ID,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_tap_v3.Tap{
+				CommonConfig: &envoy_common_tap_v3.CommonExtensionConfig{
+					ConfigType: &envoy_common_tap_v3.CommonExtensionConfig_StaticConfig{
+						StaticConfig: &envoy_config_tap_v3.TapConfig{
+							Match: &envoy_matcher_v3.MatchPredicate{
+								Rule: &envoy_matcher_v3.MatchPredicate_HttpRequestHeadersMatch{
+									HttpRequestHeadersMatch: &envoy_matcher_v3.HttpHeadersMatch{
+										Headers: []*envoy_route_v3.HeaderMatcher{{
+											Name: TapHeader,
+											HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{
+												ExactMatch: policy.ID,
+											},
+										}},
+									},
+								},
+							},
+							OutputConfig: &envoy_config_tap_v3.OutputConfig{
+								Sinks: []*envoy_config_tap_v3.OutputSink{sink},
+							},
+						},
+					},
+				},
+			}),
+		},
+	}
+}
+
+// TapMetadata returns the route metadata that configures the tap
+// select filter with the tap policy of a route, or nil if it has none.
+func TapMetadata(policy *dag.TapPolicy) *envoy_core_v3.Metadata {
+	if policy == nil {
+		return nil
+	}
+
+	headers := make([]*_struct.Value, 0, len(policy.HeaderMatchConditions))
+	for _, h := range policy.HeaderMatchConditions {
+		headers = append(headers, &_struct.Value{
+			Kind: &_struct.Value_StructValue{
+				StructValue: &_struct.Struct{
+					Fields: map[string]*_struct.Value{
+						"name":   stringValue(h.Name),
+						"value":  stringValue(h.Value),
+						"match":  stringValue(h.MatchType),
+						"invert": boolValue(h.Invert),
+					},
+				},
+			},
+		})
+	}
+
+	return &envoy_core_v3.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			luaMetadataNamespace: {
+				Fields: map[string]*_struct.Value{
+					tapMetadataKey: {
+						Kind: &_struct.Value_StructValue{
+							StructValue: &_struct.Struct{
+								Fields: map[string]*_struct.Value{
+									"id": stringValue(policy.ID),
+									"max_requests": {
+										Kind: &_struct.Value_NumberValue{NumberValue: float64(policy.MaxRequests)},
+									},
+									"headers": {
+										Kind: &_struct.Value_ListValue{
+											ListValue: &_struct.ListValue{Values: headers},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
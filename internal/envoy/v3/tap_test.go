@@ -0,0 +1,185 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/config/common/matcher/v3"
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_config_tap_v3 "github.com/envoyproxy/go-control-plane/envoy/config/tap/v3"
+	envoy_common_tap_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/tap/v3"
+	envoy_tap_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/tap/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestFilterTap(t *testing.T) {
+	tap := func(id string, sink *envoy_config_tap_v3.OutputSink) *http.HttpFilter {
+		return &http.HttpFilter{
+			Name: "tap/" + id,
+			ConfigType: &http.HttpFilter_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_tap_v3.Tap{
+					CommonConfig: &envoy_common_tap_v3.CommonExtensionConfig{
+						ConfigType: &envoy_common_tap_v3.CommonExtensionConfig_StaticConfig{
+							StaticConfig: &envoy_config_tap_v3.TapConfig{
+								Match: &envoy_matcher_v3.MatchPredicate{
+									Rule: &envoy_matcher_v3.MatchPredicate_HttpRequestHeadersMatch{
+										HttpRequestHeadersMatch: &envoy_matcher_v3.HttpHeadersMatch{
+											Headers: []*envoy_route_v3.HeaderMatcher{{
+												Name: "x-contour-tap",
+												HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_ExactMatch{
+													ExactMatch: id,
+												},
+											}},
+										},
+									},
+								},
+								OutputConfig: &envoy_config_tap_v3.OutputConfig{
+									Sinks: []*envoy_config_tap_v3.OutputSink{sink},
+								},
+							},
+						},
+					},
+				}),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		policy *dag.TapPolicy
+		want   *http.HttpFilter
+	}{
+		"nil policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"file sink": {
+			policy: &dag.TapPolicy{
+				ID:          "default_example_0",
+				MaxRequests: 10,
+				PathPrefix:  "/tmp/default_example_0",
+			},
+			want: tap("default_example_0", &envoy_config_tap_v3.OutputSink{
+				Format: envoy_config_tap_v3.OutputSink_JSON_BODY_AS_STRING,
+				OutputSinkType: &envoy_config_tap_v3.OutputSink_FilePerTap{
+					FilePerTap: &envoy_config_tap_v3.FilePerTapSink{
+						PathPrefix: "/tmp/default_example_0",
+					},
+				},
+			}),
+		},
+		"gRPC sink": {
+			policy: &dag.TapPolicy{
+				ID:          "default_example_1",
+				MaxRequests: 10,
+				Service: &dag.ExtensionCluster{
+					Name: "extension/default/tap",
+				},
+			},
+			want: tap("default_example_1", &envoy_config_tap_v3.OutputSink{
+				Format: envoy_config_tap_v3.OutputSink_PROTO_BINARY,
+				OutputSinkType: &envoy_config_tap_v3.OutputSink_StreamingGrpc{
+					StreamingGrpc: &envoy_config_tap_v3.StreamingGrpcSink{
+						TapId: "default_example_1",
+						GrpcService: &envoy_core_v3.GrpcService{
+							TargetSpecifier: &envoy_core_v3.GrpcService_EnvoyGrpc_{
+								EnvoyGrpc: &envoy_core_v3.GrpcService_EnvoyGrpc{
+									ClusterName: "extension/default/tap",
+								},
+							},
+						},
+					},
+				},
+			}),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, FilterTap(tc.policy))
+		})
+	}
+}
+
+func TestTapMetadata(t *testing.T) {
+	tests := map[string]struct {
+		policy *dag.TapPolicy
+		want   *envoy_core_v3.Metadata
+	}{
+		"nil policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"policy": {
+			policy: &dag.TapPolicy{
+				ID:          "default_example_0",
+				MaxRequests: 5,
+				HeaderMatchConditions: []dag.HeaderMatchCondition{{
+					Name:      "x-debug",
+					MatchType: dag.HeaderMatchTypePresent,
+					Invert:    true,
+				}},
+				PathPrefix: "/tmp/default_example_0",
+			},
+			want: &envoy_core_v3.Metadata{
+				FilterMetadata: map[string]*_struct.Struct{
+					"envoy.filters.http.lua": {
+						Fields: map[string]*_struct.Value{
+							"tap_policy": {
+								Kind: &_struct.Value_StructValue{
+									StructValue: &_struct.Struct{
+										Fields: map[string]*_struct.Value{
+											"id": stringValue("default_example_0"),
+											"max_requests": {
+												Kind: &_struct.Value_NumberValue{NumberValue: 5},
+											},
+											"headers": {
+												Kind: &_struct.Value_ListValue{
+													ListValue: &_struct.ListValue{
+														Values: []*_struct.Value{{
+															Kind: &_struct.Value_StructValue{
+																StructValue: &_struct.Struct{
+																	Fields: map[string]*_struct.Value{
+																		"name":   stringValue("x-debug"),
+																		"value":  stringValue(""),
+																		"match":  stringValue("present"),
+																		"invert": boolValue(true),
+																	},
+																},
+															},
+														}},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, TapMetadata(tc.policy))
+		})
+	}
+}
@@ -14,8 +14,11 @@
 package v3
 
 import (
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/protobuf"
 )
 
 func TCPKeepaliveSocketOptions() []*envoy_core_v3.SocketOption {
@@ -61,3 +64,19 @@ func TCPKeepaliveSocketOptions() []*envoy_core_v3.SocketOption {
 		},
 	}
 }
+
+// UpstreamTCPKeepalive returns the upstream connection options that
+// enable TCP keepalive probes with the given settings, or nil if k is nil.
+func UpstreamTCPKeepalive(k *dag.TCPKeepalive) *envoy_cluster_v3.UpstreamConnectionOptions {
+	if k == nil {
+		return nil
+	}
+
+	return &envoy_cluster_v3.UpstreamConnectionOptions{
+		TcpKeepalive: &envoy_core_v3.TcpKeepalive{
+			KeepaliveProbes:   protobuf.UInt32OrNil(k.Probes),
+			KeepaliveTime:     protobuf.UInt32OrNil(k.Time),
+			KeepaliveInterval: protobuf.UInt32OrNil(k.Interval),
+		},
+	}
+}
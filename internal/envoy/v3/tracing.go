@@ -0,0 +1,180 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_trace_v3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_tracing_v3 "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TracingProvider is the tracer used to export spans.
+type TracingProvider int
+
+const (
+	// ZipkinTracing exports spans to a Zipkin compatible collector.
+	ZipkinTracing TracingProvider = iota
+
+	// OpenCensusTracing exports spans to an OpenCensus agent.
+	OpenCensusTracing
+)
+
+// TracingConfig stores configuration for tracing the requests
+// through an HTTP connection manager.
+type TracingConfig struct {
+	Provider           TracingProvider
+	ExtensionService   types.NamespacedName
+	CollectorEndpoint  string
+	SamplingPercentage *float64
+	CustomTags         []*CustomTag
+}
+
+// CustomTag is a tag added to each span, with either a literal
+// value or the value of a request header.
+type CustomTag struct {
+	TagName           string
+	Literal           string
+	RequestHeaderName string
+}
+
+// Tracing returns the tracing configuration of an HTTP connection
+// manager, or nil if config is nil.
+func Tracing(config *TracingConfig) *http.HttpConnectionManager_Tracing {
+	if config == nil {
+		return nil
+	}
+
+	tracing := &http.HttpConnectionManager_Tracing{
+		CustomTags: customTags(config.CustomTags),
+		Provider:   tracingProvider(config),
+	}
+
+	if config.SamplingPercentage != nil {
+		tracing.RandomSampling = &envoy_type.Percent{
+			Value: *config.SamplingPercentage,
+		}
+	}
+
+	return tracing
+}
+
+func tracingProvider(config *TracingConfig) *envoy_trace_v3.Tracing_Http {
+	cluster := dag.ExtensionClusterName(config.ExtensionService)
+
+	switch config.Provider {
+	case OpenCensusTracing:
+		contexts := []envoy_trace_v3.OpenCensusConfig_TraceContext{
+			envoy_trace_v3.OpenCensusConfig_TRACE_CONTEXT,
+			envoy_trace_v3.OpenCensusConfig_B3,
+		}
+
+		return &envoy_trace_v3.Tracing_Http{
+			Name: "envoy.tracers.opencensus",
+			ConfigType: &envoy_trace_v3.Tracing_Http_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_trace_v3.OpenCensusConfig{
+					OcagentExporterEnabled: true,
+					OcagentGrpcService: &envoy_core_v3.GrpcService{
+						TargetSpecifier: &envoy_core_v3.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &envoy_core_v3.GrpcService_EnvoyGrpc{
+								ClusterName: cluster,
+							},
+						},
+					},
+					IncomingTraceContext: contexts,
+					OutgoingTraceContext: contexts,
+				}),
+			},
+		}
+	default:
+		endpoint := config.CollectorEndpoint
+		if endpoint == "" {
+			endpoint = "/api/v2/spans"
+		}
+
+		return &envoy_trace_v3.Tracing_Http{
+			Name: "envoy.tracers.zipkin",
+			ConfigType: &envoy_trace_v3.Tracing_Http_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_trace_v3.ZipkinConfig{
+					CollectorCluster:         cluster,
+					CollectorEndpoint:        endpoint,
+					CollectorEndpointVersion: envoy_trace_v3.ZipkinConfig_HTTP_JSON,
+				}),
+			},
+		}
+	}
+}
+
+// RouteTracing returns the tracing overrides of a route, or nil if
+// the route does not override tracing.
+func RouteTracing(policy *dag.TracingPolicy) *envoy_route_v3.Tracing {
+	if policy == nil {
+		return nil
+	}
+
+	var tags []*CustomTag
+	for _, tag := range policy.CustomTags {
+		tags = append(tags, &CustomTag{
+			TagName:           tag.TagName,
+			Literal:           tag.Literal,
+			RequestHeaderName: tag.RequestHeaderName,
+		})
+	}
+
+	tracing := &envoy_route_v3.Tracing{
+		CustomTags: customTags(tags),
+	}
+
+	if policy.SamplingPercentage != nil {
+		tracing.RandomSampling = &envoy_type.FractionalPercent{
+			Numerator:   *policy.SamplingPercentage,
+			Denominator: envoy_type.FractionalPercent_HUNDRED,
+		}
+	}
+
+	return tracing
+}
+
+func customTags(tags []*CustomTag) []*envoy_tracing_v3.CustomTag {
+	var customTags []*envoy_tracing_v3.CustomTag
+
+	for _, tag := range tags {
+		ct := &envoy_tracing_v3.CustomTag{
+			Tag: tag.TagName,
+		}
+
+		if tag.RequestHeaderName != "" {
+			ct.Type = &envoy_tracing_v3.CustomTag_RequestHeader{
+				RequestHeader: &envoy_tracing_v3.CustomTag_Header{
+					Name: tag.RequestHeaderName,
+				},
+			}
+		} else {
+			ct.Type = &envoy_tracing_v3.CustomTag_Literal_{
+				Literal: &envoy_tracing_v3.CustomTag_Literal{
+					Value: tag.Literal,
+				},
+			}
+		}
+
+		customTags = append(customTags, ct)
+	}
+
+	return customTags
+}
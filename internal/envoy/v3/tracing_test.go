@@ -0,0 +1,159 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_trace_v3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_tracing_v3 "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestTracing(t *testing.T) {
+	tenPercent := 10.0
+
+	tests := map[string]struct {
+		cfg  *TracingConfig
+		want *http.HttpConnectionManager_Tracing
+	}{
+		"nil config produces nil tracing": {
+			cfg:  nil,
+			want: nil,
+		},
+		"zipkin with defaults": {
+			cfg: &TracingConfig{
+				ExtensionService: k8s.NamespacedNameFrom("projectcontour/zipkin"),
+			},
+			want: &http.HttpConnectionManager_Tracing{
+				Provider: &envoy_trace_v3.Tracing_Http{
+					Name: "envoy.tracers.zipkin",
+					ConfigType: &envoy_trace_v3.Tracing_Http_TypedConfig{
+						TypedConfig: protobuf.MustMarshalAny(&envoy_trace_v3.ZipkinConfig{
+							CollectorCluster:         "extension/projectcontour/zipkin",
+							CollectorEndpoint:        "/api/v2/spans",
+							CollectorEndpointVersion: envoy_trace_v3.ZipkinConfig_HTTP_JSON,
+						}),
+					},
+				},
+			},
+		},
+		"opencensus with sampling and custom tags": {
+			cfg: &TracingConfig{
+				Provider:           OpenCensusTracing,
+				ExtensionService:   k8s.NamespacedNameFrom("projectcontour/otel-collector"),
+				SamplingPercentage: &tenPercent,
+				CustomTags: []*CustomTag{
+					{TagName: "cluster", Literal: "prod"},
+					{TagName: "customer", RequestHeaderName: "x-customer-id"},
+				},
+			},
+			want: &http.HttpConnectionManager_Tracing{
+				RandomSampling: &envoy_type.Percent{Value: 10},
+				CustomTags: []*envoy_tracing_v3.CustomTag{{
+					Tag: "cluster",
+					Type: &envoy_tracing_v3.CustomTag_Literal_{
+						Literal: &envoy_tracing_v3.CustomTag_Literal{Value: "prod"},
+					},
+				}, {
+					Tag: "customer",
+					Type: &envoy_tracing_v3.CustomTag_RequestHeader{
+						RequestHeader: &envoy_tracing_v3.CustomTag_Header{Name: "x-customer-id"},
+					},
+				}},
+				Provider: &envoy_trace_v3.Tracing_Http{
+					Name: "envoy.tracers.opencensus",
+					ConfigType: &envoy_trace_v3.Tracing_Http_TypedConfig{
+						TypedConfig: protobuf.MustMarshalAny(&envoy_trace_v3.OpenCensusConfig{
+							OcagentExporterEnabled: true,
+							OcagentGrpcService: &envoy_core_v3.GrpcService{
+								TargetSpecifier: &envoy_core_v3.GrpcService_EnvoyGrpc_{
+									EnvoyGrpc: &envoy_core_v3.GrpcService_EnvoyGrpc{
+										ClusterName: "extension/projectcontour/otel-collector",
+									},
+								},
+							},
+							IncomingTraceContext: []envoy_trace_v3.OpenCensusConfig_TraceContext{
+								envoy_trace_v3.OpenCensusConfig_TRACE_CONTEXT,
+								envoy_trace_v3.OpenCensusConfig_B3,
+							},
+							OutgoingTraceContext: []envoy_trace_v3.OpenCensusConfig_TraceContext{
+								envoy_trace_v3.OpenCensusConfig_TRACE_CONTEXT,
+								envoy_trace_v3.OpenCensusConfig_B3,
+							},
+						}),
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, Tracing(tc.cfg))
+		})
+	}
+}
+
+func TestRouteTracing(t *testing.T) {
+	percentage := uint32(5)
+
+	tests := map[string]struct {
+		policy *dag.TracingPolicy
+		want   *envoy_route_v3.Tracing
+	}{
+		"nil policy": {
+			policy: nil,
+			want:   nil,
+		},
+		"sampling and custom tags": {
+			policy: &dag.TracingPolicy{
+				SamplingPercentage: &percentage,
+				CustomTags: []dag.TracingCustomTag{
+					{TagName: "team", Literal: "payments"},
+					{TagName: "customer", RequestHeaderName: "x-customer-id"},
+				},
+			},
+			want: &envoy_route_v3.Tracing{
+				RandomSampling: &envoy_type.FractionalPercent{
+					Numerator:   5,
+					Denominator: envoy_type.FractionalPercent_HUNDRED,
+				},
+				CustomTags: []*envoy_tracing_v3.CustomTag{{
+					Tag: "team",
+					Type: &envoy_tracing_v3.CustomTag_Literal_{
+						Literal: &envoy_tracing_v3.CustomTag_Literal{Value: "payments"},
+					},
+				}, {
+					Tag: "customer",
+					Type: &envoy_tracing_v3.CustomTag_RequestHeader{
+						RequestHeader: &envoy_tracing_v3.CustomTag_Header{Name: "x-customer-id"},
+					},
+				}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, RouteTracing(tc.policy))
+		})
+	}
+}
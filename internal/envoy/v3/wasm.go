@@ -0,0 +1,93 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_fault_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
+	envoy_wasm_filter_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_wasm_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// FilterWasm returns the HTTP filter that runs the Wasm module m. If the
+// code of m could not be loaded, and requests don't pass on when it
+// fails, the filter fails every request with a 503 response instead.
+// It returns nil when there is nothing to run.
+func FilterWasm(m *dag.WasmModule) *http.HttpFilter {
+	if m == nil {
+		return nil
+	}
+
+	name := "wasm/" + m.Name
+
+	if m.Code == nil {
+		if m.FailOpen {
+			return nil
+		}
+		return &http.HttpFilter{
+			Name: name,
+			ConfigType: &http.HttpFilter_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_fault_v3.HTTPFault{
+					Abort: &envoy_fault_v3.FaultAbort{
+						ErrorType: &envoy_fault_v3.FaultAbort_HttpStatus{
+							HttpStatus: 503,
+						},
+						Percentage: &envoy_type.FractionalPercent{
+							Numerator:   100,
+							Denominator: envoy_type.FractionalPercent_HUNDRED,
+						},
+					},
+				}),
+			},
+		}
+	}
+
+	plugin := &envoy_wasm_v3.PluginConfig{
+		Name:   m.Name,
+		RootId: m.RootID,
+		Vm: &envoy_wasm_v3.PluginConfig_VmConfig{
+			VmConfig: &envoy_wasm_v3.VmConfig{
+				VmId:    m.Name,
+				Runtime: "envoy.wasm.runtime.v8",
+				Code: &envoy_core_v3.AsyncDataSource{
+					Specifier: &envoy_core_v3.AsyncDataSource_Local{
+						Local: &envoy_core_v3.DataSource{
+							Specifier: &envoy_core_v3.DataSource_InlineBytes{
+								InlineBytes: m.Code,
+							},
+						},
+					},
+				},
+			},
+		},
+		FailOpen: m.FailOpen,
+	}
+	if m.Configuration != "" {
+		plugin.Configuration = protobuf.MustMarshalAny(wrapperspb.String(m.Configuration))
+	}
+
+	return &http.HttpFilter{
+		Name: name,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&envoy_wasm_filter_v3.Wasm{
+				Config: plugin,
+			}),
+		},
+	}
+}
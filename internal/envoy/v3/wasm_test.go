@@ -0,0 +1,111 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_fault_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
+	envoy_wasm_filter_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_wasm_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFilterWasm(t *testing.T) {
+	code := []byte("\x00asm\x01\x00\x00\x00")
+
+	tests := map[string]struct {
+		module *dag.WasmModule
+		want   *http.HttpFilter
+	}{
+		"nil module": {
+			module: nil,
+			want:   nil,
+		},
+		"module": {
+			module: &dag.WasmModule{
+				Name:          "default/filter",
+				Code:          code,
+				RootID:        "headers",
+				Configuration: `{"header":"x-wasm"}`,
+			},
+			want: &http.HttpFilter{
+				Name: "wasm/default/filter",
+				ConfigType: &http.HttpFilter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&envoy_wasm_filter_v3.Wasm{
+						Config: &envoy_wasm_v3.PluginConfig{
+							Name:   "default/filter",
+							RootId: "headers",
+							Vm: &envoy_wasm_v3.PluginConfig_VmConfig{
+								VmConfig: &envoy_wasm_v3.VmConfig{
+									VmId:    "default/filter",
+									Runtime: "envoy.wasm.runtime.v8",
+									Code: &envoy_core_v3.AsyncDataSource{
+										Specifier: &envoy_core_v3.AsyncDataSource_Local{
+											Local: &envoy_core_v3.DataSource{
+												Specifier: &envoy_core_v3.DataSource_InlineBytes{
+													InlineBytes: code,
+												},
+											},
+										},
+									},
+								},
+							},
+							Configuration: protobuf.MustMarshalAny(wrapperspb.String(`{"header":"x-wasm"}`)),
+						},
+					}),
+				},
+			},
+		},
+		"module that failed to load": {
+			module: &dag.WasmModule{
+				Name: "default/filter",
+			},
+			want: &http.HttpFilter{
+				Name: "wasm/default/filter",
+				ConfigType: &http.HttpFilter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&envoy_fault_v3.HTTPFault{
+						Abort: &envoy_fault_v3.FaultAbort{
+							ErrorType: &envoy_fault_v3.FaultAbort_HttpStatus{
+								HttpStatus: 503,
+							},
+							Percentage: &envoy_type.FractionalPercent{
+								Numerator:   100,
+								Denominator: envoy_type.FractionalPercent_HUNDRED,
+							},
+						},
+					}),
+				},
+			},
+		},
+		"module that failed to load and fails open": {
+			module: &dag.WasmModule{
+				Name:     "default/filter",
+				FailOpen: true,
+			},
+			want: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, FilterWasm(tc.module))
+		})
+	}
+}
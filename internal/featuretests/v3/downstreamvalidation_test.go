@@ -16,8 +16,11 @@ package v3
 import (
 	"testing"
 
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/dag"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
@@ -203,3 +206,115 @@ func TestDownstreamTLSCertificateValidation(t *testing.T) {
 		TypeUrl: listenerType,
 	}).Status(proxy).IsValid()
 }
+
+func TestDownstreamTLSCertificateHeaders(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	serverTLSSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "serverTLSSecret",
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: featuretests.Secretdata(featuretests.CERTIFICATE, featuretests.RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(serverTLSSecret)
+
+	clientCASecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "clientCASecret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			dag.CACertificateKey: []byte(featuretests.CERTIFICATE),
+		},
+	}
+	rh.OnAdd(clientCASecret)
+
+	service := fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Name: "http", Port: 8080, TargetPort: intstr.FromInt(8080)})
+	rh.OnAdd(service)
+
+	proxy := fixture.NewProxy("example.com").
+		WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: serverTLSSecret.Name,
+					ClientValidation: &contour_api_v1.DownstreamValidation{
+						CACertificate: clientCASecret.Name,
+						CertificateHeaders: []contour_api_v1.ClientCertificateHeader{{
+							Name:  "x-client-subject",
+							Field: "Subject",
+						}, {
+							Name:  "x-client-fingerprint",
+							Field: "SHA256",
+						}},
+					},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		})
+	rh.OnAdd(proxy)
+
+	vhost := envoy_v3.VirtualHost("example.com", &envoy_route_v3.Route{
+		Match:  routePrefix("/"),
+		Action: routeCluster("default/kuard/8080/da39a3ee5e"),
+	})
+	vhost.RequestHeadersToAdd = []*envoy_core_v3.HeaderValueOption{{
+		Header: &envoy_core_v3.HeaderValue{
+			Key:   "X-Client-Fingerprint",
+			Value: "%DOWNSTREAM_PEER_FINGERPRINT_256%",
+		},
+		Append: &wrappers.BoolValue{Value: false},
+	}, {
+		Header: &envoy_core_v3.HeaderValue{
+			Key:   "X-Client-Subject",
+			Value: "%DOWNSTREAM_PEER_SUBJECT%",
+		},
+		Append: &wrappers.BoolValue{Value: false},
+	}}
+	vhost.RequestHeadersToRemove = []string{"X-Client-Fingerprint", "X-Client-Subject"}
+
+	c.Request(routeType, "https/example.com").Equals(&envoy_discovery_v3.DiscoveryResponse{
+		TypeUrl:   routeType,
+		Resources: resources(t, envoy_v3.RouteConfiguration("https/example.com", vhost)),
+	}).Status(proxy).IsValid()
+
+	rh.OnUpdate(proxy, fixture.NewProxy("example.com").
+		WithSpec(contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: serverTLSSecret.Name,
+					ClientValidation: &contour_api_v1.DownstreamValidation{
+						CACertificate: clientCASecret.Name,
+						CertificateHeaders: []contour_api_v1.ClientCertificateHeader{{
+							Name:  "x-client-cert",
+							Field: "Subject",
+						}, {
+							Name:  "X-Client-Cert",
+							Field: "Serial",
+						}},
+					},
+				},
+			},
+			Routes: []contour_api_v1.Route{{
+				Services: []contour_api_v1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		}))
+
+	c.Request(listenerType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		TypeUrl:   listenerType,
+		Resources: resources(t, staticListener()),
+	}).Status(proxy).HasError(contour_api_v1.ConditionTypeTLSError, "ClientValidationInvalid", `Spec.VirtualHost.TLS client validation is invalid: duplicate certificate header "X-Client-Cert"`)
+}
@@ -128,6 +128,9 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 			FieldLogger: log.WithField("context", "ExtensionServiceProcessor"),
 		},
 		&dag.HTTPProxyProcessor{},
+		&dag.TLSCertificateDelegationProcessor{
+			FieldLogger: log.WithField("context", "TLSCertificateDelegationProcessor"),
+		},
 		&dag.GatewayAPIProcessor{
 			FieldLogger: log.WithField("context", "GatewayAPIProcessor"),
 		},
@@ -147,7 +150,7 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 	require.NoError(t, err)
 
 	srv := xds.NewServer(registry)
-	contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, xdscache.ResourcesOf(resources)...), srv)
+	contour_xds_v3.RegisterServer(contour_xds_v3.NewContourServer(log, nil, xdscache.ResourcesOf(resources)...), srv)
 
 	var g workgroup.Group
 
@@ -38,7 +38,7 @@ const (
 
 func TestIngressClassAnnotation_Configured(t *testing.T) {
 	rh, c, done := setup(t, func(reh *contour.EventHandler) {
-		reh.Builder.Source.IngressClassName = "linkerd"
+		reh.Builder.Source.IngressClassNames = []string{"linkerd"}
 	})
 	defer done()
 
@@ -503,7 +503,7 @@ func TestIngressClassAnnotation_NotConfigured(t *testing.T) {
 func TestIngressClassAnnotationUpdate(t *testing.T) {
 	t.Skip("Test disabled, see issue #2964")
 	rh, c, done := setup(t, func(reh *contour.EventHandler) {
-		reh.Builder.Source.IngressClassName = "contour"
+		reh.Builder.Source.IngressClassNames = []string{"contour"}
 	})
 	defer done()
 
@@ -566,7 +566,7 @@ func TestIngressClassAnnotationUpdate(t *testing.T) {
 
 func TestIngressClassResource_Configured(t *testing.T) {
 	rh, c, done := setup(t, func(reh *contour.EventHandler) {
-		reh.Builder.Source.IngressClassName = "testingressclass"
+		reh.Builder.Source.IngressClassNames = []string{"testingressclass"}
 	})
 	defer done()
 
@@ -84,3 +84,97 @@ func TestMirrorPolicy(t *testing.T) {
 		TypeUrl: clusterType,
 	})
 }
+
+func TestMirrorPolicyRequestHeaders(t *testing.T) {
+	rh, c, done := setup(t, func(reh *contour.EventHandler) {})
+	defer done()
+
+	svc1 := fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+	svc2 := fixture.NewService("mirror").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)})
+	rh.OnAdd(svc1)
+	rh.OnAdd(svc2)
+
+	p1 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple",
+			Namespace: svc1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{Fqdn: "example.com"},
+			Routes: []contour_api_v1.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/")),
+				Services: []contour_api_v1.Service{{
+					Name: svc1.Name,
+					Port: 8080,
+				}, {
+					Name:   svc2.Name,
+					Port:   8080,
+					Mirror: true,
+					MirrorPolicy: &contour_api_v1.MirrorPolicy{
+						RequestHeaders: []contour_api_v1.HeaderValue{{
+							Name:  "x-shadow",
+							Value: "true",
+						}},
+					},
+				}},
+			}},
+		},
+	}
+	rh.OnAdd(p1)
+
+	// Requests are mirrored to Envoy's mirror listener, which
+	// sets the header on their way to the mirror service. Envoy
+	// appends "-shadow" to the Host header of mirrored requests.
+	c.Request(routeType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			envoy_v3.RouteConfiguration("ingress_http",
+				envoy_v3.VirtualHost(p1.Spec.VirtualHost.Fqdn,
+					&envoy_route_v3.Route{
+						Match:  routePrefix("/"),
+						Action: withMirrorPolicy(routeCluster("default/kuard/8080/da39a3ee5e"), "contour_mirror"),
+					},
+				),
+			),
+			&envoy_route_v3.RouteConfiguration{
+				Name: "ingress_mirror",
+				VirtualHosts: []*envoy_route_v3.VirtualHost{
+					envoy_v3.VirtualHost("example.com-shadow",
+						&envoy_route_v3.Route{
+							Match:               routePrefix("/"),
+							Action:              withResponseTimeout(routeCluster("default/mirror/8080/da39a3ee5e"), 0),
+							RequestHeadersToAdd: envoy_v3.HeaderValueList(map[string]string{"X-Shadow": "true"}, false),
+						},
+					),
+				},
+			},
+		),
+		TypeUrl: routeType,
+	})
+
+	c.Request(clusterType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			envoy_v3.MirrorCluster("127.0.0.1", 8009),
+			cluster("default/kuard/8080/da39a3ee5e", "default/kuard", "default_kuard_8080"),
+			cluster("default/mirror/8080/da39a3ee5e", "default/mirror", "default_mirror_8080"),
+		),
+		TypeUrl: clusterType,
+	})
+
+	c.Request(listenerType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			defaultHTTPListener(),
+			envoy_v3.Listener("ingress_mirror", "127.0.0.1", 8009, nil,
+				envoy_v3.HTTPConnectionManagerBuilder().
+					DefaultFilters().
+					RouteConfigName("ingress_mirror").
+					MetricsPrefix("ingress_mirror").
+					SkipXffAppend(true).
+					Get(),
+			),
+			staticListener(),
+		),
+		TypeUrl: listenerType,
+	})
+}
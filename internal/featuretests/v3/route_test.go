@@ -934,7 +934,7 @@ func TestDefaultBackendIsOverriddenByNoHostIngressRule(t *testing.T) {
 // tested in internal/contour/route_test.go
 func TestRDSIngressClassAnnotation(t *testing.T) {
 	rh, c, done := setup(t, func(reh *contour.EventHandler) {
-		reh.Builder.Source.IngressClassName = "linkerd"
+		reh.Builder.Source.IngressClassNames = []string{"linkerd"}
 	})
 	defer done()
 
@@ -15,14 +15,19 @@ package v3
 
 import (
 	"testing"
+	"time"
 
 	envoy_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_tcp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	envoy_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/featuretests"
 	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/protobuf"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -103,6 +108,77 @@ func TestTCPProxy(t *testing.T) {
 	})
 }
 
+func TestTCPProxyDisableAccessLog(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	s1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Type: "kubernetes.io/tls",
+		Data: featuretests.Secretdata(featuretests.CERTIFICATE, featuretests.RSA_PRIVATE_KEY),
+	}
+
+	svc := fixture.NewService("backend").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)})
+
+	rh.OnAdd(s1)
+	rh.OnAdd(svc)
+
+	rh.OnAdd(&contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "kuard-tcp.example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: s1.Name,
+				},
+			},
+			TCPProxy: &contour_api_v1.TCPProxy{
+				Services: []contour_api_v1.Service{{
+					Name: svc.Name,
+					Port: 80,
+				}},
+				DisableAccessLog: true,
+			},
+		},
+	})
+
+	c.Request(listenerType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			&envoy_listener_v3.Listener{
+				Name:    "ingress_https",
+				Address: envoy_v3.SocketAddress("0.0.0.0", 8443),
+				FilterChains: appendFilterChains(
+					filterchaintls("kuard-tcp.example.com", s1, &envoy_listener_v3.Filter{
+						Name: wellknown.TCPProxy,
+						ConfigType: &envoy_listener_v3.Filter_TypedConfig{
+							TypedConfig: protobuf.MustMarshalAny(&envoy_tcp_proxy_v3.TcpProxy{
+								StatPrefix: "ingress_https",
+								ClusterSpecifier: &envoy_tcp_proxy_v3.TcpProxy_Cluster{
+									Cluster: "default/backend/80/da39a3ee5e",
+								},
+								IdleTimeout: protobuf.Duration(9001 * time.Second),
+							}),
+						},
+					}, nil),
+				),
+				ListenerFilters: envoy_v3.ListenerFilters(
+					envoy_v3.TLSInspector(),
+				),
+				SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+			},
+			staticListener(),
+		),
+		TypeUrl: listenerType,
+	})
+}
+
 func TestTCPProxyDelegation(t *testing.T) {
 	rh, c, done := setup(t)
 	defer done()
@@ -345,6 +421,93 @@ func TestTCPProxyTLSBackend(t *testing.T) {
 
 // Assert that TCPProxy + a http service can be used to expose a ingress_http
 // route on the same vhost that port ingress_https is tls passthrough + proxying.
+// Assert that tcp proxying to TLS backends validates the
+// backend's certificate when the service has an UpstreamValidation.
+func TestTCPProxyTLSBackendCAValidation(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	s1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8s-tls",
+			Namespace: "default",
+		},
+		Type: "kubernetes.io/tls",
+		Data: featuretests.Secretdata(featuretests.CERTIFICATE, featuretests.RSA_PRIVATE_KEY),
+	}
+
+	ca := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			dag.CACertificateKey: []byte(featuretests.CERTIFICATE),
+		},
+	}
+
+	svc := fixture.NewService("kuard").
+		Annotate("projectcontour.io/upstream-protocol.tls", "securebackend,443").
+		WithPorts(v1.ServicePort{Name: "securebackend", Port: 443, TargetPort: intstr.FromInt(8080)})
+
+	hp1 := &contour_api_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple",
+			Namespace: s1.Namespace,
+		},
+		Spec: contour_api_v1.HTTPProxySpec{
+			VirtualHost: &contour_api_v1.VirtualHost{
+				Fqdn: "kuard-tcp.example.com",
+				TLS: &contour_api_v1.TLS{
+					SecretName: s1.Name,
+				},
+			},
+			TCPProxy: &contour_api_v1.TCPProxy{
+				Services: []contour_api_v1.Service{{
+					Name: svc.Name,
+					Port: 443,
+					UpstreamValidation: &contour_api_v1.UpstreamValidation{
+						CACertificate: ca.Name,
+						SubjectName:   "subjname",
+					},
+				}},
+			},
+		},
+	}
+
+	rh.OnAdd(s1)
+	rh.OnAdd(ca)
+	rh.OnAdd(svc)
+	rh.OnAdd(hp1)
+
+	c.Request(listenerType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			&envoy_listener_v3.Listener{
+				Name:    "ingress_https",
+				Address: envoy_v3.SocketAddress("0.0.0.0", 8443),
+				FilterChains: appendFilterChains(
+					filterchaintls("kuard-tcp.example.com", s1,
+						tcpproxy("ingress_https", "default/kuard/443/98c0f31c72"), nil),
+				),
+				ListenerFilters: envoy_v3.ListenerFilters(
+					envoy_v3.TLSInspector(),
+				),
+				SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+			},
+			staticListener(),
+		),
+		TypeUrl: listenerType,
+	})
+
+	// assert that the cluster has a certificate and subject name.
+	c.Request(clusterType).Equals(&envoy_discovery_v3.DiscoveryResponse{
+		Resources: resources(t,
+			tlsCluster(cluster("default/kuard/443/98c0f31c72", "default/kuard/securebackend", "default_kuard_443"), []byte(featuretests.CERTIFICATE), "subjname", "", nil),
+		),
+		TypeUrl: clusterType,
+	})
+}
+
 func TestTCPProxyAndHTTPService(t *testing.T) {
 	rh, c, done := setup(t)
 	defer done()
@@ -0,0 +1,385 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package geoip looks up the country and autonomous system of client
+// addresses in MaxMind DB files, and serves them to Envoy as request
+// headers.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+)
+
+// metadataStart marks the start of the metadata section, which is
+// at the end of a MaxMind DB file.
+var metadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+// maxDepth limits how deeply maps, arrays and pointers may be nested
+// in the data section, so that a corrupt file can't loop forever.
+const maxDepth = 32
+
+// Types of the values in the data section.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// Database is a MaxMind DB file, such as a GeoLite2 Country or
+// ASN database, held in memory. See
+// https://maxmind.github.io/MaxMind-DB/ for the format.
+type Database struct {
+	// Type is the database type of the file,
+	// e.g. "GeoLite2-Country".
+	Type string
+
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+}
+
+// Open reads the MaxMind DB file at path.
+func Open(path string) (*Database, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := New(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// New returns the Database held by buf.
+func New(buf []byte) (*Database, error) {
+	i := bytes.LastIndex(buf, metadataStart)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB file")
+	}
+
+	v, _, err := decoder(buf[i+len(metadataStart):]).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	meta, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid metadata: not a map")
+	}
+
+	db := &Database{
+		nodeCount:  uintValue(meta["node_count"]),
+		recordSize: uintValue(meta["record_size"]),
+		ipVersion:  uintValue(meta["ip_version"]),
+	}
+	db.Type, _ = meta["database_type"].(string)
+
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", db.recordSize)
+	}
+	switch db.ipVersion {
+	case 4, 6:
+	default:
+		return nil, fmt.Errorf("unsupported IP version %d", db.ipVersion)
+	}
+
+	// Each node holds two records, and the search tree is
+	// followed by 16 zero bytes before the data section.
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, errors.New("search tree is truncated")
+	}
+	db.tree = buf[:treeSize]
+	db.data = buf[treeSize+16 : i]
+
+	// IPv4 addresses are held at ::a.b.c.d in IPv6 databases,
+	// so their search starts after 96 zero bits.
+	if db.ipVersion == 6 {
+		for n := 0; n < 96 && db.ipv4Start < db.nodeCount; n++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+
+	return db, nil
+}
+
+// Lookup returns the record of the network that holds ip, or nil
+// if the database has none.
+func (db *Database) Lookup(ip net.IP) (map[string]interface{}, error) {
+	var addr []byte
+	var node uint
+	if ip4 := ip.To4(); ip4 != nil {
+		addr, node = ip4, db.ipv4Start
+	} else if ip16 := ip.To16(); ip16 != nil && db.ipVersion == 6 {
+		addr = ip16
+	} else {
+		return nil, nil
+	}
+
+	for i := 0; i < len(addr)*8 && node < db.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+
+	switch {
+	case node == db.nodeCount:
+		return nil, nil
+	case node < db.nodeCount:
+		return nil, fmt.Errorf("no record for %s", ip)
+	}
+
+	offset := node - db.nodeCount - 16
+	v, _, err := decoder(db.data).decode(offset, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid record for %s: %w", ip, err)
+	}
+	record, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid record for %s: not a map", ip)
+	}
+	return record, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (db *Database) record(node, bit uint) uint {
+	b := db.tree[node*db.recordSize/4:]
+
+	switch db.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// decoder decodes the values of a data section.
+type decoder []byte
+
+// decode returns the value at offset, and the offset that follows it.
+func (d decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("values are nested too deeply")
+	}
+
+	ctrl, err := d.bytes(offset, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset++
+
+	typ := uint(ctrl[0] >> 5)
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl[0], offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr, depth+1)
+		return v, next, err
+	}
+	if typ == typeExtended {
+		ext, err := d.bytes(offset, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset++
+		typ = 7 + uint(ext[0])
+	}
+
+	size, offset, err := d.size(ctrl[0], offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var k, v interface{}
+			if k, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			if v, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var v interface{}
+			if v, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid boolean size %d", size)
+		}
+		return size == 1, offset, nil
+	}
+
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if (typ == typeUint16 && size > 2) || (typ == typeUint32 && size > 4) || size > 8 {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size %d", size)
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid signed integer size %d", size)
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), offset, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size %d", size)
+		}
+		return new(big.Int).SetBytes(b), offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
+
+// pointer returns the offset that the pointer with the control byte
+// ctrl points to, and the offset that follows the pointer.
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var ptr uint
+	if n < 4 {
+		ptr = uint(ctrl & 0x7)
+	}
+	for _, c := range b {
+		ptr = ptr<<8 | uint(c)
+	}
+
+	switch n {
+	case 2:
+		ptr += 2048
+	case 3:
+		ptr += 526336
+	}
+	return ptr, offset + n, nil
+}
+
+// size returns the size of the value with the control byte ctrl,
+// and the offset that follows it.
+func (d decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	var extra uint
+	for _, c := range b {
+		extra = extra<<8 | uint(c)
+	}
+
+	switch n {
+	case 1:
+		size = 29 + extra
+	case 2:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+	return size, offset + n, nil
+}
+
+// bytes returns the n bytes at offset.
+func (d decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d)) || offset+n < offset {
+		return nil, errors.New("unexpected end of data")
+	}
+	return d[offset : offset+n], nil
+}
+
+// uintValue returns v if it is an unsigned integer, otherwise 0.
+func uintValue(v interface{}) uint {
+	n, _ := v.(uint64)
+	return uint(n)
+}
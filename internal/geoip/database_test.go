@@ -0,0 +1,317 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geoip
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pointer is a value that is encoded as a pointer to
+// the given offset of the data section.
+type pointer uint
+
+// network is a network of a test database and its record.
+type network struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+// encode returns the data section encoding of v.
+func encode(v interface{}) []byte {
+	ctrl := func(typ, size int) []byte {
+		var b []byte
+		switch {
+		case size < 29:
+			b = []byte{byte(size)}
+		case size < 285:
+			b = []byte{29, byte(size - 29)}
+		default:
+			b = []byte{30, byte((size - 285) >> 8), byte(size - 285)}
+		}
+		if typ < 8 {
+			b[0] |= byte(typ << 5)
+			return b
+		}
+		return append([]byte{b[0], byte(typ - 7)}, b[1:]...)
+	}
+
+	switch v := v.(type) {
+	case pointer:
+		return []byte{byte(typePointer<<5) | byte(v>>8)&0x7, byte(v)}
+	case string:
+		return append(ctrl(typeString, len(v)), v...)
+	case bool:
+		if v {
+			return ctrl(typeBool, 1)
+		}
+		return ctrl(typeBool, 0)
+	case uint16:
+		return append(ctrl(typeUint16, 2), byte(v>>8), byte(v))
+	case uint32:
+		return append(ctrl(typeUint32, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case []interface{}:
+		b := ctrl(typeArray, len(v))
+		for _, e := range v {
+			b = append(b, encode(e)...)
+		}
+		return b
+	case map[string]interface{}:
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b := ctrl(typeMap, len(v))
+		for _, k := range keys {
+			b = append(b, encode(k)...)
+			b = append(b, encode(v[k])...)
+		}
+		return b
+	default:
+		panic(fmt.Sprintf("can't encode %T", v))
+	}
+}
+
+// trieNode is a node of the search tree of a test database. Leaf
+// nodes hold the offset of a record in the data section.
+type trieNode struct {
+	children [2]*trieNode
+	leaf     bool
+	offset   int
+	number   int
+}
+
+// database returns a MaxMind DB file with the given record size and IP
+// version. The data section starts with the encoding of shared, which
+// records can point to.
+func database(t *testing.T, recordSize, ipVersion int, shared interface{}, networks ...network) []byte {
+	t.Helper()
+
+	var data []byte
+	if shared != nil {
+		data = encode(shared)
+	}
+
+	root := &trieNode{}
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		require.NoError(t, err)
+
+		addr := []byte(ipnet.IP)
+		ones, _ := ipnet.Mask.Size()
+		if ipVersion == 6 && len(addr) == net.IPv4len {
+			addr = append(make([]byte, 12), addr...)
+			ones += 96
+		}
+
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := addr[i/8] >> (7 - uint(i%8)) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{}
+			}
+			node = node.children[bit]
+		}
+		node.leaf = true
+		node.offset = len(data)
+		data = append(data, encode(n.record)...)
+	}
+
+	// Number the nodes breadth first, leaving out the leaves.
+	var nodes []*trieNode
+	for queue := []*trieNode{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		if n.leaf {
+			continue
+		}
+		n.number = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	value := func(n *trieNode) uint32 {
+		switch {
+		case n == nil:
+			return uint32(len(nodes))
+		case n.leaf:
+			return uint32(len(nodes) + 16 + n.offset)
+		default:
+			return uint32(n.number)
+		}
+	}
+
+	var buf []byte
+	for _, n := range nodes {
+		left, right := value(n.children[0]), value(n.children[1])
+		switch recordSize {
+		case 24:
+			buf = append(buf, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			buf = append(buf, byte(left>>16), byte(left>>8), byte(left), byte(left>>20)&0xf0|byte(right>>24)&0x0f, byte(right>>16), byte(right>>8), byte(right))
+		default:
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint32(b, left)
+			binary.BigEndian.PutUint32(b[4:], right)
+			buf = append(buf, b...)
+		}
+	}
+
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataStart...)
+	buf = append(buf, encode(map[string]interface{}{
+		"node_count":    uint32(len(nodes)),
+		"record_size":   uint16(recordSize),
+		"ip_version":    uint16(ipVersion),
+		"database_type": "Test-Country",
+		"languages":     []interface{}{"en"},
+	})...)
+
+	return buf
+}
+
+func TestLookup(t *testing.T) {
+	longName := strings.Repeat("x", 300)
+
+	networks := []network{{
+		cidr: "192.0.2.0/24",
+		record: map[string]interface{}{
+			"country": map[string]interface{}{
+				"iso_code":             "DE",
+				"is_in_european_union": true,
+			},
+		},
+	}, {
+		cidr: "198.51.100.128/25",
+		record: map[string]interface{}{
+			"registered_country": pointer(0),
+			"names": map[string]interface{}{
+				"en": longName,
+			},
+		},
+	}}
+	shared := map[string]interface{}{
+		"iso_code": "FR",
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, ipVersion := range []int{4, 6} {
+			t.Run(fmt.Sprintf("record size %d IPv%d", recordSize, ipVersion), func(t *testing.T) {
+				nets := networks
+				if ipVersion == 6 {
+					nets = append(nets, network{
+						cidr: "2001:db8::/32",
+						record: map[string]interface{}{
+							"country": pointer(0),
+						},
+					})
+				}
+
+				db, err := New(database(t, recordSize, ipVersion, shared, nets...))
+				require.NoError(t, err)
+				assert.Equal(t, "Test-Country", db.Type)
+
+				record, err := db.Lookup(net.ParseIP("192.0.2.10"))
+				require.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"country": map[string]interface{}{
+						"iso_code":             "DE",
+						"is_in_european_union": true,
+					},
+				}, record)
+
+				record, err = db.Lookup(net.ParseIP("::ffff:198.51.100.200"))
+				require.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"registered_country": map[string]interface{}{
+						"iso_code": "FR",
+					},
+					"names": map[string]interface{}{
+						"en": longName,
+					},
+				}, record)
+
+				for _, ip := range []string{"198.51.100.1", "203.0.113.1", "2001:db9::1"} {
+					record, err = db.Lookup(net.ParseIP(ip))
+					require.NoError(t, err)
+					assert.Nil(t, record, ip)
+				}
+
+				record, err = db.Lookup(net.ParseIP("2001:db8::1"))
+				require.NoError(t, err)
+				if ipVersion == 6 {
+					assert.Equal(t, map[string]interface{}{
+						"country": map[string]interface{}{
+							"iso_code": "FR",
+						},
+					}, record)
+				} else {
+					assert.Nil(t, record)
+				}
+			})
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New([]byte("not a database"))
+	assert.Error(t, err)
+
+	_, err = New(database(t, 20, 4, nil))
+	assert.Error(t, err)
+
+	_, err = New(database(t, 24, 5, nil))
+	assert.Error(t, err)
+
+	truncated := append(append([]byte(nil), metadataStart...), encode(map[string]interface{}{
+		"node_count":  uint32(10),
+		"record_size": uint16(24),
+		"ip_version":  uint16(4),
+	})...)
+	_, err = New(truncated)
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asn.mmdb")
+	require.NoError(t, ioutil.WriteFile(path, database(t, 24, 6, nil, network{
+		cidr:   "192.0.2.0/24",
+		record: map[string]interface{}{"autonomous_system_number": uint32(64496)},
+	}), 0o600))
+
+	db, err := Open(path)
+	require.NoError(t, err)
+
+	record, err := db.Lookup(net.ParseIP("192.0.2.1"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"autonomous_system_number": uint64(64496)}, record)
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
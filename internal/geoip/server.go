@@ -0,0 +1,132 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geoip
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// CountryHeader is the request header that holds the ISO 3166-1
+	// alpha-2 code of the country of the client address, e.g. "DE".
+	CountryHeader = "x-contour-geo-country"
+
+	// ASNHeader is the request header that holds the number of the
+	// autonomous system of the client address, e.g. "64496".
+	ASNHeader = "x-contour-geo-asn"
+)
+
+// Server is an Envoy external authorization service that allows every
+// request, and sets the country and ASN headers of the client address.
+// Headers that can't be looked up are removed, so that clients can't
+// set them.
+type Server struct {
+	// Country is the database that countries are looked up
+	// in, e.g. GeoLite2 Country or City. If nil, the country
+	// header is always removed.
+	Country *Database
+
+	// ASN is the database that autonomous systems are looked
+	// up in, e.g. GeoLite2 ASN. If nil, the ASN header is
+	// always removed.
+	ASN *Database
+
+	logrus.FieldLogger
+}
+
+// Register registers the Server with the gRPC server g.
+func (s *Server) Register(g *grpc.Server) {
+	envoy_service_auth_v3.RegisterAuthorizationServer(g, s)
+}
+
+// Check implements envoy_service_auth_v3.AuthorizationServer. The client
+// address is the source address of the request, which is taken from
+// the X-Forwarded-For header if Envoy trusts it.
+func (s *Server) Check(ctx context.Context, req *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error) {
+	ip := net.ParseIP(req.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress())
+
+	resp := &envoy_service_auth_v3.OkHttpResponse{}
+	setHeader(resp, CountryHeader, s.country(ip))
+	setHeader(resp, ASNHeader, s.asn(ip))
+
+	return &envoy_service_auth_v3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &envoy_service_auth_v3.CheckResponse_OkResponse{
+			OkResponse: resp,
+		},
+	}, nil
+}
+
+// country returns the country code of ip, or "" if it is not known.
+// The country of the network is preferred to the country that the
+// network is registered in.
+func (s *Server) country(ip net.IP) string {
+	record := s.lookup(s.Country, ip)
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := record[key].(map[string]interface{}); ok {
+			if code, ok := c["iso_code"].(string); ok && code != "" {
+				return code
+			}
+		}
+	}
+	return ""
+}
+
+// asn returns the autonomous system number of ip, or "" if it is
+// not known.
+func (s *Server) asn(ip net.IP) string {
+	record := s.lookup(s.ASN, ip)
+	if n, ok := record["autonomous_system_number"].(uint64); ok {
+		return strconv.FormatUint(n, 10)
+	}
+	return ""
+}
+
+// lookup returns the record of ip in db, or nil if there is none.
+func (s *Server) lookup(db *Database, ip net.IP) map[string]interface{} {
+	if db == nil || ip == nil {
+		return nil
+	}
+	record, err := db.Lookup(ip)
+	if err != nil {
+		s.WithError(err).WithField("database", db.Type).Error("failed to look up client address")
+	}
+	return record
+}
+
+// setHeader sets the header name to value, or removes
+// it if value is empty.
+func setHeader(resp *envoy_service_auth_v3.OkHttpResponse, name, value string) {
+	if value == "" {
+		resp.HeadersToRemove = append(resp.HeadersToRemove, name)
+		return
+	}
+	resp.Headers = append(resp.Headers, &envoy_core_v3.HeaderValueOption{
+		Header: &envoy_core_v3.HeaderValue{
+			Key:   name,
+			Value: value,
+		},
+		Append: protobuf.Bool(false),
+	})
+}
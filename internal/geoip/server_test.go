@@ -0,0 +1,143 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geoip
+
+import (
+	"context"
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+func TestServerCheck(t *testing.T) {
+	country, err := New(database(t, 24, 6, nil, network{
+		cidr: "192.0.2.0/24",
+		record: map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": "DE"},
+		},
+	}, network{
+		cidr: "2001:db8::/32",
+		record: map[string]interface{}{
+			"registered_country": map[string]interface{}{"iso_code": "FR"},
+		},
+	}))
+	require.NoError(t, err)
+
+	asn, err := New(database(t, 24, 4, nil, network{
+		cidr: "192.0.2.0/25",
+		record: map[string]interface{}{
+			"autonomous_system_number":       uint32(64496),
+			"autonomous_system_organization": "Example",
+		},
+	}))
+	require.NoError(t, err)
+
+	header := func(name, value string) *envoy_core_v3.HeaderValueOption {
+		return &envoy_core_v3.HeaderValueOption{
+			Header: &envoy_core_v3.HeaderValue{
+				Key:   name,
+				Value: value,
+			},
+			Append: protobuf.Bool(false),
+		}
+	}
+
+	tests := map[string]struct {
+		server  *Server
+		address string
+		want    *envoy_service_auth_v3.OkHttpResponse
+	}{
+		"country and ASN": {
+			server:  &Server{Country: country, ASN: asn},
+			address: "192.0.2.1",
+			want: &envoy_service_auth_v3.OkHttpResponse{
+				Headers: []*envoy_core_v3.HeaderValueOption{
+					header(CountryHeader, "DE"),
+					header(ASNHeader, "64496"),
+				},
+			},
+		},
+		"registered country": {
+			server:  &Server{Country: country, ASN: asn},
+			address: "2001:db8::1",
+			want: &envoy_service_auth_v3.OkHttpResponse{
+				Headers: []*envoy_core_v3.HeaderValueOption{
+					header(CountryHeader, "FR"),
+				},
+				HeadersToRemove: []string{ASNHeader},
+			},
+		},
+		"unknown address": {
+			server:  &Server{Country: country, ASN: asn},
+			address: "203.0.113.1",
+			want: &envoy_service_auth_v3.OkHttpResponse{
+				HeadersToRemove: []string{CountryHeader, ASNHeader},
+			},
+		},
+		"no address": {
+			server: &Server{Country: country, ASN: asn},
+			want: &envoy_service_auth_v3.OkHttpResponse{
+				HeadersToRemove: []string{CountryHeader, ASNHeader},
+			},
+		},
+		"country database only": {
+			server:  &Server{Country: country},
+			address: "192.0.2.1",
+			want: &envoy_service_auth_v3.OkHttpResponse{
+				Headers: []*envoy_core_v3.HeaderValueOption{
+					header(CountryHeader, "DE"),
+				},
+				HeadersToRemove: []string{ASNHeader},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.server.FieldLogger = fixture.NewTestLogger(t)
+
+			req := &envoy_service_auth_v3.CheckRequest{
+				Attributes: &envoy_service_auth_v3.AttributeContext{
+					Source: &envoy_service_auth_v3.AttributeContext_Peer{
+						Address: &envoy_core_v3.Address{
+							Address: &envoy_core_v3.Address_SocketAddress{
+								SocketAddress: &envoy_core_v3.SocketAddress{
+									Address: tc.address,
+									PortSpecifier: &envoy_core_v3.SocketAddress_PortValue{
+										PortValue: 40000,
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			got, err := tc.server.Check(context.Background(), req)
+			require.NoError(t, err)
+			protobuf.ExpectEqual(t, &envoy_service_auth_v3.CheckResponse{
+				Status: &status.Status{Code: int32(codes.OK)},
+				HttpResponse: &envoy_service_auth_v3.CheckResponse_OkResponse{
+					OkResponse: tc.want,
+				},
+			}, got)
+		})
+	}
+}
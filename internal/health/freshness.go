@@ -0,0 +1,139 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	utilclock "k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Freshness tracks whether the Kubernetes resources observed by
+// Contour have been reflected into the current xDS snapshot.
+//
+// Changes move through three stages: they are observed by the
+// event handler, included in a DAG rebuild, and then written to
+// the xDS snapshot. Freshness counts the changes that have reached
+// each stage, and remembers when the oldest change that is not yet
+// in the snapshot was observed.
+type Freshness struct {
+	// Clock times how long changes have been outstanding.
+	// If nil, the real clock is used.
+	Clock utilclock.Clock
+
+	mu sync.Mutex
+
+	// observed, built and synced count the changes that have
+	// been observed, included in a DAG rebuild, and written to
+	// the xDS snapshot. synced <= built <= observed.
+	observed, built, synced uint64
+
+	// staleSince is the time the oldest change not yet written
+	// to the xDS snapshot was observed.
+	staleSince time.Time
+
+	// unbuiltSince is the time the oldest change not yet
+	// included in a DAG rebuild was observed.
+	unbuiltSince time.Time
+
+	// observedVersion, builtVersion and syncedVersion are the
+	// resource versions of the latest change to reach each stage.
+	observedVersion, builtVersion, syncedVersion string
+}
+
+// Observe records a change to the Kubernetes object obj.
+func (f *Freshness) Observe(obj interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.observed++
+	if f.observed == f.built+1 {
+		f.unbuiltSince = f.clock().Now()
+	}
+	if f.observed == f.synced+1 {
+		f.staleSince = f.clock().Now()
+	}
+
+	if m, err := meta.Accessor(obj); err == nil {
+		f.observedVersion = m.GetResourceVersion()
+	}
+}
+
+// Built records that all the changes observed so far are
+// included in the DAG being rebuilt.
+func (f *Freshness) Built() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.built = f.observed
+	f.builtVersion = f.observedVersion
+	f.unbuiltSince = time.Time{}
+}
+
+// Synced records that the latest DAG rebuild has been
+// written to the xDS snapshot.
+func (f *Freshness) Synced() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.synced = f.built
+	f.syncedVersion = f.builtVersion
+	if f.synced == f.observed {
+		f.staleSince = time.Time{}
+	} else {
+		f.staleSince = f.unbuiltSince
+	}
+}
+
+// Check returns an error if the oldest change not yet written
+// to the xDS snapshot has been outstanding for longer than threshold.
+func (f *Freshness) Check(threshold time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.staleSince.IsZero() {
+		return nil
+	}
+	if stale := f.clock().Since(f.staleSince); stale > threshold {
+		return fmt.Errorf("resource version %q observed but xDS snapshot is at %q, stale for %s (threshold %s)",
+			f.observedVersion, f.syncedVersion, stale.Round(time.Millisecond), threshold)
+	}
+	return nil
+}
+
+func (f *Freshness) clock() utilclock.Clock {
+	if f.Clock == nil {
+		return utilclock.RealClock{}
+	}
+	return f.Clock
+}
+
+// ReadinessHandler returns a http Handler for a readiness endpoint that
+// fails while the xDS snapshot has been behind the observed Kubernetes
+// resources for longer than threshold.
+func ReadinessHandler(f *Freshness, threshold time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := f.Check(threshold); err != nil {
+			msg := fmt.Sprintf("Failed xDS Freshness Check: %v", err)
+			http.Error(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "OK")
+	})
+}
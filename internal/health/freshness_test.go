@@ -0,0 +1,210 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilclock "k8s.io/apimachinery/pkg/util/clock"
+)
+
+const threshold = 10 * time.Second
+
+// fakeClock returns a fake clock for a Freshness.
+func fakeClock() *utilclock.FakeClock {
+	return utilclock.NewFakeClock(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// object returns a Kubernetes object with the given resource version.
+func object(version string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "kuard",
+			Namespace:       "default",
+			ResourceVersion: version,
+		},
+	}
+}
+
+func TestFreshnessCheck(t *testing.T) {
+	tests := map[string]struct {
+		// run drives f, stepping the clock as it goes.
+		run     func(f *Freshness, c *utilclock.FakeClock)
+		wantErr string
+	}{
+		"no changes": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				c.Step(time.Hour)
+			},
+		},
+		"fresh": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				f.Built()
+				f.Synced()
+				c.Step(time.Hour)
+			},
+		},
+		"outstanding within the threshold": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				c.Step(threshold)
+			},
+		},
+		"stale": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				c.Step(threshold + time.Second)
+			},
+			wantErr: `resource version "1" observed but xDS snapshot is at "", stale for 11s (threshold 10s)`,
+		},
+		"stale while building": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				f.Built()
+				c.Step(threshold + time.Second)
+			},
+			wantErr: `resource version "1" observed but xDS snapshot is at "", stale for 11s (threshold 10s)`,
+		},
+		"stale since the oldest change": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				c.Step(threshold)
+				f.Observe(object("2"))
+				c.Step(time.Second)
+			},
+			wantErr: `resource version "2" observed but xDS snapshot is at "", stale for 11s (threshold 10s)`,
+		},
+		"recovered": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				c.Step(threshold + time.Second)
+				f.Built()
+				f.Synced()
+			},
+		},
+		"partly synced": {
+			// The second change is observed while the first is
+			// being built, so only it is outstanding once the
+			// snapshot is written.
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				f.Built()
+				c.Step(threshold)
+				f.Observe(object("2"))
+				f.Synced()
+				c.Step(time.Second)
+			},
+		},
+		"partly synced and stale": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				f.Built()
+				c.Step(time.Second)
+				f.Observe(object("2"))
+				f.Synced()
+				c.Step(threshold + time.Second)
+			},
+			wantErr: `resource version "2" observed but xDS snapshot is at "1", stale for 11s (threshold 10s)`,
+		},
+		"partly synced and recovered": {
+			run: func(f *Freshness, c *utilclock.FakeClock) {
+				f.Observe(object("1"))
+				f.Built()
+				f.Observe(object("2"))
+				f.Synced()
+				c.Step(threshold + time.Second)
+				f.Built()
+				f.Synced()
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := fakeClock()
+
+			f := Freshness{Clock: c}
+			tc.run(&f, c)
+
+			err := f.Check(threshold)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	c := fakeClock()
+
+	f := Freshness{Clock: c}
+	handler := ReadinessHandler(&f, threshold)
+
+	check := func(wantCode int) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		assert.Equal(t, wantCode, rec.Code)
+	}
+
+	check(http.StatusOK)
+
+	f.Observe(object("1"))
+	c.Step(threshold + time.Second)
+	check(http.StatusServiceUnavailable)
+
+	f.Built()
+	f.Synced()
+	check(http.StatusOK)
+}
+
+// TestFreshnessConcurrent drives a Freshness from several goroutines,
+// as the event handler, the snapshot handler and the readiness probe
+// do. Run it with -race.
+func TestFreshnessConcurrent(t *testing.T) {
+	f := Freshness{Clock: fakeClock()}
+	var wg sync.WaitGroup
+
+	run := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				fn()
+			}
+		}()
+	}
+
+	run(func() { f.Observe(object("1")) })
+	run(f.Built)
+	run(f.Synced)
+	run(func() { _ = f.Check(threshold) })
+	wg.Wait()
+
+	f.Built()
+	f.Synced()
+	assert.NoError(t, f.Check(threshold))
+	assert.Equal(t, f.observed, f.synced)
+}
@@ -25,35 +25,68 @@ import (
 // configured.
 const DefaultClassName = "contour"
 
+// DefaultClassAnnotation is the annotation that marks an IngressClass
+// as the cluster's default ingress class.
+const DefaultClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
 // MatchesIngress returns true if the passed in Ingress annotations
-// or Spec.IngressClassName match the passed in ingress class name.
+// or Spec.IngressClassName match one of the passed in ingress class names.
 // Annotations take precedence over spec field if both are set.
-func MatchesIngress(obj *networking_v1.Ingress, ingressClassName string) bool {
+func MatchesIngress(obj *networking_v1.Ingress, ingressClassNames []string) bool {
 	if annotationClass := annotation.IngressClass(obj); annotationClass != "" {
-		return matches(annotationClass, ingressClassName)
+		return matches(annotationClass, ingressClassNames)
 	}
 
-	return matches(pointer.StringPtrDerefOr(obj.Spec.IngressClassName, ""), ingressClassName)
+	return matches(pointer.StringPtrDerefOr(obj.Spec.IngressClassName, ""), ingressClassNames)
 }
 
 // MatchesHTTPProxy returns true if the passed in HTTPProxy annotations
-// or Spec.IngressClassName match the passed in ingress class name.
+// or Spec.IngressClassName match one of the passed in ingress class names.
 // Annotations take precedence over spec field if both are set.
-func MatchesHTTPProxy(obj *contour_v1.HTTPProxy, ingressClassName string) bool {
+func MatchesHTTPProxy(obj *contour_v1.HTTPProxy, ingressClassNames []string) bool {
 	if annotationClass := annotation.IngressClass(obj); annotationClass != "" {
-		return matches(annotationClass, ingressClassName)
+		return matches(annotationClass, ingressClassNames)
+	}
+
+	return matches(obj.Spec.IngressClassName, ingressClassNames)
+}
+
+// MatchesIngressClass returns true if the passed in IngressClass is
+// named by one of the passed in ingress class names. If no ingress
+// class names are passed, only the default class name matches.
+func MatchesIngressClass(obj *networking_v1.IngressClass, ingressClassNames []string) bool {
+	if len(ingressClassNames) == 0 {
+		return obj.Name == DefaultClassName
 	}
 
-	return matches(obj.Spec.IngressClassName, ingressClassName)
+	return matches(obj.Name, ingressClassNames)
 }
 
-func matches(objIngressClass, contourIngressClass string) bool {
-	// If Contour's configured ingress class is empty, the object can either
+// HasIngressClass returns true if the passed in Ingress names an ingress
+// class, either by annotation or by Spec.IngressClassName.
+func HasIngressClass(obj *networking_v1.Ingress) bool {
+	return annotation.IngressClass(obj) != "" || pointer.StringPtrDerefOr(obj.Spec.IngressClassName, "") != ""
+}
+
+// IsDefault returns true if the passed in IngressClass is marked as the
+// cluster's default ingress class. Ingresses that do not name an ingress
+// class belong to the default class.
+func IsDefault(obj *networking_v1.IngressClass) bool {
+	return obj.Annotations[DefaultClassAnnotation] == "true"
+}
+
+func matches(objIngressClass string, contourIngressClasses []string) bool {
+	// If Contour has no configured ingress classes, the object can either
 	// not have an ingress class, or can have a "contour" ingress class.
-	if contourIngressClass == "" {
+	if len(contourIngressClasses) == 0 {
 		return objIngressClass == "" || objIngressClass == DefaultClassName
 	}
 
-	// Otherwise, the object's ingress class must match Contour's.
-	return objIngressClass == contourIngressClass
+	// Otherwise, the object's ingress class must match one of Contour's.
+	for _, class := range contourIngressClasses {
+		if objIngressClass == class {
+			return true
+		}
+	}
+	return false
 }
@@ -25,7 +25,7 @@ import (
 
 func TestMatchesIngress(t *testing.T) {
 	// No annotation, no spec field set, class not configured
-	assert.True(t, MatchesIngress(&networking_v1.Ingress{}, ""))
+	assert.True(t, MatchesIngress(&networking_v1.Ingress{}, nil))
 	// Annotation set to default, no spec field set, class not configured
 	assert.True(t, MatchesIngress(&networking_v1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
@@ -33,13 +33,13 @@ func TestMatchesIngress(t *testing.T) {
 				"kubernetes.io/ingress.class": "contour",
 			},
 		},
-	}, ""))
+	}, nil))
 	// No annotation set, spec field set to default, class not configured
 	assert.True(t, MatchesIngress(&networking_v1.Ingress{
 		Spec: networking_v1.IngressSpec{
 			IngressClassName: pointer.StringPtr("contour"),
 		},
-	}, ""))
+	}, nil))
 	// Annotation set, no spec field set, class not configured
 	assert.False(t, MatchesIngress(&networking_v1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
@@ -47,15 +47,15 @@ func TestMatchesIngress(t *testing.T) {
 				"kubernetes.io/ingress.class": "foo",
 			},
 		},
-	}, ""))
+	}, nil))
 	// No annotation set, spec field set, class not configured
 	assert.False(t, MatchesIngress(&networking_v1.Ingress{
 		Spec: networking_v1.IngressSpec{
 			IngressClassName: pointer.StringPtr("aclass"),
 		},
-	}, ""))
+	}, nil))
 	// No annotation, no spec field set, class configured
-	assert.False(t, MatchesIngress(&networking_v1.Ingress{}, "something"))
+	assert.False(t, MatchesIngress(&networking_v1.Ingress{}, []string{"something"}))
 	// Annotation set, no spec field set, class configured
 	assert.True(t, MatchesIngress(&networking_v1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
@@ -63,13 +63,13 @@ func TestMatchesIngress(t *testing.T) {
 				"kubernetes.io/ingress.class": "something",
 			},
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// No annotation set, spec field set, class configured
 	assert.True(t, MatchesIngress(&networking_v1.Ingress{
 		Spec: networking_v1.IngressSpec{
 			IngressClassName: pointer.StringPtr("something"),
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// Annotation set, no spec field set, class configured
 	assert.False(t, MatchesIngress(&networking_v1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
@@ -77,13 +77,13 @@ func TestMatchesIngress(t *testing.T) {
 				"kubernetes.io/ingress.class": "foo",
 			},
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// No annotation set, spec field set, class configured
 	assert.False(t, MatchesIngress(&networking_v1.Ingress{
 		Spec: networking_v1.IngressSpec{
 			IngressClassName: pointer.StringPtr("aclass"),
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// Annotation set, spec field set, class configured
 	assert.True(t, MatchesIngress(&networking_v1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
@@ -94,7 +94,7 @@ func TestMatchesIngress(t *testing.T) {
 		Spec: networking_v1.IngressSpec{
 			IngressClassName: pointer.StringPtr("aclass"),
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// Annotation set, spec field set, class configured
 	assert.False(t, MatchesIngress(&networking_v1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
@@ -105,12 +105,12 @@ func TestMatchesIngress(t *testing.T) {
 		Spec: networking_v1.IngressSpec{
 			IngressClassName: pointer.StringPtr("something"),
 		},
-	}, "something"))
+	}, []string{"something"}))
 }
 
 func TestMatchesHTTPProxy(t *testing.T) {
 	// No annotation, no spec field set, class not configured
-	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{}, ""))
+	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{}, nil))
 	// Annotation set to default, no spec field set, class not configured
 	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -118,13 +118,13 @@ func TestMatchesHTTPProxy(t *testing.T) {
 				"kubernetes.io/ingress.class": "contour",
 			},
 		},
-	}, ""))
+	}, nil))
 	// No annotation set, spec field set to default, class not configured
 	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		Spec: contour_v1.HTTPProxySpec{
 			IngressClassName: "contour",
 		},
-	}, ""))
+	}, nil))
 	// Annotation set, no spec field set, class not configured
 	assert.False(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -132,15 +132,15 @@ func TestMatchesHTTPProxy(t *testing.T) {
 				"kubernetes.io/ingress.class": "foo",
 			},
 		},
-	}, ""))
+	}, nil))
 	// No annotation set, spec field set, class not configured
 	assert.False(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		Spec: contour_v1.HTTPProxySpec{
 			IngressClassName: "aclass",
 		},
-	}, ""))
+	}, nil))
 	// No annotation, no spec field set, class configured
-	assert.False(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{}, "something"))
+	assert.False(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{}, []string{"something"}))
 	// Annotation set, no spec field set, class configured
 	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -148,13 +148,13 @@ func TestMatchesHTTPProxy(t *testing.T) {
 				"kubernetes.io/ingress.class": "something",
 			},
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// No annotation set, spec field set, class configured
 	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		Spec: contour_v1.HTTPProxySpec{
 			IngressClassName: "something",
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// Annotation set, no spec field set, class configured
 	assert.False(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -162,13 +162,13 @@ func TestMatchesHTTPProxy(t *testing.T) {
 				"kubernetes.io/ingress.class": "foo",
 			},
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// No annotation set, spec field set, class configured
 	assert.False(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		Spec: contour_v1.HTTPProxySpec{
 			IngressClassName: "aclass",
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// Annotation set, spec field set, class configured
 	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -179,7 +179,7 @@ func TestMatchesHTTPProxy(t *testing.T) {
 		Spec: contour_v1.HTTPProxySpec{
 			IngressClassName: "aclass",
 		},
-	}, "something"))
+	}, []string{"something"}))
 	// Annotation set, spec field set, class configured
 	assert.False(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -190,5 +190,91 @@ func TestMatchesHTTPProxy(t *testing.T) {
 		Spec: contour_v1.HTTPProxySpec{
 			IngressClassName: "something",
 		},
-	}, "something"))
+	}, []string{"something"}))
+}
+
+func TestMatchesMultipleClasses(t *testing.T) {
+	classes := []string{"contour", "legacy"}
+
+	// Spec field set to one of the classes
+	assert.True(t, MatchesIngress(&networking_v1.Ingress{
+		Spec: networking_v1.IngressSpec{
+			IngressClassName: pointer.StringPtr("legacy"),
+		},
+	}, classes))
+	// Annotation set to one of the classes
+	assert.True(t, MatchesHTTPProxy(&contour_v1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.class": "contour",
+			},
+		},
+	}, classes))
+	// Spec field set to another class
+	assert.False(t, MatchesIngress(&networking_v1.Ingress{
+		Spec: networking_v1.IngressSpec{
+			IngressClassName: pointer.StringPtr("nginx"),
+		},
+	}, classes))
+	// No annotation, no spec field set
+	assert.False(t, MatchesIngress(&networking_v1.Ingress{}, classes))
+}
+
+func TestMatchesIngressClass(t *testing.T) {
+	// No classes configured, default class name
+	assert.True(t, MatchesIngressClass(&networking_v1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "contour"},
+	}, nil))
+	// No classes configured, other class name
+	assert.False(t, MatchesIngressClass(&networking_v1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy"},
+	}, nil))
+	// Classes configured, one of them
+	assert.True(t, MatchesIngressClass(&networking_v1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy"},
+	}, []string{"contour", "legacy"}))
+	// Classes configured, none of them
+	assert.False(t, MatchesIngressClass(&networking_v1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx"},
+	}, []string{"contour", "legacy"}))
+}
+
+func TestHasIngressClass(t *testing.T) {
+	// No annotation, no spec field set
+	assert.False(t, HasIngressClass(&networking_v1.Ingress{}))
+	// Annotation set
+	assert.True(t, HasIngressClass(&networking_v1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.class": "foo",
+			},
+		},
+	}))
+	// Spec field set
+	assert.True(t, HasIngressClass(&networking_v1.Ingress{
+		Spec: networking_v1.IngressSpec{
+			IngressClassName: pointer.StringPtr("foo"),
+		},
+	}))
+}
+
+func TestIsDefault(t *testing.T) {
+	// No annotation set
+	assert.False(t, IsDefault(&networking_v1.IngressClass{}))
+	// Annotation set to true
+	assert.True(t, IsDefault(&networking_v1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"ingressclass.kubernetes.io/is-default-class": "true",
+			},
+		},
+	}))
+	// Annotation set to false
+	assert.False(t, IsDefault(&networking_v1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"ingressclass.kubernetes.io/is-default-class": "false",
+			},
+		},
+	}))
 }
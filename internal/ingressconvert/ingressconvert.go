@@ -0,0 +1,232 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ingressconvert translates Ingress resources into equivalent
+// HTTPProxy resources.
+package ingressconvert
+
+import (
+	"fmt"
+	"strings"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/annotation"
+	networking_v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+// IngressToHTTPProxies returns the HTTPProxies equivalent to the supplied
+// Ingress, one per Ingress rule host, along with a warning for each part
+// of the Ingress that could not be converted exactly.
+func IngressToHTTPProxies(ing *networking_v1.Ingress) ([]*contour_api_v1.HTTPProxy, []string) {
+	var warnings []string
+	warnf := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("%s/%s: ", ing.Namespace, ing.Name)+fmt.Sprintf(format, args...))
+	}
+
+	if ing.Spec.DefaultBackend != nil {
+		warnf("spec.defaultBackend was not converted; HTTPProxy requires a virtual host fqdn")
+	}
+
+	// Group the rule paths by host, keeping the order in which
+	// hosts first appear.
+	var hosts []string
+	paths := map[string][]networking_v1.HTTPIngressPath{}
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host == "" {
+			warnf("rule without a host was not converted; HTTPProxy requires a virtual host fqdn")
+			continue
+		}
+		if _, ok := paths[rule.Host]; !ok {
+			hosts = append(hosts, rule.Host)
+			paths[rule.Host] = nil
+		}
+		if rule.HTTP != nil {
+			paths[rule.Host] = append(paths[rule.Host], rule.HTTP.Paths...)
+		}
+	}
+
+	if !annotation.HTTPAllowed(ing) {
+		warnf("kubernetes.io/ingress.allow-http annotation has no HTTPProxy equivalent; HTTPProxy always serves or redirects insecure requests")
+	}
+
+	var proxies []*contour_api_v1.HTTPProxy
+	for _, host := range hosts {
+		name := ing.Name
+		if len(hosts) > 1 {
+			name = ing.Name + "-" + hostSuffix(host)
+		}
+
+		proxy := &contour_api_v1.HTTPProxy{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: contour_api_v1.GroupVersion.String(),
+				Kind:       "HTTPProxy",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ing.Namespace,
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: host,
+				},
+				IngressClassName: ingressClassName(ing),
+			},
+		}
+
+		tls := tlsForHost(ing, host)
+		if tls != nil {
+			proxy.Spec.VirtualHost.TLS = tls
+		} else if annotation.TLSRequired(ing) {
+			warnf("ingress.kubernetes.io/force-ssl-redirect annotation was not converted for host %q, which has no TLS secret", host)
+		}
+
+		for _, path := range paths[host] {
+			route, ok := convertPath(ing, path, warnf)
+			if !ok {
+				continue
+			}
+
+			// An Ingress TLS host serves insecure requests unless
+			// a redirect is forced, whereas an HTTPProxy TLS host
+			// redirects them unless insecure requests are permitted.
+			route.PermitInsecure = tls != nil && !annotation.TLSRequired(ing) && annotation.HTTPAllowed(ing)
+
+			proxy.Spec.Routes = append(proxy.Spec.Routes, route)
+		}
+
+		proxies = append(proxies, proxy)
+	}
+
+	return proxies, warnings
+}
+
+// convertPath returns the HTTPProxy route equivalent to the supplied
+// Ingress path. Returns false if the path cannot be converted.
+func convertPath(ing *networking_v1.Ingress, path networking_v1.HTTPIngressPath, warnf func(string, ...interface{})) (contour_api_v1.Route, bool) {
+	prefix := path.Path
+	if prefix == "" {
+		prefix = "/"
+	}
+
+	be := path.Backend.Service
+	if be == nil {
+		warnf("path %q was not converted; only Service backends are supported", prefix)
+		return contour_api_v1.Route{}, false
+	}
+	if be.Port.Name != "" {
+		warnf("path %q was not converted; HTTPProxy requires the port number of Service %q instead of port name %q", prefix, be.Name, be.Port.Name)
+		return contour_api_v1.Route{}, false
+	}
+
+	pathType := networking_v1.PathTypeImplementationSpecific
+	if path.PathType != nil {
+		pathType = *path.PathType
+	}
+
+	switch pathType {
+	case networking_v1.PathTypeExact:
+		warnf("Exact path %q was converted to a prefix condition, which also matches longer paths", prefix)
+	case networking_v1.PathTypePrefix:
+		if prefix != "/" {
+			prefix = strings.TrimRight(prefix, "/")
+			warnf("Prefix path %q was converted to a string prefix condition, which also matches paths that do not end at a path segment", prefix)
+		}
+	case networking_v1.PathTypeImplementationSpecific:
+		if strings.ContainsAny(prefix, "^+*[]%") {
+			warnf("regular expression path %q was not converted; HTTPProxy does not support regular expression conditions", prefix)
+			return contour_api_v1.Route{}, false
+		}
+	}
+
+	route := contour_api_v1.Route{
+		Services: []contour_api_v1.Service{{
+			Name: be.Name,
+			Port: int(be.Port.Number),
+		}},
+		EnableWebsockets: annotation.WebsocketRoutes(ing)[path.Path],
+		TimeoutPolicy:    timeoutPolicy(ing),
+		RetryPolicy:      retryPolicy(ing),
+	}
+	if prefix != "/" {
+		route.Conditions = []contour_api_v1.MatchCondition{{
+			Prefix: prefix,
+		}}
+	}
+
+	return route, true
+}
+
+// tlsForHost returns the HTTPProxy TLS configuration for the supplied
+// host, or nil if the Ingress has no TLS secret for that host.
+func tlsForHost(ing *networking_v1.Ingress, host string) *contour_api_v1.TLS {
+	for _, tls := range ing.Spec.TLS {
+		for _, h := range tls.Hosts {
+			if h == host {
+				return &contour_api_v1.TLS{
+					SecretName:             tls.SecretName,
+					MinimumProtocolVersion: annotation.ContourAnnotation(ing, "tls-minimum-protocol-version"),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// timeoutPolicy returns the HTTPProxy timeout policy equivalent to the
+// Ingress timeout annotations.
+func timeoutPolicy(ing *networking_v1.Ingress) *contour_api_v1.TimeoutPolicy {
+	response := annotation.ContourAnnotation(ing, "response-timeout")
+	if response == "" {
+		response = annotation.ContourAnnotation(ing, "request-timeout")
+	}
+	if response == "" {
+		return nil
+	}
+	return &contour_api_v1.TimeoutPolicy{
+		Response: response,
+	}
+}
+
+// retryPolicy returns the HTTPProxy retry policy equivalent to the
+// Ingress retry annotations.
+func retryPolicy(ing *networking_v1.Ingress) *contour_api_v1.RetryPolicy {
+	retryOn := annotation.ContourAnnotation(ing, "retry-on")
+	if retryOn == "" {
+		return nil
+	}
+
+	rp := &contour_api_v1.RetryPolicy{
+		NumRetries:    int64(annotation.NumRetries(ing)),
+		PerTryTimeout: annotation.ContourAnnotation(ing, "per-try-timeout"),
+	}
+	for _, r := range strings.Split(retryOn, ",") {
+		rp.RetryOn = append(rp.RetryOn, contour_api_v1.RetryOn(strings.TrimSpace(r)))
+	}
+	return rp
+}
+
+// ingressClassName returns the ingress class of the supplied Ingress.
+// The annotation takes precedence over the spec field.
+func ingressClassName(ing *networking_v1.Ingress) string {
+	if class := annotation.IngressClass(ing); class != "" {
+		return class
+	}
+	return pointer.StringPtrDerefOr(ing.Spec.IngressClassName, "")
+}
+
+// hostSuffix returns a name suffix derived from the supplied host.
+func hostSuffix(host string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(host, "*", "wildcard"), ".", "-")
+}
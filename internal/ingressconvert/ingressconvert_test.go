@@ -0,0 +1,228 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingressconvert
+
+import (
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/stretchr/testify/assert"
+	networking_v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIngressToHTTPProxies(t *testing.T) {
+	prefix := networking_v1.PathTypePrefix
+	implementationSpecific := networking_v1.PathTypeImplementationSpecific
+
+	backend := func(name string, port int32) networking_v1.IngressBackend {
+		return networking_v1.IngressBackend{
+			Service: &networking_v1.IngressServiceBackend{
+				Name: name,
+				Port: networking_v1.ServiceBackendPort{Number: port},
+			},
+		}
+	}
+
+	proxyMeta := func(name string) (metav1.TypeMeta, metav1.ObjectMeta) {
+		return metav1.TypeMeta{
+			APIVersion: "projectcontour.io/v1",
+			Kind:       "HTTPProxy",
+		}, metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		}
+	}
+
+	tests := map[string]struct {
+		ingress      *networking_v1.Ingress
+		want         []*contour_api_v1.HTTPProxy
+		wantWarnings []string
+	}{
+		"single host with annotations": {
+			ingress: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kuard",
+					Namespace: "default",
+					Annotations: map[string]string{
+						"kubernetes.io/ingress.class":        "contour",
+						"projectcontour.io/response-timeout": "10s",
+						"projectcontour.io/retry-on":         "5xx,gateway-error",
+						"projectcontour.io/num-retries":      "3",
+						"projectcontour.io/per-try-timeout":  "1s",
+						"projectcontour.io/websocket-routes": "/ws",
+					},
+				},
+				Spec: networking_v1.IngressSpec{
+					TLS: []networking_v1.IngressTLS{{
+						Hosts:      []string{"kuard.example.com"},
+						SecretName: "kuard-tls",
+					}},
+					Rules: []networking_v1.IngressRule{{
+						Host: "kuard.example.com",
+						IngressRuleValue: networking_v1.IngressRuleValue{
+							HTTP: &networking_v1.HTTPIngressRuleValue{
+								Paths: []networking_v1.HTTPIngressPath{{
+									Path:     "/",
+									PathType: &implementationSpecific,
+									Backend:  backend("kuard", 80),
+								}, {
+									Path:     "/ws",
+									PathType: &implementationSpecific,
+									Backend:  backend("kuard-ws", 8080),
+								}},
+							},
+						},
+					}},
+				},
+			},
+			want: []*contour_api_v1.HTTPProxy{func() *contour_api_v1.HTTPProxy {
+				tm, om := proxyMeta("kuard")
+				timeout := &contour_api_v1.TimeoutPolicy{Response: "10s"}
+				retry := &contour_api_v1.RetryPolicy{
+					NumRetries:    3,
+					PerTryTimeout: "1s",
+					RetryOn:       []contour_api_v1.RetryOn{"5xx", "gateway-error"},
+				}
+				return &contour_api_v1.HTTPProxy{
+					TypeMeta:   tm,
+					ObjectMeta: om,
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "kuard.example.com",
+							TLS: &contour_api_v1.TLS{
+								SecretName: "kuard-tls",
+							},
+						},
+						IngressClassName: "contour",
+						Routes: []contour_api_v1.Route{{
+							Services:       []contour_api_v1.Service{{Name: "kuard", Port: 80}},
+							TimeoutPolicy:  timeout,
+							RetryPolicy:    retry,
+							PermitInsecure: true,
+						}, {
+							Conditions:       []contour_api_v1.MatchCondition{{Prefix: "/ws"}},
+							Services:         []contour_api_v1.Service{{Name: "kuard-ws", Port: 8080}},
+							EnableWebsockets: true,
+							TimeoutPolicy:    timeout,
+							RetryPolicy:      retry,
+							PermitInsecure:   true,
+						}},
+					},
+				}
+			}()},
+		},
+		"multiple hosts and unconvertible paths": {
+			ingress: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kuard",
+					Namespace: "default",
+				},
+				Spec: networking_v1.IngressSpec{
+					Rules: []networking_v1.IngressRule{{
+						Host: "a.example.com",
+						IngressRuleValue: networking_v1.IngressRuleValue{
+							HTTP: &networking_v1.HTTPIngressRuleValue{
+								Paths: []networking_v1.HTTPIngressPath{{
+									Path:     "/api/",
+									PathType: &prefix,
+									Backend:  backend("api", 80),
+								}, {
+									Path:     "/[a-z]+",
+									PathType: &implementationSpecific,
+									Backend:  backend("api", 80),
+								}},
+							},
+						},
+					}, {
+						Host: "b.example.com",
+						IngressRuleValue: networking_v1.IngressRuleValue{
+							HTTP: &networking_v1.HTTPIngressRuleValue{
+								Paths: []networking_v1.HTTPIngressPath{{
+									Path:     "/",
+									PathType: &prefix,
+									Backend: networking_v1.IngressBackend{
+										Service: &networking_v1.IngressServiceBackend{
+											Name: "web",
+											Port: networking_v1.ServiceBackendPort{Name: "http"},
+										},
+									},
+								}},
+							},
+						},
+					}},
+				},
+			},
+			want: []*contour_api_v1.HTTPProxy{func() *contour_api_v1.HTTPProxy {
+				tm, om := proxyMeta("kuard-a-example-com")
+				return &contour_api_v1.HTTPProxy{
+					TypeMeta:   tm,
+					ObjectMeta: om,
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "a.example.com",
+						},
+						Routes: []contour_api_v1.Route{{
+							Conditions: []contour_api_v1.MatchCondition{{Prefix: "/api"}},
+							Services:   []contour_api_v1.Service{{Name: "api", Port: 80}},
+						}},
+					},
+				}
+			}(), func() *contour_api_v1.HTTPProxy {
+				tm, om := proxyMeta("kuard-b-example-com")
+				return &contour_api_v1.HTTPProxy{
+					TypeMeta:   tm,
+					ObjectMeta: om,
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "b.example.com",
+						},
+					},
+				}
+			}()},
+			wantWarnings: []string{
+				`default/kuard: Prefix path "/api" was converted to a string prefix condition, which also matches paths that do not end at a path segment`,
+				`default/kuard: regular expression path "/[a-z]+" was not converted; HTTPProxy does not support regular expression conditions`,
+				`default/kuard: path "/" was not converted; HTTPProxy requires the port number of Service "web" instead of port name "http"`,
+			},
+		},
+		"default backend": {
+			ingress: &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "kuard",
+					Namespace: "default",
+				},
+				Spec: networking_v1.IngressSpec{
+					DefaultBackend: &networking_v1.IngressBackend{
+						Service: &networking_v1.IngressServiceBackend{
+							Name: "kuard",
+							Port: networking_v1.ServiceBackendPort{Number: 80},
+						},
+					},
+				},
+			},
+			wantWarnings: []string{
+				"default/kuard: spec.defaultBackend was not converted; HTTPProxy requires a virtual host fqdn",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotWarnings := IngressToHTTPProxies(tc.ingress)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantWarnings, gotWarnings)
+		})
+	}
+}
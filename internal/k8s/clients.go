@@ -15,9 +15,17 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -31,9 +39,19 @@ import (
 type Clients struct {
 	meta.RESTMapper
 
+	config  *rest.Config
+	scheme  *runtime.Scheme
 	core    *kubernetes.Clientset
 	dynamic dynamic.Interface
 	cache   cache.Cache
+
+	// scoped holds a cache for each kind whose
+	// informers are restricted by ScopeInformers.
+	scoped map[schema.GroupVersionKind]cache.Cache
+
+	// unscoped holds the caches created by
+	// NamespacedInformerForResource and InformerForObject.
+	unscoped []cache.Cache
 }
 
 // NewClients returns a new set of the various API clients required
@@ -50,7 +68,10 @@ func NewClients(kubeconfig string, inCluster bool) (*Clients, error) {
 		return nil, err
 	}
 
-	var clients Clients
+	clients := Clients{
+		config: config,
+		scheme: scheme,
+	}
 	clients.core, err = kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
@@ -92,19 +113,209 @@ func (c *Clients) InformerForResource(gvr schema.GroupVersionResource) (Informer
 		return nil, err
 	}
 
-	return c.cache.GetInformerForKind(context.Background(), gvk)
+	return c.cacheFor(gvk).GetInformerForKind(context.Background(), gvk)
+}
+
+// ScopeInformers restricts the informers of the given resources to the
+// objects that are in one of the given namespaces, or in any namespace
+// if none are given, are not in any of the excluded namespaces, and
+// have labels matching the selector. A nil selector matches all labels.
+// Objects outside the scope are never listed or watched, so they are
+// not held in memory.
+//
+// ScopeInformers must be called before any informers are created.
+func (c *Clients) ScopeInformers(namespaces, excludeNamespaces []string, selector labels.Selector, resources ...schema.GroupVersionResource) error {
+	var field fields.Selector
+	if len(excludeNamespaces) > 0 {
+		var excluded []fields.Selector
+		for _, ns := range excludeNamespaces {
+			excluded = append(excluded, fields.OneTermNotEqualSelector("metadata.namespace", ns))
+		}
+		field = fields.AndSelectors(excluded...)
+	}
+
+	newCache := cache.New
+	if len(namespaces) > 0 {
+		newCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
+	scoped := map[schema.GroupVersionKind]cache.Cache{}
+	for _, r := range resources {
+		gvk, obj, err := c.objectFor(r)
+		if err != nil {
+			return err
+		}
+
+		sc, err := newCache(c.config, cache.Options{
+			Scheme: c.scheme,
+			Mapper: c.RESTMapper,
+			SelectorsByObject: cache.SelectorsByObject{
+				obj: {Label: selector, Field: field},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		scoped[gvk] = sc
+	}
+
+	c.scoped = scoped
+	return nil
 }
 
+// NamespacedInformerForResource returns an informer for the objects of
+// gvr in namespace. Unlike InformerForResource, the informer is not
+// restricted by ScopeInformers.
+//
+// NamespacedInformerForResource must be called before StartInformers.
+func (c *Clients) NamespacedInformerForResource(gvr schema.GroupVersionResource, namespace string) (Informer, error) {
+	gvk, err := c.KindFor(gvr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only scoped informers miss objects in the namespace.
+	if _, ok := c.scoped[gvk]; !ok {
+		return c.cache.GetInformerForKind(context.Background(), gvk)
+	}
+
+	return c.unscopedInformer(gvk, cache.Options{
+		Scheme:    c.scheme,
+		Mapper:    c.RESTMapper,
+		Namespace: namespace,
+	})
+}
+
+// InformerForObject returns an informer for the single object of gvr
+// with the given name. Unlike InformerForResource, the informer is not
+// restricted by ScopeInformers.
+//
+// InformerForObject must be called before StartInformers.
+func (c *Clients) InformerForObject(gvr schema.GroupVersionResource, name types.NamespacedName) (Informer, error) {
+	gvk, obj, err := c.objectFor(gvr)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.unscopedInformer(gvk, cache.Options{
+		Scheme:    c.scheme,
+		Mapper:    c.RESTMapper,
+		Namespace: name.Namespace,
+		SelectorsByObject: cache.SelectorsByObject{
+			obj: {Field: fields.OneTermEqualSelector("metadata.name", name.Name)},
+		},
+	})
+}
+
+// unscopedInformer returns an informer for gvk from a new cache
+// created with opts.
+func (c *Clients) unscopedInformer(gvk schema.GroupVersionKind, opts cache.Options) (Informer, error) {
+	uc, err := cache.New(c.config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.unscoped = append(c.unscoped, uc)
+	return uc.GetInformerForKind(context.Background(), gvk)
+}
+
+// objectFor returns the kind of the objects of gvr, and an empty one.
+func (c *Clients) objectFor(gvr schema.GroupVersionResource) (schema.GroupVersionKind, client.Object, error) {
+	gvk, err := c.KindFor(gvr)
+	if err != nil {
+		return gvk, nil, err
+	}
+
+	obj, err := c.scheme.New(gvk)
+	if err != nil {
+		return gvk, nil, err
+	}
+
+	o, ok := obj.(client.Object)
+	if !ok {
+		return gvk, nil, fmt.Errorf("%s is not a Kubernetes object", gvk)
+	}
+	return gvk, o, nil
+}
+
+// cacheFor returns the cache that holds the objects of gvk.
+func (c *Clients) cacheFor(gvk schema.GroupVersionKind) cache.Cache {
+	if sc, ok := c.scoped[gvk]; ok {
+		return sc
+	}
+	return c.cache
+}
+
+// caches returns all the caches that informers are created in.
+func (c *Clients) caches() []cache.Cache {
+	caches := []cache.Cache{c.cache}
+	for _, sc := range c.scoped {
+		caches = append(caches, sc)
+	}
+	return append(caches, c.unscoped...)
+}
+
+// StartInformers starts the informers of all caches, and blocks
+// until ctx is done.
 func (c *Clients) StartInformers(ctx context.Context) error {
-	return c.cache.Start(ctx)
+	caches := c.caches()
+
+	errs := make(chan error, len(caches))
+	for _, cc := range caches {
+		go func(cc cache.Cache) {
+			errs <- cc.Start(ctx)
+		}(cc)
+	}
+
+	var err error
+	for range caches {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 func (c *Clients) WaitForCacheSync(ctx context.Context) bool {
-	return c.cache.WaitForCacheSync(ctx)
+	for _, cc := range c.caches() {
+		if !cc.WaitForCacheSync(ctx) {
+			return false
+		}
+	}
+	return true
 }
 
+// Cache returns a client.Reader that reads objects from the
+// informer caches, and so is restricted by ScopeInformers.
 func (c *Clients) Cache() client.Reader {
-	return c.cache
+	return cacheReader{c}
+}
+
+// cacheReader reads each object from the cache that holds objects
+// of its kind.
+type cacheReader struct {
+	*Clients
+}
+
+func (r cacheReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return r.cacheForObject(obj).Get(ctx, key, obj)
+}
+
+func (r cacheReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return r.cacheForObject(list).List(ctx, list, opts...)
+}
+
+// cacheForObject returns the cache that holds objects of the kind of
+// obj, or of the kind of its items if obj is a list.
+func (c *Clients) cacheForObject(obj runtime.Object) cache.Cache {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		// Leave it to the cache to report the error.
+		return c.cache
+	}
+
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	return c.cacheFor(gvk)
 }
 
 // ClientSet returns the Kubernetes Core v1 ClientSet.
@@ -129,3 +340,21 @@ func (c *Clients) ResourcesExist(gvr ...schema.GroupVersionResource) bool {
 
 	return true
 }
+
+// PatchAnnotations merges the given annotations into those of the
+// named object with a JSON merge patch.
+func (c *Clients) PatchAnnotations(gvr schema.GroupVersionResource, name types.NamespacedName, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dynamic.Resource(gvr).
+		Namespace(name.Namespace).
+		Patch(context.Background(), name.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
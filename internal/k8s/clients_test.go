@@ -16,11 +16,16 @@ package k8s
 import (
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestResourceKindExists(t *testing.T) {
@@ -85,3 +90,78 @@ func TestResourceKindExists(t *testing.T) {
 	assert.False(t, clients.ResourcesExist(invalid), "v1 phony exists")
 	assert.False(t, clients.ResourcesExist(valid, valid, invalid), "v1 phony exists")
 }
+
+func TestScopeInformers(t *testing.T) {
+	r := &restmapper.APIGroupResources{
+		Group: metav1.APIGroup{
+			Versions: []metav1.GroupVersionForDiscovery{
+				{
+					GroupVersion: "v1",
+					Version:      "v1",
+				},
+			},
+		},
+		VersionedResources: map[string][]metav1.APIResource{
+			"v1": {
+				{
+					Name:       "services",
+					Namespaced: true,
+					Kind:       "Service",
+					Verbs:      metav1.Verbs{"get", "list", "watch"},
+				},
+				{
+					Name:       "secrets",
+					Namespaced: true,
+					Kind:       "Secret",
+					Verbs:      metav1.Verbs{"get", "list", "watch"},
+				},
+			},
+		},
+	}
+
+	scheme, err := NewContourScheme()
+	require.NoError(t, err)
+
+	selector, err := labels.Parse("team=a")
+	require.NoError(t, err)
+
+	for name, namespaces := range map[string][]string{
+		"all namespaces":  nil,
+		"some namespaces": {"ns1", "ns2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clients := &Clients{
+				RESTMapper: restmapper.NewDiscoveryRESTMapper([]*restmapper.APIGroupResources{r}),
+				config:     &rest.Config{},
+				scheme:     scheme,
+			}
+			c, err := cache.New(clients.config, cache.Options{
+				Scheme: scheme,
+				Mapper: clients.RESTMapper,
+			})
+			require.NoError(t, err)
+			clients.cache = c
+
+			require.NoError(t, clients.ScopeInformers(namespaces, []string{"ns3"}, selector, ServicesResources()...))
+			scoped := clients.scoped[corev1.SchemeGroupVersion.WithKind("Service")]
+			require.NotNil(t, scoped)
+
+			assert.Same(t, scoped, clients.cacheFor(corev1.SchemeGroupVersion.WithKind("Service")))
+			assert.Same(t, scoped, clients.cacheForObject(&corev1.Service{}))
+			assert.Same(t, scoped, clients.cacheForObject(&corev1.ServiceList{}))
+			assert.Same(t, clients.cache, clients.cacheFor(corev1.SchemeGroupVersion.WithKind("Secret")))
+			assert.Same(t, clients.cache, clients.cacheForObject(&corev1.SecretList{}))
+			assert.Len(t, clients.caches(), 2)
+		})
+	}
+
+	clients := &Clients{
+		RESTMapper: restmapper.NewDiscoveryRESTMapper([]*restmapper.APIGroupResources{r}),
+		config:     &rest.Config{},
+		scheme:     scheme,
+	}
+	assert.Error(t, clients.ScopeInformers(nil, nil, nil, schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "phony",
+	}))
+}
@@ -15,6 +15,8 @@ package k8s
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -70,3 +72,150 @@ func (e *namespaceFilter) OnDelete(obj interface{}) {
 		e.next.OnDelete(obj)
 	}
 }
+
+type watchFilter struct {
+	next     cache.ResourceEventHandler
+	include  map[string]struct{}
+	exclude  map[string]struct{}
+	selector labels.Selector
+}
+
+// NewWatchFilter returns a cache.ResourceEventHandler that accepts only
+// objects that are in one of the given namespaces, or in any namespace
+// if none are given, are not in any of the excluded namespaces, and
+// have labels matching the selector. A nil selector matches all labels.
+// Accepted objects are passed to the next handler.
+//
+// An update that changes whether an object is accepted, for example by
+// changing its labels, is passed to the next handler as an add or delete.
+//
+// Clients.ScopeInformers has the API server filter objects the same way
+// before they are cached; the filter guards handlers of informers that
+// are not scoped.
+func NewWatchFilter(
+	namespaces []string,
+	excludeNamespaces []string,
+	selector labels.Selector,
+	next cache.ResourceEventHandler,
+) cache.ResourceEventHandler {
+	e := &watchFilter{
+		next:     next,
+		include:  make(map[string]struct{}),
+		exclude:  make(map[string]struct{}),
+		selector: selector,
+	}
+
+	for _, ns := range namespaces {
+		e.include[ns] = struct{}{}
+	}
+	for _, ns := range excludeNamespaces {
+		e.exclude[ns] = struct{}{}
+	}
+	if e.selector == nil {
+		e.selector = labels.Everything()
+	}
+
+	return e
+}
+
+func (e *watchFilter) allowed(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	o, ok := obj.(metav1.Object)
+	if !ok {
+		return true
+	}
+
+	if _, ok := e.exclude[o.GetNamespace()]; ok {
+		return false
+	}
+	if len(e.include) > 0 {
+		if _, ok := e.include[o.GetNamespace()]; !ok {
+			return false
+		}
+	}
+
+	return e.selector.Matches(labels.Set(o.GetLabels()))
+}
+
+func (e *watchFilter) OnAdd(obj interface{}) {
+	if e.allowed(obj) {
+		e.next.OnAdd(obj)
+	}
+}
+
+func (e *watchFilter) OnUpdate(oldObj, newObj interface{}) {
+	switch oldAllowed, newAllowed := e.allowed(oldObj), e.allowed(newObj); {
+	case oldAllowed && newAllowed:
+		e.next.OnUpdate(oldObj, newObj)
+	case oldAllowed:
+		e.next.OnDelete(oldObj)
+	case newAllowed:
+		e.next.OnAdd(newObj)
+	}
+}
+
+func (e *watchFilter) OnDelete(obj interface{}) {
+	if e.allowed(obj) {
+		e.next.OnDelete(obj)
+	}
+}
+
+type ignoreFilter struct {
+	next   cache.ResourceEventHandler
+	ignore map[types.NamespacedName]struct{}
+}
+
+// NewIgnoreFilter returns a cache.ResourceEventHandler that accepts
+// all objects except the named ones, for example because another
+// informer passes them to the next handler. Accepted objects are
+// passed to the next handler.
+func NewIgnoreFilter(
+	names []types.NamespacedName,
+	next cache.ResourceEventHandler,
+) cache.ResourceEventHandler {
+	e := &ignoreFilter{
+		next:   next,
+		ignore: make(map[types.NamespacedName]struct{}),
+	}
+
+	for _, name := range names {
+		e.ignore[name] = struct{}{}
+	}
+
+	return e
+}
+
+func (e *ignoreFilter) allowed(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	o, ok := obj.(metav1.Object)
+	if !ok {
+		return true
+	}
+
+	_, ignored := e.ignore[types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}]
+	return !ignored
+}
+
+func (e *ignoreFilter) OnAdd(obj interface{}) {
+	if e.allowed(obj) {
+		e.next.OnAdd(obj)
+	}
+}
+
+func (e *ignoreFilter) OnUpdate(oldObj, newObj interface{}) {
+	if e.allowed(newObj) {
+		e.next.OnUpdate(oldObj, newObj)
+	}
+}
+
+func (e *ignoreFilter) OnDelete(obj interface{}) {
+	if e.allowed(obj) {
+		e.next.OnDelete(obj)
+	}
+}
@@ -19,6 +19,9 @@ import (
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
 )
 
 type countHandler struct {
@@ -68,3 +71,72 @@ func TestNamespaceFilter(t *testing.T) {
 	assert.Equal(t, 1, counter.deleted)
 
 }
+
+func TestWatchFilter(t *testing.T) {
+	counter := countHandler{}
+	selector, err := labels.Parse("team=a")
+	require.NoError(t, err)
+	filter := NewWatchFilter([]string{"ns1", "ns2"}, []string{"ns2"}, selector, &counter)
+
+	require.NotNil(t, filter)
+
+	// Objects outside the watched namespaces, in an excluded
+	// namespace, or without matching labels are not passed on.
+	filter.OnAdd(fixture.NewProxy("ns3/proxy").Label("team", "a"))
+	filter.OnAdd(fixture.NewProxy("ns2/proxy").Label("team", "a"))
+	filter.OnAdd(fixture.NewProxy("ns1/proxy").Label("team", "b"))
+	assert.Equal(t, 0, counter.added)
+
+	filter.OnAdd(fixture.NewProxy("ns1/proxy").Label("team", "a"))
+	assert.Equal(t, 1, counter.added)
+
+	filter.OnUpdate(fixture.NewProxy("ns1/proxy").Label("team", "a"), fixture.NewProxy("ns1/proxy").Label("team", "a"))
+	assert.Equal(t, 1, counter.updated)
+
+	// An update that changes whether the object
+	// matches is passed on as a delete or add.
+	filter.OnUpdate(fixture.NewProxy("ns1/proxy").Label("team", "a"), fixture.NewProxy("ns1/proxy").Label("team", "b"))
+	assert.Equal(t, 1, counter.updated)
+	assert.Equal(t, 1, counter.deleted)
+
+	filter.OnUpdate(fixture.NewProxy("ns1/proxy").Label("team", "b"), fixture.NewProxy("ns1/proxy").Label("team", "a"))
+	assert.Equal(t, 1, counter.updated)
+	assert.Equal(t, 2, counter.added)
+
+	filter.OnDelete(fixture.NewProxy("ns1/proxy").Label("team", "b"))
+	assert.Equal(t, 1, counter.deleted)
+
+	filter.OnDelete(cache.DeletedFinalStateUnknown{Obj: fixture.NewProxy("ns1/proxy").Label("team", "a")})
+	assert.Equal(t, 2, counter.deleted)
+
+	// With no namespaces or selector, only
+	// excluded namespaces are filtered.
+	counter = countHandler{}
+	filter = NewWatchFilter(nil, []string{"ns2"}, nil, &counter)
+
+	filter.OnAdd(fixture.NewProxy("ns2/proxy"))
+	assert.Equal(t, 0, counter.added)
+
+	filter.OnAdd(fixture.NewProxy("ns3/proxy"))
+	assert.Equal(t, 1, counter.added)
+}
+
+func TestIgnoreFilter(t *testing.T) {
+	counter := countHandler{}
+	filter := NewIgnoreFilter([]types.NamespacedName{{Namespace: "ns1", Name: "fallback"}}, &counter)
+
+	require.NotNil(t, filter)
+
+	// The named object is not passed on, whatever
+	// the operation. Other objects are.
+	filter.OnAdd(fixture.NewProxy("ns1/fallback"))
+	filter.OnUpdate(fixture.NewProxy("ns1/fallback"), fixture.NewProxy("ns1/fallback"))
+	filter.OnDelete(fixture.NewProxy("ns1/fallback"))
+	filter.OnDelete(cache.DeletedFinalStateUnknown{Obj: fixture.NewProxy("ns1/fallback")})
+	assert.Equal(t, countHandler{}, counter)
+
+	filter.OnAdd(fixture.NewProxy("ns2/fallback"))
+	filter.OnUpdate(fixture.NewProxy("ns1/proxy"), fixture.NewProxy("ns1/proxy"))
+	filter.OnDelete(fixture.NewProxy("ns1/proxy"))
+	assert.Equal(t, countHandler{added: 1, updated: 1, deleted: 1}, counter)
+}
@@ -17,15 +17,27 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
 	networking_v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapi_v1alpha1 "sigs.k8s.io/gateway-api/apis/v1alpha1"
 )
 
+// ignoreTransitionTime ignores the LastTransitionTime of conditions,
+// which is always updated on each DAG rebuild regardless of whether
+// the status of the object changed or not.
+var ignoreTransitionTime = cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")
+
 // isStatusEqual checks that two objects of supported Kubernetes types
 // have equivalent Status structs.
 //
 // Currently supports:
 // networking.k8s.io/ingress/v1
 // projectcontour.io/v1
+// projectcontour.io/v1alpha1
+// networking.internal.knative.dev/v1alpha1
+// networking.x-k8s.io/v1alpha1
 func isStatusEqual(objA, objB interface{}) bool {
 
 	switch a := objA.(type) {
@@ -48,6 +60,51 @@ func isStatusEqual(objA, objB interface{}) bool {
 				return true
 			}
 		}
+	case *contour_api_v1.TLSCertificateDelegation:
+		switch b := objB.(type) {
+		case *contour_api_v1.TLSCertificateDelegation:
+			if cmp.Equal(a.Status, b.Status, ignoreTransitionTime) {
+				return true
+			}
+		}
+	case *contour_api_v1alpha1.ExtensionService:
+		switch b := objB.(type) {
+		case *contour_api_v1alpha1.ExtensionService:
+			if cmp.Equal(a.Status, b.Status, ignoreTransitionTime) {
+				return true
+			}
+		}
+	case *gatewayapi_v1alpha1.Gateway:
+		switch b := objB.(type) {
+		case *gatewayapi_v1alpha1.Gateway:
+			if cmp.Equal(a.Status, b.Status, ignoreTransitionTime) {
+				return true
+			}
+		}
+	case *gatewayapi_v1alpha1.HTTPRoute:
+		switch b := objB.(type) {
+		case *gatewayapi_v1alpha1.HTTPRoute:
+			if cmp.Equal(a.Status, b.Status, ignoreTransitionTime) {
+				return true
+			}
+		}
+	case *gatewayapi_v1alpha1.TLSRoute:
+		switch b := objB.(type) {
+		case *gatewayapi_v1alpha1.TLSRoute:
+			if cmp.Equal(a.Status, b.Status, ignoreTransitionTime) {
+				return true
+			}
+		}
+	case *knative_v1alpha1.Ingress:
+		switch b := objB.(type) {
+		case *knative_v1alpha1.Ingress:
+			// As for HTTPProxy, ignore the LastTransitionTime so
+			// that the status is only written when it changes.
+			if cmp.Equal(a.Status, b.Status,
+				cmpopts.IgnoreFields(knative_v1alpha1.Condition{}, "LastTransitionTime")) {
+				return true
+			}
+		}
 	}
 
 	return false
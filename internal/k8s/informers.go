@@ -16,7 +16,10 @@ package k8s
 import (
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	discovery_v1beta1 "k8s.io/api/discovery/v1beta1"
 	networking_v1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	gatewayapi_v1alpha1 "sigs.k8s.io/gateway-api/apis/v1alpha1"
@@ -28,6 +31,7 @@ import (
 
 // +kubebuilder:rbac:groups="projectcontour.io",resources=httpproxies;tlscertificatedelegations,verbs=get;list;watch
 // +kubebuilder:rbac:groups="projectcontour.io",resources=httpproxies/status,verbs=create;get;update
+// +kubebuilder:rbac:groups="projectcontour.io",resources=tlscertificatedelegations/status,verbs=create;get;update
 // +kubebuilder:rbac:groups="projectcontour.io",resources=extensionservices,verbs=get;list;watch
 // +kubebuilder:rbac:groups="projectcontour.io",resources=extensionservices/status,verbs=create;get;update
 
@@ -41,6 +45,24 @@ func DefaultResources() []schema.GroupVersionResource {
 	}
 }
 
+// +kubebuilder:rbac:groups="projectcontour.io",resources=contourpolicies,verbs=get;list;watch
+
+// ContourPolicyResources returns a list of ContourPolicy group/version resources.
+func ContourPolicyResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		contour_api_v1alpha1.ContourPolicyGVR,
+	}
+}
+
+// +kubebuilder:rbac:groups="projectcontour.io",resources=wasmmodules,verbs=get;list;watch
+
+// WasmModuleResources returns a list of WasmModule group/version resources.
+func WasmModuleResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		contour_api_v1alpha1.WasmModuleGVR,
+	}
+}
+
 func IngressV1Resources() []schema.GroupVersionResource {
 	return []schema.GroupVersionResource{
 		networking_v1.SchemeGroupVersion.WithResource("ingresses"),
@@ -48,6 +70,26 @@ func IngressV1Resources() []schema.GroupVersionResource {
 	}
 }
 
+// +kubebuilder:rbac:groups="networking.internal.knative.dev",resources=ingresses,verbs=get;list;watch
+// +kubebuilder:rbac:groups="networking.internal.knative.dev",resources=ingresses/status,verbs=create;get;update
+
+// KnativeIngressResources returns a list of Knative Ingress group/version resources.
+func KnativeIngressResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		knative_v1alpha1.IngressGVR,
+	}
+}
+
+// +kubebuilder:rbac:groups="multicluster.x-k8s.io",resources=serviceimports,verbs=get;list;watch
+
+// ServiceImportResources returns a list of Multi-Cluster Services
+// API group/version resources.
+func ServiceImportResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		mcs_v1alpha1.ServiceImportGVR,
+	}
+}
+
 // +kubebuilder:rbac:groups="networking.x-k8s.io",resources=gatewayclasses;gateways;httproutes;backendpolicies;tlsroutes;tcproutes;udproutes,verbs=get;list;watch
 // +kubebuilder:rbac:groups="networking.x-k8s.io",resources=gatewayclasses/status;gateways/status;httproutes/status;backendpolicies/status;tlsroutes/status;tcproutes/status;udproutes/status,verbs=update
 
@@ -102,6 +144,33 @@ func EndpointsResources() []schema.GroupVersionResource {
 	}
 }
 
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// PodResources ...
+func PodResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		corev1.SchemeGroupVersion.WithResource("pods"),
+	}
+}
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// NodeResources ...
+func NodeResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		corev1.SchemeGroupVersion.WithResource("nodes"),
+	}
+}
+
+// +kubebuilder:rbac:groups="discovery.k8s.io",resources=endpointslices,verbs=get;list;watch
+
+// EndpointSliceResources ...
+func EndpointSliceResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		discovery_v1beta1.SchemeGroupVersion.WithResource("endpointslices"),
+	}
+}
+
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
 
 // ServicesResources ...
@@ -117,3 +186,10 @@ func ServicesResources() []schema.GroupVersionResource {
 func NamespacesResource() schema.GroupVersionResource {
 	return corev1.SchemeGroupVersion.WithResource("namespaces")
 }
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// ConfigMapsResource ...
+func ConfigMapsResource() schema.GroupVersionResource {
+	return corev1.SchemeGroupVersion.WithResource("configmaps")
+}
@@ -16,6 +16,8 @@ package k8s
 import (
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -49,6 +51,14 @@ func KindOf(obj interface{}) string {
 			return "TLSCertificateDelegation"
 		case *v1alpha1.ExtensionService:
 			return "ExtensionService"
+		case *v1alpha1.ContourPolicy:
+			return "ContourPolicy"
+		case *v1alpha1.WasmModule:
+			return "WasmModule"
+		case *knative_v1alpha1.Ingress:
+			return "Ingress"
+		case *mcs_v1alpha1.ServiceImport:
+			return "ServiceImport"
 		case *unstructured.Unstructured:
 			return obj.GetKind()
 		default:
@@ -74,8 +84,12 @@ func VersionOf(obj interface{}) string {
 			return networking_v1.SchemeGroupVersion.String()
 		case *contour_api_v1.HTTPProxy, *contour_api_v1.TLSCertificateDelegation:
 			return contour_api_v1.GroupVersion.String()
-		case *v1alpha1.ExtensionService:
+		case *v1alpha1.ExtensionService, *v1alpha1.ContourPolicy, *v1alpha1.WasmModule:
 			return v1alpha1.GroupVersion.String()
+		case *knative_v1alpha1.Ingress:
+			return knative_v1alpha1.GroupVersion.String()
+		case *mcs_v1alpha1.ServiceImport:
+			return mcs_v1alpha1.GroupVersion.String()
 		case *unstructured.Unstructured:
 			return obj.GetAPIVersion()
 		default:
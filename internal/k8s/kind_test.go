@@ -18,6 +18,8 @@ import (
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
@@ -37,6 +39,10 @@ func TestKindOf(t *testing.T) {
 		{"HTTPProxy", &contour_api_v1.HTTPProxy{}},
 		{"TLSCertificateDelegation", &contour_api_v1.TLSCertificateDelegation{}},
 		{"ExtensionService", &v1alpha1.ExtensionService{}},
+		{"ContourPolicy", &v1alpha1.ContourPolicy{}},
+		{"WasmModule", &v1alpha1.WasmModule{}},
+		{"Ingress", &knative_v1alpha1.Ingress{}},
+		{"ServiceImport", &mcs_v1alpha1.ServiceImport{}},
 		{"Foo", &unstructured.Unstructured{
 			Object: map[string]interface{}{
 				"apiVersion": "test.projectcontour.io/v1",
@@ -62,6 +68,10 @@ func TestVersionOf(t *testing.T) {
 		{"projectcontour.io/v1", &contour_api_v1.HTTPProxy{}},
 		{"projectcontour.io/v1", &contour_api_v1.TLSCertificateDelegation{}},
 		{"projectcontour.io/v1alpha1", &v1alpha1.ExtensionService{}},
+		{"projectcontour.io/v1alpha1", &v1alpha1.ContourPolicy{}},
+		{"projectcontour.io/v1alpha1", &v1alpha1.WasmModule{}},
+		{"networking.internal.knative.dev/v1alpha1", &knative_v1alpha1.Ingress{}},
+		{"multicluster.x-k8s.io/v1alpha1", &mcs_v1alpha1.ServiceImport{}},
 		{"test.projectcontour.io/v1", &unstructured.Unstructured{
 			Object: map[string]interface{}{
 				"apiVersion": "test.projectcontour.io/v1",
@@ -16,6 +16,8 @@ package k8s
 import (
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	contour_api_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	gatewayapi_v1alpha1 "sigs.k8s.io/gateway-api/apis/v1alpha1"
@@ -31,6 +33,8 @@ func NewContourScheme() (*runtime.Scheme, error) {
 		contour_api_v1alpha1.AddToScheme,
 		scheme.AddToScheme,
 		gatewayapi_v1alpha1.AddToScheme,
+		knative_v1alpha1.AddToScheme,
+		mcs_v1alpha1.AddToScheme,
 	}
 
 	if err := b.AddToScheme(s); err != nil {
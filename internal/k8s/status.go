@@ -17,10 +17,13 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/projectcontour/contour/internal/metrics"
 	"github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -61,6 +64,17 @@ func (m StatusMutatorFunc) Mutate(old interface{}) interface{} {
 	return m(old)
 }
 
+// composeMutators returns a StatusMutator that applies each of
+// the given mutators in turn.
+func composeMutators(mutators ...StatusMutator) StatusMutator {
+	return StatusMutatorFunc(func(obj interface{}) interface{} {
+		for _, m := range mutators {
+			obj = m.Mutate(obj)
+		}
+		return obj
+	})
+}
+
 // StatusUpdateHandler holds the details required to actually write an Update back to the referenced object.
 type StatusUpdateHandler struct {
 	Log           logrus.FieldLogger
@@ -69,6 +83,99 @@ type StatusUpdateHandler struct {
 	LeaderElected chan struct{}
 	IsLeader      bool
 	Converter     *UnstructuredConverter
+
+	// RateLimiter, if set, limits the rate at which
+	// status updates are written to the API server.
+	RateLimiter flowcontrol.RateLimiter
+
+	// Metrics, if set, records the result of each status update.
+	Metrics *metrics.Metrics
+
+	// buffered holds the latest update of each object received
+	// before Contour is elected leader, in the order the objects
+	// were first seen. They are written as soon as Contour is
+	// elected leader, rather than waiting for the next rebuild.
+	buffered      []StatusUpdate
+	bufferedIndex map[statusKey]int
+}
+
+// statusKey identifies the object that a StatusUpdate applies to.
+type statusKey struct {
+	resource schema.GroupVersionResource
+	name     types.NamespacedName
+}
+
+// buffer holds the given update until Contour is elected leader.
+// Each rebuild computes the full status of an object, so an update
+// replaces any update of the same object that is already buffered.
+func (suh *StatusUpdateHandler) buffer(upd StatusUpdate) {
+	if suh.bufferedIndex == nil {
+		suh.bufferedIndex = map[statusKey]int{}
+	}
+
+	key := statusKey{resource: upd.Resource, name: upd.NamespacedName}
+	if i, ok := suh.bufferedIndex[key]; ok {
+		suh.buffered[i] = upd
+		return
+	}
+
+	suh.bufferedIndex[key] = len(suh.buffered)
+	suh.buffered = append(suh.buffered, upd)
+}
+
+// flush writes the updates buffered before Contour was elected leader.
+func (suh *StatusUpdateHandler) flush() {
+	if len(suh.buffered) > 0 {
+		suh.Log.WithField("count", len(suh.buffered)).Info("writing status updates buffered before election")
+	}
+
+	for _, upd := range suh.buffered {
+		suh.apply(upd)
+	}
+
+	suh.buffered = nil
+	suh.bufferedIndex = nil
+}
+
+func (suh *StatusUpdateHandler) recordResult(upd StatusUpdate, result string) {
+	if suh.Metrics != nil {
+		suh.Metrics.SetStatusUpdateResult(upd.Resource.Resource, result)
+	}
+}
+
+// coalesce drains the updates waiting on the update channel and
+// merges the updates of each object into a single update, whose
+// mutator applies each of them in the order they were received.
+// This means that an object whose status is updated many times
+// in quick succession is only written once.
+func (suh *StatusUpdateHandler) coalesce(first StatusUpdate) []StatusUpdate {
+	var updates []StatusUpdate
+	index := map[statusKey]int{}
+
+	add := func(upd StatusUpdate) {
+		key := statusKey{resource: upd.Resource, name: upd.NamespacedName}
+
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(updates)
+			updates = append(updates, upd)
+			return
+		}
+
+		suh.recordResult(upd, metrics.StatusUpdateCoalesced)
+		updates[i].Mutator = composeMutators(updates[i].Mutator, upd.Mutator)
+	}
+
+	add(first)
+
+	for {
+		select {
+		case upd := <-suh.UpdateChannel:
+			add(upd)
+		default:
+			return updates
+		}
+	}
 }
 
 func (suh *StatusUpdateHandler) apply(upd StatusUpdate) {
@@ -103,6 +210,8 @@ func (suh *StatusUpdateHandler) apply(upd StatusUpdate) {
 		return
 	}
 
+	skipped := false
+
 	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		// Fetch the lister cache for the informer associated with this resource.
 		if err := suh.Clients.Cache().Get(context.Background(), upd.NamespacedName, obj); err != nil {
@@ -115,6 +224,7 @@ func (suh *StatusUpdateHandler) apply(upd StatusUpdate) {
 			suh.Log.WithField("name", upd.NamespacedName.Name).
 				WithField("namespace", upd.NamespacedName.Namespace).
 				Debug("update was a no-op")
+			skipped = true
 			return nil
 		}
 
@@ -123,21 +233,46 @@ func (suh *StatusUpdateHandler) apply(upd StatusUpdate) {
 			return fmt.Errorf("unable to convert object: %w", err)
 		}
 
+		// Only writes count against the rate limit, so
+		// that no-op updates are never held up.
+		if suh.RateLimiter != nil {
+			suh.RateLimiter.Accept()
+		}
+
 		_, err = suh.Clients.DynamicClient().
 			Resource(upd.Resource).
 			Namespace(upd.NamespacedName.Namespace).
 			UpdateStatus(context.Background(), usNewObj, metav1.UpdateOptions{})
 		return err
 	}); err != nil {
+		// A buffered update may be for an object
+		// that has since been deleted.
+		if k8serrors.IsNotFound(err) {
+			suh.Log.WithField("name", upd.NamespacedName.Name).
+				WithField("namespace", upd.NamespacedName.Namespace).
+				Debug("object no longer exists, not applying update")
+			suh.recordResult(upd, metrics.StatusUpdateSkipped)
+			return
+		}
+
 		suh.Log.WithError(err).
 			WithField("name", upd.NamespacedName.Name).
 			WithField("namespace", upd.NamespacedName.Namespace).
 			Error("unable to update status")
+		suh.recordResult(upd, metrics.StatusUpdateFailed)
+		return
+	}
+
+	if skipped {
+		suh.recordResult(upd, metrics.StatusUpdateSkipped)
+	} else {
+		suh.recordResult(upd, metrics.StatusUpdateWritten)
 	}
 }
 
 // Start runs the goroutine to perform status writes.
-// Until the Contour is elected leader, will drop updates on the floor.
+// Until the Contour is elected leader, updates are buffered,
+// and they are written as soon as it is elected.
 func (suh *StatusUpdateHandler) Start(stop <-chan struct{}) error {
 	for {
 		select {
@@ -148,11 +283,13 @@ func (suh *StatusUpdateHandler) Start(stop <-chan struct{}) error {
 			suh.IsLeader = true
 			// disable this case
 			suh.LeaderElected = nil
+			suh.flush()
 		case upd := <-suh.UpdateChannel:
 			if !suh.IsLeader {
 				suh.Log.WithField("name", upd.NamespacedName.Name).
 					WithField("namespace", upd.NamespacedName.Namespace).
-					Debug("not leader, not applying update")
+					Debug("not leader, buffering update")
+				suh.buffer(upd)
 				continue
 			}
 
@@ -160,7 +297,9 @@ func (suh *StatusUpdateHandler) Start(stop <-chan struct{}) error {
 				WithField("namespace", upd.NamespacedName.Namespace).
 				Debug("received a status update")
 
-			suh.apply(upd)
+			for _, u := range suh.coalesce(upd) {
+				suh.apply(u)
+			}
 		}
 
 	}
@@ -0,0 +1,89 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"testing"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusUpdateHandlerCoalesce(t *testing.T) {
+	setStatus := func(status string) StatusMutator {
+		return StatusMutatorFunc(func(obj interface{}) interface{} {
+			proxy := obj.(*contour_api_v1.HTTPProxy).DeepCopy()
+			proxy.Status.CurrentStatus = status
+			return proxy
+		})
+	}
+	setDescription := func(desc string) StatusMutator {
+		return StatusMutatorFunc(func(obj interface{}) interface{} {
+			proxy := obj.(*contour_api_v1.HTTPProxy).DeepCopy()
+			proxy.Status.Description = desc
+			return proxy
+		})
+	}
+
+	suh := StatusUpdateHandler{
+		Log:           fixture.NewTestLogger(t),
+		UpdateChannel: make(chan StatusUpdate, 10),
+	}
+
+	gvr := contour_api_v1.HTTPProxyGVR
+	suh.UpdateChannel <- NewStatusUpdate("a", "default", gvr, setDescription("first"))
+	suh.UpdateChannel <- NewStatusUpdate("b", "default", gvr, setStatus("invalid"))
+	suh.UpdateChannel <- NewStatusUpdate("a", "default", gvr, setDescription("second"))
+
+	updates := suh.coalesce(NewStatusUpdate("a", "default", gvr, setStatus("valid")))
+	require.Len(t, updates, 2)
+	assert.Empty(t, suh.UpdateChannel)
+
+	assert.Equal(t, "a", updates[0].NamespacedName.Name)
+	assert.Equal(t, contour_api_v1.HTTPProxyStatus{
+		CurrentStatus: "valid",
+		Description:   "second",
+	}, updates[0].Mutator.Mutate(&contour_api_v1.HTTPProxy{}).(*contour_api_v1.HTTPProxy).Status)
+
+	assert.Equal(t, "b", updates[1].NamespacedName.Name)
+	assert.Equal(t, contour_api_v1.HTTPProxyStatus{
+		CurrentStatus: "invalid",
+	}, updates[1].Mutator.Mutate(&contour_api_v1.HTTPProxy{}).(*contour_api_v1.HTTPProxy).Status)
+}
+
+func TestStatusUpdateHandlerBuffer(t *testing.T) {
+	suh := StatusUpdateHandler{
+		Log: fixture.NewTestLogger(t),
+	}
+
+	gvr := contour_api_v1.HTTPProxyGVR
+	first := NewStatusUpdate("a", "default", gvr, nil)
+	second := NewStatusUpdate("b", "default", gvr, nil)
+	latest := NewStatusUpdate("a", "default", gvr, StatusMutatorFunc(func(obj interface{}) interface{} {
+		return obj
+	}))
+
+	suh.buffer(first)
+	suh.buffer(second)
+	suh.buffer(latest)
+
+	// The latest update of each object is buffered, in
+	// the order the objects were first seen.
+	require.Len(t, suh.buffered, 2)
+	assert.Equal(t, "a", suh.buffered[0].NamespacedName.Name)
+	assert.NotNil(t, suh.buffered[0].Mutator)
+	assert.Equal(t, "b", suh.buffered[1].NamespacedName.Name)
+}
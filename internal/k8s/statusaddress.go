@@ -15,30 +15,58 @@ package k8s
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/ingressclass"
+	"github.com/projectcontour/contour/internal/shard"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 )
 
+// +kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=patch
+// +kubebuilder:rbac:groups="projectcontour.io",resources=httpproxies,verbs=patch
+
+// ExternalDNSHostnameAnnotation is the annotation that external-dns
+// reads the DNS names of an object from.
+const ExternalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+
+// AnnotationPatcher sets annotations on Kubernetes objects.
+type AnnotationPatcher interface {
+	// PatchAnnotations merges the given annotations into those of
+	// the named object.
+	PatchAnnotations(gvr schema.GroupVersionResource, name types.NamespacedName, annotations map[string]string) error
+}
+
 // StatusAddressUpdater observes informer OnAdd and OnUpdate events and
 // updates the ingress.status.loadBalancer field on all Ingress
-// objects that match the ingress class (if used).
+// objects that match the ingress class (if used). For root HTTPProxies,
+// the addresses are also published in status.virtualhost.
 // Note that this is intended to handle updating the status.loadBalancer struct only,
 // not more general status updates. That's a job for the StatusUpdater.
 type StatusAddressUpdater struct {
-	Logger           logrus.FieldLogger
-	LBStatus         v1.LoadBalancerStatus
-	IngressClassName string
-	StatusUpdater    StatusUpdater
-	Converter        Converter
+	Logger            logrus.FieldLogger
+	LBStatus          v1.LoadBalancerStatus
+	IngressClassNames []string
+	StatusUpdater     StatusUpdater
+	Converter         Converter
+
+	// AnnotationPatcher, if set, is used to publish the hostnames
+	// of each updated object on the ExternalDNSHostnameAnnotation.
+	AnnotationPatcher AnnotationPatcher
+
+	// Shard, if set, restricts the updated objects to the Ingresses
+	// and root HTTPProxies of the shard. Included HTTPProxies may be
+	// shared by shards, so are not updated.
+	Shard *shard.Selector
 
 	// mu guards the LBStatus field, which can be updated dynamically.
 	mu sync.Mutex
@@ -81,24 +109,39 @@ func (s *StatusAddressUpdater) OnAdd(obj interface{}) {
 			WithField("namespace", obj.GetNamespace()).
 			WithField("ingress-class-annotation", annotation.IngressClass(obj)).
 			WithField("kind", KindOf(obj)).
-			WithField("target-ingress-class", s.IngressClassName).
+			WithField("target-ingress-classes", s.IngressClassNames).
 			Debug("unmatched ingress class, skipping status address update")
 	}
 
+	logOutsideShard := func(logger logrus.FieldLogger, obj metav1.Object) {
+		logger.WithField("name", obj.GetName()).
+			WithField("namespace", obj.GetNamespace()).
+			WithField("kind", KindOf(obj)).
+			Debug("object outside of shard, skipping status address update")
+	}
+
 	switch o := obj.(type) {
 	case *networking_v1.Ingress:
-		if !ingressclass.MatchesIngress(o, s.IngressClassName) {
+		if !ingressclass.MatchesIngress(o, s.IngressClassNames) {
 			logNoMatch(s.Logger.WithField("ingress-class-name", pointer.StringPtrDerefOr(o.Spec.IngressClassName, "")), o)
 			return
 		}
+		if !s.Shard.Matches(o) {
+			logOutsideShard(s.Logger, o)
+			return
+		}
 		o.GetObjectKind().SetGroupVersionKind(networking_v1.SchemeGroupVersion.WithKind("ingress"))
 		typed = o.DeepCopy()
 		gvr = networking_v1.SchemeGroupVersion.WithResource("ingresses")
 	case *contour_api_v1.HTTPProxy:
-		if !ingressclass.MatchesHTTPProxy(o, s.IngressClassName) {
+		if !ingressclass.MatchesHTTPProxy(o, s.IngressClassNames) {
 			logNoMatch(s.Logger, o)
 			return
 		}
+		if s.Shard != nil && (o.Spec.VirtualHost == nil || !s.Shard.Matches(o)) {
+			logOutsideShard(s.Logger, o)
+			return
+		}
 		o.GetObjectKind().SetGroupVersionKind(contour_api_v1.SchemeGroupVersion.WithKind("httpproxy"))
 		typed = o.DeepCopy()
 		gvr = contour_api_v1.SchemeGroupVersion.WithResource("httpproxies")
@@ -112,7 +155,7 @@ func (s *StatusAddressUpdater) OnAdd(obj interface{}) {
 		WithField("namespace", typed.GetNamespace()).
 		WithField("ingress-class", annotation.IngressClass(typed)).
 		WithField("kind", KindOf(obj)).
-		WithField("defined-ingress-class", s.IngressClassName).
+		WithField("defined-ingress-classes", s.IngressClassNames).
 		Debug("received an object, sending status address update")
 
 	s.StatusUpdater.Send(NewStatusUpdate(
@@ -128,6 +171,7 @@ func (s *StatusAddressUpdater) OnAdd(obj interface{}) {
 			case *contour_api_v1.HTTPProxy:
 				dco := o.DeepCopy()
 				dco.Status.LoadBalancer = loadBalancerStatus
+				dco.Status.VirtualHost = virtualHostStatus(dco, loadBalancerStatus)
 				return dco
 			default:
 				panic(fmt.Sprintf("Unsupported object %s/%s in status Address mutator",
@@ -136,6 +180,77 @@ func (s *StatusAddressUpdater) OnAdd(obj interface{}) {
 			}
 		}),
 	))
+
+	if s.AnnotationPatcher != nil {
+		s.publishHostnames(typed, gvr)
+	}
+}
+
+// publishHostnames sets the ExternalDNSHostnameAnnotation of the
+// given Ingress or HTTPProxy to the hostnames it serves, unless the
+// annotation is already up to date.
+func (s *StatusAddressUpdater) publishHostnames(obj metav1.Object, gvr schema.GroupVersionResource) {
+	var hosts []string
+
+	switch o := obj.(type) {
+	case *networking_v1.Ingress:
+		seen := map[string]bool{}
+		for _, rule := range o.Spec.Rules {
+			if rule.Host != "" && !seen[rule.Host] {
+				seen[rule.Host] = true
+				hosts = append(hosts, rule.Host)
+			}
+		}
+		sort.Strings(hosts)
+	case *contour_api_v1.HTTPProxy:
+		if o.Spec.VirtualHost != nil && o.Spec.VirtualHost.Fqdn != "" {
+			hosts = append(hosts, o.Spec.VirtualHost.Fqdn)
+		}
+	}
+
+	// Objects without hostnames, such as included HTTPProxies,
+	// have nothing to publish.
+	if len(hosts) == 0 {
+		return
+	}
+
+	hostnames := strings.Join(hosts, ",")
+	if obj.GetAnnotations()[ExternalDNSHostnameAnnotation] == hostnames {
+		return
+	}
+
+	if err := s.AnnotationPatcher.PatchAnnotations(gvr, NamespacedNameOf(obj), map[string]string{
+		ExternalDNSHostnameAnnotation: hostnames,
+	}); err != nil {
+		s.Logger.WithError(err).
+			WithField("name", obj.GetName()).
+			WithField("namespace", obj.GetNamespace()).
+			WithField("kind", KindOf(obj)).
+			Error("unable to publish hostname annotation")
+	}
+}
+
+// virtualHostStatus returns the addresses serving the virtual host of
+// the given HTTPProxy, or nil if the HTTPProxy has no virtual host.
+func virtualHostStatus(proxy *contour_api_v1.HTTPProxy, lbs v1.LoadBalancerStatus) *contour_api_v1.VirtualHostStatus {
+	if proxy.Spec.VirtualHost == nil || proxy.Spec.VirtualHost.Fqdn == "" {
+		return nil
+	}
+
+	vhs := &contour_api_v1.VirtualHostStatus{
+		Fqdn: proxy.Spec.VirtualHost.Fqdn,
+	}
+
+	for _, ing := range lbs.Ingress {
+		switch {
+		case ing.IP != "":
+			vhs.Addresses = append(vhs.Addresses, ing.IP)
+		case ing.Hostname != "":
+			vhs.Addresses = append(vhs.Addresses, ing.Hostname)
+		}
+	}
+
+	return vhs
 }
 
 func (s *StatusAddressUpdater) OnUpdate(oldObj, newObj interface{}) {
@@ -19,12 +19,14 @@ import (
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/ingressclass"
+	"github.com/projectcontour/contour/internal/shard"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	networking_v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 )
 
@@ -195,137 +197,137 @@ func TestStatusAddressUpdater(t *testing.T) {
 	}
 
 	testCases := map[string]struct {
-		status           v1.LoadBalancerStatus
-		ingressClassName string
-		gvr              schema.GroupVersionResource
-		preop            interface{}
-		postop           interface{}
+		status            v1.LoadBalancerStatus
+		ingressClassNames []string
+		gvr               schema.GroupVersionResource
+		preop             interface{}
+		postop            interface{}
 	}{
 		"proxy: no-op add": {
-			status:           emptyLBStatus,
-			ingressClassName: "",
-			gvr:              proxyGVR,
-			preop:            simpleProxyGenerator(objName, "", emptyLBStatus),
-			postop:           simpleProxyGenerator(objName, "", emptyLBStatus),
+			status:            emptyLBStatus,
+			ingressClassNames: nil,
+			gvr:               proxyGVR,
+			preop:             simpleProxyGenerator(objName, "", emptyLBStatus),
+			postop:            simpleProxyGenerator(objName, "", emptyLBStatus),
 		},
 		"proxy: add an IP should update": {
-			status:           ipLBStatus,
-			ingressClassName: "",
-			gvr:              proxyGVR,
-			preop:            simpleProxyGenerator(objName, "", emptyLBStatus),
-			postop:           simpleProxyGenerator(objName, "", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: nil,
+			gvr:               proxyGVR,
+			preop:             simpleProxyGenerator(objName, "", emptyLBStatus),
+			postop:            simpleProxyGenerator(objName, "", ipLBStatus),
 		},
 		"proxy: unset ingressclass should not update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              proxyGVR,
-			preop:            simpleProxyGenerator(objName, "", emptyLBStatus),
-			postop:           simpleProxyGenerator(objName, "", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               proxyGVR,
+			preop:             simpleProxyGenerator(objName, "", emptyLBStatus),
+			postop:            simpleProxyGenerator(objName, "", emptyLBStatus),
 		},
 		"proxy: non-matching ingressclass should not update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              proxyGVR,
-			preop:            simpleProxyGenerator(objName, "other", emptyLBStatus),
-			postop:           simpleProxyGenerator(objName, "other", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               proxyGVR,
+			preop:             simpleProxyGenerator(objName, "other", emptyLBStatus),
+			postop:            simpleProxyGenerator(objName, "other", emptyLBStatus),
 		},
 		"proxy: matching ingressclass should update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              proxyGVR,
-			preop:            simpleProxyGenerator(objName, "phony", emptyLBStatus),
-			postop:           simpleProxyGenerator(objName, "phony", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               proxyGVR,
+			preop:             simpleProxyGenerator(objName, "phony", emptyLBStatus),
+			postop:            simpleProxyGenerator(objName, "phony", ipLBStatus),
 		},
 		"ingress: no-op update": {
-			status:           emptyLBStatus,
-			ingressClassName: "",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "", "", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "", "", emptyLBStatus),
+			status:            emptyLBStatus,
+			ingressClassNames: nil,
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "", "", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "", "", emptyLBStatus),
 		},
 		"ingress: add an IP should update": {
-			status:           ipLBStatus,
-			ingressClassName: "",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "", "", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "", "", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: nil,
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "", "", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "", "", ipLBStatus),
 		},
 		"ingress: unset ingressclass should not update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "", "", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "", "", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "", "", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "", "", emptyLBStatus),
 		},
 		"ingress: not-configured ingressclass, annotation set to default, should update": {
-			status:           ipLBStatus,
-			ingressClassName: "",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, ingressclass.DefaultClassName, "", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, ingressclass.DefaultClassName, "", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: nil,
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, ingressclass.DefaultClassName, "", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, ingressclass.DefaultClassName, "", ipLBStatus),
 		},
 		"ingress: not-configured ingressclass, spec field set to default, should update": {
-			status:           ipLBStatus,
-			ingressClassName: "",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "", "contour", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "", "contour", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: nil,
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "", "contour", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "", "contour", ipLBStatus),
 		},
 		"ingress: not-configured ingressclass, annotation set, should not update": {
-			status:           ipLBStatus,
-			ingressClassName: "",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "something", "", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "something", "", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: nil,
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "something", "", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "something", "", emptyLBStatus),
 		},
 		"ingress: not-configured ingressclass, spec field set, should not update": {
-			status:           ipLBStatus,
-			ingressClassName: "",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "", "something", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "", "something", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: nil,
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "", "something", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "", "something", emptyLBStatus),
 		},
 		"ingress: non-matching ingressclass annotation should not update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "other", "", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "other", "", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "other", "", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "other", "", emptyLBStatus),
 		},
 		"ingress: non-matching ingressclass spec field should not update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "", "other", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "", "other", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "", "other", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "", "other", emptyLBStatus),
 		},
 		"ingress: matching ingressclass annotation should update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "phony", "", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "phony", "", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "phony", "", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "phony", "", ipLBStatus),
 		},
 		"ingress: matching ingressclass spec field should update": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "", "phony", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "", "phony", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "", "phony", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "", "phony", ipLBStatus),
 		},
 		"ingress: non-matching ingressclass annotation should not update, overrides spec field": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "other", "phony", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "other", "phony", emptyLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "other", "phony", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "other", "phony", emptyLBStatus),
 		},
 		"ingress: matching ingressclass spec field should update, overrides spec field": {
-			status:           ipLBStatus,
-			ingressClassName: "phony",
-			gvr:              ingressGVR,
-			preop:            simpleIngressGenerator(objName, "phony", "notcorrect", emptyLBStatus),
-			postop:           simpleIngressGenerator(objName, "phony", "notcorrect", ipLBStatus),
+			status:            ipLBStatus,
+			ingressClassNames: []string{"phony"},
+			gvr:               ingressGVR,
+			preop:             simpleIngressGenerator(objName, "phony", "notcorrect", emptyLBStatus),
+			postop:            simpleIngressGenerator(objName, "phony", "notcorrect", ipLBStatus),
 		},
 	}
 
@@ -335,11 +337,11 @@ func TestStatusAddressUpdater(t *testing.T) {
 			assert.True(t, suc.Add(objName, objName, tc.gvr, tc.preop), "unable to add object to cache")
 
 			isu := StatusAddressUpdater{
-				Logger:           log,
-				LBStatus:         tc.status,
-				IngressClassName: tc.ingressClassName,
-				StatusUpdater:    &suc,
-				Converter:        converter,
+				Logger:            log,
+				LBStatus:          tc.status,
+				IngressClassNames: tc.ingressClassNames,
+				StatusUpdater:     &suc,
+				Converter:         converter,
 			}
 
 			isu.OnAdd(tc.preop)
@@ -353,11 +355,11 @@ func TestStatusAddressUpdater(t *testing.T) {
 			assert.True(t, suc.Add(objName, objName, tc.gvr, tc.preop), "unable to add object to cache")
 
 			isu := StatusAddressUpdater{
-				Logger:           log,
-				LBStatus:         tc.status,
-				IngressClassName: tc.ingressClassName,
-				StatusUpdater:    &suc,
-				Converter:        converter,
+				Logger:            log,
+				LBStatus:          tc.status,
+				IngressClassNames: tc.ingressClassNames,
+				StatusUpdater:     &suc,
+				Converter:         converter,
 			}
 
 			isu.OnUpdate(tc.preop, tc.preop)
@@ -368,6 +370,246 @@ func TestStatusAddressUpdater(t *testing.T) {
 	}
 }
 
+type annotationPatcherRecorder struct {
+	patches map[types.NamespacedName]map[string]string
+}
+
+func (a *annotationPatcherRecorder) PatchAnnotations(gvr schema.GroupVersionResource, name types.NamespacedName, annotations map[string]string) error {
+	if a.patches == nil {
+		a.patches = map[types.NamespacedName]map[string]string{}
+	}
+	a.patches[name] = annotations
+	return nil
+}
+
+func TestStatusAddressUpdaterPublishHostnames(t *testing.T) {
+	const objName = "someobjfoo"
+	nsName := types.NamespacedName{Namespace: objName, Name: objName}
+
+	converter, err := NewUnstructuredConverter()
+	if err != nil {
+		t.Error(err)
+	}
+
+	ipLBStatus := v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{
+			{
+				IP: "127.0.0.1",
+			},
+		},
+	}
+
+	ingress := simpleIngressGenerator(objName, "", "", v1.LoadBalancerStatus{})
+	ingress.Spec.Rules = []networking_v1.IngressRule{
+		{Host: "b.projectcontour.io"},
+		{Host: "a.projectcontour.io"},
+		{Host: "b.projectcontour.io"},
+		{},
+	}
+
+	rootProxy := simpleProxyGenerator(objName, "", v1.LoadBalancerStatus{})
+	rootProxy.Spec.VirtualHost = &contour_api_v1.VirtualHost{Fqdn: "proxy.projectcontour.io"}
+
+	publishedProxy := rootProxy.DeepCopy()
+	publishedProxy.Annotations[ExternalDNSHostnameAnnotation] = "proxy.projectcontour.io"
+
+	testCases := map[string]struct {
+		obj  interface{}
+		gvr  schema.GroupVersionResource
+		want map[types.NamespacedName]map[string]string
+	}{
+		"ingress rule hosts are published": {
+			obj: ingress,
+			gvr: networking_v1.SchemeGroupVersion.WithResource("ingresses"),
+			want: map[types.NamespacedName]map[string]string{
+				nsName: {ExternalDNSHostnameAnnotation: "a.projectcontour.io,b.projectcontour.io"},
+			},
+		},
+		"root proxy fqdn is published": {
+			obj: rootProxy,
+			gvr: contour_api_v1.HTTPProxyGVR,
+			want: map[types.NamespacedName]map[string]string{
+				nsName: {ExternalDNSHostnameAnnotation: "proxy.projectcontour.io"},
+			},
+		},
+		"included proxy is not published": {
+			obj: simpleProxyGenerator(objName, "", v1.LoadBalancerStatus{}),
+			gvr: contour_api_v1.HTTPProxyGVR,
+		},
+		"up to date annotation is not published": {
+			obj: publishedProxy,
+			gvr: contour_api_v1.HTTPProxyGVR,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			suc := StatusUpdateCacher{}
+			assert.True(t, suc.Add(objName, objName, tc.gvr, tc.obj), "unable to add object to cache")
+
+			patcher := annotationPatcherRecorder{}
+			isu := StatusAddressUpdater{
+				Logger:            fixture.NewTestLogger(t),
+				LBStatus:          ipLBStatus,
+				StatusUpdater:     &suc,
+				Converter:         converter,
+				AnnotationPatcher: &patcher,
+			}
+
+			isu.OnAdd(tc.obj)
+
+			assert.Equal(t, tc.want, patcher.patches)
+		})
+	}
+}
+
+func TestStatusAddressUpdaterVirtualHost(t *testing.T) {
+	const objName = "someobjfoo"
+
+	converter, err := NewUnstructuredConverter()
+	if err != nil {
+		t.Error(err)
+	}
+
+	lbStatus := v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{
+			{IP: "127.0.0.1"},
+			{Hostname: "lb.projectcontour.io"},
+		},
+	}
+
+	rootProxy := simpleProxyGenerator(objName, "", v1.LoadBalancerStatus{})
+	rootProxy.Spec.VirtualHost = &contour_api_v1.VirtualHost{Fqdn: "proxy.projectcontour.io"}
+
+	testCases := map[string]struct {
+		obj  *contour_api_v1.HTTPProxy
+		want *contour_api_v1.VirtualHostStatus
+	}{
+		"root proxy addresses are published": {
+			obj: rootProxy,
+			want: &contour_api_v1.VirtualHostStatus{
+				Fqdn:      "proxy.projectcontour.io",
+				Addresses: []string{"127.0.0.1", "lb.projectcontour.io"},
+			},
+		},
+		"included proxy has no virtual host status": {
+			obj:  simpleProxyGenerator(objName, "", v1.LoadBalancerStatus{}),
+			want: nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			suc := StatusUpdateCacher{}
+			assert.True(t, suc.Add(objName, objName, contour_api_v1.HTTPProxyGVR, tc.obj), "unable to add object to cache")
+
+			isu := StatusAddressUpdater{
+				Logger:        fixture.NewTestLogger(t),
+				LBStatus:      lbStatus,
+				StatusUpdater: &suc,
+				Converter:     converter,
+			}
+
+			isu.OnAdd(tc.obj)
+
+			proxy := suc.Get(objName, objName, contour_api_v1.HTTPProxyGVR).(*contour_api_v1.HTTPProxy)
+			assert.Equal(t, tc.want, proxy.Status.VirtualHost)
+		})
+	}
+}
+
+func TestStatusAddressUpdaterShard(t *testing.T) {
+	const objName = "someobjfoo"
+
+	converter, err := NewUnstructuredConverter()
+	if err != nil {
+		t.Error(err)
+	}
+
+	ipLBStatus := v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{
+			{
+				IP: "127.0.0.1",
+			},
+		},
+	}
+
+	rootProxy := func() *contour_api_v1.HTTPProxy {
+		proxy := simpleProxyGenerator(objName, "", v1.LoadBalancerStatus{})
+		proxy.Spec.VirtualHost = &contour_api_v1.VirtualHost{Fqdn: "proxy.projectcontour.io"}
+		return proxy
+	}
+
+	testCases := map[string]struct {
+		obj   metav1.Object
+		gvr   schema.GroupVersionResource
+		shard *shard.Selector
+		want  v1.LoadBalancerStatus
+	}{
+		"ingress in shard": {
+			obj:   simpleIngressGenerator(objName, "", "", v1.LoadBalancerStatus{}),
+			gvr:   networking_v1.SchemeGroupVersion.WithResource("ingresses"),
+			shard: &shard.Selector{Namespaces: []string{objName}},
+			want:  ipLBStatus,
+		},
+		"ingress outside of shard": {
+			obj:   simpleIngressGenerator(objName, "", "", v1.LoadBalancerStatus{}),
+			gvr:   networking_v1.SchemeGroupVersion.WithResource("ingresses"),
+			shard: &shard.Selector{Namespaces: []string{"other"}},
+			want:  v1.LoadBalancerStatus{},
+		},
+		"root proxy in shard": {
+			obj:   rootProxy(),
+			gvr:   contour_api_v1.HTTPProxyGVR,
+			shard: &shard.Selector{Namespaces: []string{objName}},
+			want:  ipLBStatus,
+		},
+		"root proxy outside of shard": {
+			obj:   rootProxy(),
+			gvr:   contour_api_v1.HTTPProxyGVR,
+			shard: &shard.Selector{Namespaces: []string{"other"}},
+			want:  v1.LoadBalancerStatus{},
+		},
+		"included proxy with shard": {
+			obj:   simpleProxyGenerator(objName, "", v1.LoadBalancerStatus{}),
+			gvr:   contour_api_v1.HTTPProxyGVR,
+			shard: &shard.Selector{Namespaces: []string{objName}},
+			want:  v1.LoadBalancerStatus{},
+		},
+		"included proxy without shard": {
+			obj:  simpleProxyGenerator(objName, "", v1.LoadBalancerStatus{}),
+			gvr:  contour_api_v1.HTTPProxyGVR,
+			want: ipLBStatus,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			suc := StatusUpdateCacher{}
+			assert.True(t, suc.Add(objName, objName, tc.gvr, tc.obj), "unable to add object to cache")
+
+			isu := StatusAddressUpdater{
+				Logger:        fixture.NewTestLogger(t),
+				LBStatus:      ipLBStatus,
+				StatusUpdater: &suc,
+				Converter:     converter,
+				Shard:         tc.shard,
+			}
+
+			isu.OnAdd(tc.obj)
+
+			switch obj := suc.Get(objName, objName, tc.gvr).(type) {
+			case *networking_v1.Ingress:
+				assert.Equal(t, tc.want, obj.Status.LoadBalancer)
+			case *contour_api_v1.HTTPProxy:
+				assert.Equal(t, tc.want, obj.Status.LoadBalancer)
+			default:
+				t.Fatalf("unexpected object %T", obj)
+			}
+		})
+	}
+}
+
 func simpleIngressGenerator(name, ingressClassAnnotation, ingressClassSpec string, lbstatus v1.LoadBalancerStatus) *networking_v1.Ingress {
 	annotations := make(map[string]string)
 	if ingressClassAnnotation != "" {
@@ -0,0 +1,22 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package v1alpha1 contains the subset of the Knative
+// networking.internal.knative.dev v1alpha1 API group that Contour
+// needs to act as a Knative networking layer. The types mirror the
+// wire format of knative.dev/networking so that Knative Ingress
+// resources can be decoded without depending on the Knative modules.
+//
+// +k8s:deepcopy-gen=package
+// +groupName=networking.internal.knative.dev
+package v1alpha1
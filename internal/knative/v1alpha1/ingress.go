@@ -0,0 +1,269 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// ClassAnnotationKey is the annotation Knative uses to select
+	// the networking layer that implements an Ingress.
+	ClassAnnotationKey = "networking.knative.dev/ingress.class"
+
+	// ContourIngressClassName is the ingress class that selects
+	// Contour as the Knative networking layer.
+	ContourIngressClassName = "contour.ingress.networking.knative.dev"
+)
+
+// Ingress is a collection of rules that allow inbound connections to
+// reach the endpoints defined by a backend.
+type Ingress struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IngressSpec   `json:"spec,omitempty"`
+	Status IngressStatus `json:"status,omitempty"`
+}
+
+// IngressList is a collection of Ingress objects.
+type IngressList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Ingress `json:"items"`
+}
+
+// IngressSpec describes the Ingress the user wishes to exist.
+type IngressSpec struct {
+	// TLS configuration.
+	TLS []IngressTLS `json:"tls,omitempty"`
+
+	// A list of host rules used to configure the Ingress.
+	Rules []IngressRule `json:"rules,omitempty"`
+
+	// HTTPOption is the option of HTTP. It has the following two values:
+	// `HTTPOptionEnabled`, `HTTPOptionRedirected`
+	HTTPOption HTTPOption `json:"httpOption,omitempty"`
+}
+
+// HTTPOption is the behavior of an Ingress for plain HTTP requests.
+type HTTPOption string
+
+const (
+	// HTTPOptionEnabled serves plain HTTP requests.
+	HTTPOptionEnabled HTTPOption = "Enabled"
+
+	// HTTPOptionRedirected redirects plain HTTP requests to HTTPS.
+	HTTPOptionRedirected HTTPOption = "Redirected"
+)
+
+// IngressVisibility describes whether the Ingress should be exposed to
+// public gateways or not.
+type IngressVisibility string
+
+const (
+	// IngressVisibilityExternalIP is used to denote that the Ingress
+	// should be exposed via an external IP, for example a LoadBalancer
+	// Service. This is the default value for IngressVisibility.
+	IngressVisibilityExternalIP IngressVisibility = "ExternalIP"
+
+	// IngressVisibilityClusterLocal is used to denote that the Ingress
+	// should be only be exposed locally to the cluster.
+	IngressVisibilityClusterLocal IngressVisibility = "ClusterLocal"
+)
+
+// IngressTLS describes the transport layer security associated with an Ingress.
+type IngressTLS struct {
+	// Hosts is a list of hosts included in the TLS certificate.
+	Hosts []string `json:"hosts,omitempty"`
+
+	// SecretName is the name of the secret used to terminate SSL traffic.
+	SecretName string `json:"secretName,omitempty"`
+
+	// SecretNamespace is the namespace of the secret used to terminate SSL traffic.
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+}
+
+// IngressRule represents the rules mapping the paths under a specified
+// host to the related backend services.
+type IngressRule struct {
+	// Hosts are the fully qualified domain names of network hosts
+	// matched by this rule.
+	Hosts []string `json:"hosts,omitempty"`
+
+	// Visibility signifies whether this rule should be exposed
+	// externally or only within the cluster.
+	Visibility IngressVisibility `json:"visibility,omitempty"`
+
+	// HTTP represents a rule to apply against incoming requests.
+	HTTP *HTTPIngressRuleValue `json:"http,omitempty"`
+}
+
+// HTTPIngressRuleValue is a list of http selectors pointing to backends.
+type HTTPIngressRuleValue struct {
+	// A collection of paths that map requests to backends.
+	Paths []HTTPIngressPath `json:"paths"`
+}
+
+// HTTPIngressPath associates a path regex with a backend. Incoming URLs
+// matching the path are forwarded to the backend.
+type HTTPIngressPath struct {
+	// Path represents a literal prefix to which this rule should apply.
+	// If unspecified, the path defaults to a catch all sending traffic
+	// to the backend.
+	Path string `json:"path,omitempty"`
+
+	// RewriteHost rewrites the incoming request's host header.
+	RewriteHost string `json:"rewriteHost,omitempty"`
+
+	// Headers defines header matching rules which is a map from a
+	// header name to HeaderMatch which specify a matching condition.
+	// When a request matched with all the header matching rules,
+	// the request is routed by the corresponding ingress rule.
+	Headers map[string]HeaderMatch `json:"headers,omitempty"`
+
+	// Splits defines the referenced service endpoints to which the
+	// traffic will be forwarded to.
+	Splits []IngressBackendSplit `json:"splits"`
+
+	// AppendHeaders allow specifying additional HTTP headers to add
+	// before forwarding a request to the destination service.
+	AppendHeaders map[string]string `json:"appendHeaders,omitempty"`
+}
+
+// HeaderMatch represents a matching value of Headers in HTTPIngressPath.
+type HeaderMatch struct {
+	Exact string `json:"exact"`
+}
+
+// IngressBackendSplit describes all endpoints for a given service and port.
+type IngressBackendSplit struct {
+	// Specifies the backend receiving the traffic split.
+	IngressBackend `json:",inline"`
+
+	// Specifies the split percentage, a number between 0 and 100.
+	Percent int `json:"percent,omitempty"`
+
+	// AppendHeaders allow specifying additional HTTP headers to add
+	// before forwarding a request to the destination service.
+	AppendHeaders map[string]string `json:"appendHeaders,omitempty"`
+}
+
+// IngressBackend describes all endpoints for a given service and port.
+type IngressBackend struct {
+	// Specifies the namespace of the referenced service.
+	ServiceNamespace string `json:"serviceNamespace"`
+
+	// Specifies the name of the referenced service.
+	ServiceName string `json:"serviceName"`
+
+	// Specifies the port of the referenced service.
+	ServicePort intstr.IntOrString `json:"servicePort"`
+}
+
+// IngressStatus describe the current state of the Ingress.
+type IngressStatus struct {
+	// ObservedGeneration is the 'Generation' of the Ingress that
+	// was last processed by the controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions the latest available observations of a resource's current state.
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// Annotations is additional Status fields for the Resource to save some
+	// additional State as well as convey more information to the user.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// PublicLoadBalancer contains the current status of the load-balancer.
+	PublicLoadBalancer *LoadBalancerStatus `json:"publicLoadBalancer,omitempty"`
+
+	// PrivateLoadBalancer contains the current status of the load-balancer.
+	PrivateLoadBalancer *LoadBalancerStatus `json:"privateLoadBalancer,omitempty"`
+}
+
+// GetConditionFor returns the Condition of the given type, or nil if
+// no such condition exists.
+func (status *IngressStatus) GetConditionFor(condType string) *Condition {
+	for i, cond := range status.Conditions {
+		if cond.Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// Condition types for the Knative Ingress status.
+const (
+	// IngressConditionReady is set when the Ingress networking
+	// setting is configured and it has a load balancer address.
+	IngressConditionReady = "Ready"
+
+	// IngressConditionNetworkConfigured is set when the Ingress's
+	// underlying network programming has been configured.
+	IngressConditionNetworkConfigured = "NetworkConfigured"
+
+	// IngressConditionLoadBalancerReady is set when the Ingress has
+	// a ready LoadBalancer.
+	IngressConditionLoadBalancerReady = "LoadBalancerReady"
+)
+
+// Condition defines a readiness condition for a Knative resource.
+type Condition struct {
+	// Type of condition.
+	Type string `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+
+	// Severity with which to treat failures of this type of condition.
+	Severity string `json:"severity,omitempty"`
+
+	// LastTransitionTime is the last time the condition transitioned
+	// from one status to another.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// The reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"`
+
+	// A human readable message indicating details about the transition.
+	Message string `json:"message,omitempty"`
+}
+
+// LoadBalancerStatus represents the status of a load-balancer.
+type LoadBalancerStatus struct {
+	// Ingress is a list containing ingress points for the load-balancer.
+	Ingress []LoadBalancerIngressStatus `json:"ingress,omitempty"`
+}
+
+// LoadBalancerIngressStatus represents the status of a load-balancer
+// ingress point.
+type LoadBalancerIngressStatus struct {
+	// IP is set for load-balancer ingress points that are IP based.
+	IP string `json:"ip,omitempty"`
+
+	// Domain is set for load-balancer ingress points that are DNS based.
+	Domain string `json:"domain,omitempty"`
+
+	// DomainInternal is set if there is a cluster-local DNS name to
+	// access the Ingress.
+	DomainInternal string `json:"domainInternal,omitempty"`
+
+	// MeshOnly is set if the Ingress is only load-balanced through
+	// a Service mesh.
+	MeshOnly bool `json:"meshOnly,omitempty"`
+}
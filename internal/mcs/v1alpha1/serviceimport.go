@@ -0,0 +1,94 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LabelServiceName is the label an MCS implementation sets on
+	// the EndpointSlices of an imported service. Its value is the
+	// name of the ServiceImport in the EndpointSlice's namespace.
+	LabelServiceName = "multicluster.kubernetes.io/service-name"
+
+	// ServiceImportKind is the kind used to reference a
+	// ServiceImport from a route backend.
+	ServiceImportKind = "ServiceImport"
+)
+
+// ServiceImportType designates the type of a ServiceImport.
+type ServiceImportType string
+
+const (
+	// ClusterSetIP services are only accessible via the
+	// ClusterSet IP.
+	ClusterSetIP ServiceImportType = "ClusterSetIP"
+	// Headless services allow backend pods to be addressed
+	// directly.
+	Headless ServiceImportType = "Headless"
+)
+
+// ServiceImport describes a service imported from clusters in a
+// ClusterSet.
+type ServiceImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceImportSpec   `json:"spec,omitempty"`
+	Status ServiceImportStatus `json:"status,omitempty"`
+}
+
+// ServiceImportSpec describes an imported service and the
+// information necessary to consume it.
+type ServiceImportSpec struct {
+	Ports []ServicePort `json:"ports"`
+	// IPs are the ClusterSet IPs of the imported service.
+	IPs  []string          `json:"ips,omitempty"`
+	Type ServiceImportType `json:"type"`
+	// SessionAffinity is the session affinity of the imported
+	// service, as for a Service.
+	SessionAffinity       corev1.ServiceAffinity        `json:"sessionAffinity,omitempty"`
+	SessionAffinityConfig *corev1.SessionAffinityConfig `json:"sessionAffinityConfig,omitempty"`
+}
+
+// ServicePort represents the port on which the service is exposed.
+type ServicePort struct {
+	Name        string          `json:"name,omitempty"`
+	Protocol    corev1.Protocol `json:"protocol,omitempty"`
+	AppProtocol *string         `json:"appProtocol,omitempty"`
+	Port        int32           `json:"port"`
+}
+
+// ServiceImportStatus describes derived state of an imported service.
+type ServiceImportStatus struct {
+	// Clusters is the list of exporting clusters from which
+	// this service was derived.
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+}
+
+// ClusterStatus contains service configuration mapped to a
+// specific source cluster.
+type ClusterStatus struct {
+	Cluster string `json:"cluster"`
+}
+
+// ServiceImportList contains a list of ServiceImports.
+type ServiceImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ServiceImport `json:"items"`
+}
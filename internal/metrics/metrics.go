@@ -35,6 +35,13 @@ type Metrics struct {
 
 	dagRebuildGauge             *prometheus.GaugeVec
 	dagRebuildTotal             prometheus.Counter
+	dagRebuildBlockedGauge      prometheus.Gauge
+	dagRebuildBlockedTotal      prometheus.Counter
+	dagRebuildDurationSummary   prometheus.Summary
+	dagRoutesGauge              prometheus.Gauge
+	dagClustersGauge            prometheus.Gauge
+	xdsSnapshotGauge            prometheus.Gauge
+	statusUpdateTotal           *prometheus.CounterVec
 	CacheHandlerOnUpdateSummary prometheus.Summary
 	EventHandlerOperations      *prometheus.CounterVec
 
@@ -67,6 +74,13 @@ const (
 
 	DAGRebuildGauge             = "contour_dagrebuild_timestamp"
 	DAGRebuildTotal             = "contour_dagrebuild_total"
+	DAGRebuildBlockedGauge      = "contour_dagrebuild_blocked"
+	DAGRebuildBlockedTotal      = "contour_dagrebuild_blocked_total"
+	DAGRebuildDurationSummary   = "contour_dagrebuild_duration_seconds"
+	DAGRoutesGauge              = "contour_dag_routes"
+	DAGClustersGauge            = "contour_dag_clusters"
+	XDSSnapshotGauge            = "contour_xds_snapshot_timestamp"
+	StatusUpdateTotal           = "contour_status_update_total"
 	cacheHandlerOnUpdateSummary = "contour_cachehandler_onupdate_duration_seconds"
 	eventHandlerOperations      = "contour_eventhandler_operation_total"
 )
@@ -135,6 +149,48 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 				Help: "Total number of times DAG has been rebuilt since startup",
 			},
 		),
+		dagRebuildBlockedGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: DAGRebuildBlockedGauge,
+				Help: "Set to 1 if the last DAG rebuild was not applied because it would remove too many routes or services, otherwise 0.",
+			},
+		),
+		dagRebuildBlockedTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: DAGRebuildBlockedTotal,
+				Help: "Total number of DAG rebuilds that were not applied because they would remove too many routes or services.",
+			},
+		),
+		dagRebuildDurationSummary: prometheus.NewSummary(prometheus.SummaryOpts{
+			Name:       DAGRebuildDurationSummary,
+			Help:       "Duration of DAG rebuilds.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+		dagRoutesGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: DAGRoutesGauge,
+				Help: "Number of routes in the last DAG rebuild.",
+			},
+		),
+		dagClustersGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: DAGClustersGauge,
+				Help: "Number of clusters in the last DAG rebuild.",
+			},
+		),
+		xdsSnapshotGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: XDSSnapshotGauge,
+				Help: "Timestamp of the last xDS snapshot that was successfully generated for Envoy.",
+			},
+		),
+		statusUpdateTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: StatusUpdateTotal,
+				Help: "Total number of object status updates by resource and result. The result is one of written, skipped when the status is unchanged, coalesced into a later update of the same object, or failed.",
+			},
+			[]string{"resource", "result"},
+		),
 		CacheHandlerOnUpdateSummary: prometheus.NewSummary(prometheus.SummaryOpts{
 			Name:       cacheHandlerOnUpdateSummary,
 			Help:       "Histogram for the runtime of xDS cache regeneration.",
@@ -164,6 +220,13 @@ func (m *Metrics) register(registry *prometheus.Registry) {
 		m.proxyOrphanedGauge,
 		m.dagRebuildGauge,
 		m.dagRebuildTotal,
+		m.dagRebuildBlockedGauge,
+		m.dagRebuildBlockedTotal,
+		m.dagRebuildDurationSummary,
+		m.dagRoutesGauge,
+		m.dagClustersGauge,
+		m.xdsSnapshotGauge,
+		m.statusUpdateTotal,
 		m.CacheHandlerOnUpdateSummary,
 		m.EventHandlerOperations,
 	)
@@ -187,7 +250,12 @@ func (m *Metrics) Zero() {
 	}
 
 	m.SetDAGLastRebuilt(time.Now())
+	m.SetDAGRebuildBlocked(false)
+	m.SetDAGRebuildDuration(0)
+	m.SetDAGRouteAndClusterCount(0, 0)
+	m.SetXDSSnapshotTimestamp(time.Now())
 	m.SetHTTPProxyMetric(zeroes)
+	m.SetStatusUpdateResult("httpproxies", StatusUpdateWritten)
 	m.EventHandlerOperations.WithLabelValues("add", "Secret").Inc()
 
 	prometheus.NewTimer(m.CacheHandlerOnUpdateSummary).ObserveDuration()
@@ -203,6 +271,49 @@ func (m *Metrics) SetDAGRebuiltTotal() {
 	m.dagRebuildTotal.Inc()
 }
 
+// SetDAGRebuildBlocked records whether the last DAG rebuild was
+// blocked from being applied, and counts the blocked rebuilds.
+func (m *Metrics) SetDAGRebuildBlocked(blocked bool) {
+	if blocked {
+		m.dagRebuildBlockedGauge.Set(1)
+		m.dagRebuildBlockedTotal.Inc()
+	} else {
+		m.dagRebuildBlockedGauge.Set(0)
+	}
+}
+
+// SetDAGRebuildDuration records how long a DAG rebuild took.
+func (m *Metrics) SetDAGRebuildDuration(d time.Duration) {
+	m.dagRebuildDurationSummary.Observe(d.Seconds())
+}
+
+// SetDAGRouteAndClusterCount records the number of routes and
+// clusters in the last DAG rebuild.
+func (m *Metrics) SetDAGRouteAndClusterCount(routes, clusters int) {
+	m.dagRoutesGauge.Set(float64(routes))
+	m.dagClustersGauge.Set(float64(clusters))
+}
+
+// SetXDSSnapshotTimestamp records the last time an xDS snapshot
+// was successfully generated.
+func (m *Metrics) SetXDSSnapshotTimestamp(ts time.Time) {
+	m.xdsSnapshotGauge.Set(float64(ts.Unix()))
+}
+
+// Results of a status update recorded by SetStatusUpdateResult.
+const (
+	StatusUpdateWritten   = "written"
+	StatusUpdateSkipped   = "skipped"
+	StatusUpdateCoalesced = "coalesced"
+	StatusUpdateFailed    = "failed"
+)
+
+// SetStatusUpdateResult records the result of a status update
+// of an object of the given resource.
+func (m *Metrics) SetStatusUpdateResult(resource, result string) {
+	m.statusUpdateTotal.WithLabelValues(resource, result).Inc()
+}
+
 // SetHTTPProxyMetric sets metric values for a set of HTTPProxies
 func (m *Metrics) SetHTTPProxyMetric(metrics RouteMetric) {
 	// Process metrics
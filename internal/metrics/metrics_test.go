@@ -75,6 +75,56 @@ func TestSetDAGLastRebuilt(t *testing.T) {
 	}
 }
 
+func TestSetDAGRouteAndClusterCount(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m := NewMetrics(r)
+	m.SetDAGRouteAndClusterCount(12, 5)
+
+	gathering, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]float64{}
+	for _, mf := range gathering {
+		switch mf.GetName() {
+		case DAGRoutesGauge, DAGClustersGauge:
+			got[mf.GetName()] = mf.Metric[0].GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, map[string]float64{
+		DAGRoutesGauge:   12,
+		DAGClustersGauge: 5,
+	}, got)
+}
+
+func TestSetXDSSnapshotTimestamp(t *testing.T) {
+	r := prometheus.NewRegistry()
+	m := NewMetrics(r)
+	m.SetXDSSnapshotTimestamp(time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC))
+
+	gathering, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []*io_prometheus_client.Metric{}
+	for _, mf := range gathering {
+		if mf.GetName() == XDSSnapshotGauge {
+			got = mf.Metric
+		}
+	}
+
+	want := []*io_prometheus_client.Metric{{
+		Label: []*io_prometheus_client.LabelPair{},
+		Gauge: &io_prometheus_client.Gauge{
+			Value: func() *float64 { i := float64(1.258490098e+09); return &i }(),
+		},
+	}}
+	assert.Equal(t, want, got)
+}
+
 func TestWriteProxyMetric(t *testing.T) {
 	tests := map[string]struct {
 		proxyMetrics RouteMetric
@@ -62,6 +62,18 @@ func UInt32OrNil(val uint32) *wrappers.UInt32Value {
 	}
 }
 
+// UInt64OrNil returns a wrapped UInt64Value. If val is 0, nil is returned
+func UInt64OrNil(val uint64) *wrappers.UInt64Value {
+	switch val {
+	case 0:
+		return nil
+	default:
+		return &wrappers.UInt64Value{
+			Value: val,
+		}
+	}
+}
+
 // Bool converts a bool to a pointer to a wrappers.BoolValue.
 func Bool(val bool) *wrappers.BoolValue {
 	return &wrappers.BoolValue{
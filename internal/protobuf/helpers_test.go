@@ -29,3 +29,8 @@ func TestU32Default(t *testing.T) {
 	assert.Equal(t, UInt32(99), UInt32OrDefault(0, 99))
 	assert.Equal(t, UInt32(1), UInt32OrDefault(1, 99))
 }
+
+func TestU64Nil(t *testing.T) {
+	assert.Equal(t, (*wrappers.UInt64Value)(nil), UInt64OrNil(0))
+	assert.Equal(t, &wrappers.UInt64Value{Value: 1}, UInt64OrNil(1))
+}
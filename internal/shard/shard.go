@@ -0,0 +1,77 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shard selects the root objects whose virtual hosts are
+// served by a Contour instance, so that the virtual hosts of a
+// cluster can be split across several Contour instances, each with
+// its own Envoy fleet.
+package shard
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selector selects the root objects of a shard by namespace and by
+// label. A nil Selector selects every object.
+type Selector struct {
+	// Namespaces are the namespaces of the selected objects. If
+	// empty, objects in any namespace are selected.
+	Namespaces []string
+
+	// Labels selects objects by their labels. If nil, objects
+	// with any labels are selected.
+	Labels labels.Selector
+}
+
+// New returns a Selector for the given namespaces and label selector,
+// which uses the Kubernetes label selector syntax. If neither is
+// given, New returns nil, which selects every object.
+func New(namespaces []string, selector string) (*Selector, error) {
+	if len(namespaces) == 0 && selector == "" {
+		return nil, nil
+	}
+
+	s := &Selector{Namespaces: namespaces}
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, err
+		}
+		s.Labels = parsed
+	}
+
+	return s, nil
+}
+
+// Matches returns true if obj belongs to the shard.
+func (s *Selector) Matches(obj metav1.Object) bool {
+	if s == nil {
+		return true
+	}
+
+	if len(s.Namespaces) > 0 {
+		found := false
+		for _, ns := range s.Namespaces {
+			if ns == obj.GetNamespace() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return s.Labels == nil || s.Labels.Matches(labels.Set(obj.GetLabels()))
+}
@@ -0,0 +1,55 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	object := func(namespace string, labels map[string]string) metav1.Object {
+		return &metav1.ObjectMeta{Namespace: namespace, Labels: labels}
+	}
+
+	none, err := New(nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	assert.True(t, none.Matches(object("default", nil)))
+
+	namespaces, err := New([]string{"prod", "prod-eu"}, "")
+	require.NoError(t, err)
+	assert.True(t, namespaces.Matches(object("prod", nil)))
+	assert.True(t, namespaces.Matches(object("prod-eu", nil)))
+	assert.False(t, namespaces.Matches(object("staging", nil)))
+
+	labels, err := New(nil, "environment in (prod),!canary")
+	require.NoError(t, err)
+	assert.True(t, labels.Matches(object("default", map[string]string{"environment": "prod"})))
+	assert.False(t, labels.Matches(object("default", map[string]string{"environment": "staging"})))
+	assert.False(t, labels.Matches(object("default", map[string]string{"environment": "prod", "canary": "true"})))
+	assert.False(t, labels.Matches(object("default", nil)))
+
+	both, err := New([]string{"prod"}, "environment=prod")
+	require.NoError(t, err)
+	assert.True(t, both.Matches(object("prod", map[string]string{"environment": "prod"})))
+	assert.False(t, both.Matches(object("staging", map[string]string{"environment": "prod"})))
+	assert.False(t, both.Matches(object("prod", nil)))
+
+	_, err = New(nil, "environment in prod")
+	assert.Error(t, err)
+}
@@ -47,91 +47,32 @@ type headerMatchConditionSorter []dag.HeaderMatchCondition
 func (s headerMatchConditionSorter) Len() int      { return len(s) }
 func (s headerMatchConditionSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 func (s headerMatchConditionSorter) Less(i, j int) bool {
-	compareValue := func(a dag.HeaderMatchCondition, b dag.HeaderMatchCondition) bool {
-		switch strings.Compare(a.Value, b.Value) {
-		case -1:
-			return true
-		case 1:
-			return false
-		default:
-			// The match that is not inverted sorts first.
-			return !a.Invert
-		}
-	}
+	return dag.HeaderMatchConditionLess(s[i], s[j])
+}
 
-	val := strings.Compare(s[i].Name, s[j].Name)
-	switch val {
-	case -1:
-		return true
-	case 1:
-		return false
-	default:
-		switch s[i].MatchType {
-		case dag.HeaderMatchTypeExact:
-			// Exact matches are most specific so they sort first.
-			switch s[j].MatchType {
-			case dag.HeaderMatchTypeExact:
-				return compareValue(s[i], s[j])
-			case dag.HeaderMatchTypeRegex:
-				return true
-			case dag.HeaderMatchTypeContains:
-				return true
-			case dag.HeaderMatchTypePresent:
-				return true
-			}
-		case dag.HeaderMatchTypeRegex:
-			// Regex matches sort ahead of Contains matches.
-			switch s[j].MatchType {
-			case dag.HeaderMatchTypeRegex:
-				return compareValue(s[i], s[j])
-			case dag.HeaderMatchTypeContains:
-				return true
-			case dag.HeaderMatchTypePresent:
-				return true
-			}
-		case dag.HeaderMatchTypeContains:
-			// Contains matches sort ahead of Present matches.
-			switch s[j].MatchType {
-			case dag.HeaderMatchTypeContains:
-				return compareValue(s[i], s[j])
-			case dag.HeaderMatchTypePresent:
-				return true
-			}
-		case dag.HeaderMatchTypePresent:
-			switch s[j].MatchType {
-			case dag.HeaderMatchTypePresent:
-				// The match that is not inverted sorts first.
-				return !s[i].Invert
-			}
-		}
-		return false
+// higherPriorityRoute compares lhs and rhs, which have the same path
+// match, and returns true if lhs has a higher priority, or the same
+// priority and more specific header conditions.
+func higherPriorityRoute(lhs, rhs *dag.Route) bool {
+	if lhs.Priority != rhs.Priority {
+		return lhs.Priority > rhs.Priority
 	}
+	return longestRouteByHeaderConditions(lhs, rhs)
 }
 
 // longestRouteByHeaderConditions compares the HeaderMatchCondition slices for
-// lhs and rhs and returns true if lhs is longer.
+// lhs and rhs and returns true if lhs is longer. Slices of the same length
+// are compared by the first condition that differs, so that routes sort
+// the same way whatever order they start in.
 func longestRouteByHeaderConditions(lhs, rhs *dag.Route) bool {
-	if len(lhs.HeaderMatchConditions) == len(rhs.HeaderMatchConditions) {
-		pair := make([]dag.HeaderMatchCondition, 2)
-
-		for i := 0; i < len(lhs.HeaderMatchConditions); i++ {
-			pair[0] = lhs.HeaderMatchConditions[i]
-			pair[1] = rhs.HeaderMatchConditions[i]
-
-			if headerMatchConditionSorter(pair).Less(0, 1) {
-				return true
-			}
-		}
-	}
-
-	return len(lhs.HeaderMatchConditions) > len(rhs.HeaderMatchConditions)
+	return dag.HeaderMatchConditionsLess(lhs.HeaderMatchConditions, rhs.HeaderMatchConditions)
 }
 
 // Sorts the given Route slice in place. Routes are ordered first by
 // type (exact sorts before regex, sorts before prefix) and then
-// longest path match value, then by the length of the HeaderMatch
-// slice (if any). The HeaderMatch slice is also ordered by the matching
-// header name.
+// longest path match value, then by priority, then by the length of
+// the HeaderMatch slice (if any). The HeaderMatch slice is also ordered
+// by the matching header name.
 type routeSorter []*dag.Route
 
 func (s routeSorter) Len() int      { return len(s) }
@@ -149,8 +90,8 @@ func (s routeSorter) Less(i, j int) bool {
 			case -1:
 				return false
 			default:
-				if a.PrefixMatchType == b.PrefixMatchType {
-					return longestRouteByHeaderConditions(s[i], s[j])
+				if a.PrefixMatchType == b.PrefixMatchType || s[i].Priority != s[j].Priority {
+					return higherPriorityRoute(s[i], s[j])
 				}
 				// Segment prefixes sort first as they are more specific.
 				return a.PrefixMatchType == dag.PrefixMatchSegment
@@ -167,7 +108,7 @@ func (s routeSorter) Less(i, j int) bool {
 			case -1:
 				return false
 			default:
-				return longestRouteByHeaderConditions(s[i], s[j])
+				return higherPriorityRoute(s[i], s[j])
 			}
 		case *dag.PrefixMatchCondition:
 			return true
@@ -183,7 +124,7 @@ func (s routeSorter) Less(i, j int) bool {
 			case -1:
 				return false
 			default:
-				return longestRouteByHeaderConditions(s[i], s[j])
+				return higherPriorityRoute(s[i], s[j])
 			}
 		case *dag.PrefixMatchCondition:
 			return true
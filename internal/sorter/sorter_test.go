@@ -283,6 +283,89 @@ func TestSortRoutesLongestHeaders(t *testing.T) {
 	assert.Equal(t, want, have)
 }
 
+func TestSortRoutesPriority(t *testing.T) {
+	want := []*dag.Route{
+		{
+			PathMatchCondition: matchPrefixString("/path/longer"),
+		},
+		{
+			// Priority sorts ahead of header conditions.
+			PathMatchCondition: matchPrefixString("/path"),
+			Priority:           10,
+		},
+		{
+			// Priority sorts ahead of segment prefixes.
+			PathMatchCondition: matchPrefixString("/path"),
+			Priority:           1,
+			HeaderMatchConditions: []dag.HeaderMatchCondition{
+				presentHeader("header-name"),
+			},
+		},
+		{
+			PathMatchCondition: matchPrefixSegment("/path"),
+			HeaderMatchConditions: []dag.HeaderMatchCondition{
+				presentHeader("header-name"),
+			},
+		},
+		{
+			PathMatchCondition: matchPrefixString("/path"),
+			HeaderMatchConditions: []dag.HeaderMatchCondition{
+				exactHeader("header-name", "header-value"),
+			},
+		},
+		{
+			PathMatchCondition: matchPrefixString("/path"),
+		},
+		{
+			PathMatchCondition: matchPrefixString("/path"),
+			Priority:           -1,
+			HeaderMatchConditions: []dag.HeaderMatchCondition{
+				exactHeader("header-name", "header-value"),
+			},
+		},
+	}
+
+	have := shuffleRoutes(want)
+
+	sort.Stable(For(have))
+	assert.Equal(t, want, have)
+}
+
+func TestSortRoutesSameFirstHeader(t *testing.T) {
+	want := []*dag.Route{
+		{
+			PathMatchCondition: matchPrefixString("/"),
+			HeaderMatchConditions: []dag.HeaderMatchCondition{
+				presentHeader("a"),
+				exactHeader("b", "value"),
+			},
+		},
+		{
+			PathMatchCondition: matchPrefixString("/"),
+			HeaderMatchConditions: []dag.HeaderMatchCondition{
+				presentHeader("a"),
+				presentHeader("b"),
+			},
+		},
+		{
+			PathMatchCondition: matchPrefixString("/"),
+			HeaderMatchConditions: []dag.HeaderMatchCondition{
+				presentHeader("a"),
+				presentHeader("c"),
+			},
+		},
+	}
+
+	// Routes whose first header conditions are the same sort
+	// by the next condition, whatever order they start in.
+	for i := 0; i < 10; i++ {
+		have := shuffleRoutes(want)
+
+		sort.Stable(For(have))
+		assert.Equal(t, want, have)
+	}
+}
+
 func TestSortSecrets(t *testing.T) {
 	want := []*envoy_tls_v3.Secret{
 		{Name: "first"},
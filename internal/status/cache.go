@@ -32,6 +32,14 @@ type ConditionType string
 // ValidCondition is the ConditionType for Valid.
 const ValidCondition ConditionType = "Valid"
 
+// OrphanedCondition is the ConditionType for Orphaned. It is derived
+// from the Valid condition when the status is written.
+const OrphanedCondition ConditionType = "Orphaned"
+
+// TLSReadyCondition is the ConditionType for TLSReady. It is derived
+// from the Valid condition when the status is written.
+const TLSReadyCondition ConditionType = "TLSReady"
+
 // NewCache creates a new Cache for holding status updates.
 func NewCache(gateway types.NamespacedName) Cache {
 	return Cache{
@@ -0,0 +1,105 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"time"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DelegationCacheEntry holds status updates for a particular TLSCertificateDelegation.
+type DelegationCacheEntry struct {
+	ConditionCache
+
+	Name           types.NamespacedName
+	Generation     int64
+	TransitionTime v1.Time
+
+	// Delegations holds the status of each delegation of the object.
+	Delegations []contour_api_v1.CertificateDelegationStatus
+}
+
+var _ CacheEntry = &DelegationCacheEntry{}
+
+func (e *DelegationCacheEntry) AsStatusUpdate() k8s.StatusUpdate {
+	m := k8s.StatusMutatorFunc(func(obj interface{}) interface{} {
+		o, ok := obj.(*contour_api_v1.TLSCertificateDelegation)
+		if !ok {
+			panic(fmt.Sprintf("unsupported %T object %q in status mutator", obj, e.Name))
+		}
+
+		delegation := o.DeepCopy()
+
+		for condType, cond := range e.Conditions {
+			cond.ObservedGeneration = e.Generation
+			cond.LastTransitionTime = e.TransitionTime
+
+			currCond := delegation.Status.GetConditionFor(string(condType))
+			if currCond == nil {
+				delegation.Status.Conditions = append(delegation.Status.Conditions, *cond)
+				continue
+			}
+
+			// Don't update the condition if our observation is stale.
+			if currCond.ObservedGeneration > cond.ObservedGeneration {
+				continue
+			}
+
+			cond.DeepCopyInto(currCond)
+		}
+
+		// The status of the delegations is only as recent as our
+		// observation of the object.
+		if o.Generation <= e.Generation {
+			delegation.Status.Delegations = e.Delegations
+		}
+
+		return delegation
+	})
+
+	return k8s.StatusUpdate{
+		NamespacedName: e.Name,
+		Resource:       contour_api_v1.TLSCertificateDelegationGVR,
+		Mutator:        m,
+	}
+}
+
+// DelegationAccessor returns a pointer to a shared status cache entry
+// for the given TLSCertificateDelegation object. If no such entry exists,
+// a new entry is added. When the caller finishes with the cache entry,
+// it must call the returned function to release the entry back to the
+// cache.
+func DelegationAccessor(c *Cache, delegation *contour_api_v1.TLSCertificateDelegation) (*DelegationCacheEntry, func()) {
+	entry := c.Get(delegation)
+	if entry == nil {
+		entry = &DelegationCacheEntry{
+			Name:           k8s.NamespacedNameOf(delegation),
+			Generation:     delegation.GetGeneration(),
+			TransitionTime: v1.NewTime(time.Now()),
+		}
+
+		// Populate the cache with the new entry
+		c.Put(delegation, entry)
+	}
+
+	entry = c.Get(delegation)
+	return entry.(*DelegationCacheEntry), func() {
+		c.Put(delegation, entry)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/projectcontour/contour/internal/k8s"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// KnativeIngressCacheEntry holds status updates for a particular
+// Knative Ingress.
+type KnativeIngressCacheEntry struct {
+	ConditionCache
+
+	Name           types.NamespacedName
+	Generation     int64
+	TransitionTime v1.Time
+
+	// LoadBalancer is the load balancer status written to
+	// both the public and private load balancers of the
+	// Ingress.
+	LoadBalancer *knative_v1alpha1.LoadBalancerStatus
+}
+
+var _ CacheEntry = &KnativeIngressCacheEntry{}
+
+func (e *KnativeIngressCacheEntry) AsStatusUpdate() k8s.StatusUpdate {
+	m := k8s.StatusMutatorFunc(func(obj interface{}) interface{} {
+		o, ok := obj.(*knative_v1alpha1.Ingress)
+		if !ok {
+			panic(fmt.Sprintf("unsupported %T object %q in status mutator", obj, e.Name))
+		}
+
+		ing := o.DeepCopy()
+
+		// Don't update the status if our observation is stale.
+		if ing.Status.ObservedGeneration > e.Generation {
+			return ing
+		}
+
+		ing.Status.ObservedGeneration = e.Generation
+		ing.Status.PublicLoadBalancer = e.LoadBalancer.DeepCopy()
+		ing.Status.PrivateLoadBalancer = e.LoadBalancer.DeepCopy()
+
+		for condType, cond := range e.Conditions {
+			newCond := knative_v1alpha1.Condition{
+				Type:               string(condType),
+				Status:             corev1.ConditionStatus(cond.Status),
+				LastTransitionTime: e.TransitionTime,
+				Reason:             cond.Reason,
+				Message:            cond.Message,
+			}
+
+			currCond := ing.Status.GetConditionFor(string(condType))
+			if currCond == nil {
+				ing.Status.Conditions = append(ing.Status.Conditions, newCond)
+				continue
+			}
+
+			// Keep the original transition time if the
+			// condition status has not changed.
+			if currCond.Status == newCond.Status {
+				newCond.LastTransitionTime = currCond.LastTransitionTime
+			}
+
+			*currCond = newCond
+		}
+
+		return ing
+	})
+
+	return k8s.StatusUpdate{
+		NamespacedName: e.Name,
+		Resource:       knative_v1alpha1.IngressGVR,
+		Mutator:        m,
+	}
+}
+
+// KnativeIngressAccessor returns a pointer to a shared status cache
+// entry for the given Knative Ingress. If no such entry exists, a new
+// entry is added. When the caller finishes with the cache entry, it
+// must call the returned function to release the entry back to the
+// cache.
+func KnativeIngressAccessor(c *Cache, ing *knative_v1alpha1.Ingress) (*KnativeIngressCacheEntry, func()) {
+	entry := c.Get(ing)
+	if entry == nil {
+		entry = &KnativeIngressCacheEntry{
+			Name:           k8s.NamespacedNameOf(ing),
+			Generation:     ing.GetGeneration(),
+			TransitionTime: v1.NewTime(time.Now()),
+		}
+
+		// Populate the cache with the new entry
+		c.Put(ing, entry)
+	}
+
+	entry = c.Get(ing)
+	return entry.(*KnativeIngressCacheEntry), func() {
+		c.Put(ing, entry)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+	"time"
+
+	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/fixture"
+	knative_v1alpha1 "github.com/projectcontour/contour/internal/knative/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestKnativeIngressStatusMutator(t *testing.T) {
+	earlier := metav1.NewTime(time.Unix(1000, 0))
+	now := metav1.NewTime(time.Unix(2000, 0))
+
+	lb := &knative_v1alpha1.LoadBalancerStatus{
+		Ingress: []knative_v1alpha1.LoadBalancerIngressStatus{{
+			DomainInternal: "envoy.projectcontour.svc.cluster.local",
+		}},
+	}
+
+	entry := &KnativeIngressCacheEntry{
+		Name:           types.NamespacedName{Namespace: "default", Name: "hello"},
+		Generation:     2,
+		TransitionTime: now,
+		LoadBalancer:   lb,
+	}
+	ready := entry.ConditionFor(knative_v1alpha1.IngressConditionReady)
+	ready.Status = contour_api_v1.ConditionTrue
+
+	update := entry.AsStatusUpdate()
+	assert.Equal(t, knative_v1alpha1.IngressGVR, update.Resource)
+
+	// A new condition is added with the entry's transition time.
+	ing := &knative_v1alpha1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("default/hello"),
+	}
+	got := update.Mutator.Mutate(ing).(*knative_v1alpha1.Ingress)
+	assert.Equal(t, knative_v1alpha1.IngressStatus{
+		ObservedGeneration: 2,
+		Conditions: []knative_v1alpha1.Condition{{
+			Type:               knative_v1alpha1.IngressConditionReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: now,
+		}},
+		PublicLoadBalancer:  lb,
+		PrivateLoadBalancer: lb,
+	}, got.Status)
+
+	// An unchanged condition keeps its transition time.
+	ing.Status.ObservedGeneration = 1
+	ing.Status.Conditions = []knative_v1alpha1.Condition{{
+		Type:               knative_v1alpha1.IngressConditionReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: earlier,
+	}}
+	got = update.Mutator.Mutate(ing).(*knative_v1alpha1.Ingress)
+	assert.Equal(t, earlier, got.Status.Conditions[0].LastTransitionTime)
+
+	// A stale observation leaves the status alone.
+	ing.Status.ObservedGeneration = 3
+	got = update.Mutator.Mutate(ing).(*knative_v1alpha1.Ingress)
+	assert.Equal(t, ing.Status, got.Status)
+}
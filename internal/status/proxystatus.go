@@ -31,8 +31,17 @@ const (
 	ProxyStatusValid    ProxyStatus = "valid"
 	ProxyStatusInvalid  ProxyStatus = "invalid"
 	ProxyStatusOrphaned ProxyStatus = "orphaned"
+
+	// ProxyStatusPartiallyValid is the status of an HTTPProxy
+	// whose valid routes are in use although others are not.
+	ProxyStatusPartiallyValid ProxyStatus = "partiallyvalid"
 )
 
+// PartiallyValidReason is the reason of the Valid condition of an
+// HTTPProxy that has invalid routes or includes, when its valid
+// routes are still in use.
+const PartiallyValidReason = "PartiallyValid"
+
 // ProxyUpdate holds status updates for a particular HTTPProxy object
 type ProxyUpdate struct {
 	Fullname       types.NamespacedName
@@ -44,6 +53,9 @@ type ProxyUpdate struct {
 	// keyed by the Type (since that's what the apiserver will end up
 	// doing.)
 	Conditions map[ConditionType]*projectcontour.DetailedCondition
+
+	// Includes holds the status of each include of the object.
+	Includes []projectcontour.IncludeStatus
 }
 
 // ProxyAccessor returns a ProxyUpdate that allows a client to build up a list of
@@ -85,6 +97,19 @@ func (c *Cache) commitProxy(pu *ProxyUpdate) {
 	c.proxyUpdates[pu.Fullname] = pu
 }
 
+// MergeProxies commits the proxy updates of other to c.
+func (c *Cache) MergeProxies(other *Cache) {
+	for _, pu := range other.proxyUpdates {
+		c.commitProxy(pu)
+	}
+}
+
+// DiscardProxy removes the committed update of the named proxy, if
+// any, so that its status is left as it is.
+func (c *Cache) DiscardProxy(name types.NamespacedName) {
+	delete(c.proxyUpdates, name)
+}
+
 // ConditionFor returns a DetailedCondition for a given ConditionType.
 // Currently only "Valid" is used.
 func (pu *ProxyUpdate) ConditionFor(cond ConditionType) *projectcontour.DetailedCondition {
@@ -107,6 +132,36 @@ func (pu *ProxyUpdate) ConditionFor(cond ConditionType) *projectcontour.Detailed
 
 }
 
+// IncludeValid records that the include of namespace/name is followed
+// and that the included HTTPProxy is valid.
+func (pu *ProxyUpdate) IncludeValid(namespace, name string) {
+	pu.Includes = append(pu.Includes, projectcontour.IncludeStatus{
+		Name:      name,
+		Namespace: namespace,
+		Status:    projectcontour.ConditionTrue,
+	})
+}
+
+// IncludeNotValid records that the include of namespace/name is not
+// followed, or that the included HTTPProxy is not valid.
+func (pu *ProxyUpdate) IncludeNotValid(namespace, name, reason, message string) {
+	pu.Includes = append(pu.Includes, projectcontour.IncludeStatus{
+		Name:      name,
+		Namespace: namespace,
+		Status:    projectcontour.ConditionFalse,
+		Reason:    reason,
+		Message:   message,
+	})
+}
+
+// IncludeErrorf adds an error to the Valid condition, and records that
+// the include of namespace/name is not followed for the same reason.
+func (pu *ProxyUpdate) IncludeErrorf(namespace, name, errorType, reason, formatmsg string, args ...interface{}) {
+	message := fmt.Sprintf(formatmsg, args...)
+	pu.ConditionFor(ValidCondition).AddError(errorType, reason, message)
+	pu.IncludeNotValid(namespace, name, reason, message)
+}
+
 func (pu *ProxyUpdate) Mutate(obj interface{}) interface{} {
 	o, ok := obj.(*projectcontour.HTTPProxy)
 	if !ok {
@@ -117,29 +172,25 @@ func (pu *ProxyUpdate) Mutate(obj interface{}) interface{} {
 
 	proxy := o.DeepCopy()
 
-	for condType, cond := range pu.Conditions {
-		cond.ObservedGeneration = pu.Generation
-		cond.LastTransitionTime = pu.TransitionTime
-
-		currCond := proxy.Status.GetConditionFor(string(condType))
-		if currCond == nil {
-			proxy.Status.Conditions = append(proxy.Status.Conditions, *cond)
-			continue
-		}
-
-		// Don't update the condition if our observation is stale.
-		if currCond.ObservedGeneration > cond.ObservedGeneration {
-			continue
-		}
-
-		cond.DeepCopyInto(currCond)
-
+	for _, cond := range pu.Conditions {
+		pu.setCondition(proxy, cond)
 	}
 
 	// Set the old status fields using the Valid DetailedCondition's details.
 	// Other conditions are not relevant for these two fields.
 	validCond := proxy.Status.GetConditionFor(projectcontour.ValidConditionType)
 
+	// The Orphaned and TLSReady conditions, and the status of the
+	// includes, are derived from our Valid condition, so are only
+	// written along with it.
+	if _, ok := pu.Conditions[ValidCondition]; ok && validCond.ObservedGeneration == pu.Generation {
+		pu.setCondition(proxy, orphanedCondition(validCond))
+		if proxy.Spec.VirtualHost != nil && proxy.Spec.VirtualHost.TLS != nil {
+			pu.setCondition(proxy, tlsReadyCondition(validCond))
+		}
+		proxy.Status.Includes = pu.Includes
+	}
+
 	switch validCond.Status {
 	case projectcontour.ConditionTrue:
 		// TODO(youngnick): bring the string(ProxyStatusValid) constants in here?
@@ -151,6 +202,11 @@ func (pu *ProxyUpdate) Mutate(obj interface{}) interface{} {
 			proxy.Status.Description = orphanCond.Message
 			break
 		}
+		if validCond.Reason == PartiallyValidReason {
+			proxy.Status.CurrentStatus = string(ProxyStatusPartiallyValid)
+			proxy.Status.Description = validCond.Message
+			break
+		}
 		proxy.Status.CurrentStatus = string(ProxyStatusInvalid)
 
 		// proxy.Status.Description = validCond.Reason + ": " + validCond.Message
@@ -160,3 +216,65 @@ func (pu *ProxyUpdate) Mutate(obj interface{}) interface{} {
 	return proxy
 
 }
+
+// setCondition adds cond to the status of proxy, or replaces the
+// existing condition of the same type unless our observation is stale.
+func (pu *ProxyUpdate) setCondition(proxy *projectcontour.HTTPProxy, cond *projectcontour.DetailedCondition) {
+	cond.ObservedGeneration = pu.Generation
+	cond.LastTransitionTime = pu.TransitionTime
+
+	currCond := proxy.Status.GetConditionFor(cond.Type)
+	if currCond == nil {
+		proxy.Status.Conditions = append(proxy.Status.Conditions, *cond)
+		return
+	}
+
+	// Don't update the condition if our observation is stale.
+	if currCond.ObservedGeneration > cond.ObservedGeneration {
+		return
+	}
+
+	cond.DeepCopyInto(currCond)
+}
+
+// orphanedCondition returns the Orphaned condition that corresponds to
+// the Valid condition validCond.
+func orphanedCondition(validCond *projectcontour.DetailedCondition) *projectcontour.DetailedCondition {
+	cond := &projectcontour.DetailedCondition{}
+	cond.Type = string(OrphanedCondition)
+
+	if orphanCond, ok := validCond.GetError(projectcontour.ConditionTypeOrphanedError); ok {
+		cond.Status = projectcontour.ConditionTrue
+		cond.Reason = orphanCond.Reason
+		cond.Message = orphanCond.Message
+		return cond
+	}
+
+	cond.Status = projectcontour.ConditionFalse
+	cond.Reason = "NotOrphaned"
+	cond.Message = "HTTPProxy is a root HTTPProxy or is included by one"
+	return cond
+}
+
+// tlsReadyCondition returns the TLSReady condition that corresponds to
+// the Valid condition validCond of an HTTPProxy that terminates TLS.
+func tlsReadyCondition(validCond *projectcontour.DetailedCondition) *projectcontour.DetailedCondition {
+	cond := &projectcontour.DetailedCondition{}
+	cond.Type = string(TLSReadyCondition)
+
+	switch tlsCond, ok := validCond.GetError(projectcontour.ConditionTypeTLSError); {
+	case ok:
+		cond.Status = projectcontour.ConditionFalse
+		cond.Reason = tlsCond.Reason
+		cond.Message = tlsCond.Message
+	case validCond.Status == projectcontour.ConditionTrue || validCond.Reason == PartiallyValidReason:
+		cond.Status = projectcontour.ConditionTrue
+		cond.Reason = "TLSReady"
+		cond.Message = "TLS is configured for the virtual host"
+	default:
+		cond.Status = projectcontour.ConditionFalse
+		cond.Reason = "NotValid"
+		cond.Message = "HTTPProxy is not valid, so TLS is not configured for the virtual host"
+	}
+	return cond
+}
@@ -64,6 +64,7 @@ func TestStatusMutator(t *testing.T) {
 		testProxy         contour_api_v1.HTTPProxy
 		proxyUpdate       ProxyUpdate
 		wantConditions    []contour_api_v1.DetailedCondition
+		wantIncludes      []contour_api_v1.IncludeStatus
 		wantCurrentStatus string
 		wantDescription   string
 	}
@@ -77,6 +78,7 @@ func TestStatusMutator(t *testing.T) {
 		switch o := newProxy.(type) {
 		case *contour_api_v1.HTTPProxy:
 			assert.Equal(t, tc.wantConditions, o.Status.Conditions, desc)
+			assert.Equal(t, tc.wantIncludes, o.Status.Includes, desc)
 			assert.Equal(t, tc.wantCurrentStatus, o.Status.CurrentStatus, desc)
 			assert.Equal(t, tc.wantDescription, o.Status.Description, desc)
 		default:
@@ -132,6 +134,16 @@ func TestStatusMutator(t *testing.T) {
 					},
 				},
 			},
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(OrphanedCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "NotOrphaned",
+					Message:            "HTTPProxy is a root HTTPProxy or is included by one",
+				},
+			},
 		},
 		wantCurrentStatus: string(ProxyStatusValid),
 		wantDescription:   "Valid HTTPProxy",
@@ -186,6 +198,16 @@ func TestStatusMutator(t *testing.T) {
 					},
 				},
 			},
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(OrphanedCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "NotOrphaned",
+					Message:            "HTTPProxy is a root HTTPProxy or is included by one",
+				},
+			},
 		},
 		wantCurrentStatus: string(ProxyStatusInvalid),
 		wantDescription:   "At least one error present, see Errors for details",
@@ -240,6 +262,16 @@ func TestStatusMutator(t *testing.T) {
 					},
 				},
 			},
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(OrphanedCondition),
+					Status:             contour_api_v1.ConditionTrue,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "Orphaned",
+					Message:            "this HTTPProxy is not part of a delegation chain from a root HTTPProxy",
+				},
+			},
 		},
 		wantCurrentStatus: string(ProxyStatusOrphaned),
 		wantDescription:   "this HTTPProxy is not part of a delegation chain from a root HTTPProxy",
@@ -247,6 +279,71 @@ func TestStatusMutator(t *testing.T) {
 
 	run("orphaned HTTPProxy", orphanedCondition)
 
+	partiallyValidCondition := testcase{
+		testProxy: contour_api_v1.HTTPProxy{
+			ObjectMeta: v1.ObjectMeta{
+				Name:       "test",
+				Namespace:  "test",
+				Generation: testGeneration,
+			},
+		},
+		proxyUpdate: ProxyUpdate{
+			Fullname:       k8s.NamespacedNameFrom("test/test"),
+			Generation:     testGeneration,
+			TransitionTime: testTransitionTime,
+			Conditions: map[ConditionType]*contour_api_v1.DetailedCondition{
+				ValidCondition: {
+					Condition: contour_api_v1.Condition{
+						Type:    string(ValidCondition),
+						Status:  contour_api_v1.ConditionFalse,
+						Reason:  PartiallyValidReason,
+						Message: "Some routes or includes are not valid and are not in use, see Errors for details",
+					},
+					Errors: []contour_api_v1.SubCondition{
+						{
+							Type:    "RouteError",
+							Reason:  "NoServicesPresent",
+							Message: "route.services must have at least one entry",
+						},
+					},
+				},
+			},
+		},
+		wantConditions: []contour_api_v1.DetailedCondition{
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(ValidCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             PartiallyValidReason,
+					Message:            "Some routes or includes are not valid and are not in use, see Errors for details",
+				},
+				Errors: []contour_api_v1.SubCondition{
+					{
+						Type:    "RouteError",
+						Reason:  "NoServicesPresent",
+						Message: "route.services must have at least one entry",
+					},
+				},
+			},
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(OrphanedCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "NotOrphaned",
+					Message:            "HTTPProxy is a root HTTPProxy or is included by one",
+				},
+			},
+		},
+		wantCurrentStatus: string(ProxyStatusPartiallyValid),
+		wantDescription:   "Some routes or includes are not valid and are not in use, see Errors for details",
+	}
+
+	run("partially valid HTTPProxy", partiallyValidCondition)
+
 	updateExistingValidCond := testcase{
 		testProxy: contour_api_v1.HTTPProxy{
 			ObjectMeta: v1.ObjectMeta{
@@ -305,10 +402,131 @@ func TestStatusMutator(t *testing.T) {
 					},
 				},
 			},
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(OrphanedCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "NotOrphaned",
+					Message:            "HTTPProxy is a root HTTPProxy or is included by one",
+				},
+			},
 		},
 		wantCurrentStatus: string(ProxyStatusValid),
 		wantDescription:   "Valid HTTPProxy",
 	}
 
 	run("Test updating existing Valid Condition", updateExistingValidCond)
+
+	tlsErrorAndIncludes := testcase{
+		testProxy: contour_api_v1.HTTPProxy{
+			ObjectMeta: v1.ObjectMeta{
+				Name:       "test",
+				Namespace:  "test",
+				Generation: testGeneration,
+			},
+			Spec: contour_api_v1.HTTPProxySpec{
+				VirtualHost: &contour_api_v1.VirtualHost{
+					Fqdn: "example.com",
+					TLS: &contour_api_v1.TLS{
+						SecretName: "missing",
+					},
+				},
+			},
+		},
+		proxyUpdate: ProxyUpdate{
+			Fullname:       k8s.NamespacedNameFrom("test/test"),
+			Generation:     testGeneration,
+			TransitionTime: testTransitionTime,
+			Conditions: map[ConditionType]*contour_api_v1.DetailedCondition{
+				ValidCondition: {
+					Condition: contour_api_v1.Condition{
+						Type:    string(ValidCondition),
+						Status:  contour_api_v1.ConditionFalse,
+						Reason:  "ErrorPresent",
+						Message: "At least one error present, see Errors for details",
+					},
+					Errors: []contour_api_v1.SubCondition{
+						{
+							Type:    "TLSError",
+							Reason:  "SecretNotValid",
+							Message: "Spec.VirtualHost.TLS Secret \"missing\" is invalid: Secret not found",
+						},
+					},
+				},
+			},
+			Includes: []contour_api_v1.IncludeStatus{
+				{
+					Name:      "child",
+					Namespace: "test",
+					Status:    contour_api_v1.ConditionTrue,
+				},
+				{
+					Name:      "missing",
+					Namespace: "test",
+					Status:    contour_api_v1.ConditionFalse,
+					Reason:    "IncludeNotFound",
+					Message:   "include test/missing not found",
+				},
+			},
+		},
+		wantConditions: []contour_api_v1.DetailedCondition{
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(ValidCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "ErrorPresent",
+					Message:            "At least one error present, see Errors for details",
+				},
+				Errors: []contour_api_v1.SubCondition{
+					{
+						Type:    "TLSError",
+						Reason:  "SecretNotValid",
+						Message: "Spec.VirtualHost.TLS Secret \"missing\" is invalid: Secret not found",
+					},
+				},
+			},
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(OrphanedCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "NotOrphaned",
+					Message:            "HTTPProxy is a root HTTPProxy or is included by one",
+				},
+			},
+			{
+				Condition: contour_api_v1.Condition{
+					Type:               string(TLSReadyCondition),
+					Status:             contour_api_v1.ConditionFalse,
+					ObservedGeneration: testGeneration,
+					LastTransitionTime: testTransitionTime,
+					Reason:             "SecretNotValid",
+					Message:            "Spec.VirtualHost.TLS Secret \"missing\" is invalid: Secret not found",
+				},
+			},
+		},
+		wantIncludes: []contour_api_v1.IncludeStatus{
+			{
+				Name:      "child",
+				Namespace: "test",
+				Status:    contour_api_v1.ConditionTrue,
+			},
+			{
+				Name:      "missing",
+				Namespace: "test",
+				Status:    contour_api_v1.ConditionFalse,
+				Reason:    "IncludeNotFound",
+				Message:   "include test/missing not found",
+			},
+		},
+		wantCurrentStatus: string(ProxyStatusInvalid),
+		wantDescription:   "At least one error present, see Errors for details",
+	}
+
+	run("TLS error and includes", tlsErrorAndIncludes)
 }
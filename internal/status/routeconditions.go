@@ -25,6 +25,7 @@ import (
 
 const ResourceHTTPRoute = "httproutes"
 const ResourceTLSRoute = "tlsroutes"
+const ResourceTCPRoute = "tcproutes"
 
 const ConditionNotImplemented gatewayapi_v1alpha1.RouteConditionType = "NotImplemented"
 const ConditionResolvedRefs gatewayapi_v1alpha1.RouteConditionType = "ResolvedRefs"
@@ -160,6 +161,12 @@ func (routeUpdate *RouteConditionsUpdate) Mutate(obj interface{}) interface{} {
 		// Set the TLSRoute status.
 		route.Status.RouteStatus.Gateways = append(gatewayStatuses, routeUpdate.combineConditions(route.Status.Gateways)...)
 		return route
+	case *gatewayapi_v1alpha1.TCPRoute:
+		route := o.DeepCopy()
+
+		// Set the TCPRoute status.
+		route.Status.RouteStatus.Gateways = append(gatewayStatuses, routeUpdate.combineConditions(route.Status.Gateways)...)
+		return route
 	default:
 		panic(fmt.Sprintf("Unsupported %T object %s/%s in RouteConditionsUpdate status mutator",
 			obj, routeUpdate.FullName.Namespace, routeUpdate.FullName.Name,
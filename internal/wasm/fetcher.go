@@ -0,0 +1,122 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wasm pulls the code of Wasm modules from OCI images.
+package wasm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pullTimeout is how long pulling an image may take.
+const pullTimeout = 2 * time.Minute
+
+// Fetcher pulls the code of Wasm modules from OCI images in the
+// background, and caches it by image reference. Since images are
+// cached for the life of the Fetcher, a new version of a module
+// should be rolled out with a new tag or digest.
+type Fetcher struct {
+	// Client is the HTTP client that images are pulled with.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// RetryInterval is how long to wait before pulling an
+	// image again after it failed.
+	RetryInterval time.Duration
+
+	// OnFetch, if not nil, is called once an image has been
+	// pulled, successfully or not, so that the module can be
+	// looked up again.
+	OnFetch func()
+
+	logrus.FieldLogger
+
+	mu     sync.Mutex
+	images map[string]*image
+}
+
+// image is the state of an image that has been looked up.
+type image struct {
+	code    []byte
+	err     error
+	pulling bool
+	pulled  time.Time
+}
+
+// Fetch returns the code of the Wasm module in the named image. If
+// the image has not been pulled yet, or the last pull failed more than
+// RetryInterval ago, it is pulled in the background and Fetch returns
+// an error until it completes.
+func (f *Fetcher) Fetch(name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.images == nil {
+		f.images = make(map[string]*image)
+	}
+
+	img, ok := f.images[name]
+	if !ok {
+		img = &image{}
+		f.images[name] = img
+	}
+
+	switch {
+	case img.pulling:
+		return nil, errors.New("image is being pulled")
+	case img.code != nil:
+		return img.code, nil
+	case img.err != nil && time.Since(img.pulled) < f.RetryInterval:
+		return nil, img.err
+	}
+
+	img.pulling = true
+	go f.pull(name, img)
+
+	return nil, errors.New("image is being pulled")
+}
+
+// pull pulls the named image and records the result in img.
+func (f *Fetcher) pull(name string, img *image) {
+	ctx, cancel := context.WithTimeout(context.Background(), pullTimeout)
+	defer cancel()
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	code, err := pull(ctx, client, name)
+	if err != nil {
+		f.WithError(err).WithField("image", name).Error("failed to pull Wasm module")
+	} else {
+		f.WithField("image", name).WithField("size", len(code)).Info("pulled Wasm module")
+	}
+
+	f.mu.Lock()
+	img.code = code
+	img.err = err
+	img.pulling = false
+	img.pulled = time.Now()
+	f.mu.Unlock()
+
+	if f.OnFetch != nil {
+		f.OnFetch()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcher(t *testing.T) {
+	r := newFakeRegistry(t)
+	r.addManifest(t, "v1", manifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Layers: []descriptor{{
+			MediaType: "application/vnd.module.wasm.content.layer.v1+wasm",
+			Digest:    r.addBlob(module),
+		}},
+	})
+
+	fetched := make(chan struct{}, 2)
+	f := &Fetcher{
+		Client:        r.Client(),
+		RetryInterval: time.Hour,
+		OnFetch:       func() { fetched <- struct{}{} },
+		FieldLogger:   fixture.NewTestLogger(t),
+	}
+
+	wait := func() {
+		select {
+		case <-fetched:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for the image to be pulled")
+		}
+	}
+
+	// The first lookup of an image starts pulling it.
+	_, err := f.Fetch(r.image("v1"))
+	require.Error(t, err)
+	wait()
+
+	got, err := f.Fetch(r.image("v1"))
+	require.NoError(t, err)
+	assert.Equal(t, module, got)
+
+	// A failed pull is not retried until RetryInterval has passed.
+	_, err = f.Fetch(r.image("missing"))
+	require.Error(t, err)
+	wait()
+
+	_, err = f.Fetch(r.image("missing"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404 Not Found")
+	assert.Len(t, fetched, 0)
+}
@@ -0,0 +1,427 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxManifestSize is the largest manifest that is read.
+	maxManifestSize = 4 << 20
+
+	// maxModuleSize is the largest module, or layer that
+	// holds a module, that is read.
+	maxModuleSize = 64 << 20
+)
+
+// manifestMediaTypes are the media types of the manifests and
+// indexes that are accepted.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// wasmLayerMediaTypes are the media types of the layers of Wasm
+// artifacts whose content is the module itself.
+var wasmLayerMediaTypes = map[string]bool{
+	"application/vnd.module.wasm.content.layer.v1+wasm": true,
+	"application/wasm": true,
+}
+
+// wasmMagic is the magic number that Wasm modules start with.
+var wasmMagic = []byte("\x00asm")
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// reference is a parsed image reference.
+type reference struct {
+	registry   string
+	repository string
+	// tag is the tag of the image, if it is not pinned by digest.
+	tag string
+	// digest is the digest of the manifest of the image, if it is
+	// pinned by digest.
+	digest string
+}
+
+// parseReference parses an image reference of the form
+// [registry/]repository[:tag][@digest]. References without a registry
+// are pulled from Docker Hub, and references without a tag or digest
+// pull the latest tag.
+func parseReference(name string) (*reference, error) {
+	ref := &reference{}
+
+	rest := name
+	if i := strings.Index(rest, "@"); i >= 0 {
+		rest, ref.digest = rest[:i], rest[i+1:]
+		if !digestRegexp.MatchString(ref.digest) {
+			return nil, fmt.Errorf("image %q has an invalid digest", name)
+		}
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, ref.tag = rest[:i], rest[i+1:]
+		if ref.tag == "" {
+			return nil, fmt.Errorf("image %q has an empty tag", name)
+		}
+	}
+
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.registry, ref.repository = parts[0], parts[1]
+	} else {
+		ref.registry, ref.repository = "docker.io", rest
+	}
+	if ref.repository == "" {
+		return nil, fmt.Errorf("image %q has no repository", name)
+	}
+
+	if ref.registry == "docker.io" {
+		ref.registry = "registry-1.docker.io"
+		if !strings.Contains(ref.repository, "/") {
+			ref.repository = "library/" + ref.repository
+		}
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+
+	return ref, nil
+}
+
+// descriptor describes the content of a manifest, index or layer.
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// manifest is either a manifest, whose Layers are set, or an
+// index, whose Manifests are set.
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// registryClient pulls from a repository of an OCI registry.
+type registryClient struct {
+	client *http.Client
+	ref    *reference
+	token  string
+}
+
+// pull pulls the named image and returns the code of its Wasm module.
+func pull(ctx context.Context, client *http.Client, name string) ([]byte, error) {
+	ref, err := parseReference(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &registryClient{client: client, ref: ref}
+
+	m, err := c.manifest(ctx, ref.digest, ref.tag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wasm artifacts have a single platform, so the first
+	// manifest of an index is used.
+	if len(m.Manifests) > 0 {
+		if m, err = c.manifest(ctx, m.Manifests[0].Digest, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	layer, archive, err := wasmLayer(m)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.blob(ctx, layer.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if archive {
+		if data, err = extractModule(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if !bytes.HasPrefix(data, wasmMagic) {
+		return nil, errors.New("image does not hold a Wasm module")
+	}
+
+	return data, nil
+}
+
+// wasmLayer returns the layer of m that holds the Wasm module, and
+// whether the layer is an archive that holds it as plugin.wasm.
+func wasmLayer(m *manifest) (descriptor, bool, error) {
+	for _, layer := range m.Layers {
+		if wasmLayerMediaTypes[layer.MediaType] {
+			return layer, false, nil
+		}
+	}
+
+	if len(m.Layers) == 1 {
+		return m.Layers[0], true, nil
+	}
+
+	return descriptor{}, false, errors.New("image has no Wasm layer")
+}
+
+// extractModule returns the content of the plugin.wasm file of the
+// tar archive, which may be compressed with gzip.
+func extractModule(data []byte) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("image layer has no plugin.wasm file")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image layer: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == "plugin.wasm" {
+			return readLimited(tr, maxModuleSize)
+		}
+	}
+}
+
+// manifest returns the manifest with the given digest, or tag if
+// digest is empty.
+func (c *registryClient) manifest(ctx context.Context, digest, tag string) (*manifest, error) {
+	ref := digest
+	if ref == "" {
+		ref = tag
+	}
+
+	data, err := c.get(ctx, "/manifests/"+ref, maxManifestSize, manifestMediaTypes...)
+	if err != nil {
+		return nil, err
+	}
+	if digest != "" {
+		if err := verify(data, digest); err != nil {
+			return nil, err
+		}
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode image manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// blob returns the blob with the given digest.
+func (c *registryClient) blob(ctx context.Context, digest string) ([]byte, error) {
+	if !digestRegexp.MatchString(digest) {
+		return nil, fmt.Errorf("unsupported blob digest %q", digest)
+	}
+
+	data, err := c.get(ctx, "/blobs/"+digest, maxModuleSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(data, digest); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// get returns the body of a GET of the path in the repository. If the
+// registry requires a token, an anonymous token is requested for the
+// repository.
+func (c *registryClient) get(ctx context.Context, p string, limit int64, accept ...string) ([]byte, error) {
+	u := "https://" + c.ref.registry + "/v2/" + c.ref.repository + p
+
+	resp, err := c.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		if c.token, err = c.authorize(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(ctx, u, accept); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+
+	return readLimited(resp.Body, limit)
+}
+
+func (c *registryClient) do(ctx context.Context, u string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.client.Do(req)
+}
+
+// authorize requests an anonymous pull token for the repository from
+// the token server named by the bearer challenge.
+func (c *registryClient) authorize(ctx context.Context, challenge string) (string, error) {
+	params, ok := parseBearerChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("registry %s requires unsupported authorization %q", c.ref.registry, challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", fmt.Errorf("registry %s has an invalid token realm: %w", c.ref.registry, err)
+	}
+
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.repository + ":pull"
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	resp, err := c.do(ctx, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get a token for %s: %s", c.ref.repository, resp.Status)
+	}
+
+	data, err := readLimited(resp.Body, maxManifestSize)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	if token.AccessToken != "" {
+		return token.AccessToken, nil
+	}
+	return "", fmt.Errorf("no token was issued for %s", c.ref.repository)
+}
+
+// parseBearerChallenge returns the parameters of a WWW-Authenticate
+// Bearer challenge, such as Bearer realm="...",service="...".
+func parseBearerChallenge(challenge string) (map[string]string, bool) {
+	const scheme = "bearer "
+	if len(challenge) < len(scheme) || !strings.EqualFold(challenge[:len(scheme)], scheme) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	s := strings.TrimSpace(challenge[len(scheme):])
+	for s != "" {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			return nil, false
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimSpace(s[eq+1:])
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				return nil, false
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else if comma := strings.Index(s, ","); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		params[key] = strings.TrimSpace(value)
+
+		s = strings.TrimPrefix(strings.TrimSpace(s), ",")
+		s = strings.TrimSpace(s)
+	}
+
+	return params, true
+}
+
+// verify returns an error if data does not have the given sha256 digest.
+func verify(data []byte, digest string) error {
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return fmt.Errorf("content does not match digest %s", digest)
+	}
+	return nil
+}
+
+// readLimited reads r to the end, failing if it is longer than limit.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("content is larger than %d bytes", limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,280 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	tests := map[string]struct {
+		name    string
+		want    *reference
+		wantErr bool
+	}{
+		"docker hub library image": {
+			name: "filter",
+			want: &reference{registry: "registry-1.docker.io", repository: "library/filter", tag: "latest"},
+		},
+		"docker hub image with tag": {
+			name: "example/filter:v1",
+			want: &reference{registry: "registry-1.docker.io", repository: "example/filter", tag: "v1"},
+		},
+		"registry image": {
+			name: "ghcr.io/example/filter:v1",
+			want: &reference{registry: "ghcr.io", repository: "example/filter", tag: "v1"},
+		},
+		"registry with port": {
+			name: "localhost:5000/filter",
+			want: &reference{registry: "localhost:5000", repository: "filter", tag: "latest"},
+		},
+		"pinned by digest": {
+			name: "ghcr.io/example/filter@" + digest,
+			want: &reference{registry: "ghcr.io", repository: "example/filter", digest: digest},
+		},
+		"tag and digest": {
+			name: "ghcr.io/example/filter:v1@" + digest,
+			want: &reference{registry: "ghcr.io", repository: "example/filter", tag: "v1", digest: digest},
+		},
+		"invalid digest": {
+			name:    "ghcr.io/example/filter@sha256:abc",
+			wantErr: true,
+		},
+		"empty tag": {
+			name:    "ghcr.io/example/filter:",
+			wantErr: true,
+		},
+		"no repository": {
+			name:    "ghcr.io/",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseReference(tc.name)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseBearerChallenge(t *testing.T) {
+	params, ok := parseBearerChallenge(`Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:example/filter:pull,push"`)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://ghcr.io/token",
+		"service": "ghcr.io",
+		"scope":   "repository:example/filter:pull,push",
+	}, params)
+
+	_, ok = parseBearerChallenge(`Basic realm="registry"`)
+	assert.False(t, ok)
+}
+
+// module is the smallest valid Wasm module.
+var module = []byte("\x00asm\x01\x00\x00\x00")
+
+// fakeRegistry is a registry that serves the images of the repository
+// example/filter to clients with the token it issues.
+type fakeRegistry struct {
+	*httptest.Server
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	r := &fakeRegistry{
+		manifests: map[string][]byte{},
+		blobs:     map[string][]byte{},
+	}
+
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if req.URL.Query().Get("scope") != "repository:example/filter:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token":"secret"}`)
+			return
+		}
+
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var data []byte
+		var ok bool
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/v2/example/filter/manifests/"):
+			data, ok = r.manifests[strings.TrimPrefix(req.URL.Path, "/v2/example/filter/manifests/")]
+		case strings.HasPrefix(req.URL.Path, "/v2/example/filter/blobs/"):
+			data, ok = r.blobs[strings.TrimPrefix(req.URL.Path, "/v2/example/filter/blobs/")]
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data) // nolint:errcheck
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+// image returns the name of the image of the given tag.
+func (r *fakeRegistry) image(tag string) string {
+	return strings.TrimPrefix(r.URL, "https://") + "/example/filter:" + tag
+}
+
+// addBlob adds data as a blob and returns its digest.
+func (r *fakeRegistry) addBlob(data []byte) string {
+	d := digestOf(data)
+	r.blobs[d] = data
+	return d
+}
+
+// addManifest adds the manifest with the given tag and returns its digest.
+func (r *fakeRegistry) addManifest(t *testing.T, tag string, m interface{}) string {
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	d := digestOf(data)
+	r.manifests[d] = data
+	if tag != "" {
+		r.manifests[tag] = data
+	}
+	return d
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func pluginArchive(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name:     "plugin.wasm",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(data)),
+	}))
+	_, err := tw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestPull(t *testing.T) {
+	r := newFakeRegistry(t)
+
+	artifact := r.addManifest(t, "artifact", manifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Layers: []descriptor{{
+			MediaType: "application/vnd.module.wasm.content.layer.v1+wasm",
+			Digest:    r.addBlob(module),
+		}},
+	})
+	r.addManifest(t, "compat", manifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Layers: []descriptor{{
+			MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+			Digest:    r.addBlob(pluginArchive(t, module)),
+		}},
+	})
+	r.addManifest(t, "index", manifest{
+		MediaType: "application/vnd.oci.image.index.v1+json",
+		Manifests: []descriptor{{
+			MediaType: "application/vnd.oci.image.manifest.v1+json",
+			Digest:    artifact,
+		}},
+	})
+	tampered := "sha256:" + strings.Repeat("b", 64)
+	r.manifests[tampered] = r.manifests["compat"]
+	r.addManifest(t, "not-wasm", manifest{
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Layers: []descriptor{{
+			MediaType: "application/wasm",
+			Digest:    r.addBlob([]byte("not a module")),
+		}},
+	})
+
+	tests := map[string]struct {
+		image   string
+		wantErr string
+	}{
+		"wasm artifact": {
+			image: r.image("artifact"),
+		},
+		"container image with plugin.wasm": {
+			image: r.image("compat"),
+		},
+		"index": {
+			image: r.image("index"),
+		},
+		"pinned by digest": {
+			image: strings.TrimSuffix(r.image(""), ":") + "@" + artifact,
+		},
+		"digest does not match": {
+			image:   strings.TrimSuffix(r.image(""), ":") + "@" + tampered,
+			wantErr: "does not match",
+		},
+		"missing tag": {
+			image:   r.image("missing"),
+			wantErr: "404 Not Found",
+		},
+		"not a Wasm module": {
+			image:   r.image("not-wasm"),
+			wantErr: "does not hold a Wasm module",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := pull(context.Background(), r.Client(), tc.image)
+			if tc.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, module, got)
+		})
+	}
+}
@@ -35,6 +35,23 @@ type Resource interface {
 	TypeURL() string
 }
 
+// Aliased is implemented by a Resource whose entries may also be
+// requested by an alias of their name.
+type Aliased interface {
+	// Alias returns the name of the entry that alias refers to,
+	// or an empty name if there is none. It returns false if the
+	// name is not an alias.
+	Alias(alias string) (string, bool)
+}
+
+// Namespaced is implemented by a Resource whose entries are built
+// from objects in Kubernetes namespaces.
+type Namespaced interface {
+	// Namespace returns the namespace of the named entry, or
+	// an empty string if there is no such entry.
+	Namespace(name string) string
+}
+
 // Counter holds an atomically incrementing counter.
 type Counter uint64
 
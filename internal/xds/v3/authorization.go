@@ -0,0 +1,141 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/projectcontour/contour/internal/xds"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// NodeAuthorizer authorizes the Envoy nodes that stream resources
+// from the xDS server.
+type NodeAuthorizer interface {
+	// Authorize returns an error if node may not stream resources
+	// over the stream whose context is ctx.
+	Authorize(ctx context.Context, node *envoy_core_v3.Node) error
+
+	// Permit returns true if node may receive the named entry of r.
+	Permit(node *envoy_core_v3.Node, r xds.Resource, name string) bool
+}
+
+// NodePolicy is the authorization policy of the Envoy nodes whose ID
+// matches the ID pattern.
+type NodePolicy struct {
+	// ID is a shell pattern, as accepted by path.Match, that
+	// matches the IDs of the nodes this policy applies to.
+	ID string
+
+	// SubjectAltNames are the DNS or URI subject alternative names,
+	// one of which the client certificate of the node must have. If
+	// empty, the client certificate of the node is not checked.
+	SubjectAltNames []string
+
+	// SecretNamespaces are the namespaces of the secrets that the
+	// node may receive. If empty, the node may receive every secret.
+	SecretNamespaces []string
+}
+
+// NodeAllowlist is a NodeAuthorizer that only authorizes the nodes
+// that match one of its policies. The first matching policy applies.
+type NodeAllowlist []NodePolicy
+
+// policy returns the policy that applies to node, or nil.
+func (a NodeAllowlist) policy(node *envoy_core_v3.Node) *NodePolicy {
+	for i := range a {
+		if ok, _ := path.Match(a[i].ID, node.GetId()); ok {
+			return &a[i]
+		}
+	}
+	return nil
+}
+
+func (a NodeAllowlist) Authorize(ctx context.Context, node *envoy_core_v3.Node) error {
+	if node.GetId() == "" {
+		return errors.New("node ID not supplied")
+	}
+
+	policy := a.policy(node)
+	if policy == nil {
+		return fmt.Errorf("node %q is not authorized", node.GetId())
+	}
+
+	if len(policy.SubjectAltNames) == 0 {
+		return nil
+	}
+
+	for _, san := range peerSubjectAltNames(ctx) {
+		for _, allowed := range policy.SubjectAltNames {
+			if san == allowed {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("client certificate of node %q has no authorized subject alternative name", node.GetId())
+}
+
+func (a NodeAllowlist) Permit(node *envoy_core_v3.Node, r xds.Resource, name string) bool {
+	policy := a.policy(node)
+	if policy == nil {
+		return false
+	}
+
+	if len(policy.SecretNamespaces) == 0 || r.TypeURL() != resource.SecretType {
+		return true
+	}
+
+	namespaced, ok := r.(xds.Namespaced)
+	if !ok {
+		return false
+	}
+
+	namespace := namespaced.Namespace(name)
+	for _, allowed := range policy.SecretNamespaces {
+		if namespace == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peerSubjectAltNames returns the DNS and URI subject alternative
+// names of the client certificate of the stream whose context is ctx.
+func peerSubjectAltNames(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := info.State.PeerCertificates[0]
+	sans := append([]string{}, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	return sans
+}
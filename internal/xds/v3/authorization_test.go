@@ -0,0 +1,135 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"testing"
+
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestNodeAllowlistAuthorize(t *testing.T) {
+	allowlist := NodeAllowlist{{
+		ID:              "envoy-internal-*",
+		SubjectAltNames: []string{"spiffe://cluster.local/ns/internal/sa/envoy"},
+	}, {
+		ID: "envoy-external-*",
+	}}
+
+	withPeer := func(uris ...string) context.Context {
+		cert := &x509.Certificate{}
+		for _, u := range uris {
+			parsed, err := url.Parse(u)
+			assert.NoError(t, err)
+			cert.URIs = append(cert.URIs, parsed)
+		}
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{
+				State: tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{cert},
+				},
+			},
+		})
+	}
+
+	tests := map[string]struct {
+		ctx     context.Context
+		node    *envoy_core_v3.Node
+		wantErr bool
+	}{
+		"no node": {
+			ctx:     context.Background(),
+			wantErr: true,
+		},
+		"unknown node": {
+			ctx:     context.Background(),
+			node:    &envoy_core_v3.Node{Id: "envoy-other-1"},
+			wantErr: true,
+		},
+		"node without certificate check": {
+			ctx:  context.Background(),
+			node: &envoy_core_v3.Node{Id: "envoy-external-abcde"},
+		},
+		"node with authorized certificate": {
+			ctx:  withPeer("spiffe://cluster.local/ns/internal/sa/envoy"),
+			node: &envoy_core_v3.Node{Id: "envoy-internal-abcde"},
+		},
+		"node with unauthorized certificate": {
+			ctx:     withPeer("spiffe://cluster.local/ns/external/sa/envoy"),
+			node:    &envoy_core_v3.Node{Id: "envoy-internal-abcde"},
+			wantErr: true,
+		},
+		"node without certificate": {
+			ctx:     context.Background(),
+			node:    &envoy_core_v3.Node{Id: "envoy-internal-abcde"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := allowlist.Authorize(tc.ctx, tc.node)
+			assert.Equal(t, tc.wantErr, err != nil, "error: %v", err)
+		})
+	}
+}
+
+type namespacedResource struct {
+	mockResource
+	namespaces map[string]string
+}
+
+func (n *namespacedResource) Namespace(name string) string { return n.namespaces[name] }
+
+func TestNodeAllowlistPermit(t *testing.T) {
+	allowlist := NodeAllowlist{{
+		ID:               "envoy-internal-*",
+		SecretNamespaces: []string{"internal"},
+	}, {
+		ID: "envoy-external-*",
+	}}
+
+	secrets := &namespacedResource{
+		mockResource: mockResource{
+			typeurl: func() string { return resource.SecretType },
+		},
+		namespaces: map[string]string{
+			"internal/tls/abcde": "internal",
+			"external/tls/abcde": "external",
+		},
+	}
+	clusters := &mockResource{
+		typeurl: func() string { return resource.ClusterType },
+	}
+
+	internal := &envoy_core_v3.Node{Id: "envoy-internal-1"}
+	external := &envoy_core_v3.Node{Id: "envoy-external-1"}
+
+	assert.True(t, allowlist.Permit(internal, secrets, "internal/tls/abcde"))
+	assert.False(t, allowlist.Permit(internal, secrets, "external/tls/abcde"))
+	assert.True(t, allowlist.Permit(internal, clusters, "external/backend/80/da39a3ee5e"))
+
+	assert.True(t, allowlist.Permit(external, secrets, "internal/tls/abcde"))
+	assert.True(t, allowlist.Permit(external, secrets, "external/tls/abcde"))
+
+	assert.False(t, allowlist.Permit(&envoy_core_v3.Node{Id: "envoy-other-1"}, clusters, "external/backend/80/da39a3ee5e"))
+}
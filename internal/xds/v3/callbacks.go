@@ -14,8 +14,11 @@
 package v3
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	envoy_server_v3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"github.com/sirupsen/logrus"
@@ -23,14 +26,83 @@ import (
 
 // NewRequestLoggingCallbacks returns an implementation of the Envoy xDS server
 // callbacks for use when Contour is run in Envoy xDS server mode to provide
-// request detail logging. Currently only the xDS State of the World callback
-// OnStreamRequest is implemented.
+// request detail logging. Currently only the OnStreamRequest and
+// OnStreamDeltaRequest callbacks are implemented.
 func NewRequestLoggingCallbacks(log logrus.FieldLogger) envoy_server_v3.Callbacks {
 	return &envoy_server_v3.CallbackFuncs{
 		StreamRequestFunc: func(streamID int64, req *envoy_service_discovery_v3.DiscoveryRequest) error {
 			logDiscoveryRequestDetails(log, req)
 			return nil
 		},
+		StreamDeltaRequestFunc: func(streamID int64, req *envoy_service_discovery_v3.DeltaDiscoveryRequest) error {
+			logDeltaDiscoveryRequestDetails(log, req)
+			return nil
+		},
+	}
+}
+
+// NewAuthorizingCallbacks returns an implementation of the Envoy xDS server
+// callbacks that logs request details like NewRequestLoggingCallbacks, and
+// closes the streams of the Envoy nodes that authorizer does not authorize.
+// The Envoy xDS server does not scope the resources each node receives.
+func NewAuthorizingCallbacks(log logrus.FieldLogger, authorizer NodeAuthorizer) envoy_server_v3.Callbacks {
+	// The contexts of the streams whose node has not yet been
+	// authorized. SotW and delta streams are numbered separately.
+	var mu sync.Mutex
+	streams := map[int64]context.Context{}
+	deltaStreams := map[int64]context.Context{}
+
+	open := func(streams map[int64]context.Context) func(context.Context, int64, string) error {
+		return func(ctx context.Context, streamID int64, _ string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			streams[streamID] = ctx
+			return nil
+		}
+	}
+
+	closed := func(streams map[int64]context.Context) func(int64) {
+		return func(streamID int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(streams, streamID)
+		}
+	}
+
+	// authorize authorizes the node of the first request of a stream.
+	authorize := func(streams map[int64]context.Context, streamID int64, node *envoy_core_v3.Node) error {
+		mu.Lock()
+		ctx, ok := streams[streamID]
+		delete(streams, streamID)
+		mu.Unlock()
+
+		if !ok {
+			return nil
+		}
+		return authorizer.Authorize(ctx, node)
+	}
+
+	return &envoy_server_v3.CallbackFuncs{
+		StreamOpenFunc:        open(streams),
+		StreamClosedFunc:      closed(streams),
+		DeltaStreamOpenFunc:   open(deltaStreams),
+		DeltaStreamClosedFunc: closed(deltaStreams),
+		StreamRequestFunc: func(streamID int64, req *envoy_service_discovery_v3.DiscoveryRequest) error {
+			log := logDiscoveryRequestDetails(log, req)
+			if err := authorize(streams, streamID, req.Node); err != nil {
+				log.WithError(err).Error("unauthorized node")
+				return err
+			}
+			return nil
+		},
+		StreamDeltaRequestFunc: func(streamID int64, req *envoy_service_discovery_v3.DeltaDiscoveryRequest) error {
+			log := logDeltaDiscoveryRequestDetails(log, req)
+			if err := authorize(deltaStreams, streamID, req.Node); err != nil {
+				log.WithError(err).Error("unauthorized node")
+				return err
+			}
+			return nil
+		},
 	}
 }
 
@@ -59,3 +131,30 @@ func logDiscoveryRequestDetails(l logrus.FieldLogger, req *envoy_service_discove
 
 	return log
 }
+
+// Helper function for use in the Envoy xDS server callbacks and the Contour
+// xDS server to log delta request details. Returns logger with fields added
+// for any subsequent error handling and logging.
+func logDeltaDiscoveryRequestDetails(l logrus.FieldLogger, req *envoy_service_discovery_v3.DeltaDiscoveryRequest) *logrus.Entry {
+	log := l.WithField("response_nonce", req.ResponseNonce)
+	if req.Node != nil {
+		log = log.WithField("node_id", req.Node.Id)
+
+		if bv := req.Node.GetUserAgentBuildVersion(); bv != nil && bv.Version != nil {
+			log = log.WithField("node_version", fmt.Sprintf("v%d.%d.%d", bv.Version.MajorNumber, bv.Version.MinorNumber, bv.Version.Patch))
+		}
+	}
+
+	if status := req.ErrorDetail; status != nil {
+		// if Envoy rejected the last update log the details here.
+		log.WithField("code", status.Code).Error(status.Message)
+	}
+
+	log = log.WithField("resource_names_subscribe", req.ResourceNamesSubscribe).
+		WithField("resource_names_unsubscribe", req.ResourceNamesUnsubscribe).
+		WithField("type_url", req.GetTypeUrl())
+
+	log.Debug("handling v3 delta xDS resource request")
+
+	return log
+}
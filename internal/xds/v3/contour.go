@@ -18,16 +18,20 @@ import (
 	"fmt"
 	"strconv"
 
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_service_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	envoy_service_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
 	envoy_service_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
 	envoy_service_route_v3 "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	envoy_service_runtime_v3 "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
 	envoy_service_secret_v3 "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/projectcontour/contour/internal/xds"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
@@ -38,12 +42,16 @@ type grpcStream interface {
 }
 
 // NewContourServer creates an internally implemented Server that streams the
-// provided set of Resource objects. The returned Server implements the xDS
-// State of the World (SotW) variant.
-func NewContourServer(log logrus.FieldLogger, resources ...xds.Resource) Server {
+// provided set of Resource objects. The returned Server implements both the
+// xDS State of the World (SotW) and the incremental (delta) variants.
+//
+// If authorizer is not nil, only the Envoy nodes it authorizes may
+// stream resources, and they only receive the entries it permits.
+func NewContourServer(log logrus.FieldLogger, authorizer NodeAuthorizer, resources ...xds.Resource) Server {
 	c := contourServer{
 		FieldLogger: log,
 		resources:   map[string]xds.Resource{},
+		authorizer:  authorizer,
 	}
 
 	for i, r := range resources {
@@ -55,18 +63,48 @@ func NewContourServer(log logrus.FieldLogger, resources ...xds.Resource) Server
 
 type contourServer struct {
 	// Since we only implement the streaming state of the world
-	// protocol, embed the default null implementations to handle
-	// the unimplemented gRPC endpoints.
+	// and delta protocols, embed the default null implementations
+	// to handle the unimplemented gRPC endpoints.
 	envoy_service_discovery_v3.UnimplementedAggregatedDiscoveryServiceServer
 	envoy_service_secret_v3.UnimplementedSecretDiscoveryServiceServer
 	envoy_service_route_v3.UnimplementedRouteDiscoveryServiceServer
 	envoy_service_endpoint_v3.UnimplementedEndpointDiscoveryServiceServer
 	envoy_service_cluster_v3.UnimplementedClusterDiscoveryServiceServer
 	envoy_service_listener_v3.UnimplementedListenerDiscoveryServiceServer
+	envoy_service_runtime_v3.UnimplementedRuntimeDiscoveryServiceServer
 
 	logrus.FieldLogger
 	resources   map[string]xds.Resource
 	connections xds.Counter
+	authorizer  NodeAuthorizer
+}
+
+// authorize returns a PermissionDenied error if the authorizer does
+// not authorize node to stream resources over the stream whose
+// context is ctx.
+func (s *contourServer) authorize(ctx context.Context, node *envoy_core_v3.Node) error {
+	if s.authorizer == nil {
+		return nil
+	}
+	if err := s.authorizer.Authorize(ctx, node); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// permitted returns the resources of r that node may receive.
+func (s *contourServer) permitted(node *envoy_core_v3.Node, r xds.Resource, resources []proto.Message) []proto.Message {
+	if s.authorizer == nil {
+		return resources
+	}
+
+	var out []proto.Message
+	for _, res := range resources {
+		if s.authorizer.Permit(node, r, resourceName(res)) {
+			out = append(out, res)
+		}
+	}
+	return out
 }
 
 // stream processes a stream of DiscoveryRequests.
@@ -93,6 +131,10 @@ func (s *contourServer) stream(st grpcStream) error {
 	last := -1
 	ctx := st.Context()
 
+	// node is the Envoy node of the stream, which Envoy may
+	// only identify in the first request.
+	var node *envoy_core_v3.Node
+
 	// now stick in this loop until the client disconnects.
 	for {
 		// first we wait for the request from Envoy, this is part of
@@ -105,6 +147,13 @@ func (s *contourServer) stream(st grpcStream) error {
 		// Note: redeclare log in this scope so the next time around the loop all is forgotten.
 		log := logDiscoveryRequestDetails(log, req)
 
+		if node == nil {
+			if err := s.authorize(ctx, req.Node); err != nil {
+				return done(log, err)
+			}
+			node = req.Node
+		}
+
 		// From the request we derive the resource to stream which have
 		// been registered according to the typeURL.
 		r, ok := s.resources[req.GetTypeUrl()]
@@ -131,6 +180,7 @@ func (s *contourServer) stream(st grpcStream) error {
 				// resource hints supplied, return exactly those
 				resources = r.Query(req.ResourceNames)
 			}
+			resources = s.permitted(node, r, resources)
 
 			any := make([]*any.Any, 0, len(resources))
 			for _, r := range resources {
@@ -177,3 +227,7 @@ func (s *contourServer) StreamRoutes(srv envoy_service_route_v3.RouteDiscoverySe
 func (s *contourServer) StreamSecrets(srv envoy_service_secret_v3.SecretDiscoveryService_StreamSecretsServer) error {
 	return s.stream(srv)
 }
+
+func (s *contourServer) StreamRuntime(srv envoy_service_runtime_v3.RuntimeDiscoveryService_StreamRuntimeServer) error {
+	return s.stream(srv)
+}
@@ -0,0 +1,400 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_service_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	envoy_service_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
+	envoy_service_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/service/listener/v3"
+	envoy_service_route_v3 "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	envoy_service_runtime_v3 "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
+	envoy_service_secret_v3 "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	envoy_cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// wildcardResourceName is the resource name Envoy uses to explicitly
+// subscribe to, or unsubscribe from, all the resources of a type.
+const wildcardResourceName = "*"
+
+type deltaGrpcStream interface {
+	Context() context.Context
+	Send(*envoy_service_discovery_v3.DeltaDiscoveryResponse) error
+	Recv() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error)
+}
+
+// deltaState tracks the resources a delta xDS stream is subscribed
+// to and the version of each resource last sent on the stream.
+type deltaState struct {
+	wildcard   bool
+	subscribed map[string]struct{}
+	versions   map[string]string
+
+	// answered holds the aliases Envoy has been told the
+	// resolution of.
+	answered map[string]struct{}
+
+	// permit, if not nil, returns false for the names of the
+	// resources that may not be sent on the stream.
+	permit func(name string) bool
+}
+
+// newDeltaState returns the deltaState for a stream whose first
+// request is req. A stream that does not subscribe to any names in
+// its first request is a wildcard stream and receives all resources.
+func newDeltaState(req *envoy_service_discovery_v3.DeltaDiscoveryRequest) *deltaState {
+	d := &deltaState{
+		wildcard:   len(req.ResourceNamesSubscribe) == 0,
+		subscribed: map[string]struct{}{},
+		versions:   map[string]string{},
+		answered:   map[string]struct{}{},
+	}
+
+	// Envoy tells us the resources it already has when it
+	// reconnects, so that they are not sent again.
+	for name, version := range req.InitialResourceVersions {
+		d.versions[name] = version
+	}
+
+	d.update(req)
+	return d
+}
+
+// update applies the subscription changes in req, returning true if
+// the set of subscribed resources has changed.
+func (d *deltaState) update(req *envoy_service_discovery_v3.DeltaDiscoveryRequest) bool {
+	changed := false
+
+	for _, name := range req.ResourceNamesSubscribe {
+		if name == wildcardResourceName {
+			changed = changed || !d.wildcard
+			d.wildcard = true
+			continue
+		}
+		if _, ok := d.subscribed[name]; !ok {
+			d.subscribed[name] = struct{}{}
+			changed = true
+		}
+	}
+
+	for _, name := range req.ResourceNamesUnsubscribe {
+		if name == wildcardResourceName {
+			changed = changed || d.wildcard
+			d.wildcard = false
+			continue
+		}
+		if _, ok := d.subscribed[name]; ok {
+			delete(d.subscribed, name)
+			changed = true
+		}
+		// Envoy discards unsubscribed resources, so forget
+		// their version to send them again on resubscription.
+		delete(d.versions, name)
+		delete(d.answered, name)
+	}
+
+	return changed
+}
+
+// diff returns the subscribed resources of r that have changed since
+// they were last sent on the stream, and the names of the resources
+// that Envoy has been sent but no longer exist.
+func (d *deltaState) diff(r xds.Resource) ([]*envoy_service_discovery_v3.Resource, []string, error) {
+	var changed []*envoy_service_discovery_v3.Resource
+
+	// aliases holds the subscribed aliases of each resource name.
+	aliases := map[string][]string{}
+
+	var resources []proto.Message
+	if d.wildcard {
+		resources = r.Contents()
+	} else {
+		names := make([]string, 0, len(d.subscribed))
+		for name := range d.subscribed {
+			if a, ok := r.(xds.Aliased); ok {
+				if resolved, ok := a.Alias(name); ok {
+					if resolved == "" {
+						// Tell Envoy once that the alias does not
+						// resolve, so that it stops waiting for it.
+						if _, ok := d.answered[name]; !ok {
+							d.answered[name] = struct{}{}
+							changed = append(changed, &envoy_service_discovery_v3.Resource{
+								Name:    name,
+								Aliases: []string{name},
+							})
+						}
+						continue
+					}
+					aliases[resolved] = append(aliases[resolved], name)
+					name = resolved
+				}
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		resources = r.Query(dedup(names))
+	}
+
+	current := make(map[string]struct{}, len(resources))
+	for _, res := range resources {
+		name := resourceName(res)
+		if d.permit != nil && !d.permit(name) {
+			continue
+		}
+		current[name] = struct{}{}
+
+		marshaled, err := envoy_cache_v3.MarshalResource(res)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// A resource is sent again when it is requested by an
+		// alias Envoy has not yet been told the resolution of.
+		version := envoy_cache_v3.HashResource(marshaled)
+		unanswered := false
+		sort.Strings(aliases[name])
+		for _, alias := range aliases[name] {
+			if _, ok := d.answered[alias]; !ok {
+				d.answered[alias] = struct{}{}
+				unanswered = true
+			}
+		}
+		if d.versions[name] == version && !unanswered {
+			continue
+		}
+
+		a, err := anypb.New(proto.MessageV2(res))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		changed = append(changed, &envoy_service_discovery_v3.Resource{
+			Name:     name,
+			Aliases:  aliases[name],
+			Version:  version,
+			Resource: a,
+		})
+		d.versions[name] = version
+	}
+
+	var removed []string
+	for name := range d.versions {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+			delete(d.versions, name)
+		}
+	}
+	sort.Strings(removed)
+
+	return changed, removed, nil
+}
+
+// resourceName returns the name of res, which for virtual hosts is
+// their VHDS name.
+func resourceName(res proto.Message) string {
+	if vh, ok := res.(*envoy_route_v3.VirtualHost); ok {
+		return vh.GetName()
+	}
+	return envoy_cache_v3.GetResourceName(res)
+}
+
+// dedup returns names, which must be sorted, without repeated elements.
+func dedup(names []string) []string {
+	var out []string
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// deltaStream processes a stream of DeltaDiscoveryRequests. Unlike
+// the State of the World variant, only the resources that have
+// changed since they were last sent are sent to Envoy.
+func (s *contourServer) deltaStream(st deltaGrpcStream) error {
+	// Bump connection counter and set it as a field on the logger.
+	log := s.WithField("connection", s.connections.Next()).WithField("delta", true)
+
+	// Notify whether the stream terminated on error.
+	done := func(log logrus.FieldLogger, err error) error {
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+
+		return err
+	}
+
+	ctx := st.Context()
+
+	// Envoy may change its subscriptions at any time, so requests
+	// are received concurrently with waiting for cache changes.
+	reqs := make(chan *envoy_service_discovery_v3.DeltaDiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		r     xds.Resource
+		state *deltaState
+		nonce int
+	)
+
+	ch := make(chan int, 1)
+	registered := false
+
+	// internally all registration values start at zero so sending
+	// a last that is less than zero will guarantee that the first
+	// response on each stream is generated immediately.
+	last := -1
+	initial := true
+
+	send := func() error {
+		resources, removed, err := state.diff(r)
+		if err != nil {
+			return err
+		}
+
+		// Envoy waits for the first response on a stream before
+		// it finishes initializing, so send it even when empty.
+		if len(resources) == 0 && len(removed) == 0 && !initial {
+			return nil
+		}
+		initial = false
+
+		nonce++
+		return st.Send(&envoy_service_discovery_v3.DeltaDiscoveryResponse{
+			SystemVersionInfo: strconv.Itoa(last),
+			Resources:         resources,
+			RemovedResources:  removed,
+			TypeUrl:           r.TypeURL(),
+			Nonce:             strconv.Itoa(nonce),
+		})
+	}
+
+	// now stick in this loop until the client disconnects.
+	for {
+		if r != nil && !registered {
+			r.Register(ch, last)
+			registered = true
+		}
+
+		select {
+		case req := <-reqs:
+			// Note: redeclare log in this scope so the next time around the loop all is forgotten.
+			log := logDeltaDiscoveryRequestDetails(log, req)
+
+			if r == nil {
+				// From the first request we derive the resource to stream which
+				// has been registered according to the typeURL.
+				var ok bool
+				if r, ok = s.resources[req.GetTypeUrl()]; !ok {
+					return done(log, fmt.Errorf("no resource registered for typeURL %q", req.GetTypeUrl()))
+				}
+				if err := s.authorize(ctx, req.Node); err != nil {
+					return done(log, err)
+				}
+				state = newDeltaState(req)
+				if s.authorizer != nil {
+					node := req.Node
+					state.permit = func(name string) bool {
+						return s.authorizer.Permit(node, r, name)
+					}
+				}
+				continue
+			}
+
+			if req.GetTypeUrl() != r.TypeURL() {
+				return done(log, fmt.Errorf("typeURL %q does not match stream typeURL %q", req.GetTypeUrl(), r.TypeURL()))
+			}
+
+			// Requests that only acknowledge a response do not
+			// change the subscriptions, so need no response.
+			if !state.update(req) || initial {
+				continue
+			}
+
+			if err := send(); err != nil {
+				return done(log, err)
+			}
+
+		case last = <-ch:
+			// something in the cache has changed.
+			registered = false
+
+			if err := send(); err != nil {
+				return done(log, err)
+			}
+
+		case err := <-errs:
+			return done(log, err)
+
+		case <-ctx.Done():
+			return done(log, ctx.Err())
+		}
+	}
+}
+
+func (s *contourServer) DeltaClusters(srv envoy_service_cluster_v3.ClusterDiscoveryService_DeltaClustersServer) error {
+	return s.deltaStream(srv)
+}
+
+func (s *contourServer) DeltaEndpoints(srv envoy_service_endpoint_v3.EndpointDiscoveryService_DeltaEndpointsServer) error {
+	return s.deltaStream(srv)
+}
+
+func (s *contourServer) DeltaListeners(srv envoy_service_listener_v3.ListenerDiscoveryService_DeltaListenersServer) error {
+	return s.deltaStream(srv)
+}
+
+func (s *contourServer) DeltaRoutes(srv envoy_service_route_v3.RouteDiscoveryService_DeltaRoutesServer) error {
+	return s.deltaStream(srv)
+}
+
+func (s *contourServer) DeltaVirtualHosts(srv envoy_service_route_v3.VirtualHostDiscoveryService_DeltaVirtualHostsServer) error {
+	return s.deltaStream(srv)
+}
+
+func (s *contourServer) DeltaSecrets(srv envoy_service_secret_v3.SecretDiscoveryService_DeltaSecretsServer) error {
+	return s.deltaStream(srv)
+}
+
+func (s *contourServer) DeltaRuntime(srv envoy_service_runtime_v3.RuntimeDiscoveryService_DeltaRuntimeServer) error {
+	return s.deltaStream(srv)
+}
@@ -0,0 +1,227 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXDSHandlerDeltaStream(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	potato := func() *mockResource {
+		return &mockResource{
+			register: func(ch chan int, i int) {
+				ch <- i + 1
+			},
+			contents: func() []proto.Message {
+				return []proto.Message{&envoy_endpoint_v3.ClusterLoadAssignment{ClusterName: "potato"}}
+			},
+			typeurl: func() string { return "io.projectcontour.potato" },
+		}
+	}
+
+	// once returns a recv func that returns req, then blocks
+	// until ctx is canceled.
+	once := func(ctx context.Context, req *envoy_service_discovery_v3.DeltaDiscoveryRequest) func() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error) {
+		sent := false
+		return func() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error) {
+			if !sent {
+				sent = true
+				return req, nil
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+	}
+
+	t.Run("recv returns error immediately", func(t *testing.T) {
+		xh := contourServer{FieldLogger: log}
+		got := xh.deltaStream(&mockDeltaStream{
+			context: context.Background,
+			recv: func() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error) {
+				return nil, io.EOF
+			},
+		})
+		assert.Equal(t, io.EOF, got)
+	})
+
+	t.Run("no registered typeURL", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		xh := contourServer{FieldLogger: log}
+		got := xh.deltaStream(&mockDeltaStream{
+			context: func() context.Context { return ctx },
+			recv: once(ctx, &envoy_service_discovery_v3.DeltaDiscoveryRequest{
+				TypeUrl: "io.projectcontour.potato",
+			}),
+		})
+		assert.Equal(t, fmt.Errorf("no resource registered for typeURL %q", "io.projectcontour.potato"), got)
+	})
+
+	t.Run("failed to send", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		xh := contourServer{
+			FieldLogger: log,
+			resources: map[string]xds.Resource{
+				"io.projectcontour.potato": potato(),
+			},
+		}
+		got := xh.deltaStream(&mockDeltaStream{
+			context: func() context.Context { return ctx },
+			recv: once(ctx, &envoy_service_discovery_v3.DeltaDiscoveryRequest{
+				TypeUrl: "io.projectcontour.potato",
+			}),
+			send: func(resp *envoy_service_discovery_v3.DeltaDiscoveryResponse) error {
+				return io.EOF
+			},
+		})
+		assert.Equal(t, io.EOF, got)
+	})
+
+	t.Run("initial response", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		xh := contourServer{
+			FieldLogger: log,
+			resources: map[string]xds.Resource{
+				"io.projectcontour.potato": potato(),
+			},
+		}
+
+		var got *envoy_service_discovery_v3.DeltaDiscoveryResponse
+		err := xh.deltaStream(&mockDeltaStream{
+			context: func() context.Context { return ctx },
+			recv: once(ctx, &envoy_service_discovery_v3.DeltaDiscoveryRequest{
+				TypeUrl: "io.projectcontour.potato",
+			}),
+			send: func(resp *envoy_service_discovery_v3.DeltaDiscoveryResponse) error {
+				got = resp
+				cancel()
+				return nil
+			},
+		})
+		assert.Equal(t, context.Canceled, err)
+		require.NotNil(t, got)
+		assert.Equal(t, "io.projectcontour.potato", got.TypeUrl)
+		require.Len(t, got.Resources, 1)
+		assert.Equal(t, "potato", got.Resources[0].Name)
+	})
+}
+
+func TestDeltaStateDiff(t *testing.T) {
+	contents := []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{ClusterName: "a"},
+		&envoy_endpoint_v3.ClusterLoadAssignment{ClusterName: "b"},
+	}
+	r := &mockResource{
+		contents: func() []proto.Message { return contents },
+		query: func(names []string) []proto.Message {
+			var values []proto.Message
+			for _, name := range names {
+				values = append(values, &envoy_endpoint_v3.ClusterLoadAssignment{ClusterName: name})
+			}
+			return values
+		},
+	}
+
+	names := func(resources []*envoy_service_discovery_v3.Resource) []string {
+		var names []string
+		for _, r := range resources {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+
+	// A wildcard stream is sent every resource once.
+	d := newDeltaState(&envoy_service_discovery_v3.DeltaDiscoveryRequest{})
+	changed, removed, err := d.diff(r)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, names(changed))
+	assert.Empty(t, removed)
+
+	changed, removed, err = d.diff(r)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+	assert.Empty(t, removed)
+
+	// Only changed resources are sent, and deleted ones removed.
+	contents = []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "a",
+			Endpoints:   []*envoy_endpoint_v3.LocalityLbEndpoints{{}},
+		},
+	}
+	changed, removed, err = d.diff(r)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, names(changed))
+	assert.Equal(t, []string{"b"}, removed)
+
+	// Resources Envoy already has are not sent again.
+	d = newDeltaState(&envoy_service_discovery_v3.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe: []string{"c"},
+	})
+	changed, _, err = d.diff(r)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+
+	d = newDeltaState(&envoy_service_discovery_v3.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe:  []string{"c"},
+		InitialResourceVersions: map[string]string{"c": changed[0].Version},
+	})
+	changed, removed, err = d.diff(r)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+	assert.Empty(t, removed)
+
+	// Subscription changes are reported.
+	assert.True(t, d.update(&envoy_service_discovery_v3.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe: []string{"d"},
+	}))
+	assert.False(t, d.update(&envoy_service_discovery_v3.DeltaDiscoveryRequest{}))
+	changed, _, err = d.diff(r)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d"}, names(changed))
+}
+
+type mockDeltaStream struct {
+	context func() context.Context
+	send    func(*envoy_service_discovery_v3.DeltaDiscoveryResponse) error
+	recv    func() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error)
+}
+
+func (m *mockDeltaStream) Context() context.Context { return m.context() }
+func (m *mockDeltaStream) Send(resp *envoy_service_discovery_v3.DeltaDiscoveryResponse) error {
+	return m.send(resp)
+}
+func (m *mockDeltaStream) Recv() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error) {
+	return m.recv()
+}
@@ -41,7 +41,7 @@ func (s *snapshotter) Generate(version string, resources map[envoy_types.Respons
 		resources[envoy_types.Cluster],
 		resources[envoy_types.Route],
 		resources[envoy_types.Listener],
-		nil,
+		resources[envoy_types.Runtime],
 		resources[envoy_types.Secret],
 	)
 
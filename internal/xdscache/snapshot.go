@@ -18,10 +18,14 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"time"
 
 	envoy_types "github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
 	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/health"
+	"github.com/projectcontour/contour/internal/metrics"
 	"github.com/sirupsen/logrus"
 )
 
@@ -42,6 +46,17 @@ type SnapshotHandler struct {
 	snapshotters []Snapshotter
 	snapLock     sync.Mutex
 
+	// published holds the resources of the last snapshot that
+	// every snapshotter generated, or nil if there is none.
+	published map[envoy_types.ResponseType][]envoy_types.Resource
+
+	// Metrics to emit. May be nil.
+	Metrics *metrics.Metrics
+
+	// Freshness is told when a snapshot has been
+	// generated successfully. May be nil.
+	Freshness *health.Freshness
+
 	logrus.FieldLogger
 }
 
@@ -58,6 +73,11 @@ func (s *SnapshotHandler) AddSnapshotter(snap Snapshotter) {
 	defer s.snapLock.Unlock()
 
 	s.snapshotters = append(s.snapshotters, snap)
+
+	// The new snapshotter has not generated the
+	// published snapshot, so the next one must not
+	// be skipped.
+	s.published = nil
 }
 
 // Refresh is called when the EndpointsTranslator updates values
@@ -74,9 +94,6 @@ func (s *SnapshotHandler) OnChange(root *dag.DAG) {
 // generateNewSnapshot creates a new snapshot against
 // the Contour XDS caches.
 func (s *SnapshotHandler) generateNewSnapshot() {
-	// Generate new snapshot version.
-	version := s.newSnapshotVersion()
-
 	resources := map[envoy_types.ResponseType][]envoy_types.Resource{
 		envoy_types.Endpoint: asResources(s.resources[envoy_types.Endpoint].Contents()),
 		envoy_types.Cluster:  asResources(s.resources[envoy_types.Cluster].Contents()),
@@ -84,15 +101,51 @@ func (s *SnapshotHandler) generateNewSnapshot() {
 		envoy_types.Listener: asResources(s.resources[envoy_types.Listener].Contents()),
 		envoy_types.Secret:   asResources(s.resources[envoy_types.Secret].Contents()),
 	}
+	if runtime, ok := s.resources[envoy_types.Runtime]; ok {
+		resources[envoy_types.Runtime] = asResources(runtime.Contents())
+	}
 
 	s.snapLock.Lock()
 	defer s.snapLock.Unlock()
 
+	// Skip the snapshot if nothing has changed, so that
+	// Envoy doesn't have to ACK a no-op update.
+	if equalResources(s.published, resources) {
+		s.Debug("skipping snapshot, resources are unchanged")
+		if s.Freshness != nil {
+			s.Freshness.Synced()
+		}
+		return
+	}
+
+	// Generate new snapshot version.
+	version := s.newSnapshotVersion()
+
+	failed := false
 	for _, snap := range s.snapshotters {
 		if err := snap.Generate(version, resources); err != nil {
 			s.Errorf("failed to generate snapshot version %q: %s", version, err)
+			failed = true
 		}
 	}
+
+	if failed {
+		s.published = nil
+		return
+	}
+
+	if len(s.snapshotters) > 0 {
+		s.published = resources
+		if s.Metrics != nil {
+			s.Metrics.SetXDSSnapshotTimestamp(time.Now())
+		}
+	}
+
+	// With no snapshotters the xDS server serves the
+	// caches directly, so they are already current.
+	if s.Freshness != nil {
+		s.Freshness.Synced()
+	}
 }
 
 // newSnapshotVersion increments the current snapshotVersion
@@ -109,6 +162,28 @@ func (s *SnapshotHandler) newSnapshotVersion() string {
 	return strconv.FormatInt(s.snapshotVersion, 10)
 }
 
+// equalResources returns true if the resources of each type in a
+// and b are equal. It returns false if a is nil.
+func equalResources(a, b map[envoy_types.ResponseType][]envoy_types.Resource) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+
+	for typ, resources := range b {
+		published, ok := a[typ]
+		if !ok || len(published) != len(resources) {
+			return false
+		}
+		for i := range resources {
+			if !proto.Equal(published[i], resources[i]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 // asResources casts the given slice of values (that implement the envoy_types.Resource
 // interface) to a slice of envoy_types.Resource. If the length of the slice is 0, it
 // returns nil.
@@ -144,6 +219,8 @@ func parseResources(resources []ResourceCache) map[envoy_types.ResponseType]Reso
 			resourceMap[envoy_types.Secret] = r
 		case resource.EndpointType:
 			resourceMap[envoy_types.Endpoint] = r
+		case resource.RuntimeType:
+			resourceMap[envoy_types.Runtime] = r
 		}
 	}
 	return resourceMap
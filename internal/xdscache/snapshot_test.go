@@ -17,6 +17,12 @@ import (
 	"math"
 	"testing"
 
+	envoy_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_types "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,3 +61,63 @@ func TestGetNewSnapshotVersion(t *testing.T) {
 		want:            "1",
 	})
 }
+
+// staticCache is a ResourceCache with fixed contents.
+type staticCache struct {
+	typeURL  string
+	contents []proto.Message
+}
+
+func (c *staticCache) OnChange(*dag.DAG)                 {}
+func (c *staticCache) Contents() []proto.Message         { return c.contents }
+func (c *staticCache) Query([]string) []proto.Message    { return nil }
+func (c *staticCache) Register(chan int, int, ...string) {}
+func (c *staticCache) TypeURL() string                   { return c.typeURL }
+
+// recordingSnapshotter records the version of each snapshot generated.
+type recordingSnapshotter struct {
+	versions []string
+}
+
+func (r *recordingSnapshotter) Generate(version string, _ map[envoy_types.ResponseType][]envoy_types.Resource) error {
+	r.versions = append(r.versions, version)
+	return nil
+}
+
+func TestSnapshotSkippedWhenUnchanged(t *testing.T) {
+	clusters := &staticCache{typeURL: resource.ClusterType}
+	sh := NewSnapshotHandler([]ResourceCache{
+		clusters,
+		&staticCache{typeURL: resource.RouteType},
+		&staticCache{typeURL: resource.ListenerType},
+		&staticCache{typeURL: resource.SecretType},
+		&staticCache{typeURL: resource.EndpointType},
+	}, fixture.NewTestLogger(t))
+
+	first := &recordingSnapshotter{}
+	sh.AddSnapshotter(first)
+
+	sh.Refresh()
+	assert.Equal(t, []string{"1"}, first.versions)
+
+	// Nothing has changed, so no snapshot is generated.
+	sh.Refresh()
+	assert.Equal(t, []string{"1"}, first.versions)
+
+	// A changed resource generates a new snapshot.
+	clusters.contents = []proto.Message{&envoy_cluster_v3.Cluster{Name: "default/kuard/80"}}
+	sh.Refresh()
+	assert.Equal(t, []string{"1", "2"}, first.versions)
+
+	// An equal, but not identical, resource does not.
+	clusters.contents = []proto.Message{&envoy_cluster_v3.Cluster{Name: "default/kuard/80"}}
+	sh.Refresh()
+	assert.Equal(t, []string{"1", "2"}, first.versions)
+
+	// A new snapshotter must receive a snapshot.
+	second := &recordingSnapshotter{}
+	sh.AddSnapshotter(second)
+	sh.Refresh()
+	assert.Equal(t, []string{"1", "2", "3"}, first.versions)
+	assert.Equal(t, []string{"3"}, second.versions)
+}
@@ -30,6 +30,23 @@ import (
 
 // ClusterCache manages the contents of the gRPC CDS cache.
 type ClusterCache struct {
+	// TCPKeepalive is the default TCP keepalive configuration
+	// for clusters that do not configure their own.
+	TCPKeepalive *dag.TCPKeepalive
+
+	// ZoneAwareRouting, if not nil, enables zone-aware routing
+	// on all the clusters whose endpoints are discovered by EDS.
+	ZoneAwareRouting *envoy_cluster_v3.Cluster_CommonLbConfig_ZoneAwareLbConfig
+
+	// XDSDelta, if true, configures clusters whose endpoints are
+	// discovered by EDS to request them with the delta xDS protocol.
+	XDSDelta bool
+
+	// MirrorPort is the port of Envoy's mirror listener, which the
+	// mirror cluster connects to. If not set, defaults to
+	// DEFAULT_MIRROR_LISTENER_PORT.
+	MirrorPort int
+
 	mu     sync.Mutex
 	values map[string]*envoy_cluster_v3.Cluster
 	contour.Cond
@@ -77,18 +94,50 @@ func (c *ClusterCache) Query(names []string) []proto.Message {
 func (*ClusterCache) TypeURL() string { return resource.ClusterType }
 
 func (c *ClusterCache) OnChange(root *dag.DAG) {
-	clusters := visitClusters(root)
+	clusters := visitClusters(root, mirrorListenerPort(c.MirrorPort))
+	if c.TCPKeepalive != nil {
+		for _, cluster := range clusters {
+			if cluster.UpstreamConnectionOptions == nil {
+				cluster.UpstreamConnectionOptions = envoy_v3.UpstreamTCPKeepalive(c.TCPKeepalive)
+			}
+		}
+	}
+	if c.ZoneAwareRouting != nil {
+		for _, cluster := range clusters {
+			if cluster.GetType() != envoy_cluster_v3.Cluster_EDS {
+				continue
+			}
+			if cluster.CommonLbConfig == nil {
+				cluster.CommonLbConfig = &envoy_cluster_v3.Cluster_CommonLbConfig{}
+			}
+			cluster.CommonLbConfig.LocalityConfigSpecifier = &envoy_cluster_v3.Cluster_CommonLbConfig_ZoneAwareLbConfig_{
+				ZoneAwareLbConfig: c.ZoneAwareRouting,
+			}
+		}
+	}
+	if c.XDSDelta {
+		for _, cluster := range clusters {
+			if cluster.GetEdsClusterConfig() == nil {
+				continue
+			}
+			cluster.EdsClusterConfig.EdsConfig = envoy_v3.DeltaConfigSource("contour")
+		}
+	}
 	c.Update(clusters)
 }
 
 type clusterVisitor struct {
-	clusters map[string]*envoy_cluster_v3.Cluster
+	clusters   map[string]*envoy_cluster_v3.Cluster
+	mirrorPort int
 }
 
-// visitCluster produces a map of *envoy_cluster_v3.Clusters.
-func visitClusters(root dag.Vertex) map[string]*envoy_cluster_v3.Cluster {
+// visitCluster produces a map of *envoy_cluster_v3.Clusters. The
+// mirror cluster, if any, connects to the mirror listener on
+// mirrorPort.
+func visitClusters(root dag.Vertex, mirrorPort int) map[string]*envoy_cluster_v3.Cluster {
 	cv := clusterVisitor{
-		clusters: make(map[string]*envoy_cluster_v3.Cluster),
+		clusters:   make(map[string]*envoy_cluster_v3.Cluster),
+		mirrorPort: mirrorPort,
 	}
 	cv.visit(root)
 	return cv.clusters
@@ -106,6 +155,20 @@ func (v *clusterVisitor) visit(vertex dag.Vertex) {
 		if _, ok := v.clusters[name]; !ok {
 			v.clusters[name] = envoy_v3.ExtensionCluster(cluster)
 		}
+	case *dag.DynamicForwardProxyCluster:
+		name := envoy_v3.DynamicForwardProxyClusterName
+		if _, ok := v.clusters[name]; !ok {
+			v.clusters[name] = envoy_v3.DynamicForwardProxyCluster(cluster)
+		}
+	}
+
+	// Routes that change the headers of the requests they
+	// mirror send them through the mirror cluster.
+	if r, ok := vertex.(*dag.Route); ok && mirrorsWithHeaders(r) {
+		name := envoy_v3.MirrorClusterName
+		if _, ok := v.clusters[name]; !ok {
+			v.clusters[name] = envoy_v3.MirrorCluster(ENVOY_MIRROR_LISTENER_ADDRESS, v.mirrorPort)
+		}
 	}
 
 	// recurse into children of v
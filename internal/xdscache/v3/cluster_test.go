@@ -819,7 +819,7 @@ func TestClusterVisit(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			root := buildDAG(t, tc.objs...)
-			got := visitClusters(root)
+			got := visitClusters(root, DEFAULT_MIRROR_LISTENER_PORT)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -18,6 +18,7 @@ import (
 	"sort"
 	"sync"
 
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/golang/protobuf/proto"
@@ -25,10 +26,13 @@ import (
 	"github.com/projectcontour/contour/internal/dag"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/k8s"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/sorter"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	discovery_v1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 )
@@ -40,11 +44,42 @@ type LoadBalancingEndpoint = envoy_endpoint_v3.LbEndpoint
 // resources by matching the given service port to the given v1.Endpoints.
 // ep may be nil, in which case, the result is also nil.
 func RecalculateEndpoints(port v1.ServicePort, ep *v1.Endpoints) []*LoadBalancingEndpoint {
+	var lb []*LoadBalancingEndpoint
+	for _, le := range recalculateEndpoints(port, ep, nil, nil) {
+		lb = append(lb, le.LbEndpoints...)
+	}
+	return lb
+}
+
+// recalculateEndpoints is like RecalculateEndpoints, but only uses
+// the addresses for which include returns true, and groups them by
+// the locality of the node they run on. include may be nil, in which
+// case all the ready addresses are used. Addresses on nodes that are
+// not in nodes share a single empty locality.
+func recalculateEndpoints(port v1.ServicePort, ep *v1.Endpoints, include func(v1.EndpointAddress) bool, nodes map[string]*envoy_core_v3.Locality) []*LocalityEndpoints {
 	if ep == nil {
 		return nil
 	}
 
-	var lb []*LoadBalancingEndpoint
+	var localities []*LocalityEndpoints
+	index := map[string]*LocalityEndpoints{}
+
+	add := func(a v1.EndpointAddress, lb *LoadBalancingEndpoint) {
+		var locality *envoy_core_v3.Locality
+		if a.NodeName != nil {
+			locality = nodes[*a.NodeName]
+		}
+
+		key := locality.GetRegion() + "/" + locality.GetZone()
+		le, ok := index[key]
+		if !ok {
+			le = &LocalityEndpoints{Locality: locality}
+			index[key] = le
+			localities = append(localities, le)
+		}
+		le.LbEndpoints = append(le.LbEndpoints, lb)
+	}
+
 	for _, s := range ep.Subsets {
 		// Skip subsets without ready addresses.
 		if len(s.Addresses) < 1 {
@@ -70,7 +105,98 @@ func RecalculateEndpoints(port v1.ServicePort, ep *v1.Endpoints) []*LoadBalancin
 			sort.Slice(addresses, func(i, j int) bool { return addresses[i].IP < addresses[j].IP })
 
 			for _, a := range addresses {
+				if include != nil && !include(a) {
+					continue
+				}
+
 				addr := envoy_v3.SocketAddress(a.IP, int(p.Port))
+				add(a, envoy_v3.LBEndpoint(addr))
+			}
+		}
+	}
+
+	return localities
+}
+
+// nodeLocality returns the Envoy locality of node, from its
+// topology labels. The result is nil if node has no such labels.
+func nodeLocality(node *v1.Node) *envoy_core_v3.Locality {
+	labels := node.GetLabels()
+
+	region := labels[v1.LabelTopologyRegion]
+	if region == "" {
+		region = labels[v1.LabelFailureDomainBetaRegion]
+	}
+	zone := labels[v1.LabelTopologyZone]
+	if zone == "" {
+		zone = labels[v1.LabelFailureDomainBetaZone]
+	}
+
+	if region == "" && zone == "" {
+		return nil
+	}
+
+	return &envoy_core_v3.Locality{
+		Region: region,
+		Zone:   zone,
+	}
+}
+
+// RecalculateEndpointSlices generates a slice of LoadBalancingEndpoint
+// resources by matching the given service port to the ready endpoints
+// of the given EndpointSlices. slices may be empty, in which case, the
+// result is nil.
+func RecalculateEndpointSlices(port v1.ServicePort, slices map[string]*discovery_v1beta1.EndpointSlice) []*LoadBalancingEndpoint {
+	// Visit the slices in name order so that the result is stable.
+	names := make([]string, 0, len(slices))
+	for name := range slices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lb []*LoadBalancingEndpoint
+	for _, name := range names {
+		es := slices[name]
+
+		// Envoy can only route to IP addresses.
+		if es.AddressType == discovery_v1beta1.AddressTypeFQDN {
+			continue
+		}
+
+		for _, p := range es.Ports {
+			if p.Port == nil {
+				continue
+			}
+
+			if p.Protocol != nil && port.Protocol != *p.Protocol && *p.Protocol != v1.ProtocolTCP {
+				// NOTE: we only support "TCP", which is the default.
+				continue
+			}
+
+			// As for Endpoints, an unnamed port matches by
+			// definition. Otherwise, only take endpoint ports
+			// that match the service port name.
+			if port.Name != "" && port.Name != stringOrEmpty(p.Name) {
+				continue
+			}
+
+			var addresses []string
+			for _, e := range es.Endpoints {
+				// Endpoints with unknown readiness are ready.
+				if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+					continue
+				}
+
+				// Consumers of an EndpointSlice should only
+				// use the first address of each endpoint.
+				if len(e.Addresses) > 0 {
+					addresses = append(addresses, e.Addresses[0])
+				}
+			}
+			sort.Strings(addresses)
+
+			for _, a := range addresses {
+				addr := envoy_v3.SocketAddress(a, int(*p.Port))
 				lb = append(lb, envoy_v3.LBEndpoint(addr))
 			}
 		}
@@ -79,6 +205,40 @@ func RecalculateEndpoints(port v1.ServicePort, ep *v1.Endpoints) []*LoadBalancin
 	return lb
 }
 
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// podSelectorOf returns a function that matches the endpoint
+// addresses whose pods have labels in pods that match selector.
+func podSelectorOf(selector map[string]string, pods map[types.NamespacedName]labels.Set) func(v1.EndpointAddress) bool {
+	sel := labels.SelectorFromSet(selector)
+
+	return func(a v1.EndpointAddress) bool {
+		if a.TargetRef == nil || a.TargetRef.Kind != "Pod" {
+			return false
+		}
+
+		podLabels, ok := pods[types.NamespacedName{Namespace: a.TargetRef.Namespace, Name: a.TargetRef.Name}]
+		return ok && sel.Matches(podLabels)
+	}
+}
+
+// serviceImportNameOf returns the name of the ServiceImport that
+// the given EndpointSlice was imported for. The name is empty if the
+// EndpointSlice does not belong to a ServiceImport.
+func serviceImportNameOf(es *discovery_v1beta1.EndpointSlice) types.NamespacedName {
+	name, ok := es.GetLabels()[mcs_v1alpha1.LabelServiceName]
+	if !ok {
+		return types.NamespacedName{}
+	}
+
+	return types.NamespacedName{Namespace: es.GetNamespace(), Name: name}
+}
+
 // EndpointsCache is a cache of Endpoint and ServiceCluster objects.
 type EndpointsCache struct {
 	mu sync.Mutex // Protects all fields.
@@ -95,6 +255,29 @@ type EndpointsCache struct {
 
 	// Cache of endpoints, indexed by name.
 	endpoints map[types.NamespacedName]*v1.Endpoints
+
+	// Index of ServiceClusters, indexed by the name of their
+	// multi-cluster ServiceImports.
+	serviceImports map[types.NamespacedName][]*dag.ServiceCluster
+
+	// Cache of imported EndpointSlices, indexed by the name of
+	// their ServiceImport and then by their own name.
+	endpointSlices map[types.NamespacedName]map[string]*discovery_v1beta1.EndpointSlice
+
+	// Index of ServiceClusters that select the endpoints of
+	// headless Services by pod labels, indexed by namespace.
+	podSelectors map[string][]*dag.ServiceCluster
+
+	// Cache of pod labels, indexed by pod name.
+	pods map[types.NamespacedName]labels.Set
+
+	// Cache of node localities, indexed by node name.
+	nodes map[string]*envoy_core_v3.Locality
+
+	// overprovisioningFactor is the overprovisioning factor, as a
+	// percentage, of each ClusterLoadAssignment. If zero, the Envoy
+	// default applies.
+	overprovisioningFactor uint32
 }
 
 // Recalculate regenerates all the ClusterLoadAssignments from the
@@ -120,21 +303,45 @@ func (c *EndpointsCache) Recalculate() map[string]*envoy_endpoint_v3.ClusterLoad
 			Policy:      nil,
 		}
 
+		if c.overprovisioningFactor > 0 {
+			cla.Policy = &envoy_endpoint_v3.ClusterLoadAssignment_Policy{
+				OverprovisioningFactor: protobuf.UInt32(c.overprovisioningFactor),
+			}
+		}
+
 		// Look up each service, and if we have endpoints for that service,
 		// attach them as a new LocalityEndpoints resource2.
 		for _, w := range cluster.Services {
 			n := types.NamespacedName{Namespace: w.ServiceNamespace, Name: w.ServiceName}
-			if lb := RecalculateEndpoints(w.ServicePort, c.endpoints[n]); lb != nil {
-				// Append the new set of endpoints. Users are allowed to set the load
-				// balancing weight to 0, which we reflect to Envoy as nil in order to
-				// assign no load to that locality.
-				cla.Endpoints = append(
-					cla.Endpoints,
-					&LocalityEndpoints{
-						LbEndpoints:         lb,
-						LoadBalancingWeight: protobuf.UInt32OrNil(w.Weight),
-					},
-				)
+
+			var localities []*LocalityEndpoints
+			if w.ServiceImport {
+				if lb := RecalculateEndpointSlices(w.ServicePort, c.endpointSlices[n]); lb != nil {
+					localities = []*LocalityEndpoints{{LbEndpoints: lb}}
+				}
+			} else {
+				var include func(v1.EndpointAddress) bool
+				if len(w.PodSelector) > 0 {
+					include = podSelectorOf(w.PodSelector, c.pods)
+				}
+				localities = recalculateEndpoints(w.ServicePort, c.endpoints[n], include, c.nodes)
+			}
+
+			// Append the new set of endpoints. Users are allowed to set the load
+			// balancing weight to 0, which we reflect to Envoy as nil in order to
+			// assign no load to that locality. Failover services have a lower
+			// priority than the primary service.
+			for _, le := range localities {
+				le.LoadBalancingWeight = protobuf.UInt32OrNil(w.Weight)
+				le.Priority = w.Priority
+				if w.HealthCheckPort > 0 {
+					for _, lb := range le.LbEndpoints {
+						lb.GetEndpoint().HealthCheckConfig = &envoy_endpoint_v3.Endpoint_HealthCheckConfig{
+							PortValue: uint32(w.HealthCheckPort),
+						}
+					}
+				}
+				cla.Endpoints = append(cla.Endpoints, le)
 			}
 		}
 
@@ -154,6 +361,8 @@ func (c *EndpointsCache) SetClusters(clusters []*dag.ServiceCluster) error {
 	// Keep a local index to start with so that errors don't cause
 	// partial failure.
 	serviceIndex := map[types.NamespacedName][]*dag.ServiceCluster{}
+	importIndex := map[types.NamespacedName][]*dag.ServiceCluster{}
+	podSelectorIndex := map[string][]*dag.ServiceCluster{}
 
 	// Reindex the cluster so that we can find them by service name.
 	for _, cluster := range clusters {
@@ -170,18 +379,29 @@ func (c *EndpointsCache) SetClusters(clusters []*dag.ServiceCluster) error {
 				Name:      s.ServiceName,
 			}
 
+			index := serviceIndex
+			if s.ServiceImport {
+				index = importIndex
+			}
+
 			// Create the slice entry if we have not indexed this service yet.
-			entry := serviceIndex[name]
+			entry := index[name]
 			if entry == nil {
 				entry = []*dag.ServiceCluster{}
 			}
 
-			serviceIndex[name] = append(entry, cluster)
+			index[name] = append(entry, cluster)
+
+			if len(s.PodSelector) > 0 {
+				podSelectorIndex[name.Namespace] = append(podSelectorIndex[name.Namespace], cluster)
+			}
 		}
 	}
 
 	c.stale = clusters
 	c.services = serviceIndex
+	c.serviceImports = importIndex
+	c.podSelectors = podSelectorIndex
 
 	return nil
 }
@@ -227,6 +447,151 @@ func (c *EndpointsCache) DeleteEndpoint(ep *v1.Endpoints) bool {
 	return false
 }
 
+// UpdateEndpointSlice adds es to the cache, or replaces it if it is
+// already cached. Any ServiceClusters that are backed by the
+// ServiceImport that es belongs to become stale. Returns a boolean
+// indicating whether any ServiceClusters use es or not.
+func (c *EndpointsCache) UpdateEndpointSlice(es *discovery_v1beta1.EndpointSlice) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := serviceImportNameOf(es)
+	if name.Name == "" {
+		return false
+	}
+
+	slices := c.endpointSlices[name]
+	if slices == nil {
+		slices = map[string]*discovery_v1beta1.EndpointSlice{}
+		c.endpointSlices[name] = slices
+	}
+	slices[es.GetName()] = es.DeepCopy()
+
+	// If any service clusters include this slice, mark them
+	// all as stale.
+	if affected := c.serviceImports[name]; len(affected) > 0 {
+		c.stale = append(c.stale, affected...)
+		return true
+	}
+
+	return false
+}
+
+// DeleteEndpointSlice deletes es from the cache. Any ServiceClusters
+// that are backed by the ServiceImport that es belongs to become
+// stale. Returns a boolean indicating whether any ServiceClusters
+// use es or not.
+func (c *EndpointsCache) DeleteEndpointSlice(es *discovery_v1beta1.EndpointSlice) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := serviceImportNameOf(es)
+	if name.Name == "" {
+		return false
+	}
+
+	delete(c.endpointSlices[name], es.GetName())
+	if len(c.endpointSlices[name]) == 0 {
+		delete(c.endpointSlices, name)
+	}
+
+	// If any service clusters include this slice, mark them
+	// all as stale.
+	if affected := c.serviceImports[name]; len(affected) > 0 {
+		c.stale = append(c.stale, affected...)
+		return true
+	}
+
+	return false
+}
+
+// UpdatePod adds the labels of pod to the cache, or replaces them if
+// they are already cached. If the labels changed, any ServiceClusters
+// that select endpoints by pod labels in the namespace of pod become
+// stale. Returns a boolean indicating whether any ServiceClusters
+// became stale or not.
+func (c *EndpointsCache) UpdatePod(pod *v1.Pod) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := k8s.NamespacedNameOf(pod)
+	podLabels := labels.Set(pod.GetLabels())
+
+	// Pods are updated frequently, but only label
+	// changes affect the selected endpoints.
+	if cached, ok := c.pods[name]; ok && labels.Equals(cached, podLabels) {
+		return false
+	}
+	c.pods[name] = podLabels
+
+	if affected := c.podSelectors[name.Namespace]; len(affected) > 0 {
+		c.stale = append(c.stale, affected...)
+		return true
+	}
+
+	return false
+}
+
+// DeletePod deletes the labels of pod from the cache. Any
+// ServiceClusters that select endpoints by pod labels in the
+// namespace of pod become stale. Returns a boolean indicating
+// whether any ServiceClusters became stale or not.
+func (c *EndpointsCache) DeletePod(pod *v1.Pod) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := k8s.NamespacedNameOf(pod)
+	delete(c.pods, name)
+
+	if affected := c.podSelectors[name.Namespace]; len(affected) > 0 {
+		c.stale = append(c.stale, affected...)
+		return true
+	}
+
+	return false
+}
+
+// UpdateNode adds the locality of node to the cache, or replaces it
+// if it is already cached. If the locality changed, all the
+// ServiceClusters backed by Services become stale. Returns a boolean
+// indicating whether any ServiceClusters became stale or not.
+func (c *EndpointsCache) UpdateNode(node *v1.Node) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Nodes are updated frequently, but only changes
+	// to their topology labels affect endpoints.
+	locality := nodeLocality(node)
+	if cached, ok := c.nodes[node.Name]; ok && proto.Equal(cached, locality) {
+		return false
+	}
+	c.nodes[node.Name] = locality
+
+	return c.markServicesStale()
+}
+
+// DeleteNode deletes the locality of node from the cache. All the
+// ServiceClusters backed by Services become stale. Returns a boolean
+// indicating whether any ServiceClusters became stale or not.
+func (c *EndpointsCache) DeleteNode(node *v1.Node) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.nodes, node.Name)
+
+	return c.markServicesStale()
+}
+
+// markServicesStale marks all the ServiceClusters backed by Services
+// as stale. The caller must hold c.mu.
+func (c *EndpointsCache) markServicesStale() bool {
+	for _, affected := range c.services {
+		c.stale = append(c.stale, affected...)
+	}
+
+	return len(c.services) > 0
+}
+
 // NewEndpointsTranslator allocates a new endpoints translator.
 func NewEndpointsTranslator(log logrus.FieldLogger) *EndpointsTranslator {
 	return &EndpointsTranslator{
@@ -237,10 +602,28 @@ func NewEndpointsTranslator(log logrus.FieldLogger) *EndpointsTranslator {
 			stale:     nil,
 			services:  map[types.NamespacedName][]*dag.ServiceCluster{},
 			endpoints: map[types.NamespacedName]*v1.Endpoints{},
+
+			serviceImports: map[types.NamespacedName][]*dag.ServiceCluster{},
+			endpointSlices: map[types.NamespacedName]map[string]*discovery_v1beta1.EndpointSlice{},
+
+			podSelectors: map[string][]*dag.ServiceCluster{},
+			pods:         map[types.NamespacedName]labels.Set{},
+
+			nodes: map[string]*envoy_core_v3.Locality{},
 		},
 	}
 }
 
+// SetOverprovisioningFactor sets the overprovisioning factor, as a
+// percentage, of the generated ClusterLoadAssignments. It must be
+// called before the EndpointsTranslator receives any updates.
+func (e *EndpointsTranslator) SetOverprovisioningFactor(factor uint32) {
+	e.cache.mu.Lock()
+	defer e.cache.mu.Unlock()
+
+	e.cache.overprovisioningFactor = factor
+}
+
 // A EndpointsTranslator translates Kubernetes Endpoints objects into Envoy
 // ClusterLoadAssignment resources.
 type EndpointsTranslator struct {
@@ -356,6 +739,39 @@ func (e *EndpointsTranslator) OnAdd(obj interface{}) {
 		if e.Observer != nil {
 			e.Observer.Refresh()
 		}
+	case *discovery_v1beta1.EndpointSlice:
+		if !e.cache.UpdateEndpointSlice(obj) {
+			return
+		}
+
+		e.WithField("endpointslice", k8s.NamespacedNameOf(obj)).Debug("EndpointSlice is in use by a ServiceCluster, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
+	case *v1.Pod:
+		if !e.cache.UpdatePod(obj) {
+			return
+		}
+
+		e.WithField("pod", k8s.NamespacedNameOf(obj)).Debug("Pod labels may be selected by a ServiceCluster, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
+	case *v1.Node:
+		if !e.cache.UpdateNode(obj) {
+			return
+		}
+
+		e.WithField("node", obj.Name).Debug("Node locality changed, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
 	default:
 		e.Errorf("OnAdd unexpected type %T: %#v", obj, obj)
 	}
@@ -393,6 +809,55 @@ func (e *EndpointsTranslator) OnUpdate(oldObj, newObj interface{}) {
 		if e.Observer != nil {
 			e.Observer.Refresh()
 		}
+	case *discovery_v1beta1.EndpointSlice:
+		oldObj, ok := oldObj.(*discovery_v1beta1.EndpointSlice)
+		if !ok {
+			e.Errorf("OnUpdate endpointslice %#v received invalid oldObj %T; %#v", newObj, oldObj, oldObj)
+			return
+		}
+
+		if oldObj == newObj {
+			return
+		}
+
+		// A slice that moved to a different ServiceImport
+		// must also be removed from the old one.
+		if serviceImportNameOf(oldObj) != serviceImportNameOf(newObj) {
+			e.cache.DeleteEndpointSlice(oldObj)
+		}
+
+		if !e.cache.UpdateEndpointSlice(newObj) {
+			return
+		}
+
+		e.WithField("endpointslice", k8s.NamespacedNameOf(newObj)).Debug("EndpointSlice is in use by a ServiceCluster, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
+	case *v1.Pod:
+		if !e.cache.UpdatePod(newObj) {
+			return
+		}
+
+		e.WithField("pod", k8s.NamespacedNameOf(newObj)).Debug("Pod labels may be selected by a ServiceCluster, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
+	case *v1.Node:
+		if !e.cache.UpdateNode(newObj) {
+			return
+		}
+
+		e.WithField("node", newObj.Name).Debug("Node locality changed, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
 	default:
 		e.Errorf("OnUpdate unexpected type %T: %#v", newObj, newObj)
 	}
@@ -411,6 +876,39 @@ func (e *EndpointsTranslator) OnDelete(obj interface{}) {
 		if e.Observer != nil {
 			e.Observer.Refresh()
 		}
+	case *discovery_v1beta1.EndpointSlice:
+		if !e.cache.DeleteEndpointSlice(obj) {
+			return
+		}
+
+		e.WithField("endpointslice", k8s.NamespacedNameOf(obj)).Debug("EndpointSlice was in use by a ServiceCluster, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
+	case *v1.Pod:
+		if !e.cache.DeletePod(obj) {
+			return
+		}
+
+		e.WithField("pod", k8s.NamespacedNameOf(obj)).Debug("Pod labels may have been selected by a ServiceCluster, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
+	case *v1.Node:
+		if !e.cache.DeleteNode(obj) {
+			return
+		}
+
+		e.WithField("node", obj.Name).Debug("Node was deleted, recalculating ClusterLoadAssignments")
+		e.Merge(e.cache.Recalculate())
+		e.Notify()
+		if e.Observer != nil {
+			e.Observer.Refresh()
+		}
 	case cache.DeletedFinalStateUnknown:
 		e.OnDelete(obj.Obj) // recurse into ourselves with the tombstoned value
 	default:
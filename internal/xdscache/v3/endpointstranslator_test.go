@@ -16,15 +16,20 @@ package v3
 import (
 	"testing"
 
+	envoy_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/golang/protobuf/proto"
 	"github.com/projectcontour/contour/internal/dag"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/fixture"
+	mcs_v1alpha1 "github.com/projectcontour/contour/internal/mcs/v1alpha1"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	discovery_v1beta1 "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 )
 
 func TestEndpointsTranslatorContents(t *testing.T) {
@@ -862,6 +867,364 @@ func TestEndpointsTranslatorDefaultWeightedService(t *testing.T) {
 	protobuf.ExpectEqual(t, want, et.Contents())
 }
 
+// Test that a cluster backed by a ServiceImport takes its endpoints
+// from the imported EndpointSlices rather than from the Endpoints of
+// a Service with the same name.
+func TestEndpointsTranslatorServiceImport(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t))
+	clusters := []*dag.ServiceCluster{
+		{
+			ClusterName: "serviceimport/default/kuard/http",
+			Services: []dag.WeightedService{
+				{
+					Weight:           1,
+					ServiceName:      "kuard",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http"},
+					ServiceImport:    true,
+				},
+			},
+		},
+	}
+
+	require.NoError(t, et.cache.SetClusters(clusters))
+
+	et.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.24"),
+		Ports:     ports(port("http", 8080)),
+	}))
+	et.OnAdd(endpointSlice("default", "kuard-cluster-b", "kuard", "10.1.0.1"))
+	et.OnAdd(endpointSlice("default", "kuard-cluster-a", "kuard", "10.0.0.2"))
+	et.OnAdd(endpointSlice("default", "unrelated", "", "10.2.0.1"))
+
+	notReady := endpointSlice("default", "kuard-cluster-c", "kuard", "10.3.0.1")
+	notReady.Endpoints[0].Conditions.Ready = pointer.BoolPtr(false)
+	et.OnAdd(notReady)
+
+	want := []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "serviceimport/default/kuard/http",
+			Endpoints: envoy_v3.WeightedEndpoints(1,
+				envoy_v3.SocketAddress("10.0.0.2", 8080),
+				envoy_v3.SocketAddress("10.1.0.1", 8080),
+			),
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+
+	et.OnDelete(endpointSlice("default", "kuard-cluster-a", "kuard", "10.0.0.2"))
+
+	want = []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "serviceimport/default/kuard/http",
+			Endpoints: envoy_v3.WeightedEndpoints(1,
+				envoy_v3.SocketAddress("10.1.0.1", 8080),
+			),
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+}
+
+func TestEndpointsTranslatorPodSelector(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t))
+	clusters := []*dag.ServiceCluster{
+		{
+			ClusterName: "default/kuard/http/shard=1",
+			Services: []dag.WeightedService{
+				{
+					Weight:           1,
+					ServiceName:      "kuard",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http"},
+					PodSelector:      map[string]string{"shard": "1"},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, et.cache.SetClusters(clusters))
+
+	et.OnAdd(pod("default", "kuard-0", map[string]string{"shard": "0"}))
+	et.OnAdd(pod("default", "kuard-1", map[string]string{"shard": "1"}))
+	et.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: []v1.EndpointAddress{
+			podAddress("192.168.183.20", "default", "kuard-0"),
+			podAddress("192.168.183.21", "default", "kuard-1"),
+			{IP: "192.168.183.22"},
+		},
+		Ports: ports(port("http", 8080)),
+	}))
+
+	want := []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "default/kuard/http/shard=1",
+			Endpoints: envoy_v3.WeightedEndpoints(1,
+				envoy_v3.SocketAddress("192.168.183.21", 8080),
+			),
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+
+	// Relabelling a pod moves its endpoint into the subset.
+	et.OnUpdate(
+		pod("default", "kuard-0", map[string]string{"shard": "0"}),
+		pod("default", "kuard-0", map[string]string{"shard": "1"}),
+	)
+
+	want = []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "default/kuard/http/shard=1",
+			Endpoints: envoy_v3.WeightedEndpoints(1,
+				envoy_v3.SocketAddress("192.168.183.20", 8080),
+				envoy_v3.SocketAddress("192.168.183.21", 8080),
+			),
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+
+	// Pods with unchanged labels do not affect any ServiceCluster.
+	assert.False(t, et.cache.UpdatePod(pod("default", "kuard-1", map[string]string{"shard": "1"})))
+
+	et.OnDelete(pod("default", "kuard-1", map[string]string{"shard": "1"}))
+
+	want = []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "default/kuard/http/shard=1",
+			Endpoints: envoy_v3.WeightedEndpoints(1,
+				envoy_v3.SocketAddress("192.168.183.20", 8080),
+			),
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+}
+
+func TestEndpointsTranslatorNodeLocality(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t))
+	et.SetOverprovisioningFactor(200)
+
+	clusters := []*dag.ServiceCluster{
+		{
+			ClusterName: "default/kuard/http",
+			Services: []dag.WeightedService{
+				{
+					Weight:           1,
+					ServiceName:      "kuard",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http"},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, et.cache.SetClusters(clusters))
+
+	et.OnAdd(node("node-a", map[string]string{
+		v1.LabelTopologyRegion: "us-east-1",
+		v1.LabelTopologyZone:   "us-east-1a",
+	}))
+	et.OnAdd(node("node-b", map[string]string{
+		v1.LabelFailureDomainBetaRegion: "us-east-1",
+		v1.LabelFailureDomainBetaZone:   "us-east-1b",
+	}))
+	et.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: []v1.EndpointAddress{
+			nodeAddress("192.168.183.20", "node-a"),
+			nodeAddress("192.168.183.21", "node-b"),
+			nodeAddress("192.168.183.22", "node-a"),
+			{IP: "192.168.183.23"},
+		},
+		Ports: ports(port("http", 8080)),
+	}))
+
+	policy := &envoy_endpoint_v3.ClusterLoadAssignment_Policy{
+		OverprovisioningFactor: protobuf.UInt32(200),
+	}
+
+	want := []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "default/kuard/http",
+			Endpoints: []*envoy_endpoint_v3.LocalityLbEndpoints{
+				{
+					Locality: &envoy_core_v3.Locality{Region: "us-east-1", Zone: "us-east-1a"},
+					LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.20", 8080)),
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.22", 8080)),
+					},
+					LoadBalancingWeight: protobuf.UInt32(1),
+				},
+				{
+					Locality: &envoy_core_v3.Locality{Region: "us-east-1", Zone: "us-east-1b"},
+					LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.21", 8080)),
+					},
+					LoadBalancingWeight: protobuf.UInt32(1),
+				},
+				{
+					LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.23", 8080)),
+					},
+					LoadBalancingWeight: protobuf.UInt32(1),
+				},
+			},
+			Policy: policy,
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+
+	// Nodes with unchanged topology labels do not affect any ServiceCluster.
+	assert.False(t, et.cache.UpdateNode(node("node-a", map[string]string{
+		v1.LabelTopologyRegion: "us-east-1",
+		v1.LabelTopologyZone:   "us-east-1a",
+		"kubernetes.io/os":     "linux",
+	})))
+
+	// Moving a node into another zone moves its endpoints.
+	et.OnUpdate(
+		node("node-b", nil),
+		node("node-b", map[string]string{
+			v1.LabelTopologyRegion: "us-east-1",
+			v1.LabelTopologyZone:   "us-east-1a",
+		}),
+	)
+	et.OnDelete(node("node-a", nil))
+
+	want = []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "default/kuard/http",
+			Endpoints: []*envoy_endpoint_v3.LocalityLbEndpoints{
+				{
+					LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.20", 8080)),
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.22", 8080)),
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.23", 8080)),
+					},
+					LoadBalancingWeight: protobuf.UInt32(1),
+				},
+				{
+					Locality: &envoy_core_v3.Locality{Region: "us-east-1", Zone: "us-east-1a"},
+					LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.21", 8080)),
+					},
+					LoadBalancingWeight: protobuf.UInt32(1),
+				},
+			},
+			Policy: policy,
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+}
+
+func TestEndpointsTranslatorFailoverPriority(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t))
+	clusters := []*dag.ServiceCluster{
+		{
+			ClusterName: "default/kuard/http/failover=default/kuarder/http",
+			Services: []dag.WeightedService{
+				{
+					Weight:           1,
+					ServiceName:      "kuard",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http"},
+				},
+				{
+					Weight:           1,
+					ServiceName:      "kuarder",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http"},
+					Priority:         1,
+				},
+			},
+		},
+	}
+
+	require.NoError(t, et.cache.SetClusters(clusters))
+
+	et.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.20"),
+		Ports:     ports(port("http", 8080)),
+	}))
+	et.OnAdd(endpoints("default", "kuarder", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.21"),
+		Ports:     ports(port("http", 8080)),
+	}))
+
+	want := []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "default/kuard/http/failover=default/kuarder/http",
+			Endpoints: []*envoy_endpoint_v3.LocalityLbEndpoints{
+				{
+					LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.20", 8080)),
+					},
+					LoadBalancingWeight: protobuf.UInt32(1),
+				},
+				{
+					LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{
+						envoy_v3.LBEndpoint(envoy_v3.SocketAddress("192.168.183.21", 8080)),
+					},
+					LoadBalancingWeight: protobuf.UInt32(1),
+					Priority:            1,
+				},
+			},
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+}
+
+func TestEndpointsTranslatorHealthCheckPort(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t))
+	clusters := []*dag.ServiceCluster{
+		{
+			ClusterName: "default/kuard/http/healthcheck=15020",
+			Services: []dag.WeightedService{
+				{
+					Weight:           1,
+					ServiceName:      "kuard",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http"},
+					HealthCheckPort:  15020,
+				},
+			},
+		},
+	}
+
+	require.NoError(t, et.cache.SetClusters(clusters))
+
+	et.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.20"),
+		Ports:     ports(port("http", 8080)),
+	}))
+
+	want := []proto.Message{
+		&envoy_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: "default/kuard/http/healthcheck=15020",
+			Endpoints: []*envoy_endpoint_v3.LocalityLbEndpoints{{
+				LbEndpoints: []*envoy_endpoint_v3.LbEndpoint{{
+					HostIdentifier: &envoy_endpoint_v3.LbEndpoint_Endpoint{
+						Endpoint: &envoy_endpoint_v3.Endpoint{
+							Address: envoy_v3.SocketAddress("192.168.183.20", 8080),
+							HealthCheckConfig: &envoy_endpoint_v3.Endpoint_HealthCheckConfig{
+								PortValue: 15020,
+							},
+						},
+					},
+				}},
+				LoadBalancingWeight: protobuf.UInt32(1),
+			}},
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, et.Contents())
+}
+
 func TestEqual(t *testing.T) {
 	tests := map[string]struct {
 		a, b map[string]*envoy_endpoint_v3.ClusterLoadAssignment
@@ -954,6 +1317,70 @@ func TestEqual(t *testing.T) {
 	}
 }
 
+func endpointSlice(ns, name, serviceImport string, ips ...string) *discovery_v1beta1.EndpointSlice {
+	es := &discovery_v1beta1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    map[string]string{},
+		},
+		AddressType: discovery_v1beta1.AddressTypeIPv4,
+		Ports: []discovery_v1beta1.EndpointPort{{
+			Name: pointer.StringPtr("http"),
+			Port: pointer.Int32Ptr(8080),
+		}},
+	}
+
+	if serviceImport != "" {
+		es.Labels[mcs_v1alpha1.LabelServiceName] = serviceImport
+	}
+
+	for _, ip := range ips {
+		es.Endpoints = append(es.Endpoints, discovery_v1beta1.Endpoint{
+			Addresses: []string{ip},
+		})
+	}
+
+	return es
+}
+
+func pod(ns, name string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    labels,
+		},
+	}
+}
+
+func node(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
+func nodeAddress(ip, node string) v1.EndpointAddress {
+	return v1.EndpointAddress{
+		IP:       ip,
+		NodeName: pointer.StringPtr(node),
+	}
+}
+
+func podAddress(ip, ns, name string) v1.EndpointAddress {
+	return v1.EndpointAddress{
+		IP: ip,
+		TargetRef: &v1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: ns,
+			Name:      name,
+		},
+	}
+}
+
 func ports(eps ...v1.EndpointPort) []v1.EndpointPort {
 	return eps
 }
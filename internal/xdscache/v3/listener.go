@@ -45,6 +45,9 @@ const (
 	DEFAULT_HTTPS_ACCESS_LOG       = "/dev/stdout"
 	DEFAULT_HTTPS_LISTENER_ADDRESS = DEFAULT_HTTP_LISTENER_ADDRESS
 	DEFAULT_HTTPS_LISTENER_PORT    = 8443
+	ENVOY_MIRROR_LISTENER          = "ingress_mirror"
+	ENVOY_MIRROR_LISTENER_ADDRESS  = "127.0.0.1"
+	DEFAULT_MIRROR_LISTENER_PORT   = 8009
 )
 
 type Listener struct {
@@ -74,6 +77,16 @@ type ListenerConfig struct {
 	// If not set, defaults to DEFAULT_HTTPS_ACCESS_LOG.
 	HTTPSAccessLog string
 
+	// Envoy's additional plain TCP listener addresses, keyed by
+	// listener name. A listener is only added when an HTTPProxy
+	// selects its port.
+	TCPListeners map[string]Listener
+
+	// MirrorPort is the loopback port of the listener through which
+	// Envoy sets the request headers of mirrored requests.
+	// If not set, defaults to DEFAULT_MIRROR_LISTENER_PORT.
+	MirrorPort int
+
 	// UseProxyProto configures all listeners to expect a PROXY
 	// V1 or V2 preamble.
 	// If not set, defaults to false.
@@ -110,6 +123,14 @@ type ListenerConfig struct {
 	// AccessLogFormatterExtensions defines the Envoy extensions to enable for access log.
 	AccessLogFormatterExtensions []string
 
+	// TCPAccessLogFields sets the fields that should be shown in JSON
+	// logs of TCP proxies. Defaults to AccessLogFields.
+	TCPAccessLogFields config.AccessLogFields
+
+	// TCPAccessLogFormatString sets the format string to be used for
+	// text based access logs of TCP proxies. Defaults to AccessLogFormatString.
+	TCPAccessLogFormatString string
+
 	// RequestTimeout configures the request_timeout for all Connection Managers.
 	RequestTimeout timeout.Setting
 
@@ -138,14 +159,101 @@ type ListenerConfig struct {
 	// right side of the x-forwarded-for HTTP header to trust.
 	XffNumTrustedHops uint32
 
+	// SkipXffAppend disables appending the client address to the
+	// x-forwarded-for HTTP header.
+	SkipXffAppend bool
+
+	// ReplaceExternalRequestID replaces the x-request-id header of
+	// requests from external clients with a generated ID.
+	ReplaceExternalRequestID bool
+
+	// AcceptHTTP10 enables support for HTTP/1.0 requests on all
+	// Connection Managers.
+	AcceptHTTP10 bool
+
+	// DefaultHostForHTTP10 sets the Host header used for HTTP/1.0
+	// requests that do not supply one.
+	DefaultHostForHTTP10 string
+
 	// ConnectionBalancer
 	// The validated value is 'exact'.
 	// If no configuration is specified, Envoy will not attempt to balance active connections between worker threads
 	// If specified, the listener will use the exact connection balancer.
 	ConnectionBalancer string
+
+	// ReusePort sets SO_REUSEPORT on the listener sockets.
+	ReusePort bool
+
+	// TCPFastOpenQueueLength enables TCP fast open on the listeners
+	// with a queue of this length. If zero, TCP fast open is disabled.
+	TCPFastOpenQueueLength uint32
+
+	// Backlog is the length of the queue of pending connections of
+	// the listener sockets. If zero, Envoy's default is used.
+	Backlog uint32
+
+	// ListenerOverrides overrides the connection balancer and socket
+	// options of individual listeners, keyed by listener name.
+	ListenerOverrides map[string]ListenerOverrides
 	// RateLimitConfig optionally configures the global Rate Limit Service to be
 	// used.
 	RateLimitConfig *RateLimitConfig
+
+	// TracingConfig optionally configures the tracing of requests
+	// through the HTTP connection managers.
+	TracingConfig *envoy_v3.TracingConfig
+
+	// XDSDelta configures all Connection Managers to request their
+	// routing tables with the delta xDS protocol.
+	XDSDelta bool
+
+	// VHDS configures the HTTP Connection Managers to fetch the
+	// virtual host of each request on demand.
+	VHDS bool
+
+	// GeoIP configures the HTTP Connection Managers to have Contour
+	// set the GeoIP headers of each request.
+	GeoIP bool
+}
+
+// mirrorListenerPort returns port, or DEFAULT_MIRROR_LISTENER_PORT
+// if port is zero.
+func mirrorListenerPort(port int) int {
+	if port == 0 {
+		return DEFAULT_MIRROR_LISTENER_PORT
+	}
+	return port
+}
+
+// onDemandFilter returns the filter that fetches virtual hosts on
+// demand when VHDS is enabled, otherwise nil.
+func (lvc *ListenerConfig) onDemandFilter() *http.HttpFilter {
+	if !lvc.VHDS {
+		return nil
+	}
+	return envoy_v3.FilterOnDemand()
+}
+
+// geoIPFilters returns the filters that set the GeoIP headers of
+// requests when GeoIP is enabled, otherwise nil.
+func (lvc *ListenerConfig) geoIPFilters() []*http.HttpFilter {
+	if !lvc.GeoIP {
+		return nil
+	}
+	return []*http.HttpFilter{
+		envoy_v3.FilterGeoIPClear(),
+		envoy_v3.FilterGeoIP(),
+	}
+}
+
+// ListenerOverrides overrides the connection balancer and socket options
+// of a listener. Unset fields use the values of the ListenerConfig.
+type ListenerOverrides struct {
+	// ConnectionBalancer is 'exact' or 'none'.
+	ConnectionBalancer     string
+	ReusePort              *bool
+	TCPFastOpenQueueLength *uint32
+	Backlog                *uint32
 }
 
 type RateLimitConfig struct {
@@ -267,6 +375,29 @@ func (lvc *ListenerConfig) newSecureAccessLog() []*envoy_accesslog_v3.AccessLog
 	}
 }
 
+// newTCPAccessLog returns the access log for the supplied TCP proxy,
+// written to path, or nil if the proxy has access logging disabled.
+func (lvc *ListenerConfig) newTCPAccessLog(path string, proxy *dag.TCPProxy) []*envoy_accesslog_v3.AccessLog {
+	if proxy.DisableAccessLog {
+		return nil
+	}
+
+	switch lvc.accesslogType() {
+	case string(config.JSONAccessLog):
+		fields := lvc.TCPAccessLogFields
+		if fields == nil {
+			fields = lvc.accesslogFields()
+		}
+		return envoy_v3.FileAccessLogJSON(path, fields, lvc.AccessLogFormatterExtensions)
+	default:
+		format := lvc.TCPAccessLogFormatString
+		if format == "" {
+			format = lvc.AccessLogFormatString
+		}
+		return envoy_v3.FileAccessLogEnvoy(path, format, lvc.AccessLogFormatterExtensions)
+	}
+}
+
 // minTLSVersion returns the requested minimum TLS protocol
 // version or envoy_tls_v3.TlsParameters_TLSv1_2 if not configured.
 func (lvc *ListenerConfig) minTLSVersion() envoy_tls_v3.TlsParameters_TlsProtocol {
@@ -357,27 +488,67 @@ func (c *ListenerCache) OnChange(root *dag.DAG) {
 type listenerVisitor struct {
 	*ListenerConfig
 
-	listeners        map[string]*envoy_listener_v3.Listener
-	httpListenerName string // Name of dag.VirtualHost encountered.
+	listeners         map[string]*envoy_listener_v3.Listener
+	httpListenerNames map[string]bool // Listener names of dag.VirtualHosts encountered.
+
+	// httpDynamicForwardProxy is set if any dag.VirtualHost
+	// has a route using the dynamic forward proxy.
+	httpDynamicForwardProxy *dag.DynamicForwardProxyCluster
+
+	// accessLogFilter is set if any dag.Route samples
+	// its access logs.
+	accessLogFilter *envoy_accesslog_v3.AccessLogFilter
+
+	// bufferLimit is the largest request body limit of the
+	// buffer policies of the dag.Routes, or zero if none
+	// buffers requests.
+	bufferLimit uint32
+
+	// routeFilters holds the Lua filters that are configured
+	// by the metadata of the dag.Routes that use them.
+	routeFilters []*http.HttpFilter
 }
 
 func visitListeners(root dag.Vertex, lvc *ListenerConfig) map[string]*envoy_listener_v3.Listener {
 	lv := listenerVisitor{
-		ListenerConfig: lvc.DefaultListeners(),
-		listeners:      lvc.SecureListeners(),
+		ListenerConfig:    lvc.DefaultListeners(),
+		listeners:         lvc.SecureListeners(),
+		httpListenerNames: map[string]bool{},
+	}
+
+	if percentages, ok := accessLogSamplingOf(root); ok {
+		lv.accessLogFilter = envoy_v3.AccessLogSamplingFilter(percentages)
 	}
+	lv.bufferLimit = bufferLimitOf(root)
+	lv.routeFilters = routeFiltersOf(root)
 
 	lv.visit(root)
 
-	if httpListener, ok := lvc.HTTPListeners[lv.httpListenerName]; ok {
+	for name := range lv.httpListenerNames {
+		httpListener, ok := lvc.HTTPListeners[name]
+		if !ok {
+			// the listener is not configured.
+			continue
+		}
 
 		// Add a listener if there are vhosts bound to http.
-		cm := envoy_v3.HTTPConnectionManagerBuilder().
+		cmb := envoy_v3.HTTPConnectionManagerBuilder().
 			Codec(envoy_v3.CodecForVersions(lv.DefaultHTTPVersions...)).
-			DefaultFilters().
+			DefaultFilters()
+		for _, f := range lvc.geoIPFilters() {
+			cmb.AddFilter(f)
+		}
+		for _, f := range lv.routeFilters {
+			cmb.AddFilter(f)
+		}
+
+		cm := cmb.
 			RouteConfigName(httpListener.Name).
+			DeltaRDS(lvc.XDSDelta).
+			AddFilter(lvc.onDemandFilter()).
+			AddFilter(envoy_v3.FilterBuffer(lv.bufferLimit)).
 			MetricsPrefix(httpListener.Name).
-			AccessLoggers(lvc.newInsecureAccessLog()).
+			AccessLoggers(envoy_v3.FilterAccessLogs(lvc.newInsecureAccessLog(), lv.accessLogFilter)).
 			RequestTimeout(lvc.RequestTimeout).
 			ConnectionIdleTimeout(lvc.ConnectionIdleTimeout).
 			StreamIdleTimeout(lvc.StreamIdleTimeout).
@@ -385,8 +556,13 @@ func visitListeners(root dag.Vertex, lvc *ListenerConfig) map[string]*envoy_list
 			MaxConnectionDuration(lvc.MaxConnectionDuration).
 			ConnectionShutdownGracePeriod(lvc.ConnectionShutdownGracePeriod).
 			AllowChunkedLength(lvc.AllowChunkedLength).
+			AcceptHTTP10(lvc.AcceptHTTP10, lvc.DefaultHostForHTTP10).
 			NumTrustedHops(lvc.XffNumTrustedHops).
+			SkipXffAppend(lvc.SkipXffAppend).
+			ReplaceExternalRequestID(lvc.ReplaceExternalRequestID).
 			AddFilter(envoy_v3.GlobalRateLimitFilter(envoyGlobalRateLimitConfig(lv.RateLimitConfig))).
+			AddFilter(envoy_v3.FilterDynamicForwardProxy(lv.httpDynamicForwardProxy)).
+			Tracing(envoy_v3.Tracing(lv.TracingConfig)).
 			Get()
 
 		lv.listeners[httpListener.Name] = envoy_v3.Listener(
@@ -398,27 +574,74 @@ func visitListeners(root dag.Vertex, lvc *ListenerConfig) map[string]*envoy_list
 		)
 	}
 
-	// Remove the https listener if there are no vhosts bound to it.
-	if len(lv.listeners[ENVOY_HTTPS_LISTENER].FilterChains) == 0 {
-		delete(lv.listeners, ENVOY_HTTPS_LISTENER)
-	} else {
-		// there's some https listeners, we need to sort the filter chains
-		// to ensure that the LDS entries are identical.
-		sort.Stable(sorter.For(lv.listeners[ENVOY_HTTPS_LISTENER].FilterChains))
+	// Requests that are mirrored through the mirror listener
+	// come from Envoy itself, so it only listens on loopback.
+	if mirrorsWithHeadersOf(root) {
+		cm := envoy_v3.HTTPConnectionManagerBuilder().
+			DefaultFilters().
+			RouteConfigName(ENVOY_MIRROR_LISTENER).
+			DeltaRDS(lvc.XDSDelta).
+			MetricsPrefix(ENVOY_MIRROR_LISTENER).
+			SkipXffAppend(true).
+			Get()
+
+		lv.listeners[ENVOY_MIRROR_LISTENER] = envoy_v3.Listener(
+			ENVOY_MIRROR_LISTENER,
+			ENVOY_MIRROR_LISTENER_ADDRESS,
+			mirrorListenerPort(lvc.MirrorPort),
+			nil,
+			cm,
+		)
+	}
+
+	// Remove the https listeners if there are no vhosts bound to them.
+	for name := range lvc.HTTPSListeners {
+		if len(lv.listeners[name].FilterChains) == 0 {
+			delete(lv.listeners, name)
+		} else {
+			// there's some https listeners, we need to sort the filter chains
+			// to ensure that the LDS entries are identical.
+			sort.Stable(sorter.For(lv.listeners[name].FilterChains))
+		}
 	}
 
 	// support more params of envoy listener
 
-	// 1. connection balancer
-	switch lvc.ConnectionBalancer {
-	case "exact":
-		for _, listener := range lv.listeners {
+	for name, listener := range lv.listeners {
+		balancer := lvc.ConnectionBalancer
+		reusePort := lvc.ReusePort
+		fastOpen := lvc.TCPFastOpenQueueLength
+		backlog := lvc.Backlog
+
+		if o, ok := lvc.ListenerOverrides[name]; ok {
+			if o.ConnectionBalancer != "" {
+				balancer = o.ConnectionBalancer
+			}
+			if o.ReusePort != nil {
+				reusePort = *o.ReusePort
+			}
+			if o.TCPFastOpenQueueLength != nil {
+				fastOpen = *o.TCPFastOpenQueueLength
+			}
+			if o.Backlog != nil {
+				backlog = *o.Backlog
+			}
+		}
+
+		// 1. connection balancer
+		switch balancer {
+		case "exact":
 			listener.ConnectionBalanceConfig = &envoy_listener_v3.Listener_ConnectionBalanceConfig{
 				BalanceType: &envoy_listener_v3.Listener_ConnectionBalanceConfig_ExactBalance_{
 					ExactBalance: &envoy_listener_v3.Listener_ConnectionBalanceConfig_ExactBalance{},
 				},
 			}
 		}
+
+		// 2. socket options
+		listener.ReusePort = reusePort
+		listener.TcpFastOpenQueueLength = protobuf.UInt32OrNil(fastOpen)
+		listener.TcpBacklogSize = protobuf.UInt32OrNil(backlog)
 	}
 
 	return lv.listeners
@@ -451,6 +674,142 @@ func secureProxyProtocol(useProxy bool) []*envoy_listener_v3.ListenerFilter {
 	return append(proxyProtocol(useProxy), envoy_v3.TLSInspector())
 }
 
+// bufferLimitOf returns the largest request body limit of the buffer
+// policies of the routes beneath vertex, or zero if none buffers
+// requests.
+func bufferLimitOf(vertex dag.Vertex) uint32 {
+	var limit uint32
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok && r.BufferPolicy != nil && r.BufferPolicy.MaxRequestBytes > limit {
+			limit = r.BufferPolicy.MaxRequestBytes
+		}
+		v.Visit(visit)
+	}
+	visit(vertex)
+
+	return limit
+}
+
+// routeFiltersOf returns the cookie rewrite and header remove filters
+// that the routes beneath vertex use.
+func routeFiltersOf(vertex dag.Vertex) []*http.HttpFilter {
+	var cookieRewrite, headerRemove bool
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok {
+			cookieRewrite = cookieRewrite || len(r.CookieRewritePolicies) > 0
+			headerRemove = headerRemove || envoy_v3.HeaderRemoveMetadata(r) != nil
+		}
+		v.Visit(visit)
+	}
+	visit(vertex)
+
+	var filters []*http.HttpFilter
+	if cookieRewrite {
+		filters = append(filters, envoy_v3.FilterCookieRewrite())
+	}
+	if headerRemove {
+		filters = append(filters, envoy_v3.FilterHeaderRemove())
+	}
+	return filters
+}
+
+// dynamicForwardProxyOf returns the dynamic forward proxy cluster used
+// by any route beneath vertex, or nil if there is none.
+func dynamicForwardProxyOf(vertex dag.Vertex) *dag.DynamicForwardProxyCluster {
+	var dfp *dag.DynamicForwardProxyCluster
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if c, ok := v.(*dag.DynamicForwardProxyCluster); ok {
+			dfp = c
+			return
+		}
+		v.Visit(visit)
+	}
+	visit(vertex)
+
+	return dfp
+}
+
+// accessLogSamplingOf returns the distinct access log sampling
+// percentages, other than zero, of the routes beneath vertex. The
+// boolean is false if no route samples its access logs.
+func accessLogSamplingOf(vertex dag.Vertex) ([]uint32, bool) {
+	seen := map[uint32]bool{}
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok && r.AccessLogSampling != nil {
+			found = true
+			if *r.AccessLogSampling > 0 {
+				seen[*r.AccessLogSampling] = true
+			}
+		}
+		v.Visit(visit)
+	}
+	visit(vertex)
+
+	var percentages []uint32
+	for p := range seen {
+		percentages = append(percentages, p)
+	}
+	sort.Slice(percentages, func(i, j int) bool { return percentages[i] < percentages[j] })
+
+	return percentages, found
+}
+
+// tapPoliciesOf returns the distinct tap policies of the routes
+// beneath vertex, ordered by ID.
+func tapPoliciesOf(vertex dag.Vertex) []*dag.TapPolicy {
+	seen := map[string]*dag.TapPolicy{}
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok && r.TapPolicy != nil {
+			seen[r.TapPolicy.ID] = r.TapPolicy
+		}
+		v.Visit(visit)
+	}
+	visit(vertex)
+
+	var taps []*dag.TapPolicy
+	for _, t := range seen {
+		taps = append(taps, t)
+	}
+	sort.Slice(taps, func(i, j int) bool { return taps[i].ID < taps[j].ID })
+
+	return taps
+}
+
+// mirrorsWithHeaders returns true if r changes the headers of the
+// requests it mirrors, which Envoy routes through its mirror listener.
+func mirrorsWithHeaders(r *dag.Route) bool {
+	return r.MirrorPolicy != nil && r.MirrorPolicy.RequestHeadersPolicy != nil
+}
+
+// mirrorsWithHeadersOf returns true if any route beneath vertex
+// changes the headers of the requests it mirrors.
+func mirrorsWithHeadersOf(vertex dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok && mirrorsWithHeaders(r) {
+			found = true
+			return
+		}
+		v.Visit(visit)
+	}
+	visit(vertex)
+
+	return found
+}
+
 func (v *listenerVisitor) visit(vertex dag.Vertex) {
 	max := func(a, b envoy_tls_v3.TlsParameters_TlsProtocol) envoy_tls_v3.TlsParameters_TlsProtocol {
 		if a > b {
@@ -461,11 +820,35 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 
 	switch vh := vertex.(type) {
 	case *dag.VirtualHost:
-		// we only create on http listener so record the fact
-		// that we need to then double back at the end and add
-		// the listener properly
-		v.httpListenerName = vh.ListenerName
+		// we only create one listener for each http listener
+		// name so record the fact that we need to then double
+		// back at the end and add the listeners properly
+		v.httpListenerNames[vh.ListenerName] = true
+		if dfp := dynamicForwardProxyOf(vh); dfp != nil {
+			v.httpDynamicForwardProxy = dfp
+		}
+	case *dag.TCPVirtualHost:
+		l, ok := v.TCPListeners[vh.ListenerName]
+		if !ok {
+			// the listener is not configured.
+			return
+		}
+
+		v.listeners[l.Name] = envoy_v3.Listener(
+			l.Name,
+			l.Address,
+			l.Port,
+			proxyProtocol(v.UseProxyProto),
+			envoy_v3.TCPProxy(l.Name,
+				vh.TCPProxy,
+				v.ListenerConfig.newTCPAccessLog(v.ListenerConfig.httpAccessLog(), vh.TCPProxy)),
+		)
 	case *dag.SecureVirtualHost:
+		if _, ok := v.listeners[vh.ListenerName]; !ok {
+			// the listener is not configured.
+			return
+		}
+
 		var alpnProtos []string
 		var filters []*envoy_listener_v3.Filter
 
@@ -480,6 +863,17 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 				)
 			}
 
+			// The virtual host may override the listener's
+			// X-Forwarded-For handling.
+			numTrustedHops := v.ListenerConfig.XffNumTrustedHops
+			if vh.XffNumTrustedHops != nil {
+				numTrustedHops = *vh.XffNumTrustedHops
+			}
+			replaceExternalRequestID := v.ListenerConfig.ReplaceExternalRequestID
+			if vh.ReplaceExternalRequestID != nil {
+				replaceExternalRequestID = *vh.ReplaceExternalRequestID
+			}
+
 			// Create a uniquely named HTTP connection manager for
 			// this vhost, so that the SNI name the client requests
 			// only grants access to that host. See RFC 6066 for
@@ -487,14 +881,43 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 			// metrics prefix to keep compatibility with previous
 			// Contour versions since the metrics prefix will be
 			// coded into monitoring dashboards.
-			cm := envoy_v3.HTTPConnectionManagerBuilder().
+			cmb := envoy_v3.HTTPConnectionManagerBuilder().
 				Codec(envoy_v3.CodecForVersions(v.DefaultHTTPVersions...)).
 				AddFilter(envoy_v3.FilterMisdirectedRequests(vh.VirtualHost.Name)).
+				AddFilter(envoy_v3.FilterAdmissionControl(vh.AdmissionControlPolicy)).
 				DefaultFilters().
-				AddFilter(authFilter).
+				AddFilter(envoy_v3.FilterBuffer(bufferLimitOf(vh)))
+
+			// The GeoIP headers are set before authorization,
+			// so that the authorization service sees them.
+			for _, f := range v.ListenerConfig.geoIPFilters() {
+				cmb.AddFilter(f)
+			}
+			for _, f := range routeFiltersOf(vh) {
+				cmb.AddFilter(f)
+			}
+			cmb.AddFilter(authFilter)
+
+			// The Wasm modules of the vhost see only the
+			// requests that pass authorization.
+			for _, m := range vh.WasmModules {
+				cmb.AddFilter(envoy_v3.FilterWasm(m))
+			}
+
+			// Likewise, only the requests that pass
+			// authorization are captured by taps.
+			if taps := tapPoliciesOf(vh); len(taps) > 0 {
+				cmb.AddFilter(envoy_v3.FilterTapSelect())
+				for _, t := range taps {
+					cmb.AddFilter(envoy_v3.FilterTap(t))
+				}
+			}
+
+			cm := cmb.
 				RouteConfigName(path.Join("https", vh.VirtualHost.Name)).
+				DeltaRDS(v.ListenerConfig.XDSDelta).
 				MetricsPrefix(vh.ListenerName).
-				AccessLoggers(v.ListenerConfig.newSecureAccessLog()).
+				AccessLoggers(envoy_v3.FilterAccessLogs(v.ListenerConfig.newSecureAccessLog(), v.accessLogFilter)).
 				RequestTimeout(v.ListenerConfig.RequestTimeout).
 				ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
 				StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
@@ -502,8 +925,13 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 				MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
 				ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
 				AllowChunkedLength(v.ListenerConfig.AllowChunkedLength).
-				NumTrustedHops(v.ListenerConfig.XffNumTrustedHops).
+				AcceptHTTP10(v.ListenerConfig.AcceptHTTP10, v.ListenerConfig.DefaultHostForHTTP10).
+				NumTrustedHops(numTrustedHops).
+				SkipXffAppend(v.ListenerConfig.SkipXffAppend || vh.SkipXffAppend).
+				ReplaceExternalRequestID(replaceExternalRequestID).
 				AddFilter(envoy_v3.GlobalRateLimitFilter(envoyGlobalRateLimitConfig(v.RateLimitConfig))).
+				AddFilter(envoy_v3.FilterDynamicForwardProxy(dynamicForwardProxyOf(vh))).
+				Tracing(envoy_v3.Tracing(v.TracingConfig)).
 				Get()
 
 			filters = envoy_v3.Filters(cm)
@@ -513,7 +941,7 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 			filters = envoy_v3.Filters(
 				envoy_v3.TCPProxy(vh.ListenerName,
 					vh.TCPProxy,
-					v.ListenerConfig.newSecureAccessLog()),
+					v.ListenerConfig.newTCPAccessLog(v.ListenerConfig.httpsAccessLog(), vh.TCPProxy)),
 			)
 
 			// Do not offer ALPN for TCP proxying, since
@@ -554,11 +982,21 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 				vh.DownstreamValidation,
 				alpnProtos...)
 
-			cm := envoy_v3.HTTPConnectionManagerBuilder().
+			cmb := envoy_v3.HTTPConnectionManagerBuilder().
 				DefaultFilters().
+				AddFilter(envoy_v3.FilterBuffer(v.bufferLimit))
+			for _, f := range v.ListenerConfig.geoIPFilters() {
+				cmb.AddFilter(f)
+			}
+			for _, f := range v.routeFilters {
+				cmb.AddFilter(f)
+			}
+
+			cm := cmb.
 				RouteConfigName(ENVOY_FALLBACK_ROUTECONFIG).
+				DeltaRDS(v.ListenerConfig.XDSDelta).
 				MetricsPrefix(vh.ListenerName).
-				AccessLoggers(v.ListenerConfig.newSecureAccessLog()).
+				AccessLoggers(envoy_v3.FilterAccessLogs(v.ListenerConfig.newSecureAccessLog(), v.accessLogFilter)).
 				RequestTimeout(v.ListenerConfig.RequestTimeout).
 				ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
 				StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
@@ -566,8 +1004,12 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 				MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
 				ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
 				AllowChunkedLength(v.ListenerConfig.AllowChunkedLength).
+				AcceptHTTP10(v.ListenerConfig.AcceptHTTP10, v.ListenerConfig.DefaultHostForHTTP10).
 				NumTrustedHops(v.ListenerConfig.XffNumTrustedHops).
+				SkipXffAppend(v.ListenerConfig.SkipXffAppend).
+				ReplaceExternalRequestID(v.ListenerConfig.ReplaceExternalRequestID).
 				AddFilter(envoy_v3.GlobalRateLimitFilter(envoyGlobalRateLimitConfig(v.RateLimitConfig))).
+				Tracing(envoy_v3.Tracing(v.TracingConfig)).
 				Get()
 
 			// Default filter chain
@@ -16,6 +16,7 @@ package v3
 import (
 	"path"
 	"sort"
+	"strings"
 	"sync"
 
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
@@ -24,6 +25,7 @@ import (
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
 	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/sorter"
@@ -31,6 +33,16 @@ import (
 
 // RouteCache manages the contents of the gRPC RDS cache.
 type RouteCache struct {
+	// RequestIDHeader is the name of the request header whose value,
+	// if present, is used as the x-request-id of requests to virtual
+	// hosts that do not set their own.
+	RequestIDHeader string
+
+	// VirtualHosts, if not nil, receives the virtual hosts of the
+	// HTTP route configurations, which Envoy then fetches on demand
+	// over VHDS instead of with the route configurations.
+	VirtualHosts *VirtualHostCache
+
 	mu     sync.Mutex
 	values map[string]*envoy_route_v3.RouteConfiguration
 	contour.Cond
@@ -89,28 +101,62 @@ func (c *RouteCache) Query(names []string) []proto.Message {
 func (*RouteCache) TypeURL() string { return resource.RouteType }
 
 func (c *RouteCache) OnChange(root *dag.DAG) {
-	routes := visitRoutes(root)
+	routes := visitRoutes(root, c.RequestIDHeader)
+	if c.VirtualHosts != nil {
+		c.VirtualHosts.Update(onDemandVirtualHosts(routes))
+	}
 	c.Update(routes)
 }
 
 type routeVisitor struct {
 	routes map[string]*envoy_route_v3.RouteConfiguration
+
+	// accessLogSampling is set if any dag.Route samples its
+	// access logs, in which case every route sets the sampling
+	// header so that clients cannot supply their own.
+	accessLogSampling bool
+
+	// buffering is set if any dag.Route buffers the bodies of
+	// its requests, in which case the HTTP connection managers
+	// have the buffer filter and every virtual host disables it,
+	// leaving it enabled only on the routes with a buffer policy.
+	buffering bool
+
+	// requestIDHeader is the default request ID header of
+	// virtual hosts.
+	requestIDHeader string
+
+	// mirrors holds the routes that change the headers of the
+	// requests they mirror, keyed by the Host header of the
+	// mirrored requests.
+	mirrors map[string][]*dag.Route
 }
 
-func visitRoutes(root dag.Vertex) map[string]*envoy_route_v3.RouteConfiguration {
+func visitRoutes(root dag.Vertex, requestIDHeader string) map[string]*envoy_route_v3.RouteConfiguration {
 	// Collect the route configurations for all the routes we can
 	// find. For HTTP hosts, the routes will all be collected on the
-	// well-known ENVOY_HTTP_LISTENER, but for HTTPS hosts, we will
-	// generate a per-vhost collection. This lets us keep different
-	// SNI names disjoint when we later configure the listener.
+	// route configuration named after their listener, which is the
+	// well-known ENVOY_HTTP_LISTENER unless the host selects another,
+	// but for HTTPS hosts, we will generate a per-vhost collection.
+	// This lets us keep different SNI names disjoint when we later
+	// configure the listener.
 	rv := routeVisitor{
 		routes: map[string]*envoy_route_v3.RouteConfiguration{
 			ENVOY_HTTP_LISTENER: envoy_v3.RouteConfiguration(ENVOY_HTTP_LISTENER),
 		},
+		requestIDHeader: requestIDHeader,
+		mirrors:         map[string][]*dag.Route{},
 	}
 
+	_, rv.accessLogSampling = accessLogSamplingOf(root)
+	rv.buffering = bufferLimitOf(root) > 0
+
 	rv.visit(root)
 
+	if len(rv.mirrors) > 0 {
+		rv.routes[ENVOY_MIRROR_LISTENER] = mirrorRouteConfiguration(rv.mirrors)
+	}
+
 	for _, v := range rv.routes {
 		sort.Stable(sorter.For(v.VirtualHosts))
 	}
@@ -152,29 +198,118 @@ func (v *routeVisitor) onVirtualHost(vh *dag.VirtualHost) {
 		}
 
 		rt := &envoy_route_v3.Route{
-			Match:  envoy_v3.RouteMatch(route),
-			Action: envoy_v3.RouteRoute(route),
+			Match:    envoy_v3.RouteMatch(route),
+			Action:   envoy_v3.RouteRoute(route),
+			Metadata: envoy_v3.RouteMetadata(route),
 		}
 		if route.RequestHeadersPolicy != nil {
 			rt.RequestHeadersToAdd = append(envoy_v3.HeaderValueList(route.RequestHeadersPolicy.Set, false), envoy_v3.HeaderValueList(route.RequestHeadersPolicy.Add, true)...)
 			rt.RequestHeadersToRemove = route.RequestHeadersPolicy.Remove
 		}
 		if route.ResponseHeadersPolicy != nil {
-			rt.ResponseHeadersToAdd = envoy_v3.HeaderValueList(route.ResponseHeadersPolicy.Set, false)
+			rt.ResponseHeadersToAdd = append(envoy_v3.HeaderValueList(route.ResponseHeadersPolicy.Set, false), envoy_v3.HeaderValueList(route.ResponseHeadersPolicy.Add, true)...)
 			rt.ResponseHeadersToRemove = route.ResponseHeadersPolicy.Remove
 		}
+		if route.TracingPolicy != nil {
+			rt.Tracing = envoy_v3.RouteTracing(route.TracingPolicy)
+		}
 		if route.RateLimitPolicy != nil && route.RateLimitPolicy.Local != nil {
 			if rt.TypedPerFilterConfig == nil {
 				rt.TypedPerFilterConfig = map[string]*any.Any{}
 			}
 			rt.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = envoy_v3.LocalRateLimitConfig(route.RateLimitPolicy.Local, "vhost."+vh.Name)
 		}
+		if route.LuaScript != "" {
+			if rt.TypedPerFilterConfig == nil {
+				rt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
+			rt.TypedPerFilterConfig["envoy.filters.http.lua"] = envoy_v3.LuaPerRoute(route.LuaScript)
+		}
+		if route.BufferPolicy != nil {
+			if rt.TypedPerFilterConfig == nil {
+				rt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
+			rt.TypedPerFilterConfig["envoy.filters.http.buffer"] = envoy_v3.BufferPerRoute(route.BufferPolicy)
+		}
+		if route.RBACPolicy != nil {
+			if rt.TypedPerFilterConfig == nil {
+				rt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
+			rt.TypedPerFilterConfig["envoy.filters.http.rbac"] = envoy_v3.RBACPerRoute(route.RBACPolicy)
+		}
 		return rt
 
 	}
 
+	v.addMirrors(vh.Name, routes)
 	sortRoutes(routes)
-	v.routes[ENVOY_HTTP_LISTENER].VirtualHosts = append(v.routes[ENVOY_HTTP_LISTENER].VirtualHosts, toEnvoyVirtualHost(vh, routes, toEnvoyRoute))
+
+	name := vh.ListenerName
+	if name == "" {
+		name = ENVOY_HTTP_LISTENER
+	}
+	if _, ok := v.routes[name]; !ok {
+		v.routes[name] = envoy_v3.RouteConfiguration(name)
+	}
+	v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts, v.toEnvoyVirtualHost(vh, routes, toEnvoyRoute))
+}
+
+// toEnvoyVirtualHost converts a DAG virtual host and routes to an Envoy
+// virtual host, applying the settings that are shared by all virtual hosts.
+func (v *routeVisitor) toEnvoyVirtualHost(vh *dag.VirtualHost, routes []*dag.Route, toEnvoyRoute func(*dag.Route) *envoy_route_v3.Route) *envoy_route_v3.VirtualHost {
+	evh := toEnvoyVirtualHost(vh, routes, v.withAccessLogSampling(toEnvoyRoute))
+
+	if v.buffering {
+		if evh.TypedPerFilterConfig == nil {
+			evh.TypedPerFilterConfig = map[string]*any.Any{}
+		}
+		evh.TypedPerFilterConfig["envoy.filters.http.buffer"] = envoy_v3.BufferDisabled()
+	}
+
+	header := vh.RequestIDHeader
+	if header == "" {
+		header = v.requestIDHeader
+	}
+	if header != "" {
+		evh.RequestHeadersToAdd = envoy_v3.RequestIDFromHeader(header)
+	}
+
+	return evh
+}
+
+// toEnvoySecureVirtualHost converts a DAG secure virtual host and routes
+// to an Envoy virtual host, setting the client certificate headers.
+func (v *routeVisitor) toEnvoySecureVirtualHost(svh *dag.SecureVirtualHost, routes []*dag.Route, toEnvoyRoute func(*dag.Route) *envoy_route_v3.Route) *envoy_route_v3.VirtualHost {
+	evh := v.toEnvoyVirtualHost(&svh.VirtualHost, routes, toEnvoyRoute)
+
+	if len(svh.ClientCertificateHeaders) > 0 {
+		evh.RequestHeadersToAdd = append(evh.RequestHeadersToAdd, envoy_v3.ClientCertificateHeaders(svh.ClientCertificateHeaders)...)
+		for name := range svh.ClientCertificateHeaders {
+			evh.RequestHeadersToRemove = append(evh.RequestHeadersToRemove, name)
+		}
+		sort.Strings(evh.RequestHeadersToRemove)
+	}
+
+	return evh
+}
+
+// withAccessLogSampling wraps toEnvoyRoute to set the access log
+// sampling header on each route when access log sampling is in use.
+func (v *routeVisitor) withAccessLogSampling(toEnvoyRoute func(*dag.Route) *envoy_route_v3.Route) func(*dag.Route) *envoy_route_v3.Route {
+	if !v.accessLogSampling {
+		return toEnvoyRoute
+	}
+
+	return func(route *dag.Route) *envoy_route_v3.Route {
+		percentage := uint32(100)
+		if route.AccessLogSampling != nil {
+			percentage = *route.AccessLogSampling
+		}
+
+		rt := toEnvoyRoute(route)
+		rt.RequestHeadersToAdd = append(rt.RequestHeadersToAdd, envoy_v3.AccessLogSampling(percentage)...)
+		return rt
+	}
 }
 
 func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
@@ -201,8 +336,9 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 		}
 
 		rt := &envoy_route_v3.Route{
-			Match:  envoy_v3.RouteMatch(route),
-			Action: envoy_v3.RouteRoute(route),
+			Match:    envoy_v3.RouteMatch(route),
+			Action:   envoy_v3.RouteRoute(route),
+			Metadata: envoy_v3.RouteMetadata(route),
 		}
 
 		if route.RequestHeadersPolicy != nil {
@@ -210,15 +346,40 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 			rt.RequestHeadersToRemove = route.RequestHeadersPolicy.Remove
 		}
 		if route.ResponseHeadersPolicy != nil {
-			rt.ResponseHeadersToAdd = envoy_v3.HeaderValueList(route.ResponseHeadersPolicy.Set, false)
+			rt.ResponseHeadersToAdd = append(envoy_v3.HeaderValueList(route.ResponseHeadersPolicy.Set, false), envoy_v3.HeaderValueList(route.ResponseHeadersPolicy.Add, true)...)
 			rt.ResponseHeadersToRemove = route.ResponseHeadersPolicy.Remove
 		}
+		if route.TracingPolicy != nil {
+			rt.Tracing = envoy_v3.RouteTracing(route.TracingPolicy)
+		}
 		if route.RateLimitPolicy != nil && route.RateLimitPolicy.Local != nil {
 			if rt.TypedPerFilterConfig == nil {
 				rt.TypedPerFilterConfig = map[string]*any.Any{}
 			}
 			rt.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = envoy_v3.LocalRateLimitConfig(route.RateLimitPolicy.Local, "vhost."+svh.Name)
 		}
+		if route.LuaScript != "" {
+			if rt.TypedPerFilterConfig == nil {
+				rt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
+			rt.TypedPerFilterConfig["envoy.filters.http.lua"] = envoy_v3.LuaPerRoute(route.LuaScript)
+		}
+		if route.BufferPolicy != nil {
+			if rt.TypedPerFilterConfig == nil {
+				rt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
+			rt.TypedPerFilterConfig["envoy.filters.http.buffer"] = envoy_v3.BufferPerRoute(route.BufferPolicy)
+		}
+		if route.RBACPolicy != nil {
+			if rt.TypedPerFilterConfig == nil {
+				rt.TypedPerFilterConfig = map[string]*any.Any{}
+			}
+			rt.TypedPerFilterConfig["envoy.filters.http.rbac"] = envoy_v3.RBACPerRoute(route.RBACPolicy)
+		}
+		if route.TapPolicy != nil {
+			remove := rt.RequestHeadersToRemove
+			rt.RequestHeadersToRemove = append(remove[:len(remove):len(remove)], envoy_v3.TapHeader)
+		}
 
 		// If authorization is enabled on this host, we may need to set per-route filter overrides.
 		if svh.AuthorizationService != nil {
@@ -241,6 +402,8 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 		return rt
 	}
 
+	v.addMirrors(svh.VirtualHost.Name, routes)
+
 	// Add secure vhost route config if not already present.
 	name := path.Join("https", svh.VirtualHost.Name)
 	if _, ok := v.routes[name]; !ok {
@@ -248,7 +411,7 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 	}
 
 	sortRoutes(routes)
-	v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts, toEnvoyVirtualHost(&svh.VirtualHost, routes, toEnvoyRoute))
+	v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts, v.toEnvoySecureVirtualHost(svh, routes, toEnvoyRoute))
 
 	// A fallback route configuration contains routes for all the vhosts that have the fallback certificate enabled.
 	// When a request is received, the default TLS filterchain will accept the connection,
@@ -259,8 +422,136 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 			v.routes[ENVOY_FALLBACK_ROUTECONFIG] = envoy_v3.RouteConfiguration(ENVOY_FALLBACK_ROUTECONFIG)
 		}
 
-		v.routes[ENVOY_FALLBACK_ROUTECONFIG].VirtualHosts = append(v.routes[ENVOY_FALLBACK_ROUTECONFIG].VirtualHosts, toEnvoyVirtualHost(&svh.VirtualHost, routes, toEnvoyRoute))
+		v.routes[ENVOY_FALLBACK_ROUTECONFIG].VirtualHosts = append(v.routes[ENVOY_FALLBACK_ROUTECONFIG].VirtualHosts, v.toEnvoySecureVirtualHost(svh, routes, toEnvoyRoute))
+	}
+}
+
+// addMirrors records the routes of the virtual host named host that
+// change the headers of the requests they mirror. Envoy appends
+// "-shadow" to the Host header of mirrored requests after applying
+// the path, host and header rewrites of the route, so the recorded
+// routes match the rewritten requests.
+func (v *routeVisitor) addMirrors(host string, routes []*dag.Route) {
+	for _, route := range routes {
+		if !mirrorsWithHeaders(route) || route.HTTPSUpgrade || route.DirectResponse != nil {
+			continue
+		}
+
+		mirror := *route
+		mirror.PathMatchCondition = rewrittenPathMatch(route)
+		mirror.HeaderMatchConditions = rewrittenHeaderMatches(route)
+
+		shadow := host
+		if rewrite := envoy.HostReplaceHeader(route.RequestHeadersPolicy); rewrite != "" {
+			shadow = rewrite
+		}
+		shadow += "-shadow"
+
+		v.mirrors[shadow] = append(v.mirrors[shadow], &mirror)
+	}
+}
+
+// rewrittenPathMatch returns the condition that matches the paths of
+// the requests of r after its prefix rewrite. Envoy swaps the matched
+// prefix of a prefix match, and the whole path of an exact or regex
+// match, for the rewrite.
+func rewrittenPathMatch(r *dag.Route) dag.MatchCondition {
+	if r.PrefixRewrite == "" {
+		return r.PathMatchCondition
+	}
+
+	switch r.PathMatchCondition.(type) {
+	case *dag.PrefixMatchCondition:
+		// The remainder of the path follows the rewrite
+		// whether or not it starts with a slash, so
+		// only a string prefix matches it.
+		return &dag.PrefixMatchCondition{Prefix: r.PrefixRewrite}
+	case *dag.ExactMatchCondition, *dag.RegexMatchCondition:
+		return &dag.ExactMatchCondition{Path: r.PrefixRewrite}
+	default:
+		return r.PathMatchCondition
+	}
+}
+
+// rewrittenHeaderMatches returns the header conditions of r that
+// still hold after its request headers policy is applied. Conditions
+// on the headers the policy sets, adds or removes are dropped, since
+// the mirrored requests carry the rewritten values.
+func rewrittenHeaderMatches(r *dag.Route) []dag.HeaderMatchCondition {
+	hp := r.RequestHeadersPolicy
+	if hp == nil {
+		return r.HeaderMatchConditions
+	}
+
+	rewritten := func(name string) bool {
+		name = strings.ToLower(name)
+		for k := range hp.Set {
+			if strings.ToLower(k) == name {
+				return true
+			}
+		}
+		for k := range hp.Add {
+			if strings.ToLower(k) == name {
+				return true
+			}
+		}
+		for _, k := range hp.Remove {
+			if strings.ToLower(k) == name {
+				return true
+			}
+		}
+		for _, m := range hp.RemoveMatching {
+			// A Lua pattern may match any name.
+			if m.Pattern != "" || strings.HasPrefix(name, m.Prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var conds []dag.HeaderMatchCondition
+	for _, cond := range r.HeaderMatchConditions {
+		if !rewritten(cond.Name) {
+			conds = append(conds, cond)
+		}
 	}
+	return conds
+}
+
+// mirrorRouteConfiguration returns the route configuration of the
+// mirror listener, which has a virtual host for each Host header of
+// mirrored requests. Routes that are recorded by both the secure and
+// insecure virtual host of a host are only added once.
+func mirrorRouteConfiguration(mirrors map[string][]*dag.Route) *envoy_route_v3.RouteConfiguration {
+	rc := &envoy_route_v3.RouteConfiguration{
+		Name: ENVOY_MIRROR_LISTENER,
+	}
+
+	for host, routes := range mirrors {
+		sortRoutes(routes)
+
+		var envoyRoutes []*envoy_route_v3.Route
+		for _, route := range routes {
+			rt := envoy_v3.MirrorRoute(route)
+			if !containsRoute(envoyRoutes, rt) {
+				envoyRoutes = append(envoyRoutes, rt)
+			}
+		}
+
+		rc.VirtualHosts = append(rc.VirtualHosts, envoy_v3.VirtualHost(host, envoyRoutes...))
+	}
+
+	return rc
+}
+
+// containsRoute returns true if routes holds a route equal to r.
+func containsRoute(routes []*envoy_route_v3.Route, r *envoy_route_v3.Route) bool {
+	for _, rt := range routes {
+		if proto.Equal(rt, r) {
+			return true
+		}
+	}
+	return false
 }
 
 func (v *routeVisitor) visit(vertex dag.Vertex) {
@@ -320,10 +611,18 @@ func toEnvoyVirtualHost(vh *dag.VirtualHost, routes []*dag.Route, toEnvoyRoute f
 		}
 		evh.TypedPerFilterConfig["envoy.filters.http.local_ratelimit"] = envoy_v3.LocalRateLimitConfig(vh.RateLimitPolicy.Local, "vhost."+vh.Name)
 	}
+	if vh.LuaScript != "" {
+		if evh.TypedPerFilterConfig == nil {
+			evh.TypedPerFilterConfig = map[string]*any.Any{}
+		}
+		evh.TypedPerFilterConfig["envoy.filters.http.lua"] = envoy_v3.LuaPerRoute(vh.LuaScript)
+	}
 
 	if vh.RateLimitPolicy != nil && vh.RateLimitPolicy.Global != nil {
 		evh.RateLimits = envoy_v3.GlobalRateLimits(vh.RateLimitPolicy.Global.Descriptors)
 	}
 
+	evh.VirtualClusters = envoy_v3.VirtualClusters(vh.StatsName, routes)
+
 	return evh
 }
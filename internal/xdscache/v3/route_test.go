@@ -22,6 +22,7 @@ import (
 	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	contour_api_v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/dag"
@@ -136,6 +137,8 @@ func TestRouteCacheQuery(t *testing.T) {
 }
 
 func TestRouteVisit(t *testing.T) {
+	sampled, unsampled := uint32(10), uint32(100)
+
 	tests := map[string]struct {
 		objs                []interface{}
 		fallbackCertificate *types.NamespacedName
@@ -1399,6 +1402,296 @@ func TestRouteVisit(t *testing.T) {
 				),
 			),
 		},
+		"httpproxy with access log sampling": {
+			objs: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "www.example.com",
+							AccessLogPolicy: &contour_api_v1.AccessLogPolicy{
+								SamplingPercentage: &sampled,
+							},
+						},
+						Routes: []contour_api_v1.Route{{
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}, {
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/healthz",
+							}},
+							AccessLogPolicy: &contour_api_v1.AccessLogPolicy{
+								Disabled: true,
+							},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}, {
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/admin",
+							}},
+							AccessLogPolicy: &contour_api_v1.AccessLogPolicy{
+								SamplingPercentage: &unsampled,
+							},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Protocol:   "TCP",
+							Port:       80,
+							TargetPort: intstr.FromInt(8080),
+						}},
+					},
+				},
+			},
+			want: routeConfigurations(
+				envoy_v3.RouteConfiguration("ingress_http",
+					envoy_v3.VirtualHost("www.example.com",
+						&envoy_route_v3.Route{
+							Match:               routePrefix("/healthz"),
+							Action:              routecluster("default/backend/80/da39a3ee5e"),
+							RequestHeadersToAdd: envoy_v3.AccessLogSampling(0),
+						},
+						&envoy_route_v3.Route{
+							Match:               routePrefix("/admin"),
+							Action:              routecluster("default/backend/80/da39a3ee5e"),
+							RequestHeadersToAdd: envoy_v3.AccessLogSampling(100),
+						},
+						&envoy_route_v3.Route{
+							Match:               routePrefix("/"),
+							Action:              routecluster("default/backend/80/da39a3ee5e"),
+							RequestHeadersToAdd: envoy_v3.AccessLogSampling(10),
+						},
+					),
+				),
+			),
+		},
+		"httpproxy with stats names": {
+			objs: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn:      "www.example.com",
+							StatsName: "example",
+						},
+						Routes: []contour_api_v1.Route{{
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}, {
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/api",
+							}},
+							StatsName: "api",
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Protocol:   "TCP",
+							Port:       80,
+							TargetPort: intstr.FromInt(8080),
+						}},
+					},
+				},
+			},
+			want: routeConfigurations(
+				envoy_v3.RouteConfiguration("ingress_http",
+					&envoy_route_v3.VirtualHost{
+						Name:    "www.example.com",
+						Domains: []string{"www.example.com"},
+						Routes: []*envoy_route_v3.Route{{
+							Match:  routePrefix("/api"),
+							Action: routecluster("default/backend/80/da39a3ee5e"),
+						}, {
+							Match:  routePrefix("/"),
+							Action: routecluster("default/backend/80/da39a3ee5e"),
+						}},
+						VirtualClusters: []*envoy_route_v3.VirtualCluster{{
+							Name: "api",
+							Headers: []*envoy_route_v3.HeaderMatcher{{
+								Name: ":path",
+								HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+									PrefixMatch: "/api",
+								},
+							}},
+						}, {
+							Name: "example",
+							Headers: []*envoy_route_v3.HeaderMatcher{{
+								Name: ":path",
+								HeaderMatchSpecifier: &envoy_route_v3.HeaderMatcher_PrefixMatch{
+									PrefixMatch: "/",
+								},
+							}},
+						}},
+					},
+				),
+			),
+		},
+		"httpproxy with request id header": {
+			objs: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "www.example.com",
+							RequestIDPolicy: &contour_api_v1.RequestIDPolicy{
+								Header: "x-cdn-request-id",
+							},
+						},
+						Routes: []contour_api_v1.Route{{
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Protocol:   "TCP",
+							Port:       80,
+							TargetPort: intstr.FromInt(8080),
+						}},
+					},
+				},
+			},
+			want: routeConfigurations(
+				envoy_v3.RouteConfiguration("ingress_http",
+					&envoy_route_v3.VirtualHost{
+						Name:    "www.example.com",
+						Domains: []string{"www.example.com"},
+						Routes: []*envoy_route_v3.Route{{
+							Match:  routePrefix("/"),
+							Action: routecluster("default/backend/80/da39a3ee5e"),
+						}},
+						RequestHeadersToAdd: []*envoy_core_v3.HeaderValueOption{{
+							Header: &envoy_core_v3.HeaderValue{
+								Key:   "x-request-id",
+								Value: "%REQ(x-cdn-request-id)%",
+							},
+							Append: protobuf.Bool(false),
+						}},
+					},
+				),
+			),
+		},
+		"httpproxy with buffer policy": {
+			objs: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []contour_api_v1.Route{{
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/upload",
+							}},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+							BufferPolicy: &contour_api_v1.BufferPolicy{
+								MaxRequestBytes: 65536,
+							},
+						}, {
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Protocol:   "TCP",
+							Port:       80,
+							TargetPort: intstr.FromInt(8080),
+						}},
+					},
+				},
+			},
+			want: routeConfigurations(
+				envoy_v3.RouteConfiguration("ingress_http",
+					&envoy_route_v3.VirtualHost{
+						Name:    "www.example.com",
+						Domains: []string{"www.example.com"},
+						Routes: []*envoy_route_v3.Route{{
+							Match:  routePrefix("/upload"),
+							Action: routecluster("default/backend/80/da39a3ee5e"),
+							TypedPerFilterConfig: map[string]*any.Any{
+								"envoy.filters.http.buffer": envoy_v3.BufferPerRoute(&dag.BufferPolicy{MaxRequestBytes: 65536}),
+							},
+						}, {
+							Match:  routePrefix("/"),
+							Action: routecluster("default/backend/80/da39a3ee5e"),
+						}},
+						TypedPerFilterConfig: map[string]*any.Any{
+							"envoy.filters.http.buffer": envoy_v3.BufferDisabled(),
+						},
+					},
+				),
+			),
+		},
 		"httpproxy with mirror policy": {
 			objs: []interface{}{
 				&contour_api_v1.HTTPProxy{
@@ -1463,6 +1756,96 @@ func TestRouteVisit(t *testing.T) {
 				),
 			),
 		},
+		"httpproxy with mirror policy with request headers policy": {
+			objs: []interface{}{
+				&contour_api_v1.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: contour_api_v1.HTTPProxySpec{
+						VirtualHost: &contour_api_v1.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []contour_api_v1.Route{{
+							Conditions: []contour_api_v1.MatchCondition{{
+								Prefix: "/api",
+							}},
+							Services: []contour_api_v1.Service{{
+								Name: "backend",
+								Port: 80,
+							}, {
+								Name:   "backendtwo",
+								Port:   80,
+								Mirror: true,
+								MirrorPolicy: &contour_api_v1.MirrorPolicy{
+									RequestHeaders: []contour_api_v1.HeaderValue{{
+										Name:  "x-shadow",
+										Value: "true",
+									}},
+								},
+							}},
+							PathRewritePolicy: &contour_api_v1.PathRewritePolicy{
+								ReplacePrefix: []contour_api_v1.ReplacePrefix{{
+									Replacement: "/v2",
+								}},
+							},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Protocol:   "TCP",
+							Port:       80,
+							TargetPort: intstr.FromInt(8080),
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backendtwo",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Protocol:   "TCP",
+							Port:       80,
+							TargetPort: intstr.FromInt(8080),
+						}},
+					},
+				},
+			},
+			want: routeConfigurations(
+				envoy_v3.RouteConfiguration("ingress_http",
+					envoy_v3.VirtualHost("www.example.com",
+						&envoy_route_v3.Route{
+							Match:  routePrefix("/api/"),
+							Action: withPrefixRewrite(withMirrorPolicy(routecluster("default/backend/80/da39a3ee5e"), "contour_mirror"), "/v2/"),
+						},
+						&envoy_route_v3.Route{
+							Match:  routePrefix("/api"),
+							Action: withPrefixRewrite(withMirrorPolicy(routecluster("default/backend/80/da39a3ee5e"), "contour_mirror"), "/v2"),
+						},
+					),
+				),
+				// The mirrored requests have already been
+				// rewritten when they reach the mirror listener.
+				&envoy_route_v3.RouteConfiguration{
+					Name: "ingress_mirror",
+					VirtualHosts: []*envoy_route_v3.VirtualHost{
+						envoy_v3.VirtualHost("www.example.com-shadow",
+							shadowRoute(routePrefix("/v2/"), "default/backendtwo/80/da39a3ee5e"),
+							shadowRoute(routePrefix("/v2"), "default/backendtwo/80/da39a3ee5e"),
+						),
+					},
+				},
+			),
+		},
 		"httpproxy with pathPrefix with tls": {
 			objs: []interface{}{
 				&contour_api_v1.HTTPProxy{
@@ -2929,7 +3312,7 @@ func TestRouteVisit(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			root := buildDAGFallback(t, tc.fallbackCertificate, tc.objs...)
-			got := visitRoutes(root)
+			got := visitRoutes(root, "")
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -3210,6 +3593,77 @@ func weightedClusters(first, second *envoy_route_v3.WeightedCluster_ClusterWeigh
 	return append([]*envoy_route_v3.WeightedCluster_ClusterWeight{first, second}, rest...)
 }
 
+func TestMirrorRouteRewrites(t *testing.T) {
+	tests := map[string]struct {
+		route       *dag.Route
+		wantPath    dag.MatchCondition
+		wantHeaders []dag.HeaderMatchCondition
+	}{
+		"no rewrite": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/api", PrefixMatchType: dag.PrefixMatchSegment},
+				HeaderMatchConditions: []dag.HeaderMatchCondition{
+					{Name: "x-version", Value: "1", MatchType: "exact"},
+				},
+			},
+			wantPath: &dag.PrefixMatchCondition{Prefix: "/api", PrefixMatchType: dag.PrefixMatchSegment},
+			wantHeaders: []dag.HeaderMatchCondition{
+				{Name: "x-version", Value: "1", MatchType: "exact"},
+			},
+		},
+		"prefix rewrite of a prefix match": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/api", PrefixMatchType: dag.PrefixMatchSegment},
+				PrefixRewrite:      "/v2",
+			},
+			wantPath: &dag.PrefixMatchCondition{Prefix: "/v2"},
+		},
+		"prefix rewrite of an exact match": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.ExactMatchCondition{Path: "/api"},
+				PrefixRewrite:      "/v2/api",
+			},
+			wantPath: &dag.ExactMatchCondition{Path: "/v2/api"},
+		},
+		"prefix rewrite of a regex match": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.RegexMatchCondition{Regex: "/api/.*"},
+				PrefixRewrite:      "/v2",
+			},
+			wantPath: &dag.ExactMatchCondition{Path: "/v2"},
+		},
+		"header rewrites": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/"},
+				HeaderMatchConditions: []dag.HeaderMatchCondition{
+					{Name: "X-Version", Value: "1", MatchType: "exact"},
+					{Name: "x-debug", MatchType: "present"},
+					{Name: "x-internal-id", MatchType: "present"},
+					{Name: "x-tenant", Value: "a", MatchType: "exact"},
+				},
+				RequestHeadersPolicy: &dag.HeadersPolicy{
+					Set:    map[string]string{"x-version": "2"},
+					Remove: []string{"X-Debug"},
+					RemoveMatching: []dag.HeaderNameMatch{
+						{Prefix: "x-internal-"},
+					},
+				},
+			},
+			wantPath: &dag.PrefixMatchCondition{Prefix: "/"},
+			wantHeaders: []dag.HeaderMatchCondition{
+				{Name: "x-tenant", Value: "a", MatchType: "exact"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.wantPath, rewrittenPathMatch(tc.route))
+			assert.Equal(t, tc.wantHeaders, rewrittenHeaderMatches(tc.route))
+		})
+	}
+}
+
 func weightedCluster(name string, weight uint32) *envoy_route_v3.WeightedCluster_ClusterWeight {
 	return &envoy_route_v3.WeightedCluster_ClusterWeight{
 		Name:   name,
@@ -3225,6 +3679,24 @@ func routeConfigurations(rcs ...*envoy_route_v3.RouteConfiguration) map[string]*
 	return m
 }
 
+func withPrefixRewrite(route *envoy_route_v3.Route_Route, replacement string) *envoy_route_v3.Route_Route {
+	route.Route.PrefixRewrite = replacement
+	return route
+}
+
+// shadowRoute returns a route of the mirror listener that sets the
+// X-Shadow header of the mirrored requests it matches.
+func shadowRoute(match *envoy_route_v3.RouteMatch, cluster string) *envoy_route_v3.Route {
+	action := routecluster(cluster)
+	action.Route.Timeout = protobuf.Duration(0)
+
+	return &envoy_route_v3.Route{
+		Match:               match,
+		Action:              action,
+		RequestHeadersToAdd: envoy_v3.HeaderValueList(map[string]string{"X-Shadow": "true"}, false),
+	}
+}
+
 func withMirrorPolicy(route *envoy_route_v3.Route_Route, mirror string) *envoy_route_v3.Route_Route {
 	route.Route.RequestMirrorPolicies = []*envoy_route_v3.RouteAction_RequestMirrorPolicy{{
 		Cluster: mirror,
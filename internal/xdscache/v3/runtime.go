@@ -0,0 +1,83 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"sync"
+
+	envoy_service_runtime_v3 "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+)
+
+// RuntimeCache manages the contents of the gRPC RTDS cache. It holds
+// the single runtime layer that Envoy fetches from Contour, whose
+// values are set from Contour's configuration file rather than from
+// the DAG.
+type RuntimeCache struct {
+	mu    sync.Mutex
+	value *envoy_service_runtime_v3.Runtime
+	contour.Cond
+}
+
+// NewRuntimeCache returns a RuntimeCache holding the given
+// runtime values, keyed by runtime key.
+func NewRuntimeCache(values map[string]string) *RuntimeCache {
+	return &RuntimeCache{
+		value: envoy_v3.Runtime(values),
+	}
+}
+
+// Update replaces the runtime values held by the cache.
+func (c *RuntimeCache) Update(values map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.value = envoy_v3.Runtime(values)
+	c.Cond.Notify()
+}
+
+// Contents returns a copy of the cache's contents.
+func (c *RuntimeCache) Contents() []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.value == nil {
+		return nil
+	}
+	return []proto.Message{c.value}
+}
+
+// Query searches the RuntimeCache for the named Runtime entries.
+func (c *RuntimeCache) Query(names []string) []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, n := range names {
+		if c.value != nil && n == c.value.Name {
+			return []proto.Message{c.value}
+		}
+	}
+	return nil
+}
+
+// TypeURL returns the string type of RuntimeCache Resource.
+func (*RuntimeCache) TypeURL() string { return resource.RuntimeType }
+
+// OnChange does nothing, since the runtime values do not
+// depend on the DAG.
+func (*RuntimeCache) OnChange(*dag.DAG) {}
@@ -0,0 +1,36 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestRuntimeCache(t *testing.T) {
+	values := map[string]string{
+		"envoy.reloadable_features.http_reject_path_with_fragment": "false",
+	}
+	c := NewRuntimeCache(values)
+
+	protobuf.ExpectEqual(t, []proto.Message{envoy_v3.Runtime(values)}, c.Contents())
+	protobuf.ExpectEqual(t, []proto.Message{envoy_v3.Runtime(values)}, c.Query([]string{"contour"}))
+	protobuf.ExpectEqual(t, []proto.Message(nil), c.Query([]string{"other"}))
+
+	c.Update(nil)
+	protobuf.ExpectEqual(t, []proto.Message{envoy_v3.Runtime(nil)}, c.Contents())
+}
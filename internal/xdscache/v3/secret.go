@@ -30,8 +30,9 @@ import (
 
 // SecretCache manages the contents of the gRPC SDS cache.
 type SecretCache struct {
-	mu     sync.Mutex
-	values map[string]*envoy_tls_v3.Secret
+	mu         sync.Mutex
+	values     map[string]*envoy_tls_v3.Secret
+	namespaces map[string]string
 	contour.Cond
 }
 
@@ -44,6 +45,15 @@ func (c *SecretCache) Update(v map[string]*envoy_tls_v3.Secret) {
 	c.Cond.Notify()
 }
 
+// Namespace returns the namespace of the Kubernetes secret that the
+// named secret was built from.
+func (c *SecretCache) Namespace(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.namespaces[name]
+}
+
 // Contents returns a copy of the cache's contents.
 func (c *SecretCache) Contents() []proto.Message {
 	c.mu.Lock()
@@ -75,21 +85,29 @@ func (c *SecretCache) Query(names []string) []proto.Message {
 func (*SecretCache) TypeURL() string { return resource.SecretType }
 
 func (c *SecretCache) OnChange(root *dag.DAG) {
-	secrets := visitSecrets(root)
+	secrets, namespaces := visitSecrets(root)
+
+	c.mu.Lock()
+	c.namespaces = namespaces
+	c.mu.Unlock()
+
 	c.Update(secrets)
 }
 
 type secretVisitor struct {
-	secrets map[string]*envoy_tls_v3.Secret
+	secrets    map[string]*envoy_tls_v3.Secret
+	namespaces map[string]string
 }
 
-// visitSecrets produces a map of *envoy_tls_v3.Secret
-func visitSecrets(root dag.Vertex) map[string]*envoy_tls_v3.Secret {
+// visitSecrets produces a map of *envoy_tls_v3.Secret, and a map
+// of the namespace of each secret.
+func visitSecrets(root dag.Vertex) (map[string]*envoy_tls_v3.Secret, map[string]string) {
 	sv := secretVisitor{
-		secrets: make(map[string]*envoy_tls_v3.Secret),
+		secrets:    make(map[string]*envoy_tls_v3.Secret),
+		namespaces: make(map[string]string),
 	}
 	sv.visit(root)
-	return sv.secrets
+	return sv.secrets, sv.namespaces
 }
 
 func (v *secretVisitor) addSecret(s *dag.Secret) {
@@ -97,6 +115,7 @@ func (v *secretVisitor) addSecret(s *dag.Secret) {
 	if _, ok := v.secrets[name]; !ok {
 		envoySecret := envoy_v3.Secret(s)
 		v.secrets[envoySecret.Name] = envoySecret
+		v.namespaces[envoySecret.Name] = s.Namespace()
 	}
 }
 
@@ -470,7 +470,7 @@ func TestSecretVisit(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			root := buildDAG(t, tc.objs...)
-			got := visitSecrets(root)
+			got, _ := visitSecrets(root)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -0,0 +1,161 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/contour"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/sorter"
+)
+
+// VirtualHostType is the type URL of the virtual hosts served by VHDS.
+const VirtualHostType = "type.googleapis.com/envoy.config.route.v3.VirtualHost"
+
+// VirtualHostCache manages the contents of the gRPC VHDS cache. It
+// is populated by the RouteCache, which moves the virtual hosts of the
+// HTTP route configurations here so that Envoy fetches them on demand.
+//
+// Each virtual host is named by its route configuration name and its
+// own name, joined with a slash.
+type VirtualHostCache struct {
+	mu      sync.Mutex
+	values  map[string]*envoy_route_v3.VirtualHost
+	domains map[string]string
+	contour.Cond
+}
+
+// Update replaces the contents of the cache with the supplied map.
+func (c *VirtualHostCache) Update(v map[string]*envoy_route_v3.VirtualHost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values = v
+	c.domains = map[string]string{}
+	for name, vh := range v {
+		routeConfig := name[:strings.Index(name, "/")+1]
+		for _, domain := range vh.Domains {
+			c.domains[routeConfig+domain] = name
+		}
+	}
+	c.Cond.Notify()
+}
+
+// Contents returns a copy of the cache's contents.
+func (c *VirtualHostCache) Contents() []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var values []*envoy_route_v3.VirtualHost
+	for _, v := range c.values {
+		values = append(values, v)
+	}
+
+	sort.Stable(sorter.For(values))
+	return protobuf.AsMessages(values)
+}
+
+// Query searches the VirtualHostCache for the named VirtualHost entries.
+func (c *VirtualHostCache) Query(names []string) []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var values []*envoy_route_v3.VirtualHost
+	for _, n := range names {
+		if v, ok := c.values[n]; ok {
+			values = append(values, v)
+		}
+	}
+
+	sort.Stable(sorter.For(values))
+	return protobuf.AsMessages(values)
+}
+
+// Alias returns the name of the virtual host that serves the requests
+// for alias, which Envoy forms by joining the route configuration name
+// and the Host header of a request with a slash. The returned name is
+// empty if no virtual host serves the requests.
+func (c *VirtualHostCache) Alias(alias string) (string, bool) {
+	i := strings.Index(alias, "/")
+	if i < 0 {
+		// Envoy first subscribes to the name of the
+		// route configuration, which is not an alias.
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	routeConfig, host := alias[:i+1], strings.ToLower(alias[i+1:])
+	for _, domain := range candidateDomains(host) {
+		if name, ok := c.domains[routeConfig+domain]; ok {
+			return name, true
+		}
+	}
+
+	return "", true
+}
+
+// TypeURL returns the string type of VirtualHostCache Resource.
+func (*VirtualHostCache) TypeURL() string { return VirtualHostType }
+
+// candidateDomains returns the virtual host domains that may match
+// host, from the most to the least specific.
+func candidateDomains(host string) []string {
+	domains := []string{host}
+
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+		domains = append(domains, hostname+":*", hostname)
+	}
+
+	if i := strings.Index(hostname, "."); i > 0 {
+		domains = append(domains, "*"+hostname[i:])
+	}
+
+	return domains
+}
+
+// onDemandVirtualHosts moves the virtual hosts of the HTTP route
+// configurations in routes to the returned map, keyed by their VHDS
+// name, and configures those route configurations to fetch them on
+// demand. The route configurations of HTTPS virtual hosts each hold
+// a single virtual host, so are left as they are, as is the route
+// configuration of the mirror listener, which does not fetch on demand.
+func onDemandVirtualHosts(routes map[string]*envoy_route_v3.RouteConfiguration) map[string]*envoy_route_v3.VirtualHost {
+	vhosts := map[string]*envoy_route_v3.VirtualHost{}
+	for name, rc := range routes {
+		if strings.HasPrefix(name, "https/") || name == ENVOY_FALLBACK_ROUTECONFIG || name == ENVOY_MIRROR_LISTENER {
+			continue
+		}
+
+		for _, vh := range rc.VirtualHosts {
+			vh.Name = name + "/" + vh.Name
+			vhosts[vh.Name] = vh
+		}
+
+		rc.VirtualHosts = nil
+		rc.Vhds = envoy_v3.VirtualHostDiscovery("contour")
+	}
+
+	return vhosts
+}
@@ -0,0 +1,112 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	"testing"
+
+	envoy_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/proto"
+	envoy_v3 "github.com/projectcontour/contour/internal/envoy/v3"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualHostCacheAlias(t *testing.T) {
+	var c VirtualHostCache
+	c.Update(map[string]*envoy_route_v3.VirtualHost{
+		"ingress_http/www.example.com": {
+			Name:    "ingress_http/www.example.com",
+			Domains: []string{"www.example.com"},
+		},
+		"ingress_http/*.example.org": {
+			Name:    "ingress_http/*.example.org",
+			Domains: []string{"*.example.org"},
+		},
+	})
+
+	tests := map[string]struct {
+		alias   string
+		want    string
+		isAlias bool
+	}{
+		"route configuration name": {
+			alias:   "ingress_http",
+			isAlias: false,
+		},
+		"exact host": {
+			alias:   "ingress_http/www.example.com",
+			want:    "ingress_http/www.example.com",
+			isAlias: true,
+		},
+		"host with port": {
+			alias:   "ingress_http/WWW.example.com:8080",
+			want:    "ingress_http/www.example.com",
+			isAlias: true,
+		},
+		"wildcard host": {
+			alias:   "ingress_http/foo.example.org",
+			want:    "ingress_http/*.example.org",
+			isAlias: true,
+		},
+		"unknown host": {
+			alias:   "ingress_http/example.net",
+			isAlias: true,
+		},
+		"other route configuration": {
+			alias:   "ingress_http_2/www.example.com",
+			isAlias: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, isAlias := c.Alias(tc.alias)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.isAlias, isAlias)
+		})
+	}
+}
+
+func TestRouteCacheOnDemandVirtualHosts(t *testing.T) {
+	vhosts := &VirtualHostCache{}
+	rc := RouteCache{VirtualHosts: vhosts}
+
+	routes := map[string]*envoy_route_v3.RouteConfiguration{
+		"ingress_http": envoy_v3.RouteConfiguration("ingress_http",
+			envoy_v3.VirtualHost("www.example.com"),
+		),
+		"https/www.example.com": envoy_v3.RouteConfiguration("https/www.example.com",
+			envoy_v3.VirtualHost("www.example.com"),
+		),
+	}
+	vhosts.Update(onDemandVirtualHosts(routes))
+	rc.Update(routes)
+
+	protobuf.ExpectEqual(t, []proto.Message{
+		&envoy_route_v3.VirtualHost{
+			Name:    "ingress_http/www.example.com",
+			Domains: []string{"www.example.com"},
+		},
+	}, vhosts.Contents())
+
+	want := envoy_v3.RouteConfiguration("ingress_http")
+	want.Vhds = envoy_v3.VirtualHostDiscovery("contour")
+	protobuf.ExpectEqual(t, []proto.Message{
+		envoy_v3.RouteConfiguration("https/www.example.com",
+			envoy_v3.VirtualHost("www.example.com"),
+		),
+		want,
+	}, rc.Contents())
+}
@@ -77,7 +77,7 @@ func TestVisitClusters(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := visitClusters(tc.root)
+			got := visitClusters(tc.root, DEFAULT_MIRROR_LISTENER_PORT)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -102,6 +102,7 @@ func TestVisitListeners(t *testing.T) {
 	}
 
 	tests := map[string]struct {
+		ListenerConfig
 		root dag.Vertex
 		want map[string]*envoy_listener_v3.Listener
 	}{
@@ -146,11 +147,110 @@ func TestVisitListeners(t *testing.T) {
 				},
 			),
 		},
+		"TCPService forward on additional HTTPS listener": {
+			ListenerConfig: ListenerConfig{
+				HTTPSListeners: map[string]Listener{
+					ENVOY_HTTPS_LISTENER: {
+						Name:    ENVOY_HTTPS_LISTENER,
+						Address: "0.0.0.0",
+						Port:    8443,
+					},
+					"legacy_https": {
+						Name:    "legacy_https",
+						Address: "0.0.0.0",
+						Port:    8444,
+					},
+				},
+			},
+			root: &dag.Listener{
+				Port: 443,
+				VirtualHosts: virtualhosts(
+					&dag.SecureVirtualHost{
+						VirtualHost: dag.VirtualHost{
+							Name:         "tcpproxy.example.com",
+							ListenerName: "legacy_https",
+						},
+						TCPProxy: p1,
+						Secret: &dag.Secret{
+							Object: &v1.Secret{
+								ObjectMeta: metav1.ObjectMeta{
+									Name:      "secret",
+									Namespace: "default",
+								},
+								Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+							},
+						},
+						MinTLSVersion: "1.2",
+					},
+				),
+			},
+			want: listenermap(
+				&envoy_listener_v3.Listener{
+					Name:    "legacy_https",
+					Address: envoy_v3.SocketAddress("0.0.0.0", 8444),
+					FilterChains: []*envoy_listener_v3.FilterChain{{
+						FilterChainMatch: &envoy_listener_v3.FilterChainMatch{
+							ServerNames: []string{"tcpproxy.example.com"},
+						},
+						TransportSocket: transportSocket("secret", envoy_tls_v3.TlsParameters_TLSv1_2, nil),
+						Filters:         envoy_v3.Filters(envoy_v3.TCPProxy("legacy_https", p1, envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTPS_ACCESS_LOG, "", nil))),
+					}},
+					ListenerFilters: envoy_v3.ListenerFilters(
+						envoy_v3.TLSInspector(),
+					),
+					SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+				},
+			),
+		},
+		"TCPService forward on plain TCP listener": {
+			ListenerConfig: ListenerConfig{
+				TCPListeners: map[string]Listener{
+					"redis": {
+						Name:    "redis",
+						Address: "0.0.0.0",
+						Port:    6379,
+					},
+				},
+			},
+			root: &dag.Listener{
+				Port: 6379,
+				VirtualHosts: virtualhosts(
+					&dag.TCPVirtualHost{
+						Name:         "redis.example.com",
+						ListenerName: "redis",
+						Port:         6379,
+						TCPProxy:     p1,
+					},
+				),
+			},
+			want: listenermap(
+				&envoy_listener_v3.Listener{
+					Name:          "redis",
+					Address:       envoy_v3.SocketAddress("0.0.0.0", 6379),
+					FilterChains:  envoy_v3.FilterChains(envoy_v3.TCPProxy("redis", p1, envoy_v3.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG, "", nil))),
+					SocketOptions: envoy_v3.TCPKeepaliveSocketOptions(),
+				},
+			),
+		},
+		"TCPService forward on unconfigured plain TCP listener": {
+			root: &dag.Listener{
+				Port: 6379,
+				VirtualHosts: virtualhosts(
+					&dag.TCPVirtualHost{
+						Name:         "redis.example.com",
+						ListenerName: "redis",
+						Port:         6379,
+						TCPProxy:     p1,
+					},
+				),
+			},
+			want: map[string]*envoy_listener_v3.Listener{},
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := visitListeners(tc.root, new(ListenerConfig))
+			got := visitListeners(tc.root, &tc.ListenerConfig)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -219,7 +319,7 @@ func TestVisitSecrets(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := visitSecrets(tc.root)
+			got, _ := visitSecrets(tc.root)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -14,6 +14,9 @@
 package certs
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1" // nolint:gosec
@@ -23,9 +26,21 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"net"
 	"time"
 )
 
+// KeyType is the type of the private keys of the generated certificates.
+type KeyType string
+
+const (
+	// RSAKeyType generates RSA keys.
+	RSAKeyType KeyType = "rsa"
+
+	// ECDSAKeyType generates ECDSA keys.
+	ECDSAKeyType KeyType = "ecdsa"
+)
+
 const (
 	// DefaultContourServiceName holds the default service name
 	// used for the Contour Kubernetes service. This value is added
@@ -53,6 +68,9 @@ const (
 	// keySize sets the RSA key size to 2048 bits. This is minimum recommended size
 	// for RSA keys.
 	keySize = 2048
+
+	// ecdsaKeySize sets the default ECDSA curve to P-256.
+	ecdsaKeySize = 256
 )
 
 // Configuration holds config parameters used for generating certificates.
@@ -75,6 +93,20 @@ type Configuration struct {
 
 	// EnvoyServiceName holds the name of the Envoy service name.
 	EnvoyServiceName string
+
+	// KeyType is the type of the private keys. Defaults to RSA.
+	KeyType KeyType
+
+	// KeySize is the size in bits of RSA keys, or the size of the
+	// curve of ECDSA keys, which must be 256, 384 or 521. Defaults
+	// to 2048 for RSA and 256 for ECDSA keys.
+	KeySize int
+
+	// ContourSANs and EnvoySANs are the additional Subject Alternative
+	// Names of the Contour and Envoy certificates. Names that are IP
+	// addresses are added as IP SANs, others as DNS SANs.
+	ContourSANs []string
+	EnvoySANs   []string
 }
 
 // Certificates contains a set of Certificates as []byte each holding
@@ -96,9 +128,14 @@ func GenerateCerts(config *Configuration) (*Certificates, error) {
 		config = &Configuration{}
 	}
 
+	spec, err := newKeySpec(config.KeyType, config.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	expiry := now.Add(24 * time.Duration(uint32OrDefault(config.Lifetime, DefaultCertificateLifetime)) * time.Hour)
-	caCertPEM, caKeyPEM, err := newCA("Project Contour", expiry)
+	caCertPEM, caKeyPEM, err := newCA("Project Contour", expiry, spec)
 	if err != nil {
 		return nil, err
 	}
@@ -106,9 +143,11 @@ func GenerateCerts(config *Configuration) (*Certificates, error) {
 	contourCert, contourKey, err := newCert(caCertPEM,
 		caKeyPEM,
 		expiry,
+		spec,
 		stringOrDefault(config.ContourServiceName, DefaultContourServiceName),
 		stringOrDefault(config.Namespace, DefaultNamespace),
 		stringOrDefault(config.DNSName, DefaultDNSName),
+		config.ContourSANs,
 	)
 	if err != nil {
 		return nil, err
@@ -117,9 +156,11 @@ func GenerateCerts(config *Configuration) (*Certificates, error) {
 	envoyCert, envoyKey, err := newCert(caCertPEM,
 		caKeyPEM,
 		expiry,
+		spec,
 		stringOrDefault(config.EnvoyServiceName, DefaultEnvoyServiceName),
 		stringOrDefault(config.Namespace, DefaultNamespace),
 		stringOrDefault(config.DNSName, DefaultDNSName),
+		config.EnvoySANs,
 	)
 	if err != nil {
 		return nil, err
@@ -134,11 +175,189 @@ func GenerateCerts(config *Configuration) (*Certificates, error) {
 	}, nil
 }
 
+// CertificateRequests contains the certificate signing requests for
+// the Contour & Envoy certificates, in PEM form, along with their
+// private keys.
+type CertificateRequests struct {
+	ContourRequest    []byte
+	ContourPrivateKey []byte
+	EnvoyRequest      []byte
+	EnvoyPrivateKey   []byte
+}
+
+// GenerateCertificateRequests generates private keys for Contour & Envoy
+// along with requests for certificates to be signed by an external CA,
+// returning them as a *CertificateRequests struct or error if encountered.
+// The Lifetime of the config is not used, since it is set by the signer.
+func GenerateCertificateRequests(config *Configuration) (*CertificateRequests, error) {
+	if config == nil {
+		config = &Configuration{}
+	}
+
+	spec, err := newKeySpec(config.KeyType, config.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	contourRequest, contourKey, err := newCertificateRequest(spec,
+		stringOrDefault(config.ContourServiceName, DefaultContourServiceName),
+		stringOrDefault(config.Namespace, DefaultNamespace),
+		stringOrDefault(config.DNSName, DefaultDNSName),
+		config.ContourSANs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	envoyRequest, envoyKey, err := newCertificateRequest(spec,
+		stringOrDefault(config.EnvoyServiceName, DefaultEnvoyServiceName),
+		stringOrDefault(config.Namespace, DefaultNamespace),
+		stringOrDefault(config.DNSName, DefaultDNSName),
+		config.EnvoySANs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertificateRequests{
+		ContourRequest:    contourRequest,
+		ContourPrivateKey: contourKey,
+		EnvoyRequest:      envoyRequest,
+		EnvoyPrivateKey:   envoyKey,
+	}, nil
+}
+
+// newCertificateRequest generates a new private key and a request for a
+// certificate for it with the same names as newCert uses.
+// The return values are request, key, err.
+func newCertificateRequest(spec keySpec, service, namespace, dnsname string, sans []string) ([]byte, []byte, error) {
+	key, err := spec.generate()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot generate key: %v", err)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: service,
+		},
+	}
+	template.DNSNames, template.IPAddresses = subjectAltNames(service, namespace, dnsname, sans)
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := marshalKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	requestPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: der,
+	})
+	return requestPEM, keyPEM, nil
+}
+
+// keySpec describes the private keys to generate.
+type keySpec struct {
+	keyType KeyType
+	size    int
+}
+
+// newKeySpec returns the keySpec for keys of the type and size,
+// applying the defaults for unset values.
+func newKeySpec(keyType KeyType, size int) (keySpec, error) {
+	switch keyType {
+	case "", RSAKeyType:
+		if size == 0 {
+			size = keySize
+		}
+		if size < keySize {
+			return keySpec{}, fmt.Errorf("RSA key size %d is less than %d bits", size, keySize)
+		}
+		return keySpec{keyType: RSAKeyType, size: size}, nil
+	case ECDSAKeyType:
+		if size == 0 {
+			size = ecdsaKeySize
+		}
+		switch size {
+		case 256, 384, 521:
+			return keySpec{keyType: ECDSAKeyType, size: size}, nil
+		default:
+			return keySpec{}, fmt.Errorf("ECDSA key size %d is not 256, 384 or 521", size)
+		}
+	default:
+		return keySpec{}, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// generate generates a new private key.
+func (s keySpec) generate() (crypto.Signer, error) {
+	if s.keyType == ECDSAKeyType {
+		curve := map[int]elliptic.Curve{
+			256: elliptic.P256(),
+			384: elliptic.P384(),
+			521: elliptic.P521(),
+		}[s.size]
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	}
+	return rsa.GenerateKey(rand.Reader, s.size)
+}
+
+// keyUsage returns the key usage of the leaf certificates of keys of
+// the spec's type. ECDSA keys can not be used for key encipherment.
+func (s keySpec) keyUsage() x509.KeyUsage {
+	if s.keyType == ECDSAKeyType {
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment
+	}
+	return x509.KeyUsageDigitalSignature |
+		x509.KeyUsageDataEncipherment |
+		x509.KeyUsageKeyEncipherment |
+		x509.KeyUsageContentCommitment
+}
+
+// marshalKey returns the PEM form of key.
+func marshalKey(key crypto.Signer) ([]byte, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// subjectKeyID returns the SubjectKeyId of a public key.
+func subjectKeyID(pub crypto.PublicKey) []byte {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return bigIntHash(pub.N)
+	case *ecdsa.PublicKey:
+		h := sha1.New()                                    // nolint:gosec
+		h.Write(elliptic.Marshal(pub.Curve, pub.X, pub.Y)) // nolint:errcheck
+		return h.Sum(nil)
+	default:
+		return nil
+	}
+}
+
 // newCert generates a new keypair given the CA keypair, the expiry time, the service name
-// ("contour" or "envoy"), and the Kubernetes namespace the service will run in (because
-// of the Kubernetes DNS schema.)
+// ("contour" or "envoy"), the Kubernetes namespace the service will run in (because
+// of the Kubernetes DNS schema), and any additional Subject Alternative Names.
 // The return values are cert, key, err.
-func newCert(caCertPEM, caKeyPEM []byte, expiry time.Time, service, namespace, dnsname string) ([]byte, []byte, error) {
+func newCert(caCertPEM, caKeyPEM []byte, expiry time.Time, spec keySpec, service, namespace, dnsname string, sans []string) ([]byte, []byte, error) {
 
 	caKeyPair, err := tls.X509KeyPair(caCertPEM, caKeyPEM)
 	if err != nil {
@@ -148,12 +367,12 @@ func newCert(caCertPEM, caKeyPEM []byte, expiry time.Time, service, namespace, d
 	if err != nil {
 		return nil, nil, err
 	}
-	caKey, ok := caKeyPair.PrivateKey.(*rsa.PrivateKey)
+	caKey, ok := caKeyPair.PrivateKey.(crypto.Signer)
 	if !ok {
 		return nil, nil, fmt.Errorf("CA private key has unexpected type %T", caKeyPair.PrivateKey)
 	}
 
-	newKey, err := rsa.GenerateKey(rand.Reader, keySize)
+	newKey, err := spec.generate()
 	if err != nil {
 		return nil, nil, fmt.Errorf("cannot generate key: %v", err)
 	}
@@ -166,22 +385,19 @@ func newCert(caCertPEM, caKeyPEM []byte, expiry time.Time, service, namespace, d
 		},
 		NotBefore:    now.UTC().AddDate(0, 0, -1),
 		NotAfter:     expiry.UTC(),
-		SubjectKeyId: bigIntHash(newKey.N),
-		KeyUsage: x509.KeyUsageDigitalSignature |
-			x509.KeyUsageDataEncipherment |
-			x509.KeyUsageKeyEncipherment |
-			x509.KeyUsageContentCommitment,
-		DNSNames: serviceNames(service, namespace, dnsname),
-	}
-	newCert, err := x509.CreateCertificate(rand.Reader, template, caCert, &newKey.PublicKey, caKey)
+		SubjectKeyId: subjectKeyID(newKey.Public()),
+		KeyUsage:     spec.keyUsage(),
+	}
+	template.DNSNames, template.IPAddresses = subjectAltNames(service, namespace, dnsname, sans)
+	newCert, err := x509.CreateCertificate(rand.Reader, template, caCert, newKey.Public(), caKey)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	newKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(newKey),
-	})
+	newKeyPEM, err := marshalKey(newKey)
+	if err != nil {
+		return nil, nil, err
+	}
 	newCertPEM := pem.EncodeToMemory(&pem.Block{
 		Type:  "CERTIFICATE",
 		Bytes: newCert,
@@ -192,9 +408,9 @@ func newCert(caCertPEM, caKeyPEM []byte, expiry time.Time, service, namespace, d
 
 // newCA generates a new CA, given the CA's CN and an expiry time.
 // The return order is cacert, cakey, error.
-func newCA(cn string, expiry time.Time) ([]byte, []byte, error) {
+func newCA(cn string, expiry time.Time, spec keySpec) ([]byte, []byte, error) {
 
-	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	key, err := spec.generate()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -209,12 +425,15 @@ func newCA(cn string, expiry time.Time) ([]byte, []byte, error) {
 		},
 		NotBefore:             now.UTC().AddDate(0, 0, -1),
 		NotAfter:              expiry.UTC(),
-		SubjectKeyId:          bigIntHash(key.N),
+		SubjectKeyId:          subjectKeyID(key.Public()),
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		IsCA:                  true,
 		BasicConstraintsValid: true,
 	}
-	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if spec.keyType == ECDSAKeyType {
+		template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -222,10 +441,10 @@ func newCA(cn string, expiry time.Time) ([]byte, []byte, error) {
 		Type:  "CERTIFICATE",
 		Bytes: certDER,
 	})
-	keyPEMData := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(key),
-	})
+	keyPEMData, err := marshalKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
 	return certPEMData, keyPEMData, nil
 }
 
@@ -247,6 +466,21 @@ func bigIntHash(n *big.Int) []byte {
 	return h.Sum(nil)
 }
 
+// subjectAltNames returns the DNS and IP Subject Alternative Names of
+// the certificate for service, which are its service names and sans.
+func subjectAltNames(service, namespace, dnsname string, sans []string) ([]string, []net.IP) {
+	dnsNames := serviceNames(service, namespace, dnsname)
+	var ips []net.IP
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			dnsNames = append(dnsNames, san)
+		}
+	}
+	return dnsNames, ips
+}
+
 func serviceNames(service, namespace, dnsname string) []string {
 	return []string{
 		service,
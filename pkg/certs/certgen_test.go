@@ -112,6 +112,54 @@ func TestGenerateCerts(t *testing.T) {
 		wantEnvoyDNSName:   "envoy",
 		wantError:          nil,
 	})
+
+	run(t, "ecdsa keys", testcase{
+		config: &Configuration{
+			KeyType: ECDSAKeyType,
+			KeySize: 384,
+		},
+		wantContourDNSName: "contour",
+		wantEnvoyDNSName:   "envoy",
+		wantError:          nil,
+	})
+
+	run(t, "additional SANs", testcase{
+		config: &Configuration{
+			ContourSANs: []string{"contour.example.com"},
+			EnvoySANs:   []string{"envoy.example.com", "192.0.2.1"},
+		},
+		wantContourDNSName: "contour.example.com",
+		wantEnvoyDNSName:   "envoy.example.com",
+		wantError:          nil,
+	})
+}
+
+func TestGenerateCertsKeyType(t *testing.T) {
+	got, err := GenerateCerts(&Configuration{KeyType: ECDSAKeyType})
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(got.EnvoyPrivateKey)
+	require.NotNil(t, block)
+	assert.Equal(t, "EC PRIVATE KEY", block.Type)
+
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, 256, key.Curve.Params().BitSize)
+
+	block, _ = pem.Decode(got.EnvoyCertificate)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, x509.ECDSA, cert.PublicKeyAlgorithm)
+
+	_, err = GenerateCerts(&Configuration{KeyType: ECDSAKeyType, KeySize: 2048})
+	assert.Error(t, err)
+
+	_, err = GenerateCerts(&Configuration{KeyType: RSAKeyType, KeySize: 1024})
+	assert.Error(t, err)
+
+	_, err = GenerateCerts(&Configuration{KeyType: "dsa"})
+	assert.Error(t, err)
 }
 
 func TestGeneratedCertsValid(t *testing.T) {
@@ -119,17 +167,19 @@ func TestGeneratedCertsValid(t *testing.T) {
 	now := time.Now()
 	expiry := now.Add(24 * 365 * time.Hour)
 
-	cacert, cakey, err := newCA("contour", expiry)
+	spec := keySpec{keyType: RSAKeyType, size: keySize}
+
+	cacert, cakey, err := newCA("contour", expiry, spec)
 	require.NoErrorf(t, err, "Failed to generate CA cert")
 
-	contourcert, _, err := newCert(cacert, cakey, expiry, "contour", "projectcontour", "cluster.local")
+	contourcert, _, err := newCert(cacert, cakey, expiry, spec, "contour", "projectcontour", "cluster.local", nil)
 	require.NoErrorf(t, err, "Failed to generate Contour cert")
 
 	roots := x509.NewCertPool()
 	ok := roots.AppendCertsFromPEM(cacert)
 	require.Truef(t, ok, "Failed to set up CA cert for testing, maybe it's an invalid PEM")
 
-	envoycert, _, err := newCert(cacert, cakey, expiry, "envoy", "projectcontour", "cluster.local")
+	envoycert, _, err := newCert(cacert, cakey, expiry, spec, "envoy", "projectcontour", "cluster.local", nil)
 	require.NoErrorf(t, err, "Failed to generate Envoy cert")
 
 	tests := map[string]struct {
@@ -177,3 +227,38 @@ func verifyCert(certPEM []byte, roots *x509.CertPool, dnsname string, currentTim
 
 	return nil
 }
+
+func TestGenerateCertificateRequests(t *testing.T) {
+	got, err := GenerateCertificateRequests(&Configuration{
+		Namespace: "custom",
+		EnvoySANs: []string{"envoy.example.com", "192.0.2.1"},
+	})
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(got.ContourRequest)
+	require.NotNil(t, block)
+	assert.Equal(t, "CERTIFICATE REQUEST", block.Type)
+
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	require.NoError(t, err)
+	require.NoError(t, req.CheckSignature())
+	assert.Equal(t, "contour", req.Subject.CommonName)
+	assert.Equal(t, []string{
+		"contour",
+		"contour.custom",
+		"contour.custom.svc",
+		"contour.custom.svc.cluster.local",
+	}, req.DNSNames)
+
+	block, _ = pem.Decode(got.EnvoyRequest)
+	require.NotNil(t, block)
+	req, err = x509.ParseCertificateRequest(block.Bytes)
+	require.NoError(t, err)
+	assert.Contains(t, req.DNSNames, "envoy.example.com")
+	require.Len(t, req.IPAddresses, 1)
+	assert.Equal(t, "192.0.2.1", req.IPAddresses[0].String())
+
+	block, _ = pem.Decode(got.EnvoyPrivateKey)
+	require.NotNil(t, block)
+	assert.Equal(t, "RSA PRIVATE KEY", block.Type)
+}
@@ -114,4 +114,6 @@ var envoyComplexOperators = map[string]struct{}{
 	"START_TIME":        {},
 	"TRAILER":           {},
 	"REQ_WITHOUT_QUERY": {},
+	"DYNAMIC_METADATA":  {},
+	"FILTER_STATE":      {},
 }
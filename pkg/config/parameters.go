@@ -18,12 +18,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/validation"
 )
 
@@ -109,6 +111,29 @@ const AutoClusterDNSFamily ClusterDNSFamilyType = "auto"
 const IPv4ClusterDNSFamily ClusterDNSFamilyType = "v4"
 const IPv6ClusterDNSFamily ClusterDNSFamilyType = "v6"
 
+// InsecureListenerMode sets what the default HTTP listener serves.
+type InsecureListenerMode string
+
+func (m InsecureListenerMode) Validate() error {
+	switch m {
+	case "", InsecureListenerEnabled, InsecureListenerRedirect, InsecureListenerDisabled:
+		return nil
+	default:
+		return fmt.Errorf("invalid insecure listener mode %q", m)
+	}
+}
+
+// InsecureListenerEnabled serves the virtual hosts of the
+// default HTTP listener as they are configured.
+const InsecureListenerEnabled InsecureListenerMode = "enabled"
+
+// InsecureListenerRedirect only serves redirects to HTTPS on the
+// default HTTP listener, for the virtual hosts served over TLS.
+const InsecureListenerRedirect InsecureListenerMode = "redirect"
+
+// InsecureListenerDisabled does not create the default HTTP listener.
+const InsecureListenerDisabled InsecureListenerMode = "disabled"
+
 // AccessLogType is the name of a supported access logging mechanism.
 type AccessLogType string
 
@@ -223,10 +248,32 @@ func parseAccessLogFormat(format string) error {
 			return fmt.Errorf("invalid Envoy format: %s, invalid Envoy operator: %s", f, op)
 		}
 
-		if (op == "REQ" || op == "RESP" || op == "TRAILER" || op == "REQ_WITHOUT_QUERY") && f[3] == "" {
+		if (op == "REQ" || op == "RESP" || op == "TRAILER" || op == "REQ_WITHOUT_QUERY" ||
+			op == "DYNAMIC_METADATA" || op == "FILTER_STATE") && f[3] == "" {
 			return fmt.Errorf("invalid Envoy format: %s, arguments required for operator: %s", f, op)
 		}
 
+		// DYNAMIC_METADATA takes a filter namespace followed by an optional
+		// path of keys, none of which may be empty.
+		if op == "DYNAMIC_METADATA" {
+			for _, key := range strings.Split(strings.Trim(f[3], "()"), ":") {
+				if key == "" {
+					return fmt.Errorf("invalid Envoy format: %s, empty metadata key for operator: %s", f, op)
+				}
+			}
+		}
+
+		// FILTER_STATE takes a key and an optional PLAIN or TYPED serialization.
+		if op == "FILTER_STATE" {
+			args := strings.SplitN(strings.Trim(f[3], "()"), ":", 2)
+			if args[0] == "" {
+				return fmt.Errorf("invalid Envoy format: %s, empty filter state key for operator: %s", f, op)
+			}
+			if len(args) == 2 && args[1] != "PLAIN" && args[1] != "TYPED" {
+				return fmt.Errorf("invalid Envoy format: %s, invalid serialization %q for operator: %s", f, args[1], op)
+			}
+		}
+
 		// START_TIME cannot not have truncation length.
 		if op == "START_TIME" && f[4] != "" {
 			return fmt.Errorf("invalid Envoy format: %s, operator %s cannot have truncation length", f, op)
@@ -256,15 +303,19 @@ func (p Parameters) AccessLogFormatterExtensions() []string {
 	extensionsMap := make(map[string]bool)
 	switch p.AccessLogFormat {
 	case EnvoyAccessLog:
-		if contains(p.AccessLogFormatString, "REQ_WITHOUT_QUERY") {
-			extensionsMap["envoy.formatter.req_without_query"] = true
-		}
-	case JSONAccessLog:
-		for _, f := range p.AccessLogFields.AsFieldMap() {
+		for _, f := range []string{p.AccessLogFormatString, p.TCPAccessLogFormatString} {
 			if contains(f, "REQ_WITHOUT_QUERY") {
 				extensionsMap["envoy.formatter.req_without_query"] = true
 			}
 		}
+	case JSONAccessLog:
+		for _, fields := range []AccessLogFields{p.AccessLogFields, p.TCPAccessLogFields} {
+			for _, f := range fields.AsFieldMap() {
+				if contains(f, "REQ_WITHOUT_QUERY") {
+					extensionsMap["envoy.formatter.req_without_query"] = true
+				}
+			}
+		}
 	}
 
 	var extensions []string
@@ -334,6 +385,37 @@ type TLSParameters struct {
 	// by advanced users. Note that these will be ignored when TLS 1.3 is in
 	// use.
 	CipherSuites TLSCiphers `yaml:"cipher-suites,omitempty"`
+
+	// SPIFFE configures Envoy to obtain the client certificate
+	// and trust bundle for TLS connections to upstream clusters
+	// from a SPIFFE Workload API rather than from Kubernetes
+	// secrets.
+	SPIFFE SPIFFEParameters `yaml:"spiffe,omitempty"`
+}
+
+// SPIFFEParameters holds the names of the SPIFFE identities that
+// Envoy requests from the SPIFFE Workload API over SDS. The Workload
+// API itself is configured with `contour bootstrap`.
+type SPIFFEParameters struct {
+	// ID is the SPIFFE ID of the X.509 SVID that Envoy presents
+	// as its client certificate to upstream clusters.
+	ID string `yaml:"id,omitempty"`
+
+	// TrustDomain is the SPIFFE ID of the trust domain whose
+	// bundle Envoy uses to validate upstream clusters that have
+	// no other upstream validation.
+	TrustDomain string `yaml:"trust-domain,omitempty"`
+}
+
+// Validate that SPIFFE IDs are well formed.
+func (s SPIFFEParameters) Validate() error {
+	for _, id := range []string{s.ID, s.TrustDomain} {
+		if id != "" && !strings.HasPrefix(id, "spiffe://") {
+			return fmt.Errorf("%q is not a SPIFFE ID", id)
+		}
+	}
+
+	return nil
 }
 
 // Validate TLS fallback certificate, client certificate, and cipher suites
@@ -351,6 +433,14 @@ func (t TLSParameters) Validate() error {
 		return fmt.Errorf("invalid TLS cipher suites: %w", err)
 	}
 
+	if err := t.SPIFFE.Validate(); err != nil {
+		return fmt.Errorf("invalid TLS SPIFFE configuration: %w", err)
+	}
+
+	if t.SPIFFE.ID != "" && len(strings.TrimSpace(t.ClientCertificate.Name)) > 0 {
+		return errors.New("invalid TLS SPIFFE configuration: id cannot be used with envoy-client-certificate")
+	}
+
 	return nil
 }
 
@@ -359,6 +449,76 @@ type ServerParameters struct {
 	// Defines the XDSServer to use for `contour serve`.
 	// Defaults to "contour"
 	XDSServerType ServerType `yaml:"xds-server-type,omitempty"`
+
+	// XDSDelta configures Envoy to request routes and endpoints
+	// with the incremental (delta) variant of the xDS protocol.
+	// Envoy must also be bootstrapped with --xds-delta so that
+	// listeners and clusters are requested the same way.
+	XDSDelta bool `yaml:"xds-delta,omitempty"`
+
+	// VHDS configures Envoy to fetch the virtual hosts of HTTP
+	// requests on demand, rather than receiving every virtual host
+	// with the route configuration. VHDS is only served by the
+	// contour xDS server.
+	VHDS bool `yaml:"vhds,omitempty"`
+
+	// Nodes, if not empty, lists the Envoy nodes that are authorized
+	// to stream configuration from the xDS server. If empty, every
+	// Envoy that can connect to the xDS server is authorized.
+	Nodes []XDSNodeParameters `yaml:"nodes,omitempty"`
+}
+
+// XDSNodeParameters authorizes the Envoy nodes whose ID matches the
+// ID pattern to stream configuration from the xDS server.
+type XDSNodeParameters struct {
+	// ID is a shell pattern, such as "envoy-internal-*", that matches
+	// the node IDs of the Envoys this entry applies to.
+	ID string `yaml:"id"`
+
+	// SubjectAltNames are the DNS or URI subject alternative names,
+	// one of which the client certificate of the Envoy must have.
+	// If empty, the client certificate is not checked.
+	SubjectAltNames []string `yaml:"subject-alt-names,omitempty"`
+
+	// SecretNamespaces are the namespaces of the secrets the Envoy
+	// may receive. If empty, the Envoy receives every secret. Secret
+	// namespaces require the contour xDS server.
+	SecretNamespaces []string `yaml:"secret-namespaces,omitempty"`
+}
+
+// Validate ensures that the node parameters are valid.
+func (n XDSNodeParameters) Validate() error {
+	if n.ID == "" {
+		return errors.New("node id must be specified")
+	}
+
+	if _, err := path.Match(n.ID, ""); err != nil {
+		return fmt.Errorf("invalid node id pattern %q: %w", n.ID, err)
+	}
+
+	return nil
+}
+
+// Validate ensures that the server configuration is valid.
+func (s ServerParameters) Validate() error {
+	if err := s.XDSServerType.Validate(); err != nil {
+		return err
+	}
+
+	for _, node := range s.Nodes {
+		if err := node.Validate(); err != nil {
+			return err
+		}
+		if len(node.SecretNamespaces) > 0 && s.XDSServerType != ContourServerType {
+			return fmt.Errorf("node %q: secret-namespaces requires the %q xDS server type", node.ID, ContourServerType)
+		}
+	}
+
+	if s.VHDS && s.XDSServerType != ContourServerType {
+		return fmt.Errorf("vhds requires the %q xDS server type", ContourServerType)
+	}
+
+	return nil
 }
 
 // GatewayParameters holds the configuration for Gateway API controllers.
@@ -444,40 +604,27 @@ type TimeoutParameters struct {
 
 // Validate the timeout parameters.
 func (t TimeoutParameters) Validate() error {
-	// We can't use `timeout.Parse` for validation here because
-	// that would make an exported package depend on an internal
-	// package.
-	v := func(str string) error {
-		switch str {
-		case "", "infinity", "infinite":
-			return nil
-		default:
-			_, err := time.ParseDuration(str)
-			return err
-		}
-	}
-
-	if err := v(t.RequestTimeout); err != nil {
+	if err := validateTimeout(t.RequestTimeout); err != nil {
 		return fmt.Errorf("invalid request timeout %q: %w", t.RequestTimeout, err)
 	}
 
-	if err := v(t.ConnectionIdleTimeout); err != nil {
+	if err := validateTimeout(t.ConnectionIdleTimeout); err != nil {
 		return fmt.Errorf("connection idle timeout %q: %w", t.ConnectionIdleTimeout, err)
 	}
 
-	if err := v(t.StreamIdleTimeout); err != nil {
+	if err := validateTimeout(t.StreamIdleTimeout); err != nil {
 		return fmt.Errorf("stream idle timeout %q: %w", t.StreamIdleTimeout, err)
 	}
 
-	if err := v(t.MaxConnectionDuration); err != nil {
+	if err := validateTimeout(t.MaxConnectionDuration); err != nil {
 		return fmt.Errorf("max connection duration %q: %w", t.MaxConnectionDuration, err)
 	}
 
-	if err := v(t.DelayedCloseTimeout); err != nil {
+	if err := validateTimeout(t.DelayedCloseTimeout); err != nil {
 		return fmt.Errorf("delayed close timeout %q: %w", t.DelayedCloseTimeout, err)
 	}
 
-	if err := v(t.ConnectionShutdownGracePeriod); err != nil {
+	if err := validateTimeout(t.ConnectionShutdownGracePeriod); err != nil {
 		return fmt.Errorf("connection shutdown grace period %q: %w", t.ConnectionShutdownGracePeriod, err)
 	}
 
@@ -503,6 +650,111 @@ func (h HeadersPolicy) Validate() error {
 	return nil
 }
 
+// validateTimeout returns an error if str is not a valid timeout.
+// We can't use `timeout.Parse` for validation here because that
+// would make an exported package depend on an internal package.
+func validateTimeout(str string) error {
+	switch str {
+	case "", "infinity", "infinite":
+		return nil
+	default:
+		_, err := time.ParseDuration(str)
+		return err
+	}
+}
+
+// retryOnConditions are the conditions a RetryPolicy may retry on.
+var retryOnConditions = map[string]bool{
+	"5xx":                    true,
+	"gateway-error":          true,
+	"reset":                  true,
+	"connect-failure":        true,
+	"retriable-4xx":          true,
+	"refused-stream":         true,
+	"retriable-status-codes": true,
+	"retriable-headers":      true,
+	"cancelled":              true,
+	"deadline-exceeded":      true,
+	"internal":               true,
+	"resource-exhausted":     true,
+	"unavailable":            true,
+}
+
+// RetryPolicy is the retry policy of the HTTPProxy
+// routes that do not set their own.
+type RetryPolicy struct {
+	// NumRetries is the maximum number of retries.
+	// If not set, a request is retried once.
+	NumRetries uint32 `yaml:"count,omitempty"`
+
+	// PerTryTimeout is the timeout of each retry attempt.
+	PerTryTimeout string `yaml:"per-try-timeout,omitempty"`
+
+	// RetryOn are the conditions on which requests are
+	// retried. If not set, requests are retried on 5xx.
+	RetryOn []string `yaml:"retry-on,omitempty"`
+
+	// RetriableStatusCodes are the HTTP status codes
+	// retried on the retriable-status-codes condition.
+	RetriableStatusCodes []uint32 `yaml:"retriable-status-codes,omitempty"`
+}
+
+// Validate ensures that the retry policy is valid.
+func (r *RetryPolicy) Validate() error {
+	if r == nil {
+		return nil
+	}
+
+	if r.PerTryTimeout != "" {
+		if _, err := time.ParseDuration(r.PerTryTimeout); err != nil {
+			return fmt.Errorf("invalid per-try timeout %q: %w", r.PerTryTimeout, err)
+		}
+	}
+
+	for _, cond := range r.RetryOn {
+		if !retryOnConditions[cond] {
+			return fmt.Errorf("invalid retry-on condition %q", cond)
+		}
+	}
+
+	for _, code := range r.RetriableStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid retriable status code %d", code)
+		}
+	}
+
+	return nil
+}
+
+// TimeoutPolicy is the timeout policy of the HTTPProxy
+// routes that do not set their own.
+type TimeoutPolicy struct {
+	// Response is the timeout for receiving a response
+	// from the upstream after the request is sent.
+	Response string `yaml:"response,omitempty"`
+
+	// Idle is the timeout after which an idle
+	// request stream is closed.
+	Idle string `yaml:"idle,omitempty"`
+}
+
+// Validate ensures that the timeout policy is valid.
+func (t *TimeoutPolicy) Validate() error {
+	if t == nil {
+		return nil
+	}
+
+	if err := validateTimeout(t.Response); err != nil {
+		return fmt.Errorf("invalid response timeout %q: %w", t.Response, err)
+	}
+
+	if err := validateTimeout(t.Idle); err != nil {
+		return fmt.Errorf("invalid idle timeout %q: %w", t.Idle, err)
+	}
+
+	return nil
+}
+
 // PolicyParameters holds default policy used if not explicitly set by the user
 type PolicyParameters struct {
 	// RequestHeadersPolicy defines the request headers set/removed on all routes
@@ -510,14 +762,28 @@ type PolicyParameters struct {
 
 	// ResponseHeadersPolicy defines the response headers set/removed on all routes
 	ResponseHeadersPolicy HeadersPolicy `yaml:"response-headers,omitempty"`
+
+	// RetryPolicy is the retry policy of the HTTPProxy
+	// routes that do not set their own.
+	RetryPolicy *RetryPolicy `yaml:"retry-policy,omitempty"`
+
+	// TimeoutPolicy is the timeout policy of the HTTPProxy
+	// routes that do not set their own.
+	TimeoutPolicy *TimeoutPolicy `yaml:"timeout-policy,omitempty"`
 }
 
-// Validate the header parameters.
+// Validate the header, retry and timeout parameters.
 func (h PolicyParameters) Validate() error {
 	if err := h.RequestHeadersPolicy.Validate(); err != nil {
 		return err
 	}
-	return h.ResponseHeadersPolicy.Validate()
+	if err := h.ResponseHeadersPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := h.RetryPolicy.Validate(); err != nil {
+		return err
+	}
+	return h.TimeoutPolicy.Validate()
 }
 
 // ClusterParameters holds various configurable cluster values.
@@ -534,6 +800,63 @@ type ClusterParameters struct {
 	// See https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/cluster/v3/cluster.proto.html#envoy-v3-api-enum-config-cluster-v3-cluster-dnslookupfamily
 	// for more information.
 	DNSLookupFamily ClusterDNSFamilyType `yaml:"dns-lookup-family"`
+
+	// TCPKeepalive configures TCP keepalive probes on upstream
+	// connections, so that idle connections are not silently
+	// dropped by NAT devices between Envoy and the upstream.
+	// Services may override this with the projectcontour.io/tcp-keepalive-*
+	// annotations.
+	TCPKeepalive *TCPKeepaliveParameters `yaml:"tcp-keepalive,omitempty"`
+
+	// ZoneAwareRouting configures Envoy to prefer upstream endpoints
+	// in its own zone, to minimize cross-zone traffic. Endpoint
+	// localities are taken from the topology labels of the nodes
+	// that their pods run on.
+	ZoneAwareRouting *ZoneAwareRoutingParameters `yaml:"zone-aware-routing,omitempty"`
+}
+
+// ZoneAwareRoutingParameters holds the zone-aware routing settings
+// for upstream clusters.
+//
+// Envoy must be started with --service-zone, and with a local
+// cluster describing the Envoy deployment, for zone-aware routing
+// to take effect.
+//
+// See https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/upstream/load_balancing/zone_aware
+// for more information.
+type ZoneAwareRoutingParameters struct {
+	// Enabled enables zone-aware routing.
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// MinClusterSize is the minimum number of upstream endpoints
+	// for zone-aware routing to be used. If zero, the Envoy
+	// default of 6 applies.
+	MinClusterSize uint64 `yaml:"min-cluster-size,omitempty"`
+
+	// OverprovisioningFactor is the overprovisioning factor, as a
+	// percentage, applied to the endpoints of each cluster. Traffic
+	// only spills out of a zone once fewer than 100/factor of its
+	// endpoints are healthy. If zero, the Envoy default of 140
+	// applies.
+	OverprovisioningFactor uint32 `yaml:"overprovisioning-factor,omitempty"`
+}
+
+// TCPKeepaliveParameters holds the TCP keepalive probe settings for
+// upstream connections. Zero values use the operating system default.
+//
+// See https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/core/v3/address.proto#config-core-v3-tcpkeepalive
+// for more information.
+type TCPKeepaliveParameters struct {
+	// Probes is the number of unanswered probes sent before
+	// the connection is considered dead.
+	Probes uint32 `yaml:"probes,omitempty"`
+
+	// Time is the number of seconds a connection must be idle
+	// before probes are sent.
+	Time uint32 `yaml:"time,omitempty"`
+
+	// Interval is the number of seconds between probes.
+	Interval uint32 `yaml:"interval,omitempty"`
 }
 
 // NetworkParameters hold various configurable network values.
@@ -545,6 +868,74 @@ type NetworkParameters struct {
 	// See https://www.envoyproxy.io/docs/envoy/v1.17.0/api-v3/extensions/filters/network/http_connection_manager/v3/http_connection_manager.proto?highlight=xff_num_trusted_hops
 	// for more information.
 	XffNumTrustedHops uint32 `yaml:"num-trusted-hops"`
+
+	// SkipXffAppend disables appending the client's IP address to the
+	// x-forwarded-for HTTP header. This is useful when Contour is behind
+	// another proxy that has already set the header.
+	//
+	// See https://www.envoyproxy.io/docs/envoy/v1.17.0/api-v3/extensions/filters/network/http_connection_manager/v3/http_connection_manager.proto?highlight=skip_xff_append
+	// for more information.
+	SkipXffAppend bool `yaml:"skip-xff-append"`
+
+	// RequestID defines how the x-request-id HTTP header of requests
+	// is set.
+	RequestID RequestIDParameters `yaml:"request-id,omitempty"`
+}
+
+// RequestIDPolicy defines whether Envoy keeps the x-request-id
+// header of requests from external clients.
+type RequestIDPolicy string
+
+func (r RequestIDPolicy) Validate() error {
+	switch r {
+	case "", GenerateRequestIDPolicy, PreserveRequestIDPolicy:
+		return nil
+	default:
+		return fmt.Errorf("invalid request id policy %q", r)
+	}
+}
+
+// GenerateRequestIDPolicy always replaces the x-request-id header of
+// requests from external clients with a generated ID.
+const GenerateRequestIDPolicy RequestIDPolicy = "generate"
+
+// PreserveRequestIDPolicy keeps the x-request-id header of requests
+// from external clients, and only generates an ID if it is missing.
+const PreserveRequestIDPolicy RequestIDPolicy = "preserve"
+
+// RequestIDParameters holds the configuration of the x-request-id
+// header.
+type RequestIDParameters struct {
+	// Policy defines whether the x-request-id header of requests from
+	// external clients is preserved or replaced. Defaults to "preserve".
+	//
+	// See https://www.envoyproxy.io/docs/envoy/v1.19.0/api-v3/extensions/filters/network/http_connection_manager/v3/http_connection_manager.proto#envoy-v3-api-field-extensions-filters-network-http-connection-manager-v3-httpconnectionmanager-preserve-external-request-id
+	// for more information.
+	Policy RequestIDPolicy `yaml:"policy,omitempty"`
+
+	// Header is the name of a request header whose value, if present,
+	// is used as the x-request-id of the request. This allows request
+	// IDs assigned by a CDN in front of Envoy to be used as the
+	// request ID.
+	Header string `yaml:"header,omitempty"`
+}
+
+// Validate ensures that the request id parameters are valid.
+func (r RequestIDParameters) Validate() error {
+	if err := r.Policy.Validate(); err != nil {
+		return err
+	}
+
+	if r.Header != "" {
+		if msgs := validation.IsHTTPHeaderName(r.Header); len(msgs) != 0 {
+			return fmt.Errorf("invalid request id header %q: %s", r.Header, strings.Join(msgs, ", "))
+		}
+		if strings.EqualFold(r.Header, "x-request-id") {
+			return errors.New("request id header must not be x-request-id")
+		}
+	}
+
+	return nil
 }
 
 // ListenerParameters hold various configurable listener values.
@@ -553,6 +944,193 @@ type ListenerParameters struct {
 	// See https://www.envoyproxy.io/docs/envoy/latest/api-v2/api/v2/listener.proto#envoy-api-msg-listener-connectionbalanceconfig
 	// for more information.
 	ConnectionBalancer string `yaml:"connection-balancer"`
+
+	// ReusePort sets SO_REUSEPORT on the listener sockets, so that
+	// each Envoy worker thread accepts connections on its own socket.
+	ReusePort bool `yaml:"reuse-port,omitempty"`
+
+	// TCPFastOpenQueueLength enables TCP fast open on the listeners,
+	// allowing this many pending fast open requests. TCP fast open is
+	// disabled if zero.
+	TCPFastOpenQueueLength uint32 `yaml:"tcp-fast-open-queue-length,omitempty"`
+
+	// Backlog is the maximum length of the queue of pending
+	// connections of the listener sockets. If zero, Envoy's default
+	// is used.
+	Backlog uint32 `yaml:"backlog,omitempty"`
+
+	// Overrides overrides the connection balancer and socket options
+	// of individual listeners, keyed by listener name.
+	Overrides map[string]ListenerOverrides `yaml:"overrides,omitempty"`
+
+	// AcceptHTTP10 enables support for HTTP/1.0 requests on the
+	// HTTP connection managers. It is disabled by default.
+	//
+	// See https://www.envoyproxy.io/docs/envoy/v1.17.0/api-v3/config/core/v3/protocol.proto#envoy-v3-api-field-config-core-v3-http1protocoloptions-accept-http-10
+	// for more information.
+	AcceptHTTP10 bool `yaml:"accept-http-10,omitempty"`
+
+	// DefaultHostForHTTP10 is the Host header value used for HTTP/1.0
+	// requests that do not supply one. It requires AcceptHTTP10.
+	DefaultHostForHTTP10 string `yaml:"default-host-for-http-10,omitempty"`
+
+	// TCPListeners defines additional listeners that forward plain
+	// TCP connections to the services of the HTTPProxy that selects
+	// the listener's port.
+	TCPListeners []TCPListener `yaml:"tcp-listeners,omitempty"`
+
+	// HTTPListeners defines additional plain HTTP listeners. They
+	// serve the virtual hosts of the HTTPProxies that select them.
+	HTTPListeners []HTTPListener `yaml:"http-listeners,omitempty"`
+
+	// HTTPSListeners defines additional HTTPS listeners. They serve
+	// the TLS virtual hosts of the HTTPProxies that select them.
+	HTTPSListeners []HTTPListener `yaml:"https-listeners,omitempty"`
+
+	// InsecureListener sets what the default HTTP listener serves.
+	// If "redirect", it only redirects requests to HTTPS, and only
+	// for the virtual hosts that are served over TLS. If "disabled",
+	// the listener is not created. Defaults to "enabled".
+	InsecureListener InsecureListenerMode `yaml:"insecure-listener,omitempty"`
+
+	// MirrorPort is the port of the loopback listener through which
+	// Envoy sets the request headers of mirrored requests. It must
+	// not be used by any other listener. Defaults to 8009.
+	MirrorPort int `yaml:"mirror-port,omitempty"`
+}
+
+// ListenerOverrides overrides the connection balancer and socket
+// options of a listener. Unset fields use the values configured
+// for all listeners.
+type ListenerOverrides struct {
+	// ConnectionBalancer is either exact, to use the exact
+	// connection balancer, or none, to not balance connections.
+	ConnectionBalancer string `yaml:"connection-balancer,omitempty"`
+
+	// ReusePort sets SO_REUSEPORT on the listener socket.
+	ReusePort *bool `yaml:"reuse-port,omitempty"`
+
+	// TCPFastOpenQueueLength sets the TCP fast open queue length of
+	// the listener. Zero disables TCP fast open.
+	TCPFastOpenQueueLength *uint32 `yaml:"tcp-fast-open-queue-length,omitempty"`
+
+	// Backlog sets the maximum length of the queue of pending
+	// connections of the listener socket.
+	Backlog *uint32 `yaml:"backlog,omitempty"`
+}
+
+// HTTPListener defines an additional HTTP or HTTPS listener.
+type HTTPListener struct {
+	// Name is the name of the Envoy listener, which HTTPProxies
+	// use to select it.
+	Name string `yaml:"name"`
+
+	// Address is the address to bind. Defaults to 0.0.0.0.
+	Address string `yaml:"address,omitempty"`
+
+	// Port is the port to bind.
+	Port int `yaml:"port"`
+}
+
+// TCPListener defines an additional plain TCP listener.
+type TCPListener struct {
+	// Name is the name of the Envoy listener.
+	Name string `yaml:"name"`
+
+	// Address is the address to bind. Defaults to 0.0.0.0.
+	Address string `yaml:"address,omitempty"`
+
+	// Port is the port to bind.
+	Port int `yaml:"port"`
+}
+
+// Validate ensures that the listener parameters are consistent.
+func (l ListenerParameters) Validate() error {
+	if l.DefaultHostForHTTP10 != "" && !l.AcceptHTTP10 {
+		return errors.New("default-host-for-http-10 requires accept-http-10 to be enabled")
+	}
+
+	if err := l.InsecureListener.Validate(); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	ports := map[int]bool{}
+	for _, t := range l.TCPListeners {
+		switch t.Name {
+		case "":
+			return errors.New("tcp listener name must be specified")
+		case "ingress_http", "ingress_https", "ingress_mirror":
+			return fmt.Errorf("tcp listener name %q is reserved", t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate tcp listener name %q", t.Name)
+		}
+		names[t.Name] = true
+
+		if t.Port < 1 || t.Port > 65535 {
+			return fmt.Errorf("invalid tcp listener port %d", t.Port)
+		}
+		if ports[t.Port] {
+			return fmt.Errorf("duplicate tcp listener port %d", t.Port)
+		}
+		ports[t.Port] = true
+	}
+
+	for _, kind := range []struct {
+		name      string
+		listeners []HTTPListener
+	}{
+		{"http", l.HTTPListeners},
+		{"https", l.HTTPSListeners},
+	} {
+		for _, h := range kind.listeners {
+			switch {
+			case h.Name == "":
+				return fmt.Errorf("%s listener name must be specified", kind.name)
+			case h.Name == "ingress_http", h.Name == "ingress_https", h.Name == "ingress_mirror":
+				return fmt.Errorf("%s listener name %q is reserved", kind.name, h.Name)
+			case strings.Contains(h.Name, "/"):
+				return fmt.Errorf("%s listener name %q must not contain '/'", kind.name, h.Name)
+			}
+			if names[h.Name] {
+				return fmt.Errorf("duplicate %s listener name %q", kind.name, h.Name)
+			}
+			names[h.Name] = true
+
+			if h.Port < 1 || h.Port > 65535 {
+				return fmt.Errorf("invalid %s listener port %d", kind.name, h.Port)
+			}
+			if ports[h.Port] {
+				return fmt.Errorf("duplicate %s listener port %d", kind.name, h.Port)
+			}
+			ports[h.Port] = true
+		}
+	}
+
+	mirrorPort := l.MirrorPort
+	if mirrorPort == 0 {
+		mirrorPort = 8009
+	}
+	if mirrorPort < 0 || mirrorPort > 65535 {
+		return fmt.Errorf("invalid mirror listener port %d", mirrorPort)
+	}
+	if ports[mirrorPort] {
+		return fmt.Errorf("mirror listener port %d is used by another listener", mirrorPort)
+	}
+
+	for name, o := range l.Overrides {
+		if name != "ingress_http" && name != "ingress_https" && !names[name] {
+			return fmt.Errorf("listener overrides name %q is not a configured listener", name)
+		}
+		switch o.ConnectionBalancer {
+		case "", "exact", "none":
+		default:
+			return fmt.Errorf("invalid listener %q connection balancer %q", name, o.ConnectionBalancer)
+		}
+	}
+
+	return nil
 }
 
 // Parameters contains the configuration file parameters for the
@@ -577,6 +1155,11 @@ type Parameters struct {
 	// The value will be placed directly into the relevant field inside the status.loadBalancer struct.
 	IngressStatusAddress string `yaml:"ingress-status-address,omitempty"`
 
+	// PublishHostnameAnnotation sets the external-dns hostname
+	// annotation on each Ingress and HTTPProxy whose status.loadbalancer
+	// field is updated, listing the hostnames the object serves.
+	PublishHostnameAnnotation bool `yaml:"publish-hostname-annotation,omitempty"`
+
 	// AccessLogFormat sets the global access log format.
 	// Valid options are 'envoy' or 'json'
 	AccessLogFormat AccessLogType `yaml:"accesslog-format,omitempty"`
@@ -589,6 +1172,16 @@ type Parameters struct {
 	// output when AccessLogFormat is json.
 	AccessLogFields AccessLogFields `yaml:"json-fields,omitempty"`
 
+	// TCPAccessLogFormatString sets the access log format of TCP
+	// proxies when format is set to `envoy`. When empty,
+	// AccessLogFormatString is used.
+	TCPAccessLogFormatString string `yaml:"tcp-accesslog-format-string,omitempty"`
+
+	// TCPAccessLogFields sets the fields that JSON logging will
+	// output for TCP proxies when AccessLogFormat is json.
+	// When empty, AccessLogFields is used.
+	TCPAccessLogFields AccessLogFields `yaml:"tcp-json-fields,omitempty"`
+
 	// TLS contains TLS policy parameters.
 	TLS TLSParameters `yaml:"tls,omitempty"`
 
@@ -596,6 +1189,22 @@ type Parameters struct {
 	// permitInsecure field in HTTPProxy.
 	DisablePermitInsecure bool `yaml:"disablePermitInsecure,omitempty"`
 
+	// PermitInsecure restricts the use of the permitInsecure
+	// field in HTTPProxy to the selected namespaces.
+	PermitInsecure PermitInsecureParameters `yaml:"permitInsecure,omitempty"`
+
+	// Lua allows the HTTPProxies in the selected namespaces
+	// to run Lua scripts on their virtual hosts and routes.
+	Lua LuaParameters `yaml:"lua,omitempty"`
+
+	// Tap allows the HTTPProxies in the selected namespaces
+	// to capture requests to their routes with tap policies.
+	Tap TapParameters `yaml:"tap,omitempty"`
+
+	// GeoIP configures the GeoIP databases that the country and
+	// autonomous system of client addresses are looked up in.
+	GeoIP GeoIPParameters `yaml:"geoip,omitempty"`
+
 	// DisableAllowChunkedLength disables the RFC-compliant Envoy behavior to
 	// strip the "Content-Length" header if "Transfer-Encoding: chunked" is
 	// also set. This is an emergency off-switch to revert back to Envoy's
@@ -609,6 +1218,16 @@ type Parameters struct {
 	// TODO(youngnick): put a link to the issue and CVE here.
 	EnableExternalNameService bool `yaml:"enableExternalNameService,omitempty"`
 
+	// EnableDynamicForwardProxy allows HTTPProxy routes to proxy requests
+	// to the host named by their Host header. Defaults to disabled, since
+	// such routes can reach any host that Envoy can resolve.
+	EnableDynamicForwardProxy bool `yaml:"enableDynamicForwardProxy,omitempty"`
+
+	// DefaultHTTPProxy defines the namespace/name of the root HTTPProxy
+	// that serves plain HTTP requests whose Host matches no other
+	// virtual host, instead of Envoy returning 404.
+	DefaultHTTPProxy NamespacedName `yaml:"default-httpproxy,omitempty"`
+
 	// LeaderElection contains leader election parameters.
 	LeaderElection LeaderElectionParameters `yaml:"leaderelection,omitempty"`
 
@@ -645,6 +1264,325 @@ type Parameters struct {
 	// RateLimitService optionally holds properties of the Rate Limit Service
 	// to be used for global rate limiting.
 	RateLimitService RateLimitService `yaml:"rateLimitService,omitempty"`
+
+	// Tracing optionally configures Envoy to trace the HTTP requests
+	// it proxies and export the spans to a collector.
+	Tracing *TracingConfig `yaml:"tracing,omitempty"`
+
+	// MaxRemovalPercent is the maximum percentage of routes or services
+	// that a single rebuild of Contour's configuration may remove. A
+	// rebuild that removes more is not sent to Envoy, and is logged
+	// until the removal is reverted or the limit raised. This protects
+	// against a control plane fault, or a mass deletion by mistake,
+	// blackholing all traffic.
+	//
+	// If not specified or 0, any number of routes and services may
+	// be removed.
+	MaxRemovalPercent int `yaml:"max-removal-percent,omitempty"`
+
+	// AuditEvents enables recording a Kubernetes Event on the
+	// HTTPProxy that configured a virtual host whenever the virtual
+	// host's routes, certificate, or clusters change. The changes
+	// are logged whether or not this is enabled.
+	AuditEvents bool `yaml:"audit-events,omitempty"`
+
+	// Holdoff configures how changes to Kubernetes resources
+	// are coalesced before Contour's configuration is rebuilt.
+	Holdoff HoldoffParameters `yaml:"holdoff,omitempty"`
+
+	// HTTPProxyWorkers is the number of root HTTPProxies that
+	// are processed concurrently when Contour's configuration is
+	// rebuilt. Raising it keeps rebuilds fast when there are
+	// thousands of root HTTPProxies.
+	//
+	// If not specified, 0 or 1, root HTTPProxies are processed
+	// one at a time.
+	HTTPProxyWorkers int `yaml:"httpproxy-workers,omitempty"`
+
+	// MaxIncludeDepth is the maximum number of HTTPProxies that
+	// may be followed through includes from a root HTTPProxy. An
+	// include that would exceed it is reported in the status of
+	// the including HTTPProxy.
+	//
+	// If not specified or 0, the include depth is not limited.
+	MaxIncludeDepth int `yaml:"max-include-depth,omitempty"`
+
+	// Watch restricts the HTTPProxies, Services, and Secrets
+	// that Contour reads to build its configuration.
+	Watch WatchParameters `yaml:"watch,omitempty"`
+
+	// Quotas limits the routing configuration that the
+	// HTTPProxies in each namespace may contribute.
+	Quotas QuotaParameters `yaml:"quotas,omitempty"`
+
+	// HTTPProxyPartialValidity keeps the valid routes of an HTTPProxy
+	// that has invalid routes or includes, which are dropped, instead
+	// of dropping all of its routes. The status of such an HTTPProxy
+	// is "partiallyvalid".
+	//
+	// If not specified, all routes of the HTTPProxy are dropped.
+	HTTPProxyPartialValidity bool `yaml:"httpproxy-partial-validity,omitempty"`
+
+	// StatusUpdates limits the rate at which Contour
+	// writes the status of objects to the API server.
+	StatusUpdates StatusUpdateParameters `yaml:"status-updates,omitempty"`
+
+	// Shard restricts the virtual hosts that Contour serves
+	// to those of the selected Ingresses and root HTTPProxies,
+	// so that they can be split across Contour instances.
+	Shard ShardParameters `yaml:"shard,omitempty"`
+
+	// Runtime holds Envoy runtime values, keyed by runtime key,
+	// that Contour serves with the runtime discovery service (RTDS).
+	// Values of "true" or "false" are set as booleans, and values
+	// that parse as numbers are set as numbers. Envoy only fetches
+	// them if its bootstrap configuration was generated with the
+	// --runtime-discovery flag.
+	Runtime map[string]string `yaml:"runtime,omitempty"`
+}
+
+// ShardParameters selects the Ingresses and root HTTPProxies whose
+// virtual hosts Contour serves. HTTPProxies included by a selected
+// root are served whatever their namespace and labels. Each shard
+// needs its own Contour instances, leader election lease, and Envoy
+// fleet, so that each fleet receives only the virtual hosts of its
+// shard.
+type ShardParameters struct {
+	// Namespaces, if not empty, are the only namespaces
+	// whose Ingresses and root HTTPProxies are served.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+
+	// Selector, if not empty, is a Kubernetes label selector,
+	// e.g. "environment=prod", that the Ingresses and root
+	// HTTPProxies must match to be served.
+	Selector string `yaml:"selector,omitempty"`
+}
+
+// Validate ensures that the shard parameters are valid.
+func (s ShardParameters) Validate() error {
+	if _, err := labels.Parse(s.Selector); err != nil {
+		return fmt.Errorf("invalid shard selector %q: %w", s.Selector, err)
+	}
+	return nil
+}
+
+// LuaParameters selects the namespaces whose HTTPProxies may run Lua
+// scripts. Lua scripts run in Envoy, with access to every request and
+// response of the virtual hosts and routes that use them, so they are
+// not permitted in any namespace unless it is listed.
+type LuaParameters struct {
+	// Namespaces are namespaces whose HTTPProxies
+	// may run Lua scripts.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+}
+
+// TapParameters selects the namespaces whose HTTPProxies may capture
+// requests with tap policies. Captured requests hold every header and
+// body, including credentials, so tap policies are not permitted in
+// any namespace unless it is listed.
+type TapParameters struct {
+	// Namespaces are namespaces whose HTTPProxies
+	// may capture requests with tap policies.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+
+	// FileDirectory is the directory of the Envoy pod that
+	// tap policies write captured requests to.
+	//
+	// If not specified, /tmp is used.
+	FileDirectory string `yaml:"fileDirectory,omitempty"`
+}
+
+// Validate ensures that the tap parameters are valid.
+func (p TapParameters) Validate() error {
+	if p.FileDirectory != "" && !path.IsAbs(p.FileDirectory) {
+		return fmt.Errorf("invalid tap file directory %q, must be an absolute path", p.FileDirectory)
+	}
+	return nil
+}
+
+// GeoIPParameters configures the MaxMind DB files that Contour looks
+// up the country and autonomous system of client addresses in, for
+// Envoy to set as request headers and match routes on.
+type GeoIPParameters struct {
+	// CountryDatabase is the path of a GeoIP2 or GeoLite2
+	// Country or City database.
+	//
+	// If not specified, countries are not looked up.
+	CountryDatabase string `yaml:"countryDatabase,omitempty"`
+
+	// ASNDatabase is the path of a GeoIP2 or GeoLite2
+	// ASN database.
+	//
+	// If not specified, autonomous systems are not looked up.
+	ASNDatabase string `yaml:"asnDatabase,omitempty"`
+}
+
+// Validate ensures that the GeoIP parameters are valid.
+func (p GeoIPParameters) Validate() error {
+	if p.CountryDatabase != "" && !path.IsAbs(p.CountryDatabase) {
+		return fmt.Errorf("invalid GeoIP country database %q, must be an absolute path", p.CountryDatabase)
+	}
+	if p.ASNDatabase != "" && !path.IsAbs(p.ASNDatabase) {
+		return fmt.Errorf("invalid GeoIP ASN database %q, must be an absolute path", p.ASNDatabase)
+	}
+	return nil
+}
+
+// PermitInsecureParameters selects the namespaces whose HTTPProxies
+// may use the permitInsecure field. If neither field is set, HTTPProxies
+// in any namespace may use it. Routes in other namespaces that set it
+// are not valid.
+type PermitInsecureParameters struct {
+	// Namespaces are namespaces whose HTTPProxies
+	// may use the permitInsecure field.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+
+	// Selector, if not empty, is a Kubernetes label selector,
+	// e.g. "legacy=true", that selects the namespaces whose
+	// HTTPProxies may use the permitInsecure field.
+	Selector string `yaml:"selector,omitempty"`
+}
+
+// Validate ensures that the permitInsecure parameters are valid.
+func (p PermitInsecureParameters) Validate() error {
+	if _, err := labels.Parse(p.Selector); err != nil {
+		return fmt.Errorf("invalid permitInsecure selector %q: %w", p.Selector, err)
+	}
+	return nil
+}
+
+// StatusUpdateParameters limits the rate at which Contour writes the
+// status of objects. Updates that would not change the status of an
+// object are never written, and do not count against the limit.
+type StatusUpdateParameters struct {
+	// QPS is the maximum sustained number of status
+	// updates written per second.
+	//
+	// If not specified or 0, status updates are not rate limited.
+	QPS float32 `yaml:"qps,omitempty"`
+
+	// Burst is the maximum number of status updates that may be
+	// written at once, above QPS. It is at least 1.
+	Burst int `yaml:"burst,omitempty"`
+}
+
+// Validate ensures that the status update parameters are valid.
+func (s StatusUpdateParameters) Validate() error {
+	if s.QPS < 0 {
+		return fmt.Errorf("invalid status update qps %v, must not be negative", s.QPS)
+	}
+	if s.Burst < 0 {
+		return fmt.Errorf("invalid status update burst %d, must not be negative", s.Burst)
+	}
+	return nil
+}
+
+// HoldoffParameters configures the coalescing of changes to
+// Kubernetes resources. Each change delays the rebuild of
+// Contour's configuration by Delay, so that a burst of changes,
+// such as endpoint churn during a rollout, causes a single
+// rebuild. A rebuild is never delayed once MaxDelay has passed
+// since the previous rebuild.
+type HoldoffParameters struct {
+	// Delay is how long to wait after a change
+	// for further changes before rebuilding.
+	Delay time.Duration `yaml:"delay,omitempty"`
+
+	// MaxDelay is the longest that a change waits for
+	// a rebuild while further changes keep arriving.
+	MaxDelay time.Duration `yaml:"max-delay,omitempty"`
+}
+
+// Validate ensures that the holdoff parameters are valid.
+func (h HoldoffParameters) Validate() error {
+	if h.Delay < 0 {
+		return fmt.Errorf("invalid holdoff delay %s, must not be negative", h.Delay)
+	}
+	if h.MaxDelay < h.Delay {
+		return fmt.Errorf("invalid holdoff max delay %s, must not be less than the holdoff delay %s", h.MaxDelay, h.Delay)
+	}
+	return nil
+}
+
+// WatchParameters restricts the HTTPProxies, Services, and Secrets
+// that Contour reads to build its configuration, reducing its memory
+// use in large clusters and the objects that can affect it.
+type WatchParameters struct {
+	// Namespaces, if not empty, are the only namespaces
+	// from which objects are read.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+
+	// ExcludeNamespaces are namespaces from
+	// which objects are never read.
+	ExcludeNamespaces []string `yaml:"exclude-namespaces,omitempty"`
+
+	// LabelSelector, if not empty, is a Kubernetes label
+	// selector, e.g. "team in (a,b)", that objects must
+	// match to be read.
+	LabelSelector string `yaml:"label-selector,omitempty"`
+}
+
+// Validate ensures that the watch parameters are valid.
+func (w WatchParameters) Validate() error {
+	for _, ns := range w.ExcludeNamespaces {
+		for _, n := range w.Namespaces {
+			if ns == n {
+				return fmt.Errorf("invalid watch parameters: namespace %q is both watched and excluded", ns)
+			}
+		}
+	}
+
+	if _, err := labels.Parse(w.LabelSelector); err != nil {
+		return fmt.Errorf("invalid watch label selector %q: %w", w.LabelSelector, err)
+	}
+
+	return nil
+}
+
+// QuotaParameters limits the virtual hosts, routes, and includes that
+// the HTTPProxies in each namespace may contribute. HTTPProxies are
+// counted oldest first; those that would exceed the quota of their
+// namespace are marked invalid.
+type QuotaParameters struct {
+	// Default is the quota of namespaces not listed in Namespaces.
+	Default QuotaLimits `yaml:"default,omitempty"`
+
+	// Namespaces is the quota of each namespace listed.
+	Namespaces map[string]QuotaLimits `yaml:"namespaces,omitempty"`
+}
+
+// QuotaLimits are the limits of a namespace quota.
+// A limit that is not specified, or 0, is unlimited.
+type QuotaLimits struct {
+	// VirtualHosts is the maximum number of root HTTPProxies.
+	VirtualHosts int `yaml:"virtual-hosts,omitempty"`
+
+	// Routes is the maximum number of routes.
+	Routes int `yaml:"routes,omitempty"`
+
+	// Includes is the maximum number of includes.
+	Includes int `yaml:"includes,omitempty"`
+}
+
+// Validate ensures that the quota limits are valid.
+func (q QuotaLimits) Validate() error {
+	if q.VirtualHosts < 0 || q.Routes < 0 || q.Includes < 0 {
+		return errors.New("invalid quota: limits must not be negative")
+	}
+	return nil
+}
+
+// Validate ensures that the quota parameters are valid.
+func (q QuotaParameters) Validate() error {
+	if err := q.Default.Validate(); err != nil {
+		return err
+	}
+	for ns, limits := range q.Namespaces {
+		if err := limits.Validate(); err != nil {
+			return fmt.Errorf("namespace %q: %w", ns, err)
+		}
+	}
+	return nil
 }
 
 // RateLimitService defines properties of a global Rate Limit Service.
@@ -670,13 +1608,109 @@ type RateLimitService struct {
 	EnableXRateLimitHeaders bool `yaml:"enableXRateLimitHeaders,omitempty"`
 }
 
+// TracingProvider is the name of a supported tracing provider.
+type TracingProvider string
+
+func (t TracingProvider) Validate() error {
+	switch t {
+	case "", ZipkinTracingProvider, OpenCensusTracingProvider:
+		return nil
+	default:
+		return fmt.Errorf("invalid tracing provider %q", t)
+	}
+}
+
+// ZipkinTracingProvider exports spans to a Zipkin compatible collector
+// over HTTP.
+const ZipkinTracingProvider TracingProvider = "zipkin"
+
+// OpenCensusTracingProvider exports spans to an OpenCensus agent over GRPC.
+const OpenCensusTracingProvider TracingProvider = "opencensus"
+
+// TracingConfig defines properties of request tracing.
+type TracingConfig struct {
+	// Provider is the tracer Envoy uses to export spans.
+	// Values are 'zipkin' or 'opencensus'.
+	// If not set, defaults to 'zipkin'.
+	Provider TracingProvider `yaml:"provider,omitempty"`
+
+	// ExtensionService identifies the extension service defining the
+	// trace collector, formatted as <namespace>/<name>.
+	ExtensionService string `yaml:"extensionService,omitempty"`
+
+	// CollectorEndpoint is the path spans are sent to by the Zipkin
+	// provider. If not set, defaults to '/api/v2/spans'.
+	CollectorEndpoint string `yaml:"collectorEndpoint,omitempty"`
+
+	// SamplingPercentage is the percentage of requests, between 0
+	// and 100, that are traced. If not set, all requests are traced.
+	SamplingPercentage *float64 `yaml:"samplingPercentage,omitempty"`
+
+	// CustomTags are tags added to each span.
+	CustomTags []CustomTag `yaml:"customTags,omitempty"`
+}
+
+// CustomTag defines a tag added to each span, with either a literal
+// value or the value of a request header.
+type CustomTag struct {
+	// TagName is the name of the tag.
+	TagName string `yaml:"tagName"`
+
+	// Literal is the value of the tag.
+	Literal string `yaml:"literal,omitempty"`
+
+	// RequestHeaderName is the name of the request header whose
+	// value is the value of the tag.
+	RequestHeaderName string `yaml:"requestHeaderName,omitempty"`
+}
+
+// Validate ensures that the tracing configuration is valid.
+func (t *TracingConfig) Validate() error {
+	if t == nil {
+		return nil
+	}
+
+	if err := t.Provider.Validate(); err != nil {
+		return err
+	}
+
+	if parts := strings.Split(t.ExtensionService, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("invalid tracing extension service %q, must be formatted as <namespace>/<name>", t.ExtensionService)
+	}
+
+	if t.CollectorEndpoint != "" && t.Provider == OpenCensusTracingProvider {
+		return errors.New("tracing collector endpoint is not supported by the opencensus provider")
+	}
+
+	if p := t.SamplingPercentage; p != nil && (*p < 0 || *p > 100) {
+		return fmt.Errorf("invalid tracing sampling percentage %v, must be between 0 and 100", *p)
+	}
+
+	tags := map[string]bool{}
+	for _, tag := range t.CustomTags {
+		if tag.TagName == "" {
+			return errors.New("tracing custom tag name must be specified")
+		}
+		if tags[tag.TagName] {
+			return fmt.Errorf("duplicate tracing custom tag %q", tag.TagName)
+		}
+		tags[tag.TagName] = true
+
+		if (tag.Literal == "") == (tag.RequestHeaderName == "") {
+			return fmt.Errorf("tracing custom tag %q must specify exactly one of literal or requestHeaderName", tag.TagName)
+		}
+	}
+
+	return nil
+}
+
 // Validate verifies that the parameter values do not have any syntax errors.
 func (p *Parameters) Validate() error {
 	if err := p.Cluster.DNSLookupFamily.Validate(); err != nil {
 		return err
 	}
 
-	if err := p.Server.XDSServerType.Validate(); err != nil {
+	if err := p.Server.Validate(); err != nil {
 		return err
 	}
 
@@ -696,10 +1730,22 @@ func (p *Parameters) Validate() error {
 		return err
 	}
 
+	if err := p.TCPAccessLogFields.Validate(); err != nil {
+		return err
+	}
+
+	if err := validateAccessLogFormatString(p.TCPAccessLogFormatString); err != nil {
+		return err
+	}
+
 	if err := p.TLS.Validate(); err != nil {
 		return err
 	}
 
+	if err := p.DefaultHTTPProxy.Validate(); err != nil {
+		return fmt.Errorf("invalid default HTTPProxy: %w", err)
+	}
+
 	if err := p.Timeouts.Validate(); err != nil {
 		return err
 	}
@@ -708,12 +1754,74 @@ func (p *Parameters) Validate() error {
 		return err
 	}
 
+	if err := p.Listener.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.Tracing.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.Network.RequestID.Validate(); err != nil {
+		return err
+	}
+
 	for _, v := range p.DefaultHTTPVersions {
 		if err := v.Validate(); err != nil {
 			return err
 		}
 	}
 
+	if p.MaxRemovalPercent < 0 || p.MaxRemovalPercent > 100 {
+		return fmt.Errorf("invalid max removal percent %d, must be between 0 and 100", p.MaxRemovalPercent)
+	}
+
+	if err := p.Holdoff.Validate(); err != nil {
+		return err
+	}
+
+	if p.HTTPProxyWorkers < 0 {
+		return fmt.Errorf("invalid httpproxy workers %d, must not be negative", p.HTTPProxyWorkers)
+	}
+
+	if p.MaxIncludeDepth < 0 {
+		return fmt.Errorf("invalid max include depth %d, must not be negative", p.MaxIncludeDepth)
+	}
+
+	if err := p.Watch.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.Quotas.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.StatusUpdates.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.Shard.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.PermitInsecure.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.Tap.Validate(); err != nil {
+		return err
+	}
+
+	if err := p.GeoIP.Validate(); err != nil {
+		return err
+	}
+
+	for key := range p.Runtime {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("invalid runtime key, must not be empty")
+		}
+	}
+
 	return nil
 }
 
@@ -762,6 +1870,10 @@ func Defaults() Parameters {
 		Listener: ListenerParameters{
 			ConnectionBalancer: "",
 		},
+		Holdoff: HoldoffParameters{
+			Delay:    100 * time.Millisecond,
+			MaxDelay: 500 * time.Millisecond,
+		},
 	}
 }
 
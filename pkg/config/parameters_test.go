@@ -85,6 +85,9 @@ envoy-service-name: envoy
 default-http-versions: []
 cluster:
   dns-lookup-family: auto
+holdoff:
+  delay: 100ms
+  max-delay: 500ms
 `
 	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(string(data)))
 
@@ -161,6 +164,33 @@ func TestValidateServerType(t *testing.T) {
 	assert.NoError(t, ContourServerType.Validate())
 }
 
+func TestValidateServerParameters(t *testing.T) {
+	assert.NoError(t, ServerParameters{XDSServerType: ContourServerType, VHDS: true}.Validate())
+	assert.Error(t, ServerParameters{XDSServerType: EnvoyServerType, VHDS: true}.Validate())
+	assert.NoError(t, ServerParameters{XDSServerType: EnvoyServerType}.Validate())
+
+	assert.NoError(t, ServerParameters{
+		XDSServerType: ContourServerType,
+		Nodes:         []XDSNodeParameters{{ID: "envoy-internal-*", SecretNamespaces: []string{"internal"}}},
+	}.Validate())
+	assert.Error(t, ServerParameters{
+		XDSServerType: EnvoyServerType,
+		Nodes:         []XDSNodeParameters{{ID: "envoy-internal-*", SecretNamespaces: []string{"internal"}}},
+	}.Validate())
+	assert.NoError(t, ServerParameters{
+		XDSServerType: EnvoyServerType,
+		Nodes:         []XDSNodeParameters{{ID: "envoy-internal-*", SubjectAltNames: []string{"envoy.internal"}}},
+	}.Validate())
+	assert.Error(t, ServerParameters{
+		XDSServerType: ContourServerType,
+		Nodes:         []XDSNodeParameters{{SubjectAltNames: []string{"envoy.internal"}}},
+	}.Validate())
+	assert.Error(t, ServerParameters{
+		XDSServerType: ContourServerType,
+		Nodes:         []XDSNodeParameters{{ID: "envoy-[internal"}},
+	}.Validate())
+}
+
 func TestValidateGatewayParameters(t *testing.T) {
 	// Namespace and controllerName are required if name is passed.
 	gw := &GatewayParameters{Name: "gwname", Namespace: "", ControllerName: ""}
@@ -216,6 +246,11 @@ func TestValidateAccessLogFields(t *testing.T) {
 		{"invalid=%RESP%"},
 		{"invalid=%REQ_WITHOUT_QUERY%"},
 		{"@timestamp", "invalid=%START_TIME(%s.%6f):10%"},
+		{"invalid=%DYNAMIC_METADATA%"},
+		{"invalid=%DYNAMIC_METADATA(envoy.filters.http.ext_authz::user)%"},
+		{"invalid=%FILTER_STATE%"},
+		{"invalid=%FILTER_STATE(:PLAIN)%"},
+		{"invalid=%FILTER_STATE(my.state:JSON)%"},
 	}
 
 	for _, c := range errorCases {
@@ -235,6 +270,10 @@ func TestValidateAccessLogFields(t *testing.T) {
 		{"@timestamp", "trailer=%TRAILER(CONTENT-LENGTH):10%"},
 		{"@timestamp", "duration=my durations are %DURATION%.0 and method is %REQ(:METHOD)%"},
 		{"path=%REQ_WITHOUT_QUERY(X-ENVOY-ORIGINAL-PATH?:PATH)%"},
+		{"authz=%DYNAMIC_METADATA(envoy.filters.http.ext_authz)%"},
+		{"user=%DYNAMIC_METADATA(envoy.filters.http.ext_authz:user:name):64%"},
+		{"state=%FILTER_STATE(my.state)%"},
+		{"state=%FILTER_STATE(my.state:PLAIN)%"},
 		{"dog=pug", "cat=black"},
 	}
 
@@ -253,6 +292,28 @@ func TestValidateHTTPVersionType(t *testing.T) {
 	assert.NoError(t, HTTPVersion2.Validate())
 }
 
+func TestValidatePolicyParameters(t *testing.T) {
+	assert.NoError(t, PolicyParameters{}.Validate())
+	assert.NoError(t, PolicyParameters{
+		RetryPolicy: &RetryPolicy{
+			NumRetries:           3,
+			PerTryTimeout:        "250ms",
+			RetryOn:              []string{"gateway-error", "retriable-status-codes"},
+			RetriableStatusCodes: []uint32{503},
+		},
+		TimeoutPolicy: &TimeoutPolicy{
+			Response: "30s",
+			Idle:     "infinity",
+		},
+	}.Validate())
+
+	assert.Error(t, PolicyParameters{RetryPolicy: &RetryPolicy{PerTryTimeout: "infinity"}}.Validate())
+	assert.Error(t, PolicyParameters{RetryPolicy: &RetryPolicy{RetryOn: []string{"4xx"}}}.Validate())
+	assert.Error(t, PolicyParameters{RetryPolicy: &RetryPolicy{RetriableStatusCodes: []uint32{99}}}.Validate())
+	assert.Error(t, PolicyParameters{TimeoutPolicy: &TimeoutPolicy{Response: "foo"}}.Validate())
+	assert.Error(t, PolicyParameters{TimeoutPolicy: &TimeoutPolicy{Idle: "bar"}}.Validate())
+}
+
 func TestValidateTimeoutParams(t *testing.T) {
 	assert.NoError(t, TimeoutParameters{}.Validate())
 	assert.NoError(t, TimeoutParameters{
@@ -281,6 +342,237 @@ func TestValidateTimeoutParams(t *testing.T) {
 
 }
 
+func TestValidateListenerParameters(t *testing.T) {
+	assert.NoError(t, ListenerParameters{}.Validate())
+	assert.NoError(t, ListenerParameters{AcceptHTTP10: true}.Validate())
+	assert.NoError(t, ListenerParameters{
+		AcceptHTTP10:         true,
+		DefaultHostForHTTP10: "legacy.example.com",
+	}.Validate())
+
+	assert.Error(t, ListenerParameters{DefaultHostForHTTP10: "legacy.example.com"}.Validate())
+
+	assert.NoError(t, ListenerParameters{InsecureListener: InsecureListenerRedirect}.Validate())
+	assert.NoError(t, ListenerParameters{InsecureListener: InsecureListenerDisabled}.Validate())
+	assert.Error(t, ListenerParameters{InsecureListener: "off"}.Validate())
+
+	assert.NoError(t, ListenerParameters{
+		TCPListeners: []TCPListener{
+			{Name: "redis", Port: 6379},
+			{Name: "postgres", Address: "0.0.0.0", Port: 5432},
+		},
+	}.Validate())
+	assert.Error(t, ListenerParameters{TCPListeners: []TCPListener{{Port: 6379}}}.Validate())
+	assert.Error(t, ListenerParameters{TCPListeners: []TCPListener{{Name: "ingress_http", Port: 6379}}}.Validate())
+	assert.Error(t, ListenerParameters{TCPListeners: []TCPListener{{Name: "redis", Port: 0}}}.Validate())
+	assert.Error(t, ListenerParameters{TCPListeners: []TCPListener{{Name: "redis", Port: 65536}}}.Validate())
+	assert.Error(t, ListenerParameters{
+		TCPListeners: []TCPListener{
+			{Name: "redis", Port: 6379},
+			{Name: "redis", Port: 6380},
+		},
+	}.Validate())
+	assert.Error(t, ListenerParameters{
+		TCPListeners: []TCPListener{
+			{Name: "redis", Port: 6379},
+			{Name: "redis-replica", Port: 6379},
+		},
+	}.Validate())
+
+	assert.NoError(t, ListenerParameters{
+		HTTPListeners:  []HTTPListener{{Name: "legacy_http", Port: 8081}},
+		HTTPSListeners: []HTTPListener{{Name: "legacy_https", Address: "0.0.0.0", Port: 8444}},
+	}.Validate())
+	assert.Error(t, ListenerParameters{HTTPListeners: []HTTPListener{{Port: 8081}}}.Validate())
+	assert.Error(t, ListenerParameters{HTTPSListeners: []HTTPListener{{Name: "ingress_https", Port: 8444}}}.Validate())
+	assert.Error(t, ListenerParameters{HTTPSListeners: []HTTPListener{{Name: "legacy/https", Port: 8444}}}.Validate())
+	assert.Error(t, ListenerParameters{HTTPListeners: []HTTPListener{{Name: "legacy_http", Port: 0}}}.Validate())
+	assert.Error(t, ListenerParameters{
+		HTTPListeners:  []HTTPListener{{Name: "legacy", Port: 8081}},
+		HTTPSListeners: []HTTPListener{{Name: "legacy", Port: 8444}},
+	}.Validate())
+	assert.Error(t, ListenerParameters{
+		TCPListeners:   []TCPListener{{Name: "redis", Port: 6379}},
+		HTTPSListeners: []HTTPListener{{Name: "legacy_https", Port: 6379}},
+	}.Validate())
+
+	assert.NoError(t, ListenerParameters{
+		TCPListeners: []TCPListener{{Name: "redis", Port: 6379}},
+		MirrorPort:   8009,
+	}.Validate())
+	assert.Error(t, ListenerParameters{MirrorPort: 65536}.Validate())
+	assert.Error(t, ListenerParameters{TCPListeners: []TCPListener{{Name: "ingress_mirror", Port: 6379}}}.Validate())
+	assert.Error(t, ListenerParameters{
+		TCPListeners: []TCPListener{{Name: "redis", Port: 6379}},
+		MirrorPort:   6379,
+	}.Validate())
+	assert.Error(t, ListenerParameters{
+		HTTPListeners: []HTTPListener{{Name: "legacy_http", Port: 8009}},
+	}.Validate())
+	assert.NoError(t, ListenerParameters{
+		HTTPListeners: []HTTPListener{{Name: "legacy_http", Port: 8009}},
+		MirrorPort:    8010,
+	}.Validate())
+
+	backlog := uint32(4096)
+	assert.NoError(t, ListenerParameters{
+		TCPListeners: []TCPListener{{Name: "redis", Port: 6379}},
+		Overrides: map[string]ListenerOverrides{
+			"ingress_https": {ConnectionBalancer: "exact", Backlog: &backlog},
+			"redis":         {ConnectionBalancer: "none"},
+		},
+	}.Validate())
+	assert.Error(t, ListenerParameters{
+		Overrides: map[string]ListenerOverrides{
+			"redis": {ConnectionBalancer: "exact"},
+		},
+	}.Validate())
+	assert.Error(t, ListenerParameters{
+		Overrides: map[string]ListenerOverrides{
+			"ingress_http": {ConnectionBalancer: "random"},
+		},
+	}.Validate())
+}
+
+func TestValidateRequestIDParameters(t *testing.T) {
+	assert.NoError(t, RequestIDParameters{}.Validate())
+	assert.NoError(t, RequestIDParameters{Policy: GenerateRequestIDPolicy}.Validate())
+	assert.NoError(t, RequestIDParameters{Policy: PreserveRequestIDPolicy, Header: "x-cdn-request-id"}.Validate())
+
+	assert.Error(t, RequestIDParameters{Policy: "always"}.Validate())
+	assert.Error(t, RequestIDParameters{Header: "inv@lid-header"}.Validate())
+	assert.Error(t, RequestIDParameters{Header: "X-Request-Id"}.Validate())
+}
+
+func TestValidateHoldoffParameters(t *testing.T) {
+	assert.NoError(t, HoldoffParameters{}.Validate())
+	assert.NoError(t, Defaults().Holdoff.Validate())
+	assert.NoError(t, HoldoffParameters{Delay: time.Second, MaxDelay: time.Second}.Validate())
+
+	assert.Error(t, HoldoffParameters{Delay: -time.Second}.Validate())
+	assert.Error(t, HoldoffParameters{Delay: time.Second, MaxDelay: 500 * time.Millisecond}.Validate())
+}
+
+func TestValidateWatchParameters(t *testing.T) {
+	assert.NoError(t, WatchParameters{}.Validate())
+	assert.NoError(t, WatchParameters{
+		Namespaces:        []string{"a", "b"},
+		ExcludeNamespaces: []string{"c"},
+		LabelSelector:     "team in (a,b),!legacy",
+	}.Validate())
+
+	assert.Error(t, WatchParameters{Namespaces: []string{"a"}, ExcludeNamespaces: []string{"a"}}.Validate())
+	assert.Error(t, WatchParameters{LabelSelector: "team in a"}.Validate())
+}
+
+func TestValidateShardParameters(t *testing.T) {
+	assert.NoError(t, ShardParameters{}.Validate())
+	assert.NoError(t, ShardParameters{
+		Namespaces: []string{"prod", "prod-eu"},
+		Selector:   "environment in (prod),!canary",
+	}.Validate())
+
+	assert.Error(t, ShardParameters{Selector: "environment in prod"}.Validate())
+}
+
+func TestValidatePermitInsecureParameters(t *testing.T) {
+	assert.NoError(t, PermitInsecureParameters{}.Validate())
+	assert.NoError(t, PermitInsecureParameters{
+		Namespaces: []string{"legacy"},
+		Selector:   "contour.example.com/permit-insecure=true",
+	}.Validate())
+
+	assert.Error(t, PermitInsecureParameters{Selector: "legacy in true"}.Validate())
+}
+
+func TestValidateTapParameters(t *testing.T) {
+	assert.NoError(t, TapParameters{}.Validate())
+	assert.NoError(t, TapParameters{
+		Namespaces:    []string{"debug"},
+		FileDirectory: "/var/log/envoy/tap",
+	}.Validate())
+
+	assert.Error(t, TapParameters{FileDirectory: "tap"}.Validate())
+}
+
+func TestValidateGeoIPParameters(t *testing.T) {
+	assert.NoError(t, GeoIPParameters{}.Validate())
+	assert.NoError(t, GeoIPParameters{
+		CountryDatabase: "/var/lib/geoip/GeoLite2-Country.mmdb",
+		ASNDatabase:     "/var/lib/geoip/GeoLite2-ASN.mmdb",
+	}.Validate())
+
+	assert.Error(t, GeoIPParameters{CountryDatabase: "GeoLite2-Country.mmdb"}.Validate())
+	assert.Error(t, GeoIPParameters{ASNDatabase: "GeoLite2-ASN.mmdb"}.Validate())
+}
+
+func TestValidateQuotaParameters(t *testing.T) {
+	assert.NoError(t, QuotaParameters{}.Validate())
+	assert.NoError(t, QuotaParameters{
+		Default: QuotaLimits{VirtualHosts: 10, Routes: 100},
+		Namespaces: map[string]QuotaLimits{
+			"team-a": {Includes: 5},
+		},
+	}.Validate())
+
+	assert.Error(t, QuotaParameters{Default: QuotaLimits{Routes: -1}}.Validate())
+	assert.Error(t, QuotaParameters{
+		Namespaces: map[string]QuotaLimits{
+			"team-a": {VirtualHosts: -1},
+		},
+	}.Validate())
+}
+
+func TestValidateTracingConfig(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+
+	var nilConfig *TracingConfig
+	assert.NoError(t, nilConfig.Validate())
+
+	assert.NoError(t, (&TracingConfig{ExtensionService: "projectcontour/zipkin"}).Validate())
+	assert.NoError(t, (&TracingConfig{
+		Provider:           OpenCensusTracingProvider,
+		ExtensionService:   "projectcontour/otel-collector",
+		SamplingPercentage: percent(12.5),
+		CustomTags: []CustomTag{
+			{TagName: "cluster", Literal: "prod"},
+			{TagName: "customer", RequestHeaderName: "x-customer-id"},
+		},
+	}).Validate())
+
+	assert.Error(t, (&TracingConfig{}).Validate())
+	assert.Error(t, (&TracingConfig{ExtensionService: "zipkin"}).Validate())
+	assert.Error(t, (&TracingConfig{Provider: "xray", ExtensionService: "projectcontour/zipkin"}).Validate())
+	assert.Error(t, (&TracingConfig{
+		Provider:          OpenCensusTracingProvider,
+		ExtensionService:  "projectcontour/otel-collector",
+		CollectorEndpoint: "/api/v2/spans",
+	}).Validate())
+	assert.Error(t, (&TracingConfig{
+		ExtensionService:   "projectcontour/zipkin",
+		SamplingPercentage: percent(101),
+	}).Validate())
+	assert.Error(t, (&TracingConfig{
+		ExtensionService: "projectcontour/zipkin",
+		CustomTags:       []CustomTag{{Literal: "prod"}},
+	}).Validate())
+	assert.Error(t, (&TracingConfig{
+		ExtensionService: "projectcontour/zipkin",
+		CustomTags:       []CustomTag{{TagName: "cluster"}},
+	}).Validate())
+	assert.Error(t, (&TracingConfig{
+		ExtensionService: "projectcontour/zipkin",
+		CustomTags:       []CustomTag{{TagName: "cluster", Literal: "prod", RequestHeaderName: "x-cluster"}},
+	}).Validate())
+	assert.Error(t, (&TracingConfig{
+		ExtensionService: "projectcontour/zipkin",
+		CustomTags: []CustomTag{
+			{TagName: "cluster", Literal: "prod"},
+			{TagName: "cluster", Literal: "staging"},
+		},
+	}).Validate())
+}
+
 func TestTLSParametersValidation(t *testing.T) {
 	// Fallback certificate validation
 	assert.NoError(t, TLSParameters{
@@ -339,6 +631,28 @@ func TestTLSParametersValidation(t *testing.T) {
 			"AES128-GCM-SHA256",
 		},
 	}.Validate())
+
+	// SPIFFE validation
+	assert.NoError(t, TLSParameters{
+		SPIFFE: SPIFFEParameters{
+			ID:          "spiffe://example.org/ns/projectcontour/sa/envoy",
+			TrustDomain: "spiffe://example.org",
+		},
+	}.Validate())
+	assert.Error(t, TLSParameters{
+		SPIFFE: SPIFFEParameters{
+			TrustDomain: "example.org",
+		},
+	}.Validate())
+	assert.Error(t, TLSParameters{
+		ClientCertificate: NamespacedName{
+			Name:      "envoy",
+			Namespace: "projectcontour",
+		},
+		SPIFFE: SPIFFEParameters{
+			ID: "spiffe://example.org/ns/projectcontour/sa/envoy",
+		},
+	}.Validate())
 }
 
 func TestSanitizeCipherSuites(t *testing.T) {
@@ -420,6 +734,11 @@ tls:
     name: foo
 `)
 
+	check(`
+default-httpproxy:
+  namespace: projectcontour
+`)
+
 	check(`
 tls:
   cipher-suites:
@@ -434,6 +753,67 @@ timeouts:
 	check(`
 default-http-versions:
 - http/0.9
+`)
+
+	check(`
+max-removal-percent: 101
+`)
+
+	check(`
+max-removal-percent: -1
+`)
+
+	check(`
+holdoff:
+  delay: 2s
+`)
+
+	check(`
+httpproxy-workers: -1
+`)
+
+	check(`
+max-include-depth: -1
+`)
+
+	check(`
+watch:
+  label-selector: "=a"
+`)
+
+	check(`
+quotas:
+  default:
+    routes: -1
+`)
+
+	check(`
+status-updates:
+  qps: -1
+`)
+
+	check(`
+shard:
+  selector: "=prod"
+`)
+
+	check(`
+runtime:
+  "": true
+`)
+
+	check(`
+status-updates:
+  burst: -1
+`)
+
+	check(`
+tcp-accesslog-format-string: "%UPSTREAM_HOST%"
+`)
+
+	check(`
+tcp-json-fields:
+- bad
 `)
 
 }
@@ -486,6 +866,17 @@ tls:
   - ECDHE-RSA-AES256-GCM-SHA384
 `)
 
+	check(func(t *testing.T, conf *Parameters) {
+		assert.Equal(t, map[string]string{
+			"envoy.reloadable_features.http_reject_path_with_fragment": "false",
+			"overload.global_downstream_max_connections":               "50000",
+		}, conf.Runtime)
+	}, `
+runtime:
+  envoy.reloadable_features.http_reject_path_with_fragment: false
+  overload.global_downstream_max_connections: 50000
+`)
+
 	check(func(t *testing.T, conf *Parameters) {
 		assert.Equal(t, "foo", conf.LeaderElection.Name)
 		assert.Equal(t, "bar", conf.LeaderElection.Namespace)
@@ -531,6 +922,61 @@ default-http-versions:
 network:
   num-trusted-hops: 1
 `)
+
+	check(func(t *testing.T, conf *Parameters) {
+		assert.True(t, conf.Network.SkipXffAppend)
+	}, `
+network:
+  skip-xff-append: true
+`)
+
+	check(func(t *testing.T, conf *Parameters) {
+		assert.Equal(t, &TCPKeepaliveParameters{
+			Probes:   3,
+			Time:     60,
+			Interval: 10,
+		}, conf.Cluster.TCPKeepalive)
+	}, `
+cluster:
+  tcp-keepalive:
+    probes: 3
+    time: 60
+    interval: 10
+`)
+
+	check(func(t *testing.T, conf *Parameters) {
+		assert.Equal(t, &ZoneAwareRoutingParameters{
+			Enabled:                true,
+			MinClusterSize:         3,
+			OverprovisioningFactor: 200,
+		}, conf.Cluster.ZoneAwareRouting)
+	}, `
+cluster:
+  zone-aware-routing:
+    enabled: true
+    min-cluster-size: 3
+    overprovisioning-factor: 200
+`)
+
+	check(func(t *testing.T, conf *Parameters) {
+		tenPercent := 10.0
+		assert.Equal(t, &TracingConfig{
+			Provider:           ZipkinTracingProvider,
+			ExtensionService:   "projectcontour/zipkin",
+			SamplingPercentage: &tenPercent,
+			CustomTags: []CustomTag{
+				{TagName: "customer", RequestHeaderName: "x-customer-id"},
+			},
+		}, conf.Tracing)
+	}, `
+tracing:
+  provider: zipkin
+  extensionService: projectcontour/zipkin
+  samplingPercentage: 10
+  customTags:
+  - tagName: customer
+    requestHeaderName: x-customer-id
+`)
 }
 
 func TestAccessLogFormatString(t *testing.T) {
@@ -545,6 +991,8 @@ func TestAccessLogFormatString(t *testing.T) {
 		"%RESP%\n",
 		"%REQ_WITHOUT_QUERY%\n",
 		"%START_TIME(%s.%6f):10%\n",
+		"%DYNAMIC_METADATA%\n",
+		"%FILTER_STATE(my.state:JSON)%\n",
 		"no newline at the end",
 	}
 
@@ -562,6 +1010,8 @@ func TestAccessLogFormatString(t *testing.T) {
 		"%TRAILER(CONTENT-LENGTH):10%\n",
 		"my durations are %DURATION%.0 and method is %REQ(:METHOD)%\n",
 		"queries %REQ_WITHOUT_QUERY(X-ENVOY-ORIGINAL-PATH?:PATH)% removed\n",
+		"user %DYNAMIC_METADATA(envoy.filters.http.ext_authz:user)%\n",
+		"state %FILTER_STATE(my.state:TYPED)%\n",
 		"just a string\n",
 	}
 
@@ -586,4 +1036,10 @@ func TestAccessLogFormatExtensions(t *testing.T) {
 
 	p3 := Defaults()
 	assert.Empty(t, p3.AccessLogFormatterExtensions())
+
+	p4 := Parameters{
+		AccessLogFormat:          EnvoyAccessLog,
+		TCPAccessLogFormatString: "[%START_TIME%] \"%REQ_WITHOUT_QUERY(X-ENVOY-ORIGINAL-PATH?:PATH)%\"\n",
+	}
+	assert.Equal(t, []string{"envoy.formatter.req_without_query"}, p4.AccessLogFormatterExtensions())
 }